@@ -8,11 +8,10 @@ import (
 )
 
 func main() {
-	cfg := app.DefaultConfig()
+	cfg := app.AutoTuneContext(app.DefaultConfig())
 
+	// StartWeb stops the background workers and closes lw and db on shutdown.
 	db, lw := app.MustInit(cfg)
-	defer lw.Close()
-	defer db.Close()
 
 	fmt.Printf("Web listening on http://%s/\n", cfg.HTTPAddr)
 
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Answer style
+// - style: concise | detailed | bullet (none = no instruction)
+// - max_sentences: hard cap stated in the prompt + a max_tokens budget
+// - Resolution: request > stored profile default > Config (env)
+// - Profile defaults live in answer_style_profiles; /style edits "default".
+// ============================================================
+
+const (
+	answerStyleNone     = "none"
+	answerStyleConcise  = "concise"
+	answerStyleDetailed = "detailed"
+	answerStyleBullet   = "bullet"
+
+	defaultAnswerProfile = "default"
+
+	// max_tokens budget per allowed sentence (CJK sentences are token-heavy).
+	answerTokensPerSentence = 120
+)
+
+type AnswerStyle struct {
+	Style        string `json:"style,omitempty"`
+	MaxSentences int    `json:"max_sentences,omitempty"`
+}
+
+func normalizeAnswerStyle(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", answerStyleNone, answerStyleConcise, answerStyleDetailed, answerStyleBullet:
+		return s, true
+	case "off":
+		return answerStyleNone, true
+	}
+	return "", false
+}
+
+// overlay returns a with the non-zero fields of b applied.
+func (a AnswerStyle) overlay(b AnswerStyle) AnswerStyle {
+	if b.Style != "" {
+		a.Style = b.Style
+	}
+	if b.MaxSentences > 0 {
+		a.MaxSentences = b.MaxSentences
+	}
+	return a
+}
+
+func loadAnswerStyleProfile(db *sql.DB, profile string) (AnswerStyle, error) {
+	var st AnswerStyle
+	if db == nil {
+		return st, nil
+	}
+	err := db.QueryRow(
+		`SELECT style, max_sentences FROM answer_style_profiles WHERE profile=?`, profile,
+	).Scan(&st.Style, &st.MaxSentences)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AnswerStyle{}, nil
+	}
+	return st, err
+}
+
+func saveAnswerStyleProfile(cfg Config, db *sql.DB, profile string, st AnswerStyle) error {
+	_, err := db.Exec(`
+		INSERT INTO answer_style_profiles(profile, style, max_sentences, updated_at)
+		VALUES(?,?,?,?)
+		ON CONFLICT(profile) DO UPDATE SET
+		  style=excluded.style,
+		  max_sentences=excluded.max_sentences,
+		  updated_at=excluded.updated_at
+	`, profile, st.Style, st.MaxSentences, time.Now().In(cfg.Location).Format(time.RFC3339))
+	return err
+}
+
+// effectiveAnswerStyle resolves the style of one turn.
+func effectiveAnswerStyle(cfg Config, db *sql.DB) AnswerStyle {
+	profile := cfg.AnswerProfile
+	if profile == "" {
+		profile = defaultAnswerProfile
+	}
+	st := cfg.AnswerStyle
+	if p, err := loadAnswerStyleProfile(db, profile); err == nil {
+		st = st.overlay(p)
+	}
+	return st.overlay(cfg.AnswerStyleOverride)
+}
+
+// answerStyleInstructions is the system-prompt block for st ("" = none).
+func answerStyleInstructions(st AnswerStyle) string {
+	var b strings.Builder
+	switch st.Style {
+	case answerStyleConcise:
+		b.WriteString("- 简洁作答：先给结论，只补充必要信息；简单问题一两句话即可，不要铺垫和总结段。\n")
+	case answerStyleDetailed:
+		b.WriteString("- 详细作答：给出完整的解释、步骤和必要的例子。\n")
+	case answerStyleBullet:
+		b.WriteString("- 用要点列表作答（每行一个“- ”开头的要点），不要写成段落文章。\n")
+	}
+	if st.MaxSentences > 0 {
+		fmt.Fprintf(&b, "- 回答不超过 %d 句（列表中每个要点算一句）。\n", st.MaxSentences)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "【回答风格】\n" + b.String() + "\n"
+}
+
+// answerMaxTokens maps st to a generation max_tokens (0 = server default).
+func answerMaxTokens(st AnswerStyle) int {
+	if st.MaxSentences > 0 {
+		return st.MaxSentences*answerTokensPerSentence + answerTokensPerSentence
+	}
+	return 0
+}
+
+// runStyleCommand implements /style [concise|detailed|bullet|off] [--max N] (default profile).
+func runStyleCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	profile := cfg.AnswerProfile
+	if profile == "" {
+		profile = defaultAnswerProfile
+	}
+	cur, err := loadAnswerStyleProfile(db, profile)
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		eff := effectiveAnswerStyle(cfg, db)
+		return fmt.Sprintf("profile=%s style=%s max_sentences=%d (effective: style=%s max_sentences=%d)",
+			profile, orDash(cur.Style), cur.MaxSentences, orDash(eff.Style), eff.MaxSentences), nil
+	}
+
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "--max" {
+			if i+1 >= len(fields) {
+				return "usage: /style [concise|detailed|bullet|off] [--max N]", nil
+			}
+			i++
+			n, err := strconv.Atoi(fields[i])
+			if err != nil || n < 0 {
+				return "", fmt.Errorf("invalid --max: %s", fields[i])
+			}
+			cur.MaxSentences = n
+			continue
+		}
+		s, ok := normalizeAnswerStyle(fields[i])
+		if !ok {
+			return "usage: /style [concise|detailed|bullet|off] [--max N]", nil
+		}
+		cur.Style = s
+	}
+	if err := saveAnswerStyleProfile(cfg, db, profile, cur); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[ok] profile=%s style=%s max_sentences=%d", profile, orDash(cur.Style), cur.MaxSentences), nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
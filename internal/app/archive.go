@@ -1,15 +1,138 @@
 package app
 
 import (
+	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
 
+// ============================================================
+// Raw log archive
+// - Days older than KeepRawDays (with a daily summary) leave LogDir: each
+//   day is gzipped into its own object "<YYYY-MM>/<date>.jsonl.gz" on the
+//   configured Archive backend (TIMELAYER_ARCHIVE_BACKEND):
+//     local   (default) files under ArchiveDir
+//     s3      any S3-compatible bucket (AWS, MinIO, R2, ...; path-style)
+//     webdav  Nextcloud / ownCloud / any WebDAV share
+// - archive_manifest records every archived day (backend, key, sizes, line
+//   count, sha256 of the raw JSONL); the local file is only removed once the
+//   object is stored and the manifest row written.
+// - RestoreArchivedDay pulls a day back into LogDir (sha256 checked) so it
+//   can be cited, exported or re-summarized (--reprocess). A restored day is
+//   left in LogDir for KeepRawDays before it is archived again (no re-upload
+//   when unchanged).
+// - CLI: local-ai archive list | restore <date> [--reprocess] [--force]
+//   Chat: /unarchive <date>; API: GET /api/archive, POST /api/archive/restore.
+// - Days archived before the manifest sit in <ArchiveDir>/<YYYY-MM>.jsonl.gz
+//   (all days of the month appended); they are listed as legacy and cannot
+//   be restored per day.
+// ============================================================
+
+const (
+	archiveBackendLocal  = "local"
+	archiveBackendS3     = "s3"
+	archiveBackendWebDAV = "webdav"
+)
+
+// Archive is one archive backend. Keys are slash-separated relative paths.
+type Archive interface {
+	Name() string
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// newArchive returns the backend configured in cfg.
+func newArchive(cfg Config) (Archive, error) {
+	switch cfg.ArchiveBackend {
+	case "", archiveBackendLocal:
+		return &localArchive{dir: cfg.ArchiveDir}, nil
+	case archiveBackendS3:
+		return newS3Archive(cfg)
+	case archiveBackendWebDAV:
+		return newWebDAVArchive(cfg)
+	default:
+		return nil, fmt.Errorf("unknown archive backend %q (local | s3 | webdav)", cfg.ArchiveBackend)
+	}
+}
+
+type localArchive struct{ dir string }
+
+func (a *localArchive) Name() string { return archiveBackendLocal }
+
+func (a *localArchive) path(key string) string {
+	return filepath.Join(a.dir, filepath.FromSlash(key))
+}
+
+func (a *localArchive) Put(key string, data []byte) error {
+	dst := a.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (a *localArchive) Get(key string) ([]byte, error) { return os.ReadFile(a.path(key)) }
+
+func (a *localArchive) Delete(key string) error {
+	if err := os.Remove(a.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+type ArchiveEntry struct {
+	Day         string `json:"day"`
+	Backend     string `json:"backend"`
+	Key         string `json:"key"`
+	Bytes       int64  `json:"bytes"`        // raw JSONL
+	StoredBytes int64  `json:"stored_bytes"` // gzipped object
+	Lines       int    `json:"lines"`
+	SHA256      string `json:"sha256"`
+	ArchivedAt  string `json:"archived_at"`
+	RestoredAt  string `json:"restored_at,omitempty"`
+	InLogDir    bool   `json:"in_log_dir"`
+}
+
+type LegacyArchive struct {
+	Month string `json:"month"`
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+type ArchiveListing struct {
+	Backend string          `json:"backend"`
+	Days    []ArchiveEntry  `json:"days"`
+	Legacy  []LegacyArchive `json:"legacy,omitempty"`
+}
+
+type ArchiveRestoreReport struct {
+	Day         string `json:"day"`
+	Path        string `json:"path"`
+	Lines       int    `json:"lines"`
+	Backend     string `json:"backend"`
+	Reprocessed bool   `json:"reprocessed,omitempty"`
+}
+
+func archiveKey(date string) string {
+	return date[:7] + "/" + date + ".jsonl.gz"
+}
+
 func forgetAndArchive(cfg Config, db any) error {
 	sqlDB := db.(*sql.DB)
 
@@ -17,9 +140,15 @@ func forgetAndArchive(cfg Config, db any) error {
 	if err != nil {
 		return err
 	}
+	arc, err := newArchive(cfg)
+	if err != nil {
+		return err
+	}
 
-	cutoff := time.Now().In(cfg.Location).AddDate(0, 0, -cfg.KeepRawDays)
+	now := time.Now().In(cfg.Location)
+	cutoff := now.AddDate(0, 0, -cfg.KeepRawDays)
 
+	var firstErr error
 	for _, e := range entries {
 		name := e.Name()
 		if !strings.HasSuffix(name, ".jsonl") {
@@ -37,41 +166,326 @@ func forgetAndArchive(cfg Config, db any) error {
 			continue
 		}
 
-		srcPath := filepath.Join(cfg.LogDir, name)
-		if err := appendToMonthlyArchive(cfg, date, srcPath); err != nil {
+		if err := archiveDay(cfg, sqlDB, arc, date, now, cutoff); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", date, err)
+			}
 			continue
 		}
-		_ = os.Remove(srcPath)
 	}
 
-	return nil
+	return firstErr
 }
 
-func appendToMonthlyArchive(cfg Config, date, srcPath string) error {
-	d, err := time.ParseInLocation("2006-01-02", date, cfg.Location)
+// archiveDay moves one day from LogDir to arc and records it in the manifest.
+func archiveDay(cfg Config, db *sql.DB, arc Archive, date string, now, cutoff time.Time) error {
+	srcPath := filepath.Join(cfg.LogDir, date+".jsonl")
+	raw, err := os.ReadFile(srcPath)
 	if err != nil {
 		return err
 	}
+	sum := sha256.Sum256(raw)
+	hash := hex.EncodeToString(sum[:])
 
-	monthKey := d.Format("2006-01")
-	dstPath := filepath.Join(cfg.ArchiveDir, monthKey+".jsonl.gz")
-	_ = os.MkdirAll(cfg.ArchiveDir, 0755)
-
-	in, err := os.Open(srcPath)
+	prev, found, err := getArchiveEntry(db, date)
 	if err != nil {
 		return err
 	}
-	defer in.Close()
+	if found && prev.RestoredAt != "" {
+		if t, err := time.Parse(time.RFC3339, prev.RestoredAt); err == nil && t.After(cutoff) {
+			return nil // restored recently: leave it in LogDir for now
+		}
+	}
+	if found && prev.SHA256 == hash && prev.Backend == arc.Name() {
+		// unchanged since it was restored: the object is already there
+		_, err := db.Exec(`UPDATE archive_manifest SET restored_at='' WHERE day=?`, date)
+		if err != nil {
+			return err
+		}
+		return os.Remove(srcPath)
+	}
 
-	out, err := os.OpenFile(dstPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	if _, err := gw.Write(raw); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	key := archiveKey(date)
+	if err := arc.Put(key, gz.Bytes()); err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO archive_manifest(day, backend, object_key, bytes, stored_bytes, lines, sha256, archived_at, restored_at)
+		VALUES(?,?,?,?,?,?,?,?, '')
+		ON CONFLICT(day) DO UPDATE SET
+			backend=excluded.backend, object_key=excluded.object_key, bytes=excluded.bytes,
+			stored_bytes=excluded.stored_bytes, lines=excluded.lines, sha256=excluded.sha256,
+			archived_at=excluded.archived_at, restored_at=''
+	`, date, arc.Name(), key, len(raw), gz.Len(), countJSONLLines(raw), hash, now.Format(time.RFC3339))
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	return os.Remove(srcPath)
+}
+
+func countJSONLLines(b []byte) int {
+	n := 0
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+const archiveEntryCols = `day, backend, object_key, bytes, stored_bytes, lines, sha256, archived_at, restored_at`
+
+func scanArchiveEntry(sc interface{ Scan(...any) error }) (ArchiveEntry, error) {
+	var e ArchiveEntry
+	err := sc.Scan(&e.Day, &e.Backend, &e.Key, &e.Bytes, &e.StoredBytes, &e.Lines, &e.SHA256, &e.ArchivedAt, &e.RestoredAt)
+	return e, err
+}
+
+func getArchiveEntry(db *sql.DB, day string) (ArchiveEntry, bool, error) {
+	e, err := scanArchiveEntry(readDB(db).QueryRow(`SELECT `+archiveEntryCols+` FROM archive_manifest WHERE day=?`, day))
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, false, nil
+	}
+	return e, err == nil, err
+}
+
+// ListArchive returns the manifest (newest day first) plus legacy monthly files.
+func ListArchive(cfg Config, db *sql.DB) (ArchiveListing, error) {
+	out := ArchiveListing{Backend: cfg.ArchiveBackend, Days: []ArchiveEntry{}}
+	if out.Backend == "" {
+		out.Backend = archiveBackendLocal
+	}
+	rows, err := readDB(db).Query(`SELECT ` + archiveEntryCols + ` FROM archive_manifest ORDER BY day DESC`)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e, err := scanArchiveEntry(rows)
+		if err != nil {
+			return out, err
+		}
+		if _, err := os.Stat(filepath.Join(cfg.LogDir, e.Day+".jsonl")); err == nil {
+			e.InLogDir = true
+		}
+		out.Days = append(out.Days, e)
+	}
+	if err := rows.Err(); err != nil {
+		return out, err
+	}
+
+	files, _ := os.ReadDir(cfg.ArchiveDir)
+	for _, f := range files {
+		month, ok := strings.CutSuffix(f.Name(), ".jsonl.gz")
+		if !ok || f.IsDir() {
+			continue
+		}
+		if _, err := time.Parse("2006-01", month); err != nil {
+			continue
+		}
+		lg := LegacyArchive{Month: month, Path: filepath.Join(cfg.ArchiveDir, f.Name())}
+		if st, err := f.Info(); err == nil {
+			lg.Bytes = st.Size()
+		}
+		out.Legacy = append(out.Legacy, lg)
+	}
+	sort.Slice(out.Legacy, func(i, j int) bool { return out.Legacy[i].Month > out.Legacy[j].Month })
+	return out, nil
+}
+
+// RestoreArchivedDay copies an archived day back into LogDir. force overwrites
+// an existing file; reprocess regenerates the day's daily summary from it.
+func RestoreArchivedDay(cfg Config, db *sql.DB, date string, force, reprocess bool) (ArchiveRestoreReport, error) {
+	rep := ArchiveRestoreReport{Day: date}
+	if _, err := time.ParseInLocation("2006-01-02", date, cfg.Location); err != nil {
+		return rep, fmt.Errorf("invalid date: %s", date)
+	}
+	e, found, err := getArchiveEntry(db, date)
+	if err != nil {
+		return rep, err
+	}
+	if !found {
+		legacy := filepath.Join(cfg.ArchiveDir, date[:7]+".jsonl.gz")
+		if _, err := os.Stat(legacy); err == nil {
+			return rep, fmt.Errorf("%s was archived before the manifest: it is inside %s with the rest of the month and cannot be restored on its own", date, legacy)
+		}
+		return rep, fmt.Errorf("%s is not in the archive", date)
+	}
+	rep.Backend = e.Backend
+	rep.Path = filepath.Join(cfg.LogDir, date+".jsonl")
+	if _, err := os.Stat(rep.Path); err == nil && !force {
+		return rep, fmt.Errorf("%s already exists (use force to overwrite)", rep.Path)
+	}
+
+	ecfg := cfg
+	ecfg.ArchiveBackend = e.Backend
+	arc, err := newArchive(ecfg)
+	if err != nil {
+		return rep, err
+	}
+	obj, err := arc.Get(e.Key)
+	if err != nil {
+		return rep, fmt.Errorf("fetch %s from %s: %w", e.Key, e.Backend, err)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(obj))
+	if err != nil {
+		return rep, fmt.Errorf("archive object %s: %w", e.Key, err)
+	}
+	raw, err := io.ReadAll(gr)
+	if err != nil {
+		return rep, fmt.Errorf("archive object %s: %w", e.Key, err)
+	}
+	if sum := sha256.Sum256(raw); hex.EncodeToString(sum[:]) != e.SHA256 {
+		return rep, fmt.Errorf("archive object %s: sha256 mismatch, not restored", e.Key)
+	}
+
+	tmp := rep.Path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0644); err != nil {
+		return rep, err
+	}
+	if err := os.Rename(tmp, rep.Path); err != nil {
+		return rep, err
+	}
+	rep.Lines = countJSONLLines(raw)
+	if _, err := db.Exec(`UPDATE archive_manifest SET restored_at=? WHERE day=?`,
+		time.Now().In(cfg.Location).Format(time.RFC3339), date); err != nil {
+		return rep, err
+	}
+
+	if reprocess {
+		// user-run (/unarchive, POST /api/archive/restore): not charged to the background budget
+		if err := ensureDaily(foregroundLLM(cfg), db, date, true); err != nil {
+			return rep, fmt.Errorf("restored, but reprocess failed: %w", err)
+		}
+		rep.Reprocessed = true
+	}
+	return rep, nil
+}
 
-	gw := gzip.NewWriter(out)
-	defer gw.Close()
+// markArchivedHits flags dated summary hits whose raw day is in the archive
+// and not in LogDir (/unarchive it to cite the dialog).
+func markArchivedHits(cfg Config, db *sql.DB, hits []SearchHit) {
+	for i := range hits {
+		h := &hits[i]
+		if h.Type != "daily" || len(h.Date) != len("2006-01-02") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cfg.LogDir, h.Date+".jsonl")); err == nil {
+			continue
+		}
+		if _, found, _ := getArchiveEntry(db, h.Date); found {
+			h.Archived = true
+		}
+	}
+}
 
-	_, err = io.Copy(gw, in)
-	return err
+// deleteArchiveObjects removes every manifest object from its backend (wipe).
+func deleteArchiveObjects(cfg Config, db *sql.DB) (int, error) {
+	list, err := ListArchive(cfg, db)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range list.Days {
+		ecfg := cfg
+		ecfg.ArchiveBackend = e.Backend
+		arc, err := newArchive(ecfg)
+		if err != nil {
+			return n, err
+		}
+		if err := arc.Delete(e.Key); err != nil {
+			return n, fmt.Errorf("delete %s from %s: %w", e.Key, e.Backend, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// runUnarchiveCommand implements /unarchive for both CLI and web.
+func runUnarchiveCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return "usage: /unarchive <YYYY-MM-DD> [--reprocess] [--force]", nil
+	}
+	force, reprocess := false, false
+	for _, f := range fields[1:] {
+		switch f {
+		case "--force":
+			force = true
+		case "--reprocess":
+			reprocess = true
+		default:
+			return "unknown flag: " + f, nil
+		}
+	}
+	rep, err := RestoreArchivedDay(cfg, db, fields[0], force, reprocess)
+	if err != nil {
+		return "", err
+	}
+	out := fmt.Sprintf("[ok] restored %s from %s: %d lines → %s", rep.Day, rep.Backend, rep.Lines, rep.Path)
+	if rep.Reprocessed {
+		out += " (daily summary regenerated)"
+	}
+	return out, nil
+}
+
+// runArchiveCLI implements `local-ai archive list|restore`.
+func runArchiveCLI(cfg Config, args []string) int {
+	usage := "usage: local-ai archive list [--json] | restore <YYYY-MM-DD> [--reprocess] [--force]"
+	if len(args) == 0 {
+		fmt.Println(usage)
+		return 2
+	}
+	db := mustOpenDB(cfg)
+	defer closeDB(db)
+
+	switch args[0] {
+	case "list":
+		list, err := ListArchive(cfg, db)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return 1
+		}
+		if len(args) > 1 && args[1] == "--json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(list)
+			return 0
+		}
+		fmt.Printf("backend: %s, %d archived days\n", list.Backend, len(list.Days))
+		for _, e := range list.Days {
+			mark := ""
+			if e.InLogDir {
+				mark = "  (restored)"
+			}
+			fmt.Printf("%s  %-6s %5d lines  %8d B  %s%s\n", e.Day, e.Backend, e.Lines, e.StoredBytes, e.Key, mark)
+		}
+		for _, lg := range list.Legacy {
+			fmt.Printf("%s     legacy monthly archive  %8d B  %s\n", lg.Month, lg.Bytes, lg.Path)
+		}
+		return 0
+	case "restore":
+		if len(args) < 2 {
+			fmt.Println(usage)
+			return 2
+		}
+		out, err := runUnarchiveCommand(cfg, db, strings.Join(args[1:], " "))
+		if err != nil {
+			fmt.Println("[error]", err)
+			return 1
+		}
+		fmt.Println(out)
+		return 0
+	default:
+		fmt.Println(usage)
+		return 2
+	}
 }
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Remote archive backends (see archive.go)
+// - s3: TIMELAYER_ARCHIVE_S3_ENDPOINT / _BUCKET / _REGION / _PREFIX /
+//   _ACCESS_KEY / _SECRET_KEY. Path-style URLs signed with SigV4, so
+//   MinIO / R2 / B2 work the same as AWS (endpoint e.g.
+//   https://s3.eu-central-1.amazonaws.com).
+// - webdav: TIMELAYER_ARCHIVE_WEBDAV_URL (the base collection) plus
+//   _USER / _PASSWORD (basic auth). Missing month collections are created
+//   with MKCOL.
+// ============================================================
+
+var archiveHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// archiveHTTPError reads a short error body for non-2xx responses.
+func archiveHTTPError(op string, resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s: HTTP %d %s", op, resp.StatusCode, strings.TrimSpace(string(b)))
+}
+
+// ---------- S3 ----------
+
+type s3Archive struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	prefix    string
+	accessKey string
+	secretKey string
+}
+
+func newS3Archive(cfg Config) (*s3Archive, error) {
+	if cfg.ArchiveS3Endpoint == "" || cfg.ArchiveS3Bucket == "" || cfg.ArchiveS3AccessKey == "" || cfg.ArchiveS3SecretKey == "" {
+		return nil, errors.New("s3 archive needs TIMELAYER_ARCHIVE_S3_ENDPOINT, _BUCKET, _ACCESS_KEY and _SECRET_KEY")
+	}
+	u, err := url.Parse(strings.TrimRight(cfg.ArchiveS3Endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.ArchiveS3Endpoint)
+	}
+	region := cfg.ArchiveS3Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	prefix := strings.Trim(cfg.ArchiveS3Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &s3Archive{
+		endpoint:  u,
+		bucket:    cfg.ArchiveS3Bucket,
+		region:    region,
+		prefix:    prefix,
+		accessKey: cfg.ArchiveS3AccessKey,
+		secretKey: cfg.ArchiveS3SecretKey,
+	}, nil
+}
+
+func (a *s3Archive) Name() string { return archiveBackendS3 }
+
+func (a *s3Archive) do(method, key string, body []byte) (*http.Response, error) {
+	u := *a.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + a.bucket + "/" + a.prefix + key
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	a.sign(req, body, time.Now().UTC())
+	return archiveHTTPClient.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers (service s3).
+func (a *s3Archive) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + a.region + "/s3/aws4_request"
+	ch := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(ch[:])
+
+	mac := func(key []byte, s string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(s))
+		return h.Sum(nil)
+	}
+	k := mac([]byte("AWS4"+a.secretKey), day)
+	k = mac(k, a.region)
+	k = mac(k, "s3")
+	k = mac(k, "aws4_request")
+	sig := hex.EncodeToString(mac(k, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+a.accessKey+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+sig)
+}
+
+func (a *s3Archive) Put(key string, data []byte) error {
+	resp, err := a.do(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return archiveHTTPError("s3 put", resp)
+	}
+	return nil
+}
+
+func (a *s3Archive) Get(key string) ([]byte, error) {
+	resp, err := a.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, archiveHTTPError("s3 get", resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (a *s3Archive) Delete(key string) error {
+	resp, err := a.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return archiveHTTPError("s3 delete", resp)
+	}
+	return nil
+}
+
+// ---------- WebDAV ----------
+
+type webdavArchive struct {
+	base     string
+	user     string
+	password string
+}
+
+func newWebDAVArchive(cfg Config) (*webdavArchive, error) {
+	if cfg.ArchiveWebDAVURL == "" {
+		return nil, errors.New("webdav archive needs TIMELAYER_ARCHIVE_WEBDAV_URL")
+	}
+	if u, err := url.Parse(cfg.ArchiveWebDAVURL); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid webdav url %q", cfg.ArchiveWebDAVURL)
+	}
+	return &webdavArchive{
+		base:     strings.TrimRight(cfg.ArchiveWebDAVURL, "/"),
+		user:     cfg.ArchiveWebDAVUser,
+		password: cfg.ArchiveWebDAVPassword,
+	}, nil
+}
+
+func (a *webdavArchive) Name() string { return archiveBackendWebDAV }
+
+func (a *webdavArchive) do(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, a.base+"/"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if a.user != "" {
+		req.SetBasicAuth(a.user, a.password)
+	}
+	return archiveHTTPClient.Do(req)
+}
+
+func (a *webdavArchive) Put(key string, data []byte) error {
+	resp, err := a.do(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusNotFound {
+		// parent collection missing: MKCOL each level, then retry once
+		parts := strings.Split(key, "/")
+		for i := 1; i < len(parts); i++ {
+			mk, err := a.do("MKCOL", strings.Join(parts[:i], "/"), nil)
+			if err != nil {
+				return err
+			}
+			mk.Body.Close()
+		}
+		if resp, err = a.do(http.MethodPut, key, data); err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webdav put: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (a *webdavArchive) Get(key string) ([]byte, error) {
+	resp, err := a.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, archiveHTTPError("webdav get", resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (a *webdavArchive) Delete(key string) error {
+	resp, err := a.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return archiveHTTPError("webdav delete", resp)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"strings"
 )
 
@@ -13,17 +14,112 @@ Public API
 ========================
 */
 
+// AskReference is one memory record an answer is based on (POST /api/ask).
+type AskReference struct {
+	Rank      int     `json:"rank"`
+	Type      string  `json:"type"`
+	Date      string  `json:"date"`
+	Score     float64 `json:"score"`
+	Text      string  `json:"text"`
+	SummaryID int64   `json:"summary_id,omitempty"` // usable as /ask --doc <id>
+	Archived  bool    `json:"archived,omitempty"`
+}
+
+// AskResult is the structured outcome of a memory-grounded question.
+type AskResult struct {
+	Question     string         `json:"question"`
+	Supported    bool           `json:"supported"`
+	Answer       string         `json:"answer"`
+	Unstructured bool           `json:"unstructured,omitempty"` // the model ignored the JSON protocol; answer is its raw output
+	References   []AskReference `json:"references"`
+
+	hits []SearchHit
+}
+
+// maxAskReferences caps the references of a supported answer.
+const maxAskReferences = 10
+
 // Ask answers a question based on user's historical memory.
 // It relies on LLM to explicitly declare whether the answer
 // is supported by memory (supported: true/false).
 func Ask(db *sql.DB, cfg Config, input string) (string, error) {
+	input, scope := parseScopeFlags(input)
+	if !scope.IsZero() {
+		cfg.Scope = scope
+	}
+	input, target, err := parseAskTarget(input)
+	if err != nil {
+		return "", err
+	}
 	question, showRefs := parseAskArgs(input)
 
-	// 1️⃣ semantic search (pure retrieval, no semantics)
-	hits, err := SearchWithScore(db, cfg, question)
+	ar, err := AskQuestion(db, cfg, question, target)
 	if err != nil {
 		return "", err
 	}
+	if ar.Unstructured {
+		// ⛑️ fallback: model didn't follow protocol
+		Speak(ar.Answer)
+		return ar.Answer, nil
+	}
+	hits := ar.hits
+
+	// 6️⃣ build final output
+	var out strings.Builder
+	out.WriteString(ar.Answer)
+
+	// ✅ only attach references when explicitly supported
+	if ar.Supported && len(hits) > 0 {
+		out.WriteString("\n\n——\n")
+		out.WriteString(formatTopReference(hits[0]))
+
+		if showRefs {
+			out.WriteString("\n\n附录 · 相关记录（最多 10 条）：\n")
+			max := min(maxAskReferences, len(hits))
+			for i := 0; i < max; i++ {
+				out.WriteString(formatRefLine(i+1, hits[i]))
+				out.WriteString("\n")
+			}
+		}
+	}
+
+	// TTS only reads core answer
+	Speak(ar.Answer)
+	return out.String(), nil
+}
+
+// AskQuestion runs retrieval + the structured LLM call for question.
+// References are filled only for supported answers.
+func AskQuestion(db *sql.DB, cfg Config, question string, target AskTarget) (*AskResult, error) {
+	hits, prompt, err := prepareAsk(db, cfg, question, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4️⃣ call LLM
+	raw, err := callLLMNonStream(cfg, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return finishAsk(cfg, db, question, raw, hits), nil
+}
+
+// prepareAsk runs retrieval and builds the structured-output prompt.
+func prepareAsk(db *sql.DB, cfg Config, question string, target AskTarget) ([]SearchHit, string, error) {
+	// 1️⃣ semantic search (pure retrieval, no semantics)
+	//    --type/--period/--doc: restrict to one type or pin one summary
+	var (
+		hits []SearchHit
+		err  error
+	)
+	if target.IsZero() {
+		hits, err = SearchWithScore(db, cfg, question)
+	} else {
+		hits, err = askTargetHits(db, cfg, question, target)
+	}
+	if err != nil {
+		return nil, "", err
+	}
 
 	// 2️⃣ build memory context (TopK only)
 	var ctx strings.Builder
@@ -43,49 +139,42 @@ func Ask(db *sql.DB, cfg Config, input string) (string, error) {
 	}
 
 	// 3️⃣ compose prompt (STRUCTURED output)
-	prompt := buildAskPrompt(ctx.String(), question)
-
-	// 4️⃣ call LLM
-	raw, err := callLLMNonStream(cfg, prompt)
-	if err != nil {
-		return "", err
-	}
+	return hits, buildAskPrompt(ctx.String(), question), nil
+}
 
+// finishAsk parses the model output of an ask prompt into an AskResult.
+func finishAsk(cfg Config, db *sql.DB, question, raw string, hits []SearchHit) *AskResult {
 	// 5️⃣ parse structured answer
-	type askResult struct {
+	var ar struct {
 		Supported bool   `json:"supported"`
 		Answer    string `json:"answer"`
 	}
-
-	var ar askResult
+	res := &AskResult{Question: question, References: []AskReference{}, hits: hits}
 	if err := json.Unmarshal([]byte(raw), &ar); err != nil {
-		// ⛑️ fallback: model didn't follow protocol
-		Speak(raw)
-		return raw, nil
+		res.Answer, res.Unstructured = raw, true
+		return res
 	}
+	res.Supported, res.Answer = ar.Supported, ar.Answer
 
-	// 6️⃣ build final output
-	var out strings.Builder
-	out.WriteString(ar.Answer)
-
-	// ✅ only attach references when explicitly supported
-	if ar.Supported && len(hits) > 0 {
-		out.WriteString("\n\n——\n")
-		out.WriteString(formatTopReference(hits[0]))
-
-		if showRefs {
-			out.WriteString("\n\n附录 · 相关记录（最多 10 条）：\n")
-			max := min(10, len(hits))
-			for i := 0; i < max; i++ {
-				out.WriteString(formatRefLine(i+1, hits[i]))
-				out.WriteString("\n")
-			}
+	// ✅ opt-in: keep supported answers as "qa" memory (see ask_memory.go)
+	if res.Supported && len(hits) > 0 {
+		if err := rememberAskAnswer(cfg, db, question, res.Answer, hits); err != nil {
+			log.Printf("[warn] qa memory: %v", err)
+		}
+		for i := 0; i < len(hits) && i < maxAskReferences; i++ {
+			h := hits[i]
+			res.References = append(res.References, AskReference{
+				Rank:      i + 1,
+				Type:      h.Type,
+				Date:      h.Date,
+				Score:     h.Score,
+				Text:      strings.TrimSpace(h.Text),
+				SummaryID: h.summaryID,
+				Archived:  h.Archived,
+			})
 		}
 	}
-
-	// TTS only reads core answer
-	Speak(ar.Answer)
-	return out.String(), nil
+	return res
 }
 
 /*
@@ -167,6 +256,9 @@ func formatTopReference(h SearchHit) string {
 			strings.TrimSpace(h.Text),
 		)
 	}
+	if h.Type == summaryTypeQA {
+		return "参考：" + strings.TrimSpace(h.Text)
+	}
 
 	return fmt.Sprintf(
 		"参考：你在 %s 的 %s 记录中提到：%s",
@@ -187,12 +279,14 @@ func formatRefLine(idx int, h SearchHit) string {
 		)
 	}
 
+	// #id = summaries.id, usable as /ask --doc <id>
 	return fmt.Sprintf(
-		"%d. [%.2f] %s %s · %s",
+		"%d. [%.2f] %s %s #%d · %s",
 		idx,
 		h.Score,
 		h.Date,
 		h.Type,
+		h.summaryID,
 		firstLine(h.Text),
 	)
 }
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ============================================================
+// Q&A memory (opt-in, TIMELAYER_ASK_MEMORY=1)
+// - A supported /ask answer (the model said supported=true and there were
+//   hits) is kept as a summary of type "qa": question, answer and the keys
+//   of the hits it was based on. It is embedded like any other summary.
+// - period_key "qa:<hash of the normalized question>": asking the same
+//   question again updates that row (latest answer, asked count) instead of
+//   piling up copies.
+// - Its text reads "上次你问过：…\n当时的结论：…", so when it comes back as a
+//   search hit (chat or /ask) the assistant can build on it ("上次你问过这个，
+//   当时的结论是…").
+// - Unsupported answers are never stored. Trash / export / tags work like
+//   for any summary.
+// ============================================================
+
+const (
+	summaryTypeQA = "qa"
+
+	qaMaxRefs = 5
+)
+
+type qaRef struct {
+	Type  string  `json:"type"`
+	Key   string  `json:"key"`
+	Score float64 `json:"score"`
+}
+
+// qaKey is the period_key of a question (case, spaces and punctuation ignored).
+func qaKey(question string) string {
+	norm := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, question)
+	if norm == "" {
+		return ""
+	}
+	return "qa:" + sha256Hex(norm)[:16]
+}
+
+// qaIndexText is the searchable / displayed text of a qa summary.
+func qaIndexText(question, answer string) string {
+	return "上次你问过：" + question + "\n当时的结论：" + answer
+}
+
+// rememberAskAnswer stores (or refreshes) the qa summary of a supported answer.
+func rememberAskAnswer(cfg Config, db *sql.DB, question, answer string, hits []SearchHit) error {
+	if !cfg.AskMemory || db == nil {
+		return nil
+	}
+	question, _ = maskSecretsFor(cfg, strings.TrimSpace(question))
+	answer, _ = maskSecretsFor(cfg, strings.TrimSpace(answer))
+	key := qaKey(question)
+	if key == "" || answer == "" {
+		return nil
+	}
+
+	now := time.Now().In(cfg.Location)
+	today := now.Format("2006-01-02")
+	asked, firstAsked := 1, now.Format(time.RFC3339)
+	var prevJSON string
+	if db.QueryRow(`SELECT json FROM summaries WHERE type=? AND period_key=?`, summaryTypeQA, key).Scan(&prevJSON) == nil {
+		var prev struct {
+			Asked      int    `json:"asked"`
+			FirstAsked string `json:"first_asked"`
+		}
+		if json.Unmarshal([]byte(prevJSON), &prev) == nil && prev.Asked > 0 {
+			asked, firstAsked = prev.Asked+1, prev.FirstAsked
+		}
+	}
+
+	refs := []qaRef{}
+	for _, h := range hits {
+		if len(refs) >= qaMaxRefs || len(refs) >= cfg.SearchTopK {
+			break
+		}
+		if h.Type == summaryTypeQA && h.Date == key {
+			continue // its own earlier answer
+		}
+		refs = append(refs, qaRef{Type: h.Type, Key: h.Date, Score: h.Score})
+	}
+
+	js, err := json.MarshalIndent(map[string]any{
+		"type":        summaryTypeQA,
+		"question":    question,
+		"answer":      answer,
+		"refs":        refs,
+		"asked":       asked,
+		"first_asked": firstAsked,
+		"last_asked":  now.Format(time.RFC3339),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	text := qaIndexText(question, answer)
+	id, err := upsertSummary(db, cfg, summaryTypeQA, key, today, today, string(js), text, "")
+	if err != nil {
+		return err
+	}
+	// the answer may have changed: the old vector is for the old text
+	_ = deleteEmbedding(db, id)
+	if err := ensureEmbedding(db, cfg, text, summaryTypeQA, key); err != nil {
+		log.Printf("[warn] ensureEmbedding failed for %s: %v", key, err)
+	}
+	return nil
+}
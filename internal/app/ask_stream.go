@@ -0,0 +1,166 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ============================================================
+// Streaming ask
+// - POST /api/ask/stream takes the body of POST /api/ask and answers over
+//   SSE: {"delta":"..."} frames while the model writes, then one final
+//   frame {"references":[...],"supported":bool,"answer":"...","unstructured":bool}
+//   and {"done":"1"}. Errors are {"error":"..."} frames, as on /api/chat/stream.
+// - The prompt still asks for {"supported","answer"} JSON; askAnswerStream
+//   cuts the "answer" string out of the partial JSON, so deltas carry the
+//   answer text only. A reply that does not start with "{" is passed through
+//   as-is (the final frame then has unstructured=true).
+// - The final frame is authoritative: the client replaces the streamed text
+//   with its "answer" (they only differ when the JSON turned out malformed).
+// ============================================================
+
+var askAnswerKeyRe = regexp.MustCompile(`"answer"\s*:\s*"`)
+
+const (
+	askStreamDetect = iota
+	askStreamJSON
+	askStreamRaw
+)
+
+// askAnswerStream extracts the "answer" value from streamed JSON output.
+type askAnswerStream struct {
+	buf  strings.Builder
+	mode int
+	pos  int // next unread byte of the answer string (0 = key not seen yet)
+	done bool
+}
+
+// feed takes one model delta and returns the answer text it completes.
+func (s *askAnswerStream) feed(delta string) string {
+	s.buf.WriteString(delta)
+	switch s.mode {
+	case askStreamRaw:
+		return delta
+	case askStreamDetect:
+		head := strings.TrimLeft(s.buf.String(), " \t\r\n")
+		if head == "" {
+			return ""
+		}
+		if head[0] != '{' {
+			s.mode = askStreamRaw
+			return head
+		}
+		s.mode = askStreamJSON
+	}
+	if s.done {
+		return ""
+	}
+
+	raw := s.buf.String()
+	if s.pos == 0 {
+		loc := askAnswerKeyRe.FindStringIndex(raw)
+		if loc == nil {
+			return ""
+		}
+		s.pos = loc[1]
+	}
+
+	var out strings.Builder
+	for s.pos < len(raw) {
+		c := raw[s.pos]
+		if c == '"' {
+			s.done = true
+			break
+		}
+		if c != '\\' {
+			out.WriteByte(c)
+			s.pos++
+			continue
+		}
+		r, n := decodeJSONEscape(raw[s.pos:])
+		if n == 0 {
+			break // escape cut by the chunk boundary: wait for more
+		}
+		out.WriteRune(r)
+		s.pos += n
+	}
+	return out.String()
+}
+
+// decodeJSONEscape decodes the escape sequence at the start of s and returns
+// the rune and its length, or n=0 when s ends inside the sequence.
+func decodeJSONEscape(s string) (rune, int) {
+	if len(s) < 2 {
+		return 0, 0
+	}
+	switch s[1] {
+	case 'n':
+		return '\n', 2
+	case 't':
+		return '\t', 2
+	case 'r':
+		return '\r', 2
+	case 'b':
+		return '\b', 2
+	case 'f':
+		return '\f', 2
+	case 'u':
+	default:
+		return rune(s[1]), 2 // \" \\ \/
+	}
+
+	if len(s) < 6 {
+		return 0, 0
+	}
+	v, err := strconv.ParseUint(s[2:6], 16, 16)
+	if err != nil {
+		return utf8.RuneError, 6
+	}
+	r := rune(v)
+	if !utf16.IsSurrogate(r) {
+		return r, 6
+	}
+	if len(s) < 12 {
+		if strings.HasPrefix(`\u`, s[6:min(8, len(s))]) {
+			return 0, 0 // the low surrogate may still follow
+		}
+		return utf8.RuneError, 6
+	}
+	if s[6:8] != `\u` {
+		return utf8.RuneError, 6
+	}
+	v2, err := strconv.ParseUint(s[8:12], 16, 16)
+	if err != nil {
+		return utf8.RuneError, 6
+	}
+	return utf16.DecodeRune(r, rune(v2)), 12
+}
+
+// AskQuestionStream is AskQuestion with a streamed model call: onDelta gets
+// the answer text as it is written.
+func AskQuestionStream(ctx context.Context, db *sql.DB, cfg Config, question string, target AskTarget, onDelta func(string)) (*AskResult, error) {
+	hits, prompt, err := prepareAsk(db, cfg, question, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// no answer-style token cap: a cut-off JSON reply would lose "supported"
+	scfg := cfg
+	scfg.AnswerStyle.MaxSentences = 0
+
+	var st askAnswerStream
+	raw, err := streamChatWithContextCtx(ctx, scfg, "", nil, prompt, func(delta string) {
+		if t := st.feed(delta); t != "" && onDelta != nil {
+			onDelta(t)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finishAsk(cfg, db, question, strings.TrimSpace(raw), hits), nil
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ============================================================
+// /ask targeting
+// - --type T --period P : answer from exactly that summary (e.g. monthly 2025-09)
+// - --doc <id>          : answer from exactly that summaries row (id)
+// - --type T alone      : semantic search restricted to type T
+// Pinned summaries bypass the embedding search entirely, so other
+// memories cannot interfere.
+// ============================================================
+
+type AskTarget struct {
+	Type   string `json:"type,omitempty"`
+	Period string `json:"period,omitempty"`
+	DocID  int64  `json:"doc,omitempty"`
+}
+
+func (t AskTarget) IsZero() bool { return t.Type == "" && t.Period == "" && t.DocID == 0 }
+
+// pinned reports whether the target names a single summary.
+func (t AskTarget) pinned() bool { return t.DocID > 0 || t.Period != "" }
+
+// parseAskTarget strips --type / --period / --doc (as "--x v" or "--x=v") from input.
+func parseAskTarget(input string) (rest string, t AskTarget, err error) {
+	fields := strings.Fields(input)
+	var keep []string
+	for i := 0; i < len(fields); i++ {
+		name, val, hasVal := strings.Cut(fields[i], "=")
+		switch name {
+		case "--type", "--period", "--doc":
+		default:
+			keep = append(keep, fields[i])
+			continue
+		}
+		if !hasVal {
+			if i+1 >= len(fields) {
+				return "", t, fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			val = fields[i]
+		}
+		switch name {
+		case "--type":
+			t.Type = strings.ToLower(strings.TrimSpace(val))
+		case "--period":
+			t.Period = strings.TrimSpace(val)
+		case "--doc":
+			id, perr := strconv.ParseInt(val, 10, 64)
+			if perr != nil || id <= 0 {
+				return "", t, fmt.Errorf("invalid --doc id: %s", val)
+			}
+			t.DocID = id
+		}
+	}
+	if t.Period != "" && t.Type == "" {
+		return "", t, errors.New("--period needs --type (daily|weekly|monthly)")
+	}
+	return strings.Join(keep, " "), t, nil
+}
+
+// loadAskTargetHit loads the pinned summary as a single full-text hit.
+func loadAskTargetHit(db *sql.DB, t AskTarget) (SearchHit, error) {
+	var (
+		h  SearchHit
+		js string
+		tx string
+	)
+	var row *sql.Row
+	if t.DocID > 0 {
+		row = db.QueryRow(`SELECT id, type, period_key, json, text FROM summaries WHERE id=? AND deleted_at IS NULL`, t.DocID)
+	} else {
+		row = db.QueryRow(`SELECT id, type, period_key, json, text FROM summaries WHERE type=? AND period_key=? AND deleted_at IS NULL`, t.Type, t.Period)
+	}
+	err := row.Scan(&h.summaryID, &h.Type, &h.Date, &js, &tx)
+	if errors.Is(err, sql.ErrNoRows) {
+		if t.DocID > 0 {
+			return h, fmt.Errorf("no summary with id %d", t.DocID)
+		}
+		return h, fmt.Errorf("no %s summary for %s", t.Type, t.Period)
+	}
+	if err != nil {
+		return h, err
+	}
+	if h.Type == "fact" {
+		h.Text = strings.TrimSpace(tx)
+	} else {
+		h.Text = strings.TrimSpace(js)
+	}
+	h.Score, h.EmbScore = 1, 1
+	return h, nil
+}
+
+// askTargetHits returns the retrieval context for a targeted /ask.
+func askTargetHits(db *sql.DB, cfg Config, question string, t AskTarget) ([]SearchHit, error) {
+	if t.pinned() {
+		h, err := loadAskTargetHit(db, t)
+		if err != nil {
+			return nil, err
+		}
+		return []SearchHit{h}, nil
+	}
+
+	hits, err := SearchWithScore(db, cfg, question)
+	if err != nil {
+		return nil, err
+	}
+	out := hits[:0:0]
+	for _, h := range hits {
+		if h.Type == t.Type {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
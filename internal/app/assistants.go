@@ -0,0 +1,252 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Assistant profiles (e.g. "工作助手", "生活助手")
+// - Each profile: persona prompt + memory view (scope tags / workspace,
+//   fact include/exclude tags) + context policy (recent summary days,
+//   recent raw lines, answer style).
+// - Selected per chat request ("assistant") or TIMELAYER_ASSISTANT (CLI).
+// - Turns are logged with "assistant"; with TIMELAYER_SUMMARY_PER_ASSISTANT
+//   each day additionally gets one summary per assistant (see
+//   ensureAssistantDailies), tagged with that assistant's scope.
+// ============================================================
+
+const summaryTypeAssistantDaily = "assistant_daily"
+
+type AssistantProfile struct {
+	Name              string      `json:"name"`
+	Persona           string      `json:"persona,omitempty"`
+	Scope             SearchScope `json:"scope,omitempty"`
+	IncludeTags       []string    `json:"include_tags,omitempty"`
+	ExcludeTags       []string    `json:"exclude_tags,omitempty"`
+	RecentSummaryDays *int        `json:"recent_summary_days,omitempty"`
+	RecentMaxLines    *int        `json:"recent_max_lines,omitempty"`
+	AnswerStyle       AnswerStyle `json:"answer_style,omitempty"`
+	UpdatedAt         string      `json:"updated_at,omitempty"`
+}
+
+func SaveAssistant(cfg Config, db *sql.DB, a AssistantProfile) (AssistantProfile, error) {
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		return a, errors.New("assistant name required")
+	}
+	if s, ok := normalizeAnswerStyle(a.AnswerStyle.Style); ok {
+		a.AnswerStyle.Style = s
+	} else {
+		return a, fmt.Errorf("invalid answer style: %s", a.AnswerStyle.Style)
+	}
+	a.IncludeTags = normalizeFactTags(a.IncludeTags)
+	a.ExcludeTags = normalizeFactTags(a.ExcludeTags)
+	a.UpdatedAt = time.Now().In(cfg.Location).Format(time.RFC3339)
+
+	b, err := json.Marshal(a)
+	if err != nil {
+		return a, err
+	}
+	_, err = db.Exec(`
+		INSERT INTO assistants(name, profile, created_at, updated_at)
+		VALUES(?,?,?,?)
+		ON CONFLICT(name) DO UPDATE SET
+		  profile=excluded.profile,
+		  updated_at=excluded.updated_at
+	`, a.Name, string(b), a.UpdatedAt, a.UpdatedAt)
+	return a, err
+}
+
+func LoadAssistant(db *sql.DB, name string) (AssistantProfile, error) {
+	var a AssistantProfile
+	var js string
+	err := db.QueryRow(`SELECT profile FROM assistants WHERE name=?`, strings.TrimSpace(name)).Scan(&js)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, fmt.Errorf("unknown assistant: %s", name)
+	}
+	if err != nil {
+		return a, err
+	}
+	err = json.Unmarshal([]byte(js), &a)
+	return a, err
+}
+
+func ListAssistants(db *sql.DB) ([]AssistantProfile, error) {
+	rows, err := db.Query(`SELECT profile FROM assistants ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AssistantProfile
+	for rows.Next() {
+		var js string
+		if err := rows.Scan(&js); err != nil {
+			return nil, err
+		}
+		var a AssistantProfile
+		if json.Unmarshal([]byte(js), &a) == nil {
+			out = append(out, a)
+		}
+	}
+	return out, rows.Err()
+}
+
+func DeleteAssistant(db *sql.DB, name string) error {
+	res, err := db.Exec(`DELETE FROM assistants WHERE name=?`, strings.TrimSpace(name))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("unknown assistant: %s", name)
+	}
+	return nil
+}
+
+// applyAssistant returns cfg with the named assistant's memory view and policy applied.
+// Request-level settings are applied afterwards (requestConfig) and win.
+func applyAssistant(cfg Config, db *sql.DB, name string) (Config, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return cfg, nil
+	}
+	a, err := LoadAssistant(db, name)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Assistant = a
+	if !a.Scope.IsZero() {
+		cfg.Scope = a.Scope
+	}
+	if len(a.IncludeTags) > 0 {
+		cfg.ContextFactTags.Include = a.IncludeTags
+	}
+	if len(a.ExcludeTags) > 0 {
+		merged := append(append([]string{}, cfg.ContextFactTags.Exclude...), a.ExcludeTags...)
+		cfg.ContextFactTags.Exclude = normalizeFactTags(merged)
+	}
+	if a.RecentSummaryDays != nil && *a.RecentSummaryDays >= 0 {
+		cfg.RecentSummaryDays = *a.RecentSummaryDays
+	}
+	if a.RecentMaxLines != nil && *a.RecentMaxLines > 0 {
+		cfg.RecentMaxLines = *a.RecentMaxLines
+	}
+	cfg.AnswerStyleOverride = cfg.AnswerStyleOverride.overlay(a.AnswerStyle)
+	return cfg, nil
+}
+
+// withRecordFields tags a log record with the assistant that handled the turn,
+// the user it belongs to (users.go) and its session (sessions.go).
+func withRecordFields(cfg Config, rec map[string]string) map[string]string {
+	if cfg.Assistant.Name != "" {
+		rec["assistant"] = cfg.Assistant.Name
+	}
+	if cfg.User != "" {
+		rec["user"] = cfg.User
+	}
+	if cfg.Session != "" {
+		rec["session"] = cfg.Session
+	}
+	return rec
+}
+
+// assistantPersonaBlock is the system-prompt block of the active assistant ("" = none).
+func assistantPersonaBlock(cfg Config) string {
+	p := strings.TrimSpace(cfg.Assistant.Persona)
+	if p == "" {
+		return ""
+	}
+	return "【助手角色：" + cfg.Assistant.Name + "】\n" + p + "\n\n"
+}
+
+// filterAssistantJSONL keeps dialog records handled by assistant name.
+func filterAssistantJSONL(b []byte, name string) []byte {
+	var out []byte
+	scanJSONL(b, func(line []byte) {
+		var rec struct {
+			Assistant string `json:"assistant"`
+		}
+		if json.Unmarshal(line, &rec) == nil && rec.Assistant == name {
+			out = append(append(out, line...), '\n')
+		}
+	})
+	return out
+}
+
+// assistantsInJSONL lists the assistants that handled at least one record.
+func assistantsInJSONL(b []byte) []string {
+	seen := map[string]bool{}
+	var out []string
+	scanJSONL(b, func(line []byte) {
+		var rec struct {
+			Assistant string `json:"assistant"`
+		}
+		if json.Unmarshal(line, &rec) == nil && rec.Assistant != "" && !seen[rec.Assistant] {
+			seen[rec.Assistant] = true
+			out = append(out, rec.Assistant)
+		}
+	})
+	return out
+}
+
+// ensureAssistantDailies builds one summary per assistant for date
+// (type assistant_daily, key "<date>@<name>"), tagged with the assistant's scope
+// so that the assistant's scoped retrieval finds it. Best effort per assistant.
+func ensureAssistantDailies(cfg Config, db *sql.DB, date string, rawAll []byte, force bool) error {
+	for _, name := range assistantsInJSONL(rawAll) {
+		key := date + "@" + name
+		if !force {
+			if ok, _ := summaryExists(db, summaryTypeAssistantDaily, key); ok {
+				continue
+			}
+		}
+		sub := filterAssistantJSONL(rawAll, name)
+		if len(sub) == 0 {
+			continue
+		}
+		js, err := generateDailyJSON(cfg, db, date, sub)
+		if err != nil {
+			return fmt.Errorf("assistant daily %s: %w", key, err)
+		}
+		indexText := extractIndexText(js)
+		if _, err := upsertSummary(db, cfg, summaryTypeAssistantDaily, key, date, date, js, indexText, ""); err != nil {
+			return err
+		}
+		if a, err := LoadAssistant(db, name); err == nil {
+			if tags := a.Scope.ScopeTags(); len(tags) > 0 {
+				_, _ = SetSummaryTags(cfg, db, summaryTypeAssistantDaily, key, tags, "set")
+			}
+		}
+		if force {
+			dropSummaryEmbedding(db, summaryTypeAssistantDaily, key)
+		}
+		if err := ensureEmbedding(db, cfg, indexText, summaryTypeAssistantDaily, key); err != nil {
+			return fmt.Errorf("assistant daily %s embedding: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// runAssistantsCommand implements /assistants (list).
+func runAssistantsCommand(cfg Config, db *sql.DB) (string, error) {
+	items, err := ListAssistants(db)
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "(no assistants; create one via POST /api/assistants)", nil
+	}
+	var b strings.Builder
+	for _, a := range items {
+		mark := " "
+		if a.Name == cfg.Assistant.Name {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s %s scope=%v\n", mark, a.Name, a.Scope.ScopeTags())
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
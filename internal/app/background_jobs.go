@@ -0,0 +1,172 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Background jobs (daily / weekly / monthly rollups)
+// - Enqueued on day change; run in id order (daily before weekly before monthly).
+// - When the LLM budget is exhausted the current job is marked paused and the
+//   run stops; paused jobs resume on the next run (next day change / startup).
+// - "regen" jobs (LLM rebuild of an extractive fallback summary) that fail
+//   because the LLM is unavailable go back to pending and are retried on the
+//   next run, up to regenMaxAttempts; any other failure marks them failed.
+// ============================================================
+
+// regenMaxAttempts caps how many runs a regen job may be deferred.
+const regenMaxAttempts = 5
+
+type BackgroundJob struct {
+	ID        int64  `json:"id"`
+	Kind      string `json:"kind"` // daily | weekly | monthly | hygiene | regen | on_this_day | fact_sync | context_audit_purge
+	PeriodKey string `json:"period_key"`
+	Status    string `json:"status"` // pending | paused | done | failed
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+var bgJobsMu sync.Mutex // one runner at a time
+
+// enqueueJob adds a job; an existing failed/paused job for the same period is reset to pending.
+func enqueueJob(cfg Config, db *sql.DB, kind, periodKey string) error {
+	ts := time.Now().In(cfg.Location).Format(time.RFC3339)
+	_, err := db.Exec(`
+		INSERT INTO bg_jobs(kind, period_key, status, attempts, created_at, updated_at)
+		VALUES(?,?, 'pending', 0, ?, ?)
+		ON CONFLICT(kind, period_key) DO UPDATE SET
+		  status = CASE WHEN bg_jobs.status='done' THEN 'done' ELSE 'pending' END,
+		  updated_at = excluded.updated_at
+	`, kind, periodKey, ts, ts)
+	return err
+}
+
+func setJobStatus(cfg Config, db *sql.DB, id int64, status, lastErr string) {
+	ts := time.Now().In(cfg.Location).Format(time.RFC3339)
+	_, _ = db.Exec(`
+		UPDATE bg_jobs SET status=?, last_error=?, attempts=attempts+1, updated_at=?
+		WHERE id=?
+	`, status, lastErr, ts, id)
+}
+
+func runJob(cfg Config, db *sql.DB, j BackgroundJob) error {
+	switch j.Kind {
+	case "daily":
+		return ensureDaily(cfg, db, j.PeriodKey, false)
+	case "weekly":
+		return ensureWeekly(cfg, db, j.PeriodKey, false)
+	case "monthly":
+		return ensureMonthly(cfg, db, j.PeriodKey, false)
+	case "hygiene":
+		return ensureHygieneReport(cfg, db, j.PeriodKey, false)
+	case jobKindRegen:
+		return runSummaryRegen(cfg, db, j.PeriodKey)
+	case jobKindOnThisDay:
+		return runOnThisDayNotify(cfg, db, j.PeriodKey)
+	case jobKindFactSync:
+		return runFactSyncJob(cfg, db)
+	case jobKindContextAuditPurge:
+		_, err := purgeContextAudits(cfg, db)
+		return err
+	}
+	return fmt.Errorf("unknown job kind: %s", j.Kind)
+}
+
+// runJobIsolated turns a panic in one job into that job's error (reported),
+// so the remaining jobs still run.
+func runJobIsolated(cfg Config, db *sql.DB, j BackgroundJob) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			stack := debug.Stack()
+			log.Printf("[error] bg job %s %s panicked: %v\n%s", j.Kind, j.PeriodKey, v, stack)
+			reportPanic("bg_job", v, stack, map[string]string{"kind": j.Kind, "period_key": j.PeriodKey})
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return runJob(cfg, db, j)
+}
+
+// runBackgroundJobs processes pending and paused jobs until done or out of budget.
+func runBackgroundJobs(cfg Config, db *sql.DB) {
+	if db == nil {
+		return
+	}
+	bgJobsMu.Lock()
+	defer bgJobsMu.Unlock()
+
+	rows, err := db.Query(`
+		SELECT id, kind, period_key, attempts FROM bg_jobs
+		WHERE status IN ('pending','paused')
+		ORDER BY id
+	`)
+	if err != nil {
+		log.Printf("[warn] bg jobs query failed: %v", err)
+		return
+	}
+	var jobs []BackgroundJob
+	for rows.Next() {
+		var j BackgroundJob
+		if rows.Scan(&j.ID, &j.Kind, &j.PeriodKey, &j.Attempts) == nil {
+			jobs = append(jobs, j)
+		}
+	}
+	rows.Close()
+
+	for i, j := range jobs {
+		if backgroundStopping() {
+			return // the rest stay pending for the next start
+		}
+		err := runJobIsolated(cfg, db, j)
+		switch {
+		case err == nil:
+			setJobStatus(cfg, db, j.ID, "done", "")
+		case errors.Is(err, ErrLLMBudgetExhausted):
+			// Pause this and everything after it (later jobs may depend on it).
+			for _, p := range jobs[i:] {
+				setJobStatus(cfg, db, p.ID, "paused", ErrLLMBudgetExhausted.Error())
+			}
+			log.Printf("[info] background llm budget exhausted; %d job(s) paused until tomorrow", len(jobs)-i)
+			return
+		case j.Kind == jobKindRegen && errors.Is(err, ErrLLMUnavailable) && j.Attempts+1 < regenMaxAttempts:
+			// LLM still unavailable: keep the degraded summary, try again next run
+			setJobStatus(cfg, db, j.ID, "pending", err.Error())
+			log.Printf("[info] regen %s deferred: %v", j.PeriodKey, err)
+		default:
+			setJobStatus(cfg, db, j.ID, "failed", err.Error())
+			log.Printf("[warn] bg job %s %s failed: %v", j.Kind, j.PeriodKey, err)
+			reportError("bg_job", err, map[string]string{"kind": j.Kind, "period_key": j.PeriodKey})
+		}
+	}
+}
+
+// ListBackgroundJobs returns the most recent jobs (newest first).
+func ListBackgroundJobs(db *sql.DB, limit int) ([]BackgroundJob, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := db.Query(`
+		SELECT id, kind, period_key, status, attempts, COALESCE(last_error,''), created_at, updated_at
+		FROM bg_jobs ORDER BY id DESC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BackgroundJob
+	for rows.Next() {
+		var j BackgroundJob
+		if err := rows.Scan(&j.ID, &j.Kind, &j.PeriodKey, &j.Status, &j.Attempts, &j.LastError, &j.CreatedAt, &j.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
@@ -149,6 +149,9 @@ func streamChatWithContextCtx(
 		// thinking 行为在服务端启动阶段已由 chat template 固定。
 		// 保留该参数用于上游逻辑判断及未来 server 行为对齐。
 	}
+	if n := answerMaxTokens(cfg.AnswerStyle); n > 0 {
+		payload["max_tokens"] = n
+	}
 
 	b, err := json.Marshal(payload)
 	if err != nil {
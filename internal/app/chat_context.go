@@ -3,10 +3,12 @@ package app
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
 /*
@@ -15,8 +17,35 @@ PromptBlock 表示一段被注入到 LLM Prompt 中的上下文块。
 */
 type PromptBlock struct {
 	Role    string // system | user | assistant
-	Source  string // daily_summary | search_hit | recent_raw | remembered_fact
+	Source  string // daily_summary | daily_partial_summary | recent_summary | search_hit | recent_raw | remembered_fact | user_annotation | on_this_day | glossary
 	Content string
+
+	Trace *BlockTrace `json:"-"` // 为什么这块被注入（audit 用，不进 prompt）
+}
+
+/*
+BlockTrace 记录一个注入块的裁决依据：优先级、检索分数/排名/rerank 门控、截断。
+由 BuildChatContext 填充，ChatContextAudit.BlocksView 与 context_audits 展示。
+*/
+type BlockTrace struct {
+	Priority   int             `json:"priority"`
+	RawLen     int             `json:"raw_len"`              // sanitizeForContext 之前的字符数
+	Hits       []BlockHitTrace `json:"hits,omitempty"`       // search_hit：每个候选的分数与去留
+	Gate       string          `json:"gate,omitempty"`       // search_hit：rerank | rerank_error | skipped:<reason>
+	Truncation []string        `json:"truncation,omitempty"` // 例如 "tail<=20 lines"、"2 msgs cut at 900 chars"、"dropped: over token budget"
+	Dropped    bool            `json:"dropped,omitempty"`    // 超出 token 预算，未实际发送
+	Tokens     int             `json:"tokens,omitempty"`     // 实际发送的 token 数（prompt_budget.go；0 = 未发送）
+	Facts      []string        `json:"facts,omitempty"`      // remembered_fact：注入的事实（用量统计 fact_usage.go）
+}
+
+type BlockHitTrace struct {
+	Rank     int     `json:"rank"`
+	Score    float64 `json:"score"`
+	EmbScore float64 `json:"emb_score"`
+	Type     string  `json:"type"`
+	Date     string  `json:"date"`
+	Included bool    `json:"included"`
+	Reason   string  `json:"reason,omitempty"` // 未注入原因：today_daily | recent_summary | remembered_duplicate
 }
 
 /*
@@ -28,6 +57,11 @@ type memoryEvidence struct {
 	Source   string
 	Content  string
 	Priority int // 越大越不可被丢弃
+
+	Hits       []BlockHitTrace
+	Gate       string
+	Truncation []string
+	Facts      []string
 }
 
 // 构建 chat 上下文（被 Chat / DebugChat 行为调用）
@@ -47,15 +81,17 @@ func BuildChatContext(
 
 	rememberedSet := map[string]struct{}{}
 
-	if facts, err := loadActiveUserFacts(db, 50); err == nil && len(facts) > 0 {
+	if facts, note, err := selectContextFacts(cfg, db, userQuestion); err == nil && len(facts) > 0 {
 		var b strings.Builder
 		b.WriteString("以下是用户明确要求我长期记住的事实（高优先级、确定，不要质疑）：\n")
 
+		var injectedFacts []string
 		for _, f := range facts {
-			f = strings.TrimSpace(f)
-			if f == "" {
+			if strings.TrimSpace(f) == "" {
 				continue
 			}
+			injectedFacts = append(injectedFacts, f)
+			f = strings.TrimSpace(f)
 			rememberedSet[f] = struct{}{}
 			b.WriteString("- ")
 			b.WriteString(f)
@@ -63,12 +99,17 @@ func BuildChatContext(
 		}
 
 		if b.Len() > 0 {
-			evidences = append(evidences, memoryEvidence{
+			ev := memoryEvidence{
 				Role:     "assistant",
 				Source:   "remembered_fact",
 				Content:  b.String(),
 				Priority: 1000, // 🔒 写死：永不被裁掉
-			})
+				Facts:    injectedFacts,
+			}
+			if note != "" {
+				ev.Truncation = []string{note}
+			}
+			evidences = append(evidences, ev)
 		}
 	}
 
@@ -77,7 +118,12 @@ func BuildChatContext(
 	//     - 过滤已被 /remember 确认的 user_facts_explicit
 	// ------------------------------------------------------------
 
-	if daily := loadDailySummary(cfg, date); daily != "" {
+	// 被注入的 summary（用于附带用户批注）
+	var injected []summaryRef
+
+	todayRef := dailySummaryRef(cfg, date)
+	if daily := loadDailySummary(cfg, db, date); daily != "" {
+		injected = append(injected, todayRef)
 
 		var obj map[string]any
 		if err := json.Unmarshal([]byte(daily), &obj); err == nil {
@@ -136,6 +182,33 @@ func BuildChatContext(
 			Content:  "这是今天的对话摘要（包含自动推断内容，未必完全准确）：\n" + daily,
 			Priority: 600,
 		})
+	} else if partial := loadDailyPartialSummary(db, date); partial != "" && cfg.User == "" {
+		// 尚无正式 daily → 使用 /daily --partial 的“今天到目前为止”摘要（仅同一天）
+		evidences = append(evidences, memoryEvidence{
+			Role:     "assistant",
+			Source:   "daily_partial_summary",
+			Content:  "这是今天到目前为止的对话摘要（当天中途生成，之后的对话未包含，未必完全准确）：\n" + partial,
+			Priority: 600,
+		})
+	}
+
+	// ------------------------------------------------------------
+	// 1️⃣.5 最近几天的 daily summary（昨天起，往前 RecentSummaryDays 天）
+	//     - 今天的 daily 要到明天才生成，避免“早上失忆”
+	// ------------------------------------------------------------
+
+	recentDates := map[string]bool{}
+	if content, dates := loadRecentDailySummaries(cfg, db, date, cfg.RecentSummaryDays); content != "" {
+		recentDates = dates
+		for d := range dates {
+			injected = append(injected, dailySummaryRef(cfg, d))
+		}
+		evidences = append(evidences, memoryEvidence{
+			Role:     "assistant",
+			Source:   "recent_summary",
+			Content:  "以下是最近几天的对话摘要（自动生成，未必完全准确）：\n" + content,
+			Priority: 500,
+		})
 	}
 
 	// ------------------------------------------------------------
@@ -143,25 +216,41 @@ func BuildChatContext(
 	// ------------------------------------------------------------
 
 	hits, err := SearchWithScore(db, cfg, userQuestion)
+	if err == nil {
+		// 标签策略同样作用于 fact 类型的检索命中（避免被排除的事实从 search 侧“绕进来”）
+		hits = filterFactHitsByPolicy(db, hits, factTagPolicyFor(cfg))
+	}
 	if err == nil && len(hits) > 0 {
 		var b strings.Builder
 		b.WriteString("以下内容是通过语义相似度检索得到，可能与当前问题相关，但未必完全准确：\n")
 		included := 0
+		var traces []BlockHitTrace
 
 		max := min(cfg.SearchTopK, len(hits))
 		for i := 0; i < max; i++ {
 			h := hits[i]
-			if h.Type == "daily" && h.Date == date {
-				continue
+			t := BlockHitTrace{Rank: h.Rank, Score: h.Score, EmbScore: h.EmbScore, Type: h.Type, Date: h.Date}
+			if h.Type == todayRef.Type && h.Date == todayRef.Key {
+				t.Reason = "today_daily"
+			} else if h.Type == todayRef.Type && recentDates[strings.TrimSuffix(h.Date, userKeySep+cfg.User)] {
+				t.Reason = "recent_summary"
+			} else if _, exists := rememberedSet[strings.TrimSpace(h.Text)]; exists {
+				// ✅ 去重：如果命中内容与已 /remember 的事实完全一致，就不重复注入
+				t.Reason = "remembered_duplicate"
 			}
-			// ✅ 去重：如果命中内容与已 /remember 的事实完全一致，就不重复注入
-			if _, exists := rememberedSet[strings.TrimSpace(h.Text)]; exists {
+			if t.Reason != "" {
+				traces = append(traces, t)
 				continue
 			}
+			t.Included = true
+			traces = append(traces, t)
 			b.WriteString("- ")
 			b.WriteString(strings.TrimSpace(h.Text))
 			b.WriteString("\n")
 			included++
+			if h.Type != "fact" {
+				injected = append(injected, summaryRef{h.Type, h.Date})
+			}
 		}
 
 		if included > 0 {
@@ -170,10 +259,55 @@ func BuildChatContext(
 				Source:   "search_hit",
 				Content:  b.String(),
 				Priority: 400,
+				Hits:     traces,
+				Gate:     hits[0].Gate,
 			})
 		}
 	}
 
+	// ------------------------------------------------------------
+	// 2️⃣.5 用户对上述摘要的批注（用户亲笔，权威高于摘要本身）
+	// ------------------------------------------------------------
+
+	if notes := buildAnnotationsEvidence(db, injected); notes != "" {
+		evidences = append(evidences, memoryEvidence{
+			Role:     "assistant",
+			Source:   "user_annotation",
+			Content:  "以下是用户本人对相关摘要写的更正/备注（用户亲自撰写，与摘要冲突时以此为准）：\n" + notes,
+			Priority: 900,
+		})
+	}
+
+	// ------------------------------------------------------------
+	// 2️⃣.8 往日今天（opt-in，TIMELAYER_ON_THIS_DAY_CONTEXT）
+	// ------------------------------------------------------------
+
+	if past := buildOnThisDayEvidence(cfg, db, date); past != "" && cfg.User == "" {
+		evidences = append(evidences, memoryEvidence{
+			Role:     "assistant",
+			Source:   "on_this_day",
+			Content:  "以下是往月/往年的同一天发生的事（来自当天摘要；只在自然相关时顺带提起，不要生硬罗列）：\n" + past,
+			Priority: 300,
+		})
+	}
+
+	// ------------------------------------------------------------
+	// 2️⃣.9 术语表（问题里出现的术语才注入；低优先级，见 glossary.go）
+	// ------------------------------------------------------------
+
+	if terms, note := buildGlossaryEvidence(db, userQuestion); terms != "" {
+		ev := memoryEvidence{
+			Role:     "assistant",
+			Source:   "glossary",
+			Content:  "以下是用户定义的术语（理解问题时按此解释，不是关于用户的事实）：\n" + terms,
+			Priority: 150,
+		}
+		if note != "" {
+			ev.Truncation = []string{note}
+		}
+		evidences = append(evidences, ev)
+	}
+
 	// ------------------------------------------------------------
 	// 3️⃣ 最近 raw 对话（短期上下文）
 	// ------------------------------------------------------------
@@ -182,12 +316,17 @@ func BuildChatContext(
 	if maxLines <= 0 {
 		maxLines = 20
 	}
-	if recent := loadRecentRaw(cfg, date, maxLines); recent != "" {
+	if recent := loadRecentRaw(cfg, db, date, maxLines); recent != "" {
+		truncation := []string{fmt.Sprintf("tail<=%d lines", maxLines)}
+		if n := strings.Count(recent, recentRawCutMark); n > 0 {
+			truncation = append(truncation, fmt.Sprintf("%d msgs cut at %d chars", n, recentRawMaxChars))
+		}
 		evidences = append(evidences, memoryEvidence{
-			Role:     "assistant",
-			Source:   "recent_raw",
-			Content:  "以下是最近的原始对话记录：\n" + recent,
-			Priority: 200,
+			Role:       "assistant",
+			Source:     "recent_raw",
+			Content:    "以下是最近的原始对话记录：\n" + recent,
+			Priority:   200,
+			Truncation: truncation,
 		})
 	}
 
@@ -225,6 +364,14 @@ func resolvePromptBlocks(evs []memoryEvidence) []PromptBlock {
 			Source: e.Source,
 			// ✅ 强制加“参考信息”包装，避免被当成“模型自述”
 			Content: content,
+			Trace: &BlockTrace{
+				Priority:   e.Priority,
+				RawLen:     len([]rune(e.Content)),
+				Hits:       e.Hits,
+				Gate:       e.Gate,
+				Truncation: e.Truncation,
+				Facts:      e.Facts,
+			},
 		}
 
 		if e.Source == "remembered_fact" {
@@ -338,7 +485,11 @@ func sanitizeForContext(s string) string {
 // helpers
 // ------------------------------------------------------------
 
-func loadDailySummary(cfg Config, date string) string {
+// loadDailySummary returns the daily summary JSON of cfg.User for date ("" if none).
+func loadDailySummary(cfg Config, db *sql.DB, date string) string {
+	if cfg.User != "" {
+		return loadUserDailySummary(db, cfg.User, date)
+	}
 	path := filepath.Join(cfg.LogDir, date+".daily.json")
 	b, err := os.ReadFile(path)
 	if err != nil {
@@ -347,12 +498,54 @@ func loadDailySummary(cfg Config, date string) string {
 	return strings.TrimSpace(string(b))
 }
 
-func loadRecentRaw(cfg Config, date string, maxLines int) string {
-	path := filepath.Join(cfg.LogDir, date+".jsonl")
-	b, err := os.ReadFile(path)
+// loadRecentDailySummaries returns the index text of the daily summaries of the
+// `days` days before date (newest first), and the dates that were included.
+func loadRecentDailySummaries(cfg Config, db *sql.DB, date string, days int) (string, map[string]bool) {
+	included := map[string]bool{}
+	if days <= 0 {
+		return "", included
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, cfg.Location)
+	if err != nil {
+		return "", included
+	}
+
+	var b strings.Builder
+	for i := 1; i <= days; i++ {
+		d := day.AddDate(0, 0, -i).Format("2006-01-02")
+		js := loadDailySummary(cfg, db, d)
+		if js == "" {
+			continue
+		}
+		text := strings.TrimSpace(extractIndexText(js))
+		if text == "" {
+			continue
+		}
+		b.WriteString("【" + d + "】\n")
+		b.WriteString(text)
+		b.WriteString("\n")
+		included[d] = true
+	}
+	return b.String(), included
+}
+
+// 单条消息最长字符数（避免把很长的 assistant 回复塞爆 prompt）
+// 需要更长可以调大；保持保守能显著降低上下文污染与延迟。
+const (
+	recentRawMaxChars = 900
+	recentRawCutMark  = " …（已截断）"
+)
+
+func loadRecentRaw(cfg Config, db *sql.DB, date string, maxLines int) string {
+	b, err := readRawDay(cfg, db, date)
 	if err != nil {
 		return ""
 	}
+	// Drop malformed lines BEFORE taking the tail, so one broken line never eats the window.
+	b, bad := filterDialogJSONL(b)
+	warnMalformedLines(date, bad)
+	b = filterUserJSONL(b, cfg.User)
+	b = filterSessionJSONL(b, cfg.Session)
 
 	lines := strings.Split(string(b), "\n")
 	if len(lines) > maxLines {
@@ -361,10 +554,6 @@ func loadRecentRaw(cfg Config, date string, maxLines int) string {
 
 	var out []string
 
-	// 单条消息最长字符数（避免把很长的 assistant 回复塞爆 prompt）
-	// 需要更长可以调大；保持保守能显著降低上下文污染与延迟。
-	const maxCharsPerMsg = 900
-
 	format := func(prefix string, content string, hint string) string {
 		c := strings.TrimSpace(content)
 		if c == "" {
@@ -377,9 +566,9 @@ func loadRecentRaw(cfg Config, date string, maxLines int) string {
 		c = strings.TrimSpace(c)
 
 		// 截断超长内容
-		if len([]rune(c)) > maxCharsPerMsg {
+		if len([]rune(c)) > recentRawMaxChars {
 			r := []rune(c)
-			c = string(r[:maxCharsPerMsg]) + " …（已截断）"
+			c = string(r[:recentRawMaxChars]) + recentRawCutMark
 		}
 
 		// 多行内容：首行加 prefix，后续行缩进，避免“我/你”漂移
@@ -410,15 +599,10 @@ func loadRecentRaw(cfg Config, date string, maxLines int) string {
 		var m struct {
 			Role    string `json:"role"`
 			Content string `json:"content"`
-			Kind    string `json:"kind"`
 		}
 		if err := json.Unmarshal([]byte(line), &m); err != nil {
 			continue
 		}
-		// Never inject internal/operational logs into recent_raw.
-		if strings.TrimSpace(m.Kind) == "op" {
-			continue
-		}
 
 		switch m.Role {
 		case "user":
@@ -426,13 +610,6 @@ func loadRecentRaw(cfg Config, date string, maxLines int) string {
 				out = append(out, s)
 			}
 		case "assistant":
-			// Drop accidental internal markers that could pollute future turns.
-			trim := strings.TrimSpace(m.Content)
-			if strings.HasPrefix(trim, "[ok]") || strings.HasPrefix(trim, "[noop]") || strings.HasPrefix(trim, "[conflict]") || strings.HasPrefix(trim, "[error]") {
-				if strings.Contains(trim, "FACTS") || strings.Contains(trim, "待确认事实") || strings.Contains(trim, "PENDING") || strings.Contains(trim, "CONFLICTS") {
-					continue
-				}
-			}
 			// ✅ 关键：把 assistant 的历史回复也注入，但明确降权为“仅供语境”
 			// 这能显著提升连续追问/承接能力，同时降低把旧回复当事实的风险。
 			if s := format("助手：", m.Content, "（仅供语境，不保证正确）"); s != "" {
@@ -2,7 +2,10 @@ package app
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 )
@@ -13,6 +16,14 @@ type ContextBlockView struct {
 	Priority int    `json:"priority"`
 	Len      int    `json:"len"`
 	Preview  string `json:"preview"`
+
+	// why the block made the cut (see BlockTrace)
+	RawLen     int             `json:"raw_len"`
+	Hits       []BlockHitTrace `json:"hits,omitempty"`
+	Gate       string          `json:"gate,omitempty"`
+	Truncation []string        `json:"truncation,omitempty"`
+	Dropped    bool            `json:"dropped,omitempty"`
+	Tokens     int             `json:"tokens,omitempty"` // sent (prompt_budget.go)
 }
 
 type ChatContextAudit struct {
@@ -23,11 +34,13 @@ type ChatContextAudit struct {
 	Blocks       []PromptBlock      `json:"blocks"`
 	BlocksView   []ContextBlockView `json:"blocks_view"`
 	SearchHits   []SearchHit        `json:"search_hits"`
+	SearchDebug  []SearchDebugEvent `json:"search_debug,omitempty"`
 	RememberedN  int                `json:"remembered_n"`
 	PendingN     int                `json:"pending_n"`
 	ConflictsN   int                `json:"conflicts_n"`
 	RecentRawN   int                `json:"recent_raw_n"`
 	DailySummary bool               `json:"daily_summary"`
+	Budget       PromptBudget       `json:"budget"` // token accounting of the final prompt
 }
 
 func BuildChatContextAudit(cfg Config, db *sql.DB, date string, userQuestion string) ChatContextAudit {
@@ -41,17 +54,28 @@ func BuildChatContextAudit(cfg Config, db *sql.DB, date string, userQuestion str
 		Question: userQuestion,
 		Policy: map[string]any{
 			"search_top_k":   cfg.SearchTopK,
+			"type_weights":   cfg.SearchTypeWeights,
 			"max_recent_raw": maxLines,
 			"force_role":     "assistant",
 			// final injection order after resolvePromptBlocks
-			"order": []string{"remembered_fact", "daily_summary", "search_hit", "recent_raw"},
+			"order":     []string{"remembered_fact", "daily_summary", "search_hit", "recent_raw"},
+			"fact_tags": factTagPolicyFor(cfg),
+			"scope":     cfg.Scope.ScopeTags(),
+			"assistant": cfg.Assistant.Name,
+
+			"system_prompt": chatSystemTemplateSource(cfg),
+
+			"max_context_tokens": cfg.MaxContextTokens,
+			"max_prompt_tokens":  promptTokenLimit(cfg),
+			"prompt_tokenizer":   cfg.PromptTokenizer,
+			"audit_persist":      cfg.ContextAuditPersist,
 		},
 		PendingN:   CountPendingFacts(db),
 		ConflictsN: CountFactConflicts(db),
 	}
 
 	// 1) daily summary presence (content itself is shown in Blocks)
-	if daily := loadDailySummary(cfg, date); daily != "" {
+	if daily := loadDailySummary(cfg, db, date); daily != "" {
 		a.DailySummary = true
 		a.Steps = append(a.Steps, fmt.Sprintf("daily_summary: added=1 note=loaded %d chars", len([]rune(daily))))
 	} else {
@@ -59,7 +83,7 @@ func BuildChatContextAudit(cfg Config, db *sql.DB, date string, userQuestion str
 	}
 
 	// 2) remembered facts (active)
-	facts, _ := loadActiveUserFacts(db, 200)
+	facts, _ := loadActiveUserFactsWithPolicy(db, cfg.User, 200, factTagPolicyFor(cfg))
 	a.RememberedN = len(facts)
 	if len(facts) > 0 {
 		a.Steps = append(a.Steps, fmt.Sprintf("remembered_fact: added=1 note=%d active", len(facts)))
@@ -68,7 +92,7 @@ func BuildChatContextAudit(cfg Config, db *sql.DB, date string, userQuestion str
 	}
 
 	// 3) recent raw (count lines)
-	recent := strings.TrimSpace(loadRecentRaw(cfg, date, maxLines))
+	recent := strings.TrimSpace(loadRecentRaw(cfg, db, date, maxLines))
 	if recent != "" {
 		a.RecentRawN = len(strings.Split(recent, "\n"))
 		a.Steps = append(a.Steps, fmt.Sprintf("recent_raw: added=1 note=%d lines", a.RecentRawN))
@@ -81,8 +105,9 @@ func BuildChatContextAudit(cfg Config, db *sql.DB, date string, userQuestion str
 	if cfg.SearchTopK > 0 && userQuestion != "" {
 		sh, err := SearchWithScore(db, cfg, userQuestion)
 		if err == nil {
-			hits = sh
+			hits = filterFactHitsByPolicy(db, sh, factTagPolicyFor(cfg))
 		}
+		a.SearchDebug = SearchDebugEvents(userQuestion)
 	}
 	if len(hits) > 0 {
 		a.SearchHits = hits
@@ -91,40 +116,192 @@ func BuildChatContextAudit(cfg Config, db *sql.DB, date string, userQuestion str
 		a.Steps = append(a.Steps, "search_hits: added=0 note=none")
 	}
 
-	// final prompt blocks (source of truth)
-	a.Blocks = BuildChatContext(cfg, db, date, userQuestion)
-	a.BlocksView = make([]ContextBlockView, 0, len(a.Blocks))
-	prioOf := func(src string) int {
-		switch src {
-		case "remembered_fact":
-			return 1000
-		case "daily_summary":
-			return 600
-		case "search_hit":
-			return 400
-		case "recent_raw":
-			return 200
-		default:
-			return 0
+	// final prompt blocks (source of truth): same path as a real turn, incl. token budget
+	now := time.Now().In(cfg.Location)
+	if d, err := time.ParseInLocation("2006-01-02", date, cfg.Location); err == nil && date != now.Format("2006-01-02") {
+		now = d
+	}
+	_, _, a.Blocks, a.Budget = buildSystemPromptBudget(cfg, db, now, userQuestion)
+	a.BlocksView = contextBlockViews(a.Blocks)
+	a.Steps = append(a.Steps, budgetStep(a.Budget))
+
+	// include a timestamp so frontend can detect staleness
+	a.Policy["generated_at"] = time.Now().In(cfg.Location).Format(time.RFC3339)
+	return a
+}
+
+// budgetStep summarizes the budget decisions as an audit step.
+func budgetStep(b PromptBudget) string {
+	limit := "none"
+	if b.Limit > 0 {
+		limit = fmt.Sprint(b.Limit)
+	}
+	var cut []string
+	for _, d := range b.Blocks {
+		switch d.Action {
+		case "trimmed":
+			cut = append(cut, fmt.Sprintf("%s -%d items (%d→%d)", d.Source, d.Cut, d.Before, d.Tokens))
+		case "dropped":
+			cut = append(cut, fmt.Sprintf("%s dropped (%d)", d.Source, d.Before))
 		}
 	}
+	note := "nothing cut"
+	if len(cut) > 0 {
+		note = strings.Join(cut, ", ")
+	}
+	return fmt.Sprintf("token_budget: %d/%s tokens (%s; before %d) note=%s", b.Total, limit, b.Tokenizer, b.Before, note)
+}
 
-	for _, b := range a.Blocks {
+// contextBlockViews renders blocks with their trace (priority, scores, gate, truncation).
+func contextBlockViews(blocks []PromptBlock) []ContextBlockView {
+	out := make([]ContextBlockView, 0, len(blocks))
+	for _, b := range blocks {
 		prev := strings.ReplaceAll(b.Content, "\n", " ")
 		prev = strings.TrimSpace(prev)
 		if len([]rune(prev)) > 160 {
 			prev = string([]rune(prev)[:160]) + "…"
 		}
-		a.BlocksView = append(a.BlocksView, ContextBlockView{
-			Role:     b.Role,
-			Source:   b.Source,
-			Priority: prioOf(b.Source),
-			Len:      len([]rune(b.Content)),
-			Preview:  prev,
-		})
+		v := ContextBlockView{
+			Role:    b.Role,
+			Source:  b.Source,
+			Len:     len([]rune(b.Content)),
+			Preview: prev,
+		}
+		if t := b.Trace; t != nil {
+			v.Priority = t.Priority
+			v.RawLen = t.RawLen
+			v.Hits = t.Hits
+			v.Gate = t.Gate
+			v.Truncation = t.Truncation
+			v.Dropped = t.Dropped
+			v.Tokens = t.Tokens
+		}
+		out = append(out, v)
 	}
+	return out
+}
 
-	// include a timestamp so frontend can detect staleness
-	a.Policy["generated_at"] = time.Now().In(cfg.Location).Format(time.RFC3339)
-	return a
+// ============================================================
+// context_audits：每轮实际注入块的裁决记录（TIMELAYER_CONTEXT_AUDIT_PERSIST=true）
+// - 与 prompts_log 共用 turn_id，用于事后分析“为什么这块进了 prompt”
+// - GET /api/context/audits?limit=  最近的轮次（不含 blocks）
+//   GET /api/context/audits/:id     单轮完整记录（= /api/chat/turns/:id/audit）
+// - 保留 TIMELAYER_CONTEXT_AUDIT_RETENTION_DAYS 天（默认 30，0 = 不清理），
+//   换日时由 bg job "context_audit_purge" 删除过期记录
+// ============================================================
+
+const (
+	jobKindContextAuditPurge = "context_audit_purge"
+
+	contextAuditListDefault = 50
+	contextAuditListMax     = 500
+)
+
+type TurnContextAudit struct {
+	TurnID    string             `json:"turn_id"`
+	Day       string             `json:"day"`
+	Question  string             `json:"question"`
+	Blocks    []ContextBlockView `json:"blocks"`
+	CreatedAt string             `json:"created_at"`
+}
+
+// TurnContextAuditItem is one row of the audit list.
+type TurnContextAuditItem struct {
+	TurnID    string   `json:"turn_id"`
+	Day       string   `json:"day"`
+	Question  string   `json:"question"`
+	BlocksN   int      `json:"blocks_n"`
+	Sources   []string `json:"sources"` // distinct block sources, injection order
+	CreatedAt string   `json:"created_at"`
+}
+
+func recordTurnContextAudit(cfg Config, db *sql.DB, turnID string, now time.Time, question string, blocks []PromptBlock) error {
+	if !cfg.ContextAuditPersist || db == nil || turnID == "" {
+		return nil
+	}
+	b, err := json.Marshal(contextBlockViews(blocks))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO context_audits(turn_id, day, question, blocks_json, created_at)
+		VALUES(?,?,?,?,?)
+	`, turnID, now.Format("2006-01-02"), question, string(b), now.Format(time.RFC3339))
+	return err
+}
+
+// GetTurnContextAudit loads the stored context audit for a turn.
+func GetTurnContextAudit(db *sql.DB, turnID string) (*TurnContextAudit, error) {
+	var ta TurnContextAudit
+	var blocksJSON string
+	err := db.QueryRow(`
+		SELECT turn_id, day, question, blocks_json, created_at
+		FROM context_audits WHERE turn_id=?
+	`, turnID).Scan(&ta.TurnID, &ta.Day, &ta.Question, &blocksJSON, &ta.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("audit not found (TIMELAYER_CONTEXT_AUDIT_PERSIST off?)")
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(blocksJSON), &ta.Blocks); err != nil {
+		return nil, err
+	}
+	return &ta, nil
+}
+
+// ListTurnContextAudits returns the stored audits, newest first.
+func ListTurnContextAudits(db *sql.DB, limit int) ([]TurnContextAuditItem, error) {
+	out := []TurnContextAuditItem{}
+	if db == nil {
+		return out, nil
+	}
+	if limit <= 0 {
+		limit = contextAuditListDefault
+	}
+	rows, err := readDB(db).Query(`
+		SELECT turn_id, day, COALESCE(question,''), blocks_json, created_at
+		FROM context_audits
+		ORDER BY created_at DESC, turn_id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it TurnContextAuditItem
+		var blocksJSON string
+		if err := rows.Scan(&it.TurnID, &it.Day, &it.Question, &blocksJSON, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		var blocks []ContextBlockView
+		_ = json.Unmarshal([]byte(blocksJSON), &blocks)
+		it.BlocksN = len(blocks)
+		it.Sources = []string{}
+		for _, b := range blocks {
+			if !containsString(it.Sources, b.Source) {
+				it.Sources = append(it.Sources, b.Source)
+			}
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// purgeContextAudits deletes audits older than cfg.ContextAuditRetentionDays.
+func purgeContextAudits(cfg Config, db *sql.DB) (int, error) {
+	if db == nil || cfg.ContextAuditRetentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().In(cfg.Location).AddDate(0, 0, -cfg.ContextAuditRetentionDays).Format("2006-01-02")
+	res, err := db.Exec(`DELETE FROM context_audits WHERE day < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if n > 0 {
+		log.Printf("[info] context audits: purged %d older than %d days", n, cfg.ContextAuditRetentionDays)
+	}
+	return int(n), nil
 }
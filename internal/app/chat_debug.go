@@ -146,6 +146,10 @@ func memoryLevel(source string) string {
 		return "ABSTRACT (daily summary)"
 	case "recent_raw":
 		return "SHORT_TERM (recent dialog)"
+	case "on_this_day":
+		return "ABSTRACT (same day in earlier months / years)"
+	case "glossary":
+		return "GLOSSARY (user-defined terms)"
 	default:
 		return "UNKNOWN"
 	}
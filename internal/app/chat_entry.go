@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 )
@@ -38,10 +39,30 @@ func ChatOnceWithContext(
 	printToStdout bool,
 	onDelta func(string),
 ) (string, error) {
+	ans, _, err := ChatTurnWithContext(ctx, lw, cfg, db, input, printToStdout, onDelta)
+	return ans, err
+}
+
+// ChatTurnWithContext is ChatOnceWithContext that also returns the turn ID
+// (empty if no LLM call was made). The prompt sent for the turn is in prompts_log.
+func ChatTurnWithContext(
+	ctx context.Context,
+	lw *LogWriter,
+	cfg Config,
+	db *sql.DB,
+	input string,
+	printToStdout bool,
+	onDelta func(string),
+) (string, string, error) {
 	input = strings.TrimSpace(input)
 	if input == "" {
-		return "", nil
+		return "", "", nil
+	}
+	if cfg.Incognito {
+		ans, err := chatTurnIncognito(ctx, cfg, db, input, printToStdout, onDelta)
+		return ans, "", err
 	}
+	touchSession(cfg, db, input)
 
 	now := time.Now().In(cfg.Location)
 	origInput := input
@@ -56,11 +77,8 @@ func ChatOnceWithContext(
 	//     by chatting over the underlying fact text (without the prefix).
 	// ------------------------------------------------------------
 	if action, fact, ok := parseAutoFactsIntent(input); ok {
-		_ = lw.WriteRecord(map[string]string{
-			"role":    "user",
-			"content": origInput,
-			"kind":    "op",
-		})
+		maskedOrig, _ := maskSecretsFor(cfg, origInput)
+		_ = lw.WriteOp(opFactsIntent, map[string]string{"action": action, "input": maskedOrig})
 		when := now
 		sourceKey := when.Format("2006-01-02")
 		var resp string
@@ -69,98 +87,204 @@ func ChatOnceWithContext(
 		case "remember":
 			if strings.TrimSpace(fact) == "" {
 				resp = "usage: 记住：<fact>"
-				_ = lw.WriteRecord(map[string]string{"role": "assistant", "content": resp, "kind": "op"})
+				_ = lw.WriteOp(opFactsUsage, map[string]string{"action": action, "message": resp})
 				if printToStdout {
 					fmt.Println(resp)
 				}
-				return resp, nil
+				return resp, "", nil
 			}
 			// Background: propose into FACTS (pending/conflict/noop). No chat acknowledgement.
+			// A fact carrying a secret is blocked there (factBlocked, see secret_mask.go).
 			_, err := ProposePendingRememberFact(cfg, db, fact, "remember_auto", sourceKey, when)
 			if err != nil {
 				resp = "[warn] pending facts ingest failed: " + err.Error()
-				_ = lw.WriteRecord(map[string]string{"role": "assistant", "content": resp, "kind": "op"})
+				_ = lw.WriteOp(opFactsIngestFailed, map[string]string{"source": "remember_auto", "error": err.Error()})
 			}
 			effectiveInput = strings.TrimSpace(fact)
 			skipImplicit = true
 			// Also log the "real" user meaning (so recent_raw continuity is good).
-			_ = lw.WriteRecord(map[string]string{"role": "user", "content": effectiveInput})
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{"role": "user", "content": effectiveInput}))
 
 		case "forget":
 			if strings.TrimSpace(fact) == "" {
 				resp = "usage: 忘记：<fact>"
-				_ = lw.WriteRecord(map[string]string{"role": "assistant", "content": resp, "kind": "op"})
+				_ = lw.WriteOp(opFactsUsage, map[string]string{"action": action, "message": resp})
 				if printToStdout {
 					fmt.Println(resp)
 				}
-				return resp, nil
+				return resp, "", nil
 			}
 			if err := RetractFact(cfg, db, fact, "forget_auto", sourceKey, when); err != nil {
 				// Don't lie to the user. Keep it short and non-technical.
-				_ = lw.WriteRecord(map[string]string{
-					"role":    "assistant",
-					"content": "[warn] forget failed: " + err.Error(),
-					"kind":    "op",
-				})
+				_ = lw.WriteOp(opForgetFailed, map[string]string{"error": err.Error()})
 				resp = "抱歉，我这边没能完成这个操作，请稍后再试一次。"
 			} else {
 				// Provide a tiny normal reply without mentioning internal systems.
 				resp = "好的。"
 			}
 			resp = sanitizeAssistantText(resp)
-			_ = lw.WriteRecord(map[string]string{"role": "assistant", "content": resp})
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{"role": "assistant", "content": resp}))
 			if printToStdout {
 				fmt.Println(resp)
 			}
-			return resp, nil
+			return resp, "", nil
 		}
 	}
 
 	// write user (normal chat)
 	// (If it was an explicit remember intent, we already logged the cleaned meaning above.)
 	if !(skipImplicit && strings.TrimSpace(effectiveInput) != "" && origInput != effectiveInput) {
-		_ = lw.WriteRecord(map[string]string{
+		_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{
 			"role":    "user",
 			"content": effectiveInput,
-		})
+		}))
+	}
+
+	// Everything kept from this turn uses storedInput; only the model call
+	// below sees effectiveInput with a secret in it (see secret_mask.go).
+	storedInput, secretKinds := maskSecretsFor(cfg, effectiveInput)
+	if len(secretKinds) > 0 {
+		skipImplicit = true
 	}
 
 	// ------------------------------------------------------------
 	// ✅ Implicit self-fact -> silently propose into FACTS → PENDING
 	// (no chat acknowledgement; UI only shows LED/count)
 	// ------------------------------------------------------------
+	// the answer to last turn's clarification question (fact_clarify.go)
+	if !skipImplicit {
+		handled, reply, err := resolveFactClarification(cfg, db, effectiveInput, now)
+		if err != nil {
+			_ = lw.WriteOp(opFactsIngestFailed, map[string]string{"source": sourceTypeClarified, "error": err.Error()})
+		}
+		if handled {
+			skipImplicit = true
+		}
+		if reply != "" {
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{"role": "assistant", "content": reply}))
+			if printToStdout {
+				fmt.Println(reply)
+			} else if onDelta != nil {
+				onDelta(reply)
+			}
+			return reply, "", nil
+		}
+	}
 	if !skipImplicit {
-		if _, err := maybeAutoProposePendingFromUserInput(cfg, db, effectiveInput, now); err != nil {
+		// "不对，我的生日是…" answering the assistant: propose the corrected fact (with the exchange)
+		corrected, err := maybeProposeCorrectionFromUserInput(cfg, db, effectiveInput, now)
+		if err != nil {
+			_ = lw.WriteOp(opFactsIngestFailed, map[string]string{"source": "correction", "error": err.Error()})
+		}
+		if corrected {
+			skipImplicit = true
+		}
+	}
+	clarifyQ := "" // at most one clarification question per turn, appended to the answer
+	if !skipImplicit {
+		st, err := maybeAutoProposePendingFromUserInput(cfg, db, effectiveInput, now)
+		if err != nil {
 			// Keep UX quiet; but log the failure for operators.
-			_ = lw.WriteRecord(map[string]string{
-				"role":    "assistant",
-				"content": "[warn] pending facts ingest failed: " + err.Error(),
-				"kind":    "op",
-			})
+			_ = lw.WriteOp(opFactsIngestFailed, map[string]string{"source": "implicit", "error": err.Error()})
+		}
+		if st != nil && st.Status == "clarify" {
+			clarifyQ = st.Question
 		}
 	}
 
+	// 回答风格：request > profile 默认 > env
+	cfg.AnswerStyle = effectiveAnswerStyle(cfg, db)
+
 	// ✅ system + context messages（把记忆/检索从 system 降权出来）
-	system, ctxMsgs := buildSystemPrompt(cfg, db, now, effectiveInput)
+	system, ctxMsgs, blocks := buildSystemPrompt(cfg, db, now, storedInput)
 
 	// ✅ 小包装：降低中文“我/你”歧义
 	modelInput := "【用户原话】\n" + effectiveInput
 
+	// 复现用：记录本轮实际发送的 prompt（prompts_log）
+	turnID := newRequestID()
+	if err := recordTurnPrompt(cfg, db, turnID, now, system, ctxMsgs, "【用户原话】\n"+storedInput); err != nil {
+		log.Printf("[warn] prompts_log insert failed: %v", err)
+	}
+	if err := recordTurnContextAudit(cfg, db, turnID, now, storedInput, blocks); err != nil {
+		log.Printf("[warn] context_audits insert failed: %v", err)
+	}
+	recordFactInjections(db, blocks, now)
+
 	// stream
 	if printToStdout {
 		ans := streamChatWithContextCLI(cfg, system, ctxMsgs, modelInput)
 		ans = sanitizeAssistantText(ans)
-		_ = lw.WriteRecord(map[string]string{"role": "assistant", "content": ans})
-		return ans, nil
+		if clarifyQ != "" {
+			fmt.Println(clarifyQ)
+			ans += "\n\n" + clarifyQ
+		}
+		_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{"role": "assistant", "content": ans, "turn_id": turnID}))
+		return ans, turnID, nil
 	}
 
 	ans, err := streamChatWithContextCtx(ctx, cfg, system, ctxMsgs, modelInput, onDelta)
 	if err != nil {
-		return ans, err
+		if clarifyQ != "" {
+			_ = takeFactClarification(now) // never asked
+		}
+		return ans, turnID, err
 	}
 
 	ans = sanitizeAssistantText(ans)
-	_ = lw.WriteRecord(map[string]string{"role": "assistant", "content": ans})
+	if clarifyQ != "" {
+		if onDelta != nil {
+			onDelta("\n\n" + clarifyQ)
+		}
+		ans += "\n\n" + clarifyQ
+	}
+	_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{"role": "assistant", "content": ans, "turn_id": turnID}))
+
+	return ans, turnID, nil
+}
 
-	return ans, nil
+// chatTurnIncognito answers one turn with the usual context (facts, summaries,
+// recent raw) but writes nothing: no log records (so no summaries later), no
+// facts intents / implicit capture, no prompts_log or context audit.
+// "记住：" is sent to the model as plain text.
+func chatTurnIncognito(
+	ctx context.Context,
+	cfg Config,
+	db *sql.DB,
+	input string,
+	printToStdout bool,
+	onDelta func(string),
+) (string, error) {
+	now := time.Now().In(cfg.Location)
+	cfg.AnswerStyle = effectiveAnswerStyle(cfg, db)
+	system, ctxMsgs, _ := buildSystemPrompt(cfg, db, now, input)
+	modelInput := "【用户原话】\n" + input
+
+	if printToStdout {
+		return sanitizeAssistantText(streamChatWithContextCLI(cfg, system, ctxMsgs, modelInput)), nil
+	}
+	ans, err := streamChatWithContextCtx(ctx, cfg, system, ctxMsgs, modelInput, onDelta)
+	if err != nil {
+		return ans, err
+	}
+	return sanitizeAssistantText(ans), nil
+}
+
+// parseIncognitoArg reads "/incognito [on|off]"; no argument toggles cur.
+func parseIncognitoArg(arg string, cur bool) bool {
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "on", "1", "true":
+		return true
+	case "off", "0", "false":
+		return false
+	default:
+		return !cur
+	}
+}
+
+func incognitoStatus(on bool) string {
+	if on {
+		return "[ok] incognito ON: turns are answered with memory but not logged, summarized or captured as facts"
+	}
+	return "[ok] incognito OFF: turns are recorded again"
 }
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Chat export (/export_chat, GET /api/export/chat)
+// - Renders the dialog of a day range as Markdown or HTML, one heading per day.
+// - Reads the same raw source as summaries (readRawDay + filterDialogJSONL),
+//   so op records never show up; days without dialog are skipped.
+// - Days already archived out of LogDir are not included (/unarchive them first).
+// ============================================================
+
+const (
+	exportFormatMarkdown = "md"
+	exportFormatHTML     = "html"
+
+	maxExportDays = 366
+)
+
+type exportTurn struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	Assistant string `json:"assistant,omitempty"`
+}
+
+type exportDay struct {
+	Date  string
+	Turns []exportTurn
+}
+
+// normalizeExportFormat maps "" / "markdown" to md; ok=false for unknown formats.
+func normalizeExportFormat(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "md", "markdown":
+		return exportFormatMarkdown, true
+	case "html", "htm":
+		return exportFormatHTML, true
+	default:
+		return "", false
+	}
+}
+
+// exportContentType returns the HTTP content type for an export format.
+func exportContentType(format string) string {
+	if format == exportFormatHTML {
+		return "text/html; charset=utf-8"
+	}
+	return "text/markdown; charset=utf-8"
+}
+
+// loadExportDays collects user/assistant turns for every day in [from, to].
+func loadExportDays(cfg Config, db *sql.DB, from, to string) ([]exportDay, error) {
+	start, err := time.ParseInLocation("2006-01-02", from, cfg.Location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from date: %s", from)
+	}
+	end, err := time.ParseInLocation("2006-01-02", to, cfg.Location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to date: %s", to)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("to (%s) is before from (%s)", to, from)
+	}
+	if int(end.Sub(start).Hours()/24) >= maxExportDays {
+		return nil, fmt.Errorf("range too large (max %d days)", maxExportDays)
+	}
+
+	var days []exportDay
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		raw, err := readRawDay(cfg, db, date)
+		if err != nil || len(raw) == 0 {
+			continue
+		}
+		dialog, bad := filterDialogJSONL(raw)
+		warnMalformedLines(date, bad)
+
+		day := exportDay{Date: date}
+		scanJSONL(dialog, func(line []byte) {
+			var t exportTurn
+			if json.Unmarshal(line, &t) != nil {
+				return
+			}
+			t.Content = strings.TrimSpace(t.Content)
+			if (t.Role != "user" && t.Role != "assistant") || t.Content == "" {
+				return
+			}
+			day.Turns = append(day.Turns, t)
+		})
+		if len(day.Turns) > 0 {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// speakerLabel returns the display name of a turn's speaker.
+func speakerLabel(t exportTurn) string {
+	if t.Role == "user" {
+		return "User"
+	}
+	if t.Assistant != "" {
+		return "Assistant (" + t.Assistant + ")"
+	}
+	return "Assistant"
+}
+
+func renderChatMarkdown(from, to string, days []exportDay) string {
+	var b strings.Builder
+	b.WriteString("# TimeLayer chat export\n\n")
+	fmt.Fprintf(&b, "_%s – %s · %d day(s)_\n", from, to, len(days))
+	for _, d := range days {
+		fmt.Fprintf(&b, "\n## %s\n", d.Date)
+		for _, t := range d.Turns {
+			fmt.Fprintf(&b, "\n**%s:**\n\n%s\n", speakerLabel(t), t.Content)
+		}
+	}
+	return b.String()
+}
+
+func renderChatHTML(from, to string, days []exportDay) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>TimeLayer chat export %s – %s</title>\n", html.EscapeString(from), html.EscapeString(to))
+	b.WriteString(`<style>
+body{font-family:-apple-system,"Segoe UI",sans-serif;max-width:760px;margin:2em auto;padding:0 1em;color:#222;line-height:1.55}
+h2{border-bottom:1px solid #ddd;padding-bottom:.2em;margin-top:2em}
+.turn{margin:.8em 0;padding:.6em .9em;border-radius:8px;white-space:pre-wrap}
+.user{background:#eef4ff}
+.assistant{background:#f5f5f5}
+.who{font-weight:600;font-size:.85em;color:#555;margin-bottom:.2em}
+</style>
+</head>
+<body>
+`)
+	b.WriteString("<h1>TimeLayer chat export</h1>\n")
+	fmt.Fprintf(&b, "<p><em>%s – %s · %d day(s)</em></p>\n", html.EscapeString(from), html.EscapeString(to), len(days))
+	for _, d := range days {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(d.Date))
+		for _, t := range d.Turns {
+			fmt.Fprintf(&b, "<div class=\"turn %s\"><div class=\"who\">%s</div>%s</div>\n",
+				t.Role, html.EscapeString(speakerLabel(t)), html.EscapeString(t.Content))
+		}
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// ExportChat renders the dialog between from and to (inclusive, YYYY-MM-DD)
+// as md or html. to == "" exports a single day.
+func ExportChat(cfg Config, db *sql.DB, from, to, format string) (string, error) {
+	from = strings.TrimSpace(from)
+	to = strings.TrimSpace(to)
+	if from == "" {
+		return "", fmt.Errorf("from date is required")
+	}
+	if to == "" {
+		to = from
+	}
+	f, ok := normalizeExportFormat(format)
+	if !ok {
+		return "", fmt.Errorf("unknown format: %s (use md or html)", format)
+	}
+	days, err := loadExportDays(cfg, db, from, to)
+	if err != nil {
+		return "", err
+	}
+	if len(days) == 0 {
+		return "", fmt.Errorf("no chat messages between %s and %s", from, to)
+	}
+	if f == exportFormatHTML {
+		return renderChatHTML(from, to, days), nil
+	}
+	return renderChatMarkdown(from, to, days), nil
+}
+
+// runExportChatCommand implements /export_chat <from> [to] [--format md|html]
+// and writes the transcript to <BaseDir>/exports/.
+func runExportChatCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	var dates []string
+	format := exportFormatMarkdown
+	fields := strings.Fields(arg)
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "--format" && i+1 < len(fields):
+			format = fields[i+1]
+			i++
+		case strings.HasPrefix(fields[i], "--format="):
+			format = strings.TrimPrefix(fields[i], "--format=")
+		default:
+			dates = append(dates, fields[i])
+		}
+	}
+	if len(dates) == 0 || len(dates) > 2 {
+		return "usage: /export_chat <from YYYY-MM-DD> [to YYYY-MM-DD] [--format md|html]", nil
+	}
+	from, to := dates[0], dates[0]
+	if len(dates) == 2 {
+		to = dates[1]
+	}
+	f, ok := normalizeExportFormat(format)
+	if !ok {
+		return "", fmt.Errorf("unknown format: %s (use md or html)", format)
+	}
+
+	out, err := ExportChat(cfg, db, from, to, f)
+	if err != nil {
+		return "", err
+	}
+	name := "chat_" + from
+	if to != from {
+		name += "_" + to
+	}
+	dir := filepath.Join(cfg.BaseDir, "exports")
+	_ = os.MkdirAll(dir, 0755)
+	path := filepath.Join(dir, name+"."+f)
+	if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+		return "", err
+	}
+	return "[ok] exported: " + path, nil
+}
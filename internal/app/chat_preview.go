@@ -0,0 +1,190 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Chat preview ("what would be shared with the model")
+// - POST /api/chat/preview {"input":"...", ...same options as /api/chat}
+//   Chat: /preview <message>
+// - Runs the context assembly of a real turn (assistant, scope, style,
+//   incognito, token budget) and returns the exact system prompt, the
+//   context blocks and the user message, with estimated token counts.
+// - Nothing is called and nothing is written: no model call, no log line,
+//   no fact capture, no prompts_log / context audit. Embedding and rerank
+//   are still called for retrieval, as in a real turn.
+// - remote=true when TIMELAYER_CHAT_URL is not a loopback host, i.e. the
+//   blocks below would leave this machine.
+// ============================================================
+
+type ChatPreviewBlock struct {
+	ContextBlockView
+	Content string `json:"content"`
+}
+
+type ChatPreviewTokens struct {
+	System  int `json:"system"`
+	Context int `json:"context"`
+	Input   int `json:"input"`
+	Total   int `json:"total"`
+	Limit   int `json:"limit"` // prompt budget (0 = unknown; see prompt_budget.go)
+
+	Tokenizer string `json:"tokenizer,omitempty"`
+}
+
+type ChatPreview struct {
+	Input string `json:"input"`
+	// Send=false: the input never reaches the model (command, forget intent)
+	Send      bool   `json:"send"`
+	Note      string `json:"note,omitempty"`
+	Incognito bool   `json:"incognito"`
+	Assistant string `json:"assistant,omitempty"`
+
+	ChatURL   string `json:"chat_url"`
+	ChatModel string `json:"chat_model"`
+	Remote    bool   `json:"remote"`
+
+	System      string             `json:"system"`
+	Blocks      []ChatPreviewBlock `json:"blocks"`
+	UserMessage string             `json:"user_message"`
+	Tokens      ChatPreviewTokens  `json:"tokens"`
+	// secret kinds in the input: kept out of memory, but sent to the model as typed
+	Secrets []string `json:"secrets,omitempty"`
+}
+
+// BuildChatPreview mirrors ChatTurnWithContext up to the model call.
+func BuildChatPreview(cfg Config, db *sql.DB, input string) ChatPreview {
+	input = strings.TrimSpace(input)
+	p := ChatPreview{
+		Input:     input,
+		Incognito: cfg.Incognito,
+		Assistant: cfg.Assistant.Name,
+		ChatURL:   cfg.ChatURL,
+		ChatModel: cfg.ChatModel,
+		Remote:    !isLoopbackURL(cfg.ChatURL),
+		Blocks:    []ChatPreviewBlock{},
+	}
+	p.Tokens.Limit = promptTokenLimit(cfg)
+	if input == "" {
+		p.Note = "empty input"
+		return p
+	}
+	if strings.HasPrefix(input, "/") {
+		p.Note = "command: handled locally, nothing is sent to the model"
+		return p
+	}
+
+	effective := input
+	if !cfg.Incognito {
+		if action, fact, ok := parseAutoFactsIntent(input); ok {
+			switch {
+			case fact == "":
+				p.Note = "facts intent without a fact: answered with a usage hint, nothing is sent"
+				return p
+			case action == "forget":
+				p.Note = "forget intent: the fact is retracted locally, nothing is sent"
+				return p
+			}
+			// remember: the fact goes to FACTS → PENDING and the model chats over the fact itself
+			effective = fact
+			p.Note = "remember intent: the fact is proposed to FACTS → PENDING; the model sees the fact text"
+		}
+	}
+
+	// context is built from the masked input, the model gets it as typed (see secret_mask.go)
+	stored := effective
+	if !cfg.Incognito {
+		stored, p.Secrets = maskSecretsFor(cfg, effective)
+	}
+
+	now := time.Now().In(cfg.Location)
+	cfg.AnswerStyle = effectiveAnswerStyle(cfg, db)
+	system, _, blocks, budget := buildSystemPromptBudget(cfg, db, now, stored)
+
+	p.Send = true
+	p.System = system
+	p.UserMessage = "【用户原话】\n" + effective
+	for i, v := range contextBlockViews(blocks) {
+		if strings.TrimSpace(blocks[i].Content) == "" {
+			continue
+		}
+		p.Blocks = append(p.Blocks, ChatPreviewBlock{ContextBlockView: v, Content: blocks[i].Content})
+	}
+	p.Tokens.System = budget.System
+	p.Tokens.Input = budget.Input
+	p.Tokens.Context = budget.Context
+	p.Tokens.Total = budget.Total
+	p.Tokens.Limit = budget.Limit
+	p.Tokens.Tokenizer = budget.Tokenizer
+	return p
+}
+
+// isLoopbackURL reports whether an endpoint URL points at this machine.
+func isLoopbackURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// renderChatPreview is the text form for /preview.
+func renderChatPreview(p ChatPreview) string {
+	var b strings.Builder
+	where := "local"
+	if p.Remote {
+		where = "REMOTE"
+	}
+	fmt.Fprintf(&b, "preview → %s (%s, %s)\n", p.ChatURL, p.ChatModel, where)
+	if p.Note != "" {
+		b.WriteString(p.Note + "\n")
+	}
+	if !p.Send {
+		return strings.TrimRight(b.String(), "\n")
+	}
+	if p.Incognito {
+		b.WriteString("incognito: nothing of this turn is written\n")
+	}
+	if len(p.Secrets) > 0 {
+		b.WriteString("secrets in the input (sent as typed): " + strings.Join(p.Secrets, ", ") + "\n")
+	}
+	fmt.Fprintf(&b, "tokens ~%d (system %d + context %d + input %d)", p.Tokens.Total, p.Tokens.System, p.Tokens.Context, p.Tokens.Input)
+	if p.Tokens.Limit > 0 {
+		fmt.Fprintf(&b, " of %d", p.Tokens.Limit)
+	}
+	if p.Tokens.Tokenizer != "" {
+		fmt.Fprintf(&b, " [%s]", p.Tokens.Tokenizer)
+	}
+	b.WriteString("\n")
+	for i, bl := range p.Blocks {
+		if bl.Dropped {
+			fmt.Fprintf(&b, "#%d [%s] dropped: over token budget\n", i+1, bl.Source)
+			continue
+		}
+		fmt.Fprintf(&b, "#%d [%s] ~%d tokens\n%s\n", i+1, bl.Source, bl.Tokens, bl.Content)
+	}
+	if len(p.Blocks) == 0 {
+		b.WriteString("(no memory blocks)\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// runChatPreviewCommand implements /preview <message> for both CLI and web.
+func runChatPreviewCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return "usage: /preview <message>", nil
+	}
+	return renderChatPreview(BuildChatPreview(cfg, db, arg)), nil
+}
@@ -0,0 +1,234 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// WebSocket chat (alongside SSE)
+// - GET /api/chat/ws upgrades to a WebSocket (websocket.go). Unlike SSE it
+//   is not buffered by proxies, and one connection carries any number of
+//   turns, one at a time.
+// - Client → server (JSON text messages):
+//     {"type":"chat","input":"...", ...same options as /api/chat}
+//     {"type":"cancel"}   stop the running turn (the partial answer is not logged)
+//     {"type":"ping"}     → {"pong":"1"}
+// - Server → client: the SSE frames of /api/chat/stream ({"delta"},
+//   {"notice":"facts"}, {"turn_id"}, {"error"}), plus {"typing":"1"} when a
+//   turn starts and {"typing":"0"} at its first delta, {"cancelled":"1"} after
+//   a cancel. Every turn, failed or not, ends with {"done":"1"}.
+// - Turns count against TIMELAYER_HTTP_MAX_CONCURRENT_STREAMS like SSE
+//   streams. Closing the socket cancels the running turn (no stream resume).
+// - Auth as for other API routes (X-Auth-Token / Authorization header,
+//   loopback bypass); cross-origin browser upgrades are refused.
+// ============================================================
+
+const (
+	chatWSPingEvery   = 30 * time.Second
+	chatWSIdleTimeout = 90 * time.Second // three missed pings
+)
+
+type chatWSMsg struct {
+	Type string `json:"type"`
+	apiChatReq
+}
+
+// serveChatWS runs one /api/chat/ws connection until the client goes away.
+// On shutdown (web_shutdown.go) new turns are refused and the connection is
+// closed once idle; the drain timeout cancels a running turn.
+func serveChatWS(cfg Config, db *sql.DB, lw *LogWriter, streamSem chan struct{}, life *webLifecycle, w http.ResponseWriter, r *http.Request) {
+	conn, err := wsAccept(w, r, maxJSONBodyBytes)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.idle = chatWSIdleTimeout
+	life.enter()
+	defer life.leave()
+
+	ctx, cancelConn := context.WithCancel(r.Context())
+	var (
+		mu         sync.Mutex
+		cancelTurn context.CancelFunc // nil = idle
+		draining   bool
+		turns      sync.WaitGroup
+	)
+	goingAway := func() { conn.CloseWith(wsCloseGoingAway, errWebShuttingDown.Error()) }
+	defer context.AfterFunc(life.draining, func() {
+		mu.Lock()
+		draining = true
+		idle := cancelTurn == nil
+		mu.Unlock()
+		if idle {
+			goingAway()
+		}
+	})()
+	defer context.AfterFunc(life.stopped, func() {
+		cancelConn()
+		_ = conn.Close()
+	})()
+
+	go func() {
+		t := time.NewTicker(chatWSPingEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if conn.Ping() != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		op, b, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if op != wsOpText {
+			_ = conn.WriteJSON(map[string]string{"error": "text messages only"})
+			continue
+		}
+		var msg chatWSMsg
+		if err := json.Unmarshal(b, &msg); err != nil {
+			_ = conn.WriteJSON(map[string]string{"error": "invalid json"})
+			continue
+		}
+		switch msg.Type {
+		case "ping":
+			_ = conn.WriteJSON(map[string]string{"pong": "1"})
+		case "cancel":
+			mu.Lock()
+			if cancelTurn != nil {
+				cancelTurn()
+			}
+			mu.Unlock()
+		case "chat", "":
+			mu.Lock()
+			if draining {
+				mu.Unlock()
+				_ = conn.WriteJSON(map[string]string{"error": errWebShuttingDown.Error()})
+				continue
+			}
+			if cancelTurn != nil {
+				mu.Unlock()
+				_ = conn.WriteJSON(map[string]string{"error": "a turn is already running"})
+				continue
+			}
+			turnCtx, cancel := context.WithCancel(ctx)
+			cancelTurn = cancel
+			mu.Unlock()
+
+			turns.Add(1)
+			go func(req apiChatReq) {
+				defer turns.Done()
+				runChatWSTurn(turnCtx, cfg, db, lw, streamSem, conn, req)
+				mu.Lock()
+				cancelTurn = nil
+				closing := draining
+				mu.Unlock()
+				cancel()
+				// after the reset, so the client may send the next turn on "done"
+				_ = conn.WriteJSON(map[string]string{"done": "1"})
+				if closing {
+					goingAway()
+				}
+			}(msg.apiChatReq)
+		default:
+			_ = conn.WriteJSON(map[string]string{"error": "unknown type: " + msg.Type})
+		}
+	}
+
+	cancelConn()
+	turns.Wait()
+	conn.CloseWith(wsCloseNormal, "")
+}
+
+// runChatWSTurn answers one chat message (the caller sends {"done":"1"}).
+func runChatWSTurn(ctx context.Context, cfg Config, db *sql.DB, lw *LogWriter, streamSem chan struct{}, conn *wsConn, req apiChatReq) {
+	fail := func(msg string) { _ = conn.WriteJSON(map[string]string{"error": msg}) }
+
+	req.Input = strings.TrimSpace(req.Input)
+	if req.Input == "" {
+		fail("empty input")
+		return
+	}
+	if cfg.HTTPMaxInputBytes > 0 && len(req.Input) > cfg.HTTPMaxInputBytes {
+		fail("input too large")
+		return
+	}
+
+	// commands: one-shot, same frames as SSE
+	if strings.HasPrefix(req.Input, "/") {
+		cmd, _ := normalizeCommand(req.Input)
+		cmdCfg, err := req.userConfig(cfg)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		handled, out, err := HandleCommandWeb(cmdCfg, db, lw, req.Input)
+		if handled {
+			if err != nil {
+				fail(err.Error())
+				return
+			}
+			for _, f := range chatCommandFrames(cmd, out) {
+				if f["done"] == "" {
+					_ = conn.WriteJSON(f)
+				}
+			}
+			return
+		}
+	}
+
+	select {
+	case streamSem <- struct{}{}:
+		defer func() { <-streamSem }()
+	default:
+		fail("too many concurrent streams")
+		return
+	}
+
+	chatCfg, err := req.chatConfig(cfg, db)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+
+	_ = conn.WriteJSON(map[string]string{"typing": "1"})
+	typing := true
+	_, turnID, err := ChatTurnWithContext(ctx, lw, chatCfg, db, req.Input, false, func(delta string) {
+		if ctx.Err() != nil {
+			return
+		}
+		if typing {
+			typing = false
+			_ = conn.WriteJSON(map[string]string{"typing": "0"})
+		}
+		_ = conn.WriteJSON(map[string]string{"delta": delta})
+	})
+	if typing {
+		_ = conn.WriteJSON(map[string]string{"typing": "0"})
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			_ = conn.WriteJSON(map[string]string{"cancelled": "1"})
+			return
+		}
+		fail(err.Error())
+		return
+	}
+	if turnID != "" {
+		_ = conn.WriteJSON(map[string]string{"turn_id": turnID})
+	}
+}
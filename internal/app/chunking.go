@@ -0,0 +1,180 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================================
+// Token-based chunking for the daily / weekly / monthly pipelines
+// - Bytes map poorly to tokens: a CJK rune is 3 bytes but ~1 token, so a
+//   byte limit lets Chinese transcripts grow far past the model's window.
+// - TIMELAYER_CHUNK_MAX_TOKENS caps each chunk by estimated tokens
+//   (default: half the model context when known; else the byte limit only).
+// - TIMELAYER_CHUNK_CHARS_PER_TOKEN tunes the estimate per script:
+//   "ascii=4,cjk=1,other=2" (chars per token).
+// - Lines / array items are never split; one oversized item is its own chunk.
+// ============================================================
+
+type TokenHeuristics struct {
+	ASCII float64 `json:"ascii"` // chars per token for ASCII
+	CJK   float64 `json:"cjk"`   // Han / Kana / Hangul
+	Other float64 `json:"other"` // any other non-ASCII rune
+}
+
+func defaultTokenHeuristics() TokenHeuristics {
+	return TokenHeuristics{ASCII: 4, CJK: 1, Other: 2}
+}
+
+// parseTokenHeuristics reads "ascii=4,cjk=1.5,other=2"; missing or invalid keys keep defaults.
+func parseTokenHeuristics(s string) TokenHeuristics {
+	h := defaultTokenHeuristics()
+	for _, part := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || f <= 0 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "ascii":
+			h.ASCII = f
+		case "cjk":
+			h.CJK = f
+		case "other":
+			h.Other = f
+		}
+	}
+	return h
+}
+
+// Estimate returns the estimated token count of s.
+func (h TokenHeuristics) Estimate(s string) int {
+	ascii, cjk, other := 0, 0, 0
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case isIdeographic(r):
+			cjk++
+		default:
+			other++
+		}
+	}
+	return int(math.Ceil(float64(ascii)/h.ASCII + float64(cjk)/h.CJK + float64(other)/h.Other))
+}
+
+// chunkJSONL splits a day's dialog JSONL for the daily prompt.
+func chunkJSONL(cfg Config, raw []byte) [][]byte {
+	if cfg.ChunkMaxTokens <= 0 {
+		return splitJSONLIntoChunks(raw, cfg.MaxDailyJSONLBytes)
+	}
+	chunks := splitJSONLByTokens(raw, cfg.ChunkMaxTokens, cfg.ChunkCharsPerToken)
+	logChunking("jsonl", len(chunks), cfg.ChunkMaxTokens)
+	return chunks
+}
+
+// chunkJSONArray splits the slimmed child summaries for the weekly / monthly prompt.
+func chunkJSONArray(cfg Config, arr []byte) [][]byte {
+	if cfg.ChunkMaxTokens <= 0 {
+		return splitJSONBytes(arr, cfg.MaxDailyJSONLBytes)
+	}
+	chunks := splitJSONArrayByTokens(arr, cfg.ChunkMaxTokens, cfg.ChunkCharsPerToken)
+	logChunking("json array", len(chunks), cfg.ChunkMaxTokens)
+	return chunks
+}
+
+func logChunking(what string, n, maxTokens int) {
+	if n > 1 {
+		log.Printf("[info] %s split into %d chunks (<= ~%d tokens each)", what, n, maxTokens)
+	}
+}
+
+// splitJSONLByTokens: like splitJSONLIntoChunks, but each chunk stays under maxTokens (estimated).
+func splitJSONLByTokens(raw []byte, maxTokens int, h TokenHeuristics) [][]byte {
+	var chunks [][]byte
+	var b strings.Builder
+	cur := 0
+
+	flush := func() {
+		if b.Len() > 0 {
+			chunks = append(chunks, []byte(b.String()))
+			b.Reset()
+			cur = 0
+		}
+	}
+
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := h.Estimate(line) + 1
+		if b.Len() > 0 && cur+n > maxTokens {
+			flush()
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		cur += n
+		if cur > maxTokens {
+			flush()
+		}
+	}
+	flush()
+
+	if len(chunks) == 0 {
+		return [][]byte{{}}
+	}
+	return chunks
+}
+
+// splitJSONArrayByTokens: like splitJSONBytes (object-level split of a JSON
+// array), but budgeted in estimated tokens.
+func splitJSONArrayByTokens(arr []byte, maxTokens int, h TokenHeuristics) [][]byte {
+	if h.Estimate(string(arr)) <= maxTokens {
+		return [][]byte{arr}
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(arr, &items); err != nil || len(items) == 0 {
+		// not an array: fall back to a byte split sized for the token budget
+		return hardSplitBytes(arr, int64(float64(maxTokens)*h.ASCII))
+	}
+
+	var chunks [][]byte
+	var cur []json.RawMessage
+	curTokens := 1 // "[]"
+
+	flush := func() {
+		if len(cur) == 0 {
+			return
+		}
+		b, _ := json.Marshal(cur)
+		chunks = append(chunks, b)
+		cur = nil
+		curTokens = 1
+	}
+
+	for _, it := range items {
+		n := h.Estimate(string(it)) + 1
+		if len(cur) > 0 && curTokens+n > maxTokens {
+			flush()
+		}
+		cur = append(cur, it)
+		curTokens += n
+		if curTokens > maxTokens {
+			flush() // a single oversized item
+		}
+	}
+	flush()
+
+	if len(chunks) == 0 {
+		return [][]byte{arr}
+	}
+	return chunks
+}
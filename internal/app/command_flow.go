@@ -0,0 +1,389 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Multi-turn command flows (web)
+// - HandleCommandWeb is single-shot: one input, one reply. A flow is a
+//   small state machine on top of the command layer: start returns a flow
+//   ID plus the first prompt, every answer returns the next prompt, until
+//   Done. The UI needs no per-flow code: show Prompt, offer Options as
+//   buttons, send whatever the user types back.
+// - Flows (commandFlows):
+//     replace_conflict  pick an open conflict → keep / replace / edit → done
+//     weekly_review     show a weekly summary → note / regenerate / done
+// - Every step accepts "cancel". In-process state: a restart (or
+//   commandFlowTTL idle) forgets the flow.
+// - API: GET /api/flows, POST /api/flows/start {"flow","arg"},
+//   POST /api/flows/:id {"input"}, DELETE /api/flows/:id.
+//   Chat: /flow lists them; the web UI's /flow <name> runs one inline.
+// ============================================================
+
+const (
+	commandFlowTTL = 15 * time.Minute
+	commandFlowMax = 64 // open flows kept; the oldest goes first
+)
+
+// FlowStep is what the UI renders after each turn.
+type FlowStep struct {
+	FlowID  string   `json:"flow_id"`
+	Flow    string   `json:"flow"`
+	Step    string   `json:"step"`
+	Prompt  string   `json:"prompt"`
+	Options []string `json:"options,omitempty"`
+	Done    bool     `json:"done"`
+}
+
+// flowState is one running flow; Data carries values between steps.
+type flowState struct {
+	ID        string
+	Flow      string
+	Step      string
+	Data      map[string]string
+	UpdatedAt time.Time
+}
+
+type flowDef struct {
+	Usage string
+	// Start runs the first step (arg: optional, e.g. a conflict id / week key).
+	Start func(cfg Config, db *sql.DB, st *flowState, arg string) (FlowStep, error)
+	// Next consumes one answer for st.Step.
+	Next func(cfg Config, db *sql.DB, st *flowState, input string) (FlowStep, error)
+}
+
+var commandFlows = map[string]flowDef{
+	"replace_conflict": {
+		Usage: "resolve an open fact conflict step by step [conflict id]",
+		Start: startReplaceConflictFlow,
+		Next:  nextReplaceConflictFlow,
+	},
+	"weekly_review": {
+		Usage: "review a weekly summary, add a note or regenerate it [YYYY-Www]",
+		Start: startWeeklyReviewFlow,
+		Next:  nextWeeklyReviewFlow,
+	},
+}
+
+var openFlows struct {
+	sync.Mutex
+	m map[string]*flowState
+}
+
+// errFlowNotFound: unknown, finished or expired flow id.
+var errFlowNotFound = errors.New("flow not found (finished or expired)")
+
+// StartCommandFlow begins flow name and returns its first step.
+func StartCommandFlow(cfg Config, db *sql.DB, name, arg string) (FlowStep, error) {
+	name = strings.TrimSpace(name)
+	def, ok := commandFlows[name]
+	if !ok {
+		return FlowStep{}, fmt.Errorf("unknown flow: %s (have: %s)", name, strings.Join(commandFlowNames(), ", "))
+	}
+	st := &flowState{ID: newRequestID(), Flow: name, Data: map[string]string{}}
+	step, err := def.Start(cfg, db, st, strings.TrimSpace(arg))
+	if err != nil {
+		return FlowStep{}, err
+	}
+	return saveFlowStep(st, step), nil
+}
+
+// AdvanceCommandFlow feeds one answer to flow id.
+func AdvanceCommandFlow(cfg Config, db *sql.DB, id, input string) (FlowStep, error) {
+	now := time.Now()
+	openFlows.Lock()
+	st := openFlows.m[id]
+	if st != nil && now.Sub(st.UpdatedAt) > commandFlowTTL {
+		delete(openFlows.m, id)
+		st = nil
+	}
+	openFlows.Unlock()
+	if st == nil {
+		return FlowStep{}, errFlowNotFound
+	}
+
+	input = strings.TrimSpace(input)
+	if isFlowCancel(input) {
+		CancelCommandFlow(id)
+		return FlowStep{FlowID: id, Flow: st.Flow, Step: st.Step, Prompt: "[cancelled]", Done: true}, nil
+	}
+	step, err := commandFlows[st.Flow].Next(cfg, db, st, input)
+	if err != nil {
+		return FlowStep{}, err
+	}
+	return saveFlowStep(st, step), nil
+}
+
+// CancelCommandFlow drops flow id; false when it was not open.
+func CancelCommandFlow(id string) bool {
+	openFlows.Lock()
+	defer openFlows.Unlock()
+	if _, ok := openFlows.m[id]; !ok {
+		return false
+	}
+	delete(openFlows.m, id)
+	return true
+}
+
+// saveFlowStep stores st for the next answer (or forgets it when done).
+func saveFlowStep(st *flowState, step FlowStep) FlowStep {
+	step.FlowID, step.Flow = st.ID, st.Flow
+	if step.Step != "" {
+		st.Step = step.Step
+	}
+	step.Step = st.Step
+	st.UpdatedAt = time.Now()
+
+	openFlows.Lock()
+	defer openFlows.Unlock()
+	if step.Done {
+		delete(openFlows.m, st.ID)
+		return step
+	}
+	if openFlows.m == nil {
+		openFlows.m = map[string]*flowState{}
+	}
+	openFlows.m[st.ID] = st
+	for len(openFlows.m) > commandFlowMax {
+		var oldest *flowState
+		for _, s := range openFlows.m {
+			if oldest == nil || s.UpdatedAt.Before(oldest.UpdatedAt) {
+				oldest = s
+			}
+		}
+		delete(openFlows.m, oldest.ID)
+	}
+	return step
+}
+
+func isFlowCancel(input string) bool {
+	s := strings.ToLower(input)
+	return s == "cancel" || s == "/cancel" || s == "取消"
+}
+
+func commandFlowNames() []string {
+	names := make([]string, 0, len(commandFlows))
+	for n := range commandFlows {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flowDone ends a flow with msg.
+func flowDone(msg string) (FlowStep, error) {
+	return FlowStep{Prompt: msg, Done: true}, nil
+}
+
+// flowRetry repeats the current step with a hint.
+func flowRetry(hint string, options []string) (FlowStep, error) {
+	return FlowStep{Prompt: hint, Options: options}, nil
+}
+
+// runFlowListCommand is /flow: the available flows (running one needs the web UI or the API).
+func runFlowListCommand() string {
+	var b strings.Builder
+	b.WriteString("guided flows (web UI: /flow <name>; API: POST /api/flows/start):\n")
+	for _, n := range commandFlowNames() {
+		b.WriteString("- " + n + ": " + commandFlows[n].Usage + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// ---------- replace_conflict ----------
+
+func startReplaceConflictFlow(cfg Config, db *sql.DB, st *flowState, arg string) (FlowStep, error) {
+	if arg != "" {
+		st.Step = "pick"
+		return pickConflictStep(db, st, arg)
+	}
+	items, err := ListFactConflicts(db, cfg.User, 20)
+	if err != nil {
+		return FlowStep{}, err
+	}
+	if len(items) == 0 {
+		return flowDone("no open conflicts")
+	}
+	var b strings.Builder
+	opts := make([]string, 0, len(items))
+	b.WriteString("Which conflict? (reply with its id)\n")
+	for _, c := range items {
+		id := strconv.FormatInt(c.ID, 10)
+		b.WriteString(fmt.Sprintf("#%s %s\n  - %s\n  + %s\n", id, c.FactKey, c.ExistingFact, c.ProposedFact))
+		opts = append(opts, id)
+	}
+	return FlowStep{Step: "pick", Prompt: strings.TrimRight(b.String(), "\n"), Options: opts}, nil
+}
+
+// pickConflictStep loads conflict ref ("12" or "#12") and asks what to do with it.
+func pickConflictStep(db *sql.DB, st *flowState, ref string) (FlowStep, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(ref, "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return flowRetry("reply with a conflict id (e.g. 12), or cancel", nil)
+	}
+	c, err := getFactConflictByID(db, id)
+	if err != nil {
+		return FlowStep{}, err
+	}
+	if c == nil || c.Status != "conflict" {
+		return flowRetry(fmt.Sprintf("conflict #%d is not open; pick another id, or cancel", id), nil)
+	}
+	st.Data["id"] = strconv.FormatInt(id, 10)
+	st.Data["proposed"] = c.ProposedFact
+	d := explainFactConflict("key", c.ExistingFact, c.ProposedFact)
+	return FlowStep{
+		Step: "action",
+		Prompt: fmt.Sprintf("#%d %s\n  - %s\n  + %s\nkeep = keep the current fact, replace = take the new one, edit = type the correct fact yourself",
+			id, d.Explanation, c.ExistingFact, c.ProposedFact),
+		Options: []string{"keep", "replace", "edit"},
+	}, nil
+}
+
+func nextReplaceConflictFlow(cfg Config, db *sql.DB, st *flowState, input string) (FlowStep, error) {
+	now := time.Now().In(cfg.Location)
+	switch st.Step {
+	case "pick":
+		return pickConflictStep(db, st, input)
+
+	case "action":
+		id, _ := strconv.ParseInt(st.Data["id"], 10, 64)
+		switch strings.ToLower(input) {
+		case "keep", "k", "1":
+			if err := ResolveFactConflictKeep(db, id, now); err != nil {
+				return FlowStep{}, err
+			}
+			return conflictFlowDone(db, fmt.Sprintf("[ok] #%d: kept the current fact", id))
+		case "replace", "r", "2":
+			st.Data["replacement"] = st.Data["proposed"]
+		case "edit", "e", "3":
+			return FlowStep{Step: "edit", Prompt: "Type the fact to keep instead:"}, nil
+		default:
+			return flowRetry("reply keep, replace or edit (or cancel)", []string{"keep", "replace", "edit"})
+		}
+		return confirmReplaceStep(st)
+
+	case "edit":
+		if input == "" {
+			return flowRetry("type the fact to keep instead (or cancel)", nil)
+		}
+		st.Data["replacement"] = input
+		return confirmReplaceStep(st)
+
+	case "confirm":
+		switch strings.ToLower(input) {
+		case "yes", "y", "ok", "是", "确定":
+			id, _ := strconv.ParseInt(st.Data["id"], 10, 64)
+			if err := ResolveFactConflictReplace(cfg, db, id, st.Data["replacement"], now); err != nil {
+				return FlowStep{}, err
+			}
+			return conflictFlowDone(db, fmt.Sprintf("[ok] #%d: replaced with %q", id, st.Data["replacement"]))
+		case "no", "n", "back", "否":
+			return pickConflictStep(db, st, st.Data["id"])
+		}
+		return flowRetry("reply yes or no", []string{"yes", "no"})
+	}
+	return FlowStep{}, fmt.Errorf("replace_conflict: unknown step %q", st.Step)
+}
+
+func confirmReplaceStep(st *flowState) (FlowStep, error) {
+	return FlowStep{
+		Step:    "confirm",
+		Prompt:  fmt.Sprintf("Replace the current fact with %q?", st.Data["replacement"]),
+		Options: []string{"yes", "no"},
+	}, nil
+}
+
+// conflictFlowDone finishes with msg plus how many conflicts are still open.
+func conflictFlowDone(db *sql.DB, msg string) (FlowStep, error) {
+	if n := CountFactConflicts(db); n > 0 {
+		msg += fmt.Sprintf("\n%d more open (/flow replace_conflict)", n)
+	}
+	return flowDone(msg)
+}
+
+// ---------- weekly_review ----------
+
+func startWeeklyReviewFlow(cfg Config, db *sql.DB, st *flowState, arg string) (FlowStep, error) {
+	key := arg
+	if key == "" {
+		y, w := time.Now().In(cfg.Location).ISOWeek()
+		key = fmt.Sprintf("%04d-W%02d", y, w)
+	}
+	if y, w := parseWeekKey(key); y == 0 || w == 0 {
+		return FlowStep{}, fmt.Errorf("invalid week %q (want YYYY-Www)", key)
+	}
+	if err := ensureWeekly(foregroundLLM(cfg), db, key, false); err != nil {
+		return FlowStep{}, err
+	}
+	st.Data["week"] = key
+	return weeklyReviewStep(db, key, "")
+}
+
+// weeklyReviewStep shows the weekly summary text and the review options.
+func weeklyReviewStep(db *sql.DB, key, head string) (FlowStep, error) {
+	var text string
+	err := readDB(db).QueryRow(`
+		SELECT text FROM summaries
+		WHERE type='weekly' AND period_key=? AND deleted_at IS NULL
+	`, key).Scan(&text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return flowDone(fmt.Sprintf("no weekly summary for %s yet (no daily summaries that week)", key))
+	}
+	if err != nil {
+		return FlowStep{}, err
+	}
+	var b strings.Builder
+	if head != "" {
+		b.WriteString(head + "\n\n")
+	}
+	b.WriteString("Weekly " + key + ":\n" + strings.TrimSpace(text) + "\n")
+	for _, n := range annotationNotes(db, "weekly", key) {
+		b.WriteString("  note: " + n + "\n")
+	}
+	b.WriteString("\nAnything wrong or missing? Type a note to attach, or: regenerate / done")
+	return FlowStep{Step: "review", Prompt: b.String(), Options: []string{"regenerate", "done"}}, nil
+}
+
+func nextWeeklyReviewFlow(cfg Config, db *sql.DB, st *flowState, input string) (FlowStep, error) {
+	key := st.Data["week"]
+	switch st.Step {
+	case "review":
+		switch strings.ToLower(input) {
+		case "":
+			return flowRetry("type a note, or: regenerate / done", []string{"regenerate", "done"})
+		case "done", "ok", "好":
+			return flowDone("[ok] weekly " + key + " reviewed")
+		case "regenerate":
+			return FlowStep{
+				Step:    "regenerate",
+				Prompt:  "Rebuild weekly " + key + " from its daily summaries (notes are kept)?",
+				Options: []string{"yes", "no"},
+			}, nil
+		}
+		if _, err := AddSummaryAnnotation(cfg, db, "weekly", key, input); err != nil {
+			return FlowStep{}, err
+		}
+		return weeklyReviewStep(db, key, "[ok] note added")
+
+	case "regenerate":
+		switch strings.ToLower(input) {
+		case "yes", "y", "ok", "是", "确定":
+			if err := ensureWeekly(foregroundLLM(cfg), db, key, true); err != nil {
+				return FlowStep{}, err
+			}
+			return weeklyReviewStep(db, key, "[ok] regenerated")
+		case "no", "n", "否":
+			return weeklyReviewStep(db, key, "")
+		}
+		return flowRetry("reply yes or no", []string{"yes", "no"})
+	}
+	return FlowStep{}, fmt.Errorf("weekly_review: unknown step %q", st.Step)
+}
@@ -27,12 +27,29 @@ type Config struct {
 	DBPath             string
 	Location           *time.Location
 	KeepRawDays        int
+	TrashDays          int     // soft-deleted facts / pending / summaries are purged after N days
+	PendingMinConf     float64 // candidates below this confidence never enter pending_facts
+	PendingExpireDays  int     // pending facts older than N days are auto-rejected (0 = off)
+	PendingAcceptDays  int     // confident pending facts older than N days are auto-accepted (0 = off)
+	PendingAcceptConf  float64 // minimum confidence for PendingAcceptDays
 	MaxDailyJSONLBytes int64
+	ChunkMaxTokens     int             // rollup chunk size in estimated tokens (0 = MaxDailyJSONLBytes only; see chunking.go)
+	ChunkCharsPerToken TokenHeuristics // chars-per-token heuristics for the chunker
+	LogStorage         string          // file | sqlite | both (raw conversation storage)
 	HTTPTimeout        time.Duration
 
 	SearchTopK     int
 	SearchMinScore float64
 
+	// per-type score multipliers (fact/daily/weekly/monthly/document), applied before topK
+	SearchTypeWeights map[string]float64
+
+	// share of the full-text (FTS5) score in the blended search score (search_fts.go)
+	SearchFTSWeight float64
+
+	// vectors (per user and dimension) above which search uses the HNSW index (vector_index.go; 0 = off)
+	VectorIndexMinRows int
+
 	// ⭐ Rerank Intent Gate（只影响 rerank，不影响 search）
 	SearchMinStrong float64 // embedding 强度阈值（是否有明确语义中心）
 	SearchMinGap    float64 // top1-top2 最小差距（是否值得 rerank）
@@ -53,43 +70,216 @@ type Config struct {
 
 	// ---- Web ----
 	HTTPAddr                 string
-	HTTPAuthToken            string // optional; if set, API requires X-Auth-Token or Authorization: Bearer
-	HTTPAllowInsecureRemote  bool   // if true, allow binding to non-loopback without auth token
-	HTTPRateLimitRPM         int    // simple per-IP rate limit for API endpoints
-	HTTPMaxConcurrentStreams int    // limit concurrent /api/chat/stream
-	HTTPMaxInputBytes        int    // max bytes for chat input
+	HTTPAuthToken            string                   // optional; if set, API requires X-Auth-Token or Authorization: Bearer
+	HTTPAllowInsecureRemote  bool                     // if true, allow binding to non-loopback without auth token
+	HTTPRateLimitRPM         int                      // simple per-IP rate limit for API endpoints
+	HTTPMaxConcurrentStreams int                      // limit concurrent /api/chat/stream
+	HTTPStreamResumeWindow   time.Duration            // keep streamed deltas this long for /api/chat/stream/resume (0 = off)
+	HTTPShutdownTimeout      time.Duration            // drain chat streams this long on shutdown before cancelling them
+	HTTPMaxInputBytes        int                      // max bytes for chat input
+	HTTPAllowWipe            bool                     // enable POST /api/admin/wipe (off by default)
+	HTTPTrustedProxies       []string                 // CIDRs / IPs whose X-Forwarded-For is honoured (empty = never)
+	HTTPRateLimitPersist     bool                     // keep rate-limit buckets / bans across restarts (http_rate_limits)
+	HTTPRateLimitBanMinutes  int                      // ban an IP this long after a minute of rejected requests (0 = off)
+	HTTPRateLimitAllowlist   []string                 // CIDRs / IPs never rate-limited (http_rate_limit_tokens.go)
+	HTTPClientTokens         map[string]int           // extra API tokens → own rate-limit bucket rpm (0 = HTTPRateLimitRPM)
+	HTTPRouteTimeouts        map[string]time.Duration // per-route deadline overrides (http_deadline.go)
 
 	// ---- SQLite ----
 	SQLiteBusyTimeoutMS int
 	SQLiteJournalMode   string // WAL recommended
 	SQLiteSynchronous   string // NORMAL recommended
 	SQLiteMaxOpenConns  int
+	SQLiteReadConns     int // read-only pool for list / search paths (WAL only; 0 = off, see db_readers.go)
 
 	// ---- Recent Raw ----
 	// 最近原始对话注入的最大行数（jsonl 的最后 N 行）。
 	// 这个值越大，上下文承接能力越强，但 prompt 更长、污染风险也更高。
 	RecentMaxLines int
+
+	// ---- Recent Summaries ----
+	// 注入前 N 天的 daily 摘要（默认 1 = 昨天），弥补“今天的 daily 要到明天才有”的空窗。0 = 关闭。
+	RecentSummaryDays int
+
+	// ---- Context window ----
+	// 模型上下文长度（token）。0 = 未知；启动时 AutoTuneContext 会探测 /props 或 /v1/models。
+	MaxContextTokens int
+	ContextProbe     bool   // startup probe of the chat server for context length
+	MaxPromptTokens  int    // prompt budget (0 = 90% of MaxContextTokens; see prompt_budget.go)
+	PromptTokenizer  string // heuristic | server (llama.cpp /tokenize)
+
+	// ---- Answer style (see answer_style.go) ----
+	AnswerStyle         AnswerStyle // env default: TIMELAYER_ANSWER_STYLE / TIMELAYER_ANSWER_MAX_SENTENCES
+	AnswerProfile       string      // answer_style_profiles row used for the stored default
+	AnswerStyleOverride AnswerStyle // request-level (web), wins over the profile default
+
+	// ---- Assistant profiles (see assistants.go) ----
+	AssistantName       string           // TIMELAYER_ASSISTANT: default profile (CLI chat; web requests that name none)
+	Assistant           AssistantProfile // active profile for this turn (zero = none)
+	SummaryPerAssistant bool             // also build one daily summary per assistant
+
+	// ---- Users (see users.go) ----
+	User string // TIMELAYER_USER / web "user": whose memory a turn reads and writes ("" = primary user)
+
+	// ---- Sessions (see sessions.go) ----
+	Session string // web "session": conversation whose records feed recent_raw ("" = default stream)
+
+	// ---- Incognito (per turn, see chatTurnIncognito; web memory=off / CLI /incognito) ----
+	Incognito bool // answer with context, write nothing (logs, facts, prompts_log, audits)
+
+	// ---- Email ingestion (see email_ingest.go; off unless IMAPAddr + IMAPUser are set) ----
+	IMAPAddr         string // host:port, e.g. imap.example.com:993
+	IMAPUser         string
+	IMAPPassword     string
+	IMAPTLS          bool     // implicit TLS (default true); false for local bridges
+	IMAPFolders      []string // default INBOX
+	IMAPPollInterval time.Duration
+	IMAPSinceDays    int // first poll of a folder only looks back N days
+	IMAPMaxPerPoll   int
+
+	// ---- Embedding auto-heal (see embedding_heal.go; 0 = off) ----
+	EmbedHealInterval time.Duration
+
+	// ---- Batch embedding (see embedding_batch.go) ----
+	EmbedBatchSize int // texts per embedding request in reindex / pending clustering (1 = no batching)
+	EmbedParallel  int // embedding requests in flight
+
+	// ---- Rollup scheduler (see rollup_scheduler.go; minutes after local midnight, nil = off) ----
+	RollupTimes []int
+
+	// ---- Implicit fact capture limits (see facts_realtime_limit.go; 0 = no limit) ----
+	ImplicitMaxPerHour  int
+	ImplicitMaxPerDay   int
+	ImplicitKeyCooldown time.Duration // same fact_key is not re-proposed within this window
+	FactClarify         bool          // ask one short question about an ambiguous implicit candidate (see fact_clarify.go)
+
+	// ---- Notifications (see notify.go) ----
+	NotifyURL string // JSON webhook; empty = off
+
+	// ---- On this day (see on_this_day.go) ----
+	OnThisDayNotify  bool // push "一年前的今天" through NotifyURL on day change
+	OnThisDayContext bool // inject an on_this_day block into chat context
+
+	// ---- Q&A memory (see ask_memory.go) ----
+	AskMemory bool // keep supported /ask answers as "qa" summaries
+
+	// ---- Secret masking (see secret_mask.go) ----
+	SecretMask bool // mask API keys / passwords / tokens before the dialog log and summary prompts see them
+
+	// ---- Error reporting (see error_report.go; all empty = off) ----
+	ErrorReportFile      string // JSONL file
+	ErrorReportWebhook   string // POST JSON
+	ErrorReportSentryDSN string // Sentry-compatible DSN
+
+	// ---- Raw log archive (see archive.go / archive_remote.go) ----
+	ArchiveBackend        string // local | s3 | webdav
+	ArchiveS3Endpoint     string
+	ArchiveS3Bucket       string
+	ArchiveS3Region       string
+	ArchiveS3Prefix       string
+	ArchiveS3AccessKey    string
+	ArchiveS3SecretKey    string
+	ArchiveWebDAVURL      string
+	ArchiveWebDAVUser     string
+	ArchiveWebDAVPassword string
+
+	// ---- Summaries ----
+	OutputLanguage string // summaries / index text language: zh | en | <name> ({{OUTPUT_LANGUAGE}})
+	Summarizer     string // auto | llm | extractive (rollup strategy, see summarizer.go)
+
+	SummarySkipMinMessages int // substantive user messages below which a daily skips the LLM (0 = never skip, see summary_skip.go)
+	SummarySkipMinChars    int // substantive characters below which a daily skips the LLM
+
+	// ---- Search debug ----
+	SearchDebug string // off | store | log (rerank diagnostics, see search_debug.go)
+
+	// ---- Background LLM budget（0 = 不限） ----
+	BackgroundLLMDailyCalls  int
+	BackgroundLLMDailyTokens int  // estimated (prompt + output)
+	llmForeground            bool // set for user-run commands: no background budget (foregroundLLM)
+
+	// ---- Prompt log ----
+	PromptLogFullText bool // store full prompt text in prompts_log (hash is always stored)
+
+	// ---- Context audit ----
+	ContextAuditPersist       bool // store per-turn block traces in context_audits
+	ContextAuditRetentionDays int  // stored audits older than this are purged daily (0 = keep)
+
+	// ---- Embedding history retention (see embedding_history_retention.go) ----
+	EmbedHistoryKeep      int // newest drift-guard vectors kept per summary (0 = keep all)
+	EmbedHistoryDailyDays int // older vectors: one per day is kept for N days (0 = none)
+
+	// ---- Conflict auto-resolution (see conflict_policy.go) ----
+	ConflictPolicies []ConflictPolicy // tried in order on open conflicts (nil = off)
+
+	// ---- Fact tags ----
+	// 注入策略：哪些标签的事实允许进入 chat 上下文（例如共享设备上永不注入 health）。
+	ContextFactTags FactTagPolicy
+
+	// ---- Fact injection (see fact_relevance.go) ----
+	FactInjectMax         int      // max remembered facts injected per turn (tagged "always" facts are never cut)
+	FactRelevanceMinFacts int      // up to this many active facts, all are injected without filtering
+	FactRelevanceMinScore float64  // embedding similarity a fact needs to the question
+	FactAlwaysTags        []string // facts with these tags are always injected (pinned / core)
+	FactInjectOrder       string   // relevance | recent
+
+	FactCategoryBudgets map[string]int // per-category share of FactInjectMax when facts are cut (fact_category.go)
+
+	// Scope is request-scoped (chat/ask): constrains fact injection and retrieval
+	// to content tagged with the scope's tags/workspace. Empty = no constraint.
+	Scope SearchScope
 }
 
 func defaultConfig() Config {
+	// settings.env written by `local-ai init` / POST /api/onboarding (real ENV wins)
+	applySettingsFile()
+
 	home, _ := os.UserHomeDir()
 	base := filepath.Join(home, "local-ai")
+	if v := os.Getenv("TIMELAYER_BASE_DIR"); v != "" {
+		base = expandHomeDir(v)
+	}
 	loc := time.Local // ✅ 使用系统时区
+	if v := os.Getenv("TIMELAYER_TIMEZONE"); v != "" {
+		if l, err := time.LoadLocation(strings.TrimSpace(v)); err == nil {
+			loc = l
+		}
+	}
 
 	cfg := Config{
-		BaseDir:            base,
-		LogDir:             filepath.Join(base, "logs"),
-		ArchiveDir:         filepath.Join(base, "logs", "archive"),
-		PromptDir:          filepath.Join(base, "prompts"),
-		DBPath:             filepath.Join(base, "memory", "memory.sqlite"),
-		Location:           loc,
-		KeepRawDays:        45,
-		MaxDailyJSONLBytes: 25 * 1024 * 1024, // 25MB
-		HTTPTimeout:        600 * time.Second,
+		BaseDir:             base,
+		LogDir:              filepath.Join(base, "logs"),
+		ArchiveDir:          filepath.Join(base, "logs", "archive"),
+		ArchiveBackend:      archiveBackendLocal,
+		PromptDir:           filepath.Join(base, "prompts"),
+		DBPath:              filepath.Join(base, "memory", "memory.sqlite"),
+		Location:            loc,
+		KeepRawDays:         45,
+		TrashDays:           30,
+		PendingMinConf:      pendingFactMinConfidence,
+		PendingAcceptConf:   defaultPendingAcceptConf,
+		EmbedHealInterval:   10 * time.Minute,
+		RollupTimes:         []int{defaultRollupMinute},
+		ImplicitMaxPerHour:  5,
+		ImplicitMaxPerDay:   20,
+		ImplicitKeyCooldown: 6 * time.Hour,
+		IMAPTLS:             true,
+		IMAPFolders:         []string{"INBOX"},
+		IMAPPollInterval:    15 * time.Minute,
+		IMAPSinceDays:       7,
+		IMAPMaxPerPoll:      20,
+		MaxDailyJSONLBytes:  25 * 1024 * 1024, // 25MB
+		ChunkCharsPerToken:  defaultTokenHeuristics(),
+		LogStorage:          logStorageFile,
+		HTTPTimeout:         600 * time.Second,
 
 		SearchTopK:     5,
 		SearchMinScore: 0.75,
 
+		SearchTypeWeights:  defaultSearchTypeWeights(),
+		SearchFTSWeight:    defaultSearchFTSWeight,
+		VectorIndexMinRows: defaultVectorIndexMinRows,
+
 		// ⭐ rerank intent gate 默认值（推荐）
 		SearchMinStrong: 0.90,
 		SearchMinGap:    0.05,
@@ -111,15 +301,52 @@ func defaultConfig() Config {
 		HTTPAllowInsecureRemote:  false,
 		HTTPRateLimitRPM:         120,
 		HTTPMaxConcurrentStreams: 4,
+		HTTPStreamResumeWindow:   defaultStreamResumeWindow,
+		HTTPShutdownTimeout:      defaultHTTPShutdownTimeout,
 		HTTPMaxInputBytes:        64 * 1024,
+		HTTPAllowWipe:            false,
+		HTTPRateLimitPersist:     true,
+		HTTPRateLimitBanMinutes:  0,
 
 		SQLiteBusyTimeoutMS: 5000,
 		SQLiteJournalMode:   "WAL",
 		SQLiteSynchronous:   "NORMAL",
 		SQLiteMaxOpenConns:  1,
+		SQLiteReadConns:     defaultSQLiteReadConns,
 
 		// recent raw
 		RecentMaxLines: 20,
+
+		RecentSummaryDays: 1,
+
+		ContextProbe:    true,
+		PromptTokenizer: promptTokenizerHeuristic,
+
+		FactInjectMax:         defaultFactInjectMax,
+		FactRelevanceMinFacts: defaultFactRelevanceMinFacts,
+		FactRelevanceMinScore: defaultFactRelevanceMinScore,
+		FactAlwaysTags:        []string{"pinned", "core"},
+		FactCategoryBudgets:   defaultFactCategoryBudgets(),
+		FactInjectOrder:       "relevance",
+
+		AnswerProfile: defaultAnswerProfile,
+
+		Summarizer: summarizerAuto,
+
+		SummarySkipMinMessages: 1,
+		SummarySkipMinChars:    6,
+
+		SecretMask: true,
+
+		ContextAuditRetentionDays: 30,
+
+		EmbedHistoryKeep:      defaultEmbedHistoryKeep,
+		EmbedHistoryDailyDays: defaultEmbedHistoryDailyDays,
+
+		EmbedBatchSize: defaultEmbedBatchSize,
+		EmbedParallel:  defaultEmbedParallel,
+
+		SearchDebug: searchDebugStore,
 	}
 
 	// ENV overrides (optional)
@@ -143,26 +370,411 @@ func defaultConfig() Config {
 			cfg.HTTPAllowInsecureRemote = true
 		}
 	}
+	if v := os.Getenv("TIMELAYER_HTTP_ALLOW_WIPE"); v != "" {
+		if v == "1" || v == "true" || v == "TRUE" || v == "True" {
+			cfg.HTTPAllowWipe = true
+		}
+	}
 	if v := os.Getenv("TIMELAYER_HTTP_RATE_LIMIT_RPM"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
 			cfg.HTTPRateLimitRPM = n
 		}
 	}
+	if v := os.Getenv("TIMELAYER_HTTP_TRUSTED_PROXIES"); v != "" {
+		cfg.HTTPTrustedProxies = nil
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				cfg.HTTPTrustedProxies = append(cfg.HTTPTrustedProxies, p)
+			}
+		}
+	}
+	if v := os.Getenv("TIMELAYER_HTTP_RATE_LIMIT_PERSIST"); v != "" {
+		cfg.HTTPRateLimitPersist = v == "1" || v == "true" || v == "TRUE" || v == "True"
+	}
+	if v := os.Getenv("TIMELAYER_HTTP_RATE_LIMIT_BAN_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.HTTPRateLimitBanMinutes = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_HTTP_RATE_LIMIT_ALLOWLIST"); v != "" {
+		cfg.HTTPRateLimitAllowlist = nil
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				cfg.HTTPRateLimitAllowlist = append(cfg.HTTPRateLimitAllowlist, p)
+			}
+		}
+	}
+	if v := os.Getenv("TIMELAYER_HTTP_CLIENT_TOKENS"); v != "" {
+		cfg.HTTPClientTokens = parseClientTokens(v)
+	}
+	if v := os.Getenv("TIMELAYER_HTTP_ROUTE_TIMEOUTS"); v != "" {
+		cfg.HTTPRouteTimeouts = parseRouteTimeouts(v)
+	}
 	if v := os.Getenv("TIMELAYER_HTTP_MAX_CONCURRENT_STREAMS"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			cfg.HTTPMaxConcurrentStreams = n
 		}
 	}
+	if v := os.Getenv("TIMELAYER_HTTP_STREAM_RESUME_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.HTTPStreamResumeWindow = time.Duration(n) * time.Second
+		}
+	}
+	if v := os.Getenv("TIMELAYER_HTTP_SHUTDOWN_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.HTTPShutdownTimeout = time.Duration(n) * time.Second
+		}
+	}
 	if v := os.Getenv("TIMELAYER_HTTP_MAX_INPUT_BYTES"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			cfg.HTTPMaxInputBytes = n
 		}
 	}
+	if v := os.Getenv("TIMELAYER_LOG_STORAGE"); v != "" {
+		// file | sqlite | both
+		m := strings.ToLower(strings.TrimSpace(v))
+		switch m {
+		case logStorageFile, logStorageSQLite, logStorageBoth:
+			cfg.LogStorage = m
+		default:
+			// keep default
+		}
+	}
 	if v := os.Getenv("TIMELAYER_RECENT_MAX_LINES"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			cfg.RecentMaxLines = n
 		}
 	}
+	if v := os.Getenv("TIMELAYER_TRASH_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.TrashDays = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_PENDING_MIN_CONFIDENCE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f <= 1 {
+			cfg.PendingMinConf = f
+		}
+	}
+	if v := os.Getenv("TIMELAYER_PENDING_EXPIRE_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.PendingExpireDays = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_PENDING_AUTO_ACCEPT_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.PendingAcceptDays = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_PENDING_AUTO_ACCEPT_MIN_CONFIDENCE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f <= 1 {
+			cfg.PendingAcceptConf = f
+		}
+	}
+	if v := os.Getenv("TIMELAYER_RECENT_SUMMARY_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.RecentSummaryDays = n
+		}
+	}
+
+	if v := os.Getenv("TIMELAYER_MAX_CONTEXT_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxContextTokens = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_MAX_PROMPT_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxPromptTokens = n
+		}
+	}
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("TIMELAYER_PROMPT_TOKENIZER"))); v == promptTokenizerHeuristic || v == promptTokenizerServer {
+		cfg.PromptTokenizer = v
+	}
+	if v := os.Getenv("TIMELAYER_CHUNK_MAX_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.ChunkMaxTokens = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_CHUNK_CHARS_PER_TOKEN"); v != "" {
+		// ascii=4,cjk=1,other=2
+		cfg.ChunkCharsPerToken = parseTokenHeuristics(v)
+	}
+	if v := os.Getenv("TIMELAYER_CONTEXT_PROBE"); v != "" {
+		cfg.ContextProbe = v == "1" || v == "true" || v == "TRUE" || v == "True"
+	}
+
+	if v := os.Getenv("TIMELAYER_ANSWER_STYLE"); v != "" {
+		if s, ok := normalizeAnswerStyle(v); ok {
+			cfg.AnswerStyle.Style = s
+		}
+	}
+	if v := os.Getenv("TIMELAYER_ANSWER_MAX_SENTENCES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.AnswerStyle.MaxSentences = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_ANSWER_PROFILE"); v != "" {
+		cfg.AnswerProfile = strings.TrimSpace(v)
+	}
+
+	if v := os.Getenv("TIMELAYER_ASSISTANT"); v != "" {
+		cfg.AssistantName = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_SUMMARY_PER_ASSISTANT"); v != "" {
+		cfg.SummaryPerAssistant = v == "1" || v == "true" || v == "TRUE" || v == "True"
+	}
+	if v := os.Getenv("TIMELAYER_USER"); v != "" {
+		if u, err := normalizeUserName(v); err == nil {
+			cfg.User = u
+		}
+	}
+
+	if v := os.Getenv("TIMELAYER_EMBED_HEAL_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.EmbedHealInterval = time.Duration(n) * time.Minute
+		}
+	}
+	if v := os.Getenv("TIMELAYER_EMBED_BATCH_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.EmbedBatchSize = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_EMBED_PARALLEL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.EmbedParallel = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_ROLLUP_AT"); v != "" {
+		if strings.EqualFold(strings.TrimSpace(v), "off") {
+			cfg.RollupTimes = nil
+		} else if times, err := parseRollupTimes(v); err == nil && len(times) > 0 {
+			cfg.RollupTimes = times
+		}
+	}
+
+	if v := os.Getenv("TIMELAYER_IMPLICIT_MAX_PER_HOUR"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ImplicitMaxPerHour = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_IMPLICIT_MAX_PER_DAY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ImplicitMaxPerDay = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_IMPLICIT_COOLDOWN_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ImplicitKeyCooldown = time.Duration(n) * time.Minute
+		}
+	}
+	if v := os.Getenv("TIMELAYER_FACT_CLARIFY"); v != "" {
+		cfg.FactClarify = v == "1" || v == "true" || v == "TRUE" || v == "True"
+	}
+
+	if v := os.Getenv("TIMELAYER_NOTIFY_URL"); v != "" {
+		cfg.NotifyURL = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ON_THIS_DAY_NOTIFY"); v != "" {
+		if v == "1" || v == "true" || v == "TRUE" || v == "True" {
+			cfg.OnThisDayNotify = true
+		}
+	}
+	if v := os.Getenv("TIMELAYER_ON_THIS_DAY_CONTEXT"); v != "" {
+		if v == "1" || v == "true" || v == "TRUE" || v == "True" {
+			cfg.OnThisDayContext = true
+		}
+	}
+	if v := os.Getenv("TIMELAYER_ASK_MEMORY"); v != "" {
+		cfg.AskMemory = v == "1" || v == "true" || v == "TRUE" || v == "True"
+	}
+	if v := os.Getenv("TIMELAYER_SECRET_MASK"); v != "" {
+		cfg.SecretMask = v == "1" || v == "true" || v == "TRUE" || v == "True"
+	}
+	if v := os.Getenv("TIMELAYER_ERROR_REPORT_FILE"); v != "" {
+		cfg.ErrorReportFile = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ERROR_REPORT_WEBHOOK"); v != "" {
+		cfg.ErrorReportWebhook = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ERROR_REPORT_SENTRY_DSN"); v != "" {
+		cfg.ErrorReportSentryDSN = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_BACKEND"); v != "" {
+		cfg.ArchiveBackend = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_S3_ENDPOINT"); v != "" {
+		cfg.ArchiveS3Endpoint = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_S3_BUCKET"); v != "" {
+		cfg.ArchiveS3Bucket = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_S3_REGION"); v != "" {
+		cfg.ArchiveS3Region = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_S3_PREFIX"); v != "" {
+		cfg.ArchiveS3Prefix = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_S3_ACCESS_KEY"); v != "" {
+		cfg.ArchiveS3AccessKey = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_S3_SECRET_KEY"); v != "" {
+		cfg.ArchiveS3SecretKey = v
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_WEBDAV_URL"); v != "" {
+		cfg.ArchiveWebDAVURL = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_WEBDAV_USER"); v != "" {
+		cfg.ArchiveWebDAVUser = v
+	}
+	if v := os.Getenv("TIMELAYER_ARCHIVE_WEBDAV_PASSWORD"); v != "" {
+		cfg.ArchiveWebDAVPassword = v
+	}
+
+	if v := os.Getenv("TIMELAYER_IMAP_ADDR"); v != "" {
+		cfg.IMAPAddr = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_IMAP_USER"); v != "" {
+		cfg.IMAPUser = v
+	}
+	if v := os.Getenv("TIMELAYER_IMAP_PASSWORD"); v != "" {
+		cfg.IMAPPassword = v
+	}
+	if v := os.Getenv("TIMELAYER_IMAP_TLS"); v != "" {
+		cfg.IMAPTLS = v == "1" || v == "true" || v == "TRUE" || v == "True"
+	}
+	if v := os.Getenv("TIMELAYER_IMAP_FOLDERS"); v != "" {
+		var folders []string
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				folders = append(folders, f)
+			}
+		}
+		if len(folders) > 0 {
+			cfg.IMAPFolders = folders
+		}
+	}
+	if v := os.Getenv("TIMELAYER_IMAP_POLL_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.IMAPPollInterval = time.Duration(n) * time.Minute
+		}
+	}
+	if v := os.Getenv("TIMELAYER_IMAP_SINCE_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.IMAPSinceDays = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_IMAP_MAX_PER_POLL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.IMAPMaxPerPoll = n
+		}
+	}
+
+	if v := os.Getenv("TIMELAYER_OUTPUT_LANGUAGE"); v != "" {
+		cfg.OutputLanguage = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("TIMELAYER_SUMMARIZER"); v != "" {
+		// auto | llm | extractive
+		m := strings.ToLower(strings.TrimSpace(v))
+		switch m {
+		case summarizerAuto, summarizerLLM, summarizerExtractive:
+			cfg.Summarizer = m
+		default:
+			// keep default
+		}
+	}
+	if v := os.Getenv("TIMELAYER_SUMMARY_SKIP_MIN_MESSAGES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.SummarySkipMinMessages = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_SUMMARY_SKIP_MIN_CHARS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.SummarySkipMinChars = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_SEARCH_TYPE_WEIGHTS"); v != "" {
+		// fact=1.3,daily=1.1,...
+		cfg.SearchTypeWeights = parseSearchTypeWeights(v)
+	}
+	if v := os.Getenv("TIMELAYER_SEARCH_DEBUG"); v != "" {
+		// off | store | log
+		m := strings.ToLower(strings.TrimSpace(v))
+		switch m {
+		case searchDebugOff, searchDebugStore, searchDebugLog:
+			cfg.SearchDebug = m
+		default:
+			// keep default
+		}
+	}
+	if v := os.Getenv("TIMELAYER_BG_LLM_DAILY_CALLS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.BackgroundLLMDailyCalls = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_BG_LLM_DAILY_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.BackgroundLLMDailyTokens = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_PROMPT_LOG_FULL"); v != "" {
+		cfg.PromptLogFullText = v == "1" || v == "true" || v == "TRUE" || v == "True"
+	}
+	if v := os.Getenv("TIMELAYER_CONTEXT_AUDIT_PERSIST"); v != "" {
+		cfg.ContextAuditPersist = v == "1" || v == "true" || v == "TRUE" || v == "True"
+	}
+	if v := os.Getenv("TIMELAYER_CONTEXT_AUDIT_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ContextAuditRetentionDays = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_EMBED_HISTORY_KEEP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.EmbedHistoryKeep = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_EMBED_HISTORY_DAILY_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.EmbedHistoryDailyDays = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_CONFLICT_POLICIES"); v != "" {
+		cfg.ConflictPolicies = parseConflictPolicies(v)
+	}
+
+	if v := os.Getenv("TIMELAYER_CONTEXT_INCLUDE_TAGS"); v != "" {
+		cfg.ContextFactTags.Include = parseFactTagList(v)
+	}
+	if v := os.Getenv("TIMELAYER_CONTEXT_EXCLUDE_TAGS"); v != "" {
+		cfg.ContextFactTags.Exclude = parseFactTagList(v)
+	}
+
+	if v := os.Getenv("TIMELAYER_FACT_INJECT_MAX"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.FactInjectMax = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_FACT_RELEVANCE_MIN_FACTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.FactRelevanceMinFacts = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_FACT_RELEVANCE_MIN_SCORE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			cfg.FactRelevanceMinScore = f
+		}
+	}
+	if v, ok := os.LookupEnv("TIMELAYER_FACT_ALWAYS_TAGS"); ok {
+		cfg.FactAlwaysTags = parseFactTagList(v)
+	}
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("TIMELAYER_FACT_INJECT_ORDER"))); v == "relevance" || v == "recent" {
+		cfg.FactInjectOrder = v
+	}
+	if v := os.Getenv("TIMELAYER_FACT_CATEGORY_BUDGETS"); v != "" {
+		if strings.EqualFold(strings.TrimSpace(v), "off") {
+			cfg.FactCategoryBudgets = nil
+		} else if budgets, err := parseFactCategoryBudgets(v); err == nil && len(budgets) > 0 {
+			cfg.FactCategoryBudgets = budgets
+		}
+	}
 
 	// ---- Rerank ENV ----
 	if v := os.Getenv("TIMELAYER_ENABLE_RERANK"); v != "" {
@@ -210,6 +822,27 @@ func defaultConfig() Config {
 		}
 	}
 
+	if v := os.Getenv("TIMELAYER_SEARCH_TOP_K"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.SearchTopK = n
+		}
+	}
+	if v := os.Getenv("TIMELAYER_SEARCH_MIN_SCORE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			cfg.SearchMinScore = f
+		}
+	}
+	if v := os.Getenv("TIMELAYER_SEARCH_FTS_WEIGHT"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			cfg.SearchFTSWeight = f
+		}
+	}
+	if v := os.Getenv("TIMELAYER_VECTOR_INDEX_MIN_ROWS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.VectorIndexMinRows = n
+		}
+	}
+
 	// ---- Search Intent Gate ENV (only affects rerank gating) ----
 	if v := os.Getenv("TIMELAYER_SEARCH_MIN_STRONG"); v != "" {
 		if f, err := strconv.ParseFloat(v, 64); err == nil {
@@ -252,6 +885,11 @@ func defaultConfig() Config {
 			cfg.SQLiteMaxOpenConns = n
 		}
 	}
+	if v := os.Getenv("TIMELAYER_SQLITE_READ_CONNS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.SQLiteReadConns = n
+		}
+	}
 
 	return cfg
 }
@@ -0,0 +1,237 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Conflict auto-resolution policies
+// - TIMELAYER_CONFLICT_POLICIES="same_value,manual_wins,newest_wins:7" (off
+//   by default). Rules are tried in order on every open conflict; the first
+//   that applies decides, the rest stay for review:
+//     same_value       the normalized values match (05-03 vs 5月3日) → keep
+//     manual_wins      the active fact was set by hand (/remember, the web
+//                      UI, a conflict replace) and the proposal was not → keep
+//     newest_wins:N    the conflict is open for N days → replace
+// - The sweeper ("conflict_policy") runs at startup and every
+//   conflictPolicySweepEvery. CLI / chat: /conflict_sweep [--dry-run].
+// - Decisions go through the normal keep / replace path; user_facts_history
+//   records them with source_type conflict_policy:<rule>.
+// ============================================================
+
+const (
+	conflictPolicySweepEvery = 15 * time.Minute
+
+	conflictPolicySameValue  = "same_value"
+	conflictPolicyManualWins = "manual_wins"
+	conflictPolicyNewestWins = "newest_wins"
+)
+
+// manualFactSources are history source types of a fact set by hand.
+var manualFactSources = map[string]bool{
+	"remember":         true,
+	"remember_cli":     true,
+	"remember_ui":      true,
+	"conflict_replace": true,
+}
+
+// ConflictPolicy is one rule of TIMELAYER_CONFLICT_POLICIES.
+type ConflictPolicy struct {
+	Name string
+	Days int // newest_wins only
+}
+
+func (p ConflictPolicy) String() string {
+	if p.Name == conflictPolicyNewestWins {
+		return fmt.Sprintf("%s:%d", p.Name, p.Days)
+	}
+	return p.Name
+}
+
+// parseConflictPolicies parses "same_value,manual_wins,newest_wins:7"
+// (unknown or malformed rules are skipped).
+func parseConflictPolicies(s string) []ConflictPolicy {
+	var out []ConflictPolicy
+	for _, part := range strings.Split(s, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), ":")
+		switch name {
+		case conflictPolicySameValue, conflictPolicyManualWins:
+			out = append(out, ConflictPolicy{Name: name})
+		case conflictPolicyNewestWins:
+			n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(arg), "d"))
+			if err == nil && n >= 0 {
+				out = append(out, ConflictPolicy{Name: name, Days: n})
+			}
+		}
+	}
+	return out
+}
+
+// ConflictDecision is one conflict a policy resolved.
+type ConflictDecision struct {
+	ConflictID int64  `json:"conflict_id"`
+	FactKey    string `json:"fact_key"`
+	Policy     string `json:"policy"`
+	Action     string `json:"action"` // keep | replace
+	Existing   string `json:"existing"`
+	Proposed   string `json:"proposed"`
+	Error      string `json:"error,omitempty"`
+}
+
+// decideFactConflict returns the first policy that applies to c.
+func decideFactConflict(cfg Config, db *sql.DB, c UserFactConflict, now time.Time) (ConflictPolicy, string, bool) {
+	current, ok := getActiveUserFactByKey(db, c.FactKey)
+	if !ok {
+		current = c.ExistingFact
+	}
+	for _, p := range cfg.ConflictPolicies {
+		switch p.Name {
+		case conflictPolicySameValue:
+			d := explainFactConflict("", current, c.ProposedFact)
+			if strings.EqualFold(d.ExistingValue, d.NewValue) {
+				return p, "keep", true
+			}
+		case conflictPolicyManualWins:
+			var src string
+			_ = readDB(db).QueryRow(`
+				SELECT source_type FROM user_facts_history
+				WHERE fact_key=? AND status='active'
+				ORDER BY version DESC, id DESC LIMIT 1
+			`, c.FactKey).Scan(&src)
+			if ok && manualFactSources[src] && !manualFactSources[c.ProposedSourceType] {
+				return p, "keep", true
+			}
+		case conflictPolicyNewestWins:
+			t, err := time.Parse(time.RFC3339, c.CreatedAt)
+			if err == nil && !now.Before(t.AddDate(0, 0, p.Days)) {
+				return p, "replace", true
+			}
+		}
+	}
+	return ConflictPolicy{}, "", false
+}
+
+// SweepFactConflicts applies the policies to every open conflict (oldest
+// first). With dryRun nothing is written.
+func SweepFactConflicts(cfg Config, db *sql.DB, dryRun bool) ([]ConflictDecision, error) {
+	out := []ConflictDecision{}
+	if db == nil || len(cfg.ConflictPolicies) == 0 {
+		return out, nil
+	}
+	rows, err := db.Query(`
+		SELECT id, fact_key, existing_fact, proposed_fact, proposed_source_type, proposed_source_key, status, created_at, updated_at
+		FROM user_fact_conflicts
+		WHERE status='conflict'
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var open []UserFactConflict
+	for rows.Next() {
+		var c UserFactConflict
+		if err := rows.Scan(&c.ID, &c.FactKey, &c.ExistingFact, &c.ProposedFact, &c.ProposedSourceType, &c.ProposedSourceKey, &c.Status, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		open = append(open, c)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().In(cfg.Location)
+	for _, c := range open {
+		p, action, ok := decideFactConflict(cfg, db, c, now)
+		if !ok {
+			continue
+		}
+		d := ConflictDecision{ConflictID: c.ID, FactKey: c.FactKey, Policy: p.String(), Action: action, Existing: c.ExistingFact, Proposed: c.ProposedFact}
+		if !dryRun {
+			source := "conflict_policy:" + p.Name
+			var err error
+			if action == "replace" {
+				err = resolveFactConflictReplaceAs(cfg, db, c.ID, "", source, now)
+			} else {
+				err = resolveFactConflictKeepAs(db, c.ID, source, now)
+			}
+			if err != nil {
+				d.Error = err.Error()
+				log.Printf("[warn] conflict policy %s on #%d failed: %v", d.Policy, c.ID, err)
+			} else {
+				log.Printf("[info] conflict #%d (%s): %s by policy %s", c.ID, c.FactKey, action, d.Policy)
+			}
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// runConflictPolicySweeper sweeps now and then every conflictPolicySweepEvery.
+func runConflictPolicySweeper(ctx context.Context, cfg Config, db *sql.DB) {
+	if db == nil || len(cfg.ConflictPolicies) == 0 {
+		return
+	}
+	t := time.NewTicker(conflictPolicySweepEvery)
+	defer t.Stop()
+	for {
+		if _, err := SweepFactConflicts(cfg, db, false); err != nil {
+			log.Printf("[warn] conflict policy sweep failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// runConflictSweepCommand implements /conflict_sweep [--dry-run].
+func runConflictSweepCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	dryRun := false
+	for _, f := range strings.Fields(arg) {
+		switch f {
+		case "--dry-run":
+			dryRun = true
+		default:
+			return "usage: /conflict_sweep [--dry-run]", nil
+		}
+	}
+	if len(cfg.ConflictPolicies) == 0 {
+		return "(no conflict policies; set TIMELAYER_CONFLICT_POLICIES, e.g. same_value,manual_wins,newest_wins:7)", nil
+	}
+	ds, err := SweepFactConflicts(cfg, db, dryRun)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(cfg.ConflictPolicies))
+	for _, p := range cfg.ConflictPolicies {
+		names = append(names, p.String())
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "policies: %s\n", strings.Join(names, ", "))
+	if len(ds) == 0 {
+		b.WriteString("(no open conflict matched a policy)")
+		return b.String(), nil
+	}
+	verb := "resolved"
+	if dryRun {
+		verb = "would resolve"
+	}
+	for _, d := range ds {
+		fmt.Fprintf(&b, "#%d %s %s by %s: 「%s」 vs 「%s」", d.ConflictID, verb, d.Action, d.Policy, d.Existing, d.Proposed)
+		if d.Error != "" {
+			fmt.Fprintf(&b, " [error] %s", d.Error)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
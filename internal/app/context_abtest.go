@@ -0,0 +1,488 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ============================================================
+// Context injection A/B harness (offline)
+//   local-ai abtest --b=B.env [--a=A.env] (--questions=FILE | --from-prompts=N)
+//                   [--answer=none|mock|llm] [--out=PATH] [--json]
+// - A / B are env files (TIMELAYER_*=value lines) applied on top of the
+//   current environment; --a omitted = the current config as is.
+// - Each question goes through buildSystemPrompt under both configs (same
+//   path as a real turn, incl. token budget) — nothing is written: no logs,
+//   prompts_log, audits or fact usage counters.
+// - --answer=mock answers with a deterministic stand-in LLM (the context lines
+//   that share terms with the question), so "would the answer change" is
+//   reviewable without a model; --answer=llm calls the configured chat model.
+// - The report (Markdown, or JSON with --json) lists per question the blocks
+//   only in A / only in B and the answers; default path
+//   <BaseDir>/exports/abtest-<ts>.md.
+// ============================================================
+
+type ABQuestion struct {
+	Question string `json:"question"`
+	Date     string `json:"date,omitempty"` // context day (default today)
+}
+
+type ABBlock struct {
+	Source  string `json:"source"`
+	Len     int    `json:"len"`
+	Hash    string `json:"hash"`
+	Preview string `json:"preview"`
+	Dropped bool   `json:"dropped,omitempty"`
+}
+
+type ABItem struct {
+	Question       string    `json:"question"`
+	Date           string    `json:"date"`
+	PromptHashA    string    `json:"prompt_hash_a"`
+	PromptHashB    string    `json:"prompt_hash_b"`
+	OnlyA          []ABBlock `json:"only_a,omitempty"`
+	OnlyB          []ABBlock `json:"only_b,omitempty"`
+	Same           int       `json:"same"`
+	ContextChanged bool      `json:"context_changed"`
+	AnswerA        string    `json:"answer_a,omitempty"`
+	AnswerB        string    `json:"answer_b,omitempty"`
+	AnswerChanged  bool      `json:"answer_changed"`
+	Error          string    `json:"error,omitempty"`
+}
+
+type ABReport struct {
+	A              string   `json:"a"`
+	B              string   `json:"b"`
+	Answer         string   `json:"answer"` // none | mock | llm
+	Questions      int      `json:"questions"`
+	ContextChanged int      `json:"context_changed"`
+	AnswerChanged  int      `json:"answer_changed"`
+	Items          []ABItem `json:"items"`
+	GeneratedAt    string   `json:"generated_at"`
+}
+
+// loadABQuestions reads one question per line, or JSONL {"question","date"}.
+// Blank lines and # comments are skipped.
+func loadABQuestions(path string) ([]ABQuestion, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []ABQuestion
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "{") {
+			var q ABQuestion
+			if err := json.Unmarshal([]byte(line), &q); err != nil {
+				return nil, fmt.Errorf("%s: %w", clipRunes(line, 60), err)
+			}
+			if strings.TrimSpace(q.Question) != "" {
+				out = append(out, q)
+			}
+			continue
+		}
+		out = append(out, ABQuestion{Question: line})
+	}
+	return out, sc.Err()
+}
+
+// recentABQuestions takes the last n user inputs recorded in prompts_log
+// (only stored with TIMELAYER_PROMPT_LOG_FULL=true).
+func recentABQuestions(db *sql.DB, n int) ([]ABQuestion, error) {
+	rows, err := db.Query(`
+		SELECT user_input, day FROM prompts_log
+		WHERE user_input IS NOT NULL AND user_input != ''
+		ORDER BY created_at DESC LIMIT ?
+	`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ABQuestion
+	for rows.Next() {
+		var q ABQuestion
+		if err := rows.Scan(&q.Question, &q.Date); err != nil {
+			return nil, err
+		}
+		q.Question = strings.TrimSpace(strings.TrimPrefix(q.Question, "【用户原话】\n"))
+		out = append(out, q)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no recorded questions in prompts_log (enable TIMELAYER_PROMPT_LOG_FULL=true)")
+	}
+	return out, rows.Err()
+}
+
+// abVariantConfig builds a config with the KEY=VALUE lines of envFile applied
+// on top of the current environment (restored afterwards).
+func abVariantConfig(base Config, envFile string) (Config, error) {
+	if envFile == "" {
+		return base, nil
+	}
+	kvs, err := readEnvFile(envFile)
+	if err != nil {
+		return base, err
+	}
+	restore := map[string]*string{}
+	defer func() {
+		for k, v := range restore {
+			if v == nil {
+				_ = os.Unsetenv(k)
+			} else {
+				_ = os.Setenv(k, *v)
+			}
+		}
+	}()
+	for _, kv := range kvs {
+		k, v := kv[0], kv[1]
+		if _, seen := restore[k]; !seen {
+			if old, had := os.LookupEnv(k); had {
+				restore[k] = &old
+			} else {
+				restore[k] = nil
+			}
+		}
+		_ = os.Setenv(k, v)
+	}
+	cfg := defaultConfig()
+	if cfg.MaxContextTokens <= 0 {
+		cfg.MaxContextTokens = base.MaxContextTokens // keep the probed value (no second probe)
+	}
+	return AutoTuneContext(cfg), nil
+}
+
+// RunContextAB assembles the context for every question under a and b.
+// answer: none | mock | llm.
+func RunContextAB(a, b Config, nameA, nameB string, db *sql.DB, qs []ABQuestion, answer string) ABReport {
+	rep := ABReport{A: nameA, B: nameB, Answer: answer, Questions: len(qs)}
+	for _, q := range qs {
+		it := runContextABOne(a, b, db, q, answer)
+		if it.ContextChanged {
+			rep.ContextChanged++
+		}
+		if it.AnswerChanged {
+			rep.AnswerChanged++
+		}
+		rep.Items = append(rep.Items, it)
+	}
+	rep.GeneratedAt = time.Now().In(a.Location).Format(time.RFC3339)
+	return rep
+}
+
+func runContextABOne(a, b Config, db *sql.DB, q ABQuestion, answer string) ABItem {
+	question := strings.TrimSpace(q.Question)
+	now := time.Now().In(a.Location)
+	if d, err := time.ParseInLocation("2006-01-02", q.Date, a.Location); err == nil && q.Date != now.Format("2006-01-02") {
+		now = d
+	}
+	it := ABItem{Question: question, Date: now.Format("2006-01-02")}
+	modelInput := "【用户原话】\n" + question
+
+	sysA, ctxA, blocksA := buildSystemPrompt(a, db, now, question)
+	sysB, ctxB, blocksB := buildSystemPrompt(b, db, now, question)
+	it.PromptHashA = promptHash(sysA, ctxA, modelInput)
+	it.PromptHashB = promptHash(sysB, ctxB, modelInput)
+	it.ContextChanged = it.PromptHashA != it.PromptHashB
+
+	ba, bb := abBlocks(blocksA), abBlocks(blocksB)
+	inB := map[string]int{}
+	for _, x := range bb {
+		inB[abBlockKey(x)]++
+	}
+	for _, x := range ba {
+		k := abBlockKey(x)
+		if inB[k] > 0 {
+			inB[k]--
+			it.Same++
+			continue
+		}
+		it.OnlyA = append(it.OnlyA, x)
+	}
+	inA := map[string]int{}
+	for _, x := range ba {
+		inA[abBlockKey(x)]++
+	}
+	for _, x := range bb {
+		k := abBlockKey(x)
+		if inA[k] > 0 {
+			inA[k]--
+			continue
+		}
+		it.OnlyB = append(it.OnlyB, x)
+	}
+
+	switch answer {
+	case "mock":
+		it.AnswerA = mockLLMAnswer(ctxA, question)
+		it.AnswerB = mockLLMAnswer(ctxB, question)
+	case "llm":
+		var errA, errB error
+		it.AnswerA, errA = streamChatWithContextCtx(context.Background(), a, sysA, ctxA, modelInput, nil)
+		it.AnswerB, errB = streamChatWithContextCtx(context.Background(), b, sysB, ctxB, modelInput, nil)
+		if err := errors.Join(errA, errB); err != nil {
+			it.Error = err.Error()
+		}
+		it.AnswerA, it.AnswerB = sanitizeAssistantText(it.AnswerA), sanitizeAssistantText(it.AnswerB)
+	}
+	it.AnswerChanged = answer != "none" && strings.TrimSpace(it.AnswerA) != strings.TrimSpace(it.AnswerB)
+	return it
+}
+
+func abBlocks(blocks []PromptBlock) []ABBlock {
+	out := make([]ABBlock, 0, len(blocks))
+	for _, b := range blocks {
+		sum := sha256.Sum256([]byte(b.Content))
+		x := ABBlock{
+			Source:  b.Source,
+			Len:     len([]rune(b.Content)),
+			Hash:    hex.EncodeToString(sum[:6]),
+			Preview: clipRunes(strings.Join(strings.Fields(b.Content), " "), 160),
+		}
+		if b.Trace != nil {
+			x.Dropped = b.Trace.Dropped
+		}
+		out = append(out, x)
+	}
+	return out
+}
+
+// abBlockKey: a block kept in A but dropped for budget in B counts as changed.
+func abBlockKey(x ABBlock) string {
+	return fmt.Sprintf("%s|%s|%t", x.Source, x.Hash, x.Dropped)
+}
+
+// mockLLMAnswer is a deterministic stand-in for the chat model: it "answers"
+// with the context lines sharing the most terms with the question (top 3).
+// Good enough to see whether a policy change alters what an answer can draw on.
+func mockLLMAnswer(ctxMsgs []map[string]string, question string) string {
+	terms := abTerms(question)
+	if len(terms) == 0 {
+		return "[mock] (no terms)"
+	}
+	type scored struct {
+		line  string
+		score int
+	}
+	var cands []scored
+	seen := map[string]bool{}
+	for _, m := range ctxMsgs {
+		for _, line := range strings.Split(m["content"], "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(line, "-• "))
+			if line == "" || seen[line] {
+				continue
+			}
+			seen[line] = true
+			lt := abTerms(line)
+			n := 0
+			for t := range terms {
+				if lt[t] {
+					n++
+				}
+			}
+			if n > 0 {
+				cands = append(cands, scored{line, n})
+			}
+		}
+	}
+	if len(cands) == 0 {
+		return "[mock] 不知道（上下文中没有相关信息）"
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].score > cands[j].score })
+	if len(cands) > 3 {
+		cands = cands[:3]
+	}
+	parts := make([]string, 0, len(cands))
+	for _, c := range cands {
+		parts = append(parts, clipRunes(c.line, 120))
+	}
+	return "[mock] " + strings.Join(parts, " / ")
+}
+
+// abTerms: lower-cased words (latin / digits) plus CJK bigrams.
+func abTerms(s string) map[string]bool {
+	out := map[string]bool{}
+	var word []rune
+	var han []rune
+	flush := func() {
+		if len(word) > 1 {
+			out[strings.ToLower(string(word))] = true
+		}
+		word = word[:0]
+		for i := 0; i+1 < len(han); i++ {
+			out[string(han[i:i+2])] = true
+		}
+		han = han[:0]
+	}
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if len(word) > 0 {
+				w := han
+				flush()
+				han = w[:0]
+			}
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(han) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+func renderABReportMarkdown(rep ABReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Context A/B report\n\n")
+	fmt.Fprintf(&b, "- A: `%s`\n- B: `%s`\n- answers: %s\n- generated: %s\n\n", rep.A, rep.B, rep.Answer, rep.GeneratedAt)
+	fmt.Fprintf(&b, "**%d** questions · context changed: **%d** · answer changed: **%d**\n\n", rep.Questions, rep.ContextChanged, rep.AnswerChanged)
+	for i, it := range rep.Items {
+		mark := "="
+		if it.ContextChanged {
+			mark = "≠"
+		}
+		fmt.Fprintf(&b, "## %d. [%s] %s\n\n", i+1, mark, it.Question)
+		fmt.Fprintf(&b, "- date: %s · same blocks: %d · only A: %d · only B: %d\n", it.Date, it.Same, len(it.OnlyA), len(it.OnlyB))
+		for _, x := range it.OnlyA {
+			fmt.Fprintf(&b, "  - `- A` %s (%d chars%s): %s\n", x.Source, x.Len, abDroppedNote(x), x.Preview)
+		}
+		for _, x := range it.OnlyB {
+			fmt.Fprintf(&b, "  - `+ B` %s (%d chars%s): %s\n", x.Source, x.Len, abDroppedNote(x), x.Preview)
+		}
+		if rep.Answer != "none" {
+			if it.AnswerChanged {
+				fmt.Fprintf(&b, "- answer A: %s\n- answer B: %s\n", clipRunes(it.AnswerA, 400), clipRunes(it.AnswerB, 400))
+			} else {
+				fmt.Fprintf(&b, "- answer unchanged: %s\n", clipRunes(it.AnswerA, 200))
+			}
+		}
+		if it.Error != "" {
+			fmt.Fprintf(&b, "- error: %s\n", it.Error)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func abDroppedNote(x ABBlock) string {
+	if x.Dropped {
+		return ", dropped"
+	}
+	return ""
+}
+
+// runABTestCLI implements `local-ai abtest ...` (see the header comment).
+func runABTestCLI(cfg Config, args []string) int {
+	var envA, envB, qfile, out string
+	answer, fromPrompts, asJSON := "none", 0, false
+	for _, arg := range args {
+		k, v, _ := strings.Cut(arg, "=")
+		switch k {
+		case "--a":
+			envA = v
+		case "--b":
+			envB = v
+		case "--questions":
+			qfile = v
+		case "--from-prompts":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				fmt.Println("--from-prompts needs a positive number")
+				return 2
+			}
+			fromPrompts = n
+		case "--answer":
+			answer = strings.ToLower(v)
+		case "--out":
+			out = v
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Println("unknown flag:", arg)
+			return 2
+		}
+	}
+	if envB == "" || (qfile == "") == (fromPrompts == 0) || (answer != "none" && answer != "mock" && answer != "llm") {
+		fmt.Println("usage: local-ai abtest --b=B.env [--a=A.env] (--questions=FILE | --from-prompts=N)")
+		fmt.Println("                       [--answer=none|mock|llm] [--out=PATH] [--json]")
+		fmt.Println("A/B env files hold TIMELAYER_*=value lines applied on top of the current environment.")
+		return 2
+	}
+
+	cfgA, err := abVariantConfig(cfg, envA)
+	if err != nil {
+		fmt.Println("[error] --a:", err)
+		return 1
+	}
+	cfgB, err := abVariantConfig(cfg, envB)
+	if err != nil {
+		fmt.Println("[error] --b:", err)
+		return 1
+	}
+
+	db := mustOpenDB(cfg)
+	defer closeDB(db)
+
+	var qs []ABQuestion
+	if qfile != "" {
+		qs, err = loadABQuestions(qfile)
+	} else {
+		qs, err = recentABQuestions(db, fromPrompts)
+	}
+	if err != nil {
+		fmt.Println("[error] questions:", err)
+		return 1
+	}
+
+	nameA := "current"
+	if envA != "" {
+		nameA = filepath.Base(envA)
+	}
+	rep := RunContextAB(cfgA, cfgB, nameA, filepath.Base(envB), db, qs, answer)
+
+	var body []byte
+	ext := ".md"
+	if asJSON {
+		body, _ = json.MarshalIndent(rep, "", "  ")
+		ext = ".json"
+	} else {
+		body = []byte(renderABReportMarkdown(rep))
+	}
+	if out == "" {
+		dir := filepath.Join(cfg.BaseDir, "exports")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Println("[error]", err)
+			return 1
+		}
+		out = filepath.Join(dir, "abtest-"+time.Now().In(cfg.Location).Format("20060102-150405")+ext)
+	}
+	if err := os.WriteFile(out, body, 0644); err != nil {
+		fmt.Println("[error]", err)
+		return 1
+	}
+	fmt.Printf("[ok] %d questions, context changed: %d, answer changed: %d → %s\n", rep.Questions, rep.ContextChanged, rep.AnswerChanged, out)
+	return 0
+}
@@ -25,6 +25,10 @@ CREATE TABLE IF NOT EXISTS summaries (
   json TEXT NOT NULL,
   text TEXT NOT NULL,
   source_path TEXT,
+  source_hash TEXT,
+  title TEXT,
+  deleted_at TEXT,
+  user_id TEXT NOT NULL DEFAULT '',          -- users.go（"" = 主用户）
   created_at TEXT NOT NULL,
   UNIQUE(type, period_key)
 );
@@ -61,6 +65,19 @@ CREATE TABLE IF NOT EXISTS summary_embeddings_history (
 CREATE INDEX IF NOT EXISTS idx_seh_summary_id_created
   ON summary_embeddings_history(summary_id, created_at);
 
+/*
+================================================
+Embedding 模型（向量来自哪个模型 / 维度，见 embedding_model.go）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS embedding_model (
+  id INTEGER PRIMARY KEY CHECK (id = 1),
+  model TEXT NOT NULL DEFAULT '',
+  dim INTEGER NOT NULL,
+  embed_url TEXT NOT NULL DEFAULT '',
+  updated_at TEXT NOT NULL
+);
+
 /*
 ================================================
 显式长期事实（/remember）
@@ -71,6 +88,13 @@ CREATE TABLE IF NOT EXISTS user_facts (
   fact TEXT NOT NULL,
   fact_key TEXT NOT NULL,
   is_active INTEGER NOT NULL DEFAULT 1,
+  deleted_at TEXT,
+  inject_count INTEGER NOT NULL DEFAULT 0,   -- 注入 chat 上下文次数（fact_usage.go）
+  slot_hit_count INTEGER NOT NULL DEFAULT 0, -- 被 slot / value set 查找命中次数
+  last_used_at TEXT,
+  is_core INTEGER NOT NULL DEFAULT 0,        -- 身份级核心事实：始终注入（fact_core.go）
+  category TEXT NOT NULL DEFAULT '',         -- identity / family / work / ...（fact_category.go）
+  user_id TEXT NOT NULL DEFAULT '',          -- 所属用户，由 fact_key 派生（users.go）
   created_at TEXT NOT NULL,
   updated_at TEXT NOT NULL,
   UNIQUE(fact_key)
@@ -84,6 +108,8 @@ CREATE INDEX IF NOT EXISTS idx_user_facts_active
 事实候选池（pending_facts）
 - 来源：daily summary 的 user_facts_explicit（高置信）
 - UI 可一键晋升为 user_facts（/remember 的同源写入）
+- sources：同内容的全部来源（JSON ["daily:2026-10-14","realtime_implicit:…"]，跨来源去重合并）
+- evidence：来源对话片段（JSON，如 correction 的 assistant/user 原话）
 ================================================
 */
 CREATE TABLE IF NOT EXISTS pending_facts (
@@ -94,6 +120,10 @@ CREATE TABLE IF NOT EXISTS pending_facts (
   source_type TEXT NOT NULL,
   source_key TEXT NOT NULL,
   status TEXT NOT NULL DEFAULT 'pending',
+  sources TEXT,
+  evidence TEXT,
+  deleted_at TEXT,
+  user_id TEXT NOT NULL DEFAULT '',
   created_at TEXT NOT NULL,
   updated_at TEXT NOT NULL,
   UNIQUE(fact_key, status, source_type, source_key)
@@ -163,6 +193,348 @@ CREATE TABLE IF NOT EXISTS user_fact_conflicts (
 CREATE INDEX IF NOT EXISTS idx_ufc_status_created
   ON user_fact_conflicts(status, created_at);
 
+/*
+================================================
+user_facts tags（用户自定义分类：work / family / health ...）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS user_fact_tags (
+  fact_key TEXT NOT NULL,
+  tag TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY(fact_key, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_uft_tag
+  ON user_fact_tags(tag);
+
+/*
+================================================
+主体别名（"我本人" → "我"，"我老婆" → "老婆"；fact_subject_alias.go）
+- alias / canonical 均为 normalizeFactKey 后的形式，无链（已展开）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS subject_aliases (
+  alias TEXT PRIMARY KEY,
+  canonical TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+/*
+================================================
+事实禁存规则（fact_policy.go）
+- kind: keyword | regex | relation；命中的候选/记住请求直接丢弃
+- 属于设置，wipe 不清空
+================================================
+*/
+CREATE TABLE IF NOT EXISTS fact_deny_rules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  kind TEXT NOT NULL,
+  pattern TEXT NOT NULL,
+  note TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  UNIQUE(kind, pattern)
+);
+
+/*
+================================================
+HTTP 限流状态（重启后恢复令牌桶 / 封禁；http_rate_limit_store.go）
+- 只保存未满的桶和仍在封禁中的 IP；banned_until 为空 = 未封禁
+================================================
+*/
+CREATE TABLE IF NOT EXISTS http_rate_limits (
+  ip TEXT PRIMARY KEY,
+  tokens REAL NOT NULL,
+  last_at TEXT NOT NULL,
+  strikes INTEGER NOT NULL DEFAULT 0,
+  banned_until TEXT NOT NULL DEFAULT '',
+  updated_at TEXT NOT NULL
+);
+
+/*
+================================================
+fact_key 迁移记录（deriveFactKeyFromSubject 变更后的重算，fact_key_migrate.go）
+- action：rekeyed | merged | conflict | archived
+================================================
+*/
+CREATE TABLE IF NOT EXISTS fact_key_migrations (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_fact_id INTEGER NOT NULL,
+  old_key TEXT NOT NULL,
+  new_key TEXT NOT NULL,
+  fact TEXT NOT NULL,
+  action TEXT NOT NULL,
+  merged_into INTEGER,
+  conflict_id INTEGER,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_fkm_old_key
+  ON fact_key_migrations(old_key);
+
+/*
+================================================
+summaries tags（scope / workspace 检索过滤）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS summary_tags (
+  summary_id INTEGER NOT NULL,
+  tag TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY(summary_id, tag),
+  FOREIGN KEY(summary_id)
+    REFERENCES summaries(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_st_tag
+  ON summary_tags(tag);
+
+/*
+================================================
+messages（原始对话，TIMELAYER_LOG_STORAGE=sqlite|both 时写入）
+- seq: 当天内的顺序号（与 JSONL 行号对齐，导入幂等）
+- record: 与 JSONL 行完全一致的原始 JSON
+================================================
+*/
+CREATE TABLE IF NOT EXISTS messages (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  day TEXT NOT NULL,
+  seq INTEGER NOT NULL,
+  role TEXT NOT NULL,
+  kind TEXT,
+  content TEXT NOT NULL,
+  record TEXT NOT NULL,
+  source TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  UNIQUE(day, seq)
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_role_day
+  ON messages(role, day);
+
+/*
+================================================
+ops_log（内部事件：与对话分离，永不进入 summary / context）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS ops_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  day TEXT NOT NULL,
+  op_type TEXT NOT NULL,
+  payload TEXT,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ops_log_day_type
+  ON ops_log(day, op_type);
+
+/*
+================================================
+prompts_log（每轮实际发送的 prompt；全文可选）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS prompts_log (
+  turn_id TEXT PRIMARY KEY,
+  day TEXT NOT NULL,
+  model TEXT,
+  prompt_hash TEXT NOT NULL,
+  system TEXT,
+  context_json TEXT,
+  user_input TEXT,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_prompts_log_day
+  ON prompts_log(day);
+
+/*
+================================================
+archive_manifest（归档出 LogDir 的原始日志，每天一条；见 archive.go）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS archive_manifest (
+  day TEXT PRIMARY KEY,               -- YYYY-MM-DD
+  backend TEXT NOT NULL,              -- local | s3 | webdav
+  object_key TEXT NOT NULL,           -- YYYY-MM/YYYY-MM-DD.jsonl.gz
+  bytes INTEGER NOT NULL,             -- 原始 JSONL 大小
+  stored_bytes INTEGER NOT NULL,      -- gzip 后大小
+  lines INTEGER NOT NULL,
+  sha256 TEXT NOT NULL,               -- 原始 JSONL 的 sha256（恢复时校验）
+  archived_at TEXT NOT NULL,
+  restored_at TEXT NOT NULL DEFAULT ''
+);
+
+/*
+================================================
+context_audits（每轮注入块的分数/排名/门控/截断；可选）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS context_audits (
+  turn_id TEXT PRIMARY KEY,
+  day TEXT NOT NULL,
+  question TEXT,
+  blocks_json TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_context_audits_day
+  ON context_audits(day);
+
+/*
+================================================
+turn_feedback（每轮 👍/👎，调参分析 tune suggest 用）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS turn_feedback (
+  turn_id TEXT PRIMARY KEY,
+  rating INTEGER NOT NULL,            -- 1 = up, -1 = down
+  note TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+/*
+================================================
+Background LLM budget + jobs
+================================================
+*/
+CREATE TABLE IF NOT EXISTS llm_budget (
+  day TEXT PRIMARY KEY,
+  calls INTEGER NOT NULL DEFAULT 0,
+  est_tokens INTEGER NOT NULL DEFAULT 0,
+  updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS bg_jobs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  kind TEXT NOT NULL,
+  period_key TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  UNIQUE(kind, period_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bg_jobs_status
+  ON bg_jobs(status, id);
+
+/*
+================================================
+Summary 用户批注（按 type + period_key，summary 重算后仍保留）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS summary_annotations (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  type TEXT NOT NULL,
+  period_key TEXT NOT NULL,
+  note TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_summary_annotations_key
+  ON summary_annotations(type, period_key);
+
+/*
+================================================
+embedding 重试（自动补齐，指数退避）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS embedding_retry (
+  summary_id INTEGER PRIMARY KEY,
+  attempts INTEGER NOT NULL,
+  next_at TEXT NOT NULL,
+  last_error TEXT,
+  updated_at TEXT NOT NULL,
+  FOREIGN KEY(summary_id)
+    REFERENCES summaries(id)
+    ON DELETE CASCADE
+);
+
+/*
+================================================
+摘要 guard 告警（只记录，供每月 hygiene 报告统计）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS summary_warnings (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  summary_type TEXT NOT NULL,
+  period_key TEXT NOT NULL,
+  level TEXT NOT NULL,
+  warning_type TEXT NOT NULL,
+  message TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_summary_warnings_created
+  ON summary_warnings(created_at);
+
+/*
+================================================
+邮件导入进度（每个文件夹：UIDVALIDITY + 已处理的最大 UID）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS email_ingest_state (
+  folder TEXT PRIMARY KEY,
+  uid_validity INTEGER NOT NULL,
+  last_uid INTEGER NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+/*
+================================================
+助手档案（persona + 记忆视图，profile 为 JSON）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS assistants (
+  name TEXT PRIMARY KEY,
+  profile TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+/*
+================================================
+glossary（用户维护的术语表；问题里出现术语时才注入，见 glossary.go）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS glossary (
+  term TEXT PRIMARY KEY COLLATE NOCASE,
+  definition TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+/*
+================================================
+会话（同一天内的多个独立对话；只影响 recent_raw，见 sessions.go）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS sessions (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL DEFAULT '',
+  title TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id, updated_at);
+
+/*
+================================================
+回答风格默认值（/style，按 profile）
+================================================
+*/
+CREATE TABLE IF NOT EXISTS answer_style_profiles (
+  profile TEXT PRIMARY KEY,
+  style TEXT NOT NULL DEFAULT '',
+  max_sentences INTEGER NOT NULL DEFAULT 0,
+  updated_at TEXT NOT NULL
+);
+
 `
 
 func mustOpenDB(cfg Config) *sql.DB {
@@ -205,6 +577,20 @@ func mustOpenDB(cfg Config) *sql.DB {
 	// ✅ Backward-compatible migrations for older DBs.
 	// (CREATE TABLE IF NOT EXISTS does not update existing tables.)
 	_ = ensurePendingFactsSchema(db, cfg)
+	_ = ensureSummariesSchema(db)
+	_ = ensureTrashSchema(db)
+	_ = ensureFactUsageSchema(db)
+	_ = ensureFactCategorySchema(db)
+	_ = ensureUsersSchema(db)
+	_ = ensureSearchFTSSchema(db)
+
+	// fact keys written by older deriveFactKeyFromSubject versions (or before an alias change)
+	_ = loadSubjectAliases(db)
+	_ = loadFactDenyRules(db)
+	migrateUserFactKeys(db, cfg)
+
+	// read-only pool for long reads, after the schema exists (see db_readers.go)
+	openReaderPool(cfg, db)
 
 	return db
 }
@@ -262,6 +648,8 @@ func ensurePendingFactsSchema(db *sql.DB, cfg Config) error {
 	add("confidence", "ALTER TABLE pending_facts ADD COLUMN confidence REAL DEFAULT 0", "")
 	add("created_at", "ALTER TABLE pending_facts ADD COLUMN created_at TEXT DEFAULT ''", "UPDATE pending_facts SET created_at=? WHERE created_at IS NULL OR created_at=''", nowS)
 	add("updated_at", "ALTER TABLE pending_facts ADD COLUMN updated_at TEXT DEFAULT ''", "UPDATE pending_facts SET updated_at=? WHERE updated_at IS NULL OR updated_at=''", nowS)
+	add("evidence", "ALTER TABLE pending_facts ADD COLUMN evidence TEXT", "")
+	add("sources", "ALTER TABLE pending_facts ADD COLUMN sources TEXT", "")
 
 	// Helpful indexes (best-effort)
 	_, _ = db.Exec("CREATE INDEX IF NOT EXISTS idx_pending_facts_status ON pending_facts(status)")
@@ -272,9 +660,70 @@ func ensurePendingFactsSchema(db *sql.DB, cfg Config) error {
 	// If legacy rows contain duplicates, this may fail; ignore in that case.
 	_, _ = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS ux_pending_facts_key ON pending_facts(fact_key, status, source_type, source_key)")
 
+	// Same content proposed by several sources used to be several rows.
+	mergeDuplicatePendingFacts(db)
+
 	return nil
 }
 
+// ensureSummariesSchema adds newer summaries columns for older DBs (best-effort).
+func ensureSummariesSchema(db *sql.DB) error {
+	if db == nil {
+		return nil
+	}
+	for _, col := range []string{"source_hash", "title"} {
+		if err := addColumnIfMissing(db, "summaries", col, "TEXT"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureTrashSchema adds deleted_at (soft delete, see trash.go) for older DBs (best-effort).
+func ensureTrashSchema(db *sql.DB) error {
+	if db == nil {
+		return nil
+	}
+	for _, table := range []string{"summaries", "user_facts", "pending_facts"} {
+		if err := addColumnIfMissing(db, table, "deleted_at", "TEXT"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureFactUsageSchema adds the user_facts usage counters and is_core for older DBs (best-effort).
+func ensureFactUsageSchema(db *sql.DB) error {
+	if db == nil {
+		return nil
+	}
+	for _, c := range [][2]string{
+		{"inject_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"slot_hit_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"last_used_at", "TEXT"},
+		{"is_core", "INTEGER NOT NULL DEFAULT 0"},
+	} {
+		if err := addColumnIfMissing(db, "user_facts", c[0], c[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing runs ALTER TABLE ... ADD COLUMN unless the column exists.
+// table / col / typ are trusted identifiers (never user input).
+func addColumnIfMissing(db *sql.DB, table, col, typ string) error {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?`, table, col).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + col + ` ` + typ)
+	return err
+}
+
 // =========================
 // summaries helpers
 // =========================
@@ -303,17 +752,18 @@ func upsertSummary(
 	_, err := db.Exec(`
 		INSERT INTO summaries(
 		  type, period_key, start_date, end_date,
-		  json, text, source_path, created_at
+		  json, text, source_path, user_id, created_at
 		)
-		VALUES(?,?,?,?,?,?,?,?)
+		VALUES(?,?,?,?,?,?,?,?,?)
 		ON CONFLICT(type, period_key) DO UPDATE SET
 		  json=excluded.json,
 		  text=excluded.text,
 		  source_path=excluded.source_path
-	`, typ, key, startDate, endDate, js, text, srcPath, now)
+	`, typ, key, startDate, endDate, js, text, srcPath, keyUser(key), now)
 	if err != nil {
 		return 0, err
 	}
+	bumpMemoryVersion()
 
 	row := db.QueryRow(
 		`SELECT id FROM summaries WHERE type=? AND period_key=?`,
@@ -324,6 +774,7 @@ func upsertSummary(
 	if err := row.Scan(&id); err != nil {
 		return 0, err
 	}
+	indexSearchFTS(db, id, text)
 	return id, nil
 }
 
@@ -347,9 +798,22 @@ func deleteEmbedding(db *sql.DB, summaryID int64) error {
 		`DELETE FROM embeddings WHERE summary_id=?`,
 		summaryID,
 	)
+	if err == nil {
+		vectorIndexDrop(summaryID)
+	}
 	return err
 }
 
+// dropSummaryEmbedding deletes the vector of a summary whose text changed
+// under the same summary id (the old vector is wrong); the caller re-embeds.
+func dropSummaryEmbedding(db *sql.DB, typ, key string) {
+	var id int64
+	if db.QueryRow(`SELECT id FROM summaries WHERE type=? AND period_key=?`, typ, key).Scan(&id) != nil {
+		return
+	}
+	_ = deleteEmbedding(db, id)
+}
+
 // =========================
 // user_facts helpers
 // =========================
@@ -374,22 +838,32 @@ func upsertUserFact(
 
 	ts := now.Format(time.RFC3339)
 
+	// deactivated facts go to the trash (restorable until purged, see trash.go)
+	var deletedAt any
+	if !active {
+		deletedAt = ts
+	}
+
 	_, err := db.Exec(`
 		INSERT INTO user_facts(
-		  fact, fact_key, is_active, created_at, updated_at
+		  fact, fact_key, is_active, deleted_at, user_id, category, created_at, updated_at
 		)
-		VALUES(?,?,?,?,?)
+		VALUES(?,?,?,?,?,?,?,?)
 		ON CONFLICT(fact_key) DO UPDATE SET
 		  fact=excluded.fact,
 		  is_active=excluded.is_active,
+		  deleted_at=excluded.deleted_at,
+		  category=CASE WHEN user_facts.category='' THEN excluded.category ELSE user_facts.category END,
 		  updated_at=excluded.updated_at
-	`, fact, factKey, activeInt, ts, ts)
-
+	`, fact, factKey, activeInt, deletedAt, keyUser(factKey), deriveFactCategory(fact), ts, ts)
+	if err == nil {
+		bumpMemoryVersion()
+	}
 	return err
 }
 
-// loadActiveUserFacts 读取当前有效的显式事实（按最近更新时间排序）
-func loadActiveUserFacts(db *sql.DB, limit int) ([]string, error) {
+// loadActiveUserFacts 读取 user 当前有效的显式事实（按最近更新时间排序）
+func loadActiveUserFacts(db *sql.DB, user string, limit int) ([]string, error) {
 	if db == nil {
 		return nil, nil
 	}
@@ -397,13 +871,13 @@ func loadActiveUserFacts(db *sql.DB, limit int) ([]string, error) {
 		limit = 50
 	}
 
-	rows, err := db.Query(`
+	rows, err := stmtQuery(db, `
 		SELECT fact
 		FROM user_facts
-		WHERE is_active=1
+		WHERE is_active=1 AND user_id=?
 		ORDER BY updated_at DESC
 		LIMIT ?
-	`, limit)
+	`, user, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ============================================================
+// SQLite reader pool
+// - The main *sql.DB is the single writer (SQLiteMaxOpenConns, default 1),
+//   so a long read (history list, search scan, export) used to hold the only
+//   connection and every write behind it waited / hit busy retries.
+// - With WAL, readers never block the writer: mustOpenDB opens a second,
+//   read-only pool (mode=ro, query_only) of TIMELAYER_SQLITE_READ_CONNS
+//   connections next to it.
+// - readDB(db) returns that pool, or db itself when it is off (0 conns, not
+//   WAL, in-memory DB or the open failed). Only plain reads outside a tx use
+//   it; anything that writes or must see its own uncommitted rows stays on db.
+// ============================================================
+
+const defaultSQLiteReadConns = 4
+
+// readerPools maps a writer *sql.DB to its read-only pool.
+var readerPools sync.Map
+
+// openReaderPool opens and registers the read-only pool for db (best-effort).
+func openReaderPool(cfg Config, db *sql.DB) {
+	n := cfg.SQLiteReadConns
+	if n <= 0 || db == nil {
+		return
+	}
+	if !strings.EqualFold(strings.TrimSpace(cfg.SQLiteJournalMode), "WAL") {
+		return
+	}
+	if cfg.DBPath == "" || cfg.DBPath == ":memory:" || strings.HasPrefix(cfg.DBPath, "file:") {
+		return
+	}
+
+	q := url.Values{}
+	q.Set("mode", "ro")
+	q.Add("_pragma", "query_only(1)")
+	if cfg.SQLiteBusyTimeoutMS > 0 {
+		q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.SQLiteBusyTimeoutMS))
+	}
+	dsn := (&url.URL{Scheme: "file", Path: filepath.ToSlash(cfg.DBPath), RawQuery: q.Encode()}).String()
+
+	rdb, err := sql.Open("sqlite", dsn)
+	if err == nil {
+		err = rdb.Ping()
+	}
+	if err != nil {
+		log.Printf("[warn] sqlite reader pool disabled: %v", err)
+		if rdb != nil {
+			_ = rdb.Close()
+		}
+		return
+	}
+	rdb.SetMaxOpenConns(n)
+	rdb.SetMaxIdleConns(n)
+	rdb.SetConnMaxLifetime(0)
+	readerPools.Store(db, rdb)
+}
+
+// readDB returns the read-only pool of db, or db itself.
+func readDB(db *sql.DB) *sql.DB {
+	if db == nil {
+		return nil
+	}
+	if r, ok := readerPools.Load(db); ok {
+		return r.(*sql.DB)
+	}
+	return db
+}
+
+// closeDB closes db, its cached statements and its reader pool.
+func closeDB(db *sql.DB) error {
+	if db == nil {
+		return nil
+	}
+	closeStmts(db)
+	if r, ok := readerPools.LoadAndDelete(db); ok {
+		_ = r.(*sql.DB).Close()
+	}
+	return db.Close()
+}
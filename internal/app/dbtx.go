@@ -25,6 +25,8 @@ func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
 	if err != nil {
 		return err
 	}
+	txOwners.Store(tx, db) // lets cached statements bind to tx (stmt_cache.go)
+	defer txOwners.Delete(tx)
 	defer func() { _ = tx.Rollback() }()
 
 	if err := fn(tx); err != nil {
@@ -0,0 +1,410 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Email ingestion (optional, TIMELAYER_IMAP_ADDR)
+// - Polls the configured folders read-only (see imap_client.go).
+// - Each new email → one summary of type "email" (document memory),
+//   tagged "email", with from/subject/date in its JSON; embedded for search.
+// - Explicit, high-confidence facts (flight / booking confirmations, ...)
+//   go to the pending pool (source_type "email") for review, never straight
+//   into user_facts.
+// - Progress is kept per folder (UIDVALIDITY + last UID) in email_ingest_state.
+// ============================================================
+
+const (
+	summaryTypeEmail = "email"
+
+	emailFetchMaxBytes = 256 * 1024
+	emailBodyMaxRunes  = 6000
+	imapTimeout        = 60 * time.Second
+)
+
+// imapEnabled reports whether email ingestion is configured.
+func imapEnabled(cfg Config) bool {
+	return cfg.IMAPAddr != "" && cfg.IMAPUser != ""
+}
+
+type emailMessage struct {
+	MessageID string
+	From      string
+	Subject   string
+	Date      time.Time
+	Body      string
+}
+
+type EmailPollReport struct {
+	Folders  int `json:"folders"`
+	Fetched  int `json:"fetched"`
+	Ingested int `json:"ingested"`
+	Skipped  int `json:"skipped"`
+	Pending  int `json:"pending"`
+}
+
+var emailPollMu sync.Mutex
+
+// runEmailPoller polls every cfg.IMAPPollInterval until ctx is cancelled.
+func runEmailPoller(ctx context.Context, cfg Config, db *sql.DB) {
+	if db == nil || !imapEnabled(cfg) {
+		return
+	}
+	for {
+		rep, err := PollEmailOnce(cfg, db)
+		if err != nil {
+			log.Printf("[warn] email poll failed: %v", err)
+		} else if rep.Ingested > 0 {
+			log.Printf("[info] email poll: ingested %d email(s), %d pending fact(s)", rep.Ingested, rep.Pending)
+		}
+		if !sleepCtx(ctx, cfg.IMAPPollInterval) {
+			return
+		}
+	}
+}
+
+// PollEmailOnce fetches and ingests new mail from every configured folder.
+func PollEmailOnce(cfg Config, db *sql.DB) (EmailPollReport, error) {
+	var rep EmailPollReport
+	if !imapEnabled(cfg) {
+		return rep, fmt.Errorf("email ingestion disabled (set TIMELAYER_IMAP_ADDR / TIMELAYER_IMAP_USER)")
+	}
+	emailPollMu.Lock()
+	defer emailPollMu.Unlock()
+
+	c, err := dialIMAP(cfg.IMAPAddr, cfg.IMAPTLS, imapTimeout)
+	if err != nil {
+		return rep, err
+	}
+	defer c.Close()
+	if err := c.Login(cfg.IMAPUser, cfg.IMAPPassword); err != nil {
+		return rep, err
+	}
+	defer c.Logout()
+
+	budget := cfg.IMAPMaxPerPoll
+	for _, folder := range cfg.IMAPFolders {
+		if budget <= 0 {
+			break
+		}
+		n, err := pollEmailFolder(cfg, db, c, folder, budget, &rep)
+		budget -= n
+		rep.Folders++
+		if err != nil {
+			return rep, fmt.Errorf("folder %s: %w", folder, err)
+		}
+	}
+	return rep, nil
+}
+
+// pollEmailFolder ingests up to limit new messages of one folder; returns how many were fetched.
+func pollEmailFolder(cfg Config, db *sql.DB, c *imapClient, folder string, limit int, rep *EmailPollReport) (int, error) {
+	validity, err := c.Examine(folder)
+	if err != nil {
+		return 0, err
+	}
+	var lastValidity, lastUID uint32
+	_ = db.QueryRow(`SELECT uid_validity, last_uid FROM email_ingest_state WHERE folder=?`, folder).
+		Scan(&lastValidity, &lastUID)
+	if lastValidity != validity {
+		// mailbox was recreated: UIDs are meaningless now (duplicates are caught by message key)
+		lastUID = 0
+	}
+
+	var criteria string
+	if lastUID == 0 {
+		// first run: only recent mail, not the whole mailbox history
+		since := time.Now().In(cfg.Location).AddDate(0, 0, -cfg.IMAPSinceDays)
+		criteria = "SINCE " + since.Format("02-Jan-2006")
+	} else {
+		criteria = fmt.Sprintf("UID %d:*", lastUID+1)
+	}
+	uids, err := c.UIDSearch(criteria)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	fetched := 0
+	for _, uid := range uids {
+		if uid <= lastUID {
+			continue // "n:*" always returns the highest UID
+		}
+		if fetched >= limit {
+			break
+		}
+		raw, err := c.FetchRaw(uid, emailFetchMaxBytes)
+		if err != nil {
+			return fetched, err
+		}
+		fetched++
+		rep.Fetched++
+
+		msg, err := parseEmailMessage(raw)
+		if err != nil {
+			log.Printf("[warn] email %s/%d: %v", folder, uid, err)
+			rep.Skipped++
+		} else {
+			key := emailSummaryKey(msg, folder, validity, uid)
+			pending, err := ingestEmail(cfg, db, folder, key, msg)
+			if err != nil {
+				// LLM down / budget exhausted: stop here, retry this UID next poll
+				return fetched, err
+			}
+			if pending < 0 {
+				rep.Skipped++
+			} else {
+				rep.Ingested++
+				rep.Pending += pending
+			}
+		}
+
+		lastUID = uid
+		if _, err := db.Exec(`
+			INSERT INTO email_ingest_state(folder, uid_validity, last_uid, updated_at)
+			VALUES(?,?,?,?)
+			ON CONFLICT(folder) DO UPDATE SET
+			  uid_validity=excluded.uid_validity,
+			  last_uid=excluded.last_uid,
+			  updated_at=excluded.updated_at
+		`, folder, validity, lastUID, time.Now().In(cfg.Location).Format(time.RFC3339)); err != nil {
+			return fetched, err
+		}
+	}
+	return fetched, nil
+}
+
+// emailSummaryKey is stable across polls: Message-ID when present, else folder/validity/uid.
+func emailSummaryKey(msg emailMessage, folder string, validity, uid uint32) string {
+	id := msg.MessageID
+	if id == "" {
+		id = fmt.Sprintf("%s/%d/%d", folder, validity, uid)
+	}
+	return sha256Hex(id)[:16]
+}
+
+// ingestEmail summarizes one email into an "email" summary and proposes pending facts.
+// Returns the number of pending facts added, or -1 when the email was skipped
+// (already ingested, or the model gave no usable summary).
+func ingestEmail(cfg Config, db *sql.DB, folder, key string, msg emailMessage) (int, error) {
+	if ok, _ := summaryExists(db, summaryTypeEmail, key); ok {
+		return -1, nil
+	}
+
+	out, err := callBackgroundLLM(cfg, db, buildEmailPrompt(cfg, msg))
+	if err != nil {
+		return 0, err
+	}
+	var res struct {
+		Summary string `json:"summary"`
+		Facts   []struct {
+			Fact       string  `json:"fact"`
+			Confidence float64 `json:"confidence"`
+		} `json:"facts"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil || strings.TrimSpace(res.Summary) == "" {
+		// a bad answer for one email must not block the folder forever
+		log.Printf("[warn] email %s: summary output is not valid JSON, skipped", key)
+		return -1, nil
+	}
+
+	date := msg.Date.In(cfg.Location).Format("2006-01-02")
+	js, err := json.MarshalIndent(map[string]any{
+		"type":       summaryTypeEmail,
+		"date":       date,
+		"from":       msg.From,
+		"subject":    msg.Subject,
+		"sent_at":    msg.Date.In(cfg.Location).Format(time.RFC3339),
+		"folder":     folder,
+		"message_id": msg.MessageID,
+		"summary":    strings.TrimSpace(res.Summary),
+	}, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	indexText := strings.TrimSpace(msg.Subject + "\n" + res.Summary)
+	if _, err := upsertSummary(db, cfg, summaryTypeEmail, key, date, date, string(js), indexText, ""); err != nil {
+		return 0, err
+	}
+	if _, err := SetSummaryTags(cfg, db, summaryTypeEmail, key, []string{"email"}, "set"); err != nil {
+		log.Printf("[warn] email %s: tag failed: %v", key, err)
+	}
+	if err := ensureEmbedding(db, cfg, indexText, summaryTypeEmail, key); err != nil {
+		log.Printf("[warn] ensureEmbedding failed for email %s: %v", key, err)
+	}
+
+	pending := 0
+	for _, f := range res.Facts {
+		if f.Confidence < pendingMinConfidence(cfg) || strings.TrimSpace(f.Fact) == "" {
+			continue
+		}
+		if err := addPendingFact(cfg, db, f.Fact, f.Confidence, "email", key); err != nil {
+			log.Printf("[warn] email %s: pending fact failed: %v", key, err)
+			continue
+		}
+		pending++
+	}
+	return pending, nil
+}
+
+func buildEmailPrompt(cfg Config, msg emailMessage) string {
+	body := msg.Body
+	if r := []rune(body); len(r) > emailBodyMaxRunes {
+		body = string(r[:emailBodyMaxRunes]) + "…"
+	}
+	var b strings.Builder
+	b.WriteString("You summarize one email from the user's mailbox. Output JSON only.\n\n")
+	b.WriteString("RULES:\n")
+	b.WriteString("- summary: 1-3 sentences on what the email is about and any concrete dates, amounts, bookings or actions.\n")
+	b.WriteString("- facts: ONLY explicit, concrete facts about the user stated in the email (e.g. a confirmed flight, hotel booking, appointment, order).\n")
+	b.WriteString("  Write each fact as a first-person statement of the user. Do NOT include marketing, newsletters, guesses or inferred preferences.\n")
+	b.WriteString("- confidence: 0..1; use >= 0.9 only for confirmations with exact details. If there are no such facts, output an empty list.\n")
+	b.WriteString(outputLanguageRule(cfg))
+	b.WriteString("\nOUTPUT FORMAT:\n{\"summary\": \"...\", \"facts\": [{\"fact\": \"...\", \"confidence\": 0.9}]}\n\n")
+	b.WriteString("EMAIL:\n")
+	fmt.Fprintf(&b, "From: %s\nDate: %s\nSubject: %s\n\n%s\n",
+		msg.From, msg.Date.Format(time.RFC1123Z), msg.Subject, body)
+	return b.String()
+}
+
+// ---------- MIME parsing ----------
+
+var mimeDecoder = &mime.WordDecoder{}
+
+func decodeHeader(s string) string {
+	if d, err := mimeDecoder.DecodeHeader(s); err == nil {
+		return strings.TrimSpace(d)
+	}
+	return strings.TrimSpace(s)
+}
+
+// parseEmailMessage extracts headers and a plain-text body from a raw RFC 822 message.
+func parseEmailMessage(raw []byte) (emailMessage, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return emailMessage{}, err
+	}
+	msg := emailMessage{
+		MessageID: strings.Trim(strings.TrimSpace(m.Header.Get("Message-Id")), "<>"),
+		From:      decodeHeader(m.Header.Get("From")),
+		Subject:   decodeHeader(m.Header.Get("Subject")),
+	}
+	if t, err := m.Header.Date(); err == nil {
+		msg.Date = t
+	} else {
+		msg.Date = time.Now()
+	}
+	msg.Body = strings.TrimSpace(emailPartText(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body, 0))
+	if msg.Body == "" && msg.Subject == "" {
+		return msg, fmt.Errorf("empty message")
+	}
+	return msg, nil
+}
+
+// emailPartText returns the text of a MIME part, preferring text/plain over text/html.
+func emailPartText(contentType, encoding string, body io.Reader, depth int) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") && depth < 5 {
+		mr := multipart.NewReader(body, params["boundary"])
+		var plain, html string
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			t := emailPartText(p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p, depth+1)
+			pt, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+			switch {
+			case pt == "text/html" && html == "":
+				html = t
+			case plain == "" && t != "":
+				plain = t
+			}
+		}
+		if plain != "" {
+			return plain
+		}
+		return html
+	}
+	if !strings.HasPrefix(mediaType, "text/") {
+		return ""
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: body})
+	}
+	b, _ := io.ReadAll(io.LimitReader(body, emailFetchMaxBytes))
+	s := sanitizeUTF8(string(b))
+	if mediaType == "text/html" {
+		s = stripHTML(s)
+	}
+	return s
+}
+
+// newlineStripper drops CR/LF so base64 bodies wrapped at 76 columns decode.
+type newlineStripper struct{ r io.Reader }
+
+func (n *newlineStripper) Read(p []byte) (int, error) {
+	for {
+		k, err := n.r.Read(p)
+		j := 0
+		for _, c := range p[:k] {
+			if c != '\r' && c != '\n' {
+				p[j] = c
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+var (
+	htmlDropRe  = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlBreakRe = regexp.MustCompile(`(?i)<(br|/p|/div|/tr|/li|/h[1-6])[^>]*>`)
+	htmlTagRe   = regexp.MustCompile(`<[^>]+>`)
+	blankRunRe  = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
+)
+
+// stripHTML is a rough HTML → text conversion, good enough for summarization.
+func stripHTML(s string) string {
+	s = htmlDropRe.ReplaceAllString(s, "")
+	s = htmlBreakRe.ReplaceAllString(s, "\n")
+	s = htmlTagRe.ReplaceAllString(s, "")
+	s = strings.NewReplacer("&nbsp;", " ", "&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'").Replace(s)
+	return blankRunRe.ReplaceAllString(s, "\n\n")
+}
+
+// runEmailPollCommand implements /email_poll (one poll now).
+func runEmailPollCommand(cfg Config, db *sql.DB) (string, error) {
+	rep, err := PollEmailOnce(cfg, db)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[ok] email poll: folders=%d fetched=%d ingested=%d skipped=%d pending=%d",
+		rep.Folders, rep.Fetched, rep.Ingested, rep.Skipped, rep.Pending), nil
+}
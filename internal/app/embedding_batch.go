@@ -0,0 +1,213 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ============================================================
+// Batch embedding
+// - Bulk paths (Reindex, /reindex --model-migrate, pending fact clustering)
+//   send TIMELAYER_EMBED_BATCH_SIZE texts (default 16) per request as an
+//   array input, with TIMELAYER_EMBED_PARALLEL requests (default 4) in
+//   flight. llama-server and OpenAI-style servers answer with one vector
+//   per input; the known response shapes are decoded by decodeEmbeddingBatch.
+// - A server that rejects array input (4xx) or answers with the wrong
+//   number of vectors is remembered per embed URL: that batch, and every
+//   later one, is sent one text per request instead.
+// - Single-text callers (chat, search, summaries) still use embedQueryText.
+// ============================================================
+
+const (
+	defaultEmbedBatchSize = 16
+	defaultEmbedParallel  = 4
+)
+
+var errEmbedBatchUnsupported = errors.New("embed server does not support batch input")
+
+// embedBatchUnsupported holds the embed URLs that failed a batch request.
+var embedBatchUnsupported sync.Map
+
+// decodeEmbeddingBatch decodes n vectors (in input order) from a batch response:
+// 1) {"data":[{"index":0,"embedding":[...]}, ...]}        OpenAI
+// 2) [{"index":0,"embedding":[[...]]}, ...]               llama-server /embedding
+// 3) [{"index":0,"embedding":[...]}, ...]
+// 4) {"embedding":[[...], ...]} / [[...], ...]
+func decodeEmbeddingBatch(raw []byte, n int) ([][]float32, error) {
+	type item struct {
+		Index     int             `json:"index"`
+		Embedding json.RawMessage `json:"embedding"`
+	}
+	fromItems := func(items []item) ([][]float32, bool) {
+		if len(items) != n {
+			return nil, false
+		}
+		out := make([][]float32, n)
+		for _, it := range items {
+			if it.Index < 0 || it.Index >= n || out[it.Index] != nil {
+				return nil, false
+			}
+			var flat []float32
+			if json.Unmarshal(it.Embedding, &flat) != nil || len(flat) == 0 {
+				var nested [][]float32
+				if json.Unmarshal(it.Embedding, &nested) != nil || len(nested) == 0 || len(nested[0]) == 0 {
+					return nil, false
+				}
+				flat = nested[0]
+			}
+			out[it.Index] = flat
+		}
+		return out, true
+	}
+
+	var openai struct {
+		Data []item `json:"data"`
+	}
+	if json.Unmarshal(raw, &openai) == nil && len(openai.Data) > 0 {
+		if out, ok := fromItems(openai.Data); ok {
+			return out, nil
+		}
+	}
+	var items []item
+	if json.Unmarshal(raw, &items) == nil && len(items) > 0 && len(items[0].Embedding) > 0 {
+		if out, ok := fromItems(items); ok {
+			return out, nil
+		}
+	}
+	var obj struct {
+		Embedding [][]float32 `json:"embedding"`
+	}
+	if json.Unmarshal(raw, &obj) == nil && len(obj.Embedding) == n && len(obj.Embedding[0]) > 0 {
+		return obj.Embedding, nil
+	}
+	var matrix [][]float32
+	if json.Unmarshal(raw, &matrix) == nil && len(matrix) == n && len(matrix[0]) > 0 {
+		return matrix, nil
+	}
+	return nil, errEmbedBatchUnsupported
+}
+
+// embedBatchRequest embeds texts with one request.
+func embedBatchRequest(cfg Config, texts []string) ([][]float32, error) {
+	b, err := json.Marshal(map[string]any{"input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", cfg.EmbedURL, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := embedHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 4 {
+		return nil, errEmbedBatchUnsupported
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("embed http error %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	return decodeEmbeddingBatch(raw, len(texts))
+}
+
+// embedTextsBatched embeds texts in batches, EmbedParallel requests at a time.
+// emit is called once per text (i = index in texts) on the caller's goroutine,
+// so it may write to SQLite.
+func embedTextsBatched(cfg Config, texts []string, emit func(i int, vec []float32, l2 float64, err error)) {
+	if len(texts) == 0 {
+		return
+	}
+	size := cfg.EmbedBatchSize
+	if size <= 0 {
+		size = defaultEmbedBatchSize
+	}
+	if _, no := embedBatchUnsupported.Load(cfg.EmbedURL); no {
+		size = 1
+	}
+	par := cfg.EmbedParallel
+	if par <= 0 {
+		par = defaultEmbedParallel
+	}
+
+	type result struct {
+		i   int
+		vec []float32
+		l2  float64
+		err error
+	}
+	jobs := make(chan []int)
+	results := make(chan result)
+	single := func(i int) {
+		v, l2, err := embedQueryText(cfg, texts[i])
+		results <- result{i: i, vec: v, l2: l2, err: err}
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < par; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if len(idx) == 1 {
+					single(idx[0])
+					continue
+				}
+				batch := make([]string, len(idx))
+				for k, i := range idx {
+					batch[k] = texts[i]
+				}
+				vecs, err := embedBatchRequest(cfg, batch)
+				if errors.Is(err, errEmbedBatchUnsupported) {
+					if _, loaded := embedBatchUnsupported.LoadOrStore(cfg.EmbedURL, true); !loaded {
+						log.Printf("[warn] %s: batch input not supported, embedding one text per request", cfg.EmbedURL)
+					}
+					for _, i := range idx {
+						single(i)
+					}
+					continue
+				}
+				for k, i := range idx {
+					if err != nil {
+						results <- result{i: i, err: err}
+						continue
+					}
+					results <- result{i: i, vec: vecs[k], l2: l2norm(vecs[k])}
+				}
+			}
+		}()
+	}
+	go func() {
+		for start := 0; start < len(texts); start += size {
+			end := start + size
+			if end > len(texts) {
+				end = len(texts)
+			}
+			idx := make([]int, 0, end-start)
+			for i := start; i < end; i++ {
+				idx = append(idx, i)
+			}
+			jobs <- idx
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		emit(r.i, r.vec, r.l2, r.err)
+	}
+}
@@ -0,0 +1,262 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Embedding coverage + auto-heal
+// - A summary (or fact entry) whose ensureEmbedding failed is invisible to
+//   search. The healer sweeps for them every TIMELAYER_EMBED_HEAL_MINUTES
+//   and retries with per-row exponential backoff (embedding_retry).
+// - Facts removed from search (forgotten/archived) are NOT gaps: only rows
+//   backed by an active user_fact count (leftover rows are deleted by the
+//   fact_sync job, see fact_search_consistency.go).
+// - Counts are exposed in GET /api/stats.
+// ============================================================
+
+const (
+	embedHealBatch       = 50
+	embedHealMaxFailures = 3 // consecutive failures before a sweep gives up (server down)
+	embedRetryBase       = 5 * time.Minute
+	embedRetryMax        = 24 * time.Hour
+)
+
+// missingEmbeddingWhere selects summaries s (LEFT JOIN embeddings e) that should be searchable but are not.
+// daily_partial is never embedded (summary_daily_partial.go).
+const missingEmbeddingWhere = `
+	e.summary_id IS NULL AND s.deleted_at IS NULL AND s.type <> 'daily_partial'
+	AND (s.type<>'fact' OR EXISTS (
+		SELECT 1 FROM user_facts f WHERE 'fact:'||f.fact_key=s.period_key AND f.is_active=1
+	))`
+
+type EmbeddingCoverage struct {
+	Total          int            `json:"total"`
+	Embedded       int            `json:"embedded"`
+	Missing        int            `json:"missing"`
+	MissingByType  map[string]int `json:"missing_by_type"`
+	Retrying       int            `json:"retrying"`        // missing rows currently in backoff
+	FactsUnindexed int            `json:"facts_unindexed"` // active facts without a search entry
+	LastSweepAt    string         `json:"last_sweep_at,omitempty"`
+	LastHealed     int            `json:"last_healed"`
+	LastFailed     int            `json:"last_failed"`
+}
+
+type EmbedHealReport struct {
+	Healed  int `json:"healed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"` // no index text, or left for the next sweep after repeated failures
+}
+
+var (
+	embedHealMu   sync.Mutex
+	lastEmbedHeal struct {
+		sync.Mutex
+		at     string
+		healed int
+		failed int
+	}
+)
+
+// embedRetryDelay is the wait after the n-th consecutive failure (n >= 1).
+func embedRetryDelay(n int) time.Duration {
+	d := embedRetryBase
+	for i := 1; i < n && d < embedRetryMax; i++ {
+		d *= 2
+	}
+	if d > embedRetryMax {
+		d = embedRetryMax
+	}
+	return d
+}
+
+// GetEmbeddingCoverage counts searchable rows with and without a vector.
+func GetEmbeddingCoverage(db *sql.DB) (EmbeddingCoverage, error) {
+	cov := EmbeddingCoverage{MissingByType: map[string]int{}}
+	if err := db.QueryRow(`
+		SELECT COUNT(*), COUNT(e.summary_id)
+		FROM summaries s LEFT JOIN embeddings e ON e.summary_id=s.id
+		WHERE s.deleted_at IS NULL AND s.type <> 'daily_partial'
+		  AND (s.type<>'fact' OR EXISTS (
+			SELECT 1 FROM user_facts f WHERE 'fact:'||f.fact_key=s.period_key AND f.is_active=1
+		  ))`).Scan(&cov.Total, &cov.Embedded); err != nil {
+		return cov, err
+	}
+
+	rows, err := db.Query(`
+		SELECT s.type, COUNT(*)
+		FROM summaries s LEFT JOIN embeddings e ON e.summary_id=s.id
+		WHERE ` + missingEmbeddingWhere + `
+		GROUP BY s.type`)
+	if err != nil {
+		return cov, err
+	}
+	for rows.Next() {
+		var typ string
+		var n int
+		if rows.Scan(&typ, &n) == nil {
+			cov.MissingByType[typ] = n
+			cov.Missing += n
+		}
+	}
+	rows.Close()
+
+	_ = db.QueryRow(`
+		SELECT COUNT(*) FROM embedding_retry r
+		JOIN summaries s ON s.id=r.summary_id
+		LEFT JOIN embeddings e ON e.summary_id=s.id
+		WHERE `+missingEmbeddingWhere+` AND r.next_at>?`,
+		time.Now().UTC().Format(time.RFC3339)).Scan(&cov.Retrying)
+	_ = db.QueryRow(`
+		SELECT COUNT(*) FROM user_facts f
+		WHERE f.is_active=1
+		  AND NOT EXISTS (SELECT 1 FROM summaries s WHERE s.type='fact' AND s.period_key='fact:'||f.fact_key)`,
+	).Scan(&cov.FactsUnindexed)
+
+	lastEmbedHeal.Lock()
+	cov.LastSweepAt, cov.LastHealed, cov.LastFailed = lastEmbedHeal.at, lastEmbedHeal.healed, lastEmbedHeal.failed
+	lastEmbedHeal.Unlock()
+	return cov, nil
+}
+
+// HealEmbeddings retries missing embeddings whose backoff has expired.
+// force ignores the backoff (manual /embed_heal).
+func HealEmbeddings(cfg Config, db *sql.DB, force bool) (EmbedHealReport, error) {
+	var rep EmbedHealReport
+	embedHealMu.Lock()
+	defer embedHealMu.Unlock()
+
+	now := time.Now().In(cfg.Location)
+
+	// ---------- facts without a search entry at all ----------
+	frows, err := db.Query(`
+		SELECT f.fact_key, f.fact FROM user_facts f
+		WHERE f.is_active=1
+		  AND NOT EXISTS (SELECT 1 FROM summaries s WHERE s.type='fact' AND s.period_key='fact:'||f.fact_key)
+		LIMIT ?`, embedHealBatch)
+	if err != nil {
+		return rep, err
+	}
+	type factRow struct{ key, fact string }
+	var facts []factRow
+	for frows.Next() {
+		var f factRow
+		if frows.Scan(&f.key, &f.fact) == nil {
+			facts = append(facts, f)
+		}
+	}
+	frows.Close()
+	for _, f := range facts {
+		// creates the "fact:<key>" row; a failed vector is picked up below next sweep
+		_ = syncFactToSearch(cfg, db, f.key, f.fact, "heal")
+	}
+
+	// ---------- summaries / facts without a vector ----------
+	// next_at is stored in UTC so string comparison is chronological.
+	q := `
+		SELECT s.id, s.type, s.period_key, s.json, s.text, COALESCE(r.attempts,0)
+		FROM summaries s
+		LEFT JOIN embeddings e ON e.summary_id=s.id
+		LEFT JOIN embedding_retry r ON r.summary_id=s.id
+		WHERE ` + missingEmbeddingWhere
+	args := []any{}
+	if !force {
+		q += ` AND (r.next_at IS NULL OR r.next_at<=?)`
+		args = append(args, now.UTC().Format(time.RFC3339))
+	}
+	q += ` ORDER BY s.id LIMIT ?`
+	args = append(args, embedHealBatch)
+
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return rep, err
+	}
+	type gap struct {
+		id              int64
+		typ, key, js, t string
+		attempts        int
+	}
+	var gaps []gap
+	for rows.Next() {
+		var g gap
+		if rows.Scan(&g.id, &g.typ, &g.key, &g.js, &g.t, &g.attempts) == nil {
+			gaps = append(gaps, g)
+		}
+	}
+	rows.Close()
+
+	consecutive := 0
+	for _, g := range gaps {
+		if consecutive >= embedHealMaxFailures {
+			rep.Skipped++
+			continue
+		}
+		text := g.t
+		if text == "" {
+			text = extractIndexText(g.js)
+		}
+		if text == "" {
+			rep.Skipped++
+			continue
+		}
+		if err := ensureEmbedding(db, cfg, text, g.typ, g.key); err != nil {
+			consecutive++
+			rep.Failed++
+			n := g.attempts + 1
+			_, _ = db.Exec(`
+				INSERT INTO embedding_retry(summary_id, attempts, next_at, last_error, updated_at)
+				VALUES(?,?,?,?,?)
+				ON CONFLICT(summary_id) DO UPDATE SET
+				  attempts=excluded.attempts,
+				  next_at=excluded.next_at,
+				  last_error=excluded.last_error,
+				  updated_at=excluded.updated_at
+			`, g.id, n, now.UTC().Add(embedRetryDelay(n)).Format(time.RFC3339), err.Error(), now.Format(time.RFC3339))
+			continue
+		}
+		consecutive = 0
+		rep.Healed++
+		_, _ = db.Exec(`DELETE FROM embedding_retry WHERE summary_id=?`, g.id)
+	}
+
+	lastEmbedHeal.Lock()
+	lastEmbedHeal.at, lastEmbedHeal.healed, lastEmbedHeal.failed = now.Format(time.RFC3339), rep.Healed, rep.Failed
+	lastEmbedHeal.Unlock()
+	return rep, nil
+}
+
+// runEmbeddingHealer sweeps every cfg.EmbedHealInterval (0 = disabled).
+func runEmbeddingHealer(ctx context.Context, cfg Config, db *sql.DB) {
+	if db == nil || cfg.EmbedHealInterval <= 0 {
+		return
+	}
+	for sleepCtx(ctx, cfg.EmbedHealInterval) {
+		rep, err := HealEmbeddings(cfg, db, false)
+		if err != nil {
+			log.Printf("[warn] embedding heal failed: %v", err)
+			continue
+		}
+		if rep.Healed > 0 || rep.Failed > 0 {
+			log.Printf("[info] embedding heal: healed=%d failed=%d", rep.Healed, rep.Failed)
+		}
+	}
+}
+
+// runEmbedHealCommand implements /embed_heal (sweep now, ignoring backoff).
+func runEmbedHealCommand(cfg Config, db *sql.DB) (string, error) {
+	rep, err := HealEmbeddings(cfg, db, true)
+	if err != nil {
+		return "", err
+	}
+	cov, err := GetEmbeddingCoverage(db)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[ok] healed=%d failed=%d skipped=%d · coverage %d/%d (missing=%d)",
+		rep.Healed, rep.Failed, rep.Skipped, cov.Embedded, cov.Total, cov.Missing), nil
+}
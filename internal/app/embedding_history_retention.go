@@ -0,0 +1,150 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Embedding history retention
+// - summary_embeddings_history (drift guard, embedding_guard.go) gets a row
+//   every time a weekly/monthly summary is re-embedded and was never
+//   trimmed. The drift check only reads the newest row per summary.
+// - Retention per summary:
+//     the newest TIMELAYER_EMBED_HISTORY_KEEP rows (default 5, 0 = keep all)
+//     are always kept; of the older rows, the last one of each day is kept
+//     for TIMELAYER_EMBED_HISTORY_DAILY_DAYS (default 90, 0 = none).
+//   Rows of summaries that no longer exist are removed as well.
+// - Scheduled: at startup and on day change (after the trash purge); the
+//   DB is VACUUMed when a run removed at least embedHistoryVacuumRows rows.
+//   CLI / chat: /maintenance [--vacuum] runs it now (--vacuum always VACUUMs).
+// ============================================================
+
+const (
+	defaultEmbedHistoryKeep      = 5
+	defaultEmbedHistoryDailyDays = 90
+
+	// embedHistoryVacuumRows: the scheduled job VACUUMs after removing this many rows.
+	embedHistoryVacuumRows = 1000
+)
+
+// EmbedHistoryCompaction reports one CompactEmbeddingHistory run.
+type EmbedHistoryCompaction struct {
+	Trimmed  int64 `json:"trimmed"`  // beyond the newest N and not a kept daily
+	Orphans  int64 `json:"orphans"`  // summary no longer exists
+	Remain   int64 `json:"remain"`   // rows left
+	Vacuumed bool  `json:"vacuumed"` // VACUUM ran
+}
+
+// CompactEmbeddingHistory applies the retention policy to
+// summary_embeddings_history, then VACUUMs when vacuum is set.
+func CompactEmbeddingHistory(cfg Config, db *sql.DB, vacuum bool) (*EmbedHistoryCompaction, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db not open")
+	}
+	res := &EmbedHistoryCompaction{}
+	err := withDBRetry(3, 25*time.Millisecond, func() error {
+		*res = EmbedHistoryCompaction{}
+		return withTx(db, func(tx *sql.Tx) error {
+			r, err := tx.Exec(`
+				DELETE FROM summary_embeddings_history
+				WHERE summary_id NOT IN (SELECT id FROM summaries)
+			`)
+			if err != nil {
+				return err
+			}
+			res.Orphans, _ = r.RowsAffected()
+
+			if cfg.EmbedHistoryKeep <= 0 {
+				return nil
+			}
+			loc := cfg.Location
+			if loc == nil {
+				loc = time.Local
+			}
+			cutoff := ""
+			if cfg.EmbedHistoryDailyDays > 0 {
+				cutoff = time.Now().In(loc).AddDate(0, 0, -cfg.EmbedHistoryDailyDays).Format("2006-01-02")
+			}
+			// rn: position per summary (1 = newest); dn: position within its day.
+			r, err = tx.Exec(`
+				DELETE FROM summary_embeddings_history
+				WHERE id IN (
+					SELECT id FROM (
+						SELECT id, created_at,
+							ROW_NUMBER() OVER (PARTITION BY summary_id ORDER BY created_at DESC, id DESC) AS rn,
+							ROW_NUMBER() OVER (PARTITION BY summary_id, substr(created_at, 1, 10) ORDER BY created_at DESC, id DESC) AS dn
+						FROM summary_embeddings_history
+					)
+					WHERE rn > ? AND (? = '' OR dn > 1 OR substr(created_at, 1, 10) < ?)
+				)
+			`, cfg.EmbedHistoryKeep, cutoff, cutoff)
+			if err != nil {
+				return err
+			}
+			res.Trimmed, _ = r.RowsAffected()
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = db.QueryRow(`SELECT COUNT(*) FROM summary_embeddings_history`).Scan(&res.Remain)
+
+	if vacuum {
+		if _, err := db.Exec(`VACUUM`); err != nil {
+			return res, fmt.Errorf("vacuum: %w", err)
+		}
+		_, _ = db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+		res.Vacuumed = true
+	}
+	return res, nil
+}
+
+// runEmbedHistoryRetention is the scheduled job (startup + day change).
+func runEmbedHistoryRetention(cfg Config, db *sql.DB) {
+	res, err := CompactEmbeddingHistory(cfg, db, false)
+	if err != nil {
+		log.Printf("[warn] embedding history retention failed: %v", err)
+		return
+	}
+	removed := res.Trimmed + res.Orphans
+	if removed == 0 {
+		return
+	}
+	if removed >= embedHistoryVacuumRows {
+		if _, err := db.Exec(`VACUUM`); err != nil {
+			log.Printf("[warn] embedding history vacuum failed: %v", err)
+		}
+	}
+	log.Printf("[info] embedding history retention: removed %d row(s), %d left", removed, res.Remain)
+}
+
+// runMaintenanceCommand implements /maintenance [--vacuum].
+func runMaintenanceCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	vacuum := false
+	for _, f := range strings.Fields(arg) {
+		switch f {
+		case "--vacuum":
+			vacuum = true
+		default:
+			return "usage: /maintenance [--vacuum]", nil
+		}
+	}
+	res, err := CompactEmbeddingHistory(cfg, db, vacuum)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[ok] embedding history: removed %d old + %d orphaned row(s), %d left", res.Trimmed, res.Orphans, res.Remain)
+	if cfg.EmbedHistoryKeep <= 0 {
+		b.WriteString(" (retention off)")
+	}
+	if res.Vacuumed {
+		b.WriteString("\n[ok] database vacuumed")
+	}
+	return b.String(), nil
+}
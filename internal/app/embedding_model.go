@@ -0,0 +1,362 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Embedding model tracking
+// - embedding_model (one row) records the model the stored vectors come
+//   from: its identifier (llama.cpp /v1/models id or /props model_path,
+//   "" when the server tells neither), the dimension and the embed URL.
+//   It is written with the first vector; older DBs adopt the dimension
+//   most of their vectors have at the first startup check.
+// - Startup check (bg job embed_model_check): embed a probe text and ask
+//   the embed server for its model. A different dimension, or a different
+//   identifier when both are known, is a mismatch: it is logged with a
+//   hint to run /reindex --model-migrate and shown in GET /api/stats.
+//   Queries whose dimension does not match the stored vectors warn once
+//   instead of skipping those vectors silently.
+// - /reindex --model-migrate re-embeds every summary / fact search row and
+//   every pending fact with the current model, drops the drift-guard
+//   history (vectors of the old model would look like 100% drift), rebuilds
+//   the vector index and records the new model. Texts are sent in batches
+//   (embedding_batch.go).
+// ============================================================
+
+const embedModelProbeText = "timelayer embedding probe"
+
+// EmbeddingModelInfo is a model identifier + dimension.
+type EmbeddingModelInfo struct {
+	Model     string `json:"model"`
+	Dim       int    `json:"dim"`
+	EmbedURL  string `json:"embed_url,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// EmbeddingModelStatus is the "embedding_model" part of GET /api/stats.
+type EmbeddingModelStatus struct {
+	Stored   *EmbeddingModelInfo `json:"stored,omitempty"`
+	Current  *EmbeddingModelInfo `json:"current,omitempty"` // from the last startup check
+	Mismatch bool                `json:"mismatch"`
+	Stale    int                 `json:"stale_vectors"` // vectors whose dim differs from the current model's
+}
+
+var embedModelState struct {
+	sync.Mutex
+	current   *EmbeddingModelInfo
+	warnQuery sync.Once
+}
+
+// loadEmbeddingModel returns the recorded model (nil when none).
+func loadEmbeddingModel(db *sql.DB) *EmbeddingModelInfo {
+	if db == nil {
+		return nil
+	}
+	var m EmbeddingModelInfo
+	err := db.QueryRow(`SELECT model, dim, embed_url, updated_at FROM embedding_model WHERE id=1`).
+		Scan(&m.Model, &m.Dim, &m.EmbedURL, &m.UpdatedAt)
+	if err != nil {
+		return nil
+	}
+	return &m
+}
+
+// saveEmbeddingModel records m as the model of the stored vectors.
+func saveEmbeddingModel(cfg Config, db *sql.DB, m EmbeddingModelInfo) error {
+	_, err := db.Exec(`
+		INSERT INTO embedding_model(id, model, dim, embed_url, updated_at)
+		VALUES(1,?,?,?,?)
+		ON CONFLICT(id) DO UPDATE SET
+			model=excluded.model, dim=excluded.dim,
+			embed_url=excluded.embed_url, updated_at=excluded.updated_at
+	`, m.Model, m.Dim, m.EmbedURL, time.Now().In(cfg.Location).Format(time.RFC3339))
+	return err
+}
+
+// noteEmbeddingWrite records the model with the first stored vector
+// (best-effort; called by every vector writer).
+func noteEmbeddingWrite(cfg Config, db *sql.DB, dim int) {
+	if db == nil || dim <= 0 {
+		return
+	}
+	model := ""
+	embedModelState.Lock()
+	if c := embedModelState.current; c != nil && c.Dim == dim {
+		model = c.Model
+	}
+	embedModelState.Unlock()
+	_, _ = db.Exec(`
+		INSERT INTO embedding_model(id, model, dim, embed_url, updated_at)
+		VALUES(1,?,?,?,?)
+		ON CONFLICT(id) DO NOTHING
+	`, model, dim, cfg.EmbedURL, time.Now().In(cfg.Location).Format(time.RFC3339))
+}
+
+// noteQueryDimMismatch warns (once per process) that a query vector cannot
+// be compared with stored vectors of another dimension.
+func noteQueryDimMismatch(stored, query int) {
+	embedModelState.warnQuery.Do(func() {
+		log.Printf("[warn] embedding dim mismatch: stored vectors have dim %d, the embed model returns %d; "+
+			"these vectors are ignored until /reindex --model-migrate", stored, query)
+	})
+}
+
+// probeEmbeddingModel embeds a probe text and asks the embed server for its model id.
+func probeEmbeddingModel(cfg Config) (*EmbeddingModelInfo, error) {
+	vec, _, err := embedQueryText(cfg, embedModelProbeText)
+	if err != nil {
+		return nil, err
+	}
+	if len(vec) == 0 {
+		return nil, errors.New("empty probe embedding")
+	}
+	return &EmbeddingModelInfo{Model: probeEmbedModelID(cfg), Dim: len(vec), EmbedURL: cfg.EmbedURL}, nil
+}
+
+// probeEmbedModelID asks the embed server (/v1/models, then llama.cpp /props)
+// for the model identifier; "" when it tells neither.
+func probeEmbedModelID(cfg Config) string {
+	base, err := chatServerBase(cfg.EmbedURL)
+	if err != nil {
+		return ""
+	}
+	client := &http.Client{Timeout: 3 * time.Second}
+	get := func(path string, v any) error {
+		resp, err := client.Get(base + path)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s: http %d", path, resp.StatusCode)
+		}
+		b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	}
+
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if get("/v1/models", &models) == nil && len(models.Data) == 1 {
+		return strings.TrimSpace(models.Data[0].ID)
+	}
+	var props struct {
+		ModelPath string `json:"model_path"`
+	}
+	if get("/props", &props) == nil {
+		return strings.TrimSpace(props.ModelPath)
+	}
+	return ""
+}
+
+// embeddingModelMismatch reports whether vectors of stored cannot be compared with cur.
+func embeddingModelMismatch(stored, cur *EmbeddingModelInfo) bool {
+	if stored == nil || cur == nil {
+		return false
+	}
+	if stored.Dim != cur.Dim {
+		return true
+	}
+	return stored.Model != "" && cur.Model != "" && stored.Model != cur.Model
+}
+
+// dominantEmbeddingDim is the dimension most stored vectors have (0 = none).
+func dominantEmbeddingDim(db *sql.DB) int {
+	var dim int
+	_ = db.QueryRow(`
+		SELECT dim FROM embeddings GROUP BY dim ORDER BY COUNT(*) DESC LIMIT 1
+	`).Scan(&dim)
+	return dim
+}
+
+// checkEmbeddingModel is the startup check (bg job embed_model_check).
+func checkEmbeddingModel(cfg Config, db *sql.DB) {
+	if db == nil {
+		return
+	}
+	cur, err := probeEmbeddingModel(cfg)
+	if err != nil {
+		log.Printf("[warn] embedding model check: %v", err)
+		return
+	}
+	embedModelState.Lock()
+	embedModelState.current = cur
+	embedModelState.Unlock()
+
+	stored := loadEmbeddingModel(db)
+	if stored == nil {
+		// older DB (or no vectors yet): adopt what the vectors are
+		m := *cur
+		if dim := dominantEmbeddingDim(db); dim > 0 && dim != cur.Dim {
+			m = EmbeddingModelInfo{Dim: dim}
+		}
+		_ = saveEmbeddingModel(cfg, db, m)
+		stored = &m
+	}
+
+	if embeddingModelMismatch(stored, cur) {
+		log.Printf("[warn] embedding model changed: stored vectors are %s, %s now returns %s; "+
+			"run /reindex --model-migrate to re-embed everything", describeEmbeddingModel(stored), cfg.EmbedURL, describeEmbeddingModel(cur))
+		return
+	}
+	if stored.Model == "" && cur.Model != "" {
+		_ = saveEmbeddingModel(cfg, db, *cur) // same dim, now with a name
+	}
+}
+
+func describeEmbeddingModel(m *EmbeddingModelInfo) string {
+	name := m.Model
+	if name == "" {
+		name = "unknown model"
+	}
+	return fmt.Sprintf("%s (dim %d)", name, m.Dim)
+}
+
+// GetEmbeddingModelStatus returns the recorded and the detected embedding model.
+func GetEmbeddingModelStatus(db *sql.DB) EmbeddingModelStatus {
+	st := EmbeddingModelStatus{Stored: loadEmbeddingModel(db)}
+	embedModelState.Lock()
+	if c := embedModelState.current; c != nil {
+		cc := *c
+		st.Current = &cc
+	}
+	embedModelState.Unlock()
+	st.Mismatch = embeddingModelMismatch(st.Stored, st.Current)
+	if st.Current != nil {
+		_ = db.QueryRow(`SELECT COUNT(*) FROM embeddings WHERE dim != ?`, st.Current.Dim).Scan(&st.Stale)
+	}
+	return st
+}
+
+// EmbeddingMigrateReport is the outcome of MigrateEmbeddingModel.
+type EmbeddingMigrateReport struct {
+	From      *EmbeddingModelInfo `json:"from,omitempty"`
+	To        EmbeddingModelInfo  `json:"to"`
+	Summaries int                 `json:"summaries"`
+	Pending   int                 `json:"pending"`
+	Failed    int                 `json:"failed"`
+}
+
+// MigrateEmbeddingModel re-embeds all stored vectors with the current embed model.
+// The new model is recorded only when nothing failed (run it again otherwise).
+func MigrateEmbeddingModel(cfg Config, db *sql.DB) (*EmbeddingMigrateReport, error) {
+	cur, err := probeEmbeddingModel(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("embed server: %w", err)
+	}
+	embedModelState.Lock()
+	embedModelState.current = cur
+	embedModelState.Unlock()
+	rep := &EmbeddingMigrateReport{From: loadEmbeddingModel(db), To: *cur}
+
+	type item struct {
+		id   int64
+		typ  string
+		text string
+	}
+	// same rows as the coverage sweep (missingEmbeddingWhere): no daily_partial, no inactive fact
+	rows, err := db.Query(`
+		SELECT s.id, s.type, s.json, s.text FROM summaries s
+		WHERE s.deleted_at IS NULL AND s.type <> ?
+		  AND (s.type<>'fact' OR EXISTS (
+			SELECT 1 FROM user_facts f WHERE 'fact:'||f.fact_key=s.period_key AND f.is_active=1
+		  ))
+		ORDER BY s.id
+	`, summaryTypeDailyPartial)
+	if err != nil {
+		return nil, err
+	}
+	var items []item
+	for rows.Next() {
+		var it item
+		var js, txt sql.NullString
+		if rows.Scan(&it.id, &it.typ, &js, &txt) != nil {
+			continue
+		}
+		if it.typ == "fact" || strings.TrimSpace(js.String) == "" {
+			it.text = strings.TrimSpace(txt.String)
+		} else {
+			it.text = extractIndexText(js.String)
+		}
+		if it.text != "" {
+			items = append(items, it)
+		}
+	}
+	rows.Close()
+
+	texts := make([]string, len(items))
+	for i, it := range items {
+		texts[i] = it.text
+	}
+	ts := time.Now().In(cfg.Location).Format(time.RFC3339)
+	embedTextsBatched(cfg, texts, func(i int, vec []float32, l2 float64, err error) {
+		it := items[i]
+		if err == nil && (len(vec) == 0 || l2 == 0) {
+			err = errors.New("empty embedding")
+		}
+		if err == nil {
+			err = upsertEmbedding(db, it.id, vec, l2, ts)
+		}
+		if err != nil {
+			log.Printf("[warn] model migrate: summary #%d (%s): %v", it.id, it.typ, err)
+			rep.Failed++
+			return
+		}
+		rep.Summaries++
+	})
+
+	// pending vectors are rebuilt by the pending embed worker
+	if res, err := db.Exec(`DELETE FROM pending_fact_embeddings`); err == nil {
+		n, _ := res.RowsAffected()
+		rep.Pending = int(n)
+	}
+	invalidatePendingGroups()
+	kickPendingEmbeddings()
+
+	_, _ = db.Exec(`DELETE FROM summary_embeddings_history`)
+	// what is left in another dim (trashed summaries, failures) is unusable
+	_, _ = db.Exec(`DELETE FROM embeddings WHERE dim != ?`, cur.Dim)
+
+	clearVectorIndex()
+	goSafe("vector_index", func() { buildVectorIndex(cfg, db) })
+
+	if rep.Failed == 0 {
+		if err := saveEmbeddingModel(cfg, db, *cur); err != nil {
+			return rep, err
+		}
+	}
+	return rep, nil
+}
+
+// runReindexModelMigrate implements /reindex --model-migrate.
+func runReindexModelMigrate(cfg Config, db *sql.DB) (string, error) {
+	rep, err := MigrateEmbeddingModel(cfg, db)
+	if err != nil {
+		return "", err
+	}
+	from := "none"
+	if rep.From != nil {
+		from = describeEmbeddingModel(rep.From)
+	}
+	out := fmt.Sprintf("[ok] model migrate %s -> %s: re-embedded %d summaries/facts, %d pending fact(s) queued, failed=%d",
+		from, describeEmbeddingModel(&rep.To), rep.Summaries, rep.Pending, rep.Failed)
+	if rep.Failed > 0 {
+		out += "\n[warn] some vectors failed; the old model stays recorded, run /reindex --model-migrate again"
+	}
+	return out, nil
+}
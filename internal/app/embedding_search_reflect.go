@@ -136,7 +136,11 @@ func ensureEmbedding(db *sql.DB, cfg Config, text, typ, key string) error {
 	if err != nil {
 		return err
 	}
+	return storeSummaryEmbedding(cfg, db, sid, embedding)
+}
 
+// storeSummaryEmbedding writes the (first) vector of summary sid.
+func storeSummaryEmbedding(cfg Config, db *sql.DB, sid int64, embedding []float32) error {
 	// serialize + L2
 	buf := new(bytes.Buffer)
 	var l2 float64
@@ -146,7 +150,7 @@ func ensureEmbedding(db *sql.DB, cfg Config, text, typ, key string) error {
 	}
 	l2 = math.Sqrt(l2)
 
-	_, err = db.Exec(`
+	_, err := db.Exec(`
 		INSERT INTO embeddings(summary_id, dim, vec, l2, created_at)
 		VALUES(?,?,?,?,?)
 	`,
@@ -156,8 +160,12 @@ func ensureEmbedding(db *sql.DB, cfg Config, text, typ, key string) error {
 		l2,
 		time.Now().Format(time.RFC3339),
 	)
-
-	return err
+	if err != nil {
+		return err
+	}
+	noteEmbeddingWrite(cfg, db, len(embedding))
+	vectorIndexPut(cfg, db, sid, embedding)
+	return nil
 }
 
 /*
@@ -177,11 +185,22 @@ const helpText = `
     but does not guarantee factual completeness.
 
 
+/incognito [on|off]
+    Toggle incognito mode for the following turns.
+    Answers still use memory, but nothing is written:
+    no log lines, no summaries, no implicit facts.
+
+
 /ask <question>
     Ask a question and get a direct answer
     based ONLY on your own historical records.
     The assistant will reason, summarize, and cite memory.
     If memory is insufficient, it will say so explicitly.
+    Add --scope work,family or --ws <workspace> to only use
+    facts/summaries carrying those tags.
+    Add --type monthly --period 2025-09 (or --doc <id>) to answer
+    from that one summary only; --type alone restricts search to
+    that type. Ids are shown as #id in --refs.
 
 
 /search <query>
@@ -189,6 +208,7 @@ const helpText = `
     Performs semantic search over all stored memories
     (facts, daily / weekly / monthly summaries),
     and shows raw matching records without answering.
+    Supports the same --scope / --ws filters as /ask.
 
 
 /daily
@@ -197,6 +217,10 @@ const helpText = `
 /daily --force
     Force regenerate today's daily summary.
 
+/daily --partial
+    Summarize today so far (can be re-run during the day).
+    Used only as today's context; never searched or rolled up.
+
 
 /weekly
     Generate the current week's weekly summary
@@ -214,14 +238,106 @@ const helpText = `
     Force regenerate the current month's monthly summary.
 
 
-/reindex daily|weekly|monthly|all
-    Rebuild embeddings for existing summaries.
+/reindex daily|weekly|monthly|all|fts
+    Rebuild embeddings for existing summaries (fts: the full-text index).
     Does NOT regenerate summaries themselves.
 
+/reindex --model-migrate
+    Re-embed everything with the current embedding model (after switching
+    TIMELAYER_EMBED_URL to a model with another dimension / identifier).
+
+
+/logcheck [YYYY-MM-DD] [--fix]
+    Validate daily JSONL logs and report malformed lines.
+    --fix moves them into <date>.jsonl.bad (today's log is left alone).
+
+/logs_import [YYYY-MM-DD]
+    Ingest existing JSONL logs into the messages table
+    (all days if no date). Safe to run repeatedly.
+
+/logs_export <YYYY-MM-DD>
+    Write one day's messages back out as <date>.jsonl.
+
+/export_chat <from> [to] [--format md|html]
+    Write a readable transcript (one heading per day, no op records)
+    to ~/local-ai/exports/.
+
+/export_summary <daily|weekly|monthly> <period_key>
+/export_summary --year YYYY [--type monthly|weekly|daily]
+    Render summaries as a readable Markdown digest (one period, or a
+    combined "year in review") into ~/local-ai/exports/.
+
+/embed_heal
+    Retry every summary / fact that has no embedding now (ignores the
+    backoff of the background healer) and print the coverage.
+
+/hygiene [YYYY-MM] [--save]
+    Memory hygiene report: pending backlog, open conflicts, stale facts,
+    guard warnings, summaries without embedding. --save stores it
+    (also done monthly in the background) and sends the notification.
+
+/email_poll
+    Poll the IMAP folders now (needs TIMELAYER_IMAP_ADDR / _USER).
+    New emails become "email" memories; confirmations go to pending.
+
+/annotate <daily|weekly|monthly> <period_key> <note>
+    Attach a correction/note to a summary. Notes are shown next to
+    the summary in chat context (with higher authority) and are
+    passed to weekly/monthly rollups.
+
+/annotations <daily|weekly|monthly> <period_key>
+    List a summary's notes.
+
+/trash
+    List forgotten facts, rejected pending facts and deleted summaries
+    (kept for TIMELAYER_TRASH_DAYS, default 30, then purged).
+
+/restore <fact|pending|summary> <id>
+    Restore an item from the trash.
+
+/restore <fact>
+    Re-activate the latest archived or forgotten version of a fact (by text
+    or fact_key) from the fact history, even after the trash was purged.
 
-/remember <fact>
+/unarchive <YYYY-MM-DD> [--reprocess] [--force]
+    Pull an archived day's raw log back into the log dir (for citing,
+    exporting, or --reprocess to regenerate its daily summary).
+    /search marks daily hits whose raw log is archived.
+
+/delete_summary <daily|weekly|monthly> <period_key>
+    Move a summary to the trash (removed from search and context).
+
+/maintenance [--vacuum]
+    Trim the embedding drift history now (TIMELAYER_EMBED_HISTORY_KEEP /
+    TIMELAYER_EMBED_HISTORY_DAILY_DAYS; also runs daily). --vacuum
+    compacts the database file afterwards.
+
+/conflict_sweep [--dry-run]
+    Apply the conflict policies (TIMELAYER_CONFLICT_POLICIES) to the open
+    fact conflicts now; --dry-run only lists what they would decide.
+
+/assistants
+    List assistant profiles (* = active, set via TIMELAYER_ASSISTANT).
+
+/users
+    List users with memory (* = current, set via TIMELAYER_USER).
+
+/sessions
+    List conversation sessions (web UI: /session new|main|<id> switches).
+
+/style [concise|detailed|bullet|off] [--max N]
+    Set the default answer style (no args: show it).
+    --max caps answers at N sentences.
+
+/stale_check [--fix]
+    List daily summaries whose raw log changed after they were built.
+    --fix regenerates them (weekly/monthly are not rebuilt).
+
+
+/remember [--tag <category>] <fact>
     Explicitly teach the system a confirmed fact.
     Stored as authoritative long-term memory.
+    --tag sets its category (identity|family|work|health|preference|other).
 
 
 /forget <fact>
@@ -229,6 +345,52 @@ const helpText = `
     The fact will no longer be treated as authoritative.
 
 
+/tag <fact> #tag [#tag...]
+    Add tags (e.g. #work #family #health) to a remembered fact.
+
+/untag <fact> #tag [#tag...]
+    Remove tags from a remembered fact.
+
+/tags [#tag]
+    List tags in use, or the facts carrying a tag.
+
+/core [<fact>]
+    Mark a fact as core (name, family, occupation...): injected on every turn.
+    Without a fact, list the core facts.
+
+/uncore <fact>
+    Back to relevance filtering for this fact.
+
+/merge [<keep> | <other> [| ...]]
+    Merge duplicate facts into the first one; the others are archived.
+    Without facts, list groups of active facts that look like duplicates.
+
+/define <term> = <definition>
+    Add a glossary term (project jargon, abbreviations). It is explained
+    to the assistant only when the term appears in your question;
+    it is not a fact about you.
+
+/undefine <term>
+    Remove a glossary term.
+
+/glossary
+    List the glossary.
+
+/preview <message>
+    Show what a message would share with the model (system prompt,
+    memory blocks, estimated tokens) without sending it.
+
+/memory_diff <from> [to] [--window N] [--narrate]
+    What changed between two dates: facts added / removed / changed and
+    summary themes that emerged / faded (to defaults to today).
+    --narrate adds a short model-written narrative.
+
+/flow [<name> [arg]]
+    Guided multi-step flows (replace_conflict, weekly_review).
+    The web UI runs /flow <name> inline: answer each prompt, "cancel" stops.
+    Without a name, list the flows.
+
+
 /paste
     Enter multi-line input.
     Submit with an empty line.
@@ -280,7 +442,7 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 	switch cmd {
 
 	case "/help":
-		fmt.Println(helpText)
+		fmt.Print(helpText + "\n")
 
 	case "/debug":
 		if arg == "" {
@@ -306,16 +468,22 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 			_ = Chat(lw, cfg, db, msg)
 		} else {
 			answer := streamChat(cfg, msg)
-			_ = lw.WriteRecord(map[string]string{"role": "user", "content": msg})
-			_ = lw.WriteRecord(map[string]string{"role": "assistant", "content": answer})
+			if cfg.Incognito {
+				return
+			}
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{"role": "user", "content": msg}))
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{"role": "assistant", "content": answer}))
 		}
 
 	case "/search":
-		if arg == "" {
-			fmt.Println("usage: /search <query>")
+		q, scope := parseScopeFlags(arg)
+		if q == "" {
+			fmt.Println("usage: /search <query> [--scope tag,tag] [--ws name]")
 			return
 		}
-		hits, err := SearchWithScore(db, cfg, arg)
+		scfg := cfg
+		scfg.Scope = scope
+		hits, err := SearchWithScore(db, scfg, q)
 		if err != nil {
 			fmt.Println("search error:", err)
 			return
@@ -324,6 +492,7 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 			fmt.Println("no related memory")
 			return
 		}
+		markArchivedHits(cfg, db, hits)
 
 		for _, h := range hits {
 			// ⭐ 展示层分流：fact / 非 fact
@@ -332,6 +501,9 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 			} else {
 				fmt.Printf("[%.4f] %s %s\n", h.Score, h.Date, h.Type)
 			}
+			if h.Archived {
+				fmt.Printf("(raw log archived: /unarchive %s to cite it)\n", h.Date)
+			}
 
 			if strings.TrimSpace(h.Text) != "" {
 				fmt.Println(h.Text)
@@ -364,10 +536,10 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 
 	case "/remember":
 		if arg == "" {
-			fmt.Println("usage: /remember <fact>")
+			fmt.Println("usage: /remember [--tag <category>] <fact>")
 			return
 		}
-		out, err := RememberFactWithOutcome(lw, cfg, db, arg)
+		out, err := rememberTagged(lw, cfg, db, arg)
 		if err != nil {
 			fmt.Println("[error]", err)
 			return
@@ -375,11 +547,14 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 		if out != nil {
 			switch out.Status {
 			case "conflict":
-				fmt.Println("[conflict] 已进入 FACTS -> CONFLICTS，处理后才会晋升为长期事实。")
+				fmt.Println(conflictMessage(out))
 				return
 			case "noop":
 				fmt.Println("[noop] nothing to remember")
 				return
+			case "blocked":
+				fmt.Println("[blocked] matches a fact policy rule or contains a secret; not stored")
+				return
 			}
 		}
 		fmt.Println("[ok] fact recorded")
@@ -395,6 +570,193 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 		}
 		fmt.Println("[ok] fact retracted")
 
+	case "/tag", "/untag", "/tags":
+		out, err := runFactTagCommand(cfg, db, cmd, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/core", "/uncore":
+		out, err := runFactCoreCommand(cfg, db, cmd, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/merge":
+		out, err := runFactMergeCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/maintenance":
+		out, err := runMaintenanceCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/conflict_sweep":
+		out, err := runConflictSweepCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/define", "/undefine", "/glossary":
+		out, err := runGlossaryCommand(cfg, db, cmd, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/preview":
+		out, err := runChatPreviewCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/memory_diff":
+		out, err := runMemoryDiffCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/flow":
+		fmt.Println(runFlowListCommand())
+
+	case "/logcheck":
+		out, err := runLogCheckCommand(cfg, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/logs_import", "/logs_export":
+		out, err := runLogStoreCommand(cfg, db, cmd, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/export_chat":
+		out, err := runExportChatCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/export_summary":
+		out, err := runExportSummaryCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/email_poll":
+		out, err := runEmailPollCommand(cfg, db)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/embed_heal":
+		out, err := runEmbedHealCommand(cfg, db)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/hygiene":
+		out, err := runHygieneCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/stale_check":
+		out, err := runStaleCheckCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/style":
+		out, err := runStyleCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/annotate", "/annotations":
+		out, err := runAnnotateCommand(cfg, db, cmd, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/trash", "/restore", "/delete_summary":
+		out, err := runTrashCommand(cfg, db, cmd, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/unarchive":
+		out, err := runUnarchiveCommand(cfg, db, arg)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/assistants":
+		out, err := runAssistantsCommand(cfg, db)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/users":
+		out, err := runUsersCommand(cfg, db)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
+	case "/sessions":
+		out, err := runSessionsCommand(cfg, db)
+		if err != nil {
+			fmt.Println("[error]", err)
+			return
+		}
+		fmt.Println(out)
+
 	case "/pending_add":
 		if strings.TrimSpace(arg) == "" {
 			fmt.Println("usage: /pending_add <fact> [--conf 0.85]")
@@ -446,7 +808,17 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 			}
 		}
 
-		if err := ensureDaily(cfg, db, day, force); err != nil {
+		if strings.Contains(arg, "--partial") {
+			out, err := runDailyPartial(foregroundLLM(cfg), db, day)
+			if err != nil {
+				fmt.Println("[error] partial daily summary failed:", err)
+				return
+			}
+			fmt.Println(out)
+			return
+		}
+
+		if err := ensureDaily(foregroundLLM(cfg), db, day, force); err != nil {
 			fmt.Println("[error] daily summary failed:", err)
 			return
 		}
@@ -457,7 +829,7 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 		force := strings.Contains(arg, "--force")
 		y, w := time.Now().In(cfg.Location).ISOWeek()
 		key := fmt.Sprintf("%04d-W%02d", y, w)
-		if err := ensureWeekly(cfg, db, key, force); err != nil {
+		if err := ensureWeekly(foregroundLLM(cfg), db, key, force); err != nil {
 			fmt.Println("[error] weekly summary failed:", err)
 			return
 		}
@@ -466,7 +838,7 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 	case "/monthly":
 		force := strings.Contains(arg, "--force")
 		key := time.Now().In(cfg.Location).Format("2006-01")
-		if err := ensureMonthly(cfg, db, key, force); err != nil {
+		if err := ensureMonthly(foregroundLLM(cfg), db, key, force); err != nil {
 			fmt.Println("[error] monthly summary failed:", err)
 			return
 		}
@@ -477,6 +849,15 @@ func handleCommand(cfg Config, db *sql.DB, lw *LogWriter, reader *bufio.Reader,
 		if target == "" {
 			target = "daily"
 		}
+		if target == "--model-migrate" {
+			out, err := runReindexModelMigrate(cfg, db)
+			if err != nil {
+				fmt.Println("[error]", err)
+				return
+			}
+			fmt.Println(out)
+			return
+		}
 		if err := Reindex(db, cfg, target); err != nil {
 			fmt.Println("reindex error:", err)
 		}
@@ -0,0 +1,300 @@
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Error reporting hooks
+// - Panics (HTTP handlers, background goroutines, single bg jobs) and
+//   error-level events (failed bg jobs) are sent to the configured sinks:
+//     TIMELAYER_ERROR_REPORT_FILE        JSONL file, one ErrorEvent per line
+//     TIMELAYER_ERROR_REPORT_WEBHOOK     POST ErrorEvent as JSON
+//     TIMELAYER_ERROR_REPORT_SENTRY_DSN  Sentry-compatible store endpoint
+//   (GlitchTip / self-hosted Sentry accept the same DSN).
+// - Delivery is async and best-effort; the same source+message is sent at most
+//   once per errorReportDedupe (repeats are counted in the next event).
+// - goSafe runs a background goroutine with panic isolation: the panic is
+//   reported and the process keeps serving.
+// ============================================================
+
+const errorReportDedupe = 5 * time.Minute
+
+type ErrorEvent struct {
+	Level      string            `json:"level"`  // panic | error
+	Source     string            `json:"source"` // http | bg_job | <goroutine name>
+	Message    string            `json:"message"`
+	Stack      string            `json:"stack,omitempty"`
+	Context    map[string]string `json:"context,omitempty"` // req_id / method / path / ip, job kind …
+	Suppressed int               `json:"suppressed,omitempty"`
+	Host       string            `json:"host,omitempty"`
+	Time       string            `json:"ts"`
+}
+
+// ErrorReporter is one sink.
+type ErrorReporter interface {
+	Name() string
+	Report(ev ErrorEvent) error
+}
+
+var errorReportHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+var errorReporting struct {
+	sync.Mutex
+	sinks []ErrorReporter
+	loc   *time.Location
+	seen  map[string]*errorSeen
+}
+
+type errorSeen struct {
+	last       time.Time
+	suppressed int
+}
+
+// capturedPanic carries a panic value with the stack of the goroutine it came
+// from (re-raised elsewhere, e.g. by the deadline middleware).
+type capturedPanic struct {
+	v     any
+	stack []byte
+}
+
+func (p capturedPanic) String() string { return fmt.Sprint(p.v) }
+
+// initErrorReporting installs the sinks configured in cfg (none = reporting off).
+func initErrorReporting(cfg Config) {
+	var sinks []ErrorReporter
+	if cfg.ErrorReportFile != "" {
+		sinks = append(sinks, &fileErrorReporter{path: cfg.ErrorReportFile})
+	}
+	if cfg.ErrorReportWebhook != "" {
+		sinks = append(sinks, &webhookErrorReporter{url: cfg.ErrorReportWebhook})
+	}
+	if cfg.ErrorReportSentryDSN != "" {
+		s, err := newSentryErrorReporter(cfg.ErrorReportSentryDSN)
+		if err != nil {
+			log.Printf("[warn] error reporting: sentry dsn ignored: %v", err)
+		} else {
+			sinks = append(sinks, s)
+		}
+	}
+	errorReporting.Lock()
+	errorReporting.sinks = sinks
+	errorReporting.loc = cfg.Location
+	errorReporting.seen = map[string]*errorSeen{}
+	errorReporting.Unlock()
+}
+
+// reportError sends an error-level event (nil err is ignored).
+func reportError(source string, err error, ctx map[string]string) {
+	if err == nil {
+		return
+	}
+	dispatchErrorEvent(ErrorEvent{Level: "error", Source: source, Message: err.Error(), Context: ctx})
+}
+
+// reportPanic sends a panic event; stack nil = the current goroutine's stack.
+func reportPanic(source string, v any, stack []byte, ctx map[string]string) {
+	if cp, ok := v.(capturedPanic); ok {
+		v, stack = cp.v, cp.stack
+	}
+	if stack == nil {
+		stack = debug.Stack()
+	}
+	dispatchErrorEvent(ErrorEvent{Level: "panic", Source: source, Message: fmt.Sprint(v), Stack: string(stack), Context: ctx})
+}
+
+func dispatchErrorEvent(ev ErrorEvent) {
+	errorReporting.Lock()
+	sinks, loc := errorReporting.sinks, errorReporting.loc
+	if len(sinks) == 0 {
+		errorReporting.Unlock()
+		return
+	}
+	now := time.Now()
+	key := ev.Source + "\x00" + ev.Message
+	if s := errorReporting.seen[key]; s != nil && now.Sub(s.last) < errorReportDedupe {
+		s.suppressed++
+		errorReporting.Unlock()
+		return
+	} else if s != nil {
+		ev.Suppressed = s.suppressed
+	}
+	errorReporting.seen[key] = &errorSeen{last: now}
+	if len(errorReporting.seen) > 1024 {
+		for k, s := range errorReporting.seen {
+			if now.Sub(s.last) >= errorReportDedupe {
+				delete(errorReporting.seen, k)
+			}
+		}
+	}
+	errorReporting.Unlock()
+
+	if loc == nil {
+		loc = time.Local
+	}
+	ev.Time = now.In(loc).Format(time.RFC3339)
+	ev.Host, _ = os.Hostname()
+	go func() {
+		for _, s := range sinks {
+			if err := s.Report(ev); err != nil {
+				log.Printf("[warn] error reporting (%s) failed: %v", s.Name(), err)
+			}
+		}
+	}()
+}
+
+// goSafe runs fn in a goroutine; a panic is logged and reported instead of
+// taking the process down.
+func goSafe(source string, fn func()) {
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				stack := debug.Stack()
+				log.Printf("[error] panic in %s: %v\n%s", source, v, stack)
+				reportPanic(source, v, stack, nil)
+			}
+		}()
+		fn()
+	}()
+}
+
+// ---- sinks ----
+
+type fileErrorReporter struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (r *fileErrorReporter) Name() string { return "file" }
+
+func (r *fileErrorReporter) Report(ev ErrorEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+type webhookErrorReporter struct {
+	url string
+}
+
+func (r *webhookErrorReporter) Name() string { return "webhook" }
+
+func (r *webhookErrorReporter) Report(ev ErrorEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return postErrorReport(r.url, b, nil)
+}
+
+// sentryErrorReporter speaks the Sentry store API (DSN https://<key>@host/<project>).
+type sentryErrorReporter struct {
+	storeURL string
+	auth     string
+}
+
+func newSentryErrorReporter(dsn string) (*sentryErrorReporter, error) {
+	u, err := url.Parse(strings.TrimSpace(dsn))
+	if err != nil {
+		return nil, err
+	}
+	key := u.User.Username()
+	project := strings.Trim(u.Path, "/")
+	if key == "" || project == "" || u.Host == "" {
+		return nil, fmt.Errorf("expected <scheme>://<key>@<host>/<project>")
+	}
+	prefix := ""
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	return &sentryErrorReporter{
+		storeURL: fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+		auth:     fmt.Sprintf("Sentry sentry_version=7, sentry_client=timelayer/1.0, sentry_key=%s", key),
+	}, nil
+}
+
+func (r *sentryErrorReporter) Name() string { return "sentry" }
+
+func (r *sentryErrorReporter) Report(ev ErrorEvent) error {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	level := "error"
+	if ev.Level == "panic" {
+		level = "fatal"
+	}
+	tags := map[string]string{"source": ev.Source}
+	extra := map[string]any{}
+	for k, v := range ev.Context {
+		extra[k] = v
+	}
+	if ev.Stack != "" {
+		extra["stack"] = ev.Stack
+	}
+	if ev.Suppressed > 0 {
+		extra["suppressed"] = ev.Suppressed
+	}
+	b, err := json.Marshal(map[string]any{
+		"event_id":    hex.EncodeToString(id[:]),
+		"timestamp":   ev.Time,
+		"level":       level,
+		"logger":      ev.Source,
+		"platform":    "go",
+		"server_name": ev.Host,
+		"message":     ev.Message,
+		"tags":        tags,
+		"extra":       extra,
+		"exception": map[string]any{"values": []map[string]string{
+			{"type": ev.Level, "value": ev.Message},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	return postErrorReport(r.storeURL, b, map[string]string{"X-Sentry-Auth": r.auth})
+}
+
+func postErrorReport(u string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := errorReportHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
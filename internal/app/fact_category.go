@@ -0,0 +1,328 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ============================================================
+// Fact categories
+// - user_facts.category is one of identity / family / work / health /
+//   preference / other. It is derived from keywords when the fact row is
+//   first written (upsertUserFact) and kept when the value is replaced;
+//   /remember --tag <category> <fact> or POST /api/facts/category sets it
+//   explicitly. Unlike tags (fact_tags.go, free-form, many per fact) a fact
+//   has exactly one category.
+// - Injection budgets: when selectContextFacts has to cut (more candidates
+//   than TIMELAYER_FACT_INJECT_MAX), each category first gets up to its
+//   budget (TIMELAYER_FACT_CATEGORY_BUDGETS, e.g. "identity=8,work=5";
+//   "off" disables), in ranking order; room left over is then filled from
+//   the remaining candidates of any category, so a quiet category does not
+//   waste the cap. Always-facts (core / pinned) are not counted.
+// ============================================================
+
+const (
+	factCategoryIdentity   = "identity"
+	factCategoryFamily     = "family"
+	factCategoryWork       = "work"
+	factCategoryHealth     = "health"
+	factCategoryPreference = "preference"
+	factCategoryOther      = "other"
+)
+
+// factCategories in derivation order: the first matching category wins
+// ("我老婆的生日是…" is family, not identity).
+var factCategories = []string{
+	factCategoryHealth,
+	factCategoryFamily,
+	factCategoryWork,
+	factCategoryIdentity,
+	factCategoryPreference,
+	factCategoryOther,
+}
+
+// factCategoryKeywords: CJK keywords match as substrings, ASCII ones as
+// whole words (plural "s" allowed), or as a word prefix when ending in "*".
+var factCategoryKeywords = map[string][]string{
+	factCategoryHealth: {
+		"过敏", "生病", "疾病", "病史", "吃药", "药物", "血压", "血糖", "血型", "医院", "医生", "体检", "健康", "忌口", "素食", "失眠", "手术",
+		"allerg*", "diabet*", "medic*", "doctor", "blood", "diet", "vegetarian", "vegan", "illness", "disease", "surgery", "insomnia",
+	},
+	factCategoryFamily: {
+		"老婆", "妻子", "丈夫", "老公", "儿子", "女儿", "孩子", "爸爸", "妈妈", "父亲", "母亲", "父母", "哥哥", "姐姐", "弟弟", "妹妹", "家人", "爷爷", "奶奶", "外公", "外婆", "宠物",
+		"wife", "husband", "son", "daughter", "kid", "child", "children", "mother", "father", "mom", "dad", "parent", "brother", "sister", "family", "grandma", "grandpa", "pet",
+	},
+	factCategoryWork: {
+		"工作", "上班", "公司", "同事", "老板", "领导", "职位", "职业", "项目", "客户", "部门", "单位", "任职", "就职", "入职", "加班",
+		"work*", "job", "company", "office", "colleague", "coworker", "boss", "manager", "employer", "project", "client", "career",
+	},
+	factCategoryIdentity: {
+		"名字", "我叫", "生日", "出生", "年龄", "岁", "住在", "住址", "家乡", "老家", "国籍", "手机号", "电话", "邮箱", "身份证",
+		"name", "birthday", "born", "age", "live*", "address", "hometown", "phone", "email", "nationality",
+	},
+	factCategoryPreference: {
+		"喜欢", "爱吃", "爱喝", "爱好", "讨厌", "偏好", "习惯", "最爱", "口味", "喜爱", "兴趣",
+		"like", "love", "prefer*", "hate*", "favorite", "favourite", "enjoy*", "hobby", "hobbies",
+	},
+}
+
+// defaultFactCategoryBudgets sums to defaultFactInjectMax.
+func defaultFactCategoryBudgets() map[string]int {
+	return map[string]int{
+		factCategoryIdentity:   8,
+		factCategoryFamily:     5,
+		factCategoryWork:       5,
+		factCategoryHealth:     4,
+		factCategoryPreference: 5,
+		factCategoryOther:      3,
+	}
+}
+
+// normalizeFactCategory returns the known category for s ("" = unknown).
+func normalizeFactCategory(s string) string {
+	s = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "#")))
+	for _, c := range factCategories {
+		if s == c {
+			return c
+		}
+	}
+	return ""
+}
+
+// deriveFactCategory classifies a fact by keywords (other when nothing matches).
+func deriveFactCategory(fact string) string {
+	lower := strings.ToLower(fact)
+	words := strings.FieldsFunc(lower, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r)) || ftsIsCJK(r)
+	})
+	for _, c := range factCategories {
+		for _, kw := range factCategoryKeywords[c] {
+			if factKeywordMatch(lower, words, kw) {
+				return c
+			}
+		}
+	}
+	return factCategoryOther
+}
+
+func factKeywordMatch(lower string, words []string, kw string) bool {
+	if kw == "" {
+		return false
+	}
+	if kw[0] >= 0x80 {
+		return strings.Contains(lower, kw)
+	}
+	prefix := strings.HasSuffix(kw, "*")
+	kw = strings.TrimSuffix(kw, "*")
+	for _, w := range words {
+		if w == kw || w == kw+"s" || (prefix && strings.HasPrefix(w, kw)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFactCategoryBudgets parses "identity=8,work=5" (categories not listed get no reserved share).
+func parseFactCategoryBudgets(v string) (map[string]int, error) {
+	out := map[string]int{}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, num, ok := strings.Cut(part, "=")
+		c := normalizeFactCategory(name)
+		n, err := strconv.Atoi(strings.TrimSpace(num))
+		if !ok || c == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid category budget: %q (want <category>=<n>)", part)
+		}
+		out[c] = n
+	}
+	return out, nil
+}
+
+// applyCategoryBudgets keeps up to room candidates of ranked (in ranking
+// order): each category's budget first, then the best of the rest.
+func applyCategoryBudgets(ranked []factCandidate, room int, budgets map[string]int) []factCandidate {
+	if room <= 0 {
+		return nil
+	}
+	if len(ranked) <= room {
+		return ranked
+	}
+	if len(budgets) == 0 {
+		return ranked[:room]
+	}
+	keep := make([]bool, len(ranked))
+	used := map[string]int{}
+	n := 0
+	for i, c := range ranked {
+		if n >= room {
+			break
+		}
+		cat := c.category
+		if cat == "" {
+			cat = factCategoryOther
+		}
+		if used[cat] < budgets[cat] {
+			used[cat]++
+			keep[i] = true
+			n++
+		}
+	}
+	for i := range ranked {
+		if n >= room {
+			break
+		}
+		if !keep[i] {
+			keep[i] = true
+			n++
+		}
+	}
+	out := make([]factCandidate, 0, room)
+	for i, c := range ranked {
+		if keep[i] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ensureFactCategorySchema adds user_facts.category for older DBs and
+// derives it for rows that have none (best-effort).
+func ensureFactCategorySchema(db *sql.DB) error {
+	if db == nil {
+		return nil
+	}
+	if err := addColumnIfMissing(db, "user_facts", "category", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	rows, err := db.Query(`SELECT id, fact FROM user_facts WHERE category=''`)
+	if err != nil {
+		return err
+	}
+	cats := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var fact string
+		if rows.Scan(&id, &fact) == nil {
+			cats[id] = deriveFactCategory(fact)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(cats) == 0 {
+		return err
+	}
+	return withTx(db, func(tx *sql.Tx) error {
+		for id, c := range cats {
+			if _, err := tx.Exec(`UPDATE user_facts SET category=? WHERE id=?`, c, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetFactCategory sets the category of an active fact.
+func SetFactCategory(cfg Config, db *sql.DB, factKey, category string) error {
+	factKey = strings.TrimSpace(factKey)
+	if db == nil || factKey == "" {
+		return errors.New("fact not found")
+	}
+	c := normalizeFactCategory(category)
+	if c == "" {
+		return fmt.Errorf("unknown category: %s (want %s)", category, strings.Join(factCategories, "|"))
+	}
+	return withDBRetry(3, 25*time.Millisecond, func() error {
+		res, err := db.Exec(`UPDATE user_facts SET category=? WHERE fact_key=? AND is_active=1`, c, factKey)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errors.New("fact not found")
+		}
+		bumpMemoryVersion()
+		return nil
+	})
+}
+
+// FactCategoryCount is one category of GET /api/facts/categories.
+type FactCategoryCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+	Budget   int    `json:"budget"`
+}
+
+// ListFactCategories counts user's active facts per category (every known category, in order).
+func ListFactCategories(cfg Config, db *sql.DB, user string) ([]FactCategoryCount, error) {
+	counts := map[string]int{}
+	if db != nil {
+		rows, err := readDB(db).Query(`SELECT category, COUNT(*) FROM user_facts WHERE is_active=1 AND user_id=? GROUP BY category`, user)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var c string
+			var n int
+			if rows.Scan(&c, &n) == nil {
+				if c = normalizeFactCategory(c); c == "" {
+					c = factCategoryOther
+				}
+				counts[c] += n
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]FactCategoryCount, 0, len(factCategories))
+	for _, c := range factCategories {
+		out = append(out, FactCategoryCount{Category: c, Count: counts[c], Budget: cfg.FactCategoryBudgets[c]})
+	}
+	return out, nil
+}
+
+// parseRememberTag splits "/remember --tag <category> <fact>" (also --tag=<category>).
+func parseRememberTag(arg string) (category, rest string, err error) {
+	arg = strings.TrimSpace(arg)
+	if !strings.HasPrefix(arg, "--tag") {
+		return "", arg, nil
+	}
+	fields := strings.Fields(arg)
+	var name string
+	switch {
+	case strings.HasPrefix(fields[0], "--tag="):
+		name = strings.TrimPrefix(fields[0], "--tag=")
+		rest = strings.TrimSpace(strings.TrimPrefix(arg, fields[0]))
+	case fields[0] == "--tag" && len(fields) >= 2:
+		name = fields[1]
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(arg, "--tag")), fields[1]))
+	default:
+		return "", arg, nil
+	}
+	if category = normalizeFactCategory(name); category == "" {
+		return "", rest, fmt.Errorf("unknown category: %s (want %s)", name, strings.Join(factCategories, "|"))
+	}
+	return category, rest, nil
+}
+
+// rememberTagged is /remember with an optional --tag <category> (CLI and web).
+// The category applies when the fact is remembered; a conflict is resolved later
+// and then keeps the derived category.
+func rememberTagged(lw *LogWriter, cfg Config, db *sql.DB, arg string) (*RememberOutcome, error) {
+	category, content, err := parseRememberTag(arg)
+	if err != nil {
+		return nil, err
+	}
+	out, err := RememberFactWithOutcome(lw, cfg, db, content)
+	if err != nil || category == "" || out == nil || out.Status != "remembered" || out.FactKey == "" {
+		return out, err
+	}
+	return out, SetFactCategory(cfg, db, out.FactKey, category)
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Clarifying ambiguous implicit fact candidates (opt-in)
+// - TIMELAYER_FACT_CLARIFY=true: an implicit candidate that reads two ways
+//   is not dropped into pending. The assistant appends ONE short question to
+//   its answer instead, and the next user turn resolves it:
+//     叫 + pronoun   "我叫他老王"            is 老王 the user's name, or what
+//                                             they call someone?
+//     hedged value   "我的生日好像是5月3日"    sure?
+//     two values     "…颜色是红色或者蓝色"     which one?
+// - The reply picks an option ("1" / "2" / 是 / 不用) or restates the fact
+//   ("我叫王建国"). The chosen fact goes through ProposePendingRememberFact
+//   (source_type "clarified", confidence 1.0, the exchange as evidence).
+//   A bare option reply is acknowledged without a model call; any other
+//   reply drops the question and the turn runs as usual.
+// - At most one question per turn and one open at a time. In-process state:
+//   a restart (or factClarifyTTL) forgets the question.
+// ============================================================
+
+const (
+	sourceTypeClarified = "clarified"
+	clarifiedConfidence = 1.0
+	factClarifyTTL      = 30 * time.Minute
+)
+
+// factClarification is an open question about one implicit candidate.
+type factClarification struct {
+	Candidate string
+	Question  string
+	Options   []string // facts to record; a reply of len(Options)+1 (or "no") records nothing
+	AskedAt   time.Time
+}
+
+var factClarify struct {
+	sync.Mutex
+	open *factClarification
+}
+
+var (
+	clarifyHedges        = []string{"好像", "可能", "大概", "也许", "应该", "估计", "似乎"}
+	clarifyThirdPersons  = []string{"他们", "她们", "他", "她", "它", "人家", "别人"}
+	clarifyAlternatives  = []string{"或者", "还是", "或", "/"}
+	clarifyYesReplies    = []string{"是", "是的", "对", "对的", "嗯", "确定", "没错", "好", "yes", "y", "ok"}
+	clarifyNoReplies     = []string{"不", "不是", "不对", "不用", "不用了", "都不是", "算了", "不确定", "别记", "no", "n"}
+	clarifyOrdinalPrefix = []string{"第一", "第二", "第三", "第四"}
+)
+
+// ambiguousFactCandidate returns the question to ask about fact; ok=false when it reads one way.
+func ambiguousFactCandidate(fact string) (*factClarification, bool) {
+	tr := ExtractFactTriple(fact)
+	if tr.Object == "" {
+		return nil, false
+	}
+
+	// 叫 + pronoun: the user's name, or what they call someone
+	if tr.RelationKey == "rel:name" && isUserSubject(tr.Subject) {
+		for _, p := range clarifyThirdPersons {
+			if name := strings.TrimPrefix(tr.Object, p); name != tr.Object && name != "" {
+				return &factClarification{
+					Candidate: fact,
+					Question:  fmt.Sprintf("想确认一下：“%s”是你的名字，还是你对别人的称呼？（回复 1 = 我的名字，2 = 称呼别人）", name),
+					Options:   []string{"我叫" + name},
+				}, true
+			}
+		}
+	}
+
+	// hedged value: ask before recording it as certain
+	for _, h := range clarifyHedges {
+		if !strings.Contains(fact, h) {
+			continue
+		}
+		sure := strings.Replace(fact, h, "", 1)
+		if ExtractFactTriple(sure).Object == "" {
+			break
+		}
+		return &factClarification{
+			Candidate: fact,
+			Question:  fmt.Sprintf("“%s”——确定吗？（回复 1 = 确定，2 = 先不记）", sure),
+			Options:   []string{sure},
+		}, true
+	}
+
+	// two or three alternative values
+	for _, sep := range clarifyAlternatives {
+		parts := strings.Split(tr.Object, sep)
+		if len(parts) < 2 || len(parts) > 3 {
+			continue
+		}
+		i := strings.LastIndex(fact, tr.Object)
+		if i < 0 {
+			break
+		}
+		var opts, labels []string
+		for _, p := range parts {
+			if p = strings.TrimSpace(p); p == "" {
+				return nil, false
+			}
+			opts = append(opts, fact[:i]+p+fact[i+len(tr.Object):])
+			labels = append(labels, fmt.Sprintf("%d = %s", len(opts), p))
+		}
+		labels = append(labels, fmt.Sprintf("%d = 都不是", len(opts)+1))
+		return &factClarification{
+			Candidate: fact,
+			Question:  fmt.Sprintf("想确认一下，是哪一个？（回复 %s）", strings.Join(labels, "，")),
+			Options:   opts,
+		}, true
+	}
+	return nil, false
+}
+
+// openFactClarification records c as the open question (replacing an older one).
+func openFactClarification(c *factClarification, now time.Time) {
+	c.AskedAt = now
+	factClarify.Lock()
+	factClarify.open = c
+	factClarify.Unlock()
+}
+
+// takeFactClarification pops the open question (nil when none or expired).
+func takeFactClarification(now time.Time) *factClarification {
+	factClarify.Lock()
+	defer factClarify.Unlock()
+	c := factClarify.open
+	factClarify.open = nil
+	if c == nil || now.Sub(c.AskedAt) > factClarifyTTL {
+		return nil
+	}
+	return c
+}
+
+// parseClarifyReply maps a short reply to an option: 1..n, or 0 = record nothing.
+func parseClarifyReply(reply string, n int) (int, bool) {
+	r := strings.ToLower(strings.TrimSpace(strings.TrimRight(strings.TrimSpace(reply), "。.!！~～")))
+	if r == "" {
+		return 0, false
+	}
+	if containsString(clarifyNoReplies, r) {
+		return 0, true
+	}
+	if n == 1 && containsString(clarifyYesReplies, r) {
+		return 1, true
+	}
+	pick := 0
+	switch {
+	case len(r) == 1 && r[0] >= '1' && r[0] <= '9':
+		pick = int(r[0] - '0')
+	case r == "前者":
+		pick = 1
+	case r == "后者":
+		pick = 2
+	default:
+		for i, p := range clarifyOrdinalPrefix {
+			if strings.HasPrefix(r, p) && len([]rune(r)) <= len([]rune(p))+1 {
+				pick = i + 1
+				break
+			}
+		}
+	}
+	switch {
+	case pick >= 1 && pick <= n:
+		return pick, true
+	case pick == n+1:
+		return 0, true
+	}
+	return 0, false
+}
+
+// recordClarifiedFact proposes fact with the clarification exchange as provenance.
+func recordClarifiedFact(cfg Config, db *sql.DB, c *factClarification, fact, reply string, now time.Time) error {
+	date := now.Format("2006-01-02")
+	st, err := ProposePendingRememberFact(cfg, db, fact, sourceTypeClarified, date, now)
+	if err != nil || st == nil || st.Status != "pending" {
+		return err
+	}
+	ev, _ := json.Marshal(map[string]string{
+		"candidate": c.Candidate,
+		"question":  c.Question,
+		"user":      strings.TrimSpace(reply),
+	})
+	_, err = db.Exec(`
+		UPDATE pending_facts SET confidence=?, evidence=?
+		WHERE fact_key=? AND status='pending' AND source_type=? AND source_key=?
+	`, clarifiedConfidence, string(ev), st.FactKey, sourceTypeClarified, date)
+	return err
+}
+
+// resolveFactClarification treats input as the answer to the open question.
+// handled: input answered it (skip implicit capture); reply != "": answer the
+// turn with it instead of calling the model.
+func resolveFactClarification(cfg Config, db *sql.DB, input string, now time.Time) (handled bool, reply string, err error) {
+	if !cfg.FactClarify || db == nil || strings.HasPrefix(strings.TrimSpace(input), "/") {
+		return false, "", nil
+	}
+	c := takeFactClarification(now)
+	if c == nil {
+		return false, "", nil
+	}
+	if pick, ok := parseClarifyReply(input, len(c.Options)); ok {
+		if pick == 0 {
+			return true, "好的，那就先不记。", nil
+		}
+		return true, "好的。", recordClarifiedFact(cfg, db, c, c.Options[pick-1], input, now)
+	}
+	// restated ("我叫王建国"): record it as said, the model answers as usual
+	fact := strings.TrimSpace(strings.TrimRight(input, "。.!！"))
+	if n := len([]rune(fact)); n >= 4 && n <= 140 && looksLikeSelfStatement(fact) && ExtractFactTriple(fact).Object != "" {
+		if _, amb := ambiguousFactCandidate(fact); !amb {
+			return true, "", recordClarifiedFact(cfg, db, c, fact, input, now)
+		}
+	}
+	return false, "", nil
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================================================
+// Conflict explanation
+// - A conflict outcome used to carry only the existing text. Conflict
+//   (RememberOutcome.Conflict) says why the two facts collide:
+//     method "key":  same fact_key (derived from the subject)
+//     method "slot": same (subject, relation) slot, e.g. 我 | birthday
+//   plus the canonical values on both sides (05-03 vs 05-04, phones as
+//   digits, ...) and a one-line explanation for the CLI / web UI.
+// ============================================================
+
+type FactConflictDetail struct {
+	Method        string `json:"method"`             // key | slot
+	Subject       string `json:"subject,omitempty"`  // as written, e.g. 我 / 老婆
+	Relation      string `json:"relation,omitempty"` // canonical, e.g. birthday / phone
+	ExistingValue string `json:"existing_value"`     // normalized; the whole fact when no value was extracted
+	NewValue      string `json:"new_value"`
+	Explanation   string `json:"explanation"`
+}
+
+// explainFactConflict describes why proposed conflicts with the active fact existing.
+func explainFactConflict(method, existing, proposed string) *FactConflictDetail {
+	ex, tr := ExtractFactTriple(existing), ExtractFactTriple(proposed)
+	d := &FactConflictDetail{
+		Method:        method,
+		ExistingValue: conflictValue(ex, existing),
+		NewValue:      conflictValue(tr, proposed),
+	}
+	if tr.SubjectKey != "" && tr.SubjectKey == ex.SubjectKey {
+		d.Subject = strings.TrimSpace(tr.Subject)
+	}
+	if tr.RelationKey != "" && tr.RelationKey == ex.RelationKey {
+		d.Relation = strings.TrimPrefix(tr.RelationKey, "rel:")
+	}
+
+	switch {
+	case d.Subject != "" && d.Relation != "":
+		d.Explanation = fmt.Sprintf("「%s」的 %s 已记住为「%s」，新的说法是「%s」；这个槽位只保留一个值。",
+			d.Subject, d.Relation, d.ExistingValue, d.NewValue)
+	case d.Subject != "":
+		d.Explanation = fmt.Sprintf("关于「%s」已记住「%s」，新的说法「%s」与它不同。", d.Subject, existing, proposed)
+	default:
+		d.Explanation = fmt.Sprintf("已记住「%s」，新的说法「%s」与它不同。", existing, proposed)
+	}
+	return d
+}
+
+// conflictValue is the canonical value of a fact, or its text when none was extracted.
+func conflictValue(t FactTriple, text string) string {
+	if v := strings.TrimSpace(t.ObjectNorm); v != "" {
+		return v
+	}
+	if v := strings.TrimSpace(t.Object); v != "" {
+		return v
+	}
+	return strings.TrimSpace(text)
+}
+
+// conflictMessage is the /remember reply for a conflict outcome (CLI and web).
+func conflictMessage(o *RememberOutcome) string {
+	const tail = "已进入 FACTS -> CONFLICTS，处理后才会晋升为长期事实。"
+	if o == nil || o.Conflict == nil {
+		return "[conflict] " + tail
+	}
+	d := o.Conflict
+	return fmt.Sprintf("[conflict] %s\n  - %s\n  + %s\n%s", d.Explanation, d.ExistingValue, d.NewValue, tail)
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ============================================================
+// Conflict adjudication suggestions (LLM)
+// - POST /api/facts/conflicts/:id/suggest asks the chat model to compare the
+//   active fact with the proposed one and returns
+//     {"ok":true,"suggestion":{"action":"keep|replace|merge","rationale":"...","merged":"..."}}
+//   merged is set for merge only: one fact text that holds both values.
+// - Advisory only: nothing is resolved here. The FACTS → CONFLICTS panel
+//   shows the suggestion and applies it after the user confirms, through the
+//   normal keep / replace endpoints (merge = replace with merged as the
+//   replacement).
+// - Not background work, so the LLM budget (llm_budget.go) does not apply.
+// ============================================================
+
+var (
+	errConflictNotFound = errors.New("conflict not found")
+	errConflictResolved = errors.New("conflict is already resolved")
+)
+
+// FactConflictSuggestion is the model's advice for one conflict.
+type FactConflictSuggestion struct {
+	ConflictID int64  `json:"conflict_id"`
+	Action     string `json:"action"` // keep | replace | merge
+	Rationale  string `json:"rationale"`
+	Merged     string `json:"merged,omitempty"`
+}
+
+// SuggestFactConflict asks the chat model how conflict id should be resolved.
+func SuggestFactConflict(cfg Config, db *sql.DB, id int64) (*FactConflictSuggestion, error) {
+	c, err := getFactConflictByID(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, errConflictNotFound
+	}
+	if c.Status != "conflict" {
+		return nil, errConflictResolved
+	}
+	current, ok := getActiveUserFactByKey(db, c.FactKey)
+	if !ok {
+		current = c.ExistingFact
+	}
+	var existingSrc, existingAt string
+	_ = readDB(db).QueryRow(`
+		SELECT source_type, created_at FROM user_facts_history
+		WHERE fact_key=? AND status='active'
+		ORDER BY version DESC, id DESC LIMIT 1
+	`, c.FactKey).Scan(&existingSrc, &existingAt)
+
+	out, err := callLLMNonStream(cfg, buildConflictSuggestPrompt(cfg, c, current, existingSrc, existingAt))
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseConflictSuggestion(out)
+	if err != nil {
+		return nil, err
+	}
+	s.ConflictID = c.ID
+	return s, nil
+}
+
+func buildConflictSuggestPrompt(cfg Config, c *UserFactConflict, current, existingSrc, existingAt string) string {
+	d := explainFactConflict("", current, c.ProposedFact)
+	var b strings.Builder
+	b.WriteString("You help the user resolve a conflict in their long-term memory about themselves.\n")
+	b.WriteString("An active fact and a newly proposed fact disagree; only one text can be kept for this fact.\n\n")
+	fmt.Fprintf(&b, "ACTIVE: %s\n  (set by %s at %s)\n", current, orDash(existingSrc), orDash(existingAt))
+	fmt.Fprintf(&b, "PROPOSED: %s\n  (from %s %s at %s)\n", c.ProposedFact, orDash(c.ProposedSourceType), c.ProposedSourceKey, c.CreatedAt)
+	fmt.Fprintf(&b, "DIFFERENCE: %s\n\n", d.Explanation)
+	b.WriteString("Choose one action:\n")
+	b.WriteString("- keep: the active fact is still right (the proposal is a mistake, a guess or a passing remark)\n")
+	b.WriteString("- replace: the proposal is a correction or a later change that supersedes the active fact\n")
+	b.WriteString("- merge: both are true at once; write one fact that states both\n")
+	b.WriteString("A fact set by hand (remember, remember_cli, remember_ui) is more reliable than one extracted from chat.\n")
+	fmt.Fprintf(&b, "Write the rationale in one or two sentences in %s.\n", outputLanguageName(cfg.OutputLanguage))
+	b.WriteString(`Reply with JSON only: {"action":"keep|replace|merge","rationale":"...","merged":"..."} (merged only for merge).`)
+	return b.String()
+}
+
+// parseConflictSuggestion reads the model reply (a code fence or text around
+// the JSON object is tolerated).
+func parseConflictSuggestion(out string) (*FactConflictSuggestion, error) {
+	i, j := strings.Index(out, "{"), strings.LastIndex(out, "}")
+	if i < 0 || j < i {
+		return nil, fmt.Errorf("suggestion is not JSON: %q", clipRunes(out, 80))
+	}
+	var s FactConflictSuggestion
+	if err := json.Unmarshal([]byte(out[i:j+1]), &s); err != nil {
+		return nil, fmt.Errorf("suggestion is not JSON: %q", clipRunes(out, 80))
+	}
+	s.Action = strings.ToLower(strings.TrimSpace(s.Action))
+	s.Rationale, s.Merged = strings.TrimSpace(s.Rationale), strings.TrimSpace(s.Merged)
+	switch s.Action {
+	case "keep", "replace":
+		s.Merged = ""
+	case "merge":
+		if s.Merged == "" {
+			return nil, errors.New("merge suggestion without merged text")
+		}
+	default:
+		return nil, fmt.Errorf("invalid suggested action %q", s.Action)
+	}
+	return &s, nil
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Core facts (always-on memory)
+// - user_facts.is_core marks identity-level facts (name, family,
+//   occupation, ...) that go into every chat turn; all other facts pass
+//   the relevance filter (fact_relevance.go).
+// - The flag lives on the fact_key row, so replacing a core fact's value
+//   keeps it core.
+// - Set via /core <fact> and /uncore <fact> (CLI / web), /core lists them;
+//   API: GET / POST /api/facts/core.
+// ============================================================
+
+// SetFactCore marks (or unmarks) an active fact as core.
+func SetFactCore(cfg Config, db *sql.DB, factKey string, core bool) error {
+	factKey = strings.TrimSpace(factKey)
+	if db == nil || factKey == "" {
+		return errors.New("fact not found")
+	}
+	v := 0
+	if core {
+		v = 1
+	}
+	return withDBRetry(3, 25*time.Millisecond, func() error {
+		res, err := db.Exec(`UPDATE user_facts SET is_core=? WHERE fact_key=? AND is_active=1`, v, factKey)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errors.New("fact not found")
+		}
+		return nil
+	})
+}
+
+// ListCoreFacts lists the active core facts (newest first).
+func ListCoreFacts(db *sql.DB, user string) ([]UserFactRow, error) {
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := readDB(db).Query(`SELECT fact_key, fact, COALESCE(category,''), created_at, updated_at, `+factUsageCols+`
+FROM user_facts
+WHERE is_active = 1 AND is_core = 1 AND user_id = ?
+ORDER BY updated_at DESC`, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UserFactRow
+	for rows.Next() {
+		r := UserFactRow{IsActive: true, IsCore: true}
+		if err := rows.Scan(&r.FactKey, &r.Fact, &r.Category, &r.CreatedAt, &r.UpdatedAt, &r.InjectCount, &r.SlotHits, &r.LastUsedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachFactTags(db, out), nil
+}
+
+// runFactCoreCommand implements /core and /uncore for both CLI and web.
+//
+//	/core <fact or fact_key>     (always inject this fact)
+//	/uncore <fact or fact_key>   (back to relevance filtering)
+//	/core                        (list core facts)
+func runFactCoreCommand(cfg Config, db *sql.DB, cmd, arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	if cmd == "/core" && arg == "" {
+		items, err := ListCoreFacts(db, cfg.User)
+		if err != nil {
+			return "", err
+		}
+		if len(items) == 0 {
+			return "no core facts yet", nil
+		}
+		var b strings.Builder
+		for _, it := range items {
+			b.WriteString("- " + it.Fact + "\n")
+		}
+		return strings.TrimRight(b.String(), "\n"), nil
+	}
+	if arg == "" {
+		return "usage: " + cmd + " <fact>", nil
+	}
+	key := resolveFactKeyForTagging(db, cfg.User, arg)
+	if key == "" {
+		return "[noop] fact not found", nil
+	}
+	if err := SetFactCore(cfg, db, key, cmd == "/core"); err != nil {
+		return "", err
+	}
+	if cmd == "/core" {
+		return "[ok] core fact: always injected", nil
+	}
+	return "[ok] no longer core", nil
+}
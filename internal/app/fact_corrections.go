@@ -0,0 +1,158 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Corrections → pending facts
+// "不对，我的生日是5月3日" right after the assistant said something about the
+// user's birthday is a correction; the regular implicit path misses it (it
+// only looks at lines starting with "我").
+// Heuristic (conservative):
+//   1. the user line starts with a negation / correction marker;
+//   2. the rest parses as a self fact with a slot value (ExtractFactTriple);
+//   3. the previous assistant message talks about that slot (mentions the
+//      attribute, or the active fact's old value) but NOT the new value.
+// The corrected fact goes through ProposePendingRememberFact (source_type
+// "correction"; a clash with an active fact becomes a conflict), and the
+// exchange is kept as provenance in pending_facts.evidence.
+// ============================================================
+
+const sourceTypeCorrection = "correction"
+
+// correctionPrefixes are matched case-insensitively at the start of the user line.
+var correctionPrefixes = []string{
+	"你记错了", "你说错了", "你搞错了", "记错了", "说错了", "搞错了", "不对的", "不对", "错了", "不是的", "不是",
+	"that's wrong", "that is wrong", "wrong", "nope", "no", "actually",
+}
+
+// stripCorrectionPrefix returns the statement after a correction marker.
+func stripCorrectionPrefix(text string) (string, bool) {
+	t := strings.TrimSpace(text)
+	low := strings.ToLower(t)
+	for _, p := range correctionPrefixes {
+		if !strings.HasPrefix(low, p) {
+			continue
+		}
+		rest := t[len(p):]
+		// "no" must be a word of its own ("no, my ..." but not "nothing")
+		if r := strings.TrimLeft(rest, " "); p[0] < 0x80 && rest != "" && rest == r && !strings.ContainsAny(rest[:1], ",.!;") {
+			continue
+		}
+		rest = strings.TrimLeft(rest, " ，,。.!！;；:：—-")
+		for _, filler := range []string{"其实", "应该是说", "我是说"} {
+			rest = strings.TrimPrefix(rest, filler)
+		}
+		rest = strings.TrimSpace(strings.TrimLeft(rest, "，,"))
+		if rest == "" {
+			return "", false
+		}
+		return rest, true
+	}
+	return "", false
+}
+
+// correctionAttribute is the slot name the assistant would have mentioned ("生日" / "birthday").
+func correctionAttribute(tr FactTriple) string {
+	if strings.HasPrefix(strings.ToLower(tr.Subject), "my ") {
+		return strings.TrimSpace(tr.Subject[3:])
+	}
+	a := strings.TrimSpace(tr.Relation)
+	for _, suf := range []string{"就是", "是", "为", "叫"} {
+		a = strings.TrimSuffix(a, suf)
+	}
+	return strings.TrimSpace(a)
+}
+
+func isUserSubject(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "我" || s == "i" || strings.HasPrefix(s, "my ")
+}
+
+// detectCorrection returns the corrected fact when userText corrects prevAssistant.
+// oldValue (optional) is the current active value for the slot.
+func detectCorrection(userText, prevAssistant, oldValue string) (string, bool) {
+	rest, ok := stripCorrectionPrefix(userText)
+	if !ok || prevAssistant == "" {
+		return "", false
+	}
+	fact := strings.TrimSpace(strings.TrimRight(rest, "。.!！"))
+	if n := len([]rune(fact)); n < 4 || n > 140 {
+		return "", false
+	}
+	tr := ExtractFactTriple(fact)
+	if tr.Object == "" || !isUserSubject(tr.Subject) {
+		return "", false
+	}
+	attr := correctionAttribute(tr)
+	prev := strings.ToLower(prevAssistant)
+	if strings.Contains(prev, strings.ToLower(tr.Object)) {
+		return "", false // the assistant already had the new value
+	}
+	mentionsOld := oldValue != "" && strings.Contains(prev, strings.ToLower(oldValue))
+	mentionsSlot := attr != "" && strings.Contains(prev, strings.ToLower(attr))
+	if !mentionsOld && !mentionsSlot {
+		return "", false
+	}
+	return fact, true
+}
+
+// lastAssistantMessage returns the latest assistant message of date (before the current user line).
+func lastAssistantMessage(cfg Config, db *sql.DB, date string) string {
+	lines, err := loadRawLinesForDate(cfg, db, date)
+	if err != nil {
+		return ""
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i].Role == "assistant" {
+			return strings.TrimSpace(lines[i].Content)
+		}
+	}
+	return ""
+}
+
+// maybeProposeCorrectionFromUserInput is the correction counterpart of
+// maybeAutoProposePendingFromUserInput (silent, best-effort).
+// handled reports that input was recognised as a correction.
+func maybeProposeCorrectionFromUserInput(cfg Config, db *sql.DB, input string, now time.Time) (handled bool, err error) {
+	if db == nil || strings.HasPrefix(strings.TrimSpace(input), "/") {
+		return false, nil
+	}
+	rest, ok := stripCorrectionPrefix(input)
+	if !ok {
+		return false, nil
+	}
+	date := now.Format("2006-01-02")
+	prev := lastAssistantMessage(cfg, db, date)
+
+	oldValue := ""
+	if slot := ExtractFactTriple(rest).SlotKey(); slot != "" {
+		if _, existing, ok := getActiveUserFactBySlotKey(db, cfg.User, slot); ok {
+			oldValue = ExtractFactTriple(existing).Object
+		}
+	}
+	fact, ok := detectCorrection(input, prev, oldValue)
+	if !ok {
+		return false, nil
+	}
+
+	st, err := ProposePendingRememberFact(cfg, db, fact, sourceTypeCorrection, date, now)
+	if err != nil {
+		return true, err
+	}
+	if st != nil && st.Status == "pending" {
+		ev, _ := json.Marshal(map[string]string{
+			"assistant": clipRunes(prev, 300),
+			"user":      strings.TrimSpace(input),
+		})
+		_, _ = db.Exec(`
+			UPDATE pending_facts SET evidence=?
+			WHERE fact_key=? AND status='pending' AND source_type=? AND source_key=?
+		`, string(ev), st.FactKey, sourceTypeCorrection, date)
+	}
+	return true, nil
+}
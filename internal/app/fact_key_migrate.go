@@ -0,0 +1,330 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// ============================================================
+// fact_key backfill (startup migration)
+// deriveFactKeyFromSubject has changed over time; rows written by older
+// versions keep keys that new inserts no longer derive, so exact-key conflict
+// detection misses them. On every open (cheap when nothing is stale):
+//   1. recompute the key of every user_facts row;
+//   2. rows that now share a key collide. The winner (active first, then
+//      already-canonical, then most recently updated) keeps the key; the others:
+//        - same value             → merged (row dropped; sameFactValue)
+//        - active, different text → conflict pool (source_type "migration")
+//        - inactive, different    → archived (row dropped, history kept)
+//   3. tags / history / conflicts / fact search docs follow the key;
+//   4. every change is recorded in fact_key_migrations (old_key → new_key).
+// Stale keys are first moved to temporary keys so chained renames
+// (A→B while B→C) never hit UNIQUE(fact_key). One transaction.
+// pending_facts keys are recomputed too (best-effort, UPDATE OR IGNORE).
+// A key keeps its "@user:<name>" suffix (users.go).
+// ============================================================
+
+const factKeyMigrationSource = "migration"
+
+type FactKeyMigrationReport struct {
+	Rekeyed   int                `json:"rekeyed"`
+	Merged    int                `json:"merged"`
+	Conflicts int                `json:"conflicts"`
+	Archived  int                `json:"archived"`
+	Pending   int                `json:"pending"`
+	Changes   []FactKeyMigration `json:"changes,omitempty"`
+}
+
+// FactKeyMigration is one fact_key_migrations row.
+type FactKeyMigration struct {
+	UserFactID int64  `json:"user_fact_id"`
+	OldKey     string `json:"old_key"`
+	NewKey     string `json:"new_key"`
+	Fact       string `json:"fact"`
+	Action     string `json:"action"` // rekeyed | merged | conflict | archived
+	MergedInto int64  `json:"merged_into,omitempty"`
+	ConflictID int64  `json:"conflict_id,omitempty"`
+}
+
+func (r FactKeyMigrationReport) changed() bool {
+	return r.Rekeyed+r.Merged+r.Conflicts+r.Archived+r.Pending > 0
+}
+
+type factKeyRow struct {
+	id        int64
+	fact      string
+	key       string
+	newKey    string
+	active    bool
+	updatedAt string
+}
+
+func (r factKeyRow) stale() bool { return r.key != r.newKey }
+
+// migrateUserFactKeys is the startup entry point (best-effort, logs what it did).
+func migrateUserFactKeys(db *sql.DB, cfg Config) {
+	if db == nil {
+		return
+	}
+	now := time.Now()
+	if cfg.Location != nil {
+		now = now.In(cfg.Location)
+	}
+	rep, err := MigrateUserFactKeys(db, now)
+	if err != nil {
+		log.Printf("[warn] fact_key migration failed (unchanged): %v", err)
+		return
+	}
+	if rep.changed() {
+		log.Printf("[migrate] fact_key: rekeyed=%d merged=%d conflicts=%d archived=%d pending=%d (see fact_key_migrations)",
+			rep.Rekeyed, rep.Merged, rep.Conflicts, rep.Archived, rep.Pending)
+	}
+}
+
+// errFactKeyDryRun rolls back a dry-run migration.
+var errFactKeyDryRun = errors.New("fact_key dry run")
+
+// MigrateUserFactKeys recomputes fact keys with the current deriveFactKeyFromSubject.
+func MigrateUserFactKeys(db *sql.DB, now time.Time) (FactKeyMigrationReport, error) {
+	return migrateFactKeys(db, now, deriveFactKeyFromSubject, false)
+}
+
+// migrateFactKeys runs the migration with derive; dryRun reports what would
+// change (conflict ids are not final) and rolls everything back.
+func migrateFactKeys(db *sql.DB, now time.Time, derive func(string) string, dryRun bool) (FactKeyMigrationReport, error) {
+	var rep FactKeyMigrationReport
+	err := withTx(db, func(tx *sql.Tx) error {
+		rep = FactKeyMigrationReport{}
+		rows, err := loadFactKeyRows(tx, derive)
+		if err != nil {
+			return err
+		}
+
+		groups := map[string][]factKeyRow{}
+		var order []string
+		for _, r := range rows {
+			if _, ok := groups[r.newKey]; !ok {
+				order = append(order, r.newKey)
+			}
+			groups[r.newKey] = append(groups[r.newKey], r)
+		}
+
+		// phase 1: park every stale key on a temporary key
+		for _, r := range rows {
+			if !r.stale() {
+				continue
+			}
+			tmp := factKeyTemp(r.key)
+			if _, err := tx.Exec(`UPDATE user_facts SET fact_key=? WHERE id=?`, tmp, r.id); err != nil {
+				return err
+			}
+			if err := rekeyFactRefs(tx, r.key, tmp); err != nil {
+				return err
+			}
+		}
+
+		// phase 2: resolve each target key
+		for _, newKey := range order {
+			g := groups[newKey]
+			if len(g) == 1 && !g[0].stale() {
+				continue
+			}
+			sort.SliceStable(g, func(i, j int) bool {
+				if g[i].active != g[j].active {
+					return g[i].active
+				}
+				if g[i].stale() != g[j].stale() {
+					return !g[i].stale()
+				}
+				return g[i].updatedAt > g[j].updatedAt
+			})
+			win := g[0]
+
+			// losers first: their rows must leave before the winner takes the key
+			for _, l := range g[1:] {
+				action := "merged"
+				var cid int64
+				switch {
+				case sameFactValue(l.fact, win.fact):
+					rep.Merged++
+				case l.active && win.active:
+					action = "conflict"
+					rep.Conflicts++
+				default:
+					action = "archived"
+					rep.Archived++
+				}
+				if _, err := tx.Exec(`DELETE FROM user_facts WHERE id=?`, l.id); err != nil {
+					return err
+				}
+				from := l.key
+				if l.stale() {
+					from = factKeyTemp(l.key)
+				}
+				// a loser is not the truth: drop its search doc, keep the rest
+				if _, err := tx.Exec(`DELETE FROM summaries WHERE type='fact' AND period_key=?`, "fact:"+from); err != nil {
+					return err
+				}
+				if action == "conflict" {
+					if cid, err = createUserFactConflict(tx, newKey, win.fact, l.fact, factKeyMigrationSource, l.key, now); err != nil {
+						return err
+					}
+				}
+				if action != "merged" {
+					status := "archived"
+					if action == "conflict" {
+						status = "conflict"
+					}
+					if err := appendUserFactHistory(tx, from, l.fact, status, factKeyMigrationSource, l.key, now, 0); err != nil {
+						return err
+					}
+				}
+				if l.stale() {
+					if err := rekeyFactRefs(tx, from, newKey); err != nil {
+						return err
+					}
+				}
+				into := win.id
+				if action == "conflict" {
+					into = 0 // still its own proposal, see conflict_id
+				}
+				if err := recordFactKeyMigration(tx, &rep, l, action, into, cid, now); err != nil {
+					return err
+				}
+			}
+
+			if win.stale() {
+				if _, err := tx.Exec(`UPDATE user_facts SET fact_key=? WHERE id=?`, newKey, win.id); err != nil {
+					return err
+				}
+				if err := rekeyFactRefs(tx, factKeyTemp(win.key), newKey); err != nil {
+					return err
+				}
+				if err := recordFactKeyMigration(tx, &rep, win, "rekeyed", 0, 0, now); err != nil {
+					return err
+				}
+				rep.Rekeyed++
+			}
+		}
+
+		n, err := rekeyPendingFacts(tx, derive)
+		rep.Pending = n
+		if err == nil && dryRun {
+			return errFactKeyDryRun
+		}
+		return err
+	})
+	if errors.Is(err, errFactKeyDryRun) {
+		err = nil
+	}
+	return rep, err
+}
+
+func factKeyTemp(key string) string { return "migrating:" + key }
+
+func loadFactKeyRows(tx *sql.Tx, derive func(string) string) ([]factKeyRow, error) {
+	rows, err := tx.Query(`SELECT id, fact, fact_key, is_active, COALESCE(updated_at,'') FROM user_facts ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []factKeyRow
+	for rows.Next() {
+		var r factKeyRow
+		var active int
+		if err := rows.Scan(&r.id, &r.fact, &r.key, &active, &r.updatedAt); err != nil {
+			return nil, err
+		}
+		r.active = active == 1
+		r.newKey = userFactKey(keyUser(r.key), derive(r.fact))
+		if r.newKey == "" {
+			r.newKey = r.key
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// rekeyFactRefs moves everything keyed by a fact key (tags, history, conflicts,
+// the "fact:<key>" search doc). Rows that already exist under `to` win.
+func rekeyFactRefs(tx *sql.Tx, from, to string) error {
+	if from == to {
+		return nil
+	}
+	stmts := []struct {
+		q    string
+		args []any
+	}{
+		{`UPDATE OR IGNORE user_fact_tags SET fact_key=? WHERE fact_key=?`, []any{to, from}},
+		{`DELETE FROM user_fact_tags WHERE fact_key=?`, []any{from}},
+		{`UPDATE user_facts_history SET fact_key=? WHERE fact_key=?`, []any{to, from}},
+		{`UPDATE user_fact_conflicts SET fact_key=? WHERE fact_key=?`, []any{to, from}},
+		{`UPDATE OR IGNORE summaries SET period_key=? WHERE type='fact' AND period_key=?`, []any{"fact:" + to, "fact:" + from}},
+		{`DELETE FROM summaries WHERE type='fact' AND period_key=?`, []any{"fact:" + from}},
+	}
+	for _, s := range stmts {
+		if _, err := tx.Exec(s.q, s.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rekeyPendingFacts recomputes pending_facts.fact_key from each row's own text.
+func rekeyPendingFacts(tx *sql.Tx, derive func(string) string) (int, error) {
+	rows, err := tx.Query(`SELECT id, fact, fact_key FROM pending_facts`)
+	if err != nil {
+		return 0, err
+	}
+	type upd struct {
+		id  int64
+		key string
+	}
+	var todo []upd
+	for rows.Next() {
+		var id int64
+		var fact, key string
+		if err := rows.Scan(&id, &fact, &key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if k := userFactKey(keyUser(key), derive(fact)); k != "" && k != key {
+			todo = append(todo, upd{id, k})
+		}
+	}
+	rows.Close()
+
+	n := 0
+	for _, u := range todo {
+		res, err := tx.Exec(`UPDATE OR IGNORE pending_facts SET fact_key=? WHERE id=?`, u.key, u.id)
+		if err != nil {
+			return n, err
+		}
+		if c, _ := res.RowsAffected(); c > 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func recordFactKeyMigration(tx *sql.Tx, rep *FactKeyMigrationReport, r factKeyRow, action string, mergedInto, conflictID int64, now time.Time) error {
+	rep.Changes = append(rep.Changes, FactKeyMigration{
+		UserFactID: r.id, OldKey: r.key, NewKey: r.newKey, Fact: r.fact,
+		Action: action, MergedInto: mergedInto, ConflictID: conflictID,
+	})
+	_, err := tx.Exec(`
+		INSERT INTO fact_key_migrations(user_fact_id, old_key, new_key, fact, action, merged_into, conflict_id, created_at)
+		VALUES(?,?,?,?,?,?,?,?)
+	`, r.id, r.key, r.newKey, r.fact, action, nullIfZero(mergedInto), nullIfZero(conflictID), now.Format(time.RFC3339))
+	return err
+}
+
+func nullIfZero(v int64) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
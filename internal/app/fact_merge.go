@@ -0,0 +1,293 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Fact merge
+// - Near-duplicate active facts ("我喜欢咖啡" / "我很喜欢喝咖啡") each get
+//   their own fact_key, so both are injected. MergeFacts folds two or more
+//   active facts of one user into one canonical fact:
+//     the survivor keeps its fact_key (optionally with a new text); the
+//     others are deactivated (to the trash, see trash.go) with history
+//     status "archived", source_type merge, source_key merge:<survivor key>.
+//     Their tags are added to the survivor, which is core when any was.
+//   The search rows of the merged-away facts are removed; the survivor's
+//   is rewritten when its text changed.
+// - FindDuplicateFacts groups the active set like the pending groups
+//   (pending_facts_group.go): same subject + relation slot, or cosine of the
+//   fact search vectors >= pendingClusterThreshold. Only groups of 2+ are
+//   returned; facts without a search vector match by slot only.
+// - API: GET /api/facts/duplicates, POST /api/facts/merge.
+//   CLI / chat: /merge lists the groups, /merge <keep> | <other> [| ...] merges.
+// ============================================================
+
+const factMergeSource = "merge"
+
+// maxDuplicateScan caps the active facts FindDuplicateFacts compares.
+const maxDuplicateScan = 2000
+
+// FactMergeResult is the surviving fact of a merge.
+type FactMergeResult struct {
+	FactKey string   `json:"fact_key"`
+	Fact    string   `json:"fact"`
+	Merged  []string `json:"merged"` // fact keys archived into FactKey
+	Tags    []string `json:"tags,omitempty"`
+	IsCore  bool     `json:"is_core"`
+}
+
+// FactDuplicateGroup is one group of GET /api/facts/duplicates (first = newest).
+type FactDuplicateGroup struct {
+	GroupID string        `json:"group_id"`
+	Items   []UserFactRow `json:"items"`
+	Size    int           `json:"size"`
+}
+
+// MergeFacts merges the active facts keys into keep (one of keys; "" = the first).
+// A non-empty text replaces the survivor's wording. This function is transactional.
+func MergeFacts(cfg Config, db *sql.DB, keys []string, keep, text string) (*FactMergeResult, error) {
+	if db == nil {
+		return nil, errors.New("fact not found")
+	}
+	var uniq []string
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" && !seen[k] {
+			seen[k] = true
+			uniq = append(uniq, k)
+		}
+	}
+	if len(uniq) < 2 {
+		return nil, errors.New("merge needs at least two different facts")
+	}
+	if keep = strings.TrimSpace(keep); keep == "" {
+		keep = uniq[0]
+	}
+	if !seen[keep] {
+		return nil, fmt.Errorf("keep is not one of the merged facts: %s", keep)
+	}
+	text = strings.TrimSpace(sanitizeUTF8(text))
+
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	now := time.Now().In(loc)
+	ts := now.Format(time.RFC3339)
+	sourceKey := "merge:" + keep
+
+	var res *FactMergeResult
+	textChanged := false
+	err := withDBRetry(3, 25*time.Millisecond, func() error {
+		res = &FactMergeResult{FactKey: keep}
+		textChanged = false
+		return withTx(db, func(tx *sql.Tx) error {
+			user := keyUser(keep)
+			facts := map[string]string{}
+			for _, k := range uniq {
+				f, ok := getActiveUserFactByKey(tx, k)
+				if !ok {
+					return fmt.Errorf("active fact not found: %s", k)
+				}
+				if keyUser(k) != user {
+					return errors.New("facts of different users cannot be merged")
+				}
+				facts[k] = f
+				var core int
+				_ = tx.QueryRow(`SELECT COALESCE(is_core,0) FROM user_facts WHERE fact_key=?`, k).Scan(&core)
+				res.IsCore = res.IsCore || core != 0
+			}
+
+			res.Fact = facts[keep]
+			if text != "" && text != res.Fact {
+				if factBlocked(cfg, tx, text, factMergeSource, sourceKey) {
+					return errors.New("the merged text is blocked by a deny rule")
+				}
+				if err := upsertUserFact(tx, text, keep, true, now); err != nil {
+					return err
+				}
+				if err := appendUserFactHistory(tx, keep, text, "active", factMergeSource, sourceKey, now, 0); err != nil {
+					return err
+				}
+				res.Fact = text
+				textChanged = true
+			}
+
+			for _, k := range uniq {
+				if k == keep {
+					continue
+				}
+				if err := upsertUserFact(tx, facts[k], k, false, now); err != nil {
+					return err
+				}
+				if err := appendUserFactHistory(tx, k, facts[k], "archived", factMergeSource, sourceKey, now, 0); err != nil {
+					return err
+				}
+				if _, err := tx.Exec(`
+					INSERT INTO user_fact_tags(fact_key, tag, created_at)
+					SELECT ?, tag, ? FROM user_fact_tags WHERE fact_key=?
+					ON CONFLICT(fact_key, tag) DO NOTHING
+				`, keep, ts, k); err != nil {
+					return err
+				}
+				res.Merged = append(res.Merged, k)
+			}
+			if res.IsCore {
+				if _, err := tx.Exec(`UPDATE user_facts SET is_core=1 WHERE fact_key=?`, keep); err != nil {
+					return err
+				}
+			}
+			tags, err := loadFactTags(tx, []string{keep})
+			if err != nil {
+				return err
+			}
+			res.Tags = tags[keep]
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// best-effort: keep semantic search aligned (post-commit)
+	for _, k := range res.Merged {
+		removeFactFromSearch(db, k)
+	}
+	if textChanged {
+		_ = syncFactToSearch(cfg, db, keep, res.Fact, factMergeSource)
+	}
+	return res, nil
+}
+
+// loadFactSearchVecs returns the search vector of every remembered fact by fact_key.
+func loadFactSearchVecs(db *sql.DB) (map[string]pendingVec, error) {
+	rows, err := readDB(db).Query(`
+		SELECT s.period_key, e.dim, e.vec, e.l2
+		FROM summaries s JOIN embeddings e ON e.summary_id = s.id
+		WHERE s.type='fact'
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]pendingVec{}
+	for rows.Next() {
+		var key string
+		var dim int
+		var blob []byte
+		var l2 float64
+		if rows.Scan(&key, &dim, &blob, &l2) != nil || l2 == 0 {
+			continue
+		}
+		if v := decodeVecBlob(blob, dim); v != nil {
+			out[strings.TrimPrefix(key, "fact:")] = pendingVec{v: v, l2: l2}
+		}
+	}
+	return out, rows.Err()
+}
+
+// FindDuplicateFacts groups user's active facts that look like the same fact.
+func FindDuplicateFacts(db *sql.DB, user string) ([]FactDuplicateGroup, error) {
+	facts, err := ListActiveFacts(db, user, maxDuplicateScan)
+	if err != nil || len(facts) < 2 {
+		return nil, err
+	}
+	vecs, err := loadFactSearchVecs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	type grp struct {
+		slot  string
+		repV  pendingVec
+		items []UserFactRow
+	}
+	var groups []grp
+	for _, f := range facts {
+		slot := ExtractFactTriple(f.Fact).SlotKey()
+		pv := vecs[f.FactKey]
+		best, bestSim := -1, 0.0
+		for gi := range groups {
+			if slot != "" && groups[gi].slot == slot {
+				best, bestSim = gi, 1
+				break
+			}
+			if len(pv.v) == 0 || len(groups[gi].repV.v) == 0 {
+				continue
+			}
+			if sim := cosine(pv.v, pv.l2, groups[gi].repV.v, groups[gi].repV.l2); sim > bestSim {
+				best, bestSim = gi, sim
+			}
+		}
+		if best >= 0 && bestSim >= pendingClusterThreshold {
+			groups[best].items = append(groups[best].items, f)
+			continue
+		}
+		groups = append(groups, grp{slot: slot, repV: pv, items: []UserFactRow{f}})
+	}
+
+	var out []FactDuplicateGroup
+	for _, g := range groups {
+		if len(g.items) < 2 {
+			continue
+		}
+		out = append(out, FactDuplicateGroup{
+			GroupID: "d" + strconv.Itoa(len(out)+1),
+			Items:   g.items,
+			Size:    len(g.items),
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Size > out[j].Size })
+	return out, nil
+}
+
+// runFactMergeCommand implements /merge (list duplicate groups) and
+// /merge <keep> | <other> [| ...] (facts by text or key; the first survives).
+func runFactMergeCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		groups, err := FindDuplicateFacts(db, cfg.User)
+		if err != nil {
+			return "", err
+		}
+		if len(groups) == 0 {
+			return "no duplicate facts found", nil
+		}
+		var b strings.Builder
+		for i, g := range groups {
+			fmt.Fprintf(&b, "#%d\n", i+1)
+			for _, it := range g.Items {
+				b.WriteString("  - " + it.Fact + "\n")
+			}
+		}
+		b.WriteString("merge with: /merge <keep> | <other> [| ...]")
+		return b.String(), nil
+	}
+
+	var keys []string
+	for _, ref := range strings.Split(arg, "|") {
+		if ref = strings.TrimSpace(ref); ref == "" {
+			continue
+		}
+		key := resolveFactKeyForTagging(db, cfg.User, ref)
+		if key == "" {
+			return "[noop] fact not found: " + ref, nil
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) < 2 {
+		return "usage: /merge <keep> | <other> [| ...]", nil
+	}
+	res, err := MergeFacts(cfg, db, keys, keys[0], "")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[ok] merged %d fact(s) into: %s", len(res.Merged), res.Fact), nil
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ============================================================
+// Multi-valued relations (preferences / skills)
+// "我喜欢咖啡" + "我喜欢茶" are both true: 喜欢 / like is a SET, not a slot.
+// - Classes: like (喜欢 / 爱吃 / love …), dislike (不喜欢 / 讨厌 / hate …),
+//   good_at (擅长 / 精通 / good at).
+// - A fact's object is split into value units ("咖啡和茶" → 咖啡, 茶).
+// - Same subject+relation accumulates values (no conflict); a fact whose
+//   units are all already in the set is a noop (the existing fact is touched).
+// - GET /api/facts/value_sets lists the set per (subject, relation).
+// ============================================================
+
+// multiValuedVerbs maps the verb as written to its relation class.
+var multiValuedVerbs = map[string]string{
+	"喜欢": "like", "喜爱": "like", "热爱": "like", "爱": "like",
+	"爱吃": "like", "爱喝": "like", "爱看": "like", "爱玩": "like", "爱听": "like",
+	"like": "like", "likes": "like", "love": "like", "loves": "like", "enjoy": "like", "enjoys": "like",
+	"不喜欢": "dislike", "不爱": "dislike", "讨厌": "dislike", "受不了": "dislike", "不吃": "dislike",
+	"dislike": "dislike", "dislikes": "dislike", "hate": "dislike", "hates": "dislike",
+	"don't like": "dislike", "do not like": "dislike", "doesn't like": "dislike",
+	"擅长": "good_at", "精通": "good_at",
+	"am good at": "good_at", "is good at": "good_at",
+}
+
+// zhPreferenceVerbs are the Chinese verbs (the earliest one in the sentence wins).
+var zhPreferenceVerbs = []string{
+	"不喜欢", "不爱", "讨厌", "受不了", "不吃",
+	"喜欢", "喜爱", "热爱", "爱吃", "爱喝", "爱看", "爱玩", "爱听", "擅长", "精通", "爱",
+}
+
+// zhPreferenceAdverbs may sit between the subject and the verb ("我也很喜欢").
+var zhPreferenceAdverbs = []string{"真的", "特别", "非常", "一直", "比较", "其实", "很", "也", "超", "最", "还", "挺", "都", "太"}
+
+var reEnPreference = regexp.MustCompile(`^(.{1,30}?)\s+(?:(?:really|also|just|still)\s+)*(don't like|do not like|doesn't like|dislikes?|hates?|likes?|loves?|enjoys?|am good at|is good at)\s+(.+)$`)
+
+var reValueSep = regexp.MustCompile(`\s*(?:、|，|,|/|和|以及|还有|\band\b|&)\s*`)
+
+// multiValuedRelationKey returns the class of a multi-valued verb ("" if none).
+func multiValuedRelationKey(r string) string {
+	return multiValuedVerbs[strings.ToLower(strings.TrimSpace(r))]
+}
+
+// parsePreference parses "<subj>[adverbs]<verb><obj>" / "<subj> likes <obj>".
+// Conservative: subjects containing "的" ("我的猫喜欢鱼") and objects that are
+// really another clause ("我喜欢的颜色是蓝色") are left to the other parsers.
+func parsePreference(s string) (subject, relation, object string, ok bool) {
+	if m := reEnPreference.FindStringSubmatch(strings.ToLower(s)); m != nil {
+		subject, relation, object = m[1], m[2], strings.TrimSpace(s[len(s)-len(m[3]):])
+		if strings.Contains(subject, " is ") || strings.Contains(subject, "'s ") {
+			return "", "", "", false
+		}
+		return subject, relation, object, object != ""
+	}
+
+	// earliest verb wins ("我喜欢不吃早饭" is 喜欢); at the same spot the longer one
+	idx, v := -1, ""
+	for _, cand := range zhPreferenceVerbs {
+		i := strings.Index(s, cand)
+		if i > 0 && (idx < 0 || i < idx || (i == idx && len(cand) > len(v))) {
+			idx, v = i, cand
+		}
+	}
+	if idx > 0 {
+		subject = s[:idx]
+		object = strings.TrimSpace(s[idx+len(v):])
+		relation = v
+		for trimmed := true; trimmed; {
+			trimmed = false
+			for _, a := range zhPreferenceAdverbs {
+				if strings.HasSuffix(subject, a) && len(subject) > len(a) {
+					subject, trimmed = strings.TrimSuffix(subject, a), true
+				}
+			}
+		}
+		// "我不太喜欢" → 不 + 喜欢
+		if strings.HasSuffix(subject, "不") && multiValuedRelationKey(v) == "like" {
+			subject, relation = strings.TrimSuffix(subject, "不"), "不喜欢"
+		}
+		subject = strings.TrimSpace(subject)
+		if subject == "" || strings.Contains(subject, "的") || len([]rune(subject)) > 10 {
+			return "", "", "", false
+		}
+		if object == "" || strings.HasPrefix(object, "的") || strings.Contains(object, "是") || len([]rune(object)) > 40 {
+			return "", "", "", false
+		}
+		return subject, relation, object, true
+	}
+	return "", "", "", false
+}
+
+// factSetValues splits a normalized object into value units ("咖啡和茶" → [咖啡 茶]).
+func factSetValues(objectNorm string) []string {
+	var out []string
+	for _, p := range reValueSep.Split(objectNorm, -1) {
+		p = strings.TrimSpace(strings.TrimRight(p, "了啦呀。.!！"))
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// findFactInValueSet returns an active fact of the same set that already holds
+// every value unit of tr (repeat = noop). Only user's facts count.
+func findFactInValueSet(db dbTX, user string, tr FactTriple) (factKey, fact string, ok bool) {
+	setKey := tr.SetKey()
+	if db == nil || setKey == "" {
+		return "", "", false
+	}
+	want := factSetValues(tr.ObjectNorm)
+	if len(want) == 0 {
+		return "", "", false
+	}
+	rows, err := db.Query(`SELECT fact_key, fact FROM user_facts WHERE is_active=1 AND user_id=? ORDER BY updated_at DESC`, user)
+	if err != nil {
+		return "", "", false
+	}
+	defer rows.Close()
+	type holder struct{ key, fact string }
+	have := map[string]holder{} // value -> most recent fact holding it
+	for rows.Next() {
+		var k, f string
+		if err := rows.Scan(&k, &f); err != nil {
+			continue
+		}
+		et := ExtractFactTriple(f)
+		if et.SetKey() != setKey {
+			continue
+		}
+		for _, v := range factSetValues(et.ObjectNorm) {
+			if _, ok := have[v]; !ok {
+				have[v] = holder{k, f}
+			}
+		}
+	}
+	for _, v := range want {
+		if _, ok := have[v]; !ok {
+			return "", "", false
+		}
+	}
+	h := have[want[0]]
+	recordFactSlotHit(db, h.key)
+	return h.key, h.fact, true
+}
+
+type FactSetValue struct {
+	Value   string `json:"value"`
+	FactKey string `json:"fact_key"`
+	Fact    string `json:"fact"`
+}
+
+type FactValueSet struct {
+	Slot     string         `json:"slot"`
+	Subject  string         `json:"subject"`
+	Relation string         `json:"relation"` // like | dislike | good_at
+	Values   []FactSetValue `json:"values"`
+}
+
+// ListFactValueSets groups user's active multi-valued facts by (subject, relation).
+// relation / subject filter when non-empty.
+func ListFactValueSets(db *sql.DB, user, relation, subject string) ([]FactValueSet, error) {
+	rows, err := db.Query(`SELECT fact_key, fact FROM user_facts WHERE is_active=1 AND user_id=? ORDER BY updated_at ASC`, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	relation = strings.ToLower(strings.TrimSpace(relation))
+	subKey := ""
+	if s := strings.TrimSpace(subject); s != "" {
+		subKey = "sub:" + normalizeFactKey(resolveSubjectAlias(currentSubjectAliases(), s))
+	}
+	byKey := map[string]*FactValueSet{}
+	for rows.Next() {
+		var k, f string
+		if err := rows.Scan(&k, &f); err != nil {
+			return nil, err
+		}
+		tr := ExtractFactTriple(f)
+		setKey := tr.SetKey()
+		if setKey == "" {
+			continue
+		}
+		rel := strings.TrimPrefix(tr.RelationKey, "rel:")
+		if (relation != "" && rel != relation) || (subKey != "" && tr.SubjectKey != subKey) {
+			continue
+		}
+		vs := byKey[setKey]
+		if vs == nil {
+			vs = &FactValueSet{Slot: setKey, Subject: strings.TrimPrefix(tr.SubjectKey, "sub:"), Relation: rel}
+			byKey[setKey] = vs
+		}
+	next:
+		for _, v := range factSetValues(tr.ObjectNorm) {
+			for _, have := range vs.Values {
+				if have.Value == v {
+					continue next
+				}
+			}
+			vs.Values = append(vs.Values, FactSetValue{Value: v, FactKey: k, Fact: f})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]FactValueSet, 0, len(byKey))
+	for _, vs := range byKey {
+		out = append(out, *vs)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Slot < out[j].Slot })
+	return out, nil
+}
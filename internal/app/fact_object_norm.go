@@ -0,0 +1,155 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================================
+// Object normalization for slot values (FactTriple.ObjectNorm)
+// "生日是5月3日" / "生日是05-03" / "birthday is May 3rd" must compare equal,
+// otherwise the same value shows up as a conflict (or never dedupes).
+// Canonical forms per relation:
+//   birthday → "MM-DD" or "YYYY-MM-DD"
+//   phone    → digits only ("+<cc>" kept for non-CN numbers; +86/0086 dropped)
+//   email    → lower-case address
+//   age      → digits
+// Anything that does not parse falls back to normalizeFactKey(object).
+// ============================================================
+
+var (
+	reDateYMD    = regexp.MustCompile(`^(\d{4})\s*[-/.年]\s*(\d{1,2})\s*[-/.月]\s*(\d{1,2})\s*[日号]?$`)
+	reDateMD     = regexp.MustCompile(`^(\d{1,2})\s*[-/.月]\s*(\d{1,2})\s*[日号]?$`)
+	reDateEnMD   = regexp.MustCompile(`^([a-z]+)\.?\s+(\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(\d{4}))?$`)
+	reDateEnDM   = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?([a-z]+)\.?(?:,?\s+(\d{4}))?$`)
+	reAgeValue   = regexp.MustCompile(`^(\d{1,3})\s*(?:岁|周岁|years? old|yo)?$`)
+	rePhoneChars = regexp.MustCompile(`^\+?[\d\s\-().]+$`)
+)
+
+var englishMonths = map[string]int{
+	"jan": 1, "january": 1, "feb": 2, "february": 2, "mar": 3, "march": 3,
+	"apr": 4, "april": 4, "may": 5, "jun": 6, "june": 6, "jul": 7, "july": 7,
+	"aug": 8, "august": 8, "sep": 9, "sept": 9, "september": 9, "oct": 10, "october": 10,
+	"nov": 11, "november": 11, "dec": 12, "december": 12,
+}
+
+// normalizeObjectValue returns the canonical form of object for relation key rel.
+func normalizeObjectValue(rel, object string) string {
+	s := normalizeFactKey(object)
+	if s == "" {
+		return ""
+	}
+	var out string
+	switch rel {
+	case "birthday":
+		out = normalizeDateValue(s)
+	case "phone":
+		out = normalizePhoneValue(s)
+	case "email":
+		out = normalizeEmailValue(s)
+	case "age":
+		if m := reAgeValue.FindStringSubmatch(s); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			out = strconv.Itoa(n)
+		}
+	}
+	if out == "" {
+		return s
+	}
+	return out
+}
+
+func normalizeDateValue(s string) string {
+	s = strings.TrimSpace(strings.TrimRight(s, "。.!！ "))
+	ymd := func(y, m, d int) string {
+		if m < 1 || m > 12 || d < 1 || d > 31 {
+			return ""
+		}
+		if y == 0 {
+			return fmt.Sprintf("%02d-%02d", m, d)
+		}
+		return fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+	}
+	atoi := func(v string) int {
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	if m := reDateYMD.FindStringSubmatch(s); m != nil {
+		return ymd(atoi(m[1]), atoi(m[2]), atoi(m[3]))
+	}
+	if m := reDateMD.FindStringSubmatch(s); m != nil {
+		return ymd(0, atoi(m[1]), atoi(m[2]))
+	}
+	if m := reDateEnMD.FindStringSubmatch(s); m != nil {
+		if mon, ok := englishMonths[m[1]]; ok {
+			return ymd(atoi(m[3]), mon, atoi(m[2]))
+		}
+	}
+	if m := reDateEnDM.FindStringSubmatch(s); m != nil {
+		if mon, ok := englishMonths[m[2]]; ok {
+			return ymd(atoi(m[3]), mon, atoi(m[1]))
+		}
+	}
+	return ""
+}
+
+func normalizePhoneValue(s string) string {
+	if !rePhoneChars.MatchString(s) {
+		return ""
+	}
+	plus := strings.HasPrefix(s, "+")
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	d := b.String()
+	if strings.HasPrefix(d, "00") {
+		d, plus = d[2:], true
+	}
+	// mainland mobile: +86 13800138000 == 13800138000
+	if strings.HasPrefix(d, "86") && len(d) == 13 && d[2] == '1' && (plus || len(s) > 11) {
+		d, plus = d[2:], false
+	}
+	if len(d) < 5 {
+		return ""
+	}
+	if plus {
+		return "+" + d
+	}
+	return d
+}
+
+func normalizeEmailValue(s string) string {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "mailto:")
+	s = strings.Trim(s, "<>")
+	if strings.Count(s, "@") != 1 || strings.ContainsAny(s, " ,;") {
+		return ""
+	}
+	return s
+}
+
+// sameFactValue reports whether two facts state the same thing: identical
+// text, the same single-valued slot with equal normalized objects, or the same
+// multi-valued set with the same value units.
+func sameFactValue(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return true
+	}
+	ta, tb := ExtractFactTriple(a), ExtractFactTriple(b)
+	if k := ta.SetKey(); k != "" && k == tb.SetKey() {
+		va, vb := factSetValues(ta.ObjectNorm), factSetValues(tb.ObjectNorm)
+		sort.Strings(va)
+		sort.Strings(vb)
+		return len(va) > 0 && strings.Join(va, "\x00") == strings.Join(vb, "\x00")
+	}
+	if ta.SlotKey() == "" || ta.SlotKey() != tb.SlotKey() {
+		return false
+	}
+	return ta.ObjectNorm != "" && ta.ObjectNorm == tb.ObjectNorm
+}
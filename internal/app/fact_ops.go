@@ -7,10 +7,13 @@ import (
 )
 
 type RememberOutcome struct {
-	Status     string `json:"status"` // remembered | pending | conflict | noop
-	FactKey    string `json:"fact_key"`
-	ConflictID int64  `json:"conflict_id,omitempty"`
-	Existing   string `json:"existing,omitempty"`
+	Status     string              `json:"status"` // remembered | pending | conflict | noop | blocked | clarify | error (batch item)
+	FactKey    string              `json:"fact_key"`
+	ConflictID int64               `json:"conflict_id,omitempty"`
+	Existing   string              `json:"existing,omitempty"`
+	Conflict   *FactConflictDetail `json:"conflict,omitempty"` // why it conflicts (fact_conflict_explain.go)
+	Question   string              `json:"question,omitempty"` // clarify: asked instead of adding to pending (fact_clarify.go)
+	Error      string              `json:"error,omitempty"`
 }
 
 // ProposePendingRememberFact behaves like ProposeRememberFact, but instead of immediately writing
@@ -38,7 +41,7 @@ func proposePendingRememberFactWith(cfg Config, db dbTX, content, sourceType, so
 	if content == "" {
 		return &RememberOutcome{Status: "noop"}, nil
 	}
-	factKey := deriveFactKeyFromSubject(content)
+	factKey := deriveUserFactKey(cfg, content)
 	if factKey == "" {
 		return &RememberOutcome{Status: "noop"}, nil
 	}
@@ -48,10 +51,13 @@ func proposePendingRememberFactWith(cfg Config, db dbTX, content, sourceType, so
 	if sourceKey == "" {
 		sourceKey = when.Format("2006-01-02")
 	}
+	if factBlocked(cfg, db, content, sourceType, sourceKey) {
+		return &RememberOutcome{Status: "blocked"}, nil
+	}
 
 	// 1) exact key conflicts
 	if existing, ok := getActiveUserFactByKey(db, factKey); ok {
-		if strings.TrimSpace(existing) == strings.TrimSpace(content) {
+		if sameFactValue(existing, content) {
 			if err := upsertUserFact(db, existing, factKey, true, when); err != nil {
 				return nil, err
 			}
@@ -66,15 +72,15 @@ func proposePendingRememberFactWith(cfg Config, db dbTX, content, sourceType, so
 				return nil, err
 			}
 		}
-		return &RememberOutcome{Status: "conflict", FactKey: factKey, ConflictID: cid, Existing: existing}, nil
+		return &RememberOutcome{Status: "conflict", FactKey: factKey, ConflictID: cid, Existing: existing, Conflict: explainFactConflict("key", existing, content)}, nil
 	}
 
 	// 2) subject+predicate slot conflicts
 	tr := ExtractFactTriple(content)
 	slotKey := tr.SlotKey()
 	if slotKey != "" {
-		if existingKey, existingFact, ok := getActiveUserFactBySlotKey(db, slotKey); ok {
-			if strings.TrimSpace(existingFact) == strings.TrimSpace(content) {
+		if existingKey, existingFact, ok := getActiveUserFactBySlotKey(db, cfg.User, slotKey); ok {
+			if sameFactValue(existingFact, content) {
 				if err := upsertUserFact(db, existingFact, existingKey, true, when); err != nil {
 					return nil, err
 				}
@@ -89,8 +95,16 @@ func proposePendingRememberFactWith(cfg Config, db dbTX, content, sourceType, so
 					return nil, err
 				}
 			}
-			return &RememberOutcome{Status: "conflict", FactKey: existingKey, ConflictID: cid, Existing: existingFact}, nil
+			return &RememberOutcome{Status: "conflict", FactKey: existingKey, ConflictID: cid, Existing: existingFact, Conflict: explainFactConflict("slot", existingFact, content)}, nil
+		}
+	}
+
+	// 3) multi-valued relation (喜欢 / like ...): a value already in the set is a noop
+	if existingKey, existingFact, ok := findFactInValueSet(db, cfg.User, tr); ok {
+		if err := upsertUserFact(db, existingFact, existingKey, true, when); err != nil {
+			return nil, err
 		}
+		return &RememberOutcome{Status: "noop", FactKey: existingKey}, nil
 	}
 
 	// new candidate -> pending
@@ -161,7 +175,7 @@ func proposeRememberFactWith(cfg Config, db dbTX, content, sourceType, sourceKey
 	if content == "" {
 		return &RememberOutcome{Status: "noop"}, nil
 	}
-	factKey := deriveFactKeyFromSubject(content)
+	factKey := deriveUserFactKey(cfg, content)
 	if factKey == "" {
 		return &RememberOutcome{Status: "noop"}, nil
 	}
@@ -171,10 +185,13 @@ func proposeRememberFactWith(cfg Config, db dbTX, content, sourceType, sourceKey
 	if sourceKey == "" {
 		sourceKey = when.Format("2006-01-02")
 	}
+	if factBlocked(cfg, db, content, sourceType, sourceKey) {
+		return &RememberOutcome{Status: "blocked"}, nil
+	}
 
 	// ---- 1) exact key: same slot (legacy behaviour) ----
 	if existing, ok := getActiveUserFactByKey(db, factKey); ok {
-		if strings.TrimSpace(existing) == strings.TrimSpace(content) {
+		if sameFactValue(existing, content) {
 			// touch updated_at to keep it fresh
 			if err := upsertUserFact(db, existing, factKey, true, when); err != nil {
 				return nil, err
@@ -192,15 +209,15 @@ func proposeRememberFactWith(cfg Config, db dbTX, content, sourceType, sourceKey
 				return nil, err
 			}
 		}
-		return &RememberOutcome{Status: "conflict", FactKey: factKey, ConflictID: cid, Existing: existing}, nil
+		return &RememberOutcome{Status: "conflict", FactKey: factKey, ConflictID: cid, Existing: existing, Conflict: explainFactConflict("key", existing, content)}, nil
 	}
 
 	// ---- 2) subject+predicate slot conflict: same subject slot, different fact_key ----
 	tr := ExtractFactTriple(content)
 	slotKey := tr.SlotKey()
 	if slotKey != "" {
-		if existingKey, existingFact, ok := getActiveUserFactBySlotKey(db, slotKey); ok {
-			if strings.TrimSpace(existingFact) == strings.TrimSpace(content) {
+		if existingKey, existingFact, ok := getActiveUserFactBySlotKey(db, cfg.User, slotKey); ok {
+			if sameFactValue(existingFact, content) {
 				if err := upsertUserFact(db, existingFact, existingKey, true, when); err != nil {
 					return nil, err
 				}
@@ -215,8 +232,16 @@ func proposeRememberFactWith(cfg Config, db dbTX, content, sourceType, sourceKey
 					return nil, err
 				}
 			}
-			return &RememberOutcome{Status: "conflict", FactKey: existingKey, ConflictID: cid, Existing: existingFact}, nil
+			return &RememberOutcome{Status: "conflict", FactKey: existingKey, ConflictID: cid, Existing: existingFact, Conflict: explainFactConflict("slot", existingFact, content)}, nil
+		}
+	}
+
+	// ---- 3) multi-valued relation: repeat of a value already in the set ----
+	if existingKey, existingFact, ok := findFactInValueSet(db, cfg.User, tr); ok {
+		if err := upsertUserFact(db, existingFact, existingKey, true, when); err != nil {
+			return nil, err
 		}
+		return &RememberOutcome{Status: "noop", FactKey: existingKey}, nil
 	}
 
 	// accept as new truth
@@ -247,7 +272,7 @@ func RetractFact(cfg Config, db *sql.DB, content, sourceType, sourceKey string,
 	var removeKey string
 	err := withDBRetry(3, 25*time.Millisecond, func() error {
 		return withTx(db, func(tx *sql.Tx) error {
-			factKey := deriveFactKeyFromSubject(content)
+			factKey := deriveUserFactKey(cfg, content)
 			if factKey != "" {
 				if existing, ok := getActiveUserFactByKey(tx, factKey); ok {
 					if err := upsertUserFact(tx, existing, factKey, false, when); err != nil {
@@ -265,7 +290,7 @@ func RetractFact(cfg Config, db *sql.DB, content, sourceType, sourceKey string,
 			tr := ExtractFactTriple(content)
 			slotKey := tr.SlotKey()
 			if slotKey != "" {
-				if existingKey, existingFact, ok := getActiveUserFactBySlotKey(tx, slotKey); ok {
+				if existingKey, existingFact, ok := getActiveUserFactBySlotKey(tx, cfg.User, slotKey); ok {
 					if err := upsertUserFact(tx, existingFact, existingKey, false, when); err != nil {
 						return err
 					}
@@ -282,7 +307,7 @@ func RetractFact(cfg Config, db *sql.DB, content, sourceType, sourceKey string,
 		return err
 	}
 	if removeKey != "" {
-		removeFactFromSearch(db, removeKey, "forgotten")
+		removeFactFromSearch(db, removeKey)
 	}
 	return nil
 }
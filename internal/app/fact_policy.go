@@ -0,0 +1,205 @@
+package app
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Fact deny rules ("never store this")
+// - fact_deny_rules(kind, pattern): keyword (case-insensitive substring),
+//   regex (Go RE2, validated on save) or relation (canonical relation key of
+//   the fact triple, e.g. phone / location / like, or the raw relation text).
+// - Checked in addPendingFact and both remember paths before anything is
+//   written: a match is dropped (remember returns status "blocked") and an op
+//   record "fact_blocked" is written with the rule id and a hash of the text —
+//   never the text or the pattern (the point is often an accidentally pasted
+//   secret).
+// - Edited via /api/facts/policies; rules are settings and survive a wipe.
+// - Existing facts are not touched; use /forget or the trash for those.
+// ============================================================
+
+const (
+	factRuleKeyword  = "keyword"
+	factRuleRegex    = "regex"
+	factRuleRelation = "relation"
+)
+
+type FactDenyRule struct {
+	ID        int64  `json:"id"`
+	Kind      string `json:"kind"` // keyword | regex | relation
+	Pattern   string `json:"pattern"`
+	Note      string `json:"note,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+
+	re *regexp.Regexp
+}
+
+// factDenyCache is the in-process copy of fact_deny_rules (replaced wholesale on reload).
+var factDenyCache struct {
+	sync.RWMutex
+	rules []FactDenyRule
+}
+
+func currentFactDenyRules() []FactDenyRule {
+	factDenyCache.RLock()
+	defer factDenyCache.RUnlock()
+	return factDenyCache.rules
+}
+
+// normalizeFactRule validates kind / pattern and compiles regex rules.
+func normalizeFactRule(r FactDenyRule) (FactDenyRule, error) {
+	r.Kind = strings.ToLower(strings.TrimSpace(r.Kind))
+	r.Pattern = strings.TrimSpace(r.Pattern)
+	r.Note = strings.TrimSpace(r.Note)
+	if r.Pattern == "" {
+		return r, errors.New("pattern is required")
+	}
+	switch r.Kind {
+	case factRuleKeyword:
+		r.Pattern = strings.ToLower(r.Pattern)
+	case factRuleRelation:
+		r.Pattern = strings.TrimPrefix(strings.ToLower(r.Pattern), "rel:")
+	case factRuleRegex:
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return r, fmt.Errorf("invalid regex: %w", err)
+		}
+		r.re = re
+	default:
+		return r, fmt.Errorf("invalid kind: %s (keyword|regex|relation)", r.Kind)
+	}
+	return r, nil
+}
+
+func loadFactDenyRules(db *sql.DB) error {
+	var rules []FactDenyRule
+	if db != nil {
+		var err error
+		if rules, err = ListFactDenyRules(db); err != nil {
+			return err
+		}
+	}
+	factDenyCache.Lock()
+	factDenyCache.rules = rules
+	factDenyCache.Unlock()
+	return nil
+}
+
+func ListFactDenyRules(db *sql.DB) ([]FactDenyRule, error) {
+	rows, err := db.Query(`SELECT id, kind, pattern, note, created_at, updated_at FROM fact_deny_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []FactDenyRule{}
+	for rows.Next() {
+		var r FactDenyRule
+		if err := rows.Scan(&r.ID, &r.Kind, &r.Pattern, &r.Note, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if r, err = normalizeFactRule(r); err != nil {
+			continue // edited by hand into something invalid: ignore, keep the rest
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// AddFactDenyRule stores a rule (same kind+pattern updates the note).
+func AddFactDenyRule(cfg Config, db *sql.DB, kind, pattern, note string) (FactDenyRule, error) {
+	r, err := normalizeFactRule(FactDenyRule{Kind: kind, Pattern: pattern, Note: note})
+	if err != nil {
+		return r, err
+	}
+	ts := time.Now().In(cfg.Location).Format(time.RFC3339)
+	if _, err := db.Exec(`
+		INSERT INTO fact_deny_rules(kind, pattern, note, created_at, updated_at) VALUES(?,?,?,?,?)
+		ON CONFLICT(kind, pattern) DO UPDATE SET note=excluded.note, updated_at=excluded.updated_at
+	`, r.Kind, r.Pattern, r.Note, ts, ts); err != nil {
+		return r, err
+	}
+	_ = db.QueryRow(`SELECT id, created_at, updated_at FROM fact_deny_rules WHERE kind=? AND pattern=?`, r.Kind, r.Pattern).
+		Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	return r, loadFactDenyRules(db)
+}
+
+func DeleteFactDenyRule(db *sql.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM fact_deny_rules WHERE id=?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("rule not found")
+	}
+	return loadFactDenyRules(db)
+}
+
+// matchFactDenyRule returns the first rule the fact matches.
+func matchFactDenyRule(rules []FactDenyRule, fact string) (FactDenyRule, bool) {
+	if len(rules) == 0 || strings.TrimSpace(fact) == "" {
+		return FactDenyRule{}, false
+	}
+	lower := strings.ToLower(fact)
+	var tr *FactTriple
+	for _, r := range rules {
+		switch r.Kind {
+		case factRuleKeyword:
+			if strings.Contains(lower, r.Pattern) {
+				return r, true
+			}
+		case factRuleRegex:
+			if r.re != nil && r.re.MatchString(fact) {
+				return r, true
+			}
+		case factRuleRelation:
+			if tr == nil {
+				t := ExtractFactTriple(fact)
+				tr = &t
+			}
+			if tr.RelationKey == "" {
+				continue
+			}
+			if strings.TrimPrefix(tr.RelationKey, "rel:") == r.Pattern || strings.ToLower(tr.Relation) == r.Pattern {
+				return r, true
+			}
+		}
+	}
+	return FactDenyRule{}, false
+}
+
+// factBlocked checks fact against the deny rules; on a match it writes the
+// fact_blocked op record (best-effort) and returns true. A fact carrying a
+// secret (secret_mask.go) is blocked the same way, with a secret_masked record.
+func factBlocked(cfg Config, db dbTX, fact, sourceType, sourceKey string) bool {
+	if _, kinds := maskSecretsFor(cfg, fact); len(kinds) > 0 {
+		_ = writeOpRecord(cfg, db, opSecretMasked, map[string]any{
+			"role":        "fact",
+			"kinds":       kinds,
+			"source_type": sourceType,
+			"source_key":  sourceKey,
+		})
+		return true
+	}
+	r, ok := matchFactDenyRule(currentFactDenyRules(), fact)
+	if !ok {
+		return false
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(fact)))
+	_ = writeOpRecord(cfg, db, opFactBlocked, map[string]any{
+		"rule_id":     r.ID,
+		"kind":        r.Kind, // not the pattern: it may be the secret itself
+		"source_type": sourceType,
+		"source_key":  sourceKey,
+		"fact_sha256": hex.EncodeToString(sum[:8]),
+	})
+	return true
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Fact provenance
+// - GET /api/facts/active attaches to every fact the chain that made its
+//   current text active, so "why does it believe this" needs no SQL:
+//     history   the newest active user_facts_history row (version, source,
+//               accepted_at)
+//     conflict  when that row is a conflict_replace: the conflict and its
+//               proposed source
+//     pending   the pending_facts row it was accepted from (id, source_type,
+//               confidence, sources, evidence)
+//     raw lines when the source key is a day: the user lines of that day's
+//               raw log the fact was found in (1-based line + byte offset)
+// - Raw lines are not stored at capture time; they are matched when listed
+//   (evidence "user" text first, then the fact text), at most
+//   factProvenanceMaxLines per fact. Archived days have none.
+// ============================================================
+
+const factProvenanceMaxLines = 3
+
+// FactProvenance is the provenance chain of one active fact.
+type FactProvenance struct {
+	HistoryID  int64  `json:"history_id"`
+	Version    int    `json:"version"`
+	SourceType string `json:"source_type"` // as recorded in user_facts_history (pending, remember, conflict_replace, ...)
+	SourceKey  string `json:"source_key"`
+	AcceptedAt string `json:"accepted_at"`
+
+	ConflictID int64 `json:"conflict_id,omitempty"`
+
+	PendingID         int64           `json:"pending_id,omitempty"`
+	PendingSourceType string          `json:"pending_source_type,omitempty"` // daily / realtime_implicit / correction / email ...
+	Confidence        float64         `json:"confidence,omitempty"`
+	Sources           []string        `json:"sources,omitempty"`
+	Evidence          json.RawMessage `json:"evidence,omitempty"`
+
+	SourceDate string        `json:"source_date,omitempty"` // the day the fact came from
+	RawLines   []FactRawLine `json:"raw_lines,omitempty"`
+}
+
+// FactRawLine is one raw log line a fact was found in.
+type FactRawLine struct {
+	Line    int    `json:"line"`   // 1-based line in <date>.jsonl
+	Offset  int    `json:"offset"` // byte offset of the line
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// attachFactProvenance fills Provenance of every row (best effort).
+func attachFactProvenance(cfg Config, db *sql.DB, rows []UserFactRow) []UserFactRow {
+	days := map[string][]FactRawLine{}
+	for i := range rows {
+		rows[i].Provenance = loadFactProvenance(cfg, db, rows[i].FactKey, rows[i].Fact, days)
+	}
+	return rows
+}
+
+// loadFactProvenance builds the chain of factKey; days caches parsed raw logs.
+func loadFactProvenance(cfg Config, db *sql.DB, factKey, fact string, days map[string][]FactRawLine) *FactProvenance {
+	var p FactProvenance
+	err := readDB(db).QueryRow(`
+		SELECT id, version, source_type, source_key, created_at
+		FROM user_facts_history
+		WHERE fact_key=? AND status='active'
+		ORDER BY version DESC, id DESC LIMIT 1
+	`, factKey).Scan(&p.HistoryID, &p.Version, &p.SourceType, &p.SourceKey, &p.AcceptedAt)
+	if err != nil {
+		return nil // stored before the history existed
+	}
+
+	srcType, srcKey := p.SourceType, p.SourceKey
+	if id, ok := strings.CutPrefix(srcKey, "conflict:"); ok {
+		p.ConflictID, _ = strconv.ParseInt(id, 10, 64)
+		_ = readDB(db).QueryRow(
+			`SELECT proposed_source_type, proposed_source_key FROM user_fact_conflicts WHERE id=?`, p.ConflictID,
+		).Scan(&srcType, &srcKey)
+	}
+
+	if srcType == "pending" || srcType == pendingSourceEdit {
+		var sources, evidence string
+		err := readDB(db).QueryRow(`
+			SELECT id, source_type, source_key, confidence, COALESCE(sources,''), COALESCE(evidence,'')
+			FROM pending_facts
+			WHERE fact_key=? AND source_key=? AND status IN ('accepted','conflict')
+			ORDER BY updated_at DESC, id DESC LIMIT 1
+		`, factKey, srcKey).Scan(&p.PendingID, &p.PendingSourceType, &srcKey, &p.Confidence, &sources, &evidence)
+		if err == nil {
+			p.Sources = decodePendingSources(sources)
+			if json.Valid([]byte(evidence)) {
+				p.Evidence = json.RawMessage(evidence)
+			}
+		}
+	}
+
+	if _, err := time.ParseInLocation("2006-01-02", srcKey, cfg.Location); err == nil {
+		p.SourceDate = srcKey
+		p.RawLines = matchFactRawLines(cfg, db, srcKey, factRawNeedles(fact, p.Evidence), days)
+	}
+	return &p
+}
+
+// factRawNeedles is what a source line should contain: the user's own words
+// from the evidence, else the fact text.
+func factRawNeedles(fact string, evidence json.RawMessage) []string {
+	var out []string
+	var ev struct {
+		User string `json:"user"`
+	}
+	if len(evidence) > 0 && json.Unmarshal(evidence, &ev) == nil && strings.TrimSpace(ev.User) != "" {
+		out = append(out, strings.TrimSpace(ev.User))
+	}
+	if f := strings.TrimSpace(strings.TrimRight(fact, "。.!！")); f != "" {
+		out = append(out, f)
+	}
+	return out
+}
+
+// matchFactRawLines returns the user lines of date that contain a needle
+// (the first needle that matches anything wins).
+func matchFactRawLines(cfg Config, db *sql.DB, date string, needles []string, days map[string][]FactRawLine) []FactRawLine {
+	lines, ok := days[date]
+	if !ok {
+		lines = loadFactRawDay(cfg, db, date)
+		days[date] = lines
+	}
+	for _, n := range needles {
+		var out []FactRawLine
+		for _, l := range lines {
+			if strings.Contains(l.Content, n) {
+				out = append(out, l)
+				if len(out) == factProvenanceMaxLines {
+					break
+				}
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return nil
+}
+
+// loadFactRawDay reads the user lines of date with their positions.
+func loadFactRawDay(cfg Config, db *sql.DB, date string) []FactRawLine {
+	raw, err := readRawDay(cfg, db, date)
+	if err != nil || len(raw) == 0 {
+		return nil
+	}
+	var out []FactRawLine
+	offset := 0
+	for i, line := range bytes.Split(raw, []byte("\n")) {
+		start := offset
+		offset += len(line) + 1
+		if isLegacyOpLine(line) || isRedactedLine(line) {
+			continue
+		}
+		var r RawLine
+		if json.Unmarshal(line, &r) != nil || r.Role != "user" {
+			continue
+		}
+		out = append(out, FactRawLine{Line: i + 1, Offset: start, Role: r.Role, Content: r.Content})
+	}
+	return out
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ============================================================
+// Remembered fact selection (safety valve)
+// - BuildChatContext used to inject up to 50 active facts on every turn,
+//   whatever the question; at a few hundred facts that swamps the prompt.
+// - Up to TIMELAYER_FACT_RELEVANCE_MIN_FACTS active facts: all of them go in
+//   (newest first), as before.
+// - Above that:
+//     1. core facts (is_core, see fact_core.go) and facts tagged with
+//        TIMELAYER_FACT_ALWAYS_TAGS (default pinned, core) always go in;
+//     2. the others need an embedding similarity to the question of at least
+//        TIMELAYER_FACT_RELEVANCE_MIN_SCORE (their "fact:<key>" summary vector);
+//     3. TIMELAYER_FACT_INJECT_MAX caps the total, ordered by
+//        TIMELAYER_FACT_INJECT_ORDER (relevance = best match first, recent =
+//        newest first), shared out by category (fact_category.go).
+//        Always-facts come first and are never cut.
+// - No question, or the embed server is down: the newest facts up to the cap.
+// - The block's truncation note says how many facts were kept and why.
+// ============================================================
+
+const (
+	defaultFactInjectMax         = 30
+	defaultFactRelevanceMinFacts = 20
+	defaultFactRelevanceMinScore = 0.5
+)
+
+type factCandidate struct {
+	key      string
+	fact     string
+	category string
+	always   bool
+	score    float64
+}
+
+// loadFactCandidates returns the active facts allowed by policy, newest first.
+func loadFactCandidates(cfg Config, db *sql.DB, policy FactTagPolicy) ([]factCandidate, error) {
+	rows, err := readDB(db).Query(`
+		SELECT fact_key, fact, COALESCE(is_core,0), COALESCE(category,'')
+		FROM user_facts
+		WHERE is_active=1 AND user_id=?
+		ORDER BY updated_at DESC
+	`, cfg.User)
+	if err != nil {
+		return nil, err
+	}
+	var all []factCandidate
+	for rows.Next() {
+		var c factCandidate
+		var core int
+		if err := rows.Scan(&c.key, &c.fact, &core, &c.category); err != nil {
+			continue
+		}
+		c.always = core != 0
+		if c.fact = strings.TrimSpace(c.fact); c.fact != "" {
+			all = append(all, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(all))
+	for _, c := range all {
+		keys = append(keys, c.key)
+	}
+	tags, err := loadFactTags(db, keys)
+	if err != nil {
+		return nil, err
+	}
+	out := all[:0]
+	for _, c := range all {
+		if !policy.Allows(tags[c.key]) {
+			continue
+		}
+		for _, t := range tags[c.key] {
+			if containsString(cfg.FactAlwaysTags, t) {
+				c.always = true
+				break
+			}
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// factRelevanceScores scores every embedded fact against the question vector.
+func factRelevanceScores(db *sql.DB, qv []float32, qn float64) (map[string]float64, error) {
+	rows, err := readDB(db).Query(`
+		SELECT s.period_key, e.vec, e.l2, e.dim
+		FROM embeddings e
+		JOIN summaries s ON s.id = e.summary_id
+		WHERE s.type = 'fact'
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]float64{}
+	for rows.Next() {
+		var (
+			key  string
+			blob []byte
+			l2   float64
+			dim  int
+		)
+		if err := rows.Scan(&key, &blob, &l2, &dim); err != nil {
+			continue
+		}
+		if l2 == 0 {
+			continue
+		}
+		if dim != len(qv) {
+			noteQueryDimMismatch(dim, len(qv))
+			continue
+		}
+		dot, ok := dotProductExactDim(qv, blob, dim)
+		if !ok {
+			continue
+		}
+		if s := dot / (qn * l2); !math.IsNaN(s) && !math.IsInf(s, 0) {
+			out[strings.TrimPrefix(key, "fact:")] = s
+		}
+	}
+	return out, rows.Err()
+}
+
+// selectContextFacts picks the remembered facts injected for question.
+// note is empty when every allowed fact went in.
+func selectContextFacts(cfg Config, db *sql.DB, question string) (facts []string, note string, err error) {
+	if db == nil {
+		return nil, "", nil
+	}
+	all, err := loadFactCandidates(cfg, db, factTagPolicyFor(cfg))
+	if err != nil || len(all) == 0 {
+		return nil, "", err
+	}
+	max := cfg.FactInjectMax
+	if max <= 0 {
+		max = defaultFactInjectMax
+	}
+
+	// newest first, always-facts ahead (stable keeps recency within each group)
+	newest := func(reason string) ([]string, string) {
+		var always, rest []factCandidate
+		for _, c := range all {
+			if c.always {
+				always = append(always, c)
+			} else {
+				rest = append(rest, c)
+			}
+		}
+		var out []string
+		for _, c := range append(always, applyCategoryBudgets(rest, max-len(always), cfg.FactCategoryBudgets)...) {
+			out = append(out, c.fact)
+		}
+		if len(out) == len(all) {
+			return out, ""
+		}
+		return out, fmt.Sprintf("facts %d/%d: newest (%s)", len(out), len(all), reason)
+	}
+
+	question = strings.TrimSpace(question)
+	if len(all) <= cfg.FactRelevanceMinFacts {
+		facts, note = newest("few facts")
+		return facts, note, nil
+	}
+	if question == "" {
+		facts, note = newest("no question")
+		return facts, note, nil
+	}
+	qv, qn, err := embedQueryText(cfg, question)
+	if err != nil || qn == 0 {
+		facts, note = newest("embed unavailable")
+		return facts, note, nil
+	}
+	scores, err := factRelevanceScores(db, qv, qn)
+	if err != nil {
+		facts, note = newest("scores unavailable")
+		return facts, note, nil
+	}
+
+	var always, relevant []factCandidate
+	for _, c := range all {
+		c.score = scores[c.key]
+		switch {
+		case c.always:
+			always = append(always, c)
+		case c.score >= cfg.FactRelevanceMinScore:
+			relevant = append(relevant, c)
+		}
+	}
+	if cfg.FactInjectOrder != "recent" {
+		sort.SliceStable(relevant, func(i, j int) bool { return relevant[i].score > relevant[j].score })
+	}
+	relevant = applyCategoryBudgets(relevant, max-len(always), cfg.FactCategoryBudgets)
+	for _, c := range always {
+		facts = append(facts, c.fact)
+	}
+	for _, c := range relevant {
+		facts = append(facts, c.fact)
+	}
+	note = fmt.Sprintf("facts %d/%d: %d always, %d relevant (>=%.2f, %s)",
+		len(facts), len(all), len(always), len(relevant), cfg.FactRelevanceMinScore, cfg.FactInjectOrder)
+	return facts, note, nil
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Fact restore (from the fact history)
+// - POST /api/facts/:key/restore, CLI / chat: /restore <fact or fact_key>
+// - Re-activates the latest archived / forgotten version in
+//   user_facts_history and records it as a new "active" version
+//   (source_type "restore", source_key "history:<id>").
+// - Unlike /restore fact <id> (trash.go) it also works after the trash was
+//   purged and for facts archived by a merge or a conflict replace.
+// - Refused when the key is active again, or when another active fact holds
+//   the same slot (forget that one first).
+// ============================================================
+
+const factRestoreSource = "restore"
+
+var (
+	errFactNotRestorable   = errors.New("no archived or forgotten version of this fact")
+	errFactRestoreConflict = errors.New("cannot restore")
+)
+
+// FactRestoreResult reports one RestoreFact.
+type FactRestoreResult struct {
+	FactKey     string `json:"fact_key"`
+	Fact        string `json:"fact"`
+	Version     int    `json:"version"`      // the new history version
+	FromStatus  string `json:"from_status"`  // archived | forgotten
+	FromVersion int    `json:"from_version"` // the version brought back
+}
+
+// RestoreFact re-activates ref, a fact_key or the text of a fact of cfg.User.
+func RestoreFact(cfg Config, db *sql.DB, ref string) (*FactRestoreResult, error) {
+	ref = strings.TrimSpace(ref)
+	if db == nil || ref == "" {
+		return nil, errFactNotRestorable
+	}
+	now := time.Now().In(cfg.Location)
+
+	var res *FactRestoreResult
+	err := withDBRetry(3, 25*time.Millisecond, func() error {
+		return withTx(db, func(tx *sql.Tx) error {
+			var histID int64
+			r := FactRestoreResult{}
+			rows, err := tx.Query(`
+				SELECT id, fact_key, fact, status, version FROM user_facts_history
+				WHERE status IN ('archived','forgotten') AND (fact_key IN (?, ?, ?) OR fact=?)
+				ORDER BY version DESC, id DESC
+			`, ref, userFactKey(cfg.User, ref), deriveUserFactKey(cfg, ref), ref)
+			if err != nil {
+				return err
+			}
+			found := false
+			for rows.Next() {
+				var c FactRestoreResult
+				var id int64
+				if err := rows.Scan(&id, &c.FactKey, &c.Fact, &c.FromStatus, &c.FromVersion); err != nil {
+					continue
+				}
+				// key or text, only the user's own facts count (another user's key is not restorable here)
+				if keyUser(c.FactKey) == cfg.User {
+					histID, r, found = id, c, true
+					break
+				}
+			}
+			rows.Close()
+			if !found {
+				return errFactNotRestorable
+			}
+
+			if err := checkFactRestore(tx, r.FactKey, r.Fact); err != nil {
+				return err
+			}
+
+			if err := upsertUserFact(tx, r.Fact, r.FactKey, true, now); err != nil {
+				return err
+			}
+			r.Version = nextUserFactVersion(tx, r.FactKey)
+			if err := appendUserFactHistory(tx, r.FactKey, r.Fact, "active", factRestoreSource, "history:"+itoa64(histID), now, r.Version); err != nil {
+				return err
+			}
+			res = &r
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = syncFactToSearch(cfg, db, res.FactKey, res.Fact, factRestoreSource)
+	return res, nil
+}
+
+// checkFactRestore refuses to re-activate fact under key when the key is
+// active again or another active fact of the same user holds its slot
+// (shared by RestoreFact and the trash restore).
+func checkFactRestore(tx dbTX, key, fact string) error {
+	if cur, ok := getActiveUserFactByKey(tx, key); ok {
+		if cur == fact {
+			return fmt.Errorf("%w: already active", errFactRestoreConflict)
+		}
+		return fmt.Errorf("%w: conflicts with active fact %q (forget it first)", errFactRestoreConflict, cur)
+	}
+	if slot := ExtractFactTriple(fact).SlotKey(); slot != "" {
+		if k, f, ok := getActiveUserFactBySlotKey(tx, keyUser(key), slot); ok && k != key {
+			return fmt.Errorf("%w: conflicts with active fact %q (forget it first)", errFactRestoreConflict, f)
+		}
+	}
+	return nil
+}
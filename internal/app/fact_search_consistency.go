@@ -0,0 +1,184 @@
+package app
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// ============================================================
+// Fact search rows: lifecycle + consistency
+// - Every active user_fact has exactly one summaries row (type='fact',
+//   period_key='fact:<fact_key>', text = the fact) plus its embedding;
+//   UNIQUE(type, period_key) keeps it single.
+// - Forget deletes the row and its embedding (removeFactFromSearch);
+//   remember / trash restore / conflict replace recreate it (syncFactToSearch).
+// - Older versions only re-marked source_path ("forgotten") and kept the row,
+//   and fact key migrations / wipes could leave rows behind. The checker finds:
+//     orphan   row without an active fact behind it
+//     missing  active fact without a row
+//     stale    row text differs from the fact
+//   GET /api/facts/search-consistency reports, POST repairs; the bg job
+//   "fact_sync" repairs once a day (enqueued on day change).
+// ============================================================
+
+const (
+	jobKindFactSync = "fact_sync"
+
+	factSearchSampleMax = 20
+)
+
+type FactSearchReport struct {
+	Rows    int      `json:"rows"`   // type='fact' summaries rows
+	Active  int      `json:"active"` // active user_facts
+	Orphan  []string `json:"orphan"` // period_key of orphan rows (sample)
+	Missing []string `json:"missing"`
+	Stale   []string `json:"stale"`
+
+	OrphanN  int `json:"orphan_n"`
+	MissingN int `json:"missing_n"`
+	StaleN   int `json:"stale_n"`
+
+	Repaired *FactSearchRepair `json:"repaired,omitempty"`
+}
+
+type FactSearchRepair struct {
+	Deleted  int `json:"deleted"`
+	Synced   int `json:"synced"`
+	Failures int `json:"failures"`
+}
+
+func (r FactSearchReport) consistent() bool {
+	return r.OrphanN == 0 && r.MissingN == 0 && r.StaleN == 0
+}
+
+// CheckFactSearchConsistency compares user_facts with the fact search rows.
+func CheckFactSearchConsistency(db *sql.DB) (FactSearchReport, error) {
+	rep := FactSearchReport{Orphan: []string{}, Missing: []string{}, Stale: []string{}}
+	if db == nil {
+		return rep, nil
+	}
+	_ = db.QueryRow(`SELECT COUNT(*) FROM summaries WHERE type='fact'`).Scan(&rep.Rows)
+	_ = db.QueryRow(`SELECT COUNT(*) FROM user_facts WHERE is_active=1`).Scan(&rep.Active)
+
+	collect := func(q string, n *int, sample *[]string) error {
+		rows, err := db.Query(q)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				return err
+			}
+			*n++
+			if len(*sample) < factSearchSampleMax {
+				*sample = append(*sample, key)
+			}
+		}
+		return rows.Err()
+	}
+	if err := collect(`
+		SELECT s.period_key FROM summaries s
+		WHERE s.type='fact' AND NOT EXISTS (
+			SELECT 1 FROM user_facts f WHERE f.is_active=1 AND 'fact:'||f.fact_key=s.period_key
+		)
+		ORDER BY s.id`, &rep.OrphanN, &rep.Orphan); err != nil {
+		return rep, err
+	}
+	if err := collect(`
+		SELECT f.fact_key FROM user_facts f
+		WHERE f.is_active=1 AND NOT EXISTS (
+			SELECT 1 FROM summaries s WHERE s.type='fact' AND s.period_key='fact:'||f.fact_key
+		)
+		ORDER BY f.id`, &rep.MissingN, &rep.Missing); err != nil {
+		return rep, err
+	}
+	if err := collect(`
+		SELECT f.fact_key FROM user_facts f
+		JOIN summaries s ON s.type='fact' AND s.period_key='fact:'||f.fact_key
+		WHERE f.is_active=1 AND TRIM(s.text)<>TRIM(f.fact)
+		ORDER BY f.id`, &rep.StaleN, &rep.Stale); err != nil {
+		return rep, err
+	}
+	return rep, nil
+}
+
+// RepairFactSearch deletes orphan rows and (re)syncs missing / stale facts,
+// then returns the state after the repair.
+func RepairFactSearch(cfg Config, db *sql.DB) (FactSearchReport, error) {
+	var fix FactSearchRepair
+	if db == nil {
+		return FactSearchReport{}, nil
+	}
+
+	_, err := db.Exec(`
+		DELETE FROM embeddings WHERE summary_id IN (
+			SELECT s.id FROM summaries s
+			WHERE s.type='fact' AND NOT EXISTS (
+				SELECT 1 FROM user_facts f WHERE f.is_active=1 AND 'fact:'||f.fact_key=s.period_key
+			)
+		)`)
+	if err != nil {
+		return FactSearchReport{}, err
+	}
+	res, err := db.Exec(`
+		DELETE FROM summaries
+		WHERE type='fact' AND NOT EXISTS (
+			SELECT 1 FROM user_facts f WHERE f.is_active=1 AND 'fact:'||f.fact_key=summaries.period_key
+		)`)
+	if err != nil {
+		return FactSearchReport{}, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		fix.Deleted = int(n)
+		bumpMemoryVersion()
+	}
+
+	rows, err := db.Query(`
+		SELECT f.fact_key, f.fact FROM user_facts f
+		LEFT JOIN summaries s ON s.type='fact' AND s.period_key='fact:'||f.fact_key
+		WHERE f.is_active=1 AND (s.id IS NULL OR TRIM(s.text)<>TRIM(f.fact))
+		ORDER BY f.id`)
+	if err != nil {
+		return FactSearchReport{}, err
+	}
+	type factRow struct{ key, fact string }
+	var todo []factRow
+	for rows.Next() {
+		var f factRow
+		if rows.Scan(&f.key, &f.fact) == nil {
+			todo = append(todo, f)
+		}
+	}
+	rows.Close()
+	for _, f := range todo {
+		if strings.TrimSpace(f.fact) == "" {
+			continue
+		}
+		dropSummaryEmbedding(db, "fact", "fact:"+f.key)
+		if err := syncFactToSearch(cfg, db, f.key, f.fact, "fact_sync"); err != nil {
+			fix.Failures++
+			continue
+		}
+		fix.Synced++
+	}
+
+	rep, err := CheckFactSearchConsistency(db)
+	rep.Repaired = &fix
+	if fix.Deleted > 0 || fix.Synced > 0 {
+		log.Printf("[info] fact search rows: deleted=%d synced=%d", fix.Deleted, fix.Synced)
+	}
+	return rep, err
+}
+
+// runFactSyncJob is the "fact_sync" bg job.
+func runFactSyncJob(cfg Config, db *sql.DB) error {
+	rep, err := CheckFactSearchConsistency(db)
+	if err != nil || rep.consistent() {
+		return err
+	}
+	_, err = RepairFactSearch(cfg, db)
+	return err
+}
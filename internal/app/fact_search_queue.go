@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Fact search sync queue
+// - syncFactToSearch embeds the fact (one embed call) in the caller; a batch
+//   accept of 50 pending facts did that 50 times inside the HTTP request.
+// - queueFactSearchSync records the fact keys and kicks the worker
+//   ("fact_search_sync", started next to pending_embed), which syncs them
+//   in one pass after the commit. A key queued twice is synced once.
+// - The worker syncs the fact's current text, and skips keys that are no
+//   longer active (forgotten in between).
+// - Anything left behind (process exit, embed server down) is picked up by
+//   the embedding healer / fact_sync job like any other missing row.
+// ============================================================
+
+const factSearchSyncSweepEvery = time.Minute
+
+type factSearchSync struct {
+	key    string
+	source string
+}
+
+var factSearchQueue struct {
+	sync.Mutex
+	items []factSearchSync
+}
+
+var factSearchKick = make(chan struct{}, 1)
+
+// queueFactSearchSync adds facts to the queue and wakes the worker (non-blocking).
+func queueFactSearchSync(items ...factSearchSync) {
+	if len(items) == 0 {
+		return
+	}
+	factSearchQueue.Lock()
+	factSearchQueue.items = append(factSearchQueue.items, items...)
+	factSearchQueue.Unlock()
+	select {
+	case factSearchKick <- struct{}{}:
+	default:
+	}
+}
+
+// runFactSearchSyncWorker drains the queue until ctx is cancelled.
+func runFactSearchSyncWorker(ctx context.Context, cfg Config, db *sql.DB) {
+	if db == nil {
+		return
+	}
+	t := time.NewTicker(factSearchSyncSweepEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-factSearchKick:
+		case <-t.C:
+		}
+		if n := drainFactSearchQueue(cfg, db); n > 0 {
+			log.Printf("[info] fact search sync: %d facts", n)
+		}
+	}
+}
+
+// drainFactSearchQueue syncs every queued fact and returns how many were synced.
+func drainFactSearchQueue(cfg Config, db *sql.DB) int {
+	factSearchQueue.Lock()
+	items := factSearchQueue.items
+	factSearchQueue.items = nil
+	factSearchQueue.Unlock()
+
+	seen := map[string]bool{}
+	n := 0
+	for _, it := range items {
+		if it.key == "" || seen[it.key] {
+			continue
+		}
+		seen[it.key] = true
+		fact, ok := getActiveUserFactByKey(db, it.key)
+		if !ok {
+			continue
+		}
+		if err := syncFactToSearch(cfg, db, it.key, fact, it.source); err == nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Subject aliases for fact keys
+// "我本人的名字是…" / "我的名字是…" or "老婆的生日…" / "我老婆的生日…" used to
+// derive different subjects, so conflicts between them went unnoticed.
+// - subject_aliases(alias → canonical), edited via /api/facts/subject_aliases.
+// - extractFactSubject / finalizeTriple map an alias (exact subject, or the
+//   "<alias>的…" prefix, longest alias first) to its canonical subject.
+// - Applying a change re-runs the fact_key migration (fact_key_migrate.go), so
+//   existing facts regroup: same text merges, different text → conflict pool.
+//   dry_run returns the same report without writing anything.
+// - Aliases are flattened (no chains): alias → canonical → other is stored as
+//   alias → other.
+// ============================================================
+
+type SubjectAlias struct {
+	Alias     string `json:"alias"`
+	Canonical string `json:"canonical"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// subjectAliasCache is the in-process copy of subject_aliases. The map is
+// replaced wholesale on reload and never mutated, so readers may keep it.
+var subjectAliasCache struct {
+	sync.RWMutex
+	m map[string]string
+}
+
+func currentSubjectAliases() map[string]string {
+	subjectAliasCache.RLock()
+	defer subjectAliasCache.RUnlock()
+	return subjectAliasCache.m
+}
+
+// resolveSubjectAlias returns the canonical subject for s (s unchanged when no alias applies).
+func resolveSubjectAlias(aliases map[string]string, s string) string {
+	if len(aliases) == 0 || strings.TrimSpace(s) == "" {
+		return s
+	}
+	norm := normalizeSubjectAlias(s)
+	if c, ok := aliases[norm]; ok {
+		return c
+	}
+	best := ""
+	for a := range aliases {
+		if len(a) > len(best) && strings.HasPrefix(norm, a+"的") {
+			best = a
+		}
+	}
+	if best == "" {
+		return s
+	}
+	return aliases[best] + norm[len(best):]
+}
+
+func normalizeSubjectAlias(s string) string {
+	return normalizeFactKey(strings.Trim(s, "\"'“”‘’ "))
+}
+
+// loadSubjectAliases refreshes the cache from the DB (best-effort).
+func loadSubjectAliases(db *sql.DB) error {
+	m := map[string]string{}
+	if db != nil {
+		rows, err := db.Query(`SELECT alias, canonical FROM subject_aliases`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var a, c string
+			if err := rows.Scan(&a, &c); err != nil {
+				return err
+			}
+			m[a] = c
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	subjectAliasCache.Lock()
+	subjectAliasCache.m = m
+	subjectAliasCache.Unlock()
+	return nil
+}
+
+func ListSubjectAliases(db *sql.DB) ([]SubjectAlias, error) {
+	rows, err := db.Query(`SELECT alias, canonical, created_at, updated_at FROM subject_aliases ORDER BY canonical, alias`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []SubjectAlias{}
+	for rows.Next() {
+		var a SubjectAlias
+		if err := rows.Scan(&a.Alias, &a.Canonical, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// applySubjectAliasChange returns a copy of cur with the change applied.
+// action: set (default) | delete.
+func applySubjectAliasChange(cur map[string]string, alias, canonical, action string) (map[string]string, string, string, error) {
+	alias = normalizeSubjectAlias(alias)
+	canonical = normalizeSubjectAlias(canonical)
+	if alias == "" {
+		return nil, "", "", errors.New("alias is required")
+	}
+	next := make(map[string]string, len(cur)+1)
+	for k, v := range cur {
+		next[k] = v
+	}
+
+	switch strings.ToLower(strings.TrimSpace(action)) {
+	case "", "set":
+		if canonical == "" {
+			return nil, "", "", errors.New("canonical is required")
+		}
+		if c, ok := next[canonical]; ok {
+			canonical = c // flatten: alias → (canonical → c)
+		}
+		if canonical == alias {
+			return nil, "", "", fmt.Errorf("alias %q would point to itself", alias)
+		}
+		next[alias] = canonical
+		for k, v := range next {
+			if v == alias {
+				next[k] = canonical
+			}
+		}
+	case "delete":
+		if _, ok := next[alias]; !ok {
+			return nil, "", "", fmt.Errorf("alias not found: %s", alias)
+		}
+		delete(next, alias)
+		canonical = ""
+	default:
+		return nil, "", "", fmt.Errorf("invalid action: %s (set|delete)", action)
+	}
+	return next, alias, canonical, nil
+}
+
+// PreviewSubjectAlias reports how existing facts would regroup (nothing is written).
+func PreviewSubjectAlias(cfg Config, db *sql.DB, alias, canonical, action string) (FactKeyMigrationReport, error) {
+	next, _, _, err := applySubjectAliasChange(currentSubjectAliases(), alias, canonical, action)
+	if err != nil {
+		return FactKeyMigrationReport{}, err
+	}
+	derive := func(content string) string { return deriveFactKeyWithAliases(content, next) }
+	return migrateFactKeys(db, time.Now().In(cfg.Location), derive, true)
+}
+
+// SetSubjectAlias stores the change and regroups existing facts.
+func SetSubjectAlias(cfg Config, db *sql.DB, alias, canonical, action string) (FactKeyMigrationReport, error) {
+	_, alias, canonical, err := applySubjectAliasChange(currentSubjectAliases(), alias, canonical, action)
+	if err != nil {
+		return FactKeyMigrationReport{}, err
+	}
+	now := time.Now().In(cfg.Location)
+	ts := now.Format(time.RFC3339)
+
+	err = withTx(db, func(tx *sql.Tx) error {
+		if canonical == "" {
+			_, err := tx.Exec(`DELETE FROM subject_aliases WHERE alias=?`, alias)
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO subject_aliases(alias, canonical, created_at, updated_at) VALUES(?,?,?,?)
+			ON CONFLICT(alias) DO UPDATE SET canonical=excluded.canonical, updated_at=excluded.updated_at
+		`, alias, canonical, ts, ts); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE subject_aliases SET canonical=?, updated_at=? WHERE canonical=?`, canonical, ts, alias)
+		return err
+	})
+	if err != nil {
+		return FactKeyMigrationReport{}, err
+	}
+	if err := loadSubjectAliases(db); err != nil {
+		return FactKeyMigrationReport{}, err
+	}
+	return MigrateUserFactKeys(db, now)
+}
+
+// subjectAliasGroups lists canonical → aliases (sorted), for display.
+func subjectAliasGroups(items []SubjectAlias) map[string][]string {
+	out := map[string][]string{}
+	for _, a := range items {
+		out[a.Canonical] = append(out[a.Canonical], a.Alias)
+	}
+	for _, v := range out {
+		sort.Strings(v)
+	}
+	return out
+}
@@ -0,0 +1,500 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Fact tags
+// - user_fact_tags: (fact_key, tag) pairs, user-defined categories
+//   such as work / family / health / preferences
+// - tags are keyed by fact_key, so they survive replace/forget cycles
+// - FactTagPolicy decides which tagged facts may enter the chat context
+// ============================================================
+
+const maxFactTagRunes = 32
+
+// factTagNone is an Include entry no tag can equal (tags never contain
+// spaces): an Include narrowed to nothing must match nothing, not everything.
+const factTagNone = " none"
+
+type FactTagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// FactTagPolicy filters remembered facts by tag before they are injected into a prompt.
+// - Include: if non-empty, only facts carrying at least one of these tags are injected.
+// - Exclude: facts carrying any of these tags are never injected (wins over Include).
+type FactTagPolicy struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func (p FactTagPolicy) IsZero() bool {
+	return len(p.Include) == 0 && len(p.Exclude) == 0
+}
+
+// Allows reports whether a fact with the given tags passes the policy.
+func (p FactTagPolicy) Allows(tags []string) bool {
+	for _, t := range tags {
+		for _, x := range p.Exclude {
+			if t == x {
+				return false
+			}
+		}
+	}
+	if len(p.Include) == 0 {
+		return true
+	}
+	for _, t := range tags {
+		for _, x := range p.Include {
+			if t == x {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// narrowInclude restricts Include to tags. With no configured Include, tags
+// become the Include; otherwise only the configured tags among them stay, so
+// a request can narrow the operator's policy but never widen it.
+func (p FactTagPolicy) narrowInclude(tags []string) FactTagPolicy {
+	if len(tags) == 0 {
+		return p
+	}
+	if len(p.Include) == 0 {
+		p.Include = tags
+		return p
+	}
+	var keep []string
+	for _, t := range tags {
+		for _, x := range p.Include {
+			if t == x {
+				keep = append(keep, t)
+				break
+			}
+		}
+	}
+	if len(keep) == 0 {
+		keep = []string{factTagNone}
+	}
+	p.Include = keep
+	return p
+}
+
+// normalizeFactTag lowercases and trims a tag ("#Work " -> "work").
+// It returns "" for tags that are empty, too long or contain separators.
+func normalizeFactTag(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "#")
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || len([]rune(s)) > maxFactTagRunes {
+		return ""
+	}
+	if strings.ContainsAny(s, " \t\n,，#") {
+		return ""
+	}
+	return s
+}
+
+// normalizeFactTags normalizes and de-duplicates tags, dropping invalid ones.
+func normalizeFactTags(in []string) []string {
+	seen := make(map[string]struct{}, len(in))
+	var out []string
+	for _, t := range in {
+		t = normalizeFactTag(t)
+		if t == "" {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+// parseFactTagList splits "work, family #health" style input into normalized tags.
+func parseFactTagList(s string) []string {
+	s = strings.ReplaceAll(s, "，", ",")
+	s = strings.ReplaceAll(s, "#", " ")
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	return normalizeFactTags(parts)
+}
+
+// splitFactAndTags separates trailing "#tag" words from a fact text:
+// "我的邮箱是a@b.com #work #contact" -> ("我的邮箱是a@b.com", [work contact]).
+func splitFactAndTags(arg string) (fact string, tags []string) {
+	var parts []string
+	for _, f := range strings.Fields(arg) {
+		if strings.HasPrefix(f, "#") && len(f) > 1 {
+			tags = append(tags, f)
+			continue
+		}
+		parts = append(parts, f)
+	}
+	return strings.TrimSpace(strings.Join(parts, " ")), normalizeFactTags(tags)
+}
+
+// resolveFactKeyForTagging finds the fact_key of a fact referenced by text or by key.
+// Order: exact fact_key -> derived key -> (subject, relation) slot.
+func resolveFactKeyForTagging(db dbTX, user, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if db == nil || ref == "" {
+		return ""
+	}
+	var k string
+	if err := db.QueryRow(`SELECT fact_key FROM user_facts WHERE fact_key=? LIMIT 1`, ref).Scan(&k); err == nil {
+		return k
+	}
+	key := userFactKey(user, deriveFactKeyFromSubject(ref))
+	if key != "" {
+		if err := db.QueryRow(`SELECT fact_key FROM user_facts WHERE fact_key=? LIMIT 1`, key).Scan(&k); err == nil {
+			return k
+		}
+	}
+	if slot := ExtractFactTriple(ref).SlotKey(); slot != "" {
+		if existingKey, _, ok := getActiveUserFactBySlotKey(db, user, slot); ok {
+			return existingKey
+		}
+	}
+	return ""
+}
+
+// SetFactTags replaces, extends or prunes the tags of one fact.
+// action: set | add | remove (default: set). This function is transactional.
+func SetFactTags(cfg Config, db *sql.DB, factKey string, tags []string, action string) ([]string, error) {
+	factKey = strings.TrimSpace(factKey)
+	if db == nil || factKey == "" {
+		return nil, errors.New("fact not found")
+	}
+	tags = normalizeFactTags(tags)
+	action = strings.ToLower(strings.TrimSpace(action))
+	if action == "" {
+		action = "set"
+	}
+	if action != "set" && action != "add" && action != "remove" {
+		return nil, errors.New("invalid action: expected set|add|remove")
+	}
+
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	ts := time.Now().In(loc).Format(time.RFC3339)
+
+	var out []string
+	err := withDBRetry(3, 25*time.Millisecond, func() error {
+		return withTx(db, func(tx *sql.Tx) error {
+			var one int
+			if err := tx.QueryRow(`SELECT 1 FROM user_facts WHERE fact_key=? LIMIT 1`, factKey).Scan(&one); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errors.New("fact not found")
+				}
+				return err
+			}
+
+			if action == "set" {
+				if _, err := tx.Exec(`DELETE FROM user_fact_tags WHERE fact_key=?`, factKey); err != nil {
+					return err
+				}
+			}
+			for _, t := range tags {
+				var err error
+				if action == "remove" {
+					_, err = tx.Exec(`DELETE FROM user_fact_tags WHERE fact_key=? AND tag=?`, factKey, t)
+				} else {
+					_, err = tx.Exec(`
+						INSERT INTO user_fact_tags(fact_key, tag, created_at)
+						VALUES(?,?,?)
+						ON CONFLICT(fact_key, tag) DO NOTHING
+					`, factKey, t, ts)
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+			m, err := loadFactTags(tx, []string{factKey})
+			if err != nil {
+				return err
+			}
+			out = m[factKey]
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// factTagsChunk bounds the keys per user_fact_tags query (SQLite's variable limit).
+const factTagsChunk = 500
+
+// loadFactTags returns tags per fact_key (sorted; only the rows of factKeys are read).
+// Missing keys have no entry.
+func loadFactTags(db dbTX, factKeys []string) (map[string][]string, error) {
+	out := map[string][]string{}
+	if db == nil || len(factKeys) == 0 {
+		return out, nil
+	}
+	for len(factKeys) > 0 {
+		chunk := factKeys[:min(len(factKeys), factTagsChunk)]
+		factKeys = factKeys[len(chunk):]
+		args := make([]any, 0, len(chunk))
+		for _, k := range chunk {
+			args = append(args, k)
+		}
+		rows, err := db.Query(`SELECT fact_key, tag FROM user_fact_tags WHERE fact_key IN (?`+strings.Repeat(`,?`, len(chunk)-1)+`) ORDER BY tag`, args...)
+		if err != nil {
+			return out, err
+		}
+		for rows.Next() {
+			var k, t string
+			if err := rows.Scan(&k, &t); err != nil {
+				continue
+			}
+			out[k] = append(out[k], t)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// ListFactTags returns all tags in use by active facts with their counts.
+func ListFactTags(db *sql.DB) ([]FactTagCount, error) {
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(`
+		SELECT t.tag, COUNT(1)
+		FROM user_fact_tags t
+		JOIN user_facts f ON f.fact_key = t.fact_key
+		WHERE f.is_active=1
+		GROUP BY t.tag
+		ORDER BY COUNT(1) DESC, t.tag
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FactTagCount
+	for rows.Next() {
+		var c FactTagCount
+		if err := rows.Scan(&c.Tag, &c.Count); err != nil {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// attachFactTags fills UserFactRow.Tags for a list of rows (best-effort).
+func attachFactTags(db *sql.DB, rows []UserFactRow) []UserFactRow {
+	if len(rows) == 0 {
+		return rows
+	}
+	keys := make([]string, 0, len(rows))
+	for _, r := range rows {
+		keys = append(keys, r.FactKey)
+	}
+	m, err := loadFactTags(db, keys)
+	if err != nil {
+		return rows
+	}
+	for i := range rows {
+		rows[i].Tags = m[rows[i].FactKey]
+	}
+	return rows
+}
+
+// ListActiveFactsByTag lists active facts that carry the given tag (newest first).
+func ListActiveFactsByTag(db *sql.DB, user, tag string, limit int) ([]UserFactRow, error) {
+	tag = normalizeFactTag(tag)
+	if tag == "" {
+		return ListActiveFacts(db, user, limit)
+	}
+	if db == nil {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := db.Query(`SELECT f.fact_key, f.fact, f.is_active, COALESCE(f.is_core,0), COALESCE(f.category,''), f.created_at, f.updated_at, `+factUsageColsF+`
+FROM user_facts f
+JOIN user_fact_tags t ON t.fact_key = f.fact_key
+WHERE f.is_active = 1 AND t.tag = ? AND f.user_id = ?
+ORDER BY f.updated_at DESC
+LIMIT ?`, tag, user, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UserFactRow
+	for rows.Next() {
+		var r UserFactRow
+		var active, core int
+		if err := rows.Scan(&r.FactKey, &r.Fact, &active, &core, &r.Category, &r.CreatedAt, &r.UpdatedAt, &r.InjectCount, &r.SlotHits, &r.LastUsedAt); err != nil {
+			return nil, err
+		}
+		r.IsActive = active != 0
+		r.IsCore = core != 0
+		out = append(out, r)
+	}
+	return attachFactTags(db, out), nil
+}
+
+// loadActiveUserFactsWithPolicy behaves like loadActiveUserFacts but applies a tag policy.
+// The limit is applied after filtering so excluded facts don't eat the budget.
+func loadActiveUserFactsWithPolicy(db *sql.DB, user string, limit int, policy FactTagPolicy) ([]string, error) {
+	if policy.IsZero() {
+		return loadActiveUserFacts(db, user, limit)
+	}
+	if db == nil {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := db.Query(`
+		SELECT fact_key, fact
+		FROM user_facts
+		WHERE is_active=1 AND user_id=?
+		ORDER BY updated_at DESC
+	`, user)
+	if err != nil {
+		return nil, err
+	}
+	type kv struct{ key, fact string }
+	var all []kv
+	for rows.Next() {
+		var r kv
+		if err := rows.Scan(&r.key, &r.fact); err != nil {
+			continue
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+
+	keys := make([]string, 0, len(all))
+	for _, r := range all {
+		keys = append(keys, r.key)
+	}
+	tags, err := loadFactTags(db, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, r := range all {
+		if !policy.Allows(tags[r.key]) {
+			continue
+		}
+		out = append(out, r.fact)
+		if len(out) >= limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// filterFactHitsByPolicy drops fact-type search hits whose fact is blocked by the tag policy.
+// Non-fact hits (daily/weekly/monthly) are untouched.
+func filterFactHitsByPolicy(db *sql.DB, hits []SearchHit, policy FactTagPolicy) []SearchHit {
+	if policy.IsZero() || len(hits) == 0 {
+		return hits
+	}
+	var keys []string
+	for _, h := range hits {
+		if h.Type == "fact" {
+			keys = append(keys, strings.TrimPrefix(h.Date, "fact:"))
+		}
+	}
+	if len(keys) == 0 {
+		return hits
+	}
+	tags, err := loadFactTags(db, keys)
+	if err != nil {
+		return hits
+	}
+	out := hits[:0:0]
+	for _, h := range hits {
+		if h.Type == "fact" && !policy.Allows(tags[strings.TrimPrefix(h.Date, "fact:")]) {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// runFactTagCommand implements /tag, /untag and /tags for both CLI and web.
+//
+//	/tag <fact or fact_key> #work #family   (add tags)
+//	/untag <fact or fact_key> #work         (remove tags)
+//	/tags [#tag]                            (list tags, or facts with a tag)
+func runFactTagCommand(cfg Config, db *sql.DB, cmd, arg string) (string, error) {
+	if cmd == "/tags" {
+		if tag := normalizeFactTag(arg); tag != "" {
+			items, err := ListActiveFactsByTag(db, cfg.User, tag, 200)
+			if err != nil {
+				return "", err
+			}
+			if len(items) == 0 {
+				return "no facts tagged #" + tag, nil
+			}
+			var b strings.Builder
+			for _, it := range items {
+				b.WriteString("- " + it.Fact + "\n")
+			}
+			return strings.TrimRight(b.String(), "\n"), nil
+		}
+		items, err := ListFactTags(db)
+		if err != nil {
+			return "", err
+		}
+		if len(items) == 0 {
+			return "no tags yet", nil
+		}
+		var b strings.Builder
+		for _, it := range items {
+			b.WriteString("#" + it.Tag + " (" + itoa64(int64(it.Count)) + ")\n")
+		}
+		return strings.TrimRight(b.String(), "\n"), nil
+	}
+
+	usage := "usage: " + cmd + " <fact> #tag [#tag...]"
+	ref, tags := splitFactAndTags(arg)
+	if ref == "" || len(tags) == 0 {
+		return usage, nil
+	}
+	key := resolveFactKeyForTagging(db, cfg.User, ref)
+	if key == "" {
+		return "[noop] fact not found", nil
+	}
+	action := "add"
+	if cmd == "/untag" {
+		action = "remove"
+	}
+	cur, err := SetFactTags(cfg, db, key, tags, action)
+	if err != nil {
+		return "", err
+	}
+	if len(cur) == 0 {
+		return "[ok] tags updated: (none)", nil
+	}
+	return "[ok] tags updated: #" + strings.Join(cur, " #"), nil
+}
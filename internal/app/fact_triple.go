@@ -15,8 +15,9 @@ type FactTriple struct {
 	Object       string
 	SubjectKey   string // normalized
 	RelationKey  string // canonical
-	ObjectNorm   string // normalized
+	ObjectNorm   string // canonical per relation (dates / phones / emails, see fact_object_norm.go)
 	SingleValued bool
+	MultiValued  bool // like / dislike / good_at: values accumulate (fact_multivalue.go)
 }
 
 // SlotKey returns a stable key representing the (subject, relation) slot.
@@ -31,6 +32,14 @@ func (t FactTriple) SlotKey() string {
 	return "slot:" + t.SubjectKey + "|" + t.RelationKey
 }
 
+// SetKey returns the (subject, relation) key of a multi-valued relation ("" otherwise).
+func (t FactTriple) SetKey() string {
+	if !t.MultiValued || t.SubjectKey == "" || t.RelationKey == "" {
+		return ""
+	}
+	return "set:" + t.SubjectKey + "|" + t.RelationKey
+}
+
 // ExtractFactTriple tries to extract (subject, relation, object) from a natural-language fact.
 // It returns an empty triple if parsing is not confident enough.
 func ExtractFactTriple(fact string) FactTriple {
@@ -43,6 +52,10 @@ func ExtractFactTriple(fact string) FactTriple {
 	// normalize whitespace
 	fact = strings.Join(strings.Fields(fact), " ")
 
+	// Preferences: "<subj>喜欢<obj>" / "<subj> likes <obj>" (multi-valued)
+	if subj, rel, obj, ok := parsePreference(fact); ok {
+		return finalizeTriple(subj, rel, obj)
+	}
 	// Chinese patterns with possessive: "<subj>的<attr>是<obj>"
 	// Example: "娜娜的真名是刘娜" => subj="娜娜", rel="真名是", obj="刘娜"
 	if subj, rel, obj, ok := parseChinesePossessiveIs(fact); ok {
@@ -72,6 +85,8 @@ func finalizeTriple(subject, relation, object string) FactTriple {
 	if subject == "" || relation == "" || object == "" {
 		return FactTriple{}
 	}
+	// "我本人" / "我老婆" → configured canonical subject (subject_aliases)
+	subject = resolveSubjectAlias(currentSubjectAliases(), subject)
 	// clean leading possessives like "我的" => subject "我"
 	if strings.HasPrefix(subject, "我的") {
 		subject = "我"
@@ -93,8 +108,9 @@ func finalizeTriple(subject, relation, object string) FactTriple {
 		Object:       object,
 		SubjectKey:   "sub:" + normalizeFactKey(subject),
 		RelationKey:  "rel:" + canonRel,
-		ObjectNorm:   normalizeFactKey(object),
+		ObjectNorm:   normalizeObjectValue(canonRel, object),
 		SingleValued: single,
+		MultiValued:  !single,
 	}
 }
 
@@ -151,6 +167,10 @@ func canonicalRelationKey(relation string) (key string, single bool) {
 	if strings.Contains(r, "work") || strings.Contains(r, "company") || strings.Contains(r, "job") || strings.Contains(r, "title") {
 		return "job", true
 	}
+	// multi-valued (set semantics, no slot): 喜欢 / 讨厌 / 擅长 / like ...
+	if k := multiValuedRelationKey(r); k != "" {
+		return k, false
+	}
 	// identity: "是" / "is" / "are" / "为"
 	if r == "是" || r == "就是" || r == "为" || r == "is" || r == "are" {
 		return "identity", true
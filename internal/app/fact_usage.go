@@ -0,0 +1,87 @@
+package app
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Fact usage telemetry
+// - inject_count: the fact was in the remembered_fact block of a real chat
+//   turn (not the context audit / debug view / incognito turns).
+// - slot_hit_count: getActiveUserFactBySlotKey / findFactInValueSet matched it
+//   (conflict detection, /forget by slot, corrections, set repeats).
+// - last_used_at: either of the above. updated_at is NOT touched, so the
+//   injection order (updated_at DESC) does not change with usage.
+// - Exposed on ListActiveFacts rows; GET /api/facts/active?sort=unused lists
+//   the least used first (pruning candidates).
+// ============================================================
+
+const (
+	factUsageCols  = `COALESCE(inject_count,0), COALESCE(slot_hit_count,0), COALESCE(last_used_at,'')`
+	factUsageColsF = `COALESCE(f.inject_count,0), COALESCE(f.slot_hit_count,0), COALESCE(f.last_used_at,'')`
+)
+
+// recordFactInjections bumps inject_count for every fact of the (non-dropped)
+// remembered_fact blocks. Best-effort.
+func recordFactInjections(db *sql.DB, blocks []PromptBlock, now time.Time) {
+	if db == nil {
+		return
+	}
+	var facts []string
+	for _, b := range blocks {
+		if b.Source != "remembered_fact" || b.Trace == nil || b.Trace.Dropped {
+			continue
+		}
+		facts = append(facts, b.Trace.Facts...)
+	}
+	if len(facts) == 0 {
+		return
+	}
+	ts := now.Format(time.RFC3339)
+	err := withTx(db, func(tx *sql.Tx) error {
+		for _, f := range facts {
+			if _, err := tx.Exec(`
+				UPDATE user_facts SET inject_count=COALESCE(inject_count,0)+1, last_used_at=?
+				WHERE is_active=1 AND fact=?
+			`, ts, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[warn] fact usage (inject) update failed: %v", err)
+	}
+}
+
+// recordFactSlotHit bumps slot_hit_count for factKey (best-effort; db may be a tx).
+func recordFactSlotHit(db dbTX, factKey string) {
+	if db == nil || factKey == "" {
+		return
+	}
+	_, _ = db.Exec(`
+		UPDATE user_facts SET slot_hit_count=COALESCE(slot_hit_count,0)+1, last_used_at=?
+		WHERE fact_key=?
+	`, time.Now().Format(time.RFC3339), factKey)
+}
+
+// sortFactsByUsage orders rows least used first (never used, then oldest last use).
+func sortFactsByUsage(rows []UserFactRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		ui, uj := rows[i].InjectCount+rows[i].SlotHits, rows[j].InjectCount+rows[j].SlotHits
+		if ui != uj {
+			return ui < uj
+		}
+		return rows[i].LastUsedAt < rows[j].LastUsedAt
+	})
+}
+
+// isUnusedSort reports whether the active list was asked for pruning order.
+func isUnusedSort(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "unused" || s == "least_used"
+}
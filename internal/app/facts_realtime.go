@@ -49,8 +49,26 @@ func maybeAutoProposePendingFromUserInput(cfg Config, db *sql.DB, input string,
 		when = when.In(loc)
 	}
 	sourceKey := when.Format("2006-01-02")
+
+	// Rate limits (per hour / per day / per fact_key cooldown) keep a chatty
+	// self-description session from flooding review.
+	factKey := deriveUserFactKey(cfg, fact)
+	if !implicitCaptureAllow(cfg, factKey, when) {
+		return nil, nil
+	}
+	// Opt-in: ask about a candidate that reads two ways instead of filing it (fact_clarify.go).
+	if cfg.FactClarify {
+		if c, ok := ambiguousFactCandidate(fact); ok {
+			openFactClarification(c, when)
+			implicitCaptureRecord(factKey, when)
+			return &RememberOutcome{Status: "clarify", FactKey: factKey, Question: c.Question}, nil
+		}
+	}
 	// Best-effort, silent. No user-visible acknowledgement.
 	st, err := ProposePendingRememberFact(cfg, db, fact, "realtime_implicit", sourceKey, when)
+	if err == nil && st != nil && st.Status != "noop" {
+		implicitCaptureRecord(factKey, when)
+	}
 	return st, err
 }
 
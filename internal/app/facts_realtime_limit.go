@@ -0,0 +1,107 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Rate limits for implicit (realtime_implicit) fact capture
+// - TIMELAYER_IMPLICIT_MAX_PER_HOUR / _PER_DAY cap proposals (rolling hour,
+//   local calendar day); TIMELAYER_IMPLICIT_COOLDOWN_MINUTES keeps the same
+//   fact_key from being re-proposed while it is still fresh.
+// - Only proposals that changed something (pending / conflict) count.
+// - In-process state: a restart resets the windows (the cooldown is a spam
+//   guard, dedup in pending_facts still applies).
+// - Counters are exposed in GET /api/stats ("implicit_capture").
+// ============================================================
+
+type ImplicitCaptureStats struct {
+	Day           string `json:"day"`
+	ProposedToday int    `json:"proposed_today"`
+	LastHour      int    `json:"last_hour"`
+	ThrottledHour int    `json:"throttled_hour"` // skipped today: hourly cap
+	ThrottledDay  int    `json:"throttled_day"`  // skipped today: daily cap
+	Cooldown      int    `json:"cooldown"`       // skipped today: same fact_key too recent
+	MaxPerHour    int    `json:"max_per_hour"`
+	MaxPerDay     int    `json:"max_per_day"`
+	CooldownMin   int    `json:"cooldown_minutes"`
+}
+
+var implicitLimiter struct {
+	sync.Mutex
+	day       string
+	recent    []time.Time // proposals in the last hour
+	lastByKey map[string]time.Time
+	stats     ImplicitCaptureStats
+}
+
+// implicitRollLocked resets the daily counters and trims the windows. Caller holds the lock.
+func implicitRollLocked(cfg Config, now time.Time) {
+	l := &implicitLimiter
+	if day := now.Format("2006-01-02"); day != l.day {
+		l.day = day
+		l.stats = ImplicitCaptureStats{Day: day}
+	}
+	cut := now.Add(-time.Hour)
+	i := 0
+	for i < len(l.recent) && !l.recent[i].After(cut) {
+		i++
+	}
+	l.recent = l.recent[i:]
+	for k, at := range l.lastByKey {
+		if cfg.ImplicitKeyCooldown <= 0 || now.Sub(at) >= cfg.ImplicitKeyCooldown {
+			delete(l.lastByKey, k)
+		}
+	}
+}
+
+// implicitCaptureAllow reports whether a realtime_implicit proposal for factKey may go ahead.
+func implicitCaptureAllow(cfg Config, factKey string, now time.Time) bool {
+	l := &implicitLimiter
+	l.Lock()
+	defer l.Unlock()
+	implicitRollLocked(cfg, now)
+
+	if at, ok := l.lastByKey[factKey]; ok && factKey != "" && now.Sub(at) < cfg.ImplicitKeyCooldown {
+		l.stats.Cooldown++
+		return false
+	}
+	if cfg.ImplicitMaxPerDay > 0 && l.stats.ProposedToday >= cfg.ImplicitMaxPerDay {
+		l.stats.ThrottledDay++
+		return false
+	}
+	if cfg.ImplicitMaxPerHour > 0 && len(l.recent) >= cfg.ImplicitMaxPerHour {
+		l.stats.ThrottledHour++
+		return false
+	}
+	return true
+}
+
+// implicitCaptureRecord counts a proposal that reached pending / conflicts.
+func implicitCaptureRecord(factKey string, now time.Time) {
+	l := &implicitLimiter
+	l.Lock()
+	defer l.Unlock()
+	l.recent = append(l.recent, now)
+	l.stats.ProposedToday++
+	if factKey != "" {
+		if l.lastByKey == nil {
+			l.lastByKey = map[string]time.Time{}
+		}
+		l.lastByKey[factKey] = now
+	}
+}
+
+// GetImplicitCaptureStats returns today's implicit capture counters.
+func GetImplicitCaptureStats(cfg Config, now time.Time) ImplicitCaptureStats {
+	l := &implicitLimiter
+	l.Lock()
+	defer l.Unlock()
+	implicitRollLocked(cfg, now)
+	st := l.stats
+	st.LastHour = len(l.recent)
+	st.MaxPerHour, st.MaxPerDay = cfg.ImplicitMaxPerHour, cfg.ImplicitMaxPerDay
+	st.CooldownMin = int(cfg.ImplicitKeyCooldown / time.Minute)
+	return st
+}
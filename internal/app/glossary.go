@@ -0,0 +1,202 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ============================================================
+// Domain glossary (term → definition)
+// - User-maintained jargon ("TL" → "timelayer, this project", "灰度" → ...),
+//   kept apart from user_facts: never searched, summarized or rolled up.
+// - A "glossary" block is injected only when a term appears in the current
+//   question (case-insensitive; ASCII terms must match as a whole word, so
+//   "go" does not fire on "good"). Low priority: first to go when the
+//   context is over the token budget.
+// - At most glossaryMaxInject terms per turn, longest term first.
+// - Chat: /define <term> = <definition>, /undefine <term>, /glossary.
+//   API: GET / POST /api/glossary, DELETE /api/glossary/:term.
+// - Settings, not memory: `wipe` keeps the glossary.
+// ============================================================
+
+const (
+	glossaryMaxInject     = 12
+	glossaryMaxTermRunes  = 64
+	glossaryMaxDefinition = 500 // runes
+)
+
+type GlossaryEntry struct {
+	Term       string `json:"term"`
+	Definition string `json:"definition"`
+	UpdatedAt  string `json:"updated_at,omitempty"`
+}
+
+// SaveGlossaryEntry adds or replaces a term.
+func SaveGlossaryEntry(cfg Config, db *sql.DB, term, definition string) (GlossaryEntry, error) {
+	e := GlossaryEntry{Term: strings.TrimSpace(term), Definition: strings.TrimSpace(definition)}
+	switch {
+	case e.Term == "":
+		return e, errors.New("term required")
+	case e.Definition == "":
+		return e, errors.New("definition required")
+	case utf8.RuneCountInString(e.Term) > glossaryMaxTermRunes:
+		return e, fmt.Errorf("term too long (max %d chars)", glossaryMaxTermRunes)
+	case utf8.RuneCountInString(e.Definition) > glossaryMaxDefinition:
+		return e, fmt.Errorf("definition too long (max %d chars)", glossaryMaxDefinition)
+	}
+	e.UpdatedAt = time.Now().In(cfg.Location).Format(time.RFC3339)
+	_, err := db.Exec(`
+		INSERT INTO glossary(term, definition, created_at, updated_at)
+		VALUES(?,?,?,?)
+		ON CONFLICT(term) DO UPDATE SET
+		  term=excluded.term,
+		  definition=excluded.definition,
+		  updated_at=excluded.updated_at
+	`, e.Term, e.Definition, e.UpdatedAt, e.UpdatedAt)
+	return e, err
+}
+
+// DeleteGlossaryEntry removes a term (case-insensitive).
+func DeleteGlossaryEntry(db *sql.DB, term string) error {
+	res, err := db.Exec(`DELETE FROM glossary WHERE term=?`, strings.TrimSpace(term))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("unknown term: %s", term)
+	}
+	return nil
+}
+
+// ListGlossary returns every term, alphabetically.
+func ListGlossary(db *sql.DB) ([]GlossaryEntry, error) {
+	rows, err := readDB(db).Query(`SELECT term, definition, updated_at FROM glossary ORDER BY term`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []GlossaryEntry{}
+	for rows.Next() {
+		var e GlossaryEntry
+		if err := rows.Scan(&e.Term, &e.Definition, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// glossaryTermIn reports whether term occurs in text (both lowercased).
+func glossaryTermIn(text, term string) bool {
+	wordChar := func(r rune) bool { return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') }
+	for from := 0; from <= len(text); {
+		i := strings.Index(text[from:], term)
+		if i < 0 {
+			return false
+		}
+		i += from
+		end := i + len(term)
+		first, _ := utf8.DecodeRuneInString(term)
+		last, _ := utf8.DecodeLastRuneInString(term)
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		// ASCII word edges must not run into more ASCII word characters
+		if (!wordChar(first) || i == 0 || !wordChar(before)) && (!wordChar(last) || end == len(text) || !wordChar(after)) {
+			return true
+		}
+		from = i + 1
+	}
+	return false
+}
+
+// matchGlossary returns the entries whose term occurs in question (longest term first).
+func matchGlossary(db *sql.DB, question string) ([]GlossaryEntry, error) {
+	q := strings.ToLower(strings.TrimSpace(question))
+	if db == nil || q == "" {
+		return nil, nil
+	}
+	all, err := ListGlossary(db)
+	if err != nil {
+		return nil, err
+	}
+	var out []GlossaryEntry
+	for _, e := range all {
+		if glossaryTermIn(q, strings.ToLower(e.Term)) {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return len(out[i].Term) > len(out[j].Term) })
+	return out, nil
+}
+
+// buildGlossaryEvidence renders the glossary block for question ("" = no term matched).
+func buildGlossaryEvidence(db *sql.DB, question string) (content string, note string) {
+	entries, err := matchGlossary(db, question)
+	if err != nil || len(entries) == 0 {
+		return "", ""
+	}
+	if len(entries) > glossaryMaxInject {
+		note = fmt.Sprintf("glossary %d/%d terms", glossaryMaxInject, len(entries))
+		entries = entries[:glossaryMaxInject]
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString("- ")
+		b.WriteString(e.Term)
+		b.WriteString("：")
+		b.WriteString(e.Definition)
+		b.WriteString("\n")
+	}
+	return b.String(), note
+}
+
+// runGlossaryCommand implements /define, /undefine and /glossary for both CLI and web.
+//
+//	/define <term> = <definition>
+//	/undefine <term>
+//	/glossary
+func runGlossaryCommand(cfg Config, db *sql.DB, cmd, arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case "/define":
+		term, def, ok := strings.Cut(arg, "=")
+		if !ok {
+			term, def, ok = strings.Cut(arg, "＝")
+		}
+		if !ok || strings.TrimSpace(term) == "" || strings.TrimSpace(def) == "" {
+			return "usage: /define <term> = <definition>", nil
+		}
+		e, err := SaveGlossaryEntry(cfg, db, term, def)
+		if err != nil {
+			return "", err
+		}
+		return "[ok] defined " + e.Term, nil
+	case "/undefine":
+		if arg == "" {
+			return "usage: /undefine <term>", nil
+		}
+		if err := DeleteGlossaryEntry(db, arg); err != nil {
+			return "", err
+		}
+		return "[ok] removed " + arg, nil
+	default:
+		items, err := ListGlossary(db)
+		if err != nil {
+			return "", err
+		}
+		if len(items) == 0 {
+			return "glossary is empty (/define <term> = <definition>)", nil
+		}
+		var b strings.Builder
+		for _, e := range items {
+			b.WriteString("- " + e.Term + ": " + e.Definition + "\n")
+		}
+		return strings.TrimRight(b.String(), "\n"), nil
+	}
+}
@@ -0,0 +1,190 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================
+// Per-route request deadlines
+// - Each API request gets a context deadline from the longest matching route
+//   (entries ending in "/" are prefixes, others exact). 0 = no deadline; SSE
+//   (/api/chat/stream, /api/chat/stream/resume, /api/ask/stream), the
+//   WebSocket chat and the wipe are never cut off.
+// - The handler writes into a buffer; if the deadline passes first the client
+//   gets 504 {"ok":false,"error":"deadline_exceeded",...} and whatever the
+//   handler writes later is discarded. Context-aware work (LLM / embed calls)
+//   is cancelled; a plain SQLite call finishes in the background.
+// - TIMELAYER_HTTP_ROUTE_TIMEOUTS="/api/facts/=5s,/api/export/=0" overrides
+//   single entries; timelayer_http_deadline_exceeded_total is on /metrics.
+// ============================================================
+
+var defaultRouteTimeouts = map[string]time.Duration{
+	"/api/":                     30 * time.Second,
+	"/api/facts/":               10 * time.Second,
+	"/api/facts/conflicts/":     2 * time.Minute, // POST .../suggest asks the chat model (LLM)
+	"/api/facts/pending/groups": 5 * time.Minute, // ?rep=llm asks the chat model per group (LLM)
+	"/api/chat":                 5 * time.Minute, // non-streaming chat (LLM)
+	"/api/ask":                  5 * time.Minute, // memory Q&A (LLM)
+	"/api/chat/stream":          0,               // SSE
+	"/api/chat/stream/resume":   0,               // SSE
+	"/api/ask/stream":           0,               // SSE
+	"/api/chat/ws":              0,               // WebSocket (hijacked, never buffered)
+	"/api/summaries/":           5 * time.Minute, // POST .../regenerate runs the summarizer (LLM)
+	"/api/memory/diff":          5 * time.Minute, // ?narrate=1 asks the chat model (LLM)
+	"/api/archive/restore":      5 * time.Minute, // reprocess re-runs the daily summarizer (LLM)
+	"/api/flows/":               5 * time.Minute, // weekly_review steps run the weekly summarizer (LLM)
+	"/api/export/":              2 * time.Minute,
+	"/api/admin/wipe":           0, // a half-reported wipe is worse than a slow one
+	"/metrics":                  10 * time.Second,
+}
+
+var httpDeadlineExceeded atomic.Int64
+
+// parseRouteTimeouts parses "route=duration,..." (invalid entries are skipped).
+func parseRouteTimeouts(s string) map[string]time.Duration {
+	out := map[string]time.Duration{}
+	for _, part := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		k = strings.TrimSpace(k)
+		if !ok || !strings.HasPrefix(k, "/") {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if v == "0" {
+			out[k] = 0
+			continue
+		}
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			out[k] = d
+		}
+	}
+	return out
+}
+
+// routeTimeout returns the deadline for path and the route entry that matched.
+func routeTimeout(routes map[string]time.Duration, path string) (time.Duration, string) {
+	best, found := "", false
+	for k := range routes {
+		match := k == path || (strings.HasSuffix(k, "/") && strings.HasPrefix(path, k))
+		if match && (!found || len(k) > len(best)) {
+			best, found = k, true
+		}
+	}
+	if !found {
+		return 0, ""
+	}
+	return routes[best], best
+}
+
+// deadlineWriter buffers the response until the handler returns.
+type deadlineWriter struct {
+	mu       sync.Mutex
+	h        http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (w *deadlineWriter) Header() http.Header { return w.h }
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(p)
+}
+
+func (w *deadlineWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut && w.status == 0 {
+		w.status = code
+	}
+}
+
+// withRouteDeadlines wraps h with the per-route deadlines (defaults + overrides).
+func withRouteDeadlines(overrides map[string]time.Duration, h http.Handler) http.Handler {
+	routes := make(map[string]time.Duration, len(defaultRouteTimeouts)+len(overrides))
+	for k, v := range defaultRouteTimeouts {
+		routes[k] = v
+	}
+	for k, v := range overrides {
+		routes[k] = v
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, route := routeTimeout(routes, r.URL.Path)
+		if timeout <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		dw := &deadlineWriter{h: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- capturedPanic{v: p, stack: debug.Stack()}
+				}
+			}()
+			h.ServeHTTP(dw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p) // re-raised for the recover in applyHTTPMiddleware
+		case <-done:
+			dw.mu.Lock()
+			defer dw.mu.Unlock()
+			dst := w.Header()
+			for k, v := range dw.h {
+				dst[k] = v
+			}
+			if dw.status == 0 {
+				dw.status = http.StatusOK
+			}
+			w.WriteHeader(dw.status)
+			_, _ = w.Write(dw.buf.Bytes())
+		case <-ctx.Done():
+			dw.mu.Lock()
+			dw.timedOut = true
+			dw.mu.Unlock()
+			if ctx.Err() != context.DeadlineExceeded {
+				return // client went away
+			}
+			httpDeadlineExceeded.Add(1)
+			reqID := r.Header.Get("X-Request-Id")
+			log.Printf("[http] deadline exceeded req_id=%s path=%s route=%s timeout=%s", reqID, r.URL.Path, route, timeout)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusGatewayTimeout)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"ok":         false,
+				"error":      "deadline_exceeded",
+				"message":    fmt.Sprintf("request exceeded %s", timeout),
+				"route":      route,
+				"timeout_ms": timeout.Milliseconds(),
+				"request_id": reqID,
+			})
+		}
+	})
+}
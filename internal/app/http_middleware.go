@@ -3,6 +3,7 @@ package app
 import (
 	"bufio"
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
 	"errors"
 	"io"
@@ -124,8 +125,8 @@ func newRequestID() string {
 	return hex.EncodeToString([]byte(time.Now().Format("150405.000")))
 }
 
+// clientIP is the TCP peer; see resolveClientIP for trusted proxies.
 func clientIP(r *http.Request) string {
-	// Prefer RemoteAddr for trust; XFF is not trusted by default.
 	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
 		return host
 	}
@@ -137,12 +138,20 @@ type ipRateLimiter struct {
 	rpm    int
 	burst  float64
 	states map[string]*bucket
+	rates  map[string]int // per-key rpm overrides (client tokens, http_rate_limit_tokens.go)
 	ttl    time.Duration
+
+	ban      time.Duration // 0 = no bans
+	banAfter int           // consecutive rejections before a ban
+	dirty    bool          // changed since the last save (http_rate_limit_store.go)
+	kick     chan struct{} // asks the persist loop to save now (new ban)
 }
 
 type bucket struct {
-	tokens float64
-	last   time.Time
+	tokens  float64
+	last    time.Time
+	strikes int       // consecutive rejected requests
+	banned  time.Time // zero = not banned
 }
 
 func newIPRateLimiter(rpm int) *ipRateLimiter {
@@ -150,62 +159,109 @@ func newIPRateLimiter(rpm int) *ipRateLimiter {
 		rpm = 0
 	}
 	return &ipRateLimiter{
-		rpm:    rpm,
-		burst:  float64(maxInt(1, rpm/6)), // ~10s burst
-		states: make(map[string]*bucket),
-		ttl:    10 * time.Minute,
+		rpm:      rpm,
+		burst:    float64(maxInt(1, rpm/6)), // ~10s burst
+		states:   make(map[string]*bucket),
+		rates:    make(map[string]int),
+		ttl:      10 * time.Minute,
+		banAfter: maxInt(10, rpm), // about a minute of requests over the limit
+		kick:     make(chan struct{}, 1),
+	}
+}
+
+// rate returns the rpm and burst of bucket key (l.mu held).
+func (l *ipRateLimiter) rate(key string) (int, float64) {
+	if n, ok := l.rates[key]; ok && n > 0 {
+		return n, float64(maxInt(1, n/6))
 	}
+	return l.rpm, l.burst
 }
 
-func (l *ipRateLimiter) allow(ip string) bool {
+// allow takes one token from the bucket of key (a client IP or a client
+// token key). When it refuses, retry is how long until a request would be
+// let through again.
+func (l *ipRateLimiter) allow(key string) (ok bool, retry time.Duration) {
 	if l == nil || l.rpm <= 0 {
-		return true
+		return true, 0
 	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	rpm, burst := l.rate(key)
 	now := time.Now()
-	b := l.states[ip]
+	b := l.states[key]
 	if b == nil {
-		b = &bucket{tokens: l.burst, last: now}
-		l.states[ip] = b
+		b = &bucket{tokens: burst, last: now}
+		l.states[key] = b
+	}
+	l.dirty = true
+
+	if b.banned.After(now) {
+		return false, b.banned.Sub(now)
 	}
 
 	// refill
-	perSec := float64(l.rpm) / 60.0
+	perSec := float64(rpm) / 60.0
 	dt := now.Sub(b.last).Seconds()
 	if dt > 0 {
 		b.tokens += dt * perSec
-		if b.tokens > l.burst {
-			b.tokens = l.burst
+		if b.tokens > burst {
+			b.tokens = burst
 		}
 		b.last = now
 	}
 
 	if b.tokens < 1.0 {
-		return false
+		b.strikes++
+		if l.ban > 0 && b.strikes >= l.banAfter {
+			b.banned, b.strikes = now.Add(l.ban), 0
+			log.Printf("[http] rate limit: banned %s until %s", key, b.banned.Format(time.RFC3339))
+			select {
+			case l.kick <- struct{}{}:
+			default:
+			}
+			return false, l.ban
+		}
+		return false, time.Duration((1.0 - b.tokens) / perSec * float64(time.Second))
 	}
 	b.tokens -= 1.0
+	b.strikes = 0
 
 	// lazy cleanup
 	if len(l.states) > 2048 {
 		cutoff := now.Add(-l.ttl)
 		for k, v := range l.states {
-			if v.last.Before(cutoff) {
+			if v.last.Before(cutoff) && !v.banned.After(now) {
 				delete(l.states, k)
 			}
 		}
 	}
 
-	return true
+	return true, 0
 }
 
-func applyHTTPMiddleware(cfg Config, h http.Handler) http.Handler {
+func applyHTTPMiddleware(cfg Config, db *sql.DB, h http.Handler) http.Handler {
 	limiter := newIPRateLimiter(cfg.HTTPRateLimitRPM)
+	limiter.ban = time.Duration(cfg.HTTPRateLimitBanMinutes) * time.Minute
+	for tok, rpm := range cfg.HTTPClientTokens {
+		if rpm > 0 {
+			limiter.rates[tokenRateLimitKey(tok)] = rpm
+		}
+	}
+	if db != nil && cfg.HTTPRateLimitPersist && limiter.rpm > 0 {
+		if err := limiter.load(db); err != nil {
+			log.Printf("[warn] rate limit state not restored: %v", err)
+		}
+		go limiter.persistLoop(db, rateLimitSaveEvery)
+	}
+	trusted := parseTrustedProxies(cfg.HTTPTrustedProxies)
+	allowlist := parseIPNets(cfg.HTTPRateLimitAllowlist, "rate limit allowlist entry")
+	h = withRouteDeadlines(cfg.HTTPRouteTimeouts, h)
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqID := newRequestID()
 		r.Header.Set("X-Request-Id", reqID)
+		ip := resolveClientIP(r, trusted)
 
 		rec := &statusRecorder{ResponseWriter: w}
 		start := time.Now()
@@ -213,6 +269,7 @@ func applyHTTPMiddleware(cfg Config, h http.Handler) http.Handler {
 		defer func() {
 			if v := recover(); v != nil {
 				log.Printf("[http] panic req_id=%s method=%s path=%s err=%v", reqID, r.Method, r.URL.Path, v)
+				reportPanic("http", v, nil, map[string]string{"req_id": reqID, "method": r.Method, "path": r.URL.Path, "ip": ip})
 				http.Error(rec, "internal server error", http.StatusInternalServerError)
 			}
 			dur := time.Since(start)
@@ -220,7 +277,7 @@ func applyHTTPMiddleware(cfg Config, h http.Handler) http.Handler {
 			if status == 0 {
 				status = http.StatusOK
 			}
-			log.Printf("[http] req_id=%s ip=%s method=%s path=%s status=%d bytes=%d dur=%s", reqID, clientIP(r), r.Method, r.URL.Path, status, rec.bytes, dur)
+			log.Printf("[http] req_id=%s ip=%s method=%s path=%s status=%d bytes=%d dur=%s", reqID, ip, r.Method, r.URL.Path, status, rec.bytes, dur)
 		}()
 
 		// Basic security headers (avoid CSP here to not break existing UI).
@@ -228,17 +285,19 @@ func applyHTTPMiddleware(cfg Config, h http.Handler) http.Handler {
 		rec.Header().Set("X-Frame-Options", "DENY")
 		rec.Header().Set("Referrer-Policy", "no-referrer")
 
-		// Per-IP rate limit for API endpoints.
-		if strings.HasPrefix(r.URL.Path, "/api/") {
-			if !limiter.allow(clientIP(r)) {
+		// Rate limit for API endpoints: per client token when one is sent,
+		// else per IP; allowlisted addresses are never limited.
+		if isProtectedPath(r.URL.Path) && !ipInNets(parseHopIP(ip), allowlist) {
+			if ok, retry := limiter.allow(rateLimitKey(cfg, r, ip)); !ok {
+				rec.Header().Set("Retry-After", retryAfterSeconds(retry))
 				http.Error(rec, "rate limit exceeded", http.StatusTooManyRequests)
 				return
 			}
 		}
 
 		// Token auth (only for API routes; UI+static remain accessible so the app can load).
-		if cfg.HTTPAuthToken != "" && strings.HasPrefix(r.URL.Path, "/api/") {
-			if !checkAuthToken(cfg.HTTPAuthToken, r) {
+		if httpAuthEnabled(cfg) && isProtectedPath(r.URL.Path) {
+			if !checkAuthToken(cfg, r) {
 				rec.Header().Set("WWW-Authenticate", "Bearer")
 				http.Error(rec, "unauthorized", http.StatusUnauthorized)
 				return
@@ -249,8 +308,20 @@ func applyHTTPMiddleware(cfg Config, h http.Handler) http.Handler {
 	})
 }
 
-func checkAuthToken(token string, r *http.Request) bool {
-	if token == "" {
+// isProtectedPath: API routes and /metrics are rate-limited and token-protected.
+func isProtectedPath(p string) bool {
+	return strings.HasPrefix(p, "/api/") || p == "/metrics"
+}
+
+// httpAuthEnabled: the API requires a token once the admin token or any
+// client token is configured.
+func httpAuthEnabled(cfg Config) bool {
+	return cfg.HTTPAuthToken != "" || len(cfg.HTTPClientTokens) > 0
+}
+
+// checkAuthToken accepts the admin token or a client token.
+func checkAuthToken(cfg Config, r *http.Request) bool {
+	if !httpAuthEnabled(cfg) {
 		return true
 	}
 
@@ -265,17 +336,30 @@ func checkAuthToken(token string, r *http.Request) bool {
 	if isLoopbackRemoteAddr(r.RemoteAddr) && !hasForwardedHeaders(r) {
 		return true
 	}
+	return requestHasToken(cfg.HTTPAuthToken, r) || hasClientToken(cfg, r)
+}
+
+// requestHasToken checks the token itself (no loopback bypass); used by
+// destructive endpoints such as /api/admin/wipe.
+func requestHasToken(token string, r *http.Request) bool {
+	if token == "" || r == nil {
+		return false
+	}
+	t := requestToken(r)
+	return t != "" && subtleEqual(t, token)
+}
 
+// requestToken is the token the request sends (X-Auth-Token, else Authorization: Bearer).
+func requestToken(r *http.Request) string {
 	if t := strings.TrimSpace(r.Header.Get("X-Auth-Token")); t != "" {
-		return subtleEqual(t, token)
+		return t
 	}
 	if a := strings.TrimSpace(r.Header.Get("Authorization")); a != "" {
 		if strings.HasPrefix(strings.ToLower(a), "bearer ") {
-			v := strings.TrimSpace(a[7:])
-			return subtleEqual(v, token)
+			return strings.TrimSpace(a[7:])
 		}
 	}
-	return false
+	return ""
 }
 
 func hasForwardedHeaders(r *http.Request) bool {
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"database/sql"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Rate limiter: trusted proxies + persisted state
+// - Behind a reverse proxy every request comes from the proxy's address, so
+//   all users shared one bucket. TIMELAYER_HTTP_TRUSTED_PROXIES (CIDRs / IPs)
+//   lists the proxies whose X-Forwarded-For (or X-Real-IP) is honoured; the
+//   client is the right-most hop that is not itself a trusted proxy.
+//   Forwarded headers from any other peer are ignored (spoofable).
+// - Buckets and bans are saved to http_rate_limits every rateLimitSaveEvery
+//   (and right after a ban) and restored at startup, so a restart neither
+//   refills spent budgets nor lifts bans. Full buckets are not stored.
+// ============================================================
+
+const rateLimitSaveEvery = 30 * time.Second
+
+// parseTrustedProxies turns CIDRs / bare IPs into networks (invalid entries are logged and skipped).
+func parseTrustedProxies(list []string) []*net.IPNet {
+	return parseIPNets(list, "trusted proxy")
+}
+
+// parseIPNets parses CIDRs / bare IPs; what names the list in warnings.
+func parseIPNets(list []string, what string) []*net.IPNet {
+	var out []*net.IPNet
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				log.Printf("[warn] %s ignored (not an IP/CIDR): %q", what, s)
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			log.Printf("[warn] %s ignored (not an IP/CIDR): %q", what, s)
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHopIP accepts "1.2.3.4", "1.2.3.4:port", "[::1]:port" and "::1".
+func parseHopIP(s string) net.IP {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if h, _, err := net.SplitHostPort(s); err == nil {
+		s = h
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
+
+// resolveClientIP returns the client address used for rate limiting and logs:
+// the TCP peer, unless the peer is a trusted proxy.
+func resolveClientIP(r *http.Request, trusted []*net.IPNet) string {
+	peer := clientIP(r)
+	if len(trusted) == 0 || !ipInNets(parseHopIP(peer), trusted) {
+		return peer
+	}
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) == 0 {
+		if ip := parseHopIP(r.Header.Get("X-Real-IP")); ip != nil {
+			return ip.String()
+		}
+		return peer
+	}
+	// walk back from the proxy: the first hop we don't trust is the client
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHopIP(hops[i])
+		if ip == nil {
+			return peer // garbage in the chain: don't guess
+		}
+		if !ipInNets(ip, trusted) || i == 0 {
+			return ip.String()
+		}
+	}
+	return peer
+}
+
+// load restores saved buckets (expired bans and stale full buckets are skipped).
+func (l *ipRateLimiter) load(db *sql.DB) error {
+	rows, err := db.Query(`SELECT ip, tokens, last_at, strikes, banned_until FROM http_rate_limits`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n, bans := 0, 0
+	for rows.Next() {
+		var ip, lastAt, bannedUntil string
+		var b bucket
+		if err := rows.Scan(&ip, &b.tokens, &lastAt, &b.strikes, &bannedUntil); err != nil {
+			return err
+		}
+		if b.last, err = time.Parse(time.RFC3339Nano, lastAt); err != nil {
+			continue
+		}
+		if bannedUntil != "" {
+			b.banned, _ = time.Parse(time.RFC3339Nano, bannedUntil)
+		}
+		if b.banned.After(now) {
+			bans++
+		} else if now.Sub(b.last) > l.ttl {
+			continue // refilled long ago
+		}
+		if _, burst := l.rate(ip); b.tokens > burst {
+			b.tokens = burst // rpm lowered since
+		}
+		l.states[ip] = &b
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("[http] rate limit state restored: %d ips (%d banned)", n, bans)
+	}
+	return nil
+}
+
+// save replaces http_rate_limits with the buckets that still matter.
+func (l *ipRateLimiter) save(db *sql.DB) error {
+	type row struct {
+		ip string
+		b  bucket
+	}
+	now := time.Now()
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	var keep []row
+	for ip, b := range l.states {
+		rpm, burst := l.rate(ip)
+		refilled := b.tokens + now.Sub(b.last).Seconds()*float64(rpm)/60.0
+		if refilled >= burst && !b.banned.After(now) {
+			continue
+		}
+		keep = append(keep, row{ip, *b})
+	}
+	l.dirty = false
+	l.mu.Unlock()
+
+	ts := now.Format(time.RFC3339)
+	err := withTx(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM http_rate_limits`); err != nil {
+			return err
+		}
+		for _, k := range keep {
+			banned := ""
+			if k.b.banned.After(now) {
+				banned = k.b.banned.Format(time.RFC3339Nano)
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO http_rate_limits(ip, tokens, last_at, strikes, banned_until, updated_at)
+				VALUES(?,?,?,?,?,?)
+			`, k.ip, k.b.tokens, k.b.last.Format(time.RFC3339Nano), k.b.strikes, banned, ts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		l.mu.Lock()
+		l.dirty = true
+		l.mu.Unlock()
+	}
+	return err
+}
+
+// persistLoop saves every interval, and immediately when a ban is issued.
+func (l *ipRateLimiter) persistLoop(db *sql.DB, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-l.kick:
+		}
+		if err := l.save(db); err != nil {
+			log.Printf("[warn] rate limit state not saved: %v", err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Rate limiter: per-token buckets, allowlist, Retry-After
+// - Per-IP buckets punish everyone behind one NAT together. A request that
+//   sends a valid token (TIMELAYER_HTTP_AUTH_TOKEN or one of
+//   TIMELAYER_HTTP_CLIENT_TOKENS) is counted in that token's own bucket
+//   instead; requests without one stay per IP.
+// - TIMELAYER_HTTP_CLIENT_TOKENS="tokA=600,tokB" adds API tokens (accepted
+//   like the auth token, but never for /api/admin/wipe), each optionally with
+//   its own RPM (default TIMELAYER_HTTP_RATE_LIMIT_RPM). Setting them turns
+//   token auth on even without TIMELAYER_HTTP_AUTH_TOKEN (httpAuthEnabled).
+//   Buckets are keyed by a hash of the token, so http_rate_limits never
+//   stores the secret.
+// - TIMELAYER_HTTP_RATE_LIMIT_ALLOWLIST (CIDRs / IPs) is matched against the
+//   client IP (after trusted proxies) and skips the limiter, not the auth.
+// - 429 responses carry Retry-After (whole seconds until the bucket has a
+//   token again, or until the ban ends).
+// ============================================================
+
+// parseClientTokens parses "tokA=600,tokB" (a token may itself contain "=";
+// only a numeric suffix is read as the rpm).
+func parseClientTokens(s string) map[string]int {
+	out := map[string]int{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		tok, rpm := part, 0
+		if i := strings.LastIndex(part, "="); i > 0 {
+			if n, err := strconv.Atoi(part[i+1:]); err == nil && n >= 0 {
+				tok, rpm = part[:i], n
+			}
+		}
+		out[tok] = rpm
+	}
+	return out
+}
+
+// tokenRateLimitKey is the bucket key of a token.
+func tokenRateLimitKey(tok string) string {
+	return "token:" + sha256Hex(tok)[:12]
+}
+
+// matchClientToken returns the configured client token the request sends.
+func matchClientToken(cfg Config, r *http.Request) (string, bool) {
+	t := requestToken(r)
+	if t == "" {
+		return "", false
+	}
+	for tok := range cfg.HTTPClientTokens {
+		if subtleEqual(t, tok) {
+			return tok, true
+		}
+	}
+	return "", false
+}
+
+// hasClientToken reports whether r is authenticated by a client token.
+func hasClientToken(cfg Config, r *http.Request) bool {
+	_, ok := matchClientToken(cfg, r)
+	return ok
+}
+
+// rateLimitKey is the bucket r counts against: its token when valid, else ip.
+func rateLimitKey(cfg Config, r *http.Request, ip string) string {
+	if tok, ok := matchClientToken(cfg, r); ok {
+		return tokenRateLimitKey(tok)
+	}
+	if requestHasToken(cfg.HTTPAuthToken, r) {
+		return tokenRateLimitKey(cfg.HTTPAuthToken)
+	}
+	return ip
+}
+
+// retryAfterSeconds formats d for the Retry-After header (at least 1).
+func retryAfterSeconds(d time.Duration) string {
+	n := int(math.Ceil(d.Seconds()))
+	if n < 1 {
+		n = 1
+	}
+	return strconv.Itoa(n)
+}
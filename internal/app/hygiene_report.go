@@ -0,0 +1,286 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Memory hygiene report (monthly, bg job kind "hygiene")
+// - Pending backlog, open conflicts, facts not mentioned for 6+ months,
+//   summary guard warnings of the month, summaries missing embeddings.
+// - Stored as a summary of type "hygiene" (period_key = YYYY-MM) so it is
+//   searchable like any document; pushed via the notification webhook when set.
+// - Pure SQL, no LLM call: it never competes with the background budget.
+// ============================================================
+
+const (
+	summaryTypeHygiene = "hygiene"
+
+	hygieneStaleFactMonths = 6
+	hygienePendingOldDays  = 30
+	hygieneMaxStaleFacts   = 50
+)
+
+type HygieneStaleFact struct {
+	FactKey   string `json:"fact_key"`
+	Fact      string `json:"fact"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type HygieneReport struct {
+	Type        string `json:"type"`
+	Month       string `json:"month"`
+	GeneratedAt string `json:"generated_at"`
+
+	PendingTotal  int    `json:"pending_total"`
+	PendingOld    int    `json:"pending_older_than_30d"`
+	PendingOldest string `json:"pending_oldest,omitempty"`
+
+	OpenConflicts int `json:"open_conflicts"`
+
+	StaleFactCount int                `json:"stale_fact_count"`
+	StaleFacts     []HygieneStaleFact `json:"stale_facts,omitempty"`
+
+	GuardWarnings     map[string]int `json:"guard_warnings"`
+	GuardWarningTotal int            `json:"guard_warning_total"`
+
+	EmbeddingGaps     map[string]int `json:"embedding_gaps"`
+	EmbeddingGapTotal int            `json:"embedding_gap_total"`
+}
+
+// monthBounds returns the first and last day (YYYY-MM-DD) of monthKey.
+func monthBounds(cfg Config, monthKey string) (string, string, error) {
+	t, err := time.ParseInLocation("2006-01", monthKey, cfg.Location)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid month: %s", monthKey)
+	}
+	return t.Format("2006-01-02"), t.AddDate(0, 1, -1).Format("2006-01-02"), nil
+}
+
+// factMentionTerm is what we look for in later summaries/messages: the fact's
+// subject when its key has one ("subject:最喜欢的颜色"), else the fact text.
+func factMentionTerm(factKey, fact string) string {
+	if s, ok := strings.CutPrefix(factKey, "subject:"); ok && s != "" {
+		return s
+	}
+	return strings.TrimSpace(fact)
+}
+
+// factMentionedSince reports whether term shows up in a daily summary or a raw
+// message on/after since.
+func factMentionedSince(db *sql.DB, term, since string) bool {
+	if term == "" {
+		return true
+	}
+	like := "%" + term + "%"
+	var one int
+	if db.QueryRow(`
+		SELECT 1 FROM summaries
+		WHERE type IN ('daily', 'daily_partial') AND start_date>=? AND deleted_at IS NULL AND json LIKE ?
+		LIMIT 1`, since, like).Scan(&one) == nil {
+		return true
+	}
+	return db.QueryRow(`
+		SELECT 1 FROM messages WHERE day>=? AND role='user' AND content LIKE ? LIMIT 1`,
+		since, like).Scan(&one) == nil
+}
+
+// BuildHygieneReport compiles the report for monthKey (YYYY-MM) from the current DB state.
+func BuildHygieneReport(cfg Config, db *sql.DB, monthKey string) (HygieneReport, error) {
+	start, end, err := monthBounds(cfg, monthKey)
+	if err != nil {
+		return HygieneReport{}, err
+	}
+	now := time.Now().In(cfg.Location)
+	rep := HygieneReport{
+		Type:          summaryTypeHygiene,
+		Month:         monthKey,
+		GeneratedAt:   now.Format(time.RFC3339),
+		GuardWarnings: map[string]int{},
+		EmbeddingGaps: map[string]int{},
+	}
+
+	// ---------- pending backlog ----------
+	oldCutoff := now.AddDate(0, 0, -hygienePendingOldDays).Format(time.RFC3339)
+	var oldest sql.NullString
+	if err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN created_at<? THEN 1 ELSE 0 END),0), MIN(created_at)
+		FROM pending_facts WHERE status='pending' AND deleted_at IS NULL`, oldCutoff,
+	).Scan(&rep.PendingTotal, &rep.PendingOld, &oldest); err != nil {
+		return rep, err
+	}
+	rep.PendingOldest = oldest.String
+
+	// ---------- conflicts ----------
+	if err := db.QueryRow(`SELECT COUNT(*) FROM user_fact_conflicts WHERE status='conflict'`).
+		Scan(&rep.OpenConflicts); err != nil {
+		return rep, err
+	}
+
+	// ---------- stale facts ----------
+	endT, _ := time.ParseInLocation("2006-01-02", end, cfg.Location)
+	since := endT.AddDate(0, -hygieneStaleFactMonths, 0).Format("2006-01-02")
+	rows, err := db.Query(`
+		SELECT fact_key, fact, updated_at FROM user_facts
+		WHERE is_active=1 AND updated_at<?
+		ORDER BY updated_at`, since)
+	if err != nil {
+		return rep, err
+	}
+	var candidates []HygieneStaleFact
+	for rows.Next() {
+		var f HygieneStaleFact
+		if rows.Scan(&f.FactKey, &f.Fact, &f.UpdatedAt) == nil {
+			candidates = append(candidates, f)
+		}
+	}
+	rows.Close()
+	for _, f := range candidates {
+		if factMentionedSince(db, factMentionTerm(f.FactKey, f.Fact), since) {
+			continue
+		}
+		rep.StaleFactCount++
+		if len(rep.StaleFacts) < hygieneMaxStaleFacts {
+			rep.StaleFacts = append(rep.StaleFacts, f)
+		}
+	}
+
+	// ---------- guard warnings (this month) ----------
+	rows, err = db.Query(`
+		SELECT warning_type, COUNT(*) FROM summary_warnings
+		WHERE substr(created_at,1,10)>=? AND substr(created_at,1,10)<=?
+		GROUP BY warning_type`, start, end)
+	if err != nil {
+		return rep, err
+	}
+	for rows.Next() {
+		var typ string
+		var n int
+		if rows.Scan(&typ, &n) == nil {
+			rep.GuardWarnings[typ] = n
+			rep.GuardWarningTotal += n
+		}
+	}
+	rows.Close()
+
+	// ---------- embedding coverage ----------
+	rows, err = db.Query(`
+		SELECT s.type, COUNT(*) FROM summaries s
+		LEFT JOIN embeddings e ON e.summary_id=s.id
+		WHERE `+missingEmbeddingWhere+` AND s.type<>?
+		GROUP BY s.type`, summaryTypeHygiene)
+	if err != nil {
+		return rep, err
+	}
+	for rows.Next() {
+		var typ string
+		var n int
+		if rows.Scan(&typ, &n) == nil {
+			rep.EmbeddingGaps[typ] = n
+			rep.EmbeddingGapTotal += n
+		}
+	}
+	rows.Close()
+	return rep, nil
+}
+
+func sortedCounts(m map[string]int) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, m[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// renderHygieneText is the readable form (summary text / notification / command output).
+func renderHygieneText(rep HygieneReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Memory hygiene %s\n", rep.Month)
+	fmt.Fprintf(&b, "- pending facts: %d (%d older than %d days)\n", rep.PendingTotal, rep.PendingOld, hygienePendingOldDays)
+	fmt.Fprintf(&b, "- open conflicts: %d\n", rep.OpenConflicts)
+	fmt.Fprintf(&b, "- facts not mentioned for %d+ months: %d\n", hygieneStaleFactMonths, rep.StaleFactCount)
+	for i, f := range rep.StaleFacts {
+		if i >= 10 {
+			fmt.Fprintf(&b, "    … %d more\n", rep.StaleFactCount-i)
+			break
+		}
+		fmt.Fprintf(&b, "    · %s\n", f.Fact)
+	}
+	fmt.Fprintf(&b, "- summary guard warnings: %d", rep.GuardWarningTotal)
+	if rep.GuardWarningTotal > 0 {
+		b.WriteString(" (" + sortedCounts(rep.GuardWarnings) + ")")
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "- summaries without embedding: %d", rep.EmbeddingGapTotal)
+	if rep.EmbeddingGapTotal > 0 {
+		b.WriteString(" (" + sortedCounts(rep.EmbeddingGaps) + "; run /embed_heal)")
+	}
+	return b.String()
+}
+
+// ensureHygieneReport builds and stores the report for monthKey (force: rebuild).
+func ensureHygieneReport(cfg Config, db *sql.DB, monthKey string, force bool) error {
+	if !force {
+		if ok, _ := summaryExists(db, summaryTypeHygiene, monthKey); ok {
+			return nil
+		}
+	}
+	rep, err := BuildHygieneReport(cfg, db, monthKey)
+	if err != nil {
+		return err
+	}
+	js, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	start, end, _ := monthBounds(cfg, monthKey)
+	text := renderHygieneText(rep)
+	if _, err := upsertSummary(db, cfg, summaryTypeHygiene, monthKey, start, end, string(js), text, ""); err != nil {
+		return err
+	}
+	if force {
+		dropSummaryEmbedding(db, summaryTypeHygiene, monthKey)
+	}
+	if err := ensureEmbedding(db, cfg, text, summaryTypeHygiene, monthKey); err != nil {
+		log.Printf("[warn] ensureEmbedding failed for hygiene %s: %v", monthKey, err)
+	}
+	if err := sendNotification(cfg, "hygiene", "TimeLayer memory hygiene "+monthKey, text); err != nil {
+		log.Printf("[warn] hygiene notification failed: %v", err)
+	}
+	return nil
+}
+
+// runHygieneCommand implements /hygiene [YYYY-MM] [--save].
+// Without --save it only prints the current state; with --save it (re)stores the report.
+func runHygieneCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	month := time.Now().In(cfg.Location).Format("2006-01")
+	save := false
+	for _, f := range strings.Fields(arg) {
+		if f == "--save" {
+			save = true
+		} else {
+			month = f
+		}
+	}
+	if save {
+		if err := ensureHygieneReport(cfg, db, month, true); err != nil {
+			return "", err
+		}
+	}
+	rep, err := BuildHygieneReport(cfg, db, month)
+	if err != nil {
+		return "", err
+	}
+	return renderHygieneText(rep), nil
+}
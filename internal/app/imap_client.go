@@ -0,0 +1,218 @@
+package app
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Minimal IMAP4rev1 client (read-only), just enough for email ingestion:
+// LOGIN, EXAMINE, UID SEARCH, UID FETCH BODY.PEEK[], LOGOUT.
+// Messages are never marked \Seen (EXAMINE + BODY.PEEK).
+// ============================================================
+
+const (
+	imapMaxLiteral   = 1 << 20 // literal cap outside FetchRaw
+	imapLiteralSlack = 4 << 10 // FetchRaw: allowed beyond the requested maxBytes
+)
+
+type imapClient struct {
+	conn       net.Conn
+	r          *bufio.Reader
+	tag        int
+	timeout    time.Duration // per command
+	maxLiteral int           // 0 = imapMaxLiteral
+}
+
+// imapResponse is one untagged response line; literals ({N}) are collected in order.
+type imapResponse struct {
+	Line     string
+	Literals [][]byte
+}
+
+func dialIMAP(addr string, useTLS bool, timeout time.Duration) (*imapClient, error) {
+	d := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = tls.DialWithDialer(d, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = d.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("imap greeting: %s", greeting)
+	}
+	return c, nil
+}
+
+func (c *imapClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *imapClient) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readResponse reads one full response, following {N} literals. A literal
+// larger than the current cap is refused before anything is allocated.
+func (c *imapClient) readResponse() (imapResponse, error) {
+	limit := c.maxLiteral
+	if limit <= 0 {
+		limit = imapMaxLiteral
+	}
+	var resp imapResponse
+	var b strings.Builder
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return resp, err
+		}
+		b.WriteString(line)
+		n, ok := literalSize(line)
+		if !ok {
+			break
+		}
+		if n > limit {
+			return resp, fmt.Errorf("imap: literal of %d bytes exceeds %d", n, limit)
+		}
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return resp, err
+		}
+		resp.Literals = append(resp.Literals, lit)
+	}
+	resp.Line = b.String()
+	return resp, nil
+}
+
+// literalSize parses a trailing "{N}" literal marker.
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndexByte(line, '{')
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(line[i+1 : len(line)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// cmd sends one tagged command and returns its untagged responses.
+func (c *imapClient) cmd(format string, args ...any) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("a%03d", c.tag)
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+	var out []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return out, err
+		}
+		if strings.HasPrefix(resp.Line, tag+" ") {
+			status := strings.TrimPrefix(resp.Line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return out, fmt.Errorf("imap %s", status)
+			}
+			return out, nil
+		}
+		out = append(out, resp)
+	}
+}
+
+func imapQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func (c *imapClient) Login(user, pass string) error {
+	_, err := c.cmd("LOGIN %s %s", imapQuote(user), imapQuote(pass))
+	return err
+}
+
+// Examine opens folder read-only and returns its UIDVALIDITY.
+func (c *imapClient) Examine(folder string) (uint32, error) {
+	resps, err := c.cmd("EXAMINE %s", imapQuote(folder))
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range resps {
+		if i := strings.Index(r.Line, "[UIDVALIDITY "); i >= 0 {
+			rest := r.Line[i+len("[UIDVALIDITY "):]
+			if j := strings.IndexByte(rest, ']'); j > 0 {
+				n, _ := strconv.ParseUint(rest[:j], 10, 32)
+				return uint32(n), nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// UIDSearch runs "UID SEARCH <criteria>" and returns matching UIDs.
+func (c *imapClient) UIDSearch(criteria string) ([]uint32, error) {
+	resps, err := c.cmd("UID SEARCH %s", criteria)
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, r := range resps {
+		if !strings.HasPrefix(r.Line, "* SEARCH") {
+			continue
+		}
+		for _, f := range strings.Fields(strings.TrimPrefix(r.Line, "* SEARCH")) {
+			if n, err := strconv.ParseUint(f, 10, 32); err == nil {
+				uids = append(uids, uint32(n))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// FetchRaw returns the first maxBytes of a message (RFC 822), without setting \Seen.
+func (c *imapClient) FetchRaw(uid uint32, maxBytes int) ([]byte, error) {
+	c.maxLiteral = maxBytes + imapLiteralSlack
+	defer func() { c.maxLiteral = 0 }()
+	resps, err := c.cmd("UID FETCH %d BODY.PEEK[]<0.%d>", uid, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resps {
+		if strings.Contains(r.Line, "FETCH") && len(r.Literals) > 0 {
+			return r.Literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap: no body for uid %d", uid)
+}
+
+func (c *imapClient) Logout() {
+	_, _ = c.cmd("LOGOUT")
+}
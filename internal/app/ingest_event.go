@@ -0,0 +1,114 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// External events (POST /api/ingest/event)
+// - Fitness apps, git hooks, calendars, ... push what happened.
+// - Stored in today's dialog JSONL as {"role":"event","source":...} lines,
+//   so the daily summary sees them next to the chat. They are not op records
+//   and they are never used for user-fact extraction or recent chat context.
+// ============================================================
+
+const (
+	roleEvent = "event"
+
+	maxEventContentRunes = 2000
+)
+
+var eventSourceRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,31}$`)
+
+// IngestEvent is one structured external event.
+type IngestEvent struct {
+	Source string         `json:"source"`         // e.g. "strava", "git", "calendar"
+	Type   string         `json:"type,omitempty"` // e.g. "workout", "commit", "event_done"
+	Text   string         `json:"text,omitempty"` // human-readable description
+	At     string         `json:"at,omitempty"`   // when it happened (RFC3339), informational
+	Data   map[string]any `json:"data,omitempty"` // structured details, rendered as k=v
+}
+
+// eventContent renders the event as one readable line for the summarizer.
+func eventContent(ev IngestEvent) string {
+	var b strings.Builder
+	if ev.Type != "" {
+		b.WriteString(ev.Type)
+		b.WriteString(": ")
+	}
+	b.WriteString(strings.TrimSpace(ev.Text))
+
+	if len(ev.Data) > 0 {
+		keys := make([]string, 0, len(ev.Data))
+		for k := range ev.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			v := ev.Data[k]
+			s, ok := v.(string)
+			if !ok {
+				j, _ := json.Marshal(v)
+				s = string(j)
+			}
+			parts = append(parts, k+"="+s)
+		}
+		if strings.TrimSpace(ev.Text) != "" {
+			b.WriteString(" ")
+		}
+		b.WriteString("(" + strings.Join(parts, ", ") + ")")
+	}
+
+	s := strings.TrimSpace(b.String())
+	if r := []rune(s); len(r) > maxEventContentRunes {
+		s = string(r[:maxEventContentRunes]) + "…"
+	}
+	return s
+}
+
+// normalizeIngestEvent validates ev and fills defaults.
+func normalizeIngestEvent(cfg Config, ev IngestEvent) (IngestEvent, error) {
+	ev.Source = strings.ToLower(strings.TrimSpace(ev.Source))
+	ev.Type = strings.TrimSpace(ev.Type)
+	ev.Text = strings.TrimSpace(ev.Text)
+	ev.At = strings.TrimSpace(ev.At)
+	if !eventSourceRe.MatchString(ev.Source) {
+		return ev, fmt.Errorf("source is required ([a-z0-9_.-], max 32 chars)")
+	}
+	if ev.Text == "" && len(ev.Data) == 0 {
+		return ev, fmt.Errorf("text or data is required")
+	}
+	if ev.At == "" {
+		ev.At = time.Now().In(cfg.Location).Format(time.RFC3339)
+	} else if _, err := time.Parse(time.RFC3339, ev.At); err != nil {
+		return ev, fmt.Errorf("invalid at (want RFC3339): %s", ev.At)
+	}
+	return ev, nil
+}
+
+// IngestExternalEvent appends an external event to today's dialog log.
+func IngestExternalEvent(cfg Config, lw *LogWriter, ev IngestEvent) (IngestEvent, error) {
+	if lw == nil {
+		return ev, fmt.Errorf("log writer unavailable")
+	}
+	ev, err := normalizeIngestEvent(cfg, ev)
+	if err != nil {
+		return ev, err
+	}
+	rec := map[string]string{
+		"role":    roleEvent,
+		"source":  ev.Source,
+		"content": eventContent(ev),
+		"at":      ev.At,
+	}
+	if ev.Type != "" {
+		rec["event_type"] = ev.Type
+	}
+	return ev, lw.WriteRecord(rec)
+}
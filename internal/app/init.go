@@ -1,13 +1,102 @@
 package app
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
 
 // MustInit initializes directories/prompts and opens DB + log writer.
+// The background workers it starts are stopped by StartWebContext on
+// shutdown (or by stopBackgroundWorkers) before the DB is closed.
 func MustInit(cfg Config) (*sql.DB, *LogWriter) {
+	initErrorReporting(cfg)
 	mustEnsureDirs(cfg)
 	mustEnsurePromptFiles(cfg)
 
 	db := mustOpenDB(cfg)
 	lw := NewLogWriter(cfg, db)
+
+	startBackgroundWorkers(cfg, db)
 	return db, lw
 }
+
+// bgWorkers tracks the workers started by startBackgroundWorkers so they can
+// be stopped (ctx cancelled) and waited for before the DB is closed.
+var bgWorkers struct {
+	sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startBackgroundWorkers starts the long-running workers shared by the CLI
+// (Run) and the web server (MustInit).
+func startBackgroundWorkers(cfg Config, db *sql.DB) {
+	bgWorkers.Lock()
+	if bgWorkers.cancel == nil {
+		bgWorkers.ctx, bgWorkers.cancel = context.WithCancel(context.Background())
+	}
+	ctx := bgWorkers.ctx
+	bgWorkers.Unlock()
+
+	// resume background jobs paused by the LLM budget
+	goWorker("bg_jobs", func() { runBackgroundJobs(cfg, db) })
+	goWorker("trash_purge", func() { runTrashPurge(cfg, db) })
+	goWorker("pending_expiry", func() { runPendingExpiry(cfg, db) })
+	goWorker("embed_history_retention", func() { runEmbedHistoryRetention(cfg, db) })
+	goWorker("email_poller", func() { runEmailPoller(ctx, cfg, db) })
+	goWorker("embedding_healer", func() { runEmbeddingHealer(ctx, cfg, db) })
+	goWorker("rollup_scheduler", func() { runRollupScheduler(ctx, cfg, db) })
+	goWorker("vector_index", func() { buildVectorIndex(cfg, db) })
+	goWorker("embed_model_check", func() { checkEmbeddingModel(cfg, db) })
+	goWorker("pending_embed", func() { runPendingEmbedWorker(ctx, cfg, db) })
+	goWorker("fact_search_sync", func() { runFactSearchSyncWorker(ctx, cfg, db) })
+	goWorker("conflict_policy", func() { runConflictPolicySweeper(ctx, cfg, db) })
+}
+
+// goWorker is goSafe for a background worker that stopBackgroundWorkers waits for.
+func goWorker(source string, fn func()) {
+	bgWorkers.wg.Add(1)
+	goSafe(source, func() {
+		defer bgWorkers.wg.Done()
+		fn()
+	})
+}
+
+// backgroundStopping reports whether stopBackgroundWorkers has been called
+// (long loops such as the job queue check it between items).
+func backgroundStopping() bool {
+	bgWorkers.Lock()
+	defer bgWorkers.Unlock()
+	return bgWorkers.ctx != nil && bgWorkers.ctx.Err() != nil
+}
+
+// sleepCtx waits d or until ctx is done; false means ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// stopBackgroundWorkers cancels the workers and waits until they have all
+// returned. The periodic loops return at their next wait; a one-shot sweep
+// or job in progress finishes its current item first.
+func stopBackgroundWorkers() {
+	bgWorkers.Lock()
+	cancel := bgWorkers.cancel
+	bgWorkers.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	bgWorkers.wg.Wait()
+	log.Printf("[info] background workers stopped")
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +19,10 @@ type llmResp struct {
 	} `json:"choices"`
 }
 
+// ErrLLMUnavailable wraps failures where the model server gave no usable
+// answer (transport error, 429, 5xx); a later retry may succeed.
+var ErrLLMUnavailable = errors.New("llm unavailable")
+
 // Non-stream call (used by ask / summaries)
 func callLLMNonStream(cfg Config, prompt string) (string, error) {
 	payload := map[string]any{
@@ -42,11 +47,14 @@ func callLLMNonStream(cfg Config, prompt string) (string, error) {
 	client := &http.Client{Timeout: cfg.HTTPTimeout}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: %v", ErrLLMUnavailable, err)
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return "", fmt.Errorf("%w: llm http error %d: %s", ErrLLMUnavailable, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
 	if resp.StatusCode >= 300 {
 		return "", fmt.Errorf("llm http error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
 	}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ============================================================
+// Background LLM budget
+// - Daily cap (calls and/or estimated tokens) for background work
+//   (summaries, merges, rewrites). Foreground chat/ask and the user-run
+//   summary commands (foregroundLLM) are never capped.
+// - Usage is tracked per local day in llm_budget; 0 = unlimited.
+// ============================================================
+
+var ErrLLMBudgetExhausted = errors.New("background llm budget exhausted")
+
+type LLMBudgetStatus struct {
+	Day         string `json:"day"`
+	Calls       int    `json:"calls"`
+	CallsLimit  int    `json:"calls_limit"`
+	Tokens      int    `json:"est_tokens"`
+	TokensLimit int    `json:"est_tokens_limit"`
+	Exhausted   bool   `json:"exhausted"`
+}
+
+func budgetDay(cfg Config) string {
+	return time.Now().In(cfg.Location).Format("2006-01-02")
+}
+
+// GetLLMBudgetStatus returns today's background usage against the configured limits.
+func GetLLMBudgetStatus(cfg Config, db *sql.DB) (LLMBudgetStatus, error) {
+	st := LLMBudgetStatus{
+		Day:         budgetDay(cfg),
+		CallsLimit:  cfg.BackgroundLLMDailyCalls,
+		TokensLimit: cfg.BackgroundLLMDailyTokens,
+	}
+	if db == nil {
+		return st, nil
+	}
+	err := db.QueryRow(`SELECT calls, est_tokens FROM llm_budget WHERE day=?`, st.Day).Scan(&st.Calls, &st.Tokens)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return st, err
+	}
+	st.Exhausted = (st.CallsLimit > 0 && st.Calls >= st.CallsLimit) ||
+		(st.TokensLimit > 0 && st.Tokens >= st.TokensLimit)
+	return st, nil
+}
+
+func recordLLMUsage(cfg Config, db *sql.DB, tokens int) error {
+	if db == nil {
+		return nil
+	}
+	now := time.Now().In(cfg.Location)
+	_, err := db.Exec(`
+		INSERT INTO llm_budget(day, calls, est_tokens, updated_at)
+		VALUES(?, 1, ?, ?)
+		ON CONFLICT(day) DO UPDATE SET
+		  calls = calls + 1,
+		  est_tokens = est_tokens + excluded.est_tokens,
+		  updated_at = excluded.updated_at
+	`, now.Format("2006-01-02"), tokens, now.Format(time.RFC3339))
+	return err
+}
+
+// foregroundLLM marks cfg for a command the user runs (/daily, /weekly,
+// /monthly, a summary regenerate): the summarizers it reaches call the model
+// without checking or charging the background budget.
+func foregroundLLM(cfg Config) Config {
+	cfg.llmForeground = true
+	return cfg
+}
+
+// callBackgroundLLM is callLLMNonStream for background work: it refuses when
+// today's budget is exhausted and records usage (prompt + output, estimated).
+// Under foregroundLLM it is a plain callLLMNonStream.
+func callBackgroundLLM(cfg Config, db *sql.DB, prompt string) (string, error) {
+	if cfg.llmForeground {
+		return callLLMNonStream(cfg, prompt)
+	}
+	st, err := GetLLMBudgetStatus(cfg, db)
+	if err == nil && st.Exhausted {
+		return "", ErrLLMBudgetExhausted
+	}
+	out, err := callLLMNonStream(cfg, prompt)
+	_ = recordLLMUsage(cfg, db, estimateTokens(prompt)+estimateTokens(out))
+	return out, err
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// JSONL integrity
+// - scanJSONL: lenient parser used by every raw-log reader;
+//   malformed lines are skipped AND counted (never silently dropped).
+// - CheckLogs (/logcheck): validates each day's JSONL and, with --fix,
+//   moves malformed lines into <date>.jsonl.bad, leaving a redaction
+//   tombstone in their place so line numbers (messages.seq) stay stable.
+// ============================================================
+
+type LogCheckReport struct {
+	Date        string `json:"date"`
+	Lines       int    `json:"lines"`
+	Valid       int    `json:"valid"`
+	Bad         int    `json:"bad"`
+	Quarantined int    `json:"quarantined"`
+	Note        string `json:"note,omitempty"`
+}
+
+// isValidLogLine reports whether line is a JSON object record.
+func isValidLogLine(line []byte) bool {
+	var m map[string]any
+	return json.Unmarshal(line, &m) == nil && m != nil
+}
+
+// scanJSONL calls fn for every valid JSONL record and returns the number of
+// malformed (non-empty) lines that were skipped.
+func scanJSONL(b []byte, fn func(line []byte)) (bad int) {
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !isValidLogLine(line) {
+			bad++
+			continue
+		}
+		fn(line)
+	}
+	return bad
+}
+
+var malformedWarned sync.Map // "date:bad" -> struct{}
+
+// warnMalformedLines logs skipped malformed lines once per (date, count).
+func warnMalformedLines(date string, bad int) {
+	if bad <= 0 {
+		return
+	}
+	if _, seen := malformedWarned.LoadOrStore(fmt.Sprintf("%s:%d", date, bad), struct{}{}); seen {
+		return
+	}
+	log.Printf("[warn] %s.jsonl: skipped %d malformed line(s); run /logcheck --fix", date, bad)
+}
+
+// CheckLogs validates <LogDir>/<date>.jsonl (date == "": every day).
+// With fix, malformed lines are appended to <date>.jsonl.bad and replaced in
+// the log by a tombstone. Today's file is never rewritten (LogWriter holds it open for append).
+func CheckLogs(cfg Config, date string, fix bool) ([]LogCheckReport, error) {
+	var days []string
+	if date != "" {
+		// the date becomes a path under LogDir: YYYY-MM-DD only
+		if t, err := time.ParseInLocation("2006-01-02", date, cfg.Location); err != nil || t.Format("2006-01-02") != date {
+			return nil, fmt.Errorf("invalid date: %s", date)
+		}
+		days = []string{date}
+	} else {
+		entries, err := os.ReadDir(cfg.LogDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || !strings.HasSuffix(name, ".jsonl") {
+				continue
+			}
+			d := strings.TrimSuffix(name, ".jsonl")
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				continue
+			}
+			days = append(days, d)
+		}
+		sort.Strings(days)
+	}
+
+	today := time.Now().In(cfg.Location).Format("2006-01-02")
+	var out []LogCheckReport
+	for _, day := range days {
+		path := filepath.Join(cfg.LogDir, day+".jsonl")
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return out, err
+		}
+
+		rep := LogCheckReport{Date: day}
+		lines := bytes.Split(b, []byte("\n"))
+		var bad bytes.Buffer
+		for i, raw := range lines {
+			line := bytes.TrimSpace(raw)
+			if len(line) == 0 {
+				continue
+			}
+			rep.Lines++
+			if isValidLogLine(line) {
+				rep.Valid++
+				continue
+			}
+			rep.Bad++
+			bad.Write(line)
+			bad.WriteByte('\n')
+			lines[i] = quarantineTombstone(cfg)
+		}
+
+		if fix && rep.Bad > 0 {
+			if day == today {
+				rep.Note = "today's log is open for writing; not rewritten"
+			} else if err := quarantineBadLines(path, bytes.Join(lines, []byte("\n")), bad.Bytes()); err != nil {
+				return out, fmt.Errorf("quarantine %s: %w", day, err)
+			} else {
+				rep.Quarantined = rep.Bad
+			}
+		}
+		out = append(out, rep)
+	}
+	return out, nil
+}
+
+// quarantineTombstone stands in for a quarantined line. It is a redaction
+// tombstone, so every raw-log reader already skips it.
+func quarantineTombstone(cfg Config) []byte {
+	b, _ := json.Marshal(map[string]string{
+		"kind":        kindRedacted,
+		"reason":      "quarantined",
+		"redacted_at": time.Now().In(cfg.Location).Format(time.RFC3339),
+	})
+	return b
+}
+
+// quarantineBadLines appends bad lines to <path>.bad, then atomically replaces
+// path with fixed (bad lines tombstoned in place).
+func quarantineBadLines(path string, fixed, bad []byte) error {
+	f, err := os.OpenFile(path+".bad", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(bad); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, fixed, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// runLogCheckCommand implements /logcheck [YYYY-MM-DD] [--fix].
+func runLogCheckCommand(cfg Config, arg string) (string, error) {
+	fix, date := false, ""
+	for _, f := range strings.Fields(arg) {
+		switch {
+		case f == "--fix":
+			fix = true
+		case date == "" && !strings.HasPrefix(f, "--"):
+			date = f
+		default:
+			return "usage: /logcheck [YYYY-MM-DD] [--fix]", nil
+		}
+	}
+
+	reps, err := CheckLogs(cfg, date, fix)
+	if err != nil {
+		return "", err
+	}
+	if len(reps) == 0 {
+		return "no JSONL logs found", nil
+	}
+
+	var b strings.Builder
+	totalBad := 0
+	for _, r := range reps {
+		totalBad += r.Bad
+		if r.Bad == 0 && date == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "%s lines=%d valid=%d bad=%d quarantined=%d", r.Date, r.Lines, r.Valid, r.Bad, r.Quarantined)
+		if r.Note != "" {
+			b.WriteString(" (" + r.Note + ")")
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "[ok] checked %d day(s), bad lines=%d", len(reps), totalBad)
+	if totalBad > 0 && !fix {
+		b.WriteString(" (run /logcheck --fix to quarantine)")
+	}
+	return b.String(), nil
+}
@@ -15,6 +15,7 @@ type LogWriter struct {
 	db         *sql.DB
 	file       *os.File
 	currentDay string
+	seq        int // next messages.seq for currentDay (sqlite/both storage)
 
 	mu             sync.Mutex
 	lastRotatedDay string
@@ -36,7 +37,21 @@ func (lw *LogWriter) Close() {
 	}
 }
 
+// Flush syncs the open dialog log file to disk.
+func (lw *LogWriter) Flush() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if lw.file == nil {
+		return nil
+	}
+	return lw.file.Sync()
+}
+
 func (lw *LogWriter) WriteRecord(rec map[string]string) error {
+	// Op records never enter the dialog log (see WriteOp).
+	if rec["kind"] == "op" {
+		return lw.WriteOp("legacy", rec)
+	}
 	now := time.Now().In(lw.cfg.Location)
 	today := now.Format("2006-01-02")
 
@@ -45,6 +60,11 @@ func (lw *LogWriter) WriteRecord(rec map[string]string) error {
 	for k, v := range rec {
 		clean[k] = sanitizeUTF8(v)
 	}
+	// ---------- secrets never reach the dialog log (see secret_mask.go) ----------
+	if masked, kinds := maskSecretsFor(lw.cfg, clean["content"]); len(kinds) > 0 {
+		clean["content"] = masked
+		_ = lw.WriteOp(opSecretMasked, map[string]any{"role": clean["role"], "kinds": kinds})
+	}
 	b, err := json.Marshal(clean)
 	if err != nil {
 		return err
@@ -71,7 +91,10 @@ func (lw *LogWriter) WriteRecord(rec map[string]string) error {
 	}
 
 	// ---------- 打开当天日志 ----------
-	if lw.file == nil {
+	if lw.currentDay == "" && logStorageWritesDB(lw.cfg) {
+		lw.seq = nextMessageSeq(lw.cfg, lw.db, today)
+	}
+	if lw.file == nil && logStorageWritesFile(lw.cfg) {
 		_ = os.MkdirAll(lw.cfg.LogDir, 0755)
 		f, err := os.OpenFile(
 			filepath.Join(lw.cfg.LogDir, today+".jsonl"),
@@ -82,9 +105,20 @@ func (lw *LogWriter) WriteRecord(rec map[string]string) error {
 			lw.mu.Unlock()
 			return err
 		}
+		// A write torn by power loss leaves no trailing newline; terminate it so the
+		// next record is not glued onto the broken line.
+		if st, err := f.Stat(); err == nil && st.Size() > 0 {
+			last := make([]byte, 1)
+			if rf, err := os.Open(f.Name()); err == nil {
+				if _, err := rf.ReadAt(last, st.Size()-1); err == nil && last[0] != '\n' {
+					_, _ = f.Write([]byte{'\n'})
+				}
+				_ = rf.Close()
+			}
+		}
 		lw.file = f
-		lw.currentDay = today
 	}
+	lw.currentDay = today
 	lw.mu.Unlock()
 
 	// ---------- rollup / archive（不要持锁，避免阻塞写日志） ----------
@@ -95,6 +129,19 @@ func (lw *LogWriter) WriteRecord(rec map[string]string) error {
 	// ---------- 写入 ----------
 	lw.mu.Lock()
 	defer lw.mu.Unlock()
+	if logStorageWritesDB(lw.cfg) && lw.db != nil {
+		seq := lw.seq
+		lw.seq++
+		if _, err := insertRawMessage(lw.db, today, seq, b, "live", now.Format(time.RFC3339)); err != nil {
+			if !logStorageWritesFile(lw.cfg) {
+				return err
+			}
+			fmt.Println("[warn] messages insert failed:", err)
+		}
+	}
+	if !logStorageWritesFile(lw.cfg) {
+		return nil
+	}
 	if lw.file == nil {
 		return fmt.Errorf("log file not open")
 	}
@@ -103,12 +150,11 @@ func (lw *LogWriter) WriteRecord(rec map[string]string) error {
 }
 
 func (lw *LogWriter) rollupAndArchive(yesterday, today string) {
-	// ---------- DAILY ----------
-	if err := ensureDaily(lw.cfg, lw.db, yesterday, false); err != nil {
-		fmt.Println("[warn] ensureDaily failed:", err)
+	// ---------- DAILY / WEEKLY / MONTHLY → background jobs（受 LLM 预算约束） ----------
+	if err := enqueueJob(lw.cfg, lw.db, "daily", yesterday); err != nil {
+		fmt.Println("[warn] enqueue daily failed:", err)
 	}
 
-	// ---------- WEEKLY ----------
 	yDate, _ := time.ParseInLocation("2006-01-02", yesterday, lw.cfg.Location)
 	tDate, _ := time.ParseInLocation("2006-01-02", today, lw.cfg.Location)
 
@@ -117,23 +163,51 @@ func (lw *LogWriter) rollupAndArchive(yesterday, today string) {
 
 	if yYear != tYear || yWeek != tWeek {
 		weekKey := fmt.Sprintf("%04d-W%02d", yYear, yWeek)
-		if err := ensureWeekly(lw.cfg, lw.db, weekKey, false); err != nil {
-			fmt.Println("[warn] ensureWeekly failed:", err)
+		if err := enqueueJob(lw.cfg, lw.db, "weekly", weekKey); err != nil {
+			fmt.Println("[warn] enqueue weekly failed:", err)
 		}
 	}
 
-	// ---------- MONTHLY ----------
 	yMonth := yDate.Format("2006-01")
 	tMonth := tDate.Format("2006-01")
 
 	if yMonth != tMonth {
-		if err := ensureMonthly(lw.cfg, lw.db, yMonth, false); err != nil {
-			fmt.Println("[warn] ensureMonthly failed:", err)
+		if err := enqueueJob(lw.cfg, lw.db, "monthly", yMonth); err != nil {
+			fmt.Println("[warn] enqueue monthly failed:", err)
+		}
+		if err := enqueueJob(lw.cfg, lw.db, "hygiene", yMonth); err != nil {
+			fmt.Println("[warn] enqueue hygiene failed:", err)
+		}
+	}
+
+	if err := enqueueJob(lw.cfg, lw.db, jobKindFactSync, today); err != nil {
+		fmt.Println("[warn] enqueue fact_sync failed:", err)
+	}
+	if lw.cfg.ContextAuditRetentionDays > 0 {
+		if err := enqueueJob(lw.cfg, lw.db, jobKindContextAuditPurge, today); err != nil {
+			fmt.Println("[warn] enqueue context_audit_purge failed:", err)
 		}
 	}
+	if lw.cfg.OnThisDayNotify && notifyEnabled(lw.cfg) {
+		if err := enqueueJob(lw.cfg, lw.db, jobKindOnThisDay, today); err != nil {
+			fmt.Println("[warn] enqueue on_this_day failed:", err)
+		}
+	}
+
+	// Also resumes jobs paused yesterday by the budget.
+	runBackgroundJobs(lw.cfg, lw.db)
 
 	// ---------- ARCHIVE ----------
 	if err := forgetAndArchive(lw.cfg, lw.db); err != nil {
 		fmt.Println("[warn] archive failed:", err)
 	}
+
+	// ---------- PENDING EXPIRY ----------
+	runPendingExpiry(lw.cfg, lw.db)
+
+	// ---------- TRASH ----------
+	runTrashPurge(lw.cfg, lw.db)
+
+	// ---------- EMBEDDING HISTORY ----------
+	runEmbedHistoryRetention(lw.cfg, lw.db)
 }
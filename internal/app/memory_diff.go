@@ -0,0 +1,428 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ============================================================
+// Memory snapshot diff ("what changed between two dates")
+// - GET /api/memory/diff?from=2025-06-01&to=2025-12-01[&window=30][&narrate=1]
+//   Chat: /memory_diff <from> [to] [--window N] [--narrate]
+// - Facts: the active set at the end of each date, replayed from
+//   user_facts_history (active sets a value, archived / forgotten clear
+//   it; conflict / rejected rows change nothing). Facts without any
+//   history row fall back to user_facts created_at / deleted_at.
+//   → added / removed / changed (same fact_key, new value).
+// - Themes: monthly top_themes, weekly themes and daily topics of the
+//   summaries ending in the `window` days up to each date (weighted 3/2/1).
+//   → emerged / faded / persistent. Themes match after normalization
+//   (case, spaces, punctuation) or when one contains the other.
+// - narrate=1 asks the chat model for a short narrative of the report
+//   (nothing else is sent; nothing is stored).
+// ============================================================
+
+const (
+	memoryDiffDefaultWindow = 30
+	memoryDiffMaxWindow     = 180
+	memoryDiffMaxThemes     = 15
+)
+
+type FactDiffItem struct {
+	FactKey string `json:"fact_key"`
+	Before  string `json:"before,omitempty"`
+	After   string `json:"after,omitempty"`
+}
+
+type ThemeCount struct {
+	Theme    string `json:"theme"`
+	Mentions int    `json:"mentions"` // weighted: monthly 3, weekly 2, daily 1
+}
+
+type MemoryDiff struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	WindowDays int    `json:"window_days"`
+
+	Facts struct {
+		Added     []FactDiffItem `json:"added"`
+		Removed   []FactDiffItem `json:"removed"`
+		Changed   []FactDiffItem `json:"changed"`
+		Unchanged int            `json:"unchanged"`
+	} `json:"facts"`
+
+	Themes struct {
+		Emerged    []ThemeCount `json:"emerged"`
+		Faded      []ThemeCount `json:"faded"`
+		Persistent []ThemeCount `json:"persistent"`
+	} `json:"themes"`
+
+	// summaries behind the themes at each end
+	SummariesFrom int `json:"summaries_from"`
+	SummariesTo   int `json:"summaries_to"`
+
+	Narrative string `json:"narrative,omitempty"`
+}
+
+type MemoryDiffQuery struct {
+	From    string
+	To      string
+	Window  int
+	Narrate bool
+}
+
+// parseMemoryDiffQuery reads from (required), to (default today), window and narrate.
+func parseMemoryDiffQuery(cfg Config, get func(string) string) (MemoryDiffQuery, error) {
+	q := MemoryDiffQuery{
+		From:   strings.TrimSpace(get("from")),
+		To:     strings.TrimSpace(get("to")),
+		Window: parseIntClamp(get("window"), memoryDiffDefaultWindow, 1, memoryDiffMaxWindow),
+	}
+	n := strings.TrimSpace(get("narrate"))
+	q.Narrate = n == "1" || n == "true"
+	if q.To == "" {
+		q.To = time.Now().In(cfg.Location).Format("2006-01-02")
+	}
+	if q.From == "" {
+		return q, errors.New("from required (YYYY-MM-DD)")
+	}
+	start, err := time.ParseInLocation("2006-01-02", q.From, cfg.Location)
+	if err != nil {
+		return q, fmt.Errorf("invalid from date: %s", q.From)
+	}
+	end, err := time.ParseInLocation("2006-01-02", q.To, cfg.Location)
+	if err != nil {
+		return q, fmt.Errorf("invalid to date: %s", q.To)
+	}
+	if !end.After(start) {
+		return q, fmt.Errorf("to (%s) must be after from (%s)", q.To, q.From)
+	}
+	return q, nil
+}
+
+// BuildMemoryDiff compares the memory snapshots at the end of q.From and q.To.
+func BuildMemoryDiff(cfg Config, db *sql.DB, q MemoryDiffQuery) (MemoryDiff, error) {
+	d := MemoryDiff{From: q.From, To: q.To, WindowDays: q.Window}
+	d.Facts.Added, d.Facts.Removed, d.Facts.Changed = []FactDiffItem{}, []FactDiffItem{}, []FactDiffItem{}
+	d.Themes.Emerged, d.Themes.Faded, d.Themes.Persistent = []ThemeCount{}, []ThemeCount{}, []ThemeCount{}
+	if db == nil {
+		return d, nil
+	}
+
+	before, err := activeFactsAt(db, q.From)
+	if err != nil {
+		return d, err
+	}
+	after, err := activeFactsAt(db, q.To)
+	if err != nil {
+		return d, err
+	}
+	for k, a := range after {
+		switch b, ok := before[k]; {
+		case !ok:
+			d.Facts.Added = append(d.Facts.Added, FactDiffItem{FactKey: k, After: a})
+		case b != a:
+			d.Facts.Changed = append(d.Facts.Changed, FactDiffItem{FactKey: k, Before: b, After: a})
+		default:
+			d.Facts.Unchanged++
+		}
+	}
+	for k, b := range before {
+		if _, ok := after[k]; !ok {
+			d.Facts.Removed = append(d.Facts.Removed, FactDiffItem{FactKey: k, Before: b})
+		}
+	}
+	for _, list := range [][]FactDiffItem{d.Facts.Added, d.Facts.Removed, d.Facts.Changed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].FactKey < list[j].FactKey })
+	}
+
+	themesFrom, nFrom, err := themesBefore(cfg, db, q.From, q.Window)
+	if err != nil {
+		return d, err
+	}
+	themesTo, nTo, err := themesBefore(cfg, db, q.To, q.Window)
+	if err != nil {
+		return d, err
+	}
+	d.SummariesFrom, d.SummariesTo = nFrom, nTo
+	d.Themes.Emerged, d.Themes.Faded, d.Themes.Persistent = diffThemes(themesFrom, themesTo)
+
+	if q.Narrate {
+		n, err := narrateMemoryDiff(cfg, d)
+		if err != nil {
+			return d, err
+		}
+		d.Narrative = n
+	}
+	return d, nil
+}
+
+// activeFactsAt returns fact_key → fact for the primary user's facts active
+// at the end of date (the themes come from the primary user's summaries too).
+func activeFactsAt(db *sql.DB, date string) (map[string]string, error) {
+	out := map[string]string{}
+	primaryKeys, primaryArgs := userKeyWhere("fact_key", "")
+	rows, err := readDB(db).Query(`
+		SELECT fact_key, fact, status FROM user_facts_history
+		WHERE status IN ('active','archived','forgotten') AND substr(created_at,1,10) <= ?
+		  AND `+primaryKeys+`
+		ORDER BY created_at, id
+	`, append([]any{date}, primaryArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var key, fact, status string
+		if err := rows.Scan(&key, &fact, &status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if status == "active" {
+			out[key] = fact
+		} else {
+			delete(out, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// facts written before history existed
+	rows, err = readDB(db).Query(`
+		SELECT fact_key, fact FROM user_facts
+		WHERE substr(created_at,1,10) <= ?
+		  AND (is_active=1 OR (deleted_at IS NOT NULL AND substr(deleted_at,1,10) > ?))
+		  AND user_id = ''
+		  AND fact_key NOT IN (
+		    SELECT fact_key FROM user_facts_history WHERE status IN ('active','archived','forgotten')
+		  )
+	`, date, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, fact string
+		if err := rows.Scan(&key, &fact); err != nil {
+			return nil, err
+		}
+		out[key] = fact
+	}
+	return out, rows.Err()
+}
+
+// memoryDiffThemeFields is where each summary type keeps its themes, with its weight.
+var memoryDiffThemeFields = map[string]struct {
+	Field  string
+	Weight int
+}{
+	"monthly": {"top_themes", 3},
+	"weekly":  {"themes", 2},
+	"daily":   {"topics", 1},
+}
+
+// themesBefore counts the themes of summaries ending in (date-window, date].
+func themesBefore(cfg Config, db *sql.DB, date string, window int) (map[string]*ThemeCount, int, error) {
+	end, err := time.ParseInLocation("2006-01-02", date, cfg.Location)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := end.AddDate(0, 0, -(window - 1)).Format("2006-01-02")
+	rows, err := readDB(db).Query(`
+		SELECT type, json FROM summaries
+		WHERE type IN ('daily','weekly','monthly') AND deleted_at IS NULL
+		  AND end_date BETWEEN ? AND ?
+	`, start, date)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	out := map[string]*ThemeCount{}
+	n := 0
+	for rows.Next() {
+		var typ, js string
+		if err := rows.Scan(&typ, &js); err != nil {
+			return nil, 0, err
+		}
+		var m map[string]any
+		if json.Unmarshal([]byte(js), &m) != nil {
+			continue
+		}
+		f := memoryDiffThemeFields[typ]
+		n++
+		for _, t := range extractStringList(m[f.Field]) {
+			norm := normalizeTheme(t)
+			if norm == "" {
+				continue
+			}
+			if tc, ok := out[norm]; ok {
+				tc.Mentions += f.Weight
+			} else {
+				out[norm] = &ThemeCount{Theme: strings.TrimSpace(t), Mentions: f.Weight}
+			}
+		}
+	}
+	return out, n, rows.Err()
+}
+
+// normalizeTheme lowercases and drops spaces / punctuation, for matching only.
+func normalizeTheme(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// themeMatch finds norm among themes: exact, else one containing the other (2+ runes).
+func themeMatch(themes map[string]*ThemeCount, norm string) (string, bool) {
+	if _, ok := themes[norm]; ok {
+		return norm, true
+	}
+	if len([]rune(norm)) < 2 {
+		return "", false
+	}
+	keys := make([]string, 0, len(themes))
+	for k := range themes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if len([]rune(k)) >= 2 && (strings.Contains(k, norm) || strings.Contains(norm, k)) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+func diffThemes(from, to map[string]*ThemeCount) (emerged, faded, persistent []ThemeCount) {
+	emerged, faded, persistent = []ThemeCount{}, []ThemeCount{}, []ThemeCount{}
+	matched := map[string]bool{}
+	for norm, tc := range to {
+		if k, ok := themeMatch(from, norm); ok {
+			matched[k] = true
+			persistent = append(persistent, ThemeCount{Theme: tc.Theme, Mentions: tc.Mentions + from[k].Mentions})
+		} else {
+			emerged = append(emerged, *tc)
+		}
+	}
+	for norm, tc := range from {
+		if !matched[norm] {
+			faded = append(faded, *tc)
+		}
+	}
+	return topThemes(emerged), topThemes(faded), topThemes(persistent)
+}
+
+// topThemes sorts by mentions (then name) and keeps memoryDiffMaxThemes.
+func topThemes(in []ThemeCount) []ThemeCount {
+	sort.Slice(in, func(i, j int) bool {
+		if in[i].Mentions != in[j].Mentions {
+			return in[i].Mentions > in[j].Mentions
+		}
+		return in[i].Theme < in[j].Theme
+	})
+	if len(in) > memoryDiffMaxThemes {
+		in = in[:memoryDiffMaxThemes]
+	}
+	return in
+}
+
+// narrateMemoryDiff asks the chat model to tell the report as a short story.
+func narrateMemoryDiff(cfg Config, d MemoryDiff) (string, error) {
+	d.Narrative = ""
+	b, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	prompt := "Below is a structured report of how the user's remembered facts and recurring themes changed between " +
+		d.From + " and " + d.To + ".\n" +
+		"Write a short narrative (at most 6 sentences) of what changed in their life and work, addressed to the user as \"you\".\n" +
+		"Use ONLY the report; do not invent details. Write in " + outputLanguageName(cfg.OutputLanguage) + ".\n\n" +
+		"REPORT (JSON):\n" + string(b)
+	out, err := callLLMNonStream(cfg, prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// renderMemoryDiff is the plain-text report for /memory_diff.
+func renderMemoryDiff(d MemoryDiff) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("memory diff %s → %s (themes: %d-day windows, %d vs %d summaries)\n", d.From, d.To, d.WindowDays, d.SummariesFrom, d.SummariesTo))
+	b.WriteString(fmt.Sprintf("\nfacts: +%d -%d ~%d (=%d)\n", len(d.Facts.Added), len(d.Facts.Removed), len(d.Facts.Changed), d.Facts.Unchanged))
+	for _, f := range d.Facts.Added {
+		b.WriteString("  + " + f.After + "\n")
+	}
+	for _, f := range d.Facts.Removed {
+		b.WriteString("  - " + f.Before + "\n")
+	}
+	for _, f := range d.Facts.Changed {
+		b.WriteString("  ~ " + f.Before + " → " + f.After + "\n")
+	}
+	themes := func(label string, ts []ThemeCount) {
+		if len(ts) == 0 {
+			return
+		}
+		names := make([]string, 0, len(ts))
+		for _, t := range ts {
+			names = append(names, t.Theme)
+		}
+		b.WriteString(label + strings.Join(names, ", ") + "\n")
+	}
+	b.WriteString("\nthemes:\n")
+	themes("  emerged:    ", d.Themes.Emerged)
+	themes("  faded:      ", d.Themes.Faded)
+	themes("  persistent: ", d.Themes.Persistent)
+	if d.Narrative != "" {
+		b.WriteString("\n" + d.Narrative + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// runMemoryDiffCommand implements /memory_diff <from> [to] [--narrate] [--window N] for both CLI and web.
+func runMemoryDiffCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	args := map[string]string{}
+	var dates []string
+	fields := strings.Fields(arg)
+	for i := 0; i < len(fields); i++ {
+		switch f := fields[i]; {
+		case f == "--narrate":
+			args["narrate"] = "1"
+		case f == "--window" && i+1 < len(fields):
+			args["window"] = fields[i+1]
+			i++
+		case strings.HasPrefix(f, "--window="):
+			args["window"] = strings.TrimPrefix(f, "--window=")
+		default:
+			dates = append(dates, f)
+		}
+	}
+	if len(dates) == 0 || len(dates) > 2 {
+		return "usage: /memory_diff <from YYYY-MM-DD> [to] [--window N] [--narrate]", nil
+	}
+	args["from"] = dates[0]
+	if len(dates) == 2 {
+		args["to"] = dates[1]
+	}
+	q, err := parseMemoryDiffQuery(cfg, func(k string) string { return args[k] })
+	if err != nil {
+		return "", err
+	}
+	d, err := BuildMemoryDiff(cfg, db, q)
+	if err != nil {
+		return "", err
+	}
+	return renderMemoryDiff(d), nil
+}
@@ -0,0 +1,201 @@
+package app
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Per-message redaction ("please forget I said that")
+// - POST /api/chat/messages/:id/redact, id = "<date>:<seq>" (seq = 1-based
+//   JSONL line, same as messages.seq) or a numeric messages.id.
+// - The line is replaced by a tombstone {"kind":"redacted",...} so line
+//   numbers / seq stay stable; filterDialogJSONL and loadRawLinesForDate skip
+//   it, so it is gone from recent_raw, summaries, exports and fact capture.
+// - An existing daily summary of that day is queued for regeneration
+//   ("regen" job; the raw log no longer matches its source_hash). Another
+//   user's line queues their user_daily instead (users.go).
+// ============================================================
+
+const kindRedacted = "redacted"
+
+type RedactResult struct {
+	Date         string `json:"date"`
+	Seq          int    `json:"seq"`
+	Role         string `json:"role,omitempty"`
+	Already      bool   `json:"already,omitempty"`
+	SummaryRegen bool   `json:"summary_regen"`
+	RedactedAt   string `json:"redacted_at"`
+}
+
+// isRedactedLine reports whether a dialog JSONL line is a redaction tombstone.
+func isRedactedLine(line []byte) bool {
+	var m struct {
+		Kind string `json:"kind"`
+	}
+	return json.Unmarshal(line, &m) == nil && m.Kind == kindRedacted
+}
+
+// parseMessageRef resolves "<date>:<seq>" or a numeric messages.id to (date, seq).
+func parseMessageRef(db *sql.DB, ref string) (string, int, error) {
+	ref = strings.TrimSpace(ref)
+	if d, s, ok := strings.Cut(ref, ":"); ok {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return "", 0, fmt.Errorf("invalid date: %s", d)
+		}
+		seq, err := strconv.Atoi(s)
+		if err != nil || seq <= 0 {
+			return "", 0, fmt.Errorf("invalid seq: %s", s)
+		}
+		return d, seq, nil
+	}
+	id, err := strconv.ParseInt(ref, 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, fmt.Errorf("invalid message id: %s (use <date>:<seq> or a messages id)", ref)
+	}
+	var day string
+	var seq int
+	if err := db.QueryRow(`SELECT day, seq FROM messages WHERE id=?`, id).Scan(&day, &seq); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", 0, fmt.Errorf("message not found: %d", id)
+		}
+		return "", 0, err
+	}
+	return day, seq, nil
+}
+
+// RedactMessage tombstones one dialog line (JSONL file and/or messages table).
+func RedactMessage(cfg Config, db *sql.DB, lw *LogWriter, ref string) (RedactResult, error) {
+	date, seq, err := parseMessageRef(db, ref)
+	if err != nil {
+		return RedactResult{}, err
+	}
+	now := time.Now().In(cfg.Location)
+	res := RedactResult{Date: date, Seq: seq, RedactedAt: now.Format(time.RFC3339)}
+	tomb, _ := json.Marshal(map[string]string{"kind": kindRedacted, "redacted_at": res.RedactedAt})
+
+	found := false
+	user := "" // whose daily summary holds the line (users.go)
+	if logStorageWritesFile(cfg) {
+		err := lw.rewriteDayFile(date, func(b []byte) ([]byte, error) {
+			lines := bytes.Split(b, []byte("\n"))
+			if seq > len(lines) || len(bytes.TrimSpace(lines[seq-1])) == 0 {
+				return nil, fmt.Errorf("message not found: %s:%d", date, seq)
+			}
+			line := bytes.TrimSpace(lines[seq-1])
+			if isRedactedLine(line) {
+				res.Already = true
+				return nil, nil
+			}
+			if isLegacyOpLine(line) {
+				return nil, fmt.Errorf("%s:%d is not a dialog message", date, seq)
+			}
+			var m struct {
+				Role string `json:"role"`
+				User string `json:"user"`
+			}
+			_ = json.Unmarshal(line, &m)
+			res.Role, user = m.Role, m.User
+			lines[seq-1] = tomb
+			return bytes.Join(lines, []byte("\n")), nil
+		})
+		switch {
+		case err == nil:
+			found = true
+		case errors.Is(err, os.ErrNotExist):
+			// file mode: nothing else to look at; both/sqlite: the table may still have it
+			if !logStorageWritesDB(cfg) {
+				return res, fmt.Errorf("no raw log for %s (archived or never written)", date)
+			}
+		default:
+			return res, err
+		}
+	}
+
+	if db != nil && logStorageWritesDB(cfg) {
+		var role, kind, record string
+		qerr := db.QueryRow(`SELECT role, COALESCE(kind,''), record FROM messages WHERE day=? AND seq=?`, date, seq).Scan(&role, &kind, &record)
+		switch {
+		case qerr == nil && kind == kindRedacted:
+			if !found {
+				res.Already = true
+			}
+		case qerr == nil:
+			if _, err := db.Exec(`UPDATE messages SET role='', kind=?, content='', record=? WHERE day=? AND seq=?`,
+				kindRedacted, string(tomb), date, seq); err != nil {
+				return res, err
+			}
+			if res.Role == "" {
+				res.Role = role
+			}
+			if !found {
+				var m struct {
+					User string `json:"user"`
+				}
+				_ = json.Unmarshal([]byte(record), &m)
+				user = m.User
+			}
+			found = true
+		case errors.Is(qerr, sql.ErrNoRows):
+			if !found && !res.Already {
+				return res, fmt.Errorf("message not found: %s:%d", date, seq)
+			}
+		default:
+			return res, qerr
+		}
+	}
+	if res.Already {
+		return res, nil
+	}
+
+	if db != nil {
+		typ, key := "daily", date
+		if user != "" {
+			typ, key = summaryTypeUserDaily, userDailyKey(date, user)
+		}
+		if ok, _ := summaryExists(db, typ, key); ok {
+			if err := enqueueSummaryRegen(cfg, db, typ, key); err != nil {
+				log.Printf("[warn] redact %s:%d: schedule %s regen failed: %v", date, seq, typ, err)
+			} else {
+				res.SummaryRegen = true
+			}
+		}
+	}
+	return res, nil
+}
+
+// rewriteDayFile rewrites <LogDir>/<day>.jsonl under the writer lock. The open
+// append handle (today) is closed first and reopened by the next WriteRecord.
+// fn returning (nil, nil) leaves the file untouched.
+func (lw *LogWriter) rewriteDayFile(day string, fn func([]byte) ([]byte, error)) error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	path := filepath.Join(lw.cfg.LogDir, day+".jsonl")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := fn(b)
+	if err != nil || out == nil {
+		return err
+	}
+	if lw.currentDay == day && lw.file != nil {
+		_ = lw.file.Close()
+		lw.file = nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// ============================================================
+// Context window auto-tuning
+// - Startup: probe the chat server (llama.cpp /props, OpenAI-style /v1/models)
+//   for the model's context length.
+// - Derive MaxContextTokens / RecentMaxLines / ChunkMaxTokens defaults (explicit ENV wins).
+// - Per turn: count the prompt; warn near the limit and cut the
+//   lowest-priority context blocks instead of letting the server truncate
+//   (prompt_budget.go).
+// ============================================================
+
+const (
+	contextWarnRatio = 0.85 // warn above this share of the context window
+	contextFitRatio  = 0.90 // default prompt budget: cut low-priority blocks above this (leave room for the reply)
+)
+
+// AutoTuneContext probes the model's context length and derives defaults from it.
+func AutoTuneContext(cfg Config) Config {
+	if cfg.MaxContextTokens <= 0 && cfg.ContextProbe {
+		n, src, err := probeModelContext(cfg)
+		if err != nil {
+			log.Printf("[warn] context probe failed: %v (set TIMELAYER_MAX_CONTEXT_TOKENS to skip)", err)
+		} else {
+			cfg.MaxContextTokens = n
+			log.Printf("[info] model context: %d tokens (from %s)", n, src)
+		}
+	}
+	if cfg.MaxContextTokens > 0 && os.Getenv("TIMELAYER_RECENT_MAX_LINES") == "" {
+		cfg.RecentMaxLines = recentLinesForContext(cfg.MaxContextTokens)
+	}
+	if cfg.MaxContextTokens > 0 && cfg.ChunkMaxTokens <= 0 {
+		// half the window: the rest is the prompt template + the JSON answer
+		cfg.ChunkMaxTokens = cfg.MaxContextTokens / 2
+	}
+	return cfg
+}
+
+// recentLinesForContext gives recent_raw ~25% of the window; a line costs at most
+// ~300 tokens (loadRecentRaw truncates messages to 900 chars).
+func recentLinesForContext(ctxTokens int) int {
+	n := ctxTokens / 4 / 300
+	if n < 8 {
+		n = 8
+	}
+	if n > 60 {
+		n = 60
+	}
+	return n
+}
+
+// chatServerBase strips the OpenAI path from ChatURL: http://h:8080/v1/chat/completions -> http://h:8080
+func chatServerBase(chatURL string) (string, error) {
+	u, err := url.Parse(chatURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid chat url: %q", chatURL)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+func probeModelContext(cfg Config) (int, string, error) {
+	base, err := chatServerBase(cfg.ChatURL)
+	if err != nil {
+		return 0, "", err
+	}
+	client := &http.Client{Timeout: 3 * time.Second}
+
+	get := func(path string, v any) error {
+		resp, err := client.Get(base + path)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s: http %d", path, resp.StatusCode)
+		}
+		b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	}
+
+	// llama.cpp server
+	var props struct {
+		NCtx                      int `json:"n_ctx"`
+		DefaultGenerationSettings struct {
+			NCtx int `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+	}
+	perr := get("/props", &props)
+	if perr == nil {
+		if n := props.DefaultGenerationSettings.NCtx; n > 0 {
+			return n, "/props", nil
+		}
+		if props.NCtx > 0 {
+			return props.NCtx, "/props", nil
+		}
+	}
+
+	// OpenAI-compatible: llama.cpp meta.n_ctx_train, vLLM max_model_len, others context_length
+	var models struct {
+		Data []struct {
+			ID            string `json:"id"`
+			MaxModelLen   int    `json:"max_model_len"`
+			ContextLength int    `json:"context_length"`
+			Meta          struct {
+				NCtxTrain int `json:"n_ctx_train"`
+			} `json:"meta"`
+		} `json:"data"`
+	}
+	merr := get("/v1/models", &models)
+	if merr == nil {
+		for _, m := range models.Data {
+			if cfg.ChatModel != "" && len(models.Data) > 1 && m.ID != cfg.ChatModel {
+				continue
+			}
+			for _, n := range []int{m.MaxModelLen, m.ContextLength, m.Meta.NCtxTrain} {
+				if n > 0 {
+					return n, "/v1/models", nil
+				}
+			}
+		}
+	}
+
+	if perr == nil && merr == nil {
+		return 0, "", fmt.Errorf("no context length in /props or /v1/models")
+	}
+	return 0, "", fmt.Errorf("/props: %v; /v1/models: %v", perr, merr)
+}
+
+// estimateTokens is a cheap, conservative estimate: ~4 ASCII bytes per token,
+// 1 token per non-ASCII rune (CJK).
+func estimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return ascii/4 + other + 1
+}
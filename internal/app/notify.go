@@ -0,0 +1,52 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ============================================================
+// Notifications (optional, TIMELAYER_NOTIFY_URL)
+// - A plain JSON webhook: POST {"kind","title","text","ts"}.
+// - Point it at ntfy / Gotify / a Slack-compatible relay / your own script.
+// ============================================================
+
+var notifyHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// notifyEnabled reports whether a notification webhook is configured.
+func notifyEnabled(cfg Config) bool {
+	return cfg.NotifyURL != ""
+}
+
+// sendNotification posts one notification; a no-op when no webhook is configured.
+func sendNotification(cfg Config, kind, title, text string) error {
+	if !notifyEnabled(cfg) {
+		return nil
+	}
+	b, err := json.Marshal(map[string]string{
+		"kind":  kind,
+		"title": title,
+		"text":  text,
+		"ts":    time.Now().In(cfg.Location).Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", cfg.NotifyURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := notifyHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notify webhook: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
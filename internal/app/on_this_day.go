@@ -0,0 +1,185 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// On this day ("一年前的今天")
+// - What the daily summaries recorded on the same calendar day 1 / 3 / 6
+//   months ago and 1..onThisDayMaxYears years ago (a day that does not exist
+//   in the earlier month, e.g. 03-31 → 02-31, is skipped, not shifted).
+// - GET /api/on-this-day?date=2026-10-16 (default today).
+// - TIMELAYER_ON_THIS_DAY_NOTIFY=1: on day change a bg job "on_this_day"
+//   pushes the list through the notification webhook (once per day; nothing
+//   is sent when there is nothing to recall).
+// - TIMELAYER_ON_THIS_DAY_CONTEXT=1: chat gets an "on_this_day" block so the
+//   assistant can bring it up when it fits ("去年今天你在准备搬家").
+// - Trashed and skipped ("no significant activity") dailies are ignored.
+// ============================================================
+
+const (
+	jobKindOnThisDay = "on_this_day"
+
+	onThisDayMaxYears      = 10
+	onThisDayMaxHighlights = 3
+)
+
+var onThisDayMonths = []int{1, 3, 6}
+
+type OnThisDayItem struct {
+	Date       string   `json:"date"`
+	AgoMonths  int      `json:"ago_months"`
+	Label      string   `json:"label"` // 一年前 / 1 year ago
+	Title      string   `json:"title,omitempty"`
+	Highlights []string `json:"highlights,omitempty"`
+	Topics     []string `json:"topics,omitempty"`
+}
+
+// sameDayMonthsAgo returns the date `months` months before day, or false when
+// that month has no such day.
+func sameDayMonthsAgo(day time.Time, months int) (string, bool) {
+	y, m, d := day.Date()
+	first := time.Date(y, m, 1, 0, 0, 0, 0, day.Location()).AddDate(0, -months, 0)
+	if t := first.AddDate(0, 0, d-1); t.Month() == first.Month() {
+		return t.Format("2006-01-02"), true
+	}
+	return "", false
+}
+
+func onThisDayLabel(cfg Config, months int) string {
+	en := strings.HasPrefix(strings.ToLower(strings.TrimSpace(cfg.OutputLanguage)), "en")
+	switch {
+	case months%12 == 0 && en:
+		if months == 12 {
+			return "1 year ago"
+		}
+		return fmt.Sprintf("%d years ago", months/12)
+	case en:
+		if months == 1 {
+			return "1 month ago"
+		}
+		return fmt.Sprintf("%d months ago", months)
+	case months == 6:
+		return "半年前"
+	case months%12 == 0:
+		return chineseCount(months/12) + "年前"
+	default:
+		return chineseCount(months) + "个月前"
+	}
+}
+
+// chineseCount spells 1..10 (一 … 十, 两 for 2); larger numbers stay digits.
+func chineseCount(n int) string {
+	if n >= 1 && n <= 10 {
+		return []string{"一", "两", "三", "四", "五", "六", "七", "八", "九", "十"}[n-1]
+	}
+	return fmt.Sprint(n)
+}
+
+// FindOnThisDay lists the earlier dailies of date's calendar day, nearest first.
+func FindOnThisDay(cfg Config, db *sql.DB, date string) ([]OnThisDayItem, error) {
+	out := []OnThisDayItem{}
+	day, err := time.ParseInLocation("2006-01-02", date, cfg.Location)
+	if err != nil {
+		return out, fmt.Errorf("invalid date: %s", date)
+	}
+	if db == nil {
+		return out, nil
+	}
+	lookback := append([]int{}, onThisDayMonths...)
+	for y := 1; y <= onThisDayMaxYears; y++ {
+		lookback = append(lookback, 12*y)
+	}
+	for _, months := range lookback {
+		d, ok := sameDayMonthsAgo(day, months)
+		if !ok {
+			continue
+		}
+		var js, title string
+		err := db.QueryRow(`
+			SELECT json, COALESCE(title,'') FROM summaries
+			WHERE type='daily' AND period_key=? AND deleted_at IS NULL
+		`, d).Scan(&js, &title)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		var obj struct {
+			Highlights any             `json:"highlights"`
+			Topics     any             `json:"topics"`
+			Skipped    json.RawMessage `json:"skipped"`
+		}
+		if json.Unmarshal([]byte(js), &obj) != nil || len(obj.Skipped) > 0 {
+			continue
+		}
+		it := OnThisDayItem{Date: d, AgoMonths: months, Label: onThisDayLabel(cfg, months), Title: title}
+		for _, h := range extractStringList(obj.Highlights) {
+			if h = strings.TrimSpace(h); h != "" && len(it.Highlights) < onThisDayMaxHighlights {
+				it.Highlights = append(it.Highlights, h)
+			}
+		}
+		it.Topics = extractStringList(obj.Topics)
+		if it.Title == "" && len(it.Highlights) == 0 && len(it.Topics) == 0 {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out, nil
+}
+
+// renderOnThisDay is one line per item: "一年前（2025-10-16）：标题；亮点…".
+func renderOnThisDay(items []OnThisDayItem) string {
+	var b strings.Builder
+	for _, it := range items {
+		parts := []string{}
+		if it.Title != "" {
+			parts = append(parts, it.Title)
+		}
+		parts = append(parts, it.Highlights...)
+		if len(parts) == 0 {
+			parts = append(parts, strings.Join(it.Topics, "、"))
+		}
+		fmt.Fprintf(&b, "- %s（%s）：%s\n", it.Label, it.Date, strings.Join(parts, "；"))
+	}
+	return b.String()
+}
+
+// buildOnThisDayEvidence is the opt-in chat block ("" when off / nothing to recall).
+func buildOnThisDayEvidence(cfg Config, db *sql.DB, date string) string {
+	if !cfg.OnThisDayContext {
+		return ""
+	}
+	items, err := FindOnThisDay(cfg, db, date)
+	if err != nil || len(items) == 0 {
+		return ""
+	}
+	return renderOnThisDay(items)
+}
+
+// runOnThisDayNotify is the "on_this_day" bg job: push today's list to the webhook.
+func runOnThisDayNotify(cfg Config, db *sql.DB, date string) error {
+	if !cfg.OnThisDayNotify || !notifyEnabled(cfg) {
+		return nil
+	}
+	items, err := FindOnThisDay(cfg, db, date)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	title := "TimeLayer 往日今天 " + date
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(cfg.OutputLanguage)), "en") {
+		title = "TimeLayer on this day " + date
+	}
+	if err := sendNotification(cfg, jobKindOnThisDay, title, renderOnThisDay(items)); err != nil {
+		return err
+	}
+	log.Printf("[info] on this day %s: notified (%d items)", date, len(items))
+	return nil
+}
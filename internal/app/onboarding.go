@@ -0,0 +1,610 @@
+package app
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// First-run onboarding
+// - `local-ai init` (also started by the first plain `local-ai` run, before
+//   anything is created) and GET / POST /api/onboarding replace the silent
+//   ~/local-ai default: check the chat + embedding endpoints, then choose
+//   the base directory, summary language, timezone, your name and an
+//   optional persona.
+// - Choices are saved as KEY=VALUE lines in the settings file
+//   (TIMELAYER_SETTINGS_FILE, default <user config dir>/local-ai/settings.env)
+//   and loaded by defaultConfig on every start. A variable set in the real
+//   environment always wins over the file.
+// - Seeded profile (source_type "onboarding"): your name and timezone as
+//   core facts; the persona as an assistant profile that becomes
+//   TIMELAYER_ASSISTANT. Re-running init updates them (a different name
+//   goes through the usual conflict flow).
+// - A new base dir, endpoint or timezone applies on the next start
+//   (restart_required); the profile is seeded into the new base dir's DB.
+// ============================================================
+
+const (
+	sourceTypeOnboarding     = "onboarding"
+	onboardingDefaultPersona = "default"
+	onboardingCheckTimeout   = 20 * time.Second // under the 30s /api/ deadline
+)
+
+// onboardingKeys are the settings the wizard writes (in file order).
+var onboardingKeys = []string{
+	"TIMELAYER_BASE_DIR",
+	"TIMELAYER_CHAT_URL",
+	"TIMELAYER_EMBED_URL",
+	"TIMELAYER_CHAT_MODEL",
+	"TIMELAYER_OUTPUT_LANGUAGE",
+	"TIMELAYER_TIMEZONE",
+	"TIMELAYER_ASSISTANT",
+}
+
+type OnboardingRequest struct {
+	BaseDir   string `json:"base_dir,omitempty"`
+	ChatURL   string `json:"chat_url,omitempty"`
+	EmbedURL  string `json:"embed_url,omitempty"`
+	ChatModel string `json:"chat_model,omitempty"`
+	Language  string `json:"language,omitempty"` // summary / index language: zh | en | <name>
+	Timezone  string `json:"timezone,omitempty"` // IANA, e.g. Asia/Shanghai
+	Name      string `json:"name,omitempty"`     // your name → core fact
+	Persona   string `json:"persona,omitempty"`  // assistant persona prompt ("" = keep)
+	Assistant string `json:"assistant,omitempty"`
+}
+
+type OnboardingCheck struct {
+	Name   string `json:"name"` // chat | embedding
+	URL    string `json:"url"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Millis int64  `json:"ms"`
+}
+
+type OnboardingStatus struct {
+	Done         bool              `json:"done"` // the settings file exists
+	SettingsFile string            `json:"settings_file"`
+	Current      OnboardingRequest `json:"current"`
+	Checks       []OnboardingCheck `json:"checks,omitempty"`
+}
+
+type OnboardingResult struct {
+	SettingsFile    string   `json:"settings_file"`
+	BaseDir         string   `json:"base_dir"`
+	RestartRequired bool     `json:"restart_required"`
+	Seeded          []string `json:"seeded,omitempty"`
+	Warnings        []string `json:"warnings,omitempty"`
+}
+
+// ---------- settings file ----------
+
+// settingsFilePath is where onboarding choices live (outside the base dir it chooses).
+func settingsFilePath() string {
+	if v := strings.TrimSpace(os.Getenv("TIMELAYER_SETTINGS_FILE")); v != "" {
+		return expandHomeDir(v)
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "local-ai", "settings.env")
+}
+
+func expandHomeDir(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	return p
+}
+
+// readEnvFile parses KEY=VALUE lines (blank lines, "# ..." and "export " allowed).
+func readEnvFile(path string) ([][2]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out [][2]string
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, i+1)
+		}
+		out = append(out, [2]string{strings.TrimSpace(k), strings.Trim(strings.TrimSpace(v), `"'`)})
+	}
+	return out, nil
+}
+
+// settingsApplied remembers what applySettingsFile exported, so a rewritten
+// file is picked up on the next defaultConfig while real ENV still wins.
+var settingsApplied struct {
+	sync.Mutex
+	m map[string]string
+}
+
+// applySettingsFile exports the settings file into the environment (missing file = no-op).
+func applySettingsFile() {
+	kvs, err := readEnvFile(settingsFilePath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(os.Stderr, "[warn] settings:", err)
+		}
+		return
+	}
+	settingsApplied.Lock()
+	defer settingsApplied.Unlock()
+	if settingsApplied.m == nil {
+		settingsApplied.m = map[string]string{}
+	}
+	for _, kv := range kvs {
+		cur, set := os.LookupEnv(kv[0])
+		if prev, ours := settingsApplied.m[kv[0]]; set && (!ours || cur != prev) {
+			// real ENV wins
+			continue
+		}
+		_ = os.Setenv(kv[0], kv[1])
+		settingsApplied.m[kv[0]] = kv[1]
+	}
+}
+
+// writeSettingsFile sets keys in the settings file in place, keeping every other line.
+func writeSettingsFile(path string, set map[string]string) error {
+	var lines []string
+	done := map[string]bool{}
+	if b, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+			k, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+			k = strings.TrimSpace(k)
+			if v, ok := set[k]; ok {
+				if done[k] {
+					continue
+				}
+				line, done[k] = k+"="+v, true
+			}
+			lines = append(lines, line)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	} else {
+		lines = append(lines, "# written by `local-ai init` / POST /api/onboarding; real ENV wins")
+	}
+	for _, k := range onboardingKeys {
+		if v, ok := set[k]; ok && !done[k] {
+			lines = append(lines, k+"="+v)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// envOverridesSettings: k is set in the real environment (not by applySettingsFile).
+func envOverridesSettings(k string) bool {
+	cur, set := os.LookupEnv(k)
+	if !set {
+		return false
+	}
+	settingsApplied.Lock()
+	defer settingsApplied.Unlock()
+	prev, ours := settingsApplied.m[k]
+	return !ours || cur != prev
+}
+
+// ---------- status / checks ----------
+
+// onboardingCurrent is cfg expressed as wizard answers (the defaults offered).
+func onboardingCurrent(cfg Config, db *sql.DB) OnboardingRequest {
+	cur := OnboardingRequest{
+		BaseDir:   cfg.BaseDir,
+		ChatURL:   cfg.ChatURL,
+		EmbedURL:  cfg.EmbedURL,
+		ChatModel: cfg.ChatModel,
+		Language:  cfg.OutputLanguage,
+		Timezone:  cfg.Location.String(),
+		Assistant: cfg.AssistantName,
+	}
+	if cur.Timezone == "Local" {
+		cur.Timezone = localTimezoneName()
+	}
+	if db != nil && cfg.AssistantName != "" {
+		if a, err := LoadAssistant(db, cfg.AssistantName); err == nil {
+			cur.Persona = a.Persona
+		}
+	}
+	return cur
+}
+
+// localTimezoneName is the IANA name of the system zone ("" when unknown).
+func localTimezoneName() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		return tz
+	}
+	if p, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(p, "zoneinfo/"); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// GetOnboardingStatus reports whether onboarding ran, the current answers and (check) endpoint reachability.
+func GetOnboardingStatus(cfg Config, db *sql.DB, check bool) OnboardingStatus {
+	path := settingsFilePath()
+	_, err := os.Stat(path)
+	st := OnboardingStatus{Done: err == nil, SettingsFile: path, Current: onboardingCurrent(cfg, db)}
+	if check {
+		st.Checks = CheckOnboardingEndpoints(cfg)
+	}
+	return st
+}
+
+// CheckOnboardingEndpoints calls the chat and embedding endpoints once each,
+// concurrently. Each check gives up after onboardingCheckTimeout, so both
+// report inside the route deadline of GET /api/onboarding?check=1.
+func CheckOnboardingEndpoints(cfg Config) []OnboardingCheck {
+	ccfg := cfg
+	ccfg.HTTPTimeout = onboardingCheckTimeout
+
+	run := func(name, url string, fn func() (string, error)) OnboardingCheck {
+		type result struct {
+			detail string
+			err    error
+		}
+		start := time.Now()
+		done := make(chan result, 1)
+		go func() {
+			detail, err := fn()
+			done <- result{detail, err}
+		}()
+		var r result
+		select {
+		case r = <-done:
+		case <-time.After(onboardingCheckTimeout):
+			// the embedding client has its own, longer timeout; its call finishes unseen
+			r.err = fmt.Errorf("no answer within %s", onboardingCheckTimeout)
+		}
+		c := OnboardingCheck{Name: name, URL: url, OK: r.err == nil, Detail: r.detail, Millis: time.Since(start).Milliseconds()}
+		if r.err != nil {
+			c.Detail = r.err.Error()
+		}
+		return c
+	}
+	checks := make([]OnboardingCheck, 2)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		checks[0] = run("chat", cfg.ChatURL, func() (string, error) {
+			out, err := callLLMNonStream(ccfg, "Reply with one word: ok")
+			if err != nil {
+				return "", err
+			}
+			return "replied: " + clipRunes(strings.TrimSpace(out), 40), nil
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		checks[1] = run("embedding", cfg.EmbedURL, func() (string, error) {
+			vec, _, err := embedQueryText(cfg, "ping")
+			if err != nil {
+				return "", err
+			}
+			if len(vec) == 0 {
+				return "", errors.New("empty embedding")
+			}
+			return fmt.Sprintf("%d dims", len(vec)), nil
+		})
+	}()
+	wg.Wait()
+	return checks
+}
+
+// ---------- apply ----------
+
+// onboardingConfig is cfg with req's directories, endpoints, language and timezone.
+func onboardingConfig(cfg Config, req OnboardingRequest) (Config, error) {
+	if v := expandHomeDir(req.BaseDir); v != "" {
+		if !filepath.IsAbs(v) {
+			return cfg, fmt.Errorf("base dir must be absolute: %s", req.BaseDir)
+		}
+		v = filepath.Clean(v)
+		if v != cfg.BaseDir {
+			cfg.BaseDir = v
+			cfg.LogDir = filepath.Join(v, "logs")
+			cfg.ArchiveDir = filepath.Join(v, "logs", "archive")
+			cfg.PromptDir = filepath.Join(v, "prompts")
+			cfg.DBPath = filepath.Join(v, "memory", "memory.sqlite")
+		}
+	}
+	if v := strings.TrimSpace(req.Timezone); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return cfg, fmt.Errorf("unknown timezone %q (want e.g. Asia/Shanghai)", v)
+		}
+		cfg.Location = loc
+	}
+	if v := strings.TrimSpace(req.ChatURL); v != "" {
+		cfg.ChatURL = v
+	}
+	if v := strings.TrimSpace(req.EmbedURL); v != "" {
+		cfg.EmbedURL = v
+	}
+	if v := strings.TrimSpace(req.ChatModel); v != "" {
+		cfg.ChatModel = v
+	}
+	if v := strings.TrimSpace(req.Language); v != "" {
+		cfg.OutputLanguage = v
+	}
+	return cfg, nil
+}
+
+// ApplyOnboarding saves req to the settings file, creates the base dir and seeds the profile.
+// db is the running DB (nil from the CLI wizard); a different base dir gets its own DB.
+func ApplyOnboarding(cfg Config, db *sql.DB, req OnboardingRequest) (OnboardingResult, error) {
+	target, err := onboardingConfig(cfg, req)
+	if err != nil {
+		return OnboardingResult{}, err
+	}
+	persona := strings.TrimSpace(req.Persona)
+	assistant := strings.TrimSpace(req.Assistant)
+	if persona != "" && assistant == "" {
+		assistant = onboardingDefaultPersona
+	}
+	if assistant != "" {
+		target.AssistantName = assistant
+	}
+
+	// only what was answered: a partial POST must not pin the other defaults
+	set := map[string]string{}
+	for k, v := range map[string]string{
+		"TIMELAYER_BASE_DIR":        req.BaseDir,
+		"TIMELAYER_CHAT_URL":        req.ChatURL,
+		"TIMELAYER_EMBED_URL":       req.EmbedURL,
+		"TIMELAYER_CHAT_MODEL":      req.ChatModel,
+		"TIMELAYER_OUTPUT_LANGUAGE": req.Language,
+		"TIMELAYER_TIMEZONE":        req.Timezone,
+		"TIMELAYER_ASSISTANT":       assistant,
+	} {
+		if v = strings.TrimSpace(v); v != "" {
+			set[k] = v
+		}
+	}
+	if _, ok := set["TIMELAYER_BASE_DIR"]; ok {
+		set["TIMELAYER_BASE_DIR"] = target.BaseDir
+	}
+	res := OnboardingResult{
+		SettingsFile: settingsFilePath(),
+		BaseDir:      target.BaseDir,
+		RestartRequired: target.BaseDir != cfg.BaseDir || target.ChatURL != cfg.ChatURL ||
+			target.EmbedURL != cfg.EmbedURL || target.ChatModel != cfg.ChatModel ||
+			target.Location.String() != cfg.Location.String() || target.AssistantName != cfg.AssistantName,
+	}
+	if err := writeSettingsFile(res.SettingsFile, set); err != nil {
+		return res, err
+	}
+	for k, v := range set {
+		if envOverridesSettings(k) && os.Getenv(k) != v {
+			res.Warnings = append(res.Warnings, k+" is set in the environment and overrides the settings file")
+		}
+	}
+	sort.Strings(res.Warnings)
+
+	mustEnsureDirs(target)
+	mustEnsurePromptFiles(target)
+	if db == nil || target.DBPath != cfg.DBPath {
+		tdb := mustOpenDB(target)
+		defer closeDB(tdb)
+		db = tdb
+	}
+	res.Seeded, err = seedOnboardingProfile(target, db, req.Name, req.Timezone, assistant, persona)
+	return res, err
+}
+
+// seedOnboardingProfile records name / timezone as core facts and the persona as an assistant.
+func seedOnboardingProfile(cfg Config, db *sql.DB, name, tz, assistant, persona string) ([]string, error) {
+	now := time.Now().In(cfg.Location)
+	today := now.Format("2006-01-02")
+	var seeded []string
+
+	facts := []string{}
+	if name = strings.TrimSpace(name); name != "" {
+		facts = append(facts, "我叫"+name)
+	}
+	if tz = strings.TrimSpace(tz); tz != "" {
+		facts = append(facts, "我的时区是"+tz)
+	}
+	for _, f := range facts {
+		out, err := ProposeRememberFact(cfg, db, f, sourceTypeOnboarding, today, now)
+		if err != nil {
+			return seeded, err
+		}
+		switch {
+		case out == nil:
+			continue
+		case out.Status == "conflict":
+			seeded = append(seeded, fmt.Sprintf("conflict: %q vs %q (FACTS -> CONFLICTS)", out.Existing, f))
+			continue
+		case out.Status == "blocked":
+			seeded = append(seeded, "blocked by a fact policy rule: "+f)
+			continue
+		}
+		if out.FactKey != "" {
+			if err := SetFactCore(cfg, db, out.FactKey, true); err != nil {
+				return seeded, err
+			}
+		}
+		seeded = append(seeded, "core fact: "+f)
+	}
+
+	if persona != "" {
+		a, err := LoadAssistant(db, assistant)
+		if err != nil {
+			a = AssistantProfile{Name: assistant}
+		}
+		a.Persona = persona
+		if _, err := SaveAssistant(cfg, db, a); err != nil {
+			return seeded, err
+		}
+		seeded = append(seeded, "assistant: "+assistant)
+	}
+	return seeded, nil
+}
+
+// ---------- CLI ----------
+
+// needsOnboarding: the first plain run, before anything was created or chosen.
+func needsOnboarding(cfg Config) bool {
+	if os.Getenv("TIMELAYER_BASE_DIR") != "" {
+		return false
+	}
+	if _, err := os.Stat(settingsFilePath()); err == nil {
+		return false
+	}
+	_, err := os.Stat(cfg.BaseDir)
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// runInitCLI is the interactive wizard.
+//
+//	local-ai init [--check]   (--check: endpoint checks only, nothing is written)
+func runInitCLI(cfg Config, args []string) int {
+	checkOnly := false
+	for _, a := range args {
+		switch a {
+		case "--check":
+			checkOnly = true
+		default:
+			fmt.Println("usage: local-ai init [--check]")
+			return 2
+		}
+	}
+	in := bufio.NewReader(os.Stdin)
+	ask := func(label, def string) (string, error) {
+		if def != "" {
+			fmt.Printf("%s [%s]: ", label, def)
+		} else {
+			fmt.Printf("%s: ", label)
+		}
+		line, err := readLine(in)
+		if err != nil {
+			return "", err
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			return def, nil
+		}
+		return line, nil
+	}
+
+	cur := onboardingCurrent(cfg, nil)
+	req := cur
+	var err error
+	if !checkOnly {
+		fmt.Println("🧠 local-ai setup  (Enter keeps the value in brackets)")
+		fmt.Println()
+	}
+	for {
+		if !checkOnly {
+			if req.ChatURL, err = ask("Chat endpoint", req.ChatURL); err != nil {
+				return 1
+			}
+			if req.EmbedURL, err = ask("Embedding endpoint", req.EmbedURL); err != nil {
+				return 1
+			}
+		}
+		ccfg, err := onboardingConfig(cfg, OnboardingRequest{ChatURL: req.ChatURL, EmbedURL: req.EmbedURL})
+		if err != nil {
+			fmt.Println("[error]", err)
+			return 1
+		}
+		allOK := true
+		for _, c := range CheckOnboardingEndpoints(ccfg) {
+			status := "OK  "
+			if !c.OK {
+				status, allOK = "FAIL", false
+			}
+			fmt.Printf("  %s %-9s %5dms  %s\n", status, c.Name, c.Millis, c.Detail)
+		}
+		if checkOnly {
+			if !allOK {
+				return 1
+			}
+			return 0
+		}
+		if allOK {
+			break
+		}
+		again, err := ask("Endpoints unreachable: retry / continue anyway", "retry")
+		if err != nil {
+			return 1
+		}
+		if !strings.HasPrefix(strings.ToLower(again), "r") {
+			break
+		}
+	}
+
+	fmt.Println()
+	steps := []struct {
+		label string
+		v     *string
+	}{
+		{"Base directory (logs, memory DB, prompts)", &req.BaseDir},
+		{"Summary language (zh / en / ...)", &req.Language},
+		{"Timezone (IANA)", &req.Timezone},
+		{"Your name (remembered as a core fact; empty = skip)", &req.Name},
+		{"Assistant persona (empty = none)", &req.Persona},
+	}
+	for _, s := range steps {
+		def := *s.v
+		for {
+			if *s.v, err = ask(s.label, def); err != nil {
+				return 1
+			}
+			// validate base dir / timezone before moving on
+			if _, err := onboardingConfig(cfg, OnboardingRequest{BaseDir: req.BaseDir, Timezone: req.Timezone}); err != nil {
+				fmt.Println("[error]", err)
+				*s.v = def
+				continue
+			}
+			break
+		}
+	}
+	if req.Persona == cur.Persona {
+		req.Persona = "" // unchanged: keep the stored assistant as is
+	}
+
+	res, err := ApplyOnboarding(cfg, nil, req)
+	if err != nil {
+		fmt.Println("[error]", err)
+		return 1
+	}
+	fmt.Println()
+	fmt.Println("[ok] settings saved:", res.SettingsFile)
+	fmt.Println("[ok] base dir:", res.BaseDir)
+	for _, s := range res.Seeded {
+		fmt.Println("[ok]", s)
+	}
+	for _, w := range res.Warnings {
+		fmt.Println("[warn]", w)
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ============================================================
+// Op records（内部事件，不是对话）
+// - Structured: {ts, op_type, payload}
+// - Stored apart from dialog: <LogDir>/ops/<date>.jsonl and/or ops_log table
+//   (follows TIMELAYER_LOG_STORAGE), so summary/context readers never see them.
+// ============================================================
+
+const (
+	opFactsIntent       = "facts_intent"        // 记住：/ 忘记： prefix detected
+	opFactsUsage        = "facts_usage"         // empty facts intent, usage returned
+	opFactsIngestFailed = "facts_ingest_failed" // pending proposal failed
+	opForgetFailed      = "forget_failed"
+	opFactBlocked       = "fact_blocked" // dropped by a deny rule (fact_policy.go)
+)
+
+type OpRecord struct {
+	TS      string          `json:"ts"`
+	OpType  string          `json:"op_type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func opsLogDir(cfg Config) string {
+	return filepath.Join(cfg.LogDir, "ops")
+}
+
+// WriteOp records an internal event. It never touches the dialog log.
+func (lw *LogWriter) WriteOp(opType string, payload any) error {
+	if lw == nil {
+		return nil
+	}
+	var db dbTX
+	if lw.db != nil {
+		db = lw.db
+	}
+	return writeOpRecord(lw.cfg, db, opType, payload)
+}
+
+// writeOpRecord is WriteOp for callers without a LogWriter (db may be a tx or nil).
+func writeOpRecord(cfg Config, db dbTX, opType string, payload any) error {
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	now := time.Now().In(loc)
+
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	rec := OpRecord{TS: now.Format(time.RFC3339), OpType: opType, Payload: p}
+
+	if logStorageWritesDB(cfg) && db != nil {
+		if _, err := db.Exec(
+			`INSERT INTO ops_log(day, op_type, payload, created_at) VALUES(?,?,?,?)`,
+			now.Format("2006-01-02"), rec.OpType, string(rec.Payload), rec.TS,
+		); err != nil && !logStorageWritesFile(cfg) {
+			return err
+		}
+	}
+	if !logStorageWritesFile(cfg) {
+		return nil
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	dir := opsLogDir(cfg)
+	_ = os.MkdirAll(dir, 0755)
+	f, err := os.OpenFile(filepath.Join(dir, now.Format("2006-01-02")+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// filterDialogJSONL drops malformed lines (counted), legacy op records and redaction tombstones.
+func filterDialogJSONL(b []byte) ([]byte, int) {
+	var out []byte
+	bad := scanJSONL(b, func(line []byte) {
+		if isLegacyOpLine(line) || isRedactedLine(line) {
+			return
+		}
+		out = append(out, line...)
+		out = append(out, '\n')
+	})
+	return out, bad
+}
+
+// isLegacyOpLine reports whether a dialog JSONL line is an old-style op record
+// ({"kind":"op"}), written before ops moved to their own log.
+func isLegacyOpLine(line []byte) bool {
+	var m struct {
+		Kind string `json:"kind"`
+	}
+	return json.Unmarshal(line, &m) == nil && m.Kind == "op"
+}
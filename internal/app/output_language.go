@@ -0,0 +1,153 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// ============================================================
+// Summary output language
+// - {{OUTPUT_LANGUAGE}} in summary prompts is filled from Config.OutputLanguage
+//   (TIMELAYER_OUTPUT_LANGUAGE), independent of the conversation language.
+//   Unset keeps the built-in Chinese prompts and skips validation.
+// - Configured outputs are validated; a mismatch gets ONE rewrite pass, and a
+//   rewrite that still mismatches is logged and kept.
+// ============================================================
+
+// outputLanguageName maps a language code to the name used in prompts.
+// Unknown values are passed through as-is (and are not validated).
+func outputLanguageName(code string) string {
+	switch strings.ToLower(strings.TrimSpace(code)) {
+	case "", "zh", "zh-cn", "zh-hans":
+		return "Simplified Chinese (简体中文)"
+	case "en", "en-us", "en-gb":
+		return "English"
+	}
+	return code
+}
+
+func applyOutputLanguage(cfg Config, prompt string) string {
+	return strings.ReplaceAll(prompt, "{{OUTPUT_LANGUAGE}}", outputLanguageName(cfg.OutputLanguage))
+}
+
+// outputLanguageRule is the rule line shared by templates and merge prompts.
+func outputLanguageRule(cfg Config) string {
+	return "- Write ALL free-text values in " + outputLanguageName(cfg.OutputLanguage) +
+		", regardless of the language of the input. Keep JSON keys in English.\n"
+}
+
+// summaryLanguageSkipKeys are never language-checked (verbatim user text / identifiers).
+var summaryLanguageSkipKeys = map[string]bool{
+	"type": true, "date": true, "week_key": true, "week_start": true, "week_end": true,
+	"month": true, "month_start": true, "month_end": true, "user_facts_explicit": true,
+}
+
+// checkSummaryLanguage validates that free-text values of a summary JSON are
+// written in the configured language. Only zh / en are validated; an unset
+// language is never checked.
+func checkSummaryLanguage(cfg Config, summaryJSON string) error {
+	code := strings.ToLower(strings.TrimSpace(cfg.OutputLanguage))
+	isZh := strings.HasPrefix(code, "zh")
+	isEn := strings.HasPrefix(code, "en")
+	if !isZh && !isEn {
+		return nil
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(summaryJSON), &obj); err != nil {
+		return err
+	}
+	var texts []string
+	for k, v := range obj {
+		if summaryLanguageSkipKeys[k] {
+			continue
+		}
+		collectJSONStrings(v, &texts)
+	}
+
+	han, words := 0, 0
+	for _, t := range texts {
+		inWord := false
+		for _, r := range t {
+			switch {
+			case unicode.Is(unicode.Han, r):
+				han++
+				inWord = false
+			case unicode.Is(unicode.Latin, r):
+				if !inWord {
+					words++
+				}
+				inWord = true
+			default:
+				inWord = false
+			}
+		}
+	}
+	total := han + words
+	if total < 5 {
+		return nil
+	}
+	ratio := float64(han) / float64(total)
+	if isZh && ratio < 0.5 {
+		return fmt.Errorf("summary language mismatch: want %s, han ratio=%.2f", code, ratio)
+	}
+	if isEn && ratio > 0.2 {
+		return fmt.Errorf("summary language mismatch: want %s, han ratio=%.2f", code, ratio)
+	}
+	return nil
+}
+
+func collectJSONStrings(v any, out *[]string) {
+	switch x := v.(type) {
+	case string:
+		*out = append(*out, x)
+	case []any:
+		for _, e := range x {
+			collectJSONStrings(e, out)
+		}
+	case map[string]any:
+		for _, e := range x {
+			collectJSONStrings(e, out)
+		}
+	}
+}
+
+// enforceOutputLanguage returns summaryJSON if it passes checkSummaryLanguage;
+// otherwise asks the model once to rewrite it. A rewrite that fails or still
+// mismatches is only logged: a mostly-English or code-heavy day should not
+// fail the rollup.
+func enforceOutputLanguage(cfg Config, db *sql.DB, kind, summaryJSON string) (string, error) {
+	if err := checkSummaryLanguage(cfg, summaryJSON); err == nil {
+		return summaryJSON, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("You are a strict JSON rewriter.\n")
+	b.WriteString("Rewrite the JSON below. Keep the exact same keys, structure and meaning.\n")
+	b.WriteString("CRITICAL RULES:\n")
+	b.WriteString("- Output JSON only.\n")
+	b.WriteString("- Do NOT add or remove items.\n")
+	b.WriteString("- Do NOT change the field \"user_facts_explicit\" (verbatim user text).\n")
+	b.WriteString(outputLanguageRule(cfg))
+	b.WriteString("\nJSON:\n")
+	b.WriteString(summaryJSON)
+
+	out, err := callBackgroundLLM(cfg, db, b.String())
+	if err != nil {
+		log.Printf("[warn] %s language rewrite failed, keeping the original: %v", kind, err)
+		return summaryJSON, nil
+	}
+	out = strings.TrimSpace(out)
+	if !json.Valid([]byte(out)) {
+		log.Printf("[warn] %s language rewrite output invalid JSON, keeping the original", kind)
+		return summaryJSON, nil
+	}
+	if err := checkSummaryLanguage(cfg, out); err != nil {
+		log.Printf("[warn] %s summary kept after language rewrite: %v", kind, err)
+	}
+	return out, nil
+}
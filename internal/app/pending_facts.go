@@ -10,21 +10,31 @@ import (
 )
 
 const (
-	pendingFactMinConfidence = 0.75
+	pendingFactMinConfidence = 0.75 // default for cfg.PendingMinConf
 	pendingFactDefaultConf   = 0.85
 )
 
+// pendingMinConfidence is cfg.PendingMinConf (TIMELAYER_PENDING_MIN_CONFIDENCE).
+func pendingMinConfidence(cfg Config) float64 {
+	if cfg.PendingMinConf <= 0 {
+		return pendingFactMinConfidence
+	}
+	return cfg.PendingMinConf
+}
+
 // PendingFact is a candidate fact waiting for user confirmation.
 type PendingFact struct {
-	ID         int64   `json:"id"`
-	Fact       string  `json:"fact"`
-	FactKey    string  `json:"fact_key"`
-	Confidence float64 `json:"confidence"`
-	SourceType string  `json:"source_type"`
-	SourceKey  string  `json:"source_key"`
-	Status     string  `json:"status"`
-	CreatedAt  string  `json:"created_at"`
-	UpdatedAt  string  `json:"updated_at"`
+	ID         int64    `json:"id"`
+	Fact       string   `json:"fact"`
+	FactKey    string   `json:"fact_key"`
+	Confidence float64  `json:"confidence"`
+	SourceType string   `json:"source_type"`
+	SourceKey  string   `json:"source_key"`
+	Status     string   `json:"status"`
+	Sources    []string `json:"sources,omitempty"`  // every "source_type:source_key" that proposed this content
+	Evidence   string   `json:"evidence,omitempty"` // JSON provenance (e.g. correction exchange)
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
 }
 
 type pendingFactCandidate struct {
@@ -47,17 +57,20 @@ func addPendingFact(cfg Config, db dbTX, fact string, confidence float64, source
 	if confidence <= 0 {
 		confidence = pendingFactDefaultConf
 	}
-	if confidence < pendingFactMinConfidence {
+	if confidence < pendingMinConfidence(cfg) {
 		return nil
 	}
 	if sourceType == "" {
 		sourceType = "manual"
 	}
 
-	factKey := deriveFactKeyFromSubject(fact)
+	factKey := deriveUserFactKey(cfg, fact)
 	if factKey == "" {
 		return nil
 	}
+	if factBlocked(cfg, db, fact, sourceType, sourceKey) {
+		return nil
+	}
 
 	// Skip if already an active remembered fact
 	if hasActiveUserFact(db, factKey) {
@@ -78,43 +91,191 @@ func addPendingFact(cfg Config, db dbTX, fact string, confidence float64, source
 	// Older DBs may not have a UNIQUE constraint matching the ON CONFLICT clause.
 	// To avoid breaking upgrades, we do a read-then-update/insert upsert here.
 	// This keeps pending ingestion working even if the schema evolved.
-	var existingID int64
-	var existingConf float64
-	err := db.QueryRow(`
-		SELECT id, confidence
-		FROM pending_facts
-		WHERE fact_key=? AND status='pending' AND source_type=? AND source_key=?
-		ORDER BY updated_at DESC
-		LIMIT 1
-	`, factKey, sourceType, sourceKey).Scan(&existingID, &existingConf)
-
-	if err == nil && existingID > 0 {
+	//
+	// Dedup is content-level across sources: the same fact from daily and
+	// realtime_implicit lands in ONE row (max confidence, both in `sources`).
+	// A row from the same source/key is still updated in place (old behavior).
+	ex, err := findPendingDuplicate(db, factKey, fact, sourceType, sourceKey)
+	if err != nil {
+		return err
+	}
+	tag := pendingSourceTag(sourceType, sourceKey)
+	if ex != nil {
 		newConf := confidence
-		if existingConf > newConf {
-			newConf = existingConf
+		if ex.Confidence > newConf {
+			newConf = ex.Confidence
+		}
+		newFact := ex.Fact
+		if ex.SourceType == sourceType && ex.SourceKey == sourceKey {
+			newFact = fact
 		}
 		_, uerr := db.Exec(`
 			UPDATE pending_facts
-			SET fact=?, confidence=?, updated_at=?
+			SET fact=?, confidence=?, sources=?, updated_at=?
 			WHERE id=?
-		`, fact, newConf, nowStr, existingID)
+		`, newFact, newConf, encodePendingSources(ex.Sources, tag), nowStr, ex.ID)
+		invalidatePendingGroups()
+		if uerr == nil && newFact != ex.Fact {
+			// text changed: the stored vector is stale
+			_, _ = db.Exec(`DELETE FROM pending_fact_embeddings WHERE pending_fact_id=?`, ex.ID)
+			kickPendingEmbeddings()
+		}
 		return uerr
 	}
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return err
-	}
 
 	_, ierr := db.Exec(`
 		INSERT INTO pending_facts(
 		  fact, fact_key, confidence,
-		  source_type, source_key,
-		  status, created_at, updated_at
+		  source_type, source_key, sources,
+		  status, user_id, created_at, updated_at
 		)
-		VALUES(?,?,?,?,?, 'pending', ?, ?)
-	`, fact, factKey, confidence, sourceType, sourceKey, nowStr, nowStr)
+		VALUES(?,?,?,?,?,?, 'pending', ?, ?, ?)
+	`, fact, factKey, confidence, sourceType, sourceKey, encodePendingSources(nil, tag), keyUser(factKey), nowStr, nowStr)
+	invalidatePendingGroups()
+	if ierr == nil {
+		kickPendingEmbeddings()
+	}
 	return ierr
 }
 
+// pendingContentKey compares pending facts by content (case / spacing / punctuation-insensitive).
+func pendingContentKey(fact string) string {
+	return normalizeFactKey(normalizeText(normalizePendingFactText(fact)))
+}
+
+// pendingSourceTag is one entry of pending_facts.sources ("daily:2026-10-14").
+func pendingSourceTag(sourceType, sourceKey string) string {
+	return sourceType + ":" + sourceKey
+}
+
+func decodePendingSources(raw string) []string {
+	var out []string
+	if strings.TrimSpace(raw) == "" || json.Unmarshal([]byte(raw), &out) != nil {
+		return nil
+	}
+	return out
+}
+
+// encodePendingSources returns the JSON array of srcs plus tags (deduped, order kept).
+func encodePendingSources(srcs []string, tags ...string) string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(srcs)+len(tags))
+	for _, s := range append(append([]string{}, srcs...), tags...) {
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	b, _ := json.Marshal(out)
+	return string(b)
+}
+
+// findPendingDuplicate returns the pending row fact should merge into: the row
+// of the same source/key, else a row with the same content from any source.
+func findPendingDuplicate(db dbTX, factKey, fact, sourceType, sourceKey string) (*PendingFact, error) {
+	rows, err := db.Query(`
+		SELECT id, fact, confidence, source_type, source_key, COALESCE(sources,'')
+		FROM pending_facts
+		WHERE fact_key=? AND status='pending'
+		ORDER BY updated_at DESC
+	`, factKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ck := pendingContentKey(fact)
+	var same, byContent *PendingFact
+	for rows.Next() {
+		var pf PendingFact
+		var srcs string
+		if err := rows.Scan(&pf.ID, &pf.Fact, &pf.Confidence, &pf.SourceType, &pf.SourceKey, &srcs); err != nil {
+			continue
+		}
+		pf.Sources = decodePendingSources(srcs)
+		if len(pf.Sources) == 0 {
+			pf.Sources = []string{pendingSourceTag(pf.SourceType, pf.SourceKey)}
+		}
+		if same == nil && pf.SourceType == sourceType && pf.SourceKey == sourceKey {
+			p := pf
+			same = &p
+		}
+		if byContent == nil && pendingContentKey(pf.Fact) == ck {
+			p := pf
+			byContent = &p
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if same != nil {
+		return same, nil
+	}
+	return byContent, nil
+}
+
+// mergeDuplicatePendingFacts folds pending rows with the same content but
+// different sources (written before cross-source dedup) into the oldest one.
+func mergeDuplicatePendingFacts(db *sql.DB) {
+	rows, err := db.Query(`
+		SELECT id, fact_key, fact, confidence, source_type, source_key, COALESCE(sources,'')
+		FROM pending_facts
+		WHERE status='pending' AND fact_key IN (
+			SELECT fact_key FROM pending_facts WHERE status='pending' GROUP BY fact_key HAVING COUNT(1)>1
+		)
+		ORDER BY created_at ASC, id ASC
+	`)
+	if err != nil {
+		return
+	}
+	type row struct {
+		PendingFact
+		key string
+	}
+	keep := map[string]*row{} // fact_key + content key -> survivor
+	var order []*row
+	var drop []int64
+	for rows.Next() {
+		var r row
+		var srcs string
+		if rows.Scan(&r.ID, &r.FactKey, &r.Fact, &r.Confidence, &r.SourceType, &r.SourceKey, &srcs) != nil {
+			continue
+		}
+		r.Sources = decodePendingSources(srcs)
+		if len(r.Sources) == 0 {
+			r.Sources = []string{pendingSourceTag(r.SourceType, r.SourceKey)}
+		}
+		r.key = r.FactKey + "\x00" + pendingContentKey(r.Fact)
+		k := keep[r.key]
+		if k == nil {
+			rr := r
+			keep[r.key] = &rr
+			order = append(order, &rr)
+			continue
+		}
+		if r.Confidence > k.Confidence {
+			k.Confidence = r.Confidence
+		}
+		k.Sources = append(k.Sources, r.Sources...)
+		drop = append(drop, r.ID)
+	}
+	rows.Close()
+	if len(drop) == 0 {
+		return
+	}
+
+	defer invalidatePendingGroups()
+	for _, k := range order {
+		_, _ = db.Exec(`UPDATE pending_facts SET confidence=?, sources=? WHERE id=?`,
+			k.Confidence, encodePendingSources(k.Sources), k.ID)
+	}
+	for _, id := range drop {
+		_, _ = db.Exec(`DELETE FROM pending_fact_embeddings WHERE pending_fact_id=?`, id)
+		_, _ = db.Exec(`DELETE FROM pending_facts WHERE id=?`, id)
+	}
+}
+
 // normalizePendingFactText removes common instruction wrappers and trailing punctuation
 // to avoid polluting fact_key derivation (e.g. "记住：我最喜欢的颜色是黄色。" -> "我最喜欢的颜色是黄色").
 func normalizePendingFactText(s string) string {
@@ -189,11 +350,11 @@ func EnsurePendingFactsFromDailyJSON(cfg Config, db *sql.DB, date string, dailyJ
 			if conf <= 0 {
 				conf = defaultConf
 			}
-			if conf < pendingFactMinConfidence {
+			if conf < pendingMinConfidence(cfg) {
 				continue
 			}
 
-			factKey := deriveFactKeyFromSubject(fact)
+			factKey := deriveUserFactKey(cfg, fact)
 			if factKey == "" {
 				continue
 			}
@@ -265,7 +426,7 @@ func parsePendingCandidates(raw any) []pendingFactCandidate {
 }
 
 func hasActiveUserFact(db dbTX, factKey string) bool {
-	row := db.QueryRow(`SELECT 1 FROM user_facts WHERE is_active=1 AND fact_key=? LIMIT 1`, factKey)
+	row := stmtQueryRow(db, `SELECT 1 FROM user_facts WHERE is_active=1 AND fact_key=? LIMIT 1`, factKey)
 	var one int
 	return row.Scan(&one) == nil
 }
@@ -274,27 +435,147 @@ func CountPendingFacts(db *sql.DB) int {
 	if db == nil {
 		return 0
 	}
-	row := db.QueryRow(`SELECT COUNT(1) FROM pending_facts WHERE status='pending'`)
+	row := stmtQueryRow(db, `SELECT COUNT(1) FROM pending_facts WHERE status='pending'`)
 	var n int
 	_ = row.Scan(&n)
 	return n
 }
 
+// CountPendingFactsBySource counts pending facts per source_type (unfiltered).
+func CountPendingFactsBySource(db *sql.DB) map[string]int {
+	out := map[string]int{}
+	if db == nil {
+		return out
+	}
+	rows, err := db.Query(`SELECT source_type, COUNT(1) FROM pending_facts WHERE status='pending' GROUP BY source_type`)
+	if err != nil {
+		return out
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var st string
+		var n int
+		if rows.Scan(&st, &n) == nil {
+			out[st] = n
+		}
+	}
+	return out
+}
+
+// Pending list ordering (PendingFactQuery.Sort).
+const (
+	pendingSortNewest     = "newest"     // created_at desc (default)
+	pendingSortConfidence = "confidence" // confidence desc, then newest
+	pendingSortAge        = "age"        // oldest first (backlog triage)
+)
+
+// errUnknownCursor: the ?after_id= row of a paged list does not exist.
+var errUnknownCursor = errors.New("unknown after_id")
+
+// PendingFactQuery filters / orders the pending list.
+type PendingFactQuery struct {
+	Sort          string
+	MinConfidence float64
+	SourceType    string // exact source_type; empty = all
+	User          string // whose pending facts ("" = primary, see users.go)
+	Limit         int
+	AfterID       int64  // cursor: continue after this id in the chosen order (0 = first page)
+	Refresh       bool   // groups: bypass the clustering cache
+	Rep           string // groups: representative strategy (pending_facts_group_rep.go)
+}
+
+func normalizePendingSort(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case pendingSortConfidence, "conf":
+		return pendingSortConfidence
+	case pendingSortAge, "oldest":
+		return pendingSortAge
+	default:
+		return pendingSortNewest
+	}
+}
+
 func ListPendingFacts(db *sql.DB, limit int) ([]PendingFact, error) {
+	return ListPendingFactsQuery(db, PendingFactQuery{Limit: limit})
+}
+
+// pendingFactFilter is the WHERE clause of q (without the cursor).
+func pendingFactFilter(q PendingFactQuery) (string, []any) {
+	where := "status='pending' AND user_id=?"
+	args := []any{q.User}
+	if q.MinConfidence > 0 {
+		where += " AND confidence>=?"
+		args = append(args, q.MinConfidence)
+	}
+	if st := strings.TrimSpace(q.SourceType); st != "" {
+		where += " AND source_type=?"
+		args = append(args, st)
+	}
+	return where, args
+}
+
+// CountPendingFactsQuery counts the pending facts matching q's filters (the page total).
+func CountPendingFactsQuery(db *sql.DB, q PendingFactQuery) (int, error) {
+	if db == nil {
+		return 0, nil
+	}
+	where, args := pendingFactFilter(q)
+	var n int
+	err := readDB(db).QueryRow(`SELECT COUNT(1) FROM pending_facts WHERE `+where, args...).Scan(&n)
+	return n, err
+}
+
+// ListPendingFactsQuery lists pending facts with optional sort / min-confidence / source filters.
+// With q.AfterID it continues after that item (keyset on the sort columns + id).
+func ListPendingFactsQuery(db *sql.DB, q PendingFactQuery) ([]PendingFact, error) {
 	if db == nil {
 		return nil, nil
 	}
+	limit := q.Limit
 	if limit <= 0 {
 		limit = 50
 	}
 
-	rows, err := db.Query(`
-		SELECT id, fact, fact_key, confidence, source_type, source_key, status, created_at, updated_at
+	where, args := pendingFactFilter(q)
+	order := "created_at DESC, id DESC"
+	sort := normalizePendingSort(q.Sort)
+	switch sort {
+	case pendingSortConfidence:
+		order = "confidence DESC, created_at DESC, id DESC"
+	case pendingSortAge:
+		order = "created_at ASC, id ASC"
+	}
+	if q.AfterID > 0 {
+		var conf float64
+		var created string
+		err := readDB(db).QueryRow(`SELECT confidence, created_at FROM pending_facts WHERE id=?`, q.AfterID).Scan(&conf, &created)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errUnknownCursor
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch sort {
+		case pendingSortConfidence:
+			where += " AND (confidence<? OR (confidence=? AND (created_at<? OR (created_at=? AND id<?))))"
+			args = append(args, conf, conf, created, created, q.AfterID)
+		case pendingSortAge:
+			where += " AND (created_at>? OR (created_at=? AND id>?))"
+			args = append(args, created, created, q.AfterID)
+		default:
+			where += " AND (created_at<? OR (created_at=? AND id<?))"
+			args = append(args, created, created, q.AfterID)
+		}
+	}
+	args = append(args, limit)
+
+	rows, err := readDB(db).Query(`
+		SELECT id, fact, fact_key, confidence, source_type, source_key, COALESCE(sources,''), status, COALESCE(evidence,''), created_at, updated_at
 		FROM pending_facts
-		WHERE status='pending'
-		ORDER BY created_at DESC
+		WHERE `+where+`
+		ORDER BY `+order+`
 		LIMIT ?
-	`, limit)
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -303,9 +584,11 @@ func ListPendingFacts(db *sql.DB, limit int) ([]PendingFact, error) {
 	var out []PendingFact
 	for rows.Next() {
 		var pf PendingFact
-		if err := rows.Scan(&pf.ID, &pf.Fact, &pf.FactKey, &pf.Confidence, &pf.SourceType, &pf.SourceKey, &pf.Status, &pf.CreatedAt, &pf.UpdatedAt); err != nil {
+		var srcs string
+		if err := rows.Scan(&pf.ID, &pf.Fact, &pf.FactKey, &pf.Confidence, &pf.SourceType, &pf.SourceKey, &srcs, &pf.Status, &pf.Evidence, &pf.CreatedAt, &pf.UpdatedAt); err != nil {
 			continue
 		}
+		pf.Sources = decodePendingSources(srcs)
 		out = append(out, pf)
 	}
 	return out, nil
@@ -339,9 +622,57 @@ func RememberPendingFact(cfg Config, db *sql.DB, id int64) (*RememberOutcome, er
 	nowTime := time.Now().In(loc)
 
 	var out *RememberOutcome
-	var acceptedContent string
-	var acceptedSource string
+	var accepted *PendingFact
+
+	err := withDBRetry(3, 25*time.Millisecond, func() error {
+		return withTx(db, func(tx *sql.Tx) error {
+			o, pf, err := rememberPendingFactTx(cfg, tx, id, "pending", nowTime)
+			out, accepted = o, pf
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// best-effort: keep semantic search aligned (post-commit)
+	if out != nil && out.Status == "remembered" {
+		_ = syncFactToSearch(cfg, db, out.FactKey, strings.TrimSpace(accepted.Fact), accepted.SourceType)
+	}
+	return out, nil
+}
+
+// pendingSourceEdit marks an accept with edited text in user_facts_history:
+// the proposed wording gets status "edited", the remembered fact this source_type.
+const pendingSourceEdit = "pending_edit"
+
+var errPendingEditBlocked = errors.New("edited fact matches a fact policy rule or contains a secret; not stored")
+
+// RememberPendingFactEdited accepts pending fact id with editedText instead of
+// the proposed wording (a typo fix, a rephrase). The proposed text is kept in
+// user_facts_history; the pending row takes the edited text. An unchanged text
+// is a plain RememberPendingFact. An edit that a deny rule blocks or that
+// carries a secret is refused (errPendingEditBlocked) and the pending row is
+// left as it was.
+func RememberPendingFactEdited(cfg Config, db *sql.DB, id int64, editedText string) (*RememberOutcome, error) {
+	if db == nil {
+		return nil, nil
+	}
+	edited := normalizePendingFactText(sanitizeUTF8(editedText))
+	if edited == "" {
+		return nil, errors.New("edited fact is empty")
+	}
+	if factBlocked(cfg, db, edited, pendingSourceEdit, fmt.Sprintf("pending:%d", id)) {
+		return nil, errPendingEditBlocked
+	}
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	nowTime := time.Now().In(loc)
 
+	var out *RememberOutcome
+	var sourceType string
 	err := withDBRetry(3, 25*time.Millisecond, func() error {
 		return withTx(db, func(tx *sql.Tx) error {
 			pf, err := getPendingFactByID(tx, id)
@@ -351,22 +682,24 @@ func RememberPendingFact(cfg Config, db *sql.DB, id int64) (*RememberOutcome, er
 			if pf == nil || pf.Status != "pending" {
 				return fmt.Errorf("pending fact not found")
 			}
-
-			acceptedContent = strings.TrimSpace(pf.Fact)
-			acceptedSource = pf.SourceType
-
-			o, err := proposeRememberFactWith(cfg, tx, pf.Fact, "pending", pf.SourceKey, nowTime)
-			if err != nil {
-				return err
-			}
-			out = o
-
-			newStatus := "accepted"
-			if o != nil && o.Status == "conflict" {
-				newStatus = "conflict"
+			sourceType = "pending"
+			if original := strings.TrimSpace(pf.Fact); edited != original {
+				sourceType = pendingSourceEdit
+				user := keyUser(pf.FactKey)
+				origKey := userFactKey(user, deriveFactKeyFromSubject(original))
+				if err := appendUserFactHistory(tx, origKey, original, "edited", pendingSourceEdit, fmt.Sprintf("pending:%d", pf.ID), nowTime, 0); err != nil {
+					return err
+				}
+				key := userFactKey(user, deriveFactKeyFromSubject(edited))
+				if _, err := tx.Exec(`UPDATE pending_facts SET fact=?, fact_key=?, updated_at=? WHERE id=?`, edited, key, nowTime.Format(time.RFC3339), id); err != nil {
+					return err
+				}
+				// the vector was of the old text (pending_facts_group.go)
+				if _, err := tx.Exec(`DELETE FROM pending_fact_embeddings WHERE pending_fact_id=?`, id); err != nil {
+					return err
+				}
 			}
-			now := nowTime.Format(time.RFC3339)
-			_, err = tx.Exec(`UPDATE pending_facts SET status=?, updated_at=? WHERE id=?`, newStatus, now, id)
+			out, _, err = rememberPendingFactTx(cfg, tx, id, sourceType, nowTime)
 			return err
 		})
 	})
@@ -374,13 +707,48 @@ func RememberPendingFact(cfg Config, db *sql.DB, id int64) (*RememberOutcome, er
 		return nil, err
 	}
 
-	// best-effort: keep semantic search aligned (post-commit)
 	if out != nil && out.Status == "remembered" {
-		_ = syncFactToSearch(cfg, db, out.FactKey, acceptedContent, acceptedSource)
+		_ = syncFactToSearch(cfg, db, out.FactKey, edited, sourceType)
 	}
 	return out, nil
 }
 
+// rememberPendingFactTx accepts one pending fact inside tx and returns the
+// outcome plus the pending row (for the search sync after commit).
+// sourceType is recorded in user_facts_history ("pending" for a user accept).
+func rememberPendingFactTx(cfg Config, tx *sql.Tx, id int64, sourceType string, nowTime time.Time) (*RememberOutcome, *PendingFact, error) {
+	pf, err := getPendingFactByID(tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pf == nil || pf.Status != "pending" {
+		return nil, nil, fmt.Errorf("pending fact not found")
+	}
+
+	// accepted into the facts of whoever it was proposed for
+	cfg.User = keyUser(pf.FactKey)
+	o, err := proposeRememberFactWith(cfg, tx, pf.Fact, sourceType, pf.SourceKey, nowTime)
+	if err != nil {
+		return nil, pf, err
+	}
+
+	if o != nil && o.Status == "blocked" {
+		// a deny rule was added after it was proposed, or it carries a secret:
+		// drop it (not to the trash)
+		_, err = tx.Exec(`DELETE FROM pending_facts WHERE id=?`, id)
+		invalidatePendingGroups()
+		return o, pf, err
+	}
+	newStatus := "accepted"
+	if o != nil && o.Status == "conflict" {
+		newStatus = "conflict"
+	}
+	now := nowTime.Format(time.RFC3339)
+	_, err = tx.Exec(`UPDATE pending_facts SET status=?, updated_at=? WHERE id=?`, newStatus, now, id)
+	invalidatePendingGroups()
+	return o, pf, err
+}
+
 func RejectPendingFact(cfg Config, db *sql.DB, id int64) error {
 	if db == nil {
 		return nil
@@ -393,46 +761,91 @@ func RejectPendingFact(cfg Config, db *sql.DB, id int64) error {
 
 	return withDBRetry(3, 25*time.Millisecond, func() error {
 		return withTx(db, func(tx *sql.Tx) error {
-			pf, err := getPendingFactByID(tx, id)
-			if err != nil {
-				return err
-			}
-			if pf == nil || pf.Status != "pending" {
-				return fmt.Errorf("pending fact not found")
-			}
-			now := nowTime.Format(time.RFC3339)
-			if _, err := tx.Exec(`UPDATE pending_facts SET status='rejected', updated_at=? WHERE id=?`, now, id); err != nil {
-				return err
-			}
-
-			// Best-effort audit trail
-			factKey := deriveFactKeyFromSubject(pf.Fact)
-			_ = appendUserFactHistory(tx, factKey, strings.TrimSpace(pf.Fact), "rejected", "pending_reject", fmt.Sprintf("pending:%d", pf.ID), nowTime, 0)
-			return nil
+			return rejectPendingFactTx(tx, id, "pending_reject", nowTime)
 		})
 	})
 }
 
-// RememberPendingFactsBatch processes multiple pending ids.
+// rejectPendingFactTx moves one pending fact to the trash inside tx and
+// records sourceType in user_facts_history (best effort).
+func rejectPendingFactTx(tx *sql.Tx, id int64, sourceType string, nowTime time.Time) error {
+	pf, err := getPendingFactByID(tx, id)
+	if err != nil {
+		return err
+	}
+	if pf == nil || pf.Status != "pending" {
+		return fmt.Errorf("pending fact not found")
+	}
+	now := nowTime.Format(time.RFC3339)
+	if _, err := tx.Exec(`UPDATE pending_facts SET status='rejected', deleted_at=?, updated_at=? WHERE id=?`, now, now, id); err != nil {
+		return err
+	}
+	invalidatePendingGroups()
+
+	// Best-effort audit trail
+	factKey := userFactKey(keyUser(pf.FactKey), deriveFactKeyFromSubject(pf.Fact))
+	_ = appendUserFactHistory(tx, factKey, strings.TrimSpace(pf.Fact), "rejected", sourceType, fmt.Sprintf("pending:%d", pf.ID), nowTime, 0)
+	return nil
+}
+
+// RememberPendingFactsBatch accepts multiple pending ids in one transaction.
+// Each id runs in its own savepoint, so a failing item (status "error") does
+// not undo the others; the search rows of remembered facts are synced after
+// commit through the fact search queue (fact_search_queue.go).
 // Returns outcomes keyed by id.
 func RememberPendingFactsBatch(cfg Config, db *sql.DB, ids []int64) (map[int64]*RememberOutcome, error) {
 	out := make(map[int64]*RememberOutcome)
 	if db == nil || len(ids) == 0 {
 		return out, nil
 	}
-	// best-effort: process in order
-	for _, id := range ids {
-		if id <= 0 {
-			continue
-		}
-		o, err := RememberPendingFact(cfg, db, id)
-		if err != nil {
-			// keep going, but record nil for this id
-			out[id] = nil
-			continue
-		}
-		out[id] = o
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	nowTime := time.Now().In(loc)
+
+	var syncs []factSearchSync
+	err := withDBRetry(3, 25*time.Millisecond, func() error {
+		out = make(map[int64]*RememberOutcome)
+		syncs = syncs[:0]
+		return withTx(db, func(tx *sql.Tx) error {
+			// in order; a later id proposing the same slot sees the earlier one
+			for _, id := range ids {
+				if id <= 0 {
+					continue
+				}
+				if _, ok := out[id]; ok {
+					continue
+				}
+				if _, err := tx.Exec(`SAVEPOINT remember_item`); err != nil {
+					return err
+				}
+				o, pf, err := rememberPendingFactTx(cfg, tx, id, "pending", nowTime)
+				if err != nil {
+					if isSQLiteBusy(err) {
+						return err // whole batch retried
+					}
+					if _, rerr := tx.Exec(`ROLLBACK TO remember_item`); rerr != nil {
+						return rerr
+					}
+					o = &RememberOutcome{Status: "error", Error: err.Error()}
+				} else if o != nil && o.Status == "remembered" {
+					syncs = append(syncs, factSearchSync{key: o.FactKey, source: pf.SourceType})
+				}
+				if _, err := tx.Exec(`RELEASE remember_item`); err != nil {
+					return err
+				}
+				out[id] = o
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	// best-effort: keep semantic search aligned (post-commit, one worker pass)
+	queueFactSearchSync(syncs...)
 	return out, nil
 }
 
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Pending fact embeddings (background)
+// - ListPendingFactGroups used to embed missing vectors serially inside the
+//   HTTP request; with a big backlog that took tens of seconds.
+// - Now addPendingFact kicks this worker (and it sweeps every
+//   pendingEmbedSweepEvery as a safety net, e.g. for rows inserted in a tx
+//   that committed after the kick). Missing vectors are computed in
+//   batches (embedTextsBatched, see embedding_batch.go) and written back
+//   one at a time.
+// - The groups endpoint only reads stored vectors; an item without one is a
+//   singleton group until the worker catches up.
+// - A failed embed is retried after pendingEmbedRetryAfter (embed server down
+//   must not turn into a hot loop).
+// ============================================================
+
+const (
+	pendingEmbedBatch      = 64
+	pendingEmbedSweepEvery = time.Minute
+	pendingEmbedRetryAfter = 10 * time.Minute
+)
+
+var pendingEmbedKick = make(chan struct{}, 1)
+
+var pendingEmbedFailed struct {
+	sync.Mutex
+	at map[int64]time.Time
+}
+
+// kickPendingEmbeddings wakes the worker (non-blocking).
+func kickPendingEmbeddings() {
+	select {
+	case pendingEmbedKick <- struct{}{}:
+	default:
+	}
+}
+
+// runPendingEmbedWorker embeds missing pending fact vectors until ctx is cancelled.
+func runPendingEmbedWorker(ctx context.Context, cfg Config, db *sql.DB) {
+	if db == nil {
+		return
+	}
+	t := time.NewTicker(pendingEmbedSweepEvery)
+	defer t.Stop()
+	for {
+		done, failed := embedMissingPendingFacts(cfg, db)
+		if done > 0 || failed > 0 {
+			log.Printf("[info] pending fact embeddings: done=%d failed=%d", done, failed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-pendingEmbedKick:
+		case <-t.C:
+		}
+	}
+}
+
+// embedMissingPendingFacts embeds every pending fact without a vector (skipping
+// recent failures) and returns how many were stored / failed.
+func embedMissingPendingFacts(cfg Config, db *sql.DB) (done, failed int) {
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	for {
+		todo, err := missingPendingEmbeddings(db, pendingEmbedBatch)
+		if err != nil {
+			log.Printf("[warn] pending fact embeddings: %v", err)
+			return done, failed
+		}
+		if len(todo) == 0 {
+			return done, failed
+		}
+
+		texts := make([]string, len(todo))
+		for i, p := range todo {
+			texts[i] = p.Fact
+		}
+
+		// SQLite writes stay on this goroutine
+		progressed := false
+		embedTextsBatched(cfg, texts, func(i int, vec []float32, l2 float64, err error) {
+			id := todo[i].ID
+			if err != nil || len(vec) == 0 || l2 == 0 {
+				markPendingEmbedFailed(id)
+				failed++
+				return
+			}
+			if err := upsertPendingFactEmbedding(db, id, vec, l2, time.Now().In(loc).Format(time.RFC3339)); err != nil {
+				markPendingEmbedFailed(id)
+				failed++
+				return
+			}
+			pendingEmbedFailed.Lock()
+			delete(pendingEmbedFailed.at, id)
+			pendingEmbedFailed.Unlock()
+			done++
+			progressed = true
+		})
+		if !progressed || len(todo) < pendingEmbedBatch {
+			return done, failed
+		}
+	}
+}
+
+// missingPendingEmbeddings lists pending facts without a stored vector.
+func missingPendingEmbeddings(db *sql.DB, limit int) ([]PendingFact, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.fact FROM pending_facts p
+		LEFT JOIN pending_fact_embeddings e ON e.pending_fact_id = p.id
+		WHERE p.status='pending' AND p.deleted_at IS NULL AND e.pending_fact_id IS NULL
+		ORDER BY p.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	pendingEmbedFailed.Lock()
+	defer pendingEmbedFailed.Unlock()
+	var out []PendingFact
+	for rows.Next() {
+		var p PendingFact
+		if err := rows.Scan(&p.ID, &p.Fact); err != nil {
+			return nil, err
+		}
+		if at, ok := pendingEmbedFailed.at[p.ID]; ok && now.Sub(at) < pendingEmbedRetryAfter {
+			continue
+		}
+		out = append(out, p)
+		if len(out) >= limit {
+			break
+		}
+	}
+	return out, rows.Err()
+}
+
+func markPendingEmbedFailed(id int64) {
+	pendingEmbedFailed.Lock()
+	defer pendingEmbedFailed.Unlock()
+	if pendingEmbedFailed.at == nil {
+		pendingEmbedFailed.at = map[int64]time.Time{}
+	}
+	pendingEmbedFailed.at[id] = time.Now()
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// ============================================================
+// Pending facts expiry
+// - Pending facts nobody reviews used to stay in the pool forever. Two
+//   opt-in rules, applied at startup and on day change (next to the trash
+//   purge), oldest first:
+//     TIMELAYER_PENDING_AUTO_ACCEPT_DAYS  pending for N days with confidence
+//       >= TIMELAYER_PENDING_AUTO_ACCEPT_MIN_CONFIDENCE (default 0.95) →
+//       remembered as if accepted (conflicts and deny rules apply as usual)
+//     TIMELAYER_PENDING_EXPIRE_DAYS  pending for N days → rejected (to the
+//       trash, restorable for TIMELAYER_TRASH_DAYS)
+//   Auto-accept runs first, so a confident item is accepted even when the
+//   expiry is shorter — as long as it reaches the accept age first.
+// - Age is counted from created_at. Every item is audited in
+//   user_facts_history with source_type pending_auto_accept (the accepted
+//   fact keeps it too) or pending_expire.
+// ============================================================
+
+const (
+	defaultPendingAcceptConf = 0.95
+
+	pendingSourceAutoAccept = "pending_auto_accept"
+	pendingSourceExpire     = "pending_expire"
+)
+
+type PendingExpiryReport struct {
+	Accepted  int
+	Conflicts int
+	Rejected  int
+	Failed    int
+}
+
+// expirePendingFacts applies the auto-accept and expiry rules once.
+func expirePendingFacts(cfg Config, db *sql.DB) (PendingExpiryReport, error) {
+	var rep PendingExpiryReport
+	if db == nil || (cfg.PendingAcceptDays <= 0 && cfg.PendingExpireDays <= 0) {
+		return rep, nil
+	}
+	nowTime := time.Now().In(cfg.Location)
+
+	if cfg.PendingAcceptDays > 0 {
+		cutoff := nowTime.AddDate(0, 0, -cfg.PendingAcceptDays).Format(time.RFC3339)
+		ids, err := pendingFactIDsBefore(db, cutoff, cfg.PendingAcceptConf)
+		if err != nil {
+			return rep, err
+		}
+		var syncs []factSearchSync
+		for _, id := range ids {
+			var o *RememberOutcome
+			var pf *PendingFact
+			err := withDBRetry(3, 25*time.Millisecond, func() error {
+				return withTx(db, func(tx *sql.Tx) error {
+					var err error
+					o, pf, err = rememberPendingFactTx(cfg, tx, id, pendingSourceAutoAccept, nowTime)
+					return err
+				})
+			})
+			switch {
+			case err != nil:
+				rep.Failed++
+				log.Printf("[warn] pending auto-accept #%d failed: %v", id, err)
+			case o != nil && o.Status == "remembered":
+				rep.Accepted++
+				syncs = append(syncs, factSearchSync{key: o.FactKey, source: pf.SourceType})
+			case o != nil && o.Status == "conflict":
+				rep.Conflicts++
+			}
+		}
+		queueFactSearchSync(syncs...)
+	}
+
+	if cfg.PendingExpireDays > 0 {
+		cutoff := nowTime.AddDate(0, 0, -cfg.PendingExpireDays).Format(time.RFC3339)
+		ids, err := pendingFactIDsBefore(db, cutoff, 0)
+		if err != nil {
+			return rep, err
+		}
+		for _, id := range ids {
+			err := withDBRetry(3, 25*time.Millisecond, func() error {
+				return withTx(db, func(tx *sql.Tx) error {
+					return rejectPendingFactTx(tx, id, pendingSourceExpire, nowTime)
+				})
+			})
+			if err != nil {
+				rep.Failed++
+				log.Printf("[warn] pending expiry #%d failed: %v", id, err)
+				continue
+			}
+			rep.Rejected++
+		}
+	}
+	return rep, nil
+}
+
+// pendingFactIDsBefore lists pending ids created before cutoff with at least minConf, oldest first.
+func pendingFactIDsBefore(db *sql.DB, cutoff string, minConf float64) ([]int64, error) {
+	rows, err := db.Query(`
+		SELECT id FROM pending_facts
+		WHERE status='pending' AND deleted_at IS NULL AND created_at < ? AND confidence >= ?
+		ORDER BY created_at, id
+	`, cutoff, minConf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func runPendingExpiry(cfg Config, db *sql.DB) {
+	rep, err := expirePendingFacts(cfg, db)
+	if err != nil {
+		log.Printf("[warn] pending expiry failed: %v", err)
+		return
+	}
+	if rep.Accepted > 0 || rep.Conflicts > 0 || rep.Rejected > 0 || rep.Failed > 0 {
+		log.Printf("[info] pending expiry: accepted=%d conflicts=%d rejected=%d failed=%d",
+			rep.Accepted, rep.Conflicts, rep.Rejected, rep.Failed)
+	}
+}
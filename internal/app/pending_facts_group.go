@@ -7,14 +7,15 @@ import (
 	"math"
 	"sort"
 	"strings"
-	"time"
 )
 
 type PendingFactGroup struct {
-	GroupID string        `json:"group_id"`
-	Rep     PendingFact   `json:"rep"`
-	Items   []PendingFact `json:"items"`
-	Size    int           `json:"size"`
+	GroupID     string            `json:"group_id"`
+	Rep         PendingFact       `json:"rep"`
+	RepStrategy string            `json:"rep_strategy"` // see pending_facts_group_rep.go
+	Items       []PendingFact     `json:"items"`
+	Size        int               `json:"size"`
+	CentroidSim map[int64]float64 `json:"centroid_sim,omitempty"` // member id → cosine to the group centroid
 }
 
 const pendingClusterThreshold = 0.88
@@ -46,6 +47,7 @@ func upsertPendingFactEmbedding(db *sql.DB, pendingFactID int64, vec []float32,
         INSERT INTO pending_fact_embeddings(pending_fact_id, dim, vec, l2, created_at)
         VALUES(?,?,?,?,?)
     `, pendingFactID, len(vec), buf.Bytes(), l2, createdAt)
+	invalidatePendingGroups()
 	return err
 }
 
@@ -78,9 +80,11 @@ type pendingVec struct {
 }
 
 // ListPendingFactGroups returns pending facts grouped by semantic similarity.
-// Best-effort: if embedding fails, that item becomes a singleton group.
-func ListPendingFactGroups(cfg Config, db *sql.DB, limit int) ([]PendingFactGroup, error) {
-	items, err := ListPendingFacts(db, limit)
+// Only stored vectors are used (pending_facts_embed.go computes them in the
+// background); an item without one becomes a singleton group.
+// q filters the items first; q.Sort orders the groups (newest = size desc).
+func ListPendingFactGroups(cfg Config, db *sql.DB, q PendingFactQuery) ([]PendingFactGroup, error) {
+	items, err := ListPendingFactsQuery(db, q)
 	if err != nil {
 		return nil, err
 	}
@@ -96,12 +100,17 @@ func ListPendingFactGroups(cfg Config, db *sql.DB, limit int) ([]PendingFactGrou
 		return items[i].CreatedAt > items[j].CreatedAt
 	})
 
-	vecs := make(map[int64]pendingVec, len(items))
-	loc := cfg.Location
-	if loc == nil {
-		loc = time.Local
+	sortMode := normalizePendingSort(q.Sort)
+	repMode := normalizePendingRep(q.Rep)
+	key := pendingGroupsCacheKey(items, sortMode+"|"+repMode)
+	if !q.Refresh {
+		if shapes, ok := cachedPendingGroups(key); ok {
+			return buildPendingGroups(items, shapes, repMode), nil
+		}
 	}
-	now := time.Now().In(loc).Format(time.RFC3339)
+
+	vecs := make(map[int64]pendingVec, len(items))
+	missing := 0
 
 	ensureVec := func(p PendingFact) pendingVec {
 		if pv, ok := vecs[p.ID]; ok {
@@ -113,14 +122,7 @@ func ListPendingFactGroups(cfg Config, db *sql.DB, limit int) ([]PendingFactGrou
 			vecs[p.ID] = pv
 			return pv
 		}
-		// compute embedding (best-effort)
-		v, l2n, err := embedQueryText(cfg, p.Fact)
-		if err == nil && len(v) > 0 && l2n > 0 {
-			_ = upsertPendingFactEmbedding(db, p.ID, v, l2n, now)
-			pv := pendingVec{v: v, l2: l2n}
-			vecs[p.ID] = pv
-			return pv
-		}
+		missing++
 		pv := pendingVec{v: nil, l2: 0}
 		vecs[p.ID] = pv
 		return pv
@@ -178,29 +180,82 @@ func ListPendingFactGroups(cfg Config, db *sql.DB, limit int) ([]PendingFactGrou
 		}
 	}
 
+	if missing > 0 {
+		kickPendingEmbeddings()
+	}
+
 	// sort groups: size desc then rep confidence desc
+	// (confidence: rep confidence desc; age: oldest member first)
+	oldest := func(g grp) string {
+		o := g.items[0].CreatedAt
+		for _, it := range g.items[1:] {
+			if it.CreatedAt < o {
+				o = it.CreatedAt
+			}
+		}
+		return o
+	}
 	sort.SliceStable(groups, func(i, j int) bool {
+		switch sortMode {
+		case pendingSortConfidence:
+			if groups[i].rep.Confidence != groups[j].rep.Confidence {
+				return groups[i].rep.Confidence > groups[j].rep.Confidence
+			}
+		case pendingSortAge:
+			if oi, oj := oldest(groups[i]), oldest(groups[j]); oi != oj {
+				return oi < oj
+			}
+		}
 		if len(groups[i].items) != len(groups[j].items) {
 			return len(groups[i].items) > len(groups[j].items)
 		}
 		return groups[i].rep.Confidence > groups[j].rep.Confidence
 	})
 
-	out := make([]PendingFactGroup, 0, len(groups))
+	shapes := make([]pendingGroupShape, 0, len(groups))
 	for _, g := range groups {
+		sh := pendingGroupShape{id: g.id, sims: pendingCentroidSims(g.items, vecs)}
+		sh.rep = pickPendingRep(repMode, g.items, g.rep.ID, sh.sims)
+		for _, it := range g.items {
+			sh.items = append(sh.items, it.ID)
+		}
+		shapes = append(shapes, sh)
+	}
+	if repMode == pendingRepLLM {
+		byID := make(map[int64]PendingFact, len(items))
+		for _, it := range items {
+			byID[it.ID] = it
+		}
+		pickPendingRepsLLM(cfg, db, shapes, byID)
+	}
+	// only cache a complete clustering (missing vectors are on their way)
+	if missing == 0 {
+		storePendingGroups(key, shapes)
+	}
+	return buildPendingGroups(items, shapes, repMode), nil
+}
+
+// buildPendingGroups materializes group shapes with the current item rows.
+func buildPendingGroups(items []PendingFact, shapes []pendingGroupShape, repMode string) []PendingFactGroup {
+	byID := make(map[int64]PendingFact, len(items))
+	for _, it := range items {
+		byID[it.ID] = it
+	}
+	out := make([]PendingFactGroup, 0, len(shapes))
+	for _, sh := range shapes {
+		g := PendingFactGroup{GroupID: sh.id, Rep: byID[sh.rep], RepStrategy: repMode, CentroidSim: sh.sims}
+		for _, id := range sh.items {
+			g.Items = append(g.Items, byID[id])
+		}
 		// stable sort items by confidence desc
-		sort.SliceStable(g.items, func(i, j int) bool {
-			if g.items[i].Confidence != g.items[j].Confidence {
-				return g.items[i].Confidence > g.items[j].Confidence
+		sort.SliceStable(g.Items, func(i, j int) bool {
+			if g.Items[i].Confidence != g.Items[j].Confidence {
+				return g.Items[i].Confidence > g.Items[j].Confidence
 			}
-			return strings.Compare(g.items[i].Fact, g.items[j].Fact) < 0
-		})
-		out = append(out, PendingFactGroup{
-			GroupID: g.id,
-			Rep:     g.rep,
-			Items:   g.items,
-			Size:    len(g.items),
+			return strings.Compare(g.Items[i].Fact, g.Items[j].Fact) < 0
 		})
+		g.Size = len(g.Items)
+		out = append(out, g)
 	}
-	return out, nil
+	return out
 }
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ============================================================
+// Pending groups cache
+// Clustering is O(n²) cosine comparisons, so /api/facts/pending/groups keeps
+// the last clusterings (group ids + member ids, not the rows). The key covers
+// the filtered items (id, confidence, fact_key, in order), the sort and
+// representative modes and
+// pendingGroupsVersion, which pending mutations and stored embeddings bump.
+// Rows are re-read on every call, so a hit never serves stale text.
+// ?refresh=1 recomputes.
+// ============================================================
+
+const pendingGroupsCacheSize = 8 // distinct filter / sort combinations
+
+var pendingGroupsVersion atomic.Uint64
+
+type pendingGroupShape struct {
+	id    string
+	rep   int64
+	items []int64
+	sims  map[int64]float64 // centroid similarity per member
+}
+
+var pendingGroupsCache struct {
+	sync.Mutex
+	order   []string
+	entries map[string][]pendingGroupShape
+}
+
+// invalidatePendingGroups drops every cached clustering (cheap; call on any pending mutation).
+func invalidatePendingGroups() {
+	pendingGroupsVersion.Add(1)
+}
+
+func pendingGroupsCacheKey(items []PendingFact, sortMode string) string {
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatUint(pendingGroupsVersion.Load(), 10) + "|" + sortMode))
+	for _, it := range items {
+		h.Write([]byte("|" + strconv.FormatInt(it.ID, 10) + ":" + strconv.FormatFloat(it.Confidence, 'g', -1, 64) + ":" + it.FactKey))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func cachedPendingGroups(key string) ([]pendingGroupShape, bool) {
+	pendingGroupsCache.Lock()
+	defer pendingGroupsCache.Unlock()
+	shapes, ok := pendingGroupsCache.entries[key]
+	return shapes, ok
+}
+
+func storePendingGroups(key string, shapes []pendingGroupShape) {
+	pendingGroupsCache.Lock()
+	defer pendingGroupsCache.Unlock()
+	if pendingGroupsCache.entries == nil {
+		pendingGroupsCache.entries = map[string][]pendingGroupShape{}
+	}
+	if _, ok := pendingGroupsCache.entries[key]; !ok {
+		pendingGroupsCache.order = append(pendingGroupsCache.order, key)
+	}
+	pendingGroupsCache.entries[key] = shapes
+	for len(pendingGroupsCache.order) > pendingGroupsCacheSize {
+		delete(pendingGroupsCache.entries, pendingGroupsCache.order[0])
+		pendingGroupsCache.order = pendingGroupsCache.order[1:]
+	}
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ============================================================
+// Pending group representatives
+// Clustering always seeds on the highest-confidence item; the representative
+// shown for a group is then picked by ?rep=:
+//   confidence (default) highest confidence (the seed)
+//   longest              most characters (usually the most complete wording)
+//   central              closest to the group centroid (cosine)
+//   llm                  the chat model picks the clearest wording among the
+//                        members; only the pendingRepLLMMaxGroups largest
+//                        groups, counted against the background LLM budget,
+//                        falls back to central on any error
+// Every group also returns centroid_sim (member id → cosine to the centroid).
+// ============================================================
+
+const (
+	pendingRepConfidence = "confidence"
+	pendingRepLongest    = "longest"
+	pendingRepCentral    = "central"
+	pendingRepLLM        = "llm"
+
+	pendingRepLLMMaxGroups = 5
+)
+
+var reFirstNumber = regexp.MustCompile(`\d+`)
+
+func normalizePendingRep(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case pendingRepLongest, "long":
+		return pendingRepLongest
+	case pendingRepCentral, "centroid":
+		return pendingRepCentral
+	case pendingRepLLM:
+		return pendingRepLLM
+	default:
+		return pendingRepConfidence
+	}
+}
+
+// pendingCentroidSims returns member id → cosine to the mean of the members'
+// vectors (members without a vector are left out).
+func pendingCentroidSims(items []PendingFact, vecs map[int64]pendingVec) map[int64]float64 {
+	var centroid []float32
+	n := 0
+	for _, it := range items {
+		pv := vecs[it.ID]
+		if len(pv.v) == 0 || (centroid != nil && len(pv.v) != len(centroid)) {
+			continue
+		}
+		if centroid == nil {
+			centroid = make([]float32, len(pv.v))
+		}
+		for i, x := range pv.v {
+			centroid[i] += x / float32(pv.l2) // unit vectors: every member weighs the same
+		}
+		n++
+	}
+	out := map[int64]float64{}
+	if n == 0 {
+		return out
+	}
+	var sq float64
+	for _, x := range centroid {
+		sq += float64(x) * float64(x)
+	}
+	cl2 := math.Sqrt(sq)
+	for _, it := range items {
+		pv := vecs[it.ID]
+		if len(pv.v) == len(centroid) {
+			out[it.ID] = math.Round(cosine(pv.v, pv.l2, centroid, cl2)*1000) / 1000
+		}
+	}
+	return out
+}
+
+// pickPendingRep returns the representative id for strategy (llm is handled
+// by pickPendingRepsLLM; here it means central).
+func pickPendingRep(strategy string, items []PendingFact, seed int64, sims map[int64]float64) int64 {
+	best := seed
+	switch strategy {
+	case pendingRepLongest:
+		bestLen := -1
+		for _, it := range items { // items are in confidence order: ties keep the more confident
+			if n := len([]rune(strings.TrimSpace(it.Fact))); n > bestLen {
+				best, bestLen = it.ID, n
+			}
+		}
+	case pendingRepCentral, pendingRepLLM:
+		bestSim := -2.0
+		for _, it := range items {
+			if s, ok := sims[it.ID]; ok && s > bestSim {
+				best, bestSim = it.ID, s
+			}
+		}
+	}
+	return best
+}
+
+func buildPendingRepPrompt(items []PendingFact) string {
+	var b strings.Builder
+	b.WriteString("The numbered candidate memory entries below all state the same fact about the user.\n")
+	b.WriteString("Pick the ONE whose wording is the clearest, most complete and self-contained.\n")
+	b.WriteString("Reply with its number only.\n\n")
+	for i, it := range items {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, strings.TrimSpace(it.Fact))
+	}
+	return b.String()
+}
+
+// pickPendingRepsLLM asks the model for the first pendingRepLLMMaxGroups
+// multi-item groups (concurrently); groups it can't answer keep their rep.
+func pickPendingRepsLLM(cfg Config, db *sql.DB, shapes []pendingGroupShape, byID map[int64]PendingFact) {
+	var wg sync.WaitGroup
+	asked := 0
+	for gi := range shapes {
+		if len(shapes[gi].items) < 2 || asked >= pendingRepLLMMaxGroups {
+			continue
+		}
+		asked++
+		wg.Add(1)
+		go func(sh *pendingGroupShape) {
+			defer wg.Done()
+			items := make([]PendingFact, 0, len(sh.items))
+			for _, id := range sh.items {
+				items = append(items, byID[id])
+			}
+			out, err := callBackgroundLLM(cfg, db, buildPendingRepPrompt(items))
+			if err != nil {
+				log.Printf("[warn] pending group %s: llm representative failed: %v", sh.id, err)
+				return
+			}
+			n, err := strconv.Atoi(reFirstNumber.FindString(out))
+			if err != nil || n < 1 || n > len(items) {
+				log.Printf("[warn] pending group %s: llm representative unparsable: %q", sh.id, clipRunes(out, 40))
+				return
+			}
+			sh.rep = items[n-1].ID
+		}(&shapes[gi])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"local-ai-cli/internal/fakellama"
+)
+
+// End-to-end pipeline against the scripted fake llama-server
+// (internal/fakellama) in a t.TempDir() base dir: chat stream → dialog log →
+// daily → weekly → search, plus facts and the failure paths (malformed
+// summary JSON, broken rerank, chat timeout, a stream cut before [DONE]).
+// The subtests run in order on one DB; a new regression check is one more
+// subtest that scripts the fake replies it needs and runs the real code path.
+//
+//	go test -run TestPipeline -v ./internal/app
+
+const pipelineToken = "蓝色潜水艇"
+
+type pipelineEnv struct {
+	cfg   Config
+	db    *sql.DB
+	lw    *LogWriter
+	fake  *fakellama.Server
+	today string
+}
+
+// pipelineConfig points a config at dir and at the fake server.
+func pipelineConfig(dir string, fake *fakellama.Server) Config {
+	cfg := defaultConfig()
+	cfg.BaseDir = dir
+	cfg.LogDir = filepath.Join(dir, "logs")
+	cfg.ArchiveDir = filepath.Join(dir, "logs", "archive")
+	cfg.PromptDir = filepath.Join(dir, "prompts")
+	cfg.DBPath = filepath.Join(dir, "memory", "memory.sqlite")
+
+	cfg.ChatURL, cfg.EmbedURL, cfg.RerankURL = fake.ChatURL(), fake.EmbedURL(), fake.RerankURL()
+	cfg.HTTPTimeout = 5 * time.Second
+	cfg.RerankTimeout = 5 * time.Second
+	cfg.ContextProbe = false
+	cfg.EnableRerank, cfg.RerankMode = true, "always"
+	cfg.SearchMinScore = 0.3 // bag-of-characters vectors are less peaked than a real model's
+	cfg.Summarizer = summarizerAuto
+	cfg.NotifyURL = ""
+	cfg.ErrorReportFile, cfg.ErrorReportWebhook, cfg.ErrorReportSentryDSN = "", "", ""
+	cfg.BackgroundLLMDailyCalls, cfg.BackgroundLLMDailyTokens = 0, 0
+	return cfg
+}
+
+func newPipelineEnv(t *testing.T) *pipelineEnv {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	fake := fakellama.New()
+	t.Cleanup(fake.Close)
+
+	cfg := pipelineConfig(t.TempDir(), fake)
+	mustEnsureDirs(cfg)
+	mustEnsurePromptFiles(cfg)
+	db := mustOpenDB(cfg)
+	lw := NewLogWriter(cfg, db)
+	t.Cleanup(func() {
+		lw.Close()
+		closeDB(db)
+	})
+	return &pipelineEnv{cfg: cfg, db: db, lw: lw, fake: fake, today: time.Now().In(cfg.Location).Format("2006-01-02")}
+}
+
+func TestPipeline(t *testing.T) {
+	e := newPipelineEnv(t)
+	steps := []struct {
+		name string
+		run  func(t *testing.T, e *pipelineEnv)
+	}{
+		{"chat_stream", testPipelineChatStream},
+		{"remember_conflict", testPipelineRememberConflict},
+		{"daily_malformed", testPipelineDailyMalformed},
+		{"daily", testPipelineDaily},
+		{"weekly", testPipelineWeekly},
+		{"search", testPipelineSearch},
+		{"rerank_malformed", testPipelineRerankMalformed},
+		{"chat_timeout", testPipelineChatTimeout},
+		{"chat_stream_cut", testPipelineChatStreamCut},
+	}
+	for _, st := range steps {
+		if !t.Run(st.name, func(t *testing.T) { st.run(t, e) }) {
+			t.FailNow() // later steps build on this one
+		}
+		e.fake.Reset()
+	}
+}
+
+func testPipelineChatStream(t *testing.T, e *pipelineEnv) {
+	chunks := []string{"好的，", "新项目就叫", pipelineToken + "。"}
+	e.fake.Once(fakellama.Chat, pipelineToken, fakellama.Reply{Chunks: chunks})
+
+	var deltas []string
+	ans, turnID, err := ChatTurnWithContext(context.Background(), e.lw, e.cfg, e.db,
+		"我给新项目起名叫"+pipelineToken+"，记一下", false, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if turnID == "" {
+		t.Error("empty turn id")
+	}
+	if want := strings.Join(chunks, ""); ans != want || len(deltas) != len(chunks) {
+		t.Fatalf("answer %q in %d deltas, want %q in %d", ans, len(deltas), want, len(chunks))
+	}
+	raw, err := readRawDay(e.cfg, e.db, e.today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(raw), pipelineToken); n < 2 {
+		t.Fatalf("dialog log has %d lines with the token, want user + assistant", n)
+	}
+}
+
+func testPipelineRememberConflict(t *testing.T, e *pipelineEnv) {
+	now := time.Now().In(e.cfg.Location)
+	o, err := ProposeRememberFact(e.cfg, e.db, "我的生日是5月3日", "", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != "remembered" {
+		t.Fatalf("first remember: status %s", o.Status)
+	}
+	o, err = ProposeRememberFact(e.cfg, e.db, "我的生日是05-04", "", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != "conflict" || o.Conflict == nil {
+		t.Fatalf("second remember: status %s, want conflict", o.Status)
+	}
+	if o.Conflict.ExistingValue != "05-03" || o.Conflict.NewValue != "05-04" {
+		t.Fatalf("conflict values %s / %s, want 05-03 / 05-04", o.Conflict.ExistingValue, o.Conflict.NewValue)
+	}
+}
+
+func testPipelineDailyMalformed(t *testing.T, e *pipelineEnv) {
+	e.fake.On(fakellama.Chat, "conversation log summarizer", fakellama.Reply{Body: "{ not json"})
+	if err := ensureDaily(e.cfg, e.db, e.today, true); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := summaryExists(e.db, "daily", e.today); !ok {
+		t.Fatal("no daily after a malformed llm reply")
+	}
+	if !summaryDegraded(e.db, "daily", e.today) {
+		t.Fatal("daily from the extractive fallback is not marked degraded")
+	}
+}
+
+func testPipelineDaily(t *testing.T, e *pipelineEnv) {
+	js, _ := json.Marshal(map[string]any{
+		"type":       "daily",
+		"date":       e.today,
+		"topics":     []string{"给新项目命名为" + pipelineToken},
+		"highlights": []string{"确定了项目名称" + pipelineToken},
+	})
+	e.fake.On(fakellama.Chat, "conversation log summarizer", fakellama.Reply{Body: string(js)})
+	if err := ensureDaily(e.cfg, e.db, e.today, true); err != nil {
+		t.Fatal(err)
+	}
+	checkPipelineSummary(t, e, "daily", e.today)
+}
+
+func testPipelineWeekly(t *testing.T, e *pipelineEnv) {
+	y, w := time.Now().In(e.cfg.Location).ISOWeek()
+	weekKey := fmt.Sprintf("%04d-W%02d", y, w)
+	js, _ := json.Marshal(map[string]any{
+		"type":     "weekly",
+		"themes":   []string{"新项目" + pipelineToken + "的命名"},
+		"progress": []string{"项目名称定为" + pipelineToken},
+	})
+	e.fake.On(fakellama.Chat, "strict summarizer", fakellama.Reply{Body: string(js)})
+	if err := ensureWeekly(e.cfg, e.db, weekKey, true); err != nil {
+		t.Fatal(err)
+	}
+	checkPipelineSummary(t, e, "weekly", weekKey)
+}
+
+// checkPipelineSummary checks that a summary exists, is not degraded and is embedded.
+func checkPipelineSummary(t *testing.T, e *pipelineEnv, typ, key string) {
+	t.Helper()
+	var id int64
+	var text string
+	if err := e.db.QueryRow(`SELECT id, COALESCE(text,'') FROM summaries WHERE type=? AND period_key=?`, typ, key).Scan(&id, &text); err != nil {
+		t.Fatalf("%s %s: %v", typ, key, err)
+	}
+	if summaryDegraded(e.db, typ, key) {
+		t.Errorf("%s %s is degraded", typ, key)
+	}
+	if !strings.Contains(text, pipelineToken) {
+		t.Errorf("%s %s index text misses the token: %q", typ, key, text)
+	}
+	if !hasEmbedding(e.db, id) {
+		t.Errorf("%s %s has no embedding", typ, key)
+	}
+}
+
+func testPipelineSearch(t *testing.T, e *pipelineEnv) {
+	hits, err := SearchWithScore(e.db, e.cfg, "新项目叫什么名字 "+pipelineToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]bool{}
+	for _, h := range hits {
+		types[h.Type] = true
+	}
+	if !types["daily"] || !types["weekly"] {
+		t.Errorf("hits %v, want daily and weekly", types)
+	}
+	if len(e.fake.Requests(fakellama.Rerank)) == 0 {
+		t.Error("rerank was not called")
+	}
+}
+
+func testPipelineRerankMalformed(t *testing.T, e *pipelineEnv) {
+	e.fake.On(fakellama.Rerank, "", fakellama.Reply{Body: "{oops", Raw: true})
+	hits, err := SearchWithScore(e.db, e.cfg, "项目名称 "+pipelineToken+" 再查一次")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) == 0 {
+		t.Fatal("no hits when rerank is broken, want the embedding order")
+	}
+}
+
+func testPipelineChatTimeout(t *testing.T, e *pipelineEnv) {
+	cfg := e.cfg
+	cfg.HTTPTimeout = 300 * time.Millisecond
+	e.fake.On(fakellama.Chat, "超时测试", fakellama.Reply{Body: "太晚了", Delay: 2 * time.Second})
+	start := time.Now()
+	_, _, err := ChatTurnWithContext(context.Background(), e.lw, cfg, e.db, "超时测试：请回答", false, nil)
+	if err == nil {
+		t.Fatal("no error from a chat server slower than HTTPTimeout")
+	}
+	if d := time.Since(start); d > 1500*time.Millisecond {
+		t.Fatalf("timeout took %s", d)
+	}
+}
+
+func testPipelineChatStreamCut(t *testing.T, e *pipelineEnv) {
+	e.fake.On(fakellama.Chat, "断流测试", fakellama.Reply{Chunks: []string{"前半句，", "后半句"}, Raw: true})
+	ans, _, err := ChatTurnWithContext(context.Background(), e.lw, e.cfg, e.db, "断流测试：请回答", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ans != "前半句，后半句" {
+		t.Fatalf("answer %q from a stream without [DONE]", ans)
+	}
+}
@@ -0,0 +1,365 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Prompt token budget
+// - The prompt (system + context blocks + user input) must fit
+//   TIMELAYER_MAX_PROMPT_TOKENS; unset, 90% of the model context
+//   (MaxContextTokens, probed or TIMELAYER_MAX_CONTEXT_TOKENS), which leaves
+//   room for the reply. No limit known: nothing is cut.
+// - Tokens are counted by TIMELAYER_PROMPT_TOKENIZER:
+//     heuristic  chars per token (TIMELAYER_CHUNK_CHARS_PER_TOKEN), no I/O
+//     server     the chat server's llama.cpp POST /tokenize, one call per
+//                block; falls back to the heuristic for the turn on error
+// - Over the limit, blocks are cut lowest Priority first: a list-shaped
+//   block loses whole items (recent_raw its oldest messages, the others
+//   their last = lowest-ranked items) down to one item; only then is it
+//   dropped. JSON blocks (today's daily) and remembered facts are kept
+//   whole or dropped whole.
+// - Every decision lands in the block's trace (tokens, truncation note,
+//   dropped) and in PromptBudget, shown by /api/context/audit and /preview.
+// ============================================================
+
+const (
+	promptTokenizerHeuristic = "heuristic"
+	promptTokenizerServer    = "server"
+
+	promptMsgOverhead = 4 // per context message (role + separators)
+	tokenizeTimeout   = 2 * time.Second
+)
+
+// PromptBudget is the token accounting of one prompt.
+type PromptBudget struct {
+	Limit     int                    `json:"limit"` // 0 = unknown, nothing cut
+	Tokenizer string                 `json:"tokenizer"`
+	System    int                    `json:"system"`
+	Input     int                    `json:"input"`
+	Context   int                    `json:"context"`
+	Total     int                    `json:"total"`
+	Before    int                    `json:"before"` // total before cutting
+	Blocks    []PromptBudgetDecision `json:"blocks"`
+	Note      string                 `json:"note,omitempty"`
+}
+
+// PromptBudgetDecision is what the budget did to one block (in prompt order).
+type PromptBudgetDecision struct {
+	Source   string `json:"source"`
+	Priority int    `json:"priority"`
+	Before   int    `json:"before"`        // tokens as built
+	Tokens   int    `json:"tokens"`        // tokens sent (0 = dropped)
+	Action   string `json:"action"`        // kept | trimmed | dropped
+	Cut      int    `json:"cut,omitempty"` // items cut when trimmed
+}
+
+// promptTokenLimit is the prompt budget in tokens (0 = unknown).
+func promptTokenLimit(cfg Config) int {
+	if cfg.MaxPromptTokens > 0 {
+		return cfg.MaxPromptTokens
+	}
+	return int(contextFitRatio * float64(cfg.MaxContextTokens))
+}
+
+// promptTokenCounter counts with the configured tokenizer; the returned
+// name says which one was used (the server one may have fallen back).
+type promptTokenCounter struct {
+	h      TokenHeuristics
+	server string // tokenize base URL ("" = heuristic)
+	client *http.Client
+	failed error
+}
+
+func newPromptTokenCounter(cfg Config) *promptTokenCounter {
+	c := &promptTokenCounter{h: cfg.ChunkCharsPerToken}
+	if c.h.ASCII <= 0 || c.h.CJK <= 0 || c.h.Other <= 0 {
+		c.h = defaultTokenHeuristics()
+	}
+	if cfg.PromptTokenizer == promptTokenizerServer {
+		if base, err := chatServerBase(cfg.ChatURL); err == nil {
+			c.server = base
+			c.client = &http.Client{Timeout: tokenizeTimeout}
+		}
+	}
+	return c
+}
+
+func (c *promptTokenCounter) Name() string {
+	switch {
+	case c.server == "":
+		return promptTokenizerHeuristic
+	case c.failed != nil:
+		return promptTokenizerHeuristic + " (server failed)"
+	}
+	return promptTokenizerServer
+}
+
+// Count counts s; the first server error switches to the heuristic for the rest of the turn.
+func (c *promptTokenCounter) Count(s string) int {
+	if c.server != "" && c.failed == nil {
+		n, err := tokenizeRemote(c.client, c.server, s)
+		if err == nil {
+			return n
+		}
+		c.failed = err
+		warnTokenizeFailure(err)
+	}
+	return c.h.Estimate(s)
+}
+
+// Estimate is the heuristic count, used to split a block into items.
+func (c *promptTokenCounter) Estimate(s string) int { return c.h.Estimate(s) }
+
+var tokenizeWarnOnce sync.Once
+
+func warnTokenizeFailure(err error) {
+	tokenizeWarnOnce.Do(func() {
+		log.Printf("[warn] /tokenize failed, counting prompt tokens heuristically: %v", err)
+	})
+}
+
+// tokenizeRemote counts s with llama.cpp's POST /tokenize.
+func tokenizeRemote(client *http.Client, base, s string) (int, error) {
+	body, _ := json.Marshal(map[string]any{"content": s, "add_special": false})
+	resp, err := client.Post(base+"/tokenize", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("/tokenize: http %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return 0, err
+	}
+	var out struct {
+		Tokens []json.RawMessage `json:"tokens"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return 0, err
+	}
+	if out.Tokens == nil {
+		return 0, fmt.Errorf("/tokenize: no tokens in response")
+	}
+	return len(out.Tokens), nil
+}
+
+// promptBlockMessage is the context message of a block.
+func promptBlockMessage(b PromptBlock) string {
+	return "【" + b.Source + "】\n" + b.Content
+}
+
+// fitPromptBudget builds the context messages of blocks, cutting blocks until
+// the prompt fits the budget. Cut blocks are rewritten in place (trimmed
+// Content, Trace.Dropped / Truncation / Tokens) so audits show what was sent.
+func fitPromptBudget(cfg Config, system string, blocks []PromptBlock, userInput string) ([]map[string]string, PromptBudget) {
+	tc := newPromptTokenCounter(cfg)
+	rep := PromptBudget{Limit: promptTokenLimit(cfg)}
+	rep.System = tc.Count(system)
+	rep.Input = tc.Count(userInput)
+
+	var idx []int // non-empty blocks, in prompt order
+	tokens := map[int]int{}
+	for i, b := range blocks {
+		if strings.TrimSpace(b.Content) == "" {
+			continue
+		}
+		idx = append(idx, i)
+		tokens[i] = tc.Count(promptBlockMessage(b)) + promptMsgOverhead
+		rep.Context += tokens[i]
+	}
+	rep.Before = rep.System + rep.Input + rep.Context
+	before := map[int]int{}
+	for i, n := range tokens {
+		before[i] = n
+	}
+
+	if rep.Limit > 0 && cfg.MaxContextTokens > 0 && float64(rep.Before) >= contextWarnRatio*float64(cfg.MaxContextTokens) {
+		log.Printf("[warn] prompt ~%d tokens (%d%% of model context %d)", rep.Before, rep.Before*100/cfg.MaxContextTokens, cfg.MaxContextTokens)
+	}
+
+	// lowest priority first; on ties the later block (prompt order) goes first
+	order := append([]int(nil), idx...)
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, pb := blockPriority(blocks[order[a]]), blockPriority(blocks[order[b]])
+		if pa != pb {
+			return pa < pb
+		}
+		return order[a] > order[b]
+	})
+
+	dropped := map[int]bool{}
+	cut := map[int]int{}
+	var droppedSrc []string
+	total := rep.Before
+	for _, i := range order {
+		if rep.Limit <= 0 || total <= rep.Limit {
+			break
+		}
+		over := total - rep.Limit
+		if content, n, ok := trimPromptBlock(tc, blocks[i], tokens[i], over); ok {
+			blocks[i].Content = content
+			nt := tc.Count(promptBlockMessage(blocks[i])) + promptMsgOverhead
+			total -= tokens[i] - nt
+			tokens[i] = nt
+			cut[i] = n
+			if t := blocks[i].Trace; t != nil {
+				t.Truncation = append(t.Truncation, fmt.Sprintf("trimmed: %d items over token budget", n))
+				if blocks[i].Source == "search_hit" {
+					unincludeLastHits(t.Hits, n)
+				}
+			}
+			continue
+		}
+		total -= tokens[i]
+		tokens[i] = 0
+		dropped[i] = true
+		droppedSrc = append(droppedSrc, blocks[i].Source)
+		if t := blocks[i].Trace; t != nil {
+			t.Dropped = true
+			t.Truncation = append(t.Truncation, "dropped: over token budget")
+		}
+	}
+	if len(droppedSrc) > 0 {
+		log.Printf("[warn] context over budget; dropped blocks: %s", strings.Join(droppedSrc, ","))
+	}
+
+	msgs := make([]map[string]string, 0, len(idx))
+	rep.Context = 0
+	for _, i := range idx {
+		d := PromptBudgetDecision{
+			Source:   blocks[i].Source,
+			Priority: blockPriority(blocks[i]),
+			Before:   before[i],
+			Tokens:   tokens[i],
+			Action:   "kept",
+		}
+		switch {
+		case dropped[i]:
+			d.Action = "dropped"
+		case cut[i] > 0:
+			d.Action, d.Cut = "trimmed", cut[i]
+		}
+		rep.Blocks = append(rep.Blocks, d)
+		if t := blocks[i].Trace; t != nil {
+			t.Tokens = tokens[i]
+		}
+		if dropped[i] {
+			continue
+		}
+		rep.Context += tokens[i]
+		// b.Role 在 resolvePromptBlocks 里已被强制成 "assistant"
+		msgs = append(msgs, map[string]string{"role": blocks[i].Role, "content": promptBlockMessage(blocks[i])})
+	}
+	rep.Total = rep.System + rep.Input + rep.Context
+	rep.Tokenizer = tc.Name()
+	if rep.Limit > 0 && rep.Total > rep.Limit {
+		rep.Note = "over budget without context blocks (system prompt + input)"
+	}
+	return msgs, rep
+}
+
+// unincludeLastHits marks the n lowest-ranked included hits as cut by the budget.
+func unincludeLastHits(hits []BlockHitTrace, n int) {
+	for i := len(hits) - 1; i >= 0 && n > 0; i-- {
+		if hits[i].Included {
+			hits[i].Included = false
+			hits[i].Reason = "over_token_budget"
+			n--
+		}
+	}
+}
+
+func blockPriority(b PromptBlock) int {
+	if b.Trace == nil {
+		return 0
+	}
+	return b.Trace.Priority
+}
+
+// promptBlockItemsTrimmable lists the list-shaped sources trimPromptBlock may shorten.
+var promptBlockItemsTrimmable = map[string]bool{
+	"recent_raw":      true,
+	"recent_summary":  true,
+	"search_hit":      true,
+	"user_annotation": true,
+	"on_this_day":     true,
+	"glossary":        true,
+}
+
+// trimPromptBlock cuts whole items from b until it saves at least over tokens
+// (tokens = b's current count). ok=false when it would have to cut every item.
+func trimPromptBlock(tc *promptTokenCounter, b PromptBlock, tokens, over int) (content string, cut int, ok bool) {
+	if !promptBlockItemsTrimmable[b.Source] {
+		return "", 0, false
+	}
+	header, items := splitPromptBlockItems(b.Content)
+	if len(items) < 2 {
+		return "", 0, false
+	}
+	// heuristic item sizes, scaled to the counter in use
+	est := tc.Estimate(promptBlockMessage(b)) + promptMsgOverhead
+	scale := 1.0
+	if est > 0 {
+		scale = float64(tokens) / float64(est)
+	}
+	oldestFirst := b.Source == "recent_raw"
+	saved := 0.0
+	for cut < len(items)-1 && saved < float64(over) {
+		j := len(items) - 1 - cut
+		if oldestFirst {
+			j = cut
+		}
+		saved += float64(tc.Estimate(items[j])) * scale
+		cut++
+	}
+	if saved < float64(over) {
+		return "", 0, false
+	}
+	if oldestFirst {
+		items = items[cut:]
+	} else {
+		items = items[:len(items)-cut]
+	}
+	return header + "\n" + strings.Join(items, "\n"), cut, true
+}
+
+// splitPromptBlockItems splits a block into its header line and items. An item
+// starts with the marker of the first body line ("- " or "【"), otherwise at
+// every unindented line (indented lines continue the item, as in recent_raw).
+func splitPromptBlockItems(content string) (string, []string) {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if len(lines) < 2 {
+		return content, nil
+	}
+	marker := ""
+	for _, m := range []string{"- ", "【"} {
+		if strings.HasPrefix(lines[1], m) {
+			marker = m
+		}
+	}
+	var items []string
+	for _, l := range lines[1:] {
+		start := !strings.HasPrefix(l, " ") && !strings.HasPrefix(l, "\t")
+		if marker != "" {
+			start = strings.HasPrefix(l, marker)
+		}
+		if start || len(items) == 0 {
+			items = append(items, l)
+		} else {
+			items[len(items)-1] += "\n" + l
+		}
+	}
+	return lines[0], items
+}
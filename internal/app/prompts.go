@@ -30,6 +30,8 @@ CRITICAL RULES (must follow strictly):
 - Do NOT create memory candidates or long-term interpretations.
 - Do NOT rephrase, generalize, or interpret user statements.
 - If something is ambiguous, implicit, or inferred, ignore it.
+- Lines with "role":"event" are external events (labelled by "source", e.g. a workout, a git commit, a finished calendar item). Include them in topics/highlights as things that happened that day, but NEVER extract user facts from them.
+- Write ALL free-text values in {{OUTPUT_LANGUAGE}}, regardless of the language of the conversation. Keep JSON keys in English.
 
 ALLOWED EXCEPTION (very strict):
 - You MAY extract user facts ONLY IF they are:
@@ -87,6 +89,8 @@ CRITICAL RULES:
 - Do NOT create memory candidates or long-term facts.
 - Do NOT restate assistant or system information.
 - Weekly summary is for trends and progress only.
+- "user_annotations" are corrections written by the user; they override the daily content they contradict.
+- Write ALL free-text values in {{OUTPUT_LANGUAGE}}, regardless of the language of the input. Keep JSON keys in English.
 
 STYLE AND SCOPE CONSTRAINTS:
 - Do NOT generalize beyond what is explicitly supported by daily summaries.
@@ -127,6 +131,8 @@ CRITICAL RULES:
 - Do NOT create memory candidates or long-term facts.
 - Do NOT restate assistant or system information.
 - Monthly summary is for long-term trajectory only.
+- "user_annotations" are corrections written by the user; they override the weekly content they contradict.
+- Write ALL free-text values in {{OUTPUT_LANGUAGE}}, regardless of the language of the input. Keep JSON keys in English.
 
 STYLE AND SCOPE CONSTRAINTS:
 - Focus on direction and themes, not details.
@@ -169,6 +175,9 @@ func mustEnsurePromptFiles(cfg Config) {
 	_ = os.WriteFile(filepath.Join(cfg.PromptDir, "daily.txt"), []byte(promptDaily), 0644)
 	_ = os.WriteFile(filepath.Join(cfg.PromptDir, "weekly.txt"), []byte(promptWeekly), 0644)
 	_ = os.WriteFile(filepath.Join(cfg.PromptDir, "monthly.txt"), []byte(promptMonthly), 0644)
+
+	// chat_system.txt 属于用户，不覆盖；只刷新示例文件
+	writeChatSystemPromptExample(cfg)
 }
 
 func mustReadPrompt(cfg Config, name string) string {
@@ -177,5 +186,5 @@ func mustReadPrompt(cfg Config, name string) string {
 	if err != nil {
 		panic(err)
 	}
-	return string(b)
+	return applyOutputLanguage(cfg, string(b))
 }
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ============================================================
+// prompts_log：每轮对话实际发送给模型的 prompt（用于复现幻觉报告）
+// - turn_id 同时写入该轮 assistant 记录（JSONL / messages.record）
+// - 始终记录 hash；全文仅在 TIMELAYER_PROMPT_LOG_FULL=true 时保存
+// ============================================================
+
+type TurnPrompt struct {
+	TurnID     string              `json:"turn_id"`
+	Day        string              `json:"day"`
+	Model      string              `json:"model"`
+	PromptHash string              `json:"prompt_hash"`
+	System     string              `json:"system,omitempty"`
+	Context    []map[string]string `json:"context,omitempty"`
+	UserInput  string              `json:"user_input,omitempty"`
+	FullText   bool                `json:"full_text"`
+	CreatedAt  string              `json:"created_at"`
+}
+
+// promptHash hashes exactly what is sent: system, context messages, user message.
+func promptHash(system string, ctxMsgs []map[string]string, userInput string) string {
+	b, _ := json.Marshal(struct {
+		System  string              `json:"system"`
+		Context []map[string]string `json:"context"`
+		User    string              `json:"user"`
+	}{system, ctxMsgs, userInput})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func recordTurnPrompt(cfg Config, db *sql.DB, turnID string, now time.Time, system string, ctxMsgs []map[string]string, userInput string) error {
+	if db == nil || turnID == "" {
+		return nil
+	}
+	hash := promptHash(system, ctxMsgs, userInput)
+
+	var sys, ctxJSON, user sql.NullString
+	if cfg.PromptLogFullText {
+		b, err := json.Marshal(ctxMsgs)
+		if err != nil {
+			return err
+		}
+		sys = sql.NullString{String: system, Valid: true}
+		ctxJSON = sql.NullString{String: string(b), Valid: true}
+		user = sql.NullString{String: userInput, Valid: true}
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO prompts_log(turn_id, day, model, prompt_hash, system, context_json, user_input, created_at)
+		VALUES(?,?,?,?,?,?,?,?)
+	`, turnID, now.Format("2006-01-02"), cfg.ChatModel, hash, sys, ctxJSON, user, now.Format(time.RFC3339))
+	return err
+}
+
+// GetTurnPrompt loads the stored prompt for a turn.
+func GetTurnPrompt(db *sql.DB, turnID string) (*TurnPrompt, error) {
+	var tp TurnPrompt
+	var sys, ctxJSON, user sql.NullString
+	err := db.QueryRow(`
+		SELECT turn_id, day, model, prompt_hash, system, context_json, user_input, created_at
+		FROM prompts_log WHERE turn_id=?
+	`, turnID).Scan(&tp.TurnID, &tp.Day, &tp.Model, &tp.PromptHash, &sys, &ctxJSON, &user, &tp.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("turn not found")
+		}
+		return nil, err
+	}
+	if sys.Valid {
+		tp.FullText = true
+		tp.System = sys.String
+		tp.UserInput = user.String
+		_ = json.Unmarshal([]byte(ctxJSON.String), &tp.Context)
+	}
+	return &tp, nil
+}
@@ -0,0 +1,270 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Raw log storage
+// - file   (default): <LogDir>/<date>.jsonl only
+// - both:             JSONL file + messages table
+// - sqlite:           messages table only (JSONL can be exported on demand)
+// Readers go through readRawDay so every mode feeds the same pipeline.
+// ============================================================
+
+const (
+	logStorageFile   = "file"
+	logStorageSQLite = "sqlite"
+	logStorageBoth   = "both"
+)
+
+func logStorageWritesFile(cfg Config) bool {
+	return cfg.LogStorage != logStorageSQLite
+}
+
+func logStorageWritesDB(cfg Config) bool {
+	return cfg.LogStorage == logStorageSQLite || cfg.LogStorage == logStorageBoth
+}
+
+type RawMessage struct {
+	ID        int64  `json:"id"`
+	Day       string `json:"day"`
+	Seq       int    `json:"seq"`
+	Role      string `json:"role"`
+	Kind      string `json:"kind,omitempty"`
+	Content   string `json:"content"`
+	Source    string `json:"source"`
+	CreatedAt string `json:"created_at"`
+}
+
+type LogImportReport struct {
+	Days     int `json:"days"`
+	Lines    int `json:"lines"`
+	Inserted int `json:"inserted"`
+	Skipped  int `json:"skipped"`
+}
+
+// insertRawMessage stores one JSONL record. (day, seq) is unique, so re-importing
+// the same file (or importing a day already written live in "both" mode) is a no-op.
+func insertRawMessage(db dbTX, day string, seq int, record []byte, source, ts string) (bool, error) {
+	var m struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+		Kind    string `json:"kind"`
+	}
+	if err := json.Unmarshal(record, &m); err != nil {
+		return false, err
+	}
+	res, err := db.Exec(`
+		INSERT INTO messages(day, seq, role, kind, content, record, source, created_at)
+		VALUES(?,?,?,?,?,?,?,?)
+		ON CONFLICT(day, seq) DO NOTHING
+	`, day, seq, m.Role, m.Kind, m.Content, string(record), source, ts)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// nextMessageSeq returns the next seq for a day. In "both" mode the JSONL line count
+// is also considered so seq stays aligned with file line numbers.
+func nextMessageSeq(cfg Config, db *sql.DB, day string) int {
+	maxSeq := 0
+	if db != nil {
+		_ = db.QueryRow(`SELECT COALESCE(MAX(seq),0) FROM messages WHERE day=?`, day).Scan(&maxSeq)
+	}
+	if b, err := os.ReadFile(filepath.Join(cfg.LogDir, day+".jsonl")); err == nil {
+		if n := bytes.Count(b, []byte("\n")); n > maxSeq {
+			maxSeq = n
+		}
+	}
+	return maxSeq + 1
+}
+
+// readRawDay returns the day's raw JSONL. The file wins if present; otherwise the
+// messages table is rendered back into JSONL lines (same format as the file).
+func readRawDay(cfg Config, db *sql.DB, date string) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(cfg.LogDir, date+".jsonl"))
+	if err == nil || db == nil || !logStorageWritesDB(cfg) {
+		return b, err
+	}
+	out, derr := renderMessagesJSONL(db, date)
+	if derr != nil || len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+func renderMessagesJSONL(db *sql.DB, date string) ([]byte, error) {
+	rows, err := readDB(db).Query(`SELECT record FROM messages WHERE day=? ORDER BY seq`, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var buf bytes.Buffer
+	for rows.Next() {
+		var rec string
+		if err := rows.Scan(&rec); err != nil {
+			return nil, err
+		}
+		buf.WriteString(rec)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), rows.Err()
+}
+
+// ListRawMessages returns messages for one day, ordered by seq (limit<=0: all).
+func ListRawMessages(db *sql.DB, day string, limit int) ([]RawMessage, error) {
+	q := `SELECT id, day, seq, role, COALESCE(kind,''), content, source, created_at
+		FROM messages WHERE day=? ORDER BY seq`
+	args := []any{day}
+	if limit > 0 {
+		// newest N, still returned in chronological order
+		q = `SELECT * FROM (
+			SELECT id, day, seq, role, COALESCE(kind,''), content, source, created_at
+			FROM messages WHERE day=? ORDER BY seq DESC LIMIT ?
+		) ORDER BY seq`
+		args = append(args, limit)
+	}
+	rows, err := readDB(db).Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []RawMessage
+	for rows.Next() {
+		var m RawMessage
+		if err := rows.Scan(&m.ID, &m.Day, &m.Seq, &m.Role, &m.Kind, &m.Content, &m.Source, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// ImportJSONLLogs ingests <LogDir>/<date>.jsonl into the messages table.
+// date == "" imports every daily JSONL file. Safe to run repeatedly.
+func ImportJSONLLogs(cfg Config, db *sql.DB, date string) (LogImportReport, error) {
+	var rep LogImportReport
+	if db == nil {
+		return rep, errors.New("db is nil")
+	}
+
+	var days []string
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return rep, fmt.Errorf("invalid date: %s", date)
+		}
+		days = []string{date}
+	} else {
+		entries, err := os.ReadDir(cfg.LogDir)
+		if err != nil {
+			return rep, err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || !strings.HasSuffix(name, ".jsonl") {
+				continue
+			}
+			d := strings.TrimSuffix(name, ".jsonl")
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				continue
+			}
+			days = append(days, d)
+		}
+		sort.Strings(days)
+	}
+
+	ts := time.Now().In(cfg.Location).Format(time.RFC3339)
+	for _, day := range days {
+		f, err := os.Open(filepath.Join(cfg.LogDir, day+".jsonl"))
+		if err != nil {
+			return rep, err
+		}
+		err = withTx(db, func(tx *sql.Tx) error {
+			sc := bufio.NewScanner(f)
+			sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+			seq := 0
+			for sc.Scan() {
+				seq++
+				line := bytes.TrimSpace(sc.Bytes())
+				if len(line) == 0 {
+					continue
+				}
+				rep.Lines++
+				ok, err := insertRawMessage(tx, day, seq, line, "import", ts)
+				if err != nil || !ok {
+					rep.Skipped++
+					continue
+				}
+				rep.Inserted++
+			}
+			return sc.Err()
+		})
+		_ = f.Close()
+		if err != nil {
+			return rep, fmt.Errorf("import %s: %w", day, err)
+		}
+		rep.Days++
+	}
+	return rep, nil
+}
+
+// ExportMessagesJSONL writes the messages of one day to <LogDir>/<date>.jsonl.
+// Refuses to overwrite an existing file.
+func ExportMessagesJSONL(cfg Config, db *sql.DB, date string) (string, error) {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return "", fmt.Errorf("invalid date: %s", date)
+	}
+	path := filepath.Join(cfg.LogDir, date+".jsonl")
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("file already exists: %s", path)
+	}
+	b, err := renderMessagesJSONL(db, date)
+	if err != nil {
+		return "", err
+	}
+	if len(b) == 0 {
+		return "", fmt.Errorf("no messages for %s", date)
+	}
+	_ = os.MkdirAll(cfg.LogDir, 0755)
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// runLogStoreCommand implements /logs_import [date] and /logs_export <date>.
+func runLogStoreCommand(cfg Config, db *sql.DB, cmd, arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case "/logs_import":
+		rep, err := ImportJSONLLogs(cfg, db, arg)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("[ok] imported days=%d lines=%d inserted=%d skipped=%d",
+			rep.Days, rep.Lines, rep.Inserted, rep.Skipped), nil
+	case "/logs_export":
+		if arg == "" {
+			return "usage: /logs_export <YYYY-MM-DD>", nil
+		}
+		path, err := ExportMessagesJSONL(cfg, db, arg)
+		if err != nil {
+			return "", err
+		}
+		return "[ok] exported: " + path, nil
+	}
+	return "", fmt.Errorf("unknown command: %s", cmd)
+}
@@ -19,20 +19,31 @@ func Reindex(db *sql.DB, cfg Config, typ string) error {
 	)
 
 	switch typ {
+	case "fts":
+		n, err := rebuildSearchFTS(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[reindex done] full-text docs=%d\n", n)
+		return nil
+
 	case "daily", "weekly", "monthly":
 		rows, err = db.Query(`
-			SELECT id, type, period_key, json
-			FROM summaries
-			WHERE type = ?
-			ORDER BY period_key
+			SELECT s.id, s.type, s.period_key, s.json, e.summary_id IS NOT NULL
+			FROM summaries s
+			LEFT JOIN embeddings e ON e.summary_id = s.id
+			WHERE s.type = ? AND s.deleted_at IS NULL
+			ORDER BY s.period_key
 		`, typ)
 
 	case "all":
 		rows, err = db.Query(`
-			SELECT id, type, period_key, json
-			FROM summaries
-			ORDER BY type, period_key
-		`)
+			SELECT s.id, s.type, s.period_key, s.json, e.summary_id IS NOT NULL
+			FROM summaries s
+			LEFT JOIN embeddings e ON e.summary_id = s.id
+			WHERE s.deleted_at IS NULL AND s.type <> ?
+			ORDER BY s.type, s.period_key
+		`, summaryTypeDailyPartial)
 
 	default:
 		return fmt.Errorf("unknown reindex type: %s", typ)
@@ -43,11 +54,18 @@ func Reindex(db *sql.DB, cfg Config, typ string) error {
 	}
 	defer rows.Close()
 
+	type todo struct {
+		id   int64
+		sty  string
+		key  string
+		text string
+	}
 	var (
 		total   int
 		created int
 		skipped int
 		failed  int
+		items   []todo
 	)
 
 	for rows.Next() {
@@ -56,16 +74,18 @@ func Reindex(db *sql.DB, cfg Config, typ string) error {
 			sty string
 			key string
 			js  string
+			has bool
 		)
 
-		if err := rows.Scan(&id, &sty, &key, &js); err != nil {
+		// (no queries while rows is open: with one SQLite connection they would block)
+		if err := rows.Scan(&id, &sty, &key, &js, &has); err != nil {
 			failed++
 			continue
 		}
 		total++
 
 		// ✅ 1:1 embedding：已有就跳过
-		if hasEmbedding(db, id) {
+		if has {
 			skipped++
 			continue
 		}
@@ -76,20 +96,35 @@ func Reindex(db *sql.DB, cfg Config, typ string) error {
 			skipped++
 			continue
 		}
+		items = append(items, todo{id: id, sty: sty, key: key, text: indexText})
+	}
+	rows.Close()
 
-		// 写入 embedding
-		if err := ensureEmbedding(db, cfg, indexText, sty, key); err != nil {
+	// 批量 embedding（TIMELAYER_EMBED_BATCH_SIZE / TIMELAYER_EMBED_PARALLEL, see embedding_batch.go）
+	texts := make([]string, len(items))
+	for i, it := range items {
+		texts[i] = it.text
+	}
+	embedTextsBatched(cfg, texts, func(i int, vec []float32, l2 float64, err error) {
+		it := items[i]
+		if err == nil && (len(vec) == 0 || l2 == 0) {
+			err = fmt.Errorf("empty embedding")
+		}
+		if err == nil {
+			err = storeSummaryEmbedding(cfg, db, it.id, vec)
+		}
+		if err != nil {
 			fmt.Printf(
 				"[warn] embed failed %s %s: %v\n",
-				sty, key, err,
+				it.sty, it.key, err,
 			)
 			failed++
-			continue
+			return
 		}
 
-		fmt.Printf("[ok] embedded %s %s\n", sty, key)
+		fmt.Printf("[ok] embedded %s %s\n", it.sty, it.key)
 		created++
-	}
+	})
 
 	fmt.Printf(
 		"[reindex done] total=%d created=%d skipped=%d failed=%d\n",
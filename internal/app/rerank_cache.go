@@ -0,0 +1,119 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ============================================================
+// Memory version + rerank cache
+// - memoryVersion is bumped on every summary / fact write.
+// - Rerank scores are cached per (query hash, doc hash set, memory version),
+//   so e.g. /api/context/audit followed by the real chat reranks once.
+// - Hit/miss counters are exported on /metrics.
+// ============================================================
+
+var memoryVersion atomic.Uint64
+
+func bumpMemoryVersion() { memoryVersion.Add(1) }
+
+const rerankCacheMaxEntries = 512
+
+type rerankCacheEntry struct {
+	scores map[string]float64 // doc hash -> score
+}
+
+var rerankCache = struct {
+	mu      sync.Mutex
+	entries map[string]rerankCacheEntry
+	order   []string // FIFO eviction
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+}{entries: map[string]rerankCacheEntry{}}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func rerankCacheKey(query string, docHashes []string) string {
+	set := append([]string{}, docHashes...)
+	sort.Strings(set)
+	return fmt.Sprintf("%d:%s:%s", memoryVersion.Load(), sha256Hex(query), sha256Hex(strings.Join(set, ",")))
+}
+
+// rerankTextsCached is rerankTexts with a result cache. Scores are returned in docs order.
+func rerankTextsCached(cfg Config, query string, docs []string) ([]float64, error) {
+	hashes := make([]string, len(docs))
+	for i, d := range docs {
+		hashes[i] = sha256Hex(d)
+	}
+	key := rerankCacheKey(query, hashes)
+
+	rerankCache.mu.Lock()
+	e, ok := rerankCache.entries[key]
+	rerankCache.mu.Unlock()
+	if ok {
+		rerankCache.hits.Add(1)
+		out := make([]float64, len(docs))
+		for i, h := range hashes {
+			out[i] = e.scores[h]
+		}
+		return out, nil
+	}
+	rerankCache.misses.Add(1)
+
+	scores, err := rerankTexts(cfg, query, docs)
+	if err != nil || len(scores) != len(docs) {
+		return scores, err
+	}
+
+	e = rerankCacheEntry{scores: make(map[string]float64, len(docs))}
+	for i, h := range hashes {
+		e.scores[h] = scores[i]
+	}
+	rerankCache.mu.Lock()
+	if _, exists := rerankCache.entries[key]; !exists {
+		rerankCache.order = append(rerankCache.order, key)
+	}
+	rerankCache.entries[key] = e
+	for len(rerankCache.order) > rerankCacheMaxEntries {
+		delete(rerankCache.entries, rerankCache.order[0])
+		rerankCache.order = rerankCache.order[1:]
+	}
+	rerankCache.mu.Unlock()
+	return scores, nil
+}
+
+// writeMetrics writes Prometheus text-format metrics.
+func writeMetrics(w io.Writer) {
+	hits := rerankCache.hits.Load()
+	misses := rerankCache.misses.Load()
+	rerankCache.mu.Lock()
+	entries := len(rerankCache.entries)
+	rerankCache.mu.Unlock()
+
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+
+	fmt.Fprintln(w, "# TYPE timelayer_memory_version gauge")
+	fmt.Fprintf(w, "timelayer_memory_version %d\n", memoryVersion.Load())
+	fmt.Fprintln(w, "# TYPE timelayer_rerank_cache_hits_total counter")
+	fmt.Fprintf(w, "timelayer_rerank_cache_hits_total %d\n", hits)
+	fmt.Fprintln(w, "# TYPE timelayer_rerank_cache_misses_total counter")
+	fmt.Fprintf(w, "timelayer_rerank_cache_misses_total %d\n", misses)
+	fmt.Fprintln(w, "# TYPE timelayer_rerank_cache_entries gauge")
+	fmt.Fprintf(w, "timelayer_rerank_cache_entries %d\n", entries)
+	fmt.Fprintln(w, "# TYPE timelayer_rerank_cache_hit_ratio gauge")
+	fmt.Fprintf(w, "timelayer_rerank_cache_hit_ratio %.4f\n", ratio)
+	fmt.Fprintln(w, "# TYPE timelayer_http_deadline_exceeded_total counter")
+	fmt.Fprintf(w, "timelayer_http_deadline_exceeded_total %d\n", httpDeadlineExceeded.Load())
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Rollup scheduler
+// - LogWriter only rolls up when a write crosses midnight, so a day nobody
+//   chats after got its daily (and the week / month its rollup) only with
+//   the next message. The scheduler enqueues them at fixed local times
+//   (TIMELAYER_ROLLUP_AT, default "00:10"; "off" disables):
+//     daily   yesterday
+//     weekly  the previous ISO week
+//     monthly the previous month
+//   and runs the background jobs (budget, pause / resume as usual).
+// - Also runs once at startup when today's first slot has passed, which
+//   catches up on rollups missed while the process was down.
+// - Idempotent: finished jobs are not re-run, existing summaries are kept.
+// ============================================================
+
+const defaultRollupMinute = 10 // 00:10
+
+// parseRollupTimes parses "HH:MM[,HH:MM...]" into sorted minutes after midnight.
+func parseRollupTimes(v string) ([]int, error) {
+	var out []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		hh, mm, ok := strings.Cut(part, ":")
+		h, err1 := strconv.Atoi(hh)
+		m, err2 := strconv.Atoi(mm)
+		if !ok || err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+			return nil, fmt.Errorf("invalid rollup time: %q (want HH:MM)", part)
+		}
+		if !seen[h*60+m] {
+			seen[h*60+m] = true
+			out = append(out, h*60+m)
+		}
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+// rollupSlot is day's scheduled time at minute-of-day min.
+func rollupSlot(day time.Time, min int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), min/60, min%60, 0, 0, day.Location())
+}
+
+// nextRollupAt is the first scheduled time after now.
+func nextRollupAt(now time.Time, times []int) time.Time {
+	for _, m := range times {
+		if t := rollupSlot(now, m); t.After(now) {
+			return t
+		}
+	}
+	return rollupSlot(now.AddDate(0, 0, 1), times[0])
+}
+
+// runRollupScheduler enqueues rollups at the configured times until ctx is cancelled.
+func runRollupScheduler(ctx context.Context, cfg Config, db *sql.DB) {
+	if db == nil || len(cfg.RollupTimes) == 0 {
+		return
+	}
+	now := time.Now().In(cfg.Location)
+	if !now.Before(rollupSlot(now, cfg.RollupTimes[0])) {
+		runScheduledRollup(cfg, db, now)
+	}
+	for {
+		now = time.Now().In(cfg.Location)
+		if !sleepCtx(ctx, nextRollupAt(now, cfg.RollupTimes).Sub(now)) {
+			return
+		}
+		runScheduledRollup(cfg, db, time.Now().In(cfg.Location))
+	}
+}
+
+// runScheduledRollup enqueues the rollups due at now and runs the job queue.
+func runScheduledRollup(cfg Config, db *sql.DB, now time.Time) {
+	yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
+	wy, ww := now.AddDate(0, 0, -7).ISOWeek()
+	weekKey := fmt.Sprintf("%04d-W%02d", wy, ww)
+	monthKey := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0).Format("2006-01")
+
+	for _, j := range []struct{ kind, key string }{
+		{"daily", yesterday},
+		{"weekly", weekKey},
+		{"monthly", monthKey},
+	} {
+		if err := enqueueJob(cfg, db, j.kind, j.key); err != nil {
+			log.Printf("[warn] scheduled rollup: enqueue %s %s failed: %v", j.kind, j.key, err)
+		}
+	}
+	runBackgroundJobs(cfg, db)
+}
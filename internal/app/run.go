@@ -33,15 +33,58 @@ func Run() {
 	// 0️⃣ 初始化
 	// ------------------------------
 	cfg := defaultConfig()
+
+	// local-ai init [--check]   (onboarding wizard; writes the settings file)
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		os.Exit(runInitCLI(cfg, os.Args[2:]))
+	}
+	// first plain run on a terminal: ask instead of silently creating ~/local-ai with defaults
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 && len(os.Args) == 1 && needsOnboarding(cfg) {
+		fmt.Println("First run: nothing is set up yet.")
+		if code := runInitCLI(cfg, nil); code != 0 {
+			os.Exit(code)
+		}
+		fmt.Println()
+		cfg = defaultConfig()
+	}
+
+	cfg = AutoTuneContext(cfg)
+	initErrorReporting(cfg)
 	mustEnsureDirs(cfg)
 	mustEnsurePromptFiles(cfg)
 
+	// local-ai wipe --confirm [--export[=DIR]]
+	if len(os.Args) > 1 && os.Args[1] == "wipe" {
+		os.Exit(runWipeCLI(cfg, os.Args[2:]))
+	}
+	// local-ai abtest --b=B.env [--a=A.env] (--questions=FILE | --from-prompts=N) [--answer=mock]
+	if len(os.Args) > 1 && os.Args[1] == "abtest" {
+		os.Exit(runABTestCLI(cfg, os.Args[2:]))
+	}
+	// local-ai tune suggest [--days=30] [--min=5] [--json]
+	if len(os.Args) > 1 && os.Args[1] == "tune" {
+		os.Exit(runTuneCLI(cfg, os.Args[2:]))
+	}
+	// local-ai archive list [--json] | restore <date> [--reprocess] [--force]
+	if len(os.Args) > 1 && os.Args[1] == "archive" {
+		os.Exit(runArchiveCLI(cfg, os.Args[2:]))
+	}
+
 	db := mustOpenDB(cfg)
-	defer db.Close()
+	defer closeDB(db)
 
 	lw := NewLogWriter(cfg, db)
 	defer lw.Close()
 
+	if c, err := applyAssistant(cfg, db, cfg.AssistantName); err != nil {
+		fmt.Println("[warn]", err)
+	} else {
+		cfg = c
+	}
+
+	startBackgroundWorkers(cfg, db)
+	defer stopBackgroundWorkers() // runs before lw.Close and closeDB
+
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Println("🧠 Local AI Chat")
@@ -52,7 +95,11 @@ func Run() {
 	// 1️⃣ 主循环
 	// ==============================
 	for {
-		fmt.Print("You> ")
+		if cfg.Incognito {
+			fmt.Print("You (incognito)> ")
+		} else {
+			fmt.Print("You> ")
+		}
 
 		line, err := readLine(reader)
 		if err != nil {
@@ -86,9 +133,16 @@ func Run() {
 		// ------------------------------
 		// 2️⃣ 命令模式（/xxx）
 		// ------------------------------
+		// /incognito toggles memory writes for the following turns (session state)
+		if cmd, arg := normalizeCommand(line); cmd == "/incognito" {
+			cfg.Incognito = parseIncognitoArg(arg, cfg.Incognito)
+			fmt.Println(incognitoStatus(cfg.Incognito))
+			fmt.Print("\n------------------\n\n")
+			continue
+		}
 		if strings.HasPrefix(line, "/") {
 			handleCommand(cfg, db, lw, reader, line)
-			fmt.Println("\n------------------\n")
+			fmt.Print("\n------------------\n\n")
 			continue
 		}
 
@@ -101,11 +155,11 @@ func Run() {
 			if err != nil {
 				if errors.Is(err, ErrDirtyInput) {
 					fmt.Println("⚠️ 输入法异常，已忽略")
-					fmt.Println("\n------------------\n")
+					fmt.Print("\n------------------\n\n")
 					continue
 				}
 				fmt.Println("input error:", err)
-				fmt.Println("\n------------------\n")
+				fmt.Print("\n------------------\n\n")
 				continue
 			}
 		} else {
@@ -114,7 +168,7 @@ func Run() {
 
 		input = strings.TrimSpace(input)
 		if input == "" {
-			fmt.Println("\n------------------\n")
+			fmt.Print("\n------------------\n\n")
 			continue
 		}
 
@@ -129,18 +183,22 @@ func Run() {
 			}
 		} else {
 			answer := streamChat(cfg, input)
+			if cfg.Incognito {
+				fmt.Print("\n------------------\n\n")
+				continue
+			}
 
-			_ = lw.WriteRecord(map[string]string{
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{
 				"role":    "user",
 				"content": input,
-			})
-			_ = lw.WriteRecord(map[string]string{
+			}))
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{
 				"role":    "assistant",
 				"content": answer,
-			})
+			}))
 		}
 
-		fmt.Println("\n------------------\n")
+		fmt.Print("\n------------------\n\n")
 	}
 }
 
@@ -9,7 +9,6 @@ import (
 	"io"
 	"math"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
 )
@@ -21,11 +20,17 @@ Search Result Structure
 */
 
 type SearchHit struct {
-	Score    float64 `json:"score"`     // rerank 后为最终分，否则等于 EmbScore
-	EmbScore float64 `json:"emb_score"` // embedding cosine（仅 debug / 结构判断）
+	Score    float64 `json:"score"`               // rerank 后为最终分，否则为 embedding 与全文的融合分
+	EmbScore float64 `json:"emb_score"`           // embedding cosine（仅 debug / 结构判断；纯全文命中为 0）
+	FTSScore float64 `json:"fts_score,omitempty"` // 全文 bm25 归一化分（search_fts.go）
 	Type     string  `json:"type"`
 	Date     string  `json:"date"`
 	Text     string  `json:"text"`
+	Rank     int     `json:"rank,omitempty"`     // 1-based position in the final result
+	Gate     string  `json:"gate,omitempty"`     // rerank | rerank_error | skipped:<reason>
+	Archived bool    `json:"archived,omitempty"` // daily hit whose raw log is archived (see /unarchive)
+
+	summaryID int64 // summaries.id (internal; used for scope filtering)
 }
 
 /*
@@ -183,96 +188,123 @@ func SearchWithScore(db *sql.DB, cfg Config, query string) ([]SearchHit, error)
 	if query == "" {
 		return nil, nil
 	}
+	beginSearchDebug(cfg, query)
 
-	// 1️⃣ embed query
-	qv, qn, err := embedQueryText(cfg, query)
-	if err != nil {
-		return nil, err
+	// 1️⃣ embed query（失败时退回全文检索，见 search_fts.go）
+	qv, qn, embErr := embedQueryText(cfg, query)
+	useVec := embErr == nil && qn > 0
+
+	w := cfg.SearchFTSWeight
+	if !useVec {
+		w = 1
 	}
-	if qn == 0 {
-		return nil, nil
+	ftsHits := searchFTS(db, cfg.User, query, ftsCandidateLimit)
+	ftsByID := make(map[int64]ftsHit, len(ftsHits))
+	for _, fh := range ftsHits {
+		ftsByID[fh.id] = fh
 	}
-
-	// 2️⃣ load embeddings
-	rows, err := db.Query(`
-		SELECT
-			s.type,
-			s.period_key,
-			s.json,
-			s.text,
-			e.vec,
-			e.l2,
-			e.dim
-		FROM embeddings e
-		JOIN summaries s ON s.id = e.summary_id
-	`)
-	if err != nil {
-		return nil, err
+	if !useVec && len(ftsHits) == 0 {
+		return nil, embErr
 	}
-	defer rows.Close()
 
 	var hits []SearchHit
+	seen := map[int64]bool{}
 
-	for rows.Next() {
-		var (
-			typ  string
-			key  string
-			js   string
-			txt  string
-			blob []byte
-			l2   float64
-			dim  int
-		)
-
-		if err := rows.Scan(&typ, &key, &js, &txt, &blob, &l2, &dim); err != nil {
-			continue
-		}
-		if dim != len(qv) || l2 == 0 {
-			continue
+	// 2️⃣ load embeddings
+	if useVec {
+		rows, err := searchEmbeddingRows(db, cfg, qv, ftsHits)
+		if err != nil {
+			return nil, err
 		}
 
-		dot, ok := dotProductExactDim(qv, blob, dim)
-		if !ok {
-			continue
-		}
+		for rows.Next() {
+			var (
+				sid  int64
+				typ  string
+				key  string
+				js   string
+				txt  string
+				blob []byte
+				l2   float64
+				dim  int
+			)
 
-		embScore := dot / (qn * l2)
-		if math.IsNaN(embScore) || math.IsInf(embScore, 0) {
-			continue
-		}
-		if embScore < cfg.SearchMinScore {
-			continue
-		}
+			if err := rows.Scan(&sid, &typ, &key, &js, &txt, &blob, &l2, &dim); err != nil {
+				continue
+			}
+			if l2 == 0 {
+				continue
+			}
+			if dim != len(qv) {
+				noteQueryDimMismatch(dim, len(qv))
+				continue // dimension mismatch: full-text side only (below)
+			}
 
-		// 展示文本选择
-		displayText := ""
-		if typ == "fact" && strings.TrimSpace(txt) != "" {
-			displayText = strings.TrimSpace(txt)
-		} else {
-			displayText = extractHumanText(js)
-		}
-		displayText = strings.TrimSpace(displayText)
-		if displayText == "" {
-			continue
+			dot, ok := dotProductExactDim(qv, blob, dim)
+			if !ok {
+				continue
+			}
+
+			embScore := dot / (qn * l2)
+			if math.IsNaN(embScore) || math.IsInf(embScore, 0) {
+				continue
+			}
+			seen[sid] = true
+			if embScore < cfg.SearchMinScore {
+				continue
+			}
+
+			displayText := searchDisplayText(typ, js, txt)
+			if displayText == "" {
+				continue
+			}
+
+			fts := ftsByID[sid].score
+			hits = append(hits, SearchHit{
+				Score:    (1-w)*embScore + w*fts,
+				EmbScore: embScore,
+				FTSScore: fts,
+				Type:     typ,
+				Date:     key,
+				Text:     displayText,
+
+				summaryID: sid,
+			})
 		}
+		rows.Close()
+	}
 
-		hits = append(hits, SearchHit{
-			Score:    embScore,
-			EmbScore: embScore,
-			Type:     typ,
-			Date:     key,
-			Text:     displayText,
-		})
+	// full-text matches without a usable vector (not embedded, dim mismatch, embed down)
+	if w > 0 {
+		for _, fh := range ftsHits {
+			if seen[fh.id] {
+				continue
+			}
+			displayText := searchDisplayText(fh.typ, fh.js, fh.txt)
+			if displayText == "" {
+				continue
+			}
+			hits = append(hits, SearchHit{
+				Score:    w * fh.score,
+				FTSScore: fh.score,
+				Type:     fh.typ,
+				Date:     fh.key,
+				Text:     displayText,
+
+				summaryID: fh.id,
+			})
+		}
 	}
 
+	// scope filter（在截断之前做，保证 scoped 结果仍能填满 topK）
+	hits = filterHitsByScope(db, hits, cfg.Scope)
+
 	if len(hits) == 0 {
 		return nil, nil
 	}
 
-	// 3️⃣ embedding 排序
-	sort.Slice(hits, func(i, j int) bool {
-		return hits[i].EmbScore > hits[j].EmbScore
-	})
+	// 3️⃣ 融合分排序（按类型权重加权）
+	applyTypeWeights(cfg, hits, func(h SearchHit) float64 { return h.Score })
 
 	// 4️⃣ 截断给 rerank
 	topN := cfg.RerankTopN
@@ -287,55 +319,92 @@ func SearchWithScore(db *sql.DB, cfg Config, query string) ([]SearchHit, error)
 	}
 
 	// 5️⃣ rerank（Intent Gate 在这里）
+	gate := "rerank_error" // also covers a score count mismatch
 	if shouldRerank(hits, cfg) {
 		docs := make([]string, 0, len(hits))
 		for _, h := range hits {
 			docs = append(docs, h.Text)
 		}
 
-		scores, rerr := rerankTexts(cfg, query, docs)
+		scores, rerr := rerankTextsCached(cfg, query, docs)
 		if rerr == nil && len(scores) == len(hits) {
 			for i := range hits {
 				hits[i].Score = scores[i]
 			}
 
-			sort.SliceStable(hits, func(i, j int) bool {
-				return hits[i].Score > hits[j].Score
-			})
-
-			printRerankDebug(hits)
+			// rerank 分同样按类型加权（topK 之前）
+			applyTypeWeights(cfg, hits, func(h SearchHit) float64 { return h.Score })
+			gate = "rerank"
+
+			ev := newSearchDebugEvent(cfg, "rerank", hits)
+			ev.Top = debugTopHits(hits)
+			recordSearchDebug(cfg, query, ev)
+		} else if rerr != nil {
+			ev := newSearchDebugEvent(cfg, "rerank_error", hits)
+			ev.Error = rerr.Error()
+			recordSearchDebug(cfg, query, ev)
 		}
 	} else {
-		// ⭐ 新增：rerank 被跳过时的明确日志
-		now := time.Now().Format("2006-01-02 15:04:05.000")
-		reason := explainRerankSkip(hits, cfg)
-		mode := strings.ToLower(strings.TrimSpace(cfg.RerankMode))
-
-		// Add a tiny bit of numeric context to make tuning easier.
-		if len(hits) >= 2 {
-			top1 := hits[0].EmbScore
-			top2 := hits[1].EmbScore
-			gap := top1 - top2
-			fmt.Printf(
-				"========== RERANK SKIPPED @ %s mode=%s reason=%s hits=%d top1=%.4f top2=%.4f gap=%.4f strong=%.4f gap_th=%.4f ==========\n",
-				now, mode, reason, len(hits), top1, top2, gap, cfg.SearchMinStrong, cfg.SearchMinGap,
-			)
-		} else {
-			fmt.Printf(
-				"========== RERANK SKIPPED @ %s mode=%s reason=%s hits=%d ==========\n",
-				now, mode, reason, len(hits),
-			)
-		}
+		// rerank 被跳过：记录原因 + 阈值（便于调参）
+		ev := newSearchDebugEvent(cfg, "rerank_skipped", hits)
+		ev.Reason = explainRerankSkip(hits, cfg)
+		gate = "skipped:" + ev.Reason
+		recordSearchDebug(cfg, query, ev)
 	}
 
 	// 6️⃣ topK
 	if len(hits) > cfg.SearchTopK {
 		hits = hits[:cfg.SearchTopK]
 	}
+	for i := range hits {
+		hits[i].Rank = i + 1
+		hits[i].Gate = gate
+	}
 
 	return hits, nil
 }
 
+// searchEmbeddingRows loads the user's embeddings: all of them, or only the ANN
+// candidates plus the full-text hits once the vector index covers the user (vector_index.go).
+func searchEmbeddingRows(db *sql.DB, cfg Config, qv []float32, ftsHits []ftsHit) (*sql.Rows, error) {
+	q := `
+		SELECT
+			s.id,
+			s.type,
+			s.period_key,
+			s.json,
+			s.text,
+			e.vec,
+			e.l2,
+			e.dim
+		FROM embeddings e
+		JOIN summaries s ON s.id = e.summary_id
+		WHERE s.user_id = ? AND s.type <> ?`
+	args := []any{cfg.User, summaryTypeDailyPartial}
+	if ids, ok := vectorIndexCandidates(cfg, cfg.User, qv, max(vectorIndexK, 4*cfg.RerankTopN)); ok {
+		for _, fh := range ftsHits {
+			ids = append(ids, fh.id)
+		}
+		if len(ids) == 0 {
+			q += ` AND 0`
+		} else {
+			q += ` AND e.summary_id IN (?` + strings.Repeat(`,?`, len(ids)-1) + `)`
+		}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	return readDB(db).Query(q, args...)
+}
+
+// searchDisplayText picks what a hit shows: the text of facts / QA, else the summary highlights.
+func searchDisplayText(typ, js, txt string) string {
+	if (typ == "fact" || typ == summaryTypeQA) && strings.TrimSpace(txt) != "" {
+		return strings.TrimSpace(txt)
+	}
+	return strings.TrimSpace(extractHumanText(js))
+}
+
 /*
 ========================
 Query embedding
@@ -446,23 +515,6 @@ Debug
 ========================
 */
 
-func printRerankDebug(hits []SearchHit) {
-	n := len(hits)
-	if n > 10 {
-		n = 10
-	}
-	now := time.Now().Format("2006-01-02 15:04:05.000")
-	fmt.Printf("========== RERANK DEBUG (top) @ %s ==========\n", now)
-	for i := 0; i < n; i++ {
-		h := hits[i]
-		fmt.Printf(
-			"[%02d] final=%.4f emb=%.4f type=%s date=%s text=%q\n",
-			i, h.Score, h.EmbScore, h.Type, h.Date, cutForDebug(h.Text, 120),
-		)
-	}
-	fmt.Println("==============================================")
-}
-
 func cutForDebug(s string, max int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
 	s = strings.TrimSpace(s)
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Search debug events
+// - Rerank / rerank-skipped diagnostics as structured events, stored per query
+//   (last N queries) and returned by the context audit.
+// - Verbosity (TIMELAYER_SEARCH_DEBUG): off | store (default) | log
+//   log = store + one JSON line per event via the standard logger.
+// - Nothing is printed to stdout, so the CLI chat view stays clean.
+// ============================================================
+
+const (
+	searchDebugOff   = "off"
+	searchDebugStore = "store"
+	searchDebugLog   = "log"
+
+	searchDebugMaxQueries = 64
+	searchDebugTopHits    = 10
+)
+
+type SearchDebugHit struct {
+	Score    float64 `json:"score"`
+	EmbScore float64 `json:"emb_score"`
+	Type     string  `json:"type"`
+	Date     string  `json:"date"`
+	Text     string  `json:"text"`
+}
+
+type SearchDebugEvent struct {
+	TS     string           `json:"ts"`
+	Event  string           `json:"event"` // rerank | rerank_skipped | rerank_error
+	Mode   string           `json:"mode"`
+	Reason string           `json:"reason,omitempty"`
+	Hits   int              `json:"hits"`
+	Top1   float64          `json:"top1,omitempty"`
+	Top2   float64          `json:"top2,omitempty"`
+	Gap    float64          `json:"gap,omitempty"`
+	Strong float64          `json:"strong_th"`
+	GapTh  float64          `json:"gap_th"`
+	Error  string           `json:"error,omitempty"`
+	Top    []SearchDebugHit `json:"top,omitempty"`
+}
+
+var searchDebug = struct {
+	mu     sync.Mutex
+	events map[string][]SearchDebugEvent // query -> events of its latest search
+	order  []string
+}{events: map[string][]SearchDebugEvent{}}
+
+// newSearchDebugEvent fills the gate context shared by all events.
+func newSearchDebugEvent(cfg Config, event string, hits []SearchHit) SearchDebugEvent {
+	ev := SearchDebugEvent{
+		TS:     time.Now().In(cfg.Location).Format(time.RFC3339Nano),
+		Event:  event,
+		Mode:   strings.ToLower(strings.TrimSpace(cfg.RerankMode)),
+		Hits:   len(hits),
+		Strong: cfg.SearchMinStrong,
+		GapTh:  cfg.SearchMinGap,
+	}
+	if len(hits) >= 1 {
+		ev.Top1 = hits[0].EmbScore
+	}
+	if len(hits) >= 2 {
+		ev.Top2 = hits[1].EmbScore
+		ev.Gap = ev.Top1 - ev.Top2
+	}
+	return ev
+}
+
+func debugTopHits(hits []SearchHit) []SearchDebugHit {
+	n := len(hits)
+	if n > searchDebugTopHits {
+		n = searchDebugTopHits
+	}
+	out := make([]SearchDebugHit, 0, n)
+	for _, h := range hits[:n] {
+		out = append(out, SearchDebugHit{
+			Score: h.Score, EmbScore: h.EmbScore, Type: h.Type, Date: h.Date,
+			Text: cutForDebug(h.Text, 120),
+		})
+	}
+	return out
+}
+
+// beginSearchDebug resets the stored events of a query (a new search replaces the old trace).
+func beginSearchDebug(cfg Config, query string) {
+	if cfg.SearchDebug == searchDebugOff {
+		return
+	}
+	searchDebug.mu.Lock()
+	defer searchDebug.mu.Unlock()
+	if _, ok := searchDebug.events[query]; !ok {
+		searchDebug.order = append(searchDebug.order, query)
+		for len(searchDebug.order) > searchDebugMaxQueries {
+			delete(searchDebug.events, searchDebug.order[0])
+			searchDebug.order = searchDebug.order[1:]
+		}
+	}
+	searchDebug.events[query] = nil
+}
+
+func recordSearchDebug(cfg Config, query string, ev SearchDebugEvent) {
+	switch cfg.SearchDebug {
+	case searchDebugOff:
+		return
+	case searchDebugLog:
+		if b, err := json.Marshal(ev); err == nil {
+			log.Printf("[search] %s", b)
+		}
+	}
+	searchDebug.mu.Lock()
+	defer searchDebug.mu.Unlock()
+	if _, ok := searchDebug.events[query]; ok {
+		searchDebug.events[query] = append(searchDebug.events[query], ev)
+	}
+}
+
+// SearchDebugEvents returns the events recorded by the latest search for query.
+func SearchDebugEvents(query string) []SearchDebugEvent {
+	searchDebug.mu.Lock()
+	defer searchDebug.mu.Unlock()
+	return append([]SearchDebugEvent(nil), searchDebug.events[strings.TrimSpace(query)]...)
+}
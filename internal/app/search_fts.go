@@ -0,0 +1,237 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// ============================================================
+// Full-text search (SQLite FTS5)
+// - summaries_fts holds one row per summary (rowid = summaries.id) with its
+//   index text pre-tokenized here: CJK runs as unigrams + bigrams (unicode61
+//   alone would keep a whole Chinese sentence as one token), other text as
+//   lowercased words. Facts are covered through their "fact:<key>" docs
+//   (syncFactToSearch), which mirror the active user_facts.
+// - Written by upsertSummary; rows of deleted / trashed summaries are
+//   dropped at query time by the join (ids are never reused, see wipe.go),
+//   as is daily_partial, which is never searched.
+//   Built on first open of an older DB; /reindex fts rebuilds it.
+// - SearchWithScore blends bm25 (normalized to the best match = 1) with the
+//   embedding cosine: (1-w)*cos + w*fts, w = TIMELAYER_SEARCH_FTS_WEIGHT.
+//   Docs without a usable vector (not embedded yet, dimension mismatch)
+//   enter on the full-text side alone. When the embedding server is down,
+//   search runs on full text only. w=0 keeps pure embedding ranking and
+//   uses full text only as that fallback.
+// ============================================================
+
+const (
+	defaultSearchFTSWeight = 0.25
+	ftsCandidateLimit      = 200
+	ftsMaxQueryTerms       = 32
+)
+
+// ftsAvailable is false when the SQLite build has no FTS5 (search then stays embedding-only).
+var ftsAvailable = true
+
+func ensureSearchFTSSchema(db *sql.DB) error {
+	if db == nil {
+		return nil
+	}
+	if _, err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS summaries_fts USING fts5(body, tokenize='unicode61')`); err != nil {
+		ftsAvailable = false
+		log.Printf("[warn] full-text search disabled: %v", err)
+		return err
+	}
+	var indexed, total int
+	_ = db.QueryRow(`SELECT COUNT(*) FROM summaries_fts`).Scan(&indexed)
+	_ = db.QueryRow(`SELECT COUNT(*) FROM summaries WHERE deleted_at IS NULL`).Scan(&total)
+	if indexed == 0 && total > 0 {
+		n, err := rebuildSearchFTS(db)
+		if err != nil {
+			return err
+		}
+		log.Printf("[info] full-text index built: %d docs", n)
+	}
+	return nil
+}
+
+// rebuildSearchFTS re-tokenizes every live summary.
+func rebuildSearchFTS(db *sql.DB) (int, error) {
+	if !ftsAvailable {
+		return 0, fmt.Errorf("full-text search unavailable (sqlite without fts5)")
+	}
+	rows, err := db.Query(`SELECT id, text FROM summaries WHERE deleted_at IS NULL`)
+	if err != nil {
+		return 0, err
+	}
+	type doc struct {
+		id   int64
+		text string
+	}
+	var docs []doc
+	for rows.Next() {
+		var d doc
+		if err := rows.Scan(&d.id, &d.text); err == nil {
+			docs = append(docs, d)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM summaries_fts`); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, d := range docs {
+		body := ftsDocBody(d.text)
+		if body == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO summaries_fts(rowid, body) VALUES(?,?)`, d.id, body); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	return n, tx.Commit()
+}
+
+// indexSearchFTS (re)indexes one summary (best effort).
+func indexSearchFTS(db *sql.DB, id int64, text string) {
+	if !ftsAvailable || db == nil || id <= 0 {
+		return
+	}
+	_, _ = db.Exec(`DELETE FROM summaries_fts WHERE rowid=?`, id)
+	if body := ftsDocBody(text); body != "" {
+		_, _ = db.Exec(`INSERT INTO summaries_fts(rowid, body) VALUES(?,?)`, id, body)
+	}
+}
+
+// ftsIsCJK covers CJK ideographs and kana / hangul (unlike tts.go isCJK, no punctuation).
+func ftsIsCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// ftsTokens splits text into CJK runs and lowercased words.
+// Each CJK run yields its bigrams, plus unigrams when withUnigrams (always for a 1-rune run).
+func ftsTokens(text string, withUnigrams bool) []string {
+	var out []string
+	var word []rune
+	var run []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			out = append(out, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	flushRun := func() {
+		if len(run) == 0 {
+			return
+		}
+		if withUnigrams || len(run) == 1 {
+			for _, r := range run {
+				out = append(out, string(r))
+			}
+		}
+		for i := 0; i+1 < len(run); i++ {
+			out = append(out, string(run[i:i+2]))
+		}
+		run = run[:0]
+	}
+	for _, r := range text {
+		switch {
+		case ftsIsCJK(r):
+			flushWord()
+			run = append(run, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushRun()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushRun()
+		}
+	}
+	flushWord()
+	flushRun()
+	return out
+}
+
+func ftsDocBody(text string) string {
+	return strings.Join(ftsTokens(text, true), " ")
+}
+
+// ftsMatchQuery is an OR query over the distinct terms of q ("" = nothing to match).
+func ftsMatchQuery(q string) string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range ftsTokens(q, false) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		terms = append(terms, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+		if len(terms) >= ftsMaxQueryTerms {
+			break
+		}
+	}
+	return strings.Join(terms, " OR ")
+}
+
+type ftsHit struct {
+	id    int64
+	typ   string
+	key   string
+	js    string
+	txt   string
+	score float64 // bm25 normalized to (0,1], best match = 1
+}
+
+// searchFTS returns the best full-text matches of user's live summaries.
+func searchFTS(db *sql.DB, user, query string, limit int) []ftsHit {
+	match := ftsMatchQuery(query)
+	if !ftsAvailable || db == nil || match == "" {
+		return nil
+	}
+	rows, err := readDB(db).Query(`
+		SELECT s.id, s.type, s.period_key, s.json, s.text, bm25(summaries_fts)
+		FROM summaries_fts f
+		JOIN summaries s ON s.id = f.rowid
+		WHERE summaries_fts MATCH ? AND s.user_id = ? AND s.deleted_at IS NULL AND s.type <> ?
+		ORDER BY bm25(summaries_fts)
+		LIMIT ?`, match, user, summaryTypeDailyPartial, limit)
+	if err != nil {
+		log.Printf("[warn] full-text search failed: %v", err)
+		return nil
+	}
+	defer rows.Close()
+	var out []ftsHit
+	for rows.Next() {
+		var h ftsHit
+		if err := rows.Scan(&h.id, &h.typ, &h.key, &h.js, &h.txt, &h.score); err != nil {
+			continue
+		}
+		out = append(out, h)
+	}
+	// bm25 is negative, lower = better
+	if len(out) > 0 && out[0].score < 0 {
+		best := out[0].score
+		for i := range out {
+			out[i].score /= best
+		}
+	} else {
+		for i := range out {
+			out[i].score = 1
+		}
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Search scope
+// - A chat/ask request may carry a scope (tags and/or workspace).
+// - Scope constrains BOTH fact injection and summary retrieval to
+//   content tagged with at least one of the scope tags.
+// - Workspace is a tag namespace: workspace "acme" == tag "ws:acme".
+// - Summaries are tagged via summary_tags; facts via user_fact_tags.
+// ============================================================
+
+const workspaceTagPrefix = "ws:"
+
+type SearchScope struct {
+	Tags      []string `json:"tags,omitempty"`
+	Workspace string   `json:"workspace,omitempty"`
+}
+
+func (s SearchScope) IsZero() bool {
+	return len(s.ScopeTags()) == 0
+}
+
+// ScopeTags returns the normalized tag set matched by this scope.
+func (s SearchScope) ScopeTags() []string {
+	tags := append([]string{}, s.Tags...)
+	if ws := normalizeFactTag(s.Workspace); ws != "" {
+		if !strings.HasPrefix(ws, workspaceTagPrefix) {
+			ws = workspaceTagPrefix + ws
+		}
+		tags = append(tags, ws)
+	}
+	return normalizeFactTags(tags)
+}
+
+// matches reports whether content carrying tags is inside the scope.
+func (s SearchScope) matches(tags []string) bool {
+	st := s.ScopeTags()
+	if len(st) == 0 {
+		return true
+	}
+	for _, t := range tags {
+		for _, x := range st {
+			if t == x {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// factTagPolicyFor merges the request scope into the configured fact tag policy:
+// a non-empty scope narrows Include (see narrowInclude), Exclude always stays in force.
+func factTagPolicyFor(cfg Config) FactTagPolicy {
+	return cfg.ContextFactTags.narrowInclude(cfg.Scope.ScopeTags())
+}
+
+// parseScopeFlags strips --scope=a,b / --scope a,b / --ws=name / --workspace name flags
+// from a command argument and returns the remaining text and the scope.
+func parseScopeFlags(input string) (rest string, scope SearchScope) {
+	fields := strings.Fields(input)
+	var keep []string
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		name, val, hasVal := strings.Cut(f, "=")
+		switch name {
+		case "--scope", "--ws", "--workspace":
+		default:
+			keep = append(keep, f)
+			continue
+		}
+		if !hasVal {
+			if i+1 >= len(fields) {
+				continue
+			}
+			i++
+			val = fields[i]
+		}
+		if name == "--scope" {
+			scope.Tags = append(scope.Tags, parseFactTagList(val)...)
+		} else {
+			scope.Workspace = val
+		}
+	}
+	return strings.Join(keep, " "), scope
+}
+
+// filterHitsByScope keeps only hits whose underlying content carries a scope tag.
+// fact hits use user_fact_tags (keyed by fact_key); other hits use summary_tags.
+func filterHitsByScope(db *sql.DB, hits []SearchHit, scope SearchScope) []SearchHit {
+	if scope.IsZero() || len(hits) == 0 {
+		return hits
+	}
+
+	var factKeys []string
+	var summaryIDs []int64
+	for _, h := range hits {
+		if h.Type == "fact" {
+			factKeys = append(factKeys, strings.TrimPrefix(h.Date, "fact:"))
+		} else {
+			summaryIDs = append(summaryIDs, h.summaryID)
+		}
+	}
+	factTags, _ := loadFactTags(db, factKeys)
+	sumTags, _ := loadSummaryTags(db, summaryIDs)
+
+	out := hits[:0:0]
+	for _, h := range hits {
+		var tags []string
+		if h.Type == "fact" {
+			tags = factTags[strings.TrimPrefix(h.Date, "fact:")]
+		} else {
+			tags = sumTags[h.summaryID]
+		}
+		if scope.matches(tags) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// summaryTagsChunk bounds the ids per summary_tags query (SQLite's variable limit).
+const summaryTagsChunk = 500
+
+// loadSummaryTags returns tags per summary id (only the rows of ids are read).
+func loadSummaryTags(db dbTX, ids []int64) (map[int64][]string, error) {
+	out := map[int64][]string{}
+	if db == nil || len(ids) == 0 {
+		return out, nil
+	}
+	for len(ids) > 0 {
+		chunk := ids[:min(len(ids), summaryTagsChunk)]
+		ids = ids[len(chunk):]
+		args := make([]any, 0, len(chunk))
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+		rows, err := db.Query(`SELECT summary_id, tag FROM summary_tags WHERE summary_id IN (?`+strings.Repeat(`,?`, len(chunk)-1)+`) ORDER BY tag`, args...)
+		if err != nil {
+			return out, err
+		}
+		for rows.Next() {
+			var id int64
+			var t string
+			if err := rows.Scan(&id, &t); err != nil {
+				continue
+			}
+			out[id] = append(out[id], t)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// SetSummaryTags tags a summary (type + period_key) so scoped searches can find it.
+// action: set | add | remove (default: set). This function is transactional.
+func SetSummaryTags(cfg Config, db *sql.DB, typ, periodKey string, tags []string, action string) ([]string, error) {
+	typ = strings.TrimSpace(typ)
+	periodKey = strings.TrimSpace(periodKey)
+	if db == nil || typ == "" || periodKey == "" {
+		return nil, errors.New("summary not found")
+	}
+	tags = normalizeFactTags(tags)
+	action = strings.ToLower(strings.TrimSpace(action))
+	if action == "" {
+		action = "set"
+	}
+	if action != "set" && action != "add" && action != "remove" {
+		return nil, errors.New("invalid action: expected set|add|remove")
+	}
+
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	ts := time.Now().In(loc).Format(time.RFC3339)
+
+	var out []string
+	err := withDBRetry(3, 25*time.Millisecond, func() error {
+		return withTx(db, func(tx *sql.Tx) error {
+			var id int64
+			if err := tx.QueryRow(`SELECT id FROM summaries WHERE type=? AND period_key=?`, typ, periodKey).Scan(&id); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errors.New("summary not found")
+				}
+				return err
+			}
+			if action == "set" {
+				if _, err := tx.Exec(`DELETE FROM summary_tags WHERE summary_id=?`, id); err != nil {
+					return err
+				}
+			}
+			for _, t := range tags {
+				var err error
+				if action == "remove" {
+					_, err = tx.Exec(`DELETE FROM summary_tags WHERE summary_id=? AND tag=?`, id, t)
+				} else {
+					_, err = tx.Exec(`
+						INSERT INTO summary_tags(summary_id, tag, created_at)
+						VALUES(?,?,?)
+						ON CONFLICT(summary_id, tag) DO NOTHING
+					`, id, t, ts)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			m, err := loadSummaryTags(tx, []int64{id})
+			if err != nil {
+				return err
+			}
+			out = m[id]
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================================
+// Query-time type weighting
+// - Per-type score multipliers (fact, daily, weekly, monthly, document).
+// - Applied to the embedding ranking (before the rerank pool is cut) and to
+//   the final score (before topK), so weighted types can win both stages.
+// - Missing type = 1.0. TIMELAYER_SEARCH_TYPE_WEIGHTS="fact=1.3,daily=1.1"
+// ============================================================
+
+func defaultSearchTypeWeights() map[string]float64 {
+	return map[string]float64{
+		"fact":     1.0,
+		"daily":    1.0,
+		"weekly":   1.0,
+		"monthly":  1.0,
+		"document": 1.0,
+		"hygiene":  0.5, // maintenance reports rank below real memories
+	}
+}
+
+// parseSearchTypeWeights parses "type=weight,type=weight" over the defaults.
+// Invalid or negative entries are ignored.
+func parseSearchTypeWeights(s string) map[string]float64 {
+	out := defaultSearchTypeWeights()
+	for _, part := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if k == "" || err != nil || f < 0 {
+			continue
+		}
+		out[k] = f
+	}
+	return out
+}
+
+func searchTypeWeight(cfg Config, typ string) float64 {
+	if w, ok := cfg.SearchTypeWeights[typ]; ok {
+		return w
+	}
+	return 1.0
+}
+
+// weightedScore applies w so that w > 1 always boosts, also for negative (logit) rerank scores.
+func weightedScore(score, w float64) float64 {
+	if w == 1.0 {
+		return score
+	}
+	if score >= 0 || w == 0 {
+		return score * w
+	}
+	return score / w
+}
+
+// applyTypeWeights reweights Score (from base) per hit type and re-sorts by Score.
+func applyTypeWeights(cfg Config, hits []SearchHit, base func(SearchHit) float64) {
+	for i := range hits {
+		hits[i].Score = weightedScore(base(hits[i]), searchTypeWeight(cfg, hits[i].Type))
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+}
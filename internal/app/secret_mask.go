@@ -0,0 +1,168 @@
+package app
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ============================================================
+// Secret masking (accidentally pasted keys / passwords)
+// - maskSecrets replaces obvious secrets with "[REDACTED:<kind>]":
+//     private_key  PEM private key blocks
+//     jwt          eyJ….….…
+//     api_key      well-known prefixes (sk-, ghp_, github_pat_, xoxb-, AKIA…,
+//                  AIza…, glpat-) and "Bearer <token>"
+//     password     "password: …" / "api_key=…" / "密码是…" (value only; after
+//                  "is" / 是 / 为 only when the value has a digit or symbol)
+//     hex          32+ hex chars
+//     base64       40+ base64 chars mixing upper / lower / digits
+// - Applied (TIMELAYER_SECRET_MASK, default on):
+//     LogWriter.WriteRecord  every dialog line before it is persisted; an op
+//                            record "secret_masked" keeps role + kinds only
+//     daily / partial        the raw day again before the summary prompt
+//                            (lines written before masking existed)
+//     chat turn              prompts_log, context audit, retrieval query;
+//                            no fact is captured from a turn that had one
+//     facts                  /remember, 记住：, pending adds and accepts refuse
+//                            a fact carrying one (factBlocked → "blocked")
+// - Only the current turn's model call sees the unmasked text, so the
+//   immediate reply can still use it; nothing of it is kept.
+// ============================================================
+
+const opSecretMasked = "secret_masked"
+
+type secretRule struct {
+	kind  string
+	re    *regexp.Regexp
+	group int // submatch to mask (0 = whole match)
+	keep  func(s string, m []int) bool
+}
+
+var secretRules = []secretRule{
+	{kind: "private_key", re: regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+	{kind: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{kind: "api_key", re: regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{20,}|gh[pousr]_[A-Za-z0-9]{30,}|github_pat_[A-Za-z0-9_]{30,}|xox[abprs]-[A-Za-z0-9-]{10,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_-]{35}|glpat-[A-Za-z0-9_-]{20,})`)},
+	{kind: "api_key", re: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{16,}=*)`), group: 1, keep: keepUnmasked},
+	{
+		kind:  "password",
+		re:    regexp.MustCompile(`(?i)(password|passwd|passphrase|passcode|pwd|api[_-]?key|access[_-]?key|secret[_-]?key|secret|token|密码|口令|密钥)(\s*[:：=]\s*|\s+is\s+|\s*[是为]\s*)([!-~]{4,})`),
+		group: 3,
+		keep:  keepPasswordValue,
+	},
+	{kind: "hex", re: regexp.MustCompile(`\b[0-9a-fA-F]{32,}\b`)},
+	{kind: "base64", re: regexp.MustCompile(`[A-Za-z0-9+/]{40,}={0,2}`), keep: keepBase64},
+}
+
+func secretPlaceholder(kind string) string {
+	return "[REDACTED:" + kind + "]"
+}
+
+// keepUnmasked skips values an earlier rule already replaced.
+func keepUnmasked(s string, m []int) bool {
+	return strings.HasPrefix(s[m[2]:], "[REDACTED:")
+}
+
+// keepPasswordValue: "password is sunshine" is prose, "password is hunter2" is not.
+func keepPasswordValue(s string, m []int) bool {
+	val := s[m[6]:m[7]]
+	if strings.HasPrefix(val, "[REDACTED:") {
+		return true
+	}
+	if strings.ContainsAny(s[m[4]:m[5]], ":：=") {
+		return false
+	}
+	return strings.IndexFunc(val, func(r rune) bool { return !unicode.IsLetter(r) }) < 0
+}
+
+// keepBase64 keeps runs that are not key-like: single-case words, and URL
+// paths ("/2026/10/SomeTitle…" is preceded by '/' or '.').
+func keepBase64(s string, m []int) bool {
+	if m[0] > 0 && (s[m[0]-1] == '/' || s[m[0]-1] == '.') {
+		return true
+	}
+	val := s[m[0]:m[1]]
+	return !(strings.IndexFunc(val, unicode.IsUpper) >= 0 &&
+		strings.IndexFunc(val, unicode.IsLower) >= 0 &&
+		strings.IndexFunc(val, unicode.IsDigit) >= 0)
+}
+
+// maskSecrets returns s with secrets replaced and the kinds found (first
+// occurrence order); s is returned unchanged when there is none.
+func maskSecrets(s string) (string, []string) {
+	var kinds []string
+	for _, r := range secretRules {
+		idx := r.re.FindAllStringSubmatchIndex(s, -1)
+		if len(idx) == 0 {
+			continue
+		}
+		var b strings.Builder
+		last, hit := 0, false
+		for _, m := range idx {
+			if r.keep != nil && r.keep(s, m) {
+				continue
+			}
+			lo, hi := m[2*r.group], m[2*r.group+1]
+			b.WriteString(s[last:lo])
+			b.WriteString(secretPlaceholder(r.kind))
+			last, hit = hi, true
+		}
+		if !hit {
+			continue
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+		if !containsString(kinds, r.kind) {
+			kinds = append(kinds, r.kind)
+		}
+	}
+	return s, kinds
+}
+
+// maskSecretsFor is maskSecrets gated by TIMELAYER_SECRET_MASK.
+func maskSecretsFor(cfg Config, s string) (string, []string) {
+	if !cfg.SecretMask || s == "" {
+		return s, nil
+	}
+	return maskSecrets(s)
+}
+
+// maskSecretsJSONL masks the "content" of every line of already filtered
+// dialog JSONL; raw comes back as is when nothing was masked.
+func maskSecretsJSONL(cfg Config, raw []byte) []byte {
+	if !cfg.SecretMask || len(raw) == 0 {
+		return raw
+	}
+	var out []byte
+	changed := false
+	scanJSONL(raw, func(line []byte) {
+		var m map[string]json.RawMessage
+		var content string
+		if json.Unmarshal(line, &m) == nil && json.Unmarshal(m["content"], &content) == nil {
+			if masked, kinds := maskSecrets(content); len(kinds) > 0 {
+				if c, err := json.Marshal(masked); err == nil {
+					m["content"] = c
+					if b, err := json.Marshal(m); err == nil {
+						line, changed = b, true
+					}
+				}
+			}
+		}
+		out = append(out, line...)
+		out = append(out, '\n')
+	})
+	if !changed {
+		return raw
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
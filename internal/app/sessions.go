@@ -0,0 +1,179 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Conversation sessions (threads within a day)
+// - A session is a named conversation of one user (sessions table). Chat
+//   requests select it with "session" (POST /api/sessions creates one);
+//   turns are logged with "session" (withRecordFields).
+// - Only recent_raw is per session: it replays the session's records of
+//   today instead of the whole day. Records without "session" form the
+//   default stream (CLI, clients that send none).
+// - Everything else stays per day: dailies, facts and search cover all
+//   sessions of the user.
+// - An untitled session takes its title from its first message.
+// ============================================================
+
+const sessionTitleMaxRunes = 40
+
+type Session struct {
+	ID        string `json:"id"`
+	User      string `json:"user,omitempty"`
+	Title     string `json:"title"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// CreateSession starts a new session of cfg.User.
+func CreateSession(cfg Config, db *sql.DB, title string) (Session, error) {
+	now := time.Now().In(cfg.Location).Format(time.RFC3339)
+	s := Session{
+		ID:        newRequestID(),
+		User:      cfg.User,
+		Title:     sessionTitle(title),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	_, err := db.Exec(`
+		INSERT INTO sessions(id, user_id, title, created_at, updated_at)
+		VALUES(?,?,?,?,?)
+	`, s.ID, s.User, s.Title, s.CreatedAt, s.UpdatedAt)
+	return s, err
+}
+
+// LoadSession returns the session id of user.
+func LoadSession(db *sql.DB, user, id string) (Session, error) {
+	s := Session{ID: strings.TrimSpace(id)}
+	err := db.QueryRow(`SELECT user_id, title, created_at, updated_at FROM sessions WHERE id=? AND user_id=?`,
+		s.ID, user).Scan(&s.User, &s.Title, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, fmt.Errorf("unknown session: %s", id)
+	}
+	return s, err
+}
+
+// ListSessions lists user's sessions, most recently used first.
+func ListSessions(db *sql.DB, user string) ([]Session, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, title, created_at, updated_at FROM sessions
+		WHERE user_id=? ORDER BY updated_at DESC, created_at DESC
+	`, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Session
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.User, &s.Title, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// RenameSession sets the title of user's session id.
+func RenameSession(cfg Config, db *sql.DB, user, id, title string) (Session, error) {
+	s, err := LoadSession(db, user, id)
+	if err != nil {
+		return s, err
+	}
+	s.Title = sessionTitle(title)
+	s.UpdatedAt = time.Now().In(cfg.Location).Format(time.RFC3339)
+	_, err = db.Exec(`UPDATE sessions SET title=?, updated_at=? WHERE id=?`, s.Title, s.UpdatedAt, s.ID)
+	return s, err
+}
+
+// DeleteSession removes the session; its logged records stay in the day's log.
+func DeleteSession(db *sql.DB, user, id string) error {
+	res, err := db.Exec(`DELETE FROM sessions WHERE id=? AND user_id=?`, strings.TrimSpace(id), user)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("unknown session: %s", id)
+	}
+	return nil
+}
+
+// sessionTitle collapses whitespace and cuts s to sessionTitleMaxRunes.
+func sessionTitle(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > sessionTitleMaxRunes {
+		s = string(r[:sessionTitleMaxRunes]) + "…"
+	}
+	return s
+}
+
+// applySession selects the session for cfg.User ("" = default stream).
+func applySession(cfg Config, db *sql.DB, id string) (Config, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return cfg, nil
+	}
+	if _, err := LoadSession(db, cfg.User, id); err != nil {
+		return cfg, err
+	}
+	cfg.Session = id
+	return cfg, nil
+}
+
+// touchSession marks the session as used; an untitled one is named after input (best effort).
+func touchSession(cfg Config, db *sql.DB, input string) {
+	if cfg.Session == "" || db == nil {
+		return
+	}
+	now := time.Now().In(cfg.Location).Format(time.RFC3339)
+	title := sessionTitle(input)
+	_, _ = db.Exec(`
+		UPDATE sessions SET updated_at=?, title=CASE WHEN title='' THEN ? ELSE title END
+		WHERE id=?
+	`, now, title, cfg.Session)
+}
+
+// filterSessionJSONL keeps the records of session ("" = records without "session").
+func filterSessionJSONL(b []byte, session string) []byte {
+	var out []byte
+	scanJSONL(b, func(line []byte) {
+		var rec struct {
+			Session string `json:"session"`
+		}
+		if json.Unmarshal(line, &rec) == nil && rec.Session == session {
+			out = append(append(out, line...), '\n')
+		}
+	})
+	return out
+}
+
+// runSessionsCommand implements /sessions (list).
+func runSessionsCommand(cfg Config, db *sql.DB) (string, error) {
+	items, err := ListSessions(db, cfg.User)
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "(no sessions; create one via POST /api/sessions)", nil
+	}
+	var b strings.Builder
+	for _, s := range items {
+		mark := " "
+		if s.ID == cfg.Session {
+			mark = "*"
+		}
+		title := s.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		fmt.Fprintf(&b, "%s %s %s (last used %s)\n", mark, s.ID, title, s.UpdatedAt)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
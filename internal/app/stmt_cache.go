@@ -0,0 +1,89 @@
+package app
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// ============================================================
+// Prepared statement cache (hot queries)
+// - Lookups that run on every chat turn / fact proposal (active facts,
+//   pending count, fact key checks) used to be parsed by SQLite on each call.
+// - stmtQuery / stmtQueryRow prepare the query once per *sql.DB and reuse it;
+//   database/sql re-prepares it lazily on each pool connection it lands on.
+// - Inside withTx the tx is mapped back to its DB so an already cached
+//   statement is bound to the tx (tx.Stmt); otherwise (and for any other
+//   dbTX) it is a plain query.
+// - closeDB closes the cached statements of a DB.
+// ============================================================
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+var (
+	stmtCache sync.Map // stmtKey → *sql.Stmt
+	txOwners  sync.Map // *sql.Tx → *sql.DB (live withTx transactions)
+)
+
+// cachedStmt returns the prepared statement for query on db.
+func cachedStmt(db *sql.DB, query string) (*sql.Stmt, error) {
+	k := stmtKey{db, query}
+	if s, ok := stmtCache.Load(k); ok {
+		return s.(*sql.Stmt), nil
+	}
+	s, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if prev, loaded := stmtCache.LoadOrStore(k, s); loaded {
+		_ = s.Close()
+		return prev.(*sql.Stmt), nil
+	}
+	return s, nil
+}
+
+// stmtFor resolves the cached statement for db (a *sql.DB or a withTx tx).
+func stmtFor(db dbTX, query string) *sql.Stmt {
+	switch t := db.(type) {
+	case *sql.DB:
+		if s, err := cachedStmt(t, query); err == nil {
+			return s
+		}
+	case *sql.Tx:
+		// only reuse: preparing on the DB would wait for the connection this
+		// tx holds (SQLiteMaxOpenConns=1)
+		if owner, ok := txOwners.Load(t); ok {
+			if s, ok := stmtCache.Load(stmtKey{owner.(*sql.DB), query}); ok {
+				return t.Stmt(s.(*sql.Stmt))
+			}
+		}
+	}
+	return nil
+}
+
+func stmtQueryRow(db dbTX, query string, args ...any) *sql.Row {
+	if s := stmtFor(db, query); s != nil {
+		return s.QueryRow(args...)
+	}
+	return db.QueryRow(query, args...)
+}
+
+func stmtQuery(db dbTX, query string, args ...any) (*sql.Rows, error) {
+	if s := stmtFor(db, query); s != nil {
+		return s.Query(args...)
+	}
+	return db.Query(query, args...)
+}
+
+// closeStmts drops and closes the cached statements of db.
+func closeStmts(db *sql.DB) {
+	stmtCache.Range(func(k, v any) bool {
+		if k.(stmtKey).db == db {
+			stmtCache.Delete(k)
+			_ = v.(*sql.Stmt).Close()
+		}
+		return true
+	})
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+// Before/after benchmarks for the prepared statement cache (stmt_cache.go):
+// "query" runs the SQL of each hot lookup through db.Query / db.QueryRow
+// (parsed on every call), "stmt" calls the lookup itself (cached statement).
+//
+//	go test -run '^$' -bench StmtCache -benchmem ./internal/app
+
+const (
+	benchFacts   = 200
+	benchPending = 50
+)
+
+// benchStmtDB opens a temp DB with benchFacts active facts and benchPending pending facts.
+func benchStmtDB(b *testing.B) (Config, *sql.DB) {
+	b.Helper()
+	b.Setenv("HOME", b.TempDir())
+	b.Setenv("XDG_CONFIG_HOME", b.TempDir())
+	cfg := defaultConfig()
+	mustEnsureDirs(cfg)
+	db := mustOpenDB(cfg)
+	b.Cleanup(func() { closeDB(db) })
+
+	for i := 0; i < benchFacts; i++ {
+		if err := RememberFactSilent(cfg, db, fmt.Sprintf("备忘 %d：周%d 带伞", i, i%7)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	for i := 0; i < benchPending; i++ {
+		if err := addPendingFact(cfg, db, fmt.Sprintf("候选 %d：喜欢散步", i), 0.9, "daily", "2026-01-02"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if n := CountPendingFacts(db); n != benchPending {
+		b.Fatalf("seeded %d pending facts, want %d", n, benchPending)
+	}
+	return cfg, db
+}
+
+// anyActiveFactKey returns the key of one seeded fact.
+func anyActiveFactKey(b *testing.B, db *sql.DB) string {
+	b.Helper()
+	var k string
+	if err := db.QueryRow(`SELECT fact_key FROM user_facts WHERE is_active=1 LIMIT 1`).Scan(&k); err != nil {
+		b.Fatal(err)
+	}
+	return k
+}
+
+func BenchmarkStmtCacheLoadActiveUserFacts(b *testing.B) {
+	cfg, db := benchStmtDB(b)
+	b.Run("query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rows, err := db.Query(`
+		SELECT fact
+		FROM user_facts
+		WHERE is_active=1 AND user_id=?
+		ORDER BY updated_at DESC
+		LIMIT ?
+	`, cfg.User, 50)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for rows.Next() {
+				var fact string
+				_ = rows.Scan(&fact)
+			}
+			rows.Close()
+		}
+	})
+	b.Run("stmt", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := loadActiveUserFacts(db, cfg.User, 50); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkStmtCacheCountPendingFacts(b *testing.B) {
+	_, db := benchStmtDB(b)
+	b.Run("query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var n int
+			_ = db.QueryRow(`SELECT COUNT(1) FROM pending_facts WHERE status='pending'`).Scan(&n)
+		}
+	})
+	b.Run("stmt", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CountPendingFacts(db)
+		}
+	})
+}
+
+func BenchmarkStmtCacheHasActiveUserFact(b *testing.B) {
+	_, db := benchStmtDB(b)
+	key := anyActiveFactKey(b, db)
+	b.Run("query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var one int
+			_ = db.QueryRow(`SELECT 1 FROM user_facts WHERE is_active=1 AND fact_key=? LIMIT 1`, key).Scan(&one)
+		}
+	})
+	b.Run("stmt", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if !hasActiveUserFact(db, key) {
+				b.Fatal("fact not found")
+			}
+		}
+	})
+}
+
+func BenchmarkStmtCacheGetActiveUserFactByKey(b *testing.B) {
+	_, db := benchStmtDB(b)
+	key := anyActiveFactKey(b, db)
+	b.Run("query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var fact string
+			_ = db.QueryRow(`SELECT fact FROM user_facts WHERE fact_key=? AND is_active=1 LIMIT 1`, key).Scan(&fact)
+		}
+	})
+	b.Run("stmt", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok := getActiveUserFactByKey(db, key); !ok {
+				b.Fatal("fact not found")
+			}
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Chat stream resume
+// - Mobile clients drop the SSE connection mid-answer. Every streamed chat
+//   turn now gets a generation id, sent first as {"gen":"..."}, and its
+//   deltas are buffered in memory (streamGen).
+// - When the client goes away the model is not cancelled: the turn keeps
+//   generating into the buffer and is logged complete.
+// - GET /api/chat/stream/resume?gen=...&offset=N replays the deltas from
+//   index N (offset counts delta events already received) and follows the
+//   live generation to the end, with the same frames (delta / turn_id /
+//   error / done).
+// - A generation is kept TIMELAYER_HTTP_STREAM_RESUME_SECONDS after the turn
+//   ends, then dropped. 0 turns it off (a dropped client cancels the model,
+//   as before).
+// ============================================================
+
+const (
+	defaultStreamResumeWindow = 2 * time.Minute
+	streamResumeKeepAlive     = 15 * time.Second
+)
+
+type streamGen struct {
+	mu     sync.Mutex
+	deltas []string
+	turnID string
+	errMsg string
+	done   bool
+	wake   chan struct{} // closed (and replaced) on every change
+}
+
+// streamGens maps generation id → *streamGen.
+var streamGens sync.Map
+
+// newStreamGen registers a generation and returns its id.
+func newStreamGen() (string, *streamGen) {
+	id := newRequestID()
+	g := &streamGen{wake: make(chan struct{})}
+	streamGens.Store(id, g)
+	return id, g
+}
+
+func lookupStreamGen(id string) (*streamGen, bool) {
+	g, ok := streamGens.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return g.(*streamGen), true
+}
+
+// signal wakes every follower; the caller holds g.mu.
+func (g *streamGen) signal() {
+	close(g.wake)
+	g.wake = make(chan struct{})
+}
+
+func (g *streamGen) append(delta string) {
+	g.mu.Lock()
+	g.deltas = append(g.deltas, delta)
+	g.signal()
+	g.mu.Unlock()
+}
+
+// finish ends the generation and drops it after window.
+func (g *streamGen) finish(id, turnID, errMsg string, window time.Duration) {
+	g.mu.Lock()
+	g.turnID, g.errMsg, g.done = turnID, errMsg, true
+	g.signal()
+	g.mu.Unlock()
+	time.AfterFunc(window, func() { streamGens.Delete(id) })
+}
+
+// since returns the deltas from offset on, the state, and a channel closed on the next change.
+func (g *streamGen) since(offset int) (deltas []string, turnID, errMsg string, done bool, wake <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if offset < 0 {
+		offset = 0
+	}
+	if offset < len(g.deltas) {
+		deltas = append([]string(nil), g.deltas[offset:]...)
+	}
+	return deltas, g.turnID, g.errMsg, g.done, g.wake
+}
+
+// serveStreamResume writes the rest of generation g to the client, following it until done.
+func serveStreamResume(w http.ResponseWriter, r *http.Request, fl http.Flusher, g *streamGen, offset int) {
+	ka := time.NewTicker(streamResumeKeepAlive)
+	defer ka.Stop()
+	for {
+		deltas, turnID, errMsg, done, wake := g.since(offset)
+		for _, d := range deltas {
+			if err := writeSSE(w, fl, map[string]string{"delta": d}); err != nil {
+				return
+			}
+		}
+		offset += len(deltas)
+		if done {
+			if errMsg != "" {
+				_ = writeSSE(w, fl, map[string]string{"error": errMsg})
+				return
+			}
+			if turnID != "" {
+				_ = writeSSE(w, fl, map[string]string{"turn_id": turnID})
+			}
+			_ = writeSSE(w, fl, map[string]string{"done": "1"})
+			return
+		}
+		select {
+		case <-wake:
+		case <-ka.C:
+			if _, err := w.Write([]byte(":ka\n\n")); err != nil {
+				return
+			}
+			fl.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
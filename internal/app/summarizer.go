@@ -0,0 +1,434 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ============================================================
+// Summarizer strategies (TIMELAYER_SUMMARIZER)
+// - auto (default): LLM; when the LLM call fails, fall back to a pure-Go
+//   extractive summary so the rollup still produces something searchable.
+// - llm: LLM only (a failure fails the job, as before).
+// - extractive: never call the LLM for rollups.
+// - Extractive output carries "degraded": true. In auto mode a bg job of kind
+//   "regen" (period_key "<type>:<key>") is queued to redo it with the LLM;
+//   it stays pending until the LLM answers.
+// - The LLM budget running out is NOT a failure: the job pauses as before.
+// ============================================================
+
+const (
+	summarizerAuto       = "auto"
+	summarizerLLM        = "llm"
+	summarizerExtractive = "extractive"
+
+	jobKindRegen = "regen"
+
+	extractiveMaxTopics     = 8
+	extractiveMaxHighlights = 5
+	extractiveMaxQuestions  = 5
+	extractiveMaxItemRunes  = 120
+	extractiveMaxGram       = 4
+)
+
+// summarizeWithFallback runs the configured strategy for one rollup.
+// degraded reports that the extractive summarizer produced the result.
+func summarizeWithFallback(
+	cfg Config,
+	db *sql.DB,
+	typ, key string,
+	llm func() (string, error),
+	extractive func() (string, error),
+) (out string, degraded bool, err error) {
+	switch cfg.Summarizer {
+	case summarizerExtractive:
+		out, err = extractive()
+		return out, err == nil, err
+	case summarizerLLM:
+		out, err = llm()
+		return out, false, err
+	}
+
+	out, err = llm()
+	if err == nil || errors.Is(err, ErrLLMBudgetExhausted) {
+		return out, false, err
+	}
+	log.Printf("[warn] %s %s: llm summary failed (%v); using extractive fallback", typ, key, err)
+	out, xerr := extractive()
+	if xerr != nil {
+		return "", false, fmt.Errorf("%w (extractive fallback: %v)", err, xerr)
+	}
+	if qerr := enqueueSummaryRegen(cfg, db, typ, key); qerr != nil {
+		log.Printf("[warn] %s %s: schedule regen failed: %v", typ, key, qerr)
+	}
+	return out, true, nil
+}
+
+// enqueueSummaryRegen queues an LLM rebuild of a degraded summary
+// (re-armed even when an earlier regen for the same period is done).
+func enqueueSummaryRegen(cfg Config, db *sql.DB, typ, key string) error {
+	ts := time.Now().In(cfg.Location).Format(time.RFC3339)
+	_, err := db.Exec(`
+		INSERT INTO bg_jobs(kind, period_key, status, attempts, created_at, updated_at)
+		VALUES(?,?, 'pending', 0, ?, ?)
+		ON CONFLICT(kind, period_key) DO UPDATE SET
+		  status='pending', attempts=0, last_error=NULL, updated_at=excluded.updated_at
+	`, jobKindRegen, typ+":"+key, ts, ts)
+	return err
+}
+
+// summaryDegraded reports whether the stored summary was built by the extractive fallback.
+func summaryDegraded(db *sql.DB, typ, key string) bool {
+	var js string
+	if db.QueryRow(`SELECT json FROM summaries WHERE type=? AND period_key=? AND deleted_at IS NULL`,
+		typ, key).Scan(&js) != nil {
+		return false
+	}
+	var obj struct {
+		Degraded bool `json:"degraded"`
+	}
+	return json.Unmarshal([]byte(js), &obj) == nil && obj.Degraded
+}
+
+// summaryNeedsRegen: an existing degraded summary is rebuilt in place only
+// when the LLM is the sole strategy (the regen job runs that way).
+func summaryNeedsRegen(cfg Config, db *sql.DB, typ, key string) bool {
+	return cfg.Summarizer == summarizerLLM && summaryDegraded(db, typ, key)
+}
+
+// runSummaryRegen is the "regen" job: rebuild a degraded summary with the LLM
+// (or a daily / user_daily whose raw log changed, e.g. a redacted message).
+// A no-op when the summary is gone or was already rebuilt.
+func runSummaryRegen(cfg Config, db *sql.DB, periodKey string) error {
+	typ, key, ok := strings.Cut(periodKey, ":")
+	if !ok {
+		return fmt.Errorf("invalid regen key: %s", periodKey)
+	}
+	if !summaryDegraded(db, typ, key) {
+		switch typ {
+		case "daily":
+			if changed, _ := dailySourceChanged(cfg, db, key); !changed {
+				return nil
+			}
+		case summaryTypeUserDaily:
+			if !userDailySourceChanged(cfg, db, key) {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+	cfg.Summarizer = summarizerLLM
+	switch typ {
+	case "daily":
+		return ensureDaily(cfg, db, key, false)
+	case "weekly":
+		return ensureWeekly(cfg, db, key, false)
+	case "monthly":
+		return ensureMonthly(cfg, db, key, false)
+	case summaryTypeUserDaily:
+		return regenUserDaily(cfg, db, key)
+	}
+	return fmt.Errorf("regen not supported for type %s", typ)
+}
+
+// ------------------------------------------------------------
+// Extractive daily: term frequency over the user's own messages
+// ------------------------------------------------------------
+
+var extractiveStopwords = map[string]struct{}{
+	"the": {}, "and": {}, "for": {}, "are": {}, "but": {}, "not": {}, "you": {}, "your": {},
+	"with": {}, "this": {}, "that": {}, "have": {}, "from": {}, "what": {}, "how": {}, "can": {},
+	"was": {}, "were": {}, "will": {}, "would": {}, "could": {}, "should": {}, "about": {},
+	"there": {}, "their": {}, "they": {}, "them": {}, "then": {}, "than": {}, "just": {},
+	"like": {}, "also": {}, "some": {}, "any": {}, "all": {}, "one": {}, "did": {}, "does": {},
+	"its": {}, "it's": {}, "i'm": {}, "don't": {}, "been": {}, "into": {}, "out": {}, "get": {},
+	"我们": {}, "你们": {}, "他们": {}, "什么": {}, "这个": {}, "那个": {}, "可以": {}, "没有": {},
+	"就是": {}, "不是": {}, "怎么": {}, "知道": {}, "觉得": {}, "现在": {}, "今天": {}, "因为": {},
+	"所以": {}, "但是": {}, "如果": {}, "还是": {}, "然后": {}, "已经": {}, "自己": {}, "一个": {},
+	"一下": {}, "这样": {}, "那样": {}, "有没": {}, "是不": {}, "不会": {}, "需要": {}, "帮我": {},
+	"我的": {}, "你的": {}, "一些": {}, "这些": {}, "那些": {}, "的话": {}, "我想": {},
+}
+
+// isIdeographic: CJK letters only (tts.go's isCJK also counts CJK punctuation).
+func isIdeographic(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// extractiveTerms splits text into candidate topic terms:
+// Latin words (>= 3 chars, lowercased) and CJK 2..4-grams (no word segmenter in pure Go).
+func extractiveTerms(text string) []string {
+	var out []string
+	var word []rune
+	var cjk []rune
+	flushWord := func() {
+		if len(word) >= 3 {
+			w := strings.ToLower(string(word))
+			if _, stop := extractiveStopwords[w]; !stop {
+				out = append(out, w)
+			}
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		for n := 2; n <= extractiveMaxGram; n++ {
+			for i := 0; i+n <= len(cjk); i++ {
+				g := string(cjk[i : i+n])
+				if _, stop := extractiveStopwords[g]; !stop {
+					out = append(out, g)
+				}
+			}
+		}
+		cjk = cjk[:0]
+	}
+	for _, r := range text {
+		switch {
+		case isIdeographic(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '-':
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return out
+}
+
+func clipRunes(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
+
+func isQuestion(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasSuffix(s, "?") || strings.HasSuffix(s, "？") ||
+		strings.HasSuffix(s, "吗") || strings.HasSuffix(s, "呢")
+}
+
+// extractiveDailyJSON builds a daily summary (same shape as the LLM's) from dialog JSONL.
+func extractiveDailyJSON(date string, raw []byte) (string, error) {
+	var msgs []string
+	scanJSONL(raw, func(line []byte) {
+		var r RawLine
+		if json.Unmarshal(line, &r) == nil && r.Role == "user" {
+			if c := strings.TrimSpace(r.Content); c != "" {
+				msgs = append(msgs, c)
+			}
+		}
+	})
+	if len(msgs) == 0 {
+		return "", fmt.Errorf("extractive daily %s: no user messages", date)
+	}
+
+	// ---------- term frequency (one count per message) ----------
+	freq := map[string]int{}
+	first := map[string]int{}
+	terms := make([][]string, len(msgs))
+	for i, m := range msgs {
+		seen := map[string]bool{}
+		for _, t := range extractiveTerms(m) {
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			terms[i] = append(terms[i], t)
+			if _, ok := first[t]; !ok {
+				first[t] = len(first)
+			}
+			freq[t]++
+		}
+	}
+
+	// ---------- topics: most frequent terms ----------
+	ranked := make([]string, 0, len(freq))
+	for t := range freq {
+		ranked = append(ranked, t)
+	}
+	// equal frequency: longer first, so "马拉松" wins over "马拉"/"拉松"
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if freq[a] != freq[b] {
+			return freq[a] > freq[b]
+		}
+		if la, lb := len([]rune(a)), len([]rune(b)); la != lb {
+			return la > lb
+		}
+		return first[a] < first[b]
+	})
+	minCount := 2
+	if len(msgs) < 4 {
+		minCount = 1
+	}
+	topics := []string{}
+	overlaps := func(t string) bool {
+		for _, x := range topics {
+			if strings.Contains(x, t) || strings.Contains(t, x) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range ranked {
+		if len(topics) >= extractiveMaxTopics || freq[t] < minCount {
+			break
+		}
+		if !overlaps(t) {
+			topics = append(topics, t)
+		}
+	}
+
+	// ---------- highlights: messages carrying the most frequent terms ----------
+	type scored struct {
+		idx   int
+		score float64
+	}
+	cand := make([]scored, 0, len(msgs))
+	for i := range msgs {
+		s := 0.0
+		for _, t := range terms[i] {
+			s += float64(freq[t] - 1)
+		}
+		if n := len(terms[i]); n > 0 {
+			cand = append(cand, scored{i, s / float64(n+4)}) // mild length normalization
+		}
+	}
+	sort.SliceStable(cand, func(i, j int) bool { return cand[i].score > cand[j].score })
+	if len(cand) > extractiveMaxHighlights {
+		cand = cand[:extractiveMaxHighlights]
+	}
+	sort.Slice(cand, func(i, j int) bool { return cand[i].idx < cand[j].idx }) // chronological
+	highlights := []string{}
+	for _, c := range cand {
+		highlights = append(highlights, clipRunes(msgs[c.idx], extractiveMaxItemRunes))
+	}
+
+	// ---------- open questions: the user's questions ----------
+	questions := []string{}
+	for _, m := range msgs {
+		if len(questions) >= extractiveMaxQuestions {
+			break
+		}
+		if isQuestion(m) {
+			questions = append(questions, clipRunes(m, extractiveMaxItemRunes))
+		}
+	}
+
+	b, err := json.MarshalIndent(map[string]any{
+		"type":                "daily",
+		"date":                date,
+		"topics":              topics,
+		"patterns":            []string{},
+		"open_questions":      questions,
+		"highlights":          highlights,
+		"lowlights":           []string{},
+		"user_facts_explicit": []string{},
+		"degraded":            true,
+	}, "", "  ")
+	return string(b), err
+}
+
+// ------------------------------------------------------------
+// Extractive weekly / monthly: merge the child summaries' lists
+// ------------------------------------------------------------
+
+// rankedUnion counts each distinct item once per child and returns the most
+// frequent first (ties: first appearance), at most n.
+func rankedUnion(children []map[string]any, field string, n int) []string {
+	freq := map[string]int{}
+	var order []string
+	for _, c := range children {
+		seen := map[string]bool{}
+		for _, s := range extractStringList(c[field]) {
+			s = strings.TrimSpace(s)
+			if s == "" || seen[s] {
+				continue
+			}
+			seen[s] = true
+			if freq[s] == 0 {
+				order = append(order, s)
+			}
+			freq[s]++
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return freq[order[i]] > freq[order[j]] })
+	if len(order) > n {
+		order = order[:n]
+	}
+	if order == nil {
+		return []string{}
+	}
+	return order
+}
+
+// firstEach takes up to per items from each child (in child order), at most n.
+func firstEach(children []map[string]any, field string, per, n int) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, c := range children {
+		for i, s := range extractStringList(c[field]) {
+			if i >= per || len(out) >= n {
+				break
+			}
+			s = clipRunes(s, extractiveMaxItemRunes)
+			if s != "" && !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+func extractiveWeeklyJSON(weekStart, weekEnd string, dailies []map[string]any) (string, error) {
+	if len(dailies) == 0 {
+		return "", fmt.Errorf("extractive weekly %s: no daily summaries", weekStart)
+	}
+	b, err := json.MarshalIndent(map[string]any{
+		"type":               "weekly",
+		"week_start":         weekStart,
+		"week_end":           weekEnd,
+		"themes":             rankedUnion(dailies, "topics", extractiveMaxTopics),
+		"progress":           firstEach(dailies, "highlights", 2, 10),
+		"recurring_blockers": rankedUnion(dailies, "lowlights", extractiveMaxQuestions),
+		"notable_decisions":  []string{},
+		"next_week_focus":    rankedUnion(dailies, "open_questions", extractiveMaxQuestions),
+		"degraded":           true,
+	}, "", "  ")
+	return string(b), err
+}
+
+func extractiveMonthlyJSON(monthKey, monthStart, monthEnd string, weeklies []map[string]any) (string, error) {
+	if len(weeklies) == 0 {
+		return "", fmt.Errorf("extractive monthly %s: no weekly summaries", monthKey)
+	}
+	b, err := json.MarshalIndent(map[string]any{
+		"type":                 "monthly",
+		"month":                monthKey,
+		"month_start":          monthStart,
+		"month_end":            monthEnd,
+		"trajectory":           []string{},
+		"top_themes":           rankedUnion(weeklies, "themes", extractiveMaxTopics),
+		"wins":                 firstEach(weeklies, "progress", 3, 10),
+		"losses":               rankedUnion(weeklies, "recurring_blockers", extractiveMaxQuestions),
+		"systems_improvements": []string{},
+		"next_month_bets":      rankedUnion(weeklies, "next_week_focus", extractiveMaxQuestions),
+		"degraded":             true,
+	}, "", "  ")
+	return string(b), err
+}
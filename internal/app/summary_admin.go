@@ -0,0 +1,136 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Summaries admin API
+// - GET    /api/summaries?type=daily&limit=50   list (summary_title.go)
+// - GET    /api/summaries/:type/:key            one summary: json + text
+// - POST   /api/summaries/:type/:key/regenerate the summarizer runs again
+//          (same as /daily <date> --force, /weekly --force, /monthly --force)
+// - DELETE /api/summaries/:type/:key            to the trash (trash.go)
+// - Regenerate accepts daily YYYY-MM-DD, weekly YYYY-Www, monthly YYYY-MM;
+//   it also builds a summary that does not exist yet. A summary in the
+//   trash must be restored first.
+// - ?user= (users.go): "daily" of another user is their user_daily
+//   (<date>@user:<name>); a key of another user is not found.
+// ============================================================
+
+var errSummaryNotFound = errors.New("summary not found")
+
+// SummaryDetail is GET /api/summaries/:type/:key.
+type SummaryDetail struct {
+	SummaryListItem
+	JSON       json.RawMessage `json:"json"`
+	Text       string          `json:"text"`
+	SourcePath string          `json:"source_path,omitempty"`
+	Embedded   bool            `json:"embedded"`
+}
+
+// GetSummary returns the live summary typ/key.
+func GetSummary(db *sql.DB, typ, key string) (*SummaryDetail, error) {
+	var d SummaryDetail
+	var js string
+	var src sql.NullString
+	err := db.QueryRow(`
+		SELECT s.id, s.type, s.period_key, s.start_date, s.end_date, COALESCE(s.title,''), s.created_at,
+		       s.json, s.text, s.source_path, e.summary_id IS NOT NULL
+		FROM summaries s
+		LEFT JOIN embeddings e ON e.summary_id = s.id
+		WHERE s.type=? AND s.period_key=? AND s.deleted_at IS NULL
+	`, typ, key).Scan(&d.ID, &d.Type, &d.PeriodKey, &d.StartDate, &d.EndDate, &d.Title, &d.CreatedAt,
+		&js, &d.Text, &src, &d.Embedded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errSummaryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if json.Valid([]byte(js)) {
+		d.JSON = json.RawMessage(js)
+	} else {
+		b, _ := json.Marshal(js)
+		d.JSON = b
+	}
+	d.SourcePath = src.String
+	return &d, nil
+}
+
+// userSummaryRef maps typ/key as addressed by user to the stored summary:
+// another user's daily is their user_daily, and another user's key is not theirs.
+func userSummaryRef(user, typ, key string) (string, string, error) {
+	if typ == "daily" && user != "" {
+		return summaryTypeUserDaily, userDailyKey(key, user), nil
+	}
+	if keyUser(key) != user {
+		return "", "", errSummaryNotFound
+	}
+	return typ, key, nil
+}
+
+// validSummaryKey reports whether key is a well-formed period of typ.
+func validSummaryKey(cfg Config, typ, key string) bool {
+	switch typ {
+	case "daily":
+		t, err := time.ParseInLocation("2006-01-02", key, cfg.Location)
+		return err == nil && t.Format("2006-01-02") == key
+	case "weekly":
+		y, w := parseWeekKey(key)
+		return w >= 1 && w <= 53 && fmt.Sprintf("%04d-W%02d", y, w) == key
+	case "monthly":
+		t, err := time.ParseInLocation("2006-01", key, cfg.Location)
+		return err == nil && t.Format("2006-01") == key
+	case summaryTypeUserDaily:
+		date, user, ok := strings.Cut(key, userKeySep)
+		return ok && reUserName.MatchString(user) && validSummaryKey(cfg, "daily", date)
+	}
+	return false
+}
+
+// RegenerateSummary rebuilds typ/key with force semantics and returns the result.
+func RegenerateSummary(cfg Config, db *sql.DB, typ, key string) (*SummaryDetail, error) {
+	switch typ {
+	case "daily", "weekly", "monthly", summaryTypeUserDaily:
+	default:
+		return nil, fmt.Errorf("cannot regenerate %s summaries", typ)
+	}
+	if !validSummaryKey(cfg, typ, key) {
+		return nil, fmt.Errorf("invalid %s period key: %s", typ, key)
+	}
+	var trashed int
+	_ = db.QueryRow(
+		`SELECT COUNT(*) FROM summaries WHERE type=? AND period_key=? AND deleted_at IS NOT NULL`, typ, key,
+	).Scan(&trashed)
+	if trashed > 0 {
+		return nil, errors.New("summary is in the trash (restore it first)")
+	}
+
+	cfg = foregroundLLM(cfg)
+	var err error
+	switch typ {
+	case "daily":
+		err = ensureDaily(cfg, db, key, true)
+	case "weekly":
+		err = ensureWeekly(cfg, db, key, true)
+	case "monthly":
+		err = ensureMonthly(cfg, db, key, true)
+	case summaryTypeUserDaily:
+		err = regenUserDaily(cfg, db, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	bumpMemoryVersion()
+	d, err := GetSummary(db, typ, key)
+	if errors.Is(err, errSummaryNotFound) {
+		return nil, fmt.Errorf("%w: nothing to summarize for %s %s", errSummaryNotFound, typ, key)
+	}
+	return d, err
+}
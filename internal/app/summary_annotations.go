@@ -0,0 +1,179 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Summary annotations (user-authored corrections / notes)
+// - Stored in summary_annotations keyed by (type, period_key), so they
+//   survive summary regeneration (/daily --force, stale rebuilds).
+// - Injected next to summaries in chat context with higher authority
+//   than the summaries themselves.
+// - Passed to weekly/monthly rollups as "user_annotations".
+// ============================================================
+
+const maxAnnotationRunes = 2000
+
+type SummaryAnnotation struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	PeriodKey string `json:"period_key"`
+	Note      string `json:"note"`
+	CreatedAt string `json:"created_at"`
+}
+
+// AddSummaryAnnotation attaches a note to an existing summary.
+func AddSummaryAnnotation(cfg Config, db *sql.DB, typ, periodKey, note string) (SummaryAnnotation, error) {
+	a := SummaryAnnotation{
+		Type:      strings.TrimSpace(typ),
+		PeriodKey: strings.TrimSpace(periodKey),
+		Note:      strings.TrimSpace(note),
+		CreatedAt: time.Now().In(cfg.Location).Format(time.RFC3339),
+	}
+	if a.Note == "" {
+		return a, errors.New("empty note")
+	}
+	if len([]rune(a.Note)) > maxAnnotationRunes {
+		return a, fmt.Errorf("note too long (max %d chars)", maxAnnotationRunes)
+	}
+	if ok, err := summaryExists(db, a.Type, a.PeriodKey); err != nil {
+		return a, err
+	} else if !ok {
+		return a, errors.New("summary not found")
+	}
+
+	res, err := db.Exec(`
+		INSERT INTO summary_annotations(type, period_key, note, created_at)
+		VALUES(?,?,?,?)
+	`, a.Type, a.PeriodKey, a.Note, a.CreatedAt)
+	if err != nil {
+		return a, err
+	}
+	a.ID, _ = res.LastInsertId()
+	// annotations change what chat/search context contains
+	bumpMemoryVersion()
+	return a, nil
+}
+
+func ListSummaryAnnotations(db *sql.DB, typ, periodKey string) ([]SummaryAnnotation, error) {
+	rows, err := db.Query(`
+		SELECT id, type, period_key, note, created_at
+		FROM summary_annotations
+		WHERE type=? AND period_key=?
+		ORDER BY id
+	`, typ, periodKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SummaryAnnotation
+	for rows.Next() {
+		var a SummaryAnnotation
+		if err := rows.Scan(&a.ID, &a.Type, &a.PeriodKey, &a.Note, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// annotationNotes returns just the note texts (errors → none).
+func annotationNotes(db *sql.DB, typ, periodKey string) []string {
+	if db == nil {
+		return nil
+	}
+	items, err := ListSummaryAnnotations(db, typ, periodKey)
+	if err != nil {
+		return nil
+	}
+	notes := make([]string, 0, len(items))
+	for _, a := range items {
+		notes = append(notes, a.Note)
+	}
+	return notes
+}
+
+// weekAnnotationNotes collects notes on a weekly and on the dailies inside it (for monthly rollups).
+func weekAnnotationNotes(cfg Config, db *sql.DB, weekStart, weekEnd string) []string {
+	start, err := time.ParseInLocation("2006-01-02", weekStart, cfg.Location)
+	if err != nil {
+		return nil
+	}
+	y, w := start.ISOWeek()
+	notes := annotationNotes(db, "weekly", fmt.Sprintf("%04d-W%02d", y, w))
+
+	rows, err := db.Query(`
+		SELECT period_key, note FROM summary_annotations
+		WHERE type='daily' AND period_key BETWEEN ? AND ?
+		ORDER BY period_key, id
+	`, weekStart, weekEnd)
+	if err != nil {
+		return notes
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day, note string
+		if rows.Scan(&day, &note) == nil {
+			notes = append(notes, day+": "+note)
+		}
+	}
+	return notes
+}
+
+// summaryRef names one summary that was injected into context.
+type summaryRef struct{ Type, Key string }
+
+// buildAnnotationsEvidence renders the notes of the injected summaries as one block ("" = none).
+func buildAnnotationsEvidence(db *sql.DB, refs []summaryRef) string {
+	var b strings.Builder
+	seen := map[summaryRef]bool{}
+	for _, r := range refs {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		for _, n := range annotationNotes(db, r.Type, r.Key) {
+			fmt.Fprintf(&b, "- [%s %s] %s\n", r.Type, r.Key, n)
+		}
+	}
+	return b.String()
+}
+
+// runAnnotateCommand implements /annotate <type> <period_key> <note> and /annotations <type> <period_key>.
+func runAnnotateCommand(cfg Config, db *sql.DB, cmd, arg string) (string, error) {
+	fields := strings.Fields(arg)
+	if cmd == "/annotations" {
+		if len(fields) < 2 {
+			return "usage: /annotations <daily|weekly|monthly> <period_key>", nil
+		}
+		items, err := ListSummaryAnnotations(db, fields[0], fields[1])
+		if err != nil {
+			return "", err
+		}
+		if len(items) == 0 {
+			return "(no annotations)", nil
+		}
+		var b strings.Builder
+		for _, a := range items {
+			fmt.Fprintf(&b, "#%d %s %s\n", a.ID, a.CreatedAt, a.Note)
+		}
+		return strings.TrimRight(b.String(), "\n"), nil
+	}
+
+	if len(fields) < 3 {
+		return "usage: /annotate <daily|weekly|monthly> <period_key> <note>", nil
+	}
+	note := strings.TrimSpace(arg)
+	note = strings.TrimSpace(strings.TrimPrefix(note, fields[0]))
+	note = strings.TrimSpace(strings.TrimPrefix(note, fields[1]))
+	a, err := AddSummaryAnnotation(cfg, db, fields[0], fields[1], note)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[ok] annotation #%d added to %s %s", a.ID, a.Type, a.PeriodKey), nil
+}
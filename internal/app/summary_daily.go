@@ -20,15 +20,13 @@ Daily Summary (FINAL)
 */
 
 func ensureDaily(cfg Config, db *sql.DB, date string, force bool) error {
+	// other users' records get their own user_daily; "daily" is the primary user's (see users.go)
+	ensureUserDailies(cfg, db, date, force)
+	cfg.User = ""
+
 	// ---------- FORCE MODE ----------
 	if force {
-		_, _ = db.Exec(`
-			DELETE FROM embeddings
-			WHERE summary_id IN (
-				SELECT id FROM summaries
-				WHERE type='daily' AND period_key=?
-			)
-		`, date)
+		dropSummaryEmbedding(db, "daily", date)
 
 		_, _ = db.Exec(`
 			DELETE FROM summaries
@@ -39,91 +37,71 @@ func ensureDaily(cfg Config, db *sql.DB, date string, force bool) error {
 	}
 
 	// ---------- IDEMPOTENT CHECK ----------
+	stale := false
 	if !force {
 		if ok, _ := summaryExists(db, "daily", date); ok {
-			// 即使 daily 已存在，也要确保 pending_facts 能被持续补齐
-			if b, err := os.ReadFile(filepath.Join(cfg.LogDir, date+".daily.json")); err == nil {
-				if err := EnsurePendingFactsFromDailyJSON(cfg, db, date, string(b)); err != nil {
-					fmt.Fprintf(os.Stderr, "[warn] pending facts ingest failed: %v\n", err)
+			// 原始日志变更（导入 / 手动编辑）或 degraded（extractive 兜底）待 LLM 重建
+			// → 原地重算，旧 summary 保留到新结果写入
+			stale, _ = dailySourceChanged(cfg, db, date)
+			if !stale && summaryNeedsRegen(cfg, db, "daily", date) {
+				log.Printf("[info] daily %s: degraded summary; regenerating with the llm", date)
+				stale = true
+			}
+			if !stale {
+				ensureSummaryTitle(cfg, db, "daily", date)
+				// 即使 daily 已存在，也要确保 pending_facts 能被持续补齐
+				if b, err := os.ReadFile(filepath.Join(cfg.LogDir, date+".daily.json")); err == nil {
+					if err := EnsurePendingFactsFromDailyJSON(cfg, db, date, string(b)); err != nil {
+						fmt.Fprintf(os.Stderr, "[warn] pending facts ingest failed: %v\n", err)
+					}
 				}
+				return nil
+			}
+			if !summaryDegraded(db, "daily", date) {
+				log.Printf("[info] daily %s: raw log changed since summary was built; regenerating", date)
 			}
-			return nil
 		}
 	}
 
 	logPath := filepath.Join(cfg.LogDir, date+".jsonl")
-	info, err := os.Stat(logPath)
-	if err != nil || info.Size() == 0 {
+
+	// ---------- READ FULL RAW（file 或 messages 表） ----------
+	rawAll, err := readRawDay(cfg, db, date)
+	if err != nil || len(rawAll) == 0 {
 		return nil
 	}
-
-	// ---------- READ FULL RAW ----------
-	rawAll, err := os.ReadFile(logPath)
-	if err != nil {
-		return err
+	rawAll, bad := filterDialogJSONL(rawAll)
+	warnMalformedLines(date, bad)
+	rawAll = filterUserJSONL(rawAll, "")
+	if len(rawAll) == 0 {
+		return nil
 	}
+	sourceHash := sha256Hex(string(rawAll))
+	// lines logged before secret masking existed (the hash stays on the stored bytes)
+	rawAll = maskSecretsJSONL(cfg, rawAll)
 
-	// ---------- SPLIT INTO TOKEN-SAFE CHUNKS ----------
-	chunks := splitJSONLIntoChunks(rawAll, cfg.MaxDailyJSONLBytes)
-
-	var dailyJSON string
+	// ---------- USER FACT EXTRACTION ----------
+	rawLines, _ := loadRawLinesForDate(cfg, db, date)
+	userFacts := ExtractUserFactsFromRaw(rawLines)
 
-	if len(chunks) == 1 {
-		prompt := mustReadPrompt(cfg, "daily.txt")
-		prompt = strings.ReplaceAll(prompt, "{{DATE}}", date)
-		prompt = strings.ReplaceAll(prompt, "{{TRANSCRIPT}}", string(chunks[0]))
+	// ---------- SKIP EMPTY / NOISE-ONLY DAYS (see summary_skip.go) ----------
+	activity := measureDailyActivity(rawAll)
+	skipped := len(userFacts) == 0 && shouldSkipDaily(cfg, activity)
 
-		out, err := callLLMNonStream(cfg, prompt)
-		if err != nil {
-			return err
-		}
-		if !json.Valid([]byte(out)) {
-			return fmt.Errorf("daily llm output is not valid JSON\nraw:\n%s", out)
-		}
-		dailyJSON = out
+	var dailyJSON string
+	if skipped {
+		log.Printf("[info] daily %s: no significant activity (user=%d substantive=%d chars=%d); written without the llm",
+			date, activity.UserMessages, activity.SubstantiveMessages, activity.Chars)
+		dailyJSON, err = noActivityDailyJSON(cfg, date, activity)
 	} else {
-		partials := make([]string, 0, len(chunks))
-
-		for i, c := range chunks {
-			prompt := mustReadPrompt(cfg, "daily.txt")
-			prompt = strings.ReplaceAll(prompt, "{{DATE}}", date)
-
-			transcript := fmt.Sprintf(
-				"【PART %d/%d】\n%s",
-				i+1, len(chunks), string(c),
-			)
-			prompt = strings.ReplaceAll(prompt, "{{TRANSCRIPT}}", transcript)
-
-			out, err := callLLMNonStream(cfg, prompt)
-			if err != nil {
-				return err
-			}
-			if !json.Valid([]byte(out)) {
-				return fmt.Errorf(
-					"daily chunk %d output invalid JSON\nraw:\n%s",
-					i+1, out,
-				)
-			}
-			partials = append(partials, out)
-		}
-
-		mergePrompt := buildDailyMergePrompt(date, partials)
-		merged, err := callLLMNonStream(cfg, mergePrompt)
-		if err != nil {
-			return err
-		}
-		if !json.Valid([]byte(merged)) {
-			return fmt.Errorf(
-				"daily merged output invalid JSON\nraw:\n%s",
-				merged,
-			)
-		}
-		dailyJSON = merged
+		dailyJSON, _, err = summarizeWithFallback(cfg, db, "daily", date,
+			func() (string, error) { return generateDailyJSON(cfg, db, date, rawAll) },
+			func() (string, error) { return extractiveDailyJSON(date, rawAll) },
+		)
+	}
+	if err != nil {
+		return err
 	}
-
-	// ---------- USER FACT EXTRACTION ----------
-	rawLines, _ := loadRawLinesForDate(cfg, date)
-	userFacts := ExtractUserFactsFromRaw(rawLines)
 
 	out, err := buildDailyFinal(dailyJSON, userFacts)
 	if err != nil {
@@ -136,11 +114,8 @@ func ensureDaily(cfg Config, db *sql.DB, date string, force bool) error {
 	}
 
 	// ---------- SUMMARY GUARDS ----------
-	warnings := RunSummaryGuards(db, "daily", out)
-	for _, w := range warnings {
-		// 这里只报警，不中断
-		log.Printf("[SUMMARY %s] %s", w.Type, w.Message)
-	}
+	// 这里只报警，不中断
+	recordSummaryWarnings(cfg, db, "daily", date, RunSummaryGuards(db, "daily", out))
 
 	// ---------- WRITE DAILY FILE ----------
 	outPath := filepath.Join(cfg.LogDir, date+".daily.json")
@@ -165,12 +140,34 @@ func ensureDaily(cfg Config, db *sql.DB, date string, force bool) error {
 	if err != nil {
 		return err
 	}
+	if err := setSummarySourceHash(db, "daily", date, sourceHash); err != nil {
+		log.Printf("[warn] daily %s: store source hash failed: %v", date, err)
+	}
+	deleteDailyPartial(db, date)
+	if stale {
+		_, _ = db.Exec(`UPDATE summaries SET title=NULL WHERE type='daily' AND period_key=?`, date)
+	}
+	if skipped {
+		_, _ = db.Exec(`UPDATE summaries SET title=? WHERE type='daily' AND period_key=?`, noActivityNote(cfg), date)
+	} else {
+		ensureSummaryTitle(cfg, db, "daily", date)
+	}
+	if stale {
+		dropSummaryEmbedding(db, "daily", date)
+	}
 
 	// ---------- EMBEDDING ----------
 	// Best effort (non-fatal) - retrieval still works in degraded mode without new vectors.
 	if err := ensureEmbedding(db, cfg, indexText, "daily", date); err != nil {
 		log.Printf("[warn] ensureEmbedding failed for daily %s: %v", date, err)
 	}
+
+	// ---------- PER-ASSISTANT (optional) ----------
+	if cfg.SummaryPerAssistant && !skipped {
+		if err := ensureAssistantDailies(cfg, db, date, rawAll, force || stale); err != nil {
+			log.Printf("[warn] %v", err)
+		}
+	}
 	return nil
 }
 
@@ -180,27 +177,87 @@ Helpers
 ========================
 */
 
+// generateDailyJSON runs the daily prompt over a day's dialog JSONL
+// (chunked + merged when large) and enforces the output language.
+func generateDailyJSON(cfg Config, db *sql.DB, date string, rawAll []byte) (string, error) {
+	// ---------- SPLIT INTO TOKEN-SAFE CHUNKS ----------
+	chunks := chunkJSONL(cfg, rawAll)
+
+	var dailyJSON string
+
+	if len(chunks) == 1 {
+		prompt := mustReadPrompt(cfg, "daily.txt")
+		prompt = strings.ReplaceAll(prompt, "{{DATE}}", date)
+		prompt = strings.ReplaceAll(prompt, "{{TRANSCRIPT}}", string(chunks[0]))
+
+		out, err := callBackgroundLLM(cfg, db, prompt)
+		if err != nil {
+			return "", err
+		}
+		if !json.Valid([]byte(out)) {
+			return "", fmt.Errorf("daily llm output is not valid JSON\nraw:\n%s", out)
+		}
+		dailyJSON = out
+	} else {
+		partials := make([]string, 0, len(chunks))
+
+		for i, c := range chunks {
+			prompt := mustReadPrompt(cfg, "daily.txt")
+			prompt = strings.ReplaceAll(prompt, "{{DATE}}", date)
+
+			transcript := fmt.Sprintf(
+				"【PART %d/%d】\n%s",
+				i+1, len(chunks), string(c),
+			)
+			prompt = strings.ReplaceAll(prompt, "{{TRANSCRIPT}}", transcript)
+
+			out, err := callBackgroundLLM(cfg, db, prompt)
+			if err != nil {
+				return "", err
+			}
+			if !json.Valid([]byte(out)) {
+				return "", fmt.Errorf(
+					"daily chunk %d output invalid JSON\nraw:\n%s",
+					i+1, out,
+				)
+			}
+			partials = append(partials, out)
+		}
+
+		merged, err := reducePartials(cfg, db, "daily", partials, func(g []string) string {
+			return buildDailyMergePrompt(cfg, date, g)
+		})
+		if err != nil {
+			return "", err
+		}
+		dailyJSON = merged
+	}
+
+	// ---------- OUTPUT LANGUAGE ----------
+	return enforceOutputLanguage(cfg, db, "daily", dailyJSON)
+}
+
 // -------- raw lines (for user facts) --------
 
-func loadRawLinesForDate(cfg Config, date string) ([]RawLine, error) {
-	path := filepath.Join(cfg.LogDir, date+".jsonl")
-	b, err := os.ReadFile(path)
+func loadRawLinesForDate(cfg Config, db *sql.DB, date string) ([]RawLine, error) {
+	b, err := readRawDay(cfg, db, date)
 	if err != nil {
 		return nil, err
 	}
 
 	var lines []RawLine
-	for _, line := range strings.Split(string(b), "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+	bad := scanJSONL(b, func(line []byte) {
+		if isLegacyOpLine(line) || isRedactedLine(line) {
+			return
 		}
-
 		var r RawLine
-		if err := json.Unmarshal([]byte(line), &r); err == nil {
+		// external events are context, never a source of user facts
+		if err := json.Unmarshal(line, &r); err == nil && r.Role != roleEvent && r.User == cfg.User {
+			r.Content, _ = maskSecretsFor(cfg, r.Content)
 			lines = append(lines, r)
 		}
-	}
+	})
+	warnMalformedLines(date, bad)
 
 	return lines, nil
 }
@@ -333,7 +390,7 @@ func splitJSONLIntoChunks(raw []byte, maxBytes int64) [][]byte {
 
 // -------- merge prompt --------
 
-func buildDailyMergePrompt(date string, partials []string) string {
+func buildDailyMergePrompt(cfg Config, date string, partials []string) string {
 	var b strings.Builder
 
 	b.WriteString("You are a strict daily summary reducer.\n")
@@ -343,7 +400,9 @@ func buildDailyMergePrompt(date string, partials []string) string {
 	b.WriteString("- Output JSON only.\n")
 	b.WriteString("- Do NOT add new facts.\n")
 	b.WriteString("- Do NOT infer user identity.\n")
-	b.WriteString("- Deduplicate and merge semantically.\n\n")
+	b.WriteString("- Deduplicate and merge semantically.\n")
+	b.WriteString(outputLanguageRule(cfg))
+	b.WriteString("\n")
 
 	b.WriteString("OUTPUT FORMAT (JSON only):\n")
 	b.WriteString("{\n")
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+========================
+Partial Daily Summary（今天到目前为止）
+- /daily --partial: type="daily_partial", period_key=date, upserted in place
+- no embedding → never a search hit, never read by weekly rollup
+- injected into chat context only for that same day, when no final daily exists
+- removed once the final daily for the day is written
+========================
+*/
+
+const summaryTypeDailyPartial = "daily_partial"
+
+// ensureDailyPartial (re)builds the intraday summary for date.
+// It is a no-op when the dialog has not changed since the last partial.
+func ensureDailyPartial(cfg Config, db *sql.DB, date string) (bool, error) {
+	cfg.User = "" // the partial is the primary user's, like "daily"
+	rawAll, err := readRawDay(cfg, db, date)
+	if err != nil || len(rawAll) == 0 {
+		return false, nil
+	}
+	rawAll, bad := filterDialogJSONL(rawAll)
+	warnMalformedLines(date, bad)
+	rawAll = filterUserJSONL(rawAll, "")
+	if len(rawAll) == 0 {
+		return false, nil
+	}
+
+	sourceHash := sha256Hex(string(rawAll))
+	var prevHash string
+	_ = db.QueryRow(
+		`SELECT COALESCE(source_hash,'') FROM summaries WHERE type=? AND period_key=?`,
+		summaryTypeDailyPartial, date,
+	).Scan(&prevHash)
+	if prevHash == sourceHash {
+		return false, nil
+	}
+
+	dailyJSON, err := generateDailyJSON(cfg, db, date, maskSecretsJSONL(cfg, rawAll))
+	if err != nil {
+		return false, err
+	}
+	rawLines, _ := loadRawLinesForDate(cfg, db, date)
+	out, err := buildDailyFinal(dailyJSON, ExtractUserFactsFromRaw(rawLines))
+	if err != nil {
+		return false, err
+	}
+
+	// pending facts are NOT ingested here; the final daily does that once.
+	if _, err := upsertSummary(
+		db, cfg, summaryTypeDailyPartial, date, date, date,
+		out, extractIndexText(out), filepath.Join(cfg.LogDir, date+".jsonl"),
+	); err != nil {
+		return false, err
+	}
+	if err := setSummarySourceHash(db, summaryTypeDailyPartial, date, sourceHash); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// loadDailyPartialSummary returns the partial summary JSON for date ("" if none).
+func loadDailyPartialSummary(db *sql.DB, date string) string {
+	if db == nil {
+		return ""
+	}
+	var js string
+	err := db.QueryRow(
+		`SELECT json FROM summaries WHERE type=? AND period_key=?`,
+		summaryTypeDailyPartial, date,
+	).Scan(&js)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return ""
+	}
+	return strings.TrimSpace(js)
+}
+
+func deleteDailyPartial(db *sql.DB, date string) {
+	_, _ = db.Exec(`DELETE FROM summaries WHERE type=? AND period_key=?`, summaryTypeDailyPartial, date)
+}
+
+// runDailyPartial is the shared /daily --partial handler.
+func runDailyPartial(cfg Config, db *sql.DB, date string) (string, error) {
+	if ok, _ := summaryExists(db, "daily", date); ok {
+		return "[ok] final daily summary already exists: " + date, nil
+	}
+	updated, err := ensureDailyPartial(cfg, db, date)
+	if err != nil {
+		return "", err
+	}
+	if !updated {
+		return "[ok] partial daily summary up to date: " + date, nil
+	}
+	return fmt.Sprintf("[ok] partial daily summary updated: %s (as of %s)",
+		date, time.Now().In(cfg.Location).Format("15:04")), nil
+}
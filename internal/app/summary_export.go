@@ -0,0 +1,311 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ============================================================
+// Summary export (/export_summary, GET /api/export/summaries)
+// - Renders the structured daily/weekly/monthly JSON as Markdown,
+//   following each type's schema (prompts.go) field by field.
+// - Single period, or a combined "year in review" (monthly by default).
+// - Soft-deleted summaries are never exported.
+// ============================================================
+
+type summaryField struct {
+	Key     string
+	Heading string
+}
+
+// summaryExportFields lists the schema fields of each summary type, in display order.
+var summaryExportFields = map[string][]summaryField{
+	"daily": {
+		{"topics", "Topics"},
+		{"highlights", "Highlights"},
+		{"lowlights", "Lowlights"},
+		{"patterns", "Patterns"},
+		{"open_questions", "Open questions"},
+		{"user_facts_explicit", "Facts stated"},
+	},
+	"weekly": {
+		{"themes", "Themes"},
+		{"progress", "Progress"},
+		{"notable_decisions", "Decisions"},
+		{"recurring_blockers", "Recurring blockers"},
+		{"next_week_focus", "Next week focus"},
+	},
+	"monthly": {
+		{"trajectory", "Trajectory"},
+		{"top_themes", "Top themes"},
+		{"wins", "Wins"},
+		{"losses", "Losses"},
+		{"systems_improvements", "Systems improvements"},
+		{"next_month_bets", "Next month bets"},
+	},
+}
+
+// summaryMetaKeys are period/bookkeeping fields that are shown in the heading, not as sections.
+var summaryMetaKeys = map[string]bool{
+	"type": true, "date": true, "week_start": true, "week_end": true,
+	"month": true, "month_start": true, "month_end": true,
+	"user_facts_implicit": true, "user_annotations": true,
+}
+
+type exportSummary struct {
+	Type      string
+	PeriodKey string
+	StartDate string
+	EndDate   string
+	Title     string
+	JSON      string
+}
+
+// loadExportSummaries returns non-deleted summaries of typ whose start_date is within [from, to].
+func loadExportSummaries(db *sql.DB, typ, from, to string) ([]exportSummary, error) {
+	rows, err := readDB(db).Query(`
+		SELECT type, period_key, start_date, end_date, COALESCE(title,''), json
+		FROM summaries
+		WHERE type=? AND start_date>=? AND start_date<=? AND deleted_at IS NULL
+		ORDER BY start_date, period_key`, typ, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []exportSummary
+	for rows.Next() {
+		var s exportSummary
+		if err := rows.Scan(&s.Type, &s.PeriodKey, &s.StartDate, &s.EndDate, &s.Title, &s.JSON); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// markdownItem renders one list entry; objects become "k: v · k: v" in key order.
+func markdownItem(v any) string {
+	switch x := v.(type) {
+	case string:
+		return strings.TrimSpace(x)
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var parts []string
+		for _, k := range keys {
+			if s := markdownItem(x[k]); s != "" {
+				parts = append(parts, k+": "+s)
+			}
+		}
+		return strings.Join(parts, " · ")
+	case []any:
+		var parts []string
+		for _, it := range x {
+			if s := markdownItem(it); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	case nil:
+		return ""
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+func writeMarkdownSection(b *strings.Builder, level, heading string, v any) {
+	var items []string
+	if list, ok := v.([]any); ok {
+		for _, it := range list {
+			if s := markdownItem(it); s != "" {
+				items = append(items, s)
+			}
+		}
+	} else if s := markdownItem(v); s != "" {
+		items = []string{s}
+	}
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n%s %s\n\n", level, heading)
+	for _, it := range items {
+		b.WriteString("- " + strings.ReplaceAll(it, "\n", " ") + "\n")
+	}
+}
+
+// humanizeKey turns "open_questions" into "Open questions" for fields outside the schema.
+func humanizeKey(k string) string {
+	k = strings.ReplaceAll(k, "_", " ")
+	if k == "" {
+		return k
+	}
+	return strings.ToUpper(k[:1]) + k[1:]
+}
+
+func summaryPeriodLabel(s exportSummary) string {
+	if s.StartDate == s.EndDate || s.EndDate == "" {
+		return s.PeriodKey
+	}
+	return fmt.Sprintf("%s (%s – %s)", s.PeriodKey, s.StartDate, s.EndDate)
+}
+
+// renderSummaryMarkdown renders one summary; level is the heading level of the title ("#" or "##").
+func renderSummaryMarkdown(db *sql.DB, s exportSummary, level string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s · %s\n", level, humanizeKey(s.Type), summaryPeriodLabel(s))
+	if s.Title != "" {
+		fmt.Fprintf(&b, "\n_%s_\n", s.Title)
+	}
+
+	sub := level + "#"
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(s.JSON), &obj); err != nil {
+		// not structured: keep the raw text readable rather than dropping it
+		fmt.Fprintf(&b, "\n```\n%s\n```\n", strings.TrimSpace(s.JSON))
+		return b.String()
+	}
+
+	known := map[string]bool{}
+	for _, f := range summaryExportFields[s.Type] {
+		known[f.Key] = true
+		writeMarkdownSection(&b, sub, f.Heading, obj[f.Key])
+	}
+	// fields the schema doesn't list (older prompts / custom prompts)
+	var extra []string
+	for k := range obj {
+		if !known[k] && !summaryMetaKeys[k] {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		writeMarkdownSection(&b, sub, humanizeKey(k), obj[k])
+	}
+
+	if db != nil {
+		var notes []any
+		for _, n := range annotationNotes(db, s.Type, s.PeriodKey) {
+			notes = append(notes, n)
+		}
+		writeMarkdownSection(&b, sub, "Your notes", notes)
+	}
+	return b.String()
+}
+
+// ExportSummaryMarkdown renders a single summary (type + period_key) as Markdown.
+func ExportSummaryMarkdown(db *sql.DB, typ, key string) (string, error) {
+	typ, key = strings.TrimSpace(typ), strings.TrimSpace(key)
+	if _, ok := summaryExportFields[typ]; !ok {
+		return "", fmt.Errorf("unknown summary type: %s (daily|weekly|monthly)", typ)
+	}
+	var s exportSummary
+	err := db.QueryRow(`
+		SELECT type, period_key, start_date, end_date, COALESCE(title,''), json
+		FROM summaries WHERE type=? AND period_key=? AND deleted_at IS NULL`, typ, key,
+	).Scan(&s.Type, &s.PeriodKey, &s.StartDate, &s.EndDate, &s.Title, &s.JSON)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("summary not found: %s %s", typ, key)
+	}
+	if err != nil {
+		return "", err
+	}
+	return renderSummaryMarkdown(db, s, "#"), nil
+}
+
+// ExportYearInReview combines every summary of typ (default monthly) that starts in year.
+func ExportYearInReview(db *sql.DB, year, typ string) (string, error) {
+	year = strings.TrimSpace(year)
+	if len(year) != 4 || strings.Trim(year, "0123456789") != "" {
+		return "", fmt.Errorf("invalid year: %s", year)
+	}
+	if typ = strings.TrimSpace(typ); typ == "" {
+		typ = "monthly"
+	}
+	if _, ok := summaryExportFields[typ]; !ok {
+		return "", fmt.Errorf("unknown summary type: %s (daily|weekly|monthly)", typ)
+	}
+	items, err := loadExportSummaries(db, typ, year+"-01-01", year+"-12-31")
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "", fmt.Errorf("no %s summaries in %s", typ, year)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s in review\n\n", year)
+	fmt.Fprintf(&b, "_%d %s summaries_\n\n", len(items), typ)
+	for _, s := range items {
+		label := s.PeriodKey
+		if s.Title != "" {
+			label += " — " + s.Title
+		}
+		fmt.Fprintf(&b, "- %s\n", label)
+	}
+	for _, s := range items {
+		b.WriteString("\n---\n\n")
+		b.WriteString(renderSummaryMarkdown(db, s, "##"))
+	}
+	return b.String(), nil
+}
+
+// runExportSummaryCommand implements:
+//
+//	/export_summary <daily|weekly|monthly> <period_key>
+//	/export_summary --year 2026 [--type monthly|weekly|daily]
+//
+// and writes the Markdown to <BaseDir>/exports/.
+func runExportSummaryCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	const usage = "usage: /export_summary <daily|weekly|monthly> <period_key> | /export_summary --year YYYY [--type monthly|weekly|daily]"
+	var year, typ string
+	var rest []string
+	fields := strings.Fields(arg)
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "--year" && i+1 < len(fields):
+			year = fields[i+1]
+			i++
+		case fields[i] == "--type" && i+1 < len(fields):
+			typ = fields[i+1]
+			i++
+		default:
+			rest = append(rest, fields[i])
+		}
+	}
+
+	var out, name string
+	var err error
+	switch {
+	case year != "":
+		out, err = ExportYearInReview(db, year, typ)
+		if typ == "" {
+			typ = "monthly"
+		}
+		name = "summary_" + year + "_" + typ + "_review"
+	case len(rest) == 2:
+		out, err = ExportSummaryMarkdown(db, rest[0], rest[1])
+		name = "summary_" + rest[0] + "_" + rest[1]
+	default:
+		return usage, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(cfg.BaseDir, "exports")
+	_ = os.MkdirAll(dir, 0755)
+	path := filepath.Join(dir, name+".md")
+	if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+		return "", err
+	}
+	return "[ok] exported: " + path, nil
+}
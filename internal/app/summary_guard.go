@@ -3,7 +3,9 @@ package app
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"strings"
+	"time"
 )
 
 /*
@@ -48,6 +50,19 @@ func RunSummaryGuards(
 	return warnings
 }
 
+// recordSummaryWarnings logs the warnings and keeps them in summary_warnings
+// (counted by the monthly hygiene report).
+func recordSummaryWarnings(cfg Config, db *sql.DB, summaryType, periodKey string, warnings []SummaryWarning) {
+	ts := time.Now().In(cfg.Location).Format(time.RFC3339)
+	for _, w := range warnings {
+		log.Printf("[SUMMARY %s] %s", w.Type, w.Message)
+		_, _ = db.Exec(`
+			INSERT INTO summary_warnings(summary_type, period_key, level, warning_type, message, created_at)
+			VALUES(?,?,?,?,?,?)
+		`, summaryType, periodKey, w.Level, w.Type, w.Message, ts)
+	}
+}
+
 // ========================
 // Fact Conflict Detection
 // ========================
@@ -55,10 +70,11 @@ func RunSummaryGuards(
 func detectFactConflicts(db *sql.DB, claims []string) []SummaryWarning {
 	var warnings []SummaryWarning
 
+	// guarded summaries are the primary user's (users.go)
 	rows, err := db.Query(`
 		SELECT fact
 		FROM user_facts
-		WHERE is_active=1
+		WHERE is_active=1 AND user_id=''
 	`)
 	if err != nil {
 		return warnings
@@ -28,13 +28,7 @@ periodKey = YYYY-MM
 func ensureMonthly(cfg Config, db *sql.DB, monthKey string, force bool) error {
 	// ---------- FORCE MODE ----------
 	if force {
-		_, _ = db.Exec(`
-			DELETE FROM embeddings
-			WHERE summary_id IN (
-				SELECT id FROM summaries
-				WHERE type='monthly' AND period_key=?
-			)
-		`, monthKey)
+		dropSummaryEmbedding(db, "monthly", monthKey)
 
 		_, _ = db.Exec(`
 			DELETE FROM summaries
@@ -45,9 +39,14 @@ func ensureMonthly(cfg Config, db *sql.DB, monthKey string, force bool) error {
 	}
 
 	// ---------- IDEMPOTENT CHECK ----------
+	// degraded（extractive 兜底）的 summary 在 LLM-only 模式下原地重建
+	regen := false
 	if !force {
 		if ok, _ := summaryExists(db, "monthly", monthKey); ok {
-			return nil
+			if !summaryNeedsRegen(cfg, db, "monthly", monthKey) {
+				return nil
+			}
+			regen = true
 		}
 	}
 
@@ -91,6 +90,11 @@ func ensureMonthly(cfg Config, db *sql.DB, monthKey string, force bool) error {
 			"notable_decisions":  obj["notable_decisions"],
 			"next_week_focus":    obj["next_week_focus"],
 		}
+		ws, _ := obj["week_start"].(string)
+		we, _ := obj["week_end"].(string)
+		if notes := weekAnnotationNotes(cfg, db, ws, we); len(notes) > 0 {
+			slim["user_annotations"] = notes
+		}
 		slimmed = append(slimmed, slim)
 	}
 
@@ -99,78 +103,17 @@ func ensureMonthly(cfg Config, db *sql.DB, monthKey string, force bool) error {
 		return fmt.Errorf("monthly marshal slimmed weeklies failed: %w", err)
 	}
 
-	// ---------- SPLIT IF NEEDED ----------
-	chunks := splitJSONBytes(rawBytes, cfg.MaxDailyJSONLBytes)
-
-	var monthlyJSON string
-
-	if len(chunks) == 1 {
-		prompt := mustReadPrompt(cfg, "monthly.txt")
-		prompt = strings.ReplaceAll(prompt, "{{MONTH}}", monthKey)
-		prompt = strings.ReplaceAll(prompt, "{{MONTH_START}}", monthStart)
-		prompt = strings.ReplaceAll(prompt, "{{MONTH_END}}", monthEnd)
-		prompt = strings.ReplaceAll(prompt, "{{WEEKLY_JSON_ARRAY}}", string(chunks[0]))
-
-		out, err := callLLMNonStream(cfg, prompt)
-		if err != nil {
-			return err
-		}
-		out = strings.TrimSpace(out)
-		if out == "" {
-			return fmt.Errorf("monthly llm output is empty")
-		}
-		if !json.Valid([]byte(out)) {
-			return fmt.Errorf("monthly llm output invalid JSON\nraw:\n%s", out)
-		}
-		monthlyJSON = out
-	} else {
-		partials := make([]string, 0, len(chunks))
-
-		for i, c := range chunks {
-			prompt := mustReadPrompt(cfg, "monthly.txt")
-			prompt = strings.ReplaceAll(prompt, "{{MONTH}}", monthKey)
-			prompt = strings.ReplaceAll(prompt, "{{MONTH_START}}", monthStart)
-			prompt = strings.ReplaceAll(prompt, "{{MONTH_END}}", monthEnd)
-			prompt = strings.ReplaceAll(
-				prompt,
-				"{{WEEKLY_JSON_ARRAY}}",
-				fmt.Sprintf("/* PART %d/%d */\n%s", i+1, len(chunks), string(c)),
-			)
-
-			out, err := callLLMNonStream(cfg, prompt)
-			if err != nil {
-				return err
-			}
-			out = strings.TrimSpace(out)
-			if out == "" {
-				return fmt.Errorf("monthly chunk %d empty", i+1)
-			}
-			if !json.Valid([]byte(out)) {
-				return fmt.Errorf("monthly chunk %d invalid JSON\nraw:\n%s", i+1, out)
-			}
-			partials = append(partials, out)
-		}
-
-		mergePrompt := buildMonthlyMergePrompt(monthKey, monthStart, monthEnd, partials)
-		merged, err := callLLMNonStream(cfg, mergePrompt)
-		if err != nil {
-			return err
-		}
-		merged = strings.TrimSpace(merged)
-		if merged == "" {
-			return fmt.Errorf("monthly merged output empty")
-		}
-		if !json.Valid([]byte(merged)) {
-			return fmt.Errorf("monthly merged output invalid JSON\nraw:\n%s", merged)
-		}
-		monthlyJSON = merged
+	// ---------- SUMMARIZE (LLM; extractive fallback, see summarizer.go) ----------
+	monthlyJSON, _, err := summarizeWithFallback(cfg, db, "monthly", monthKey,
+		func() (string, error) { return generateMonthlyJSON(cfg, db, monthKey, monthStart, monthEnd, rawBytes) },
+		func() (string, error) { return extractiveMonthlyJSON(monthKey, monthStart, monthEnd, slimmed) },
+	)
+	if err != nil {
+		return err
 	}
 
 	// ---------- ⭐ SUMMARY GUARDS ----------
-	warnings := RunSummaryGuards(db, "monthly", monthlyJSON)
-	for _, w := range warnings {
-		log.Printf("[SUMMARY %s] %s", w.Type, w.Message)
-	}
+	recordSummaryWarnings(cfg, db, "monthly", monthKey, RunSummaryGuards(db, "monthly", monthlyJSON))
 
 	// ---------- WRITE FILE ----------
 	outPath := filepath.Join(cfg.LogDir, monthKey+".monthly.json")
@@ -195,6 +138,9 @@ func ensureMonthly(cfg Config, db *sql.DB, monthKey string, force bool) error {
 	if err != nil {
 		return err
 	}
+	if regen {
+		dropSummaryEmbedding(db, "monthly", monthKey)
+	}
 
 	// ---------- ⭐ EMBEDDING DRIFT GUARD ----------
 	{
@@ -266,7 +212,7 @@ func collectWeeklySummariesForMonth(cfg Config, monthKey string) []string {
 	return out
 }
 
-func buildMonthlyMergePrompt(monthKey, monthStart, monthEnd string, partials []string) string {
+func buildMonthlyMergePrompt(cfg Config, monthKey, monthStart, monthEnd string, partials []string) string {
 	var b strings.Builder
 
 	b.WriteString("You are a strict monthly summary reducer.\n")
@@ -276,7 +222,9 @@ func buildMonthlyMergePrompt(monthKey, monthStart, monthEnd string, partials []s
 	b.WriteString("- Output JSON only.\n")
 	b.WriteString("- Do NOT add new facts.\n")
 	b.WriteString("- Do NOT infer user identity.\n")
-	b.WriteString("- Deduplicate and merge semantically.\n\n")
+	b.WriteString("- Deduplicate and merge semantically.\n")
+	b.WriteString(outputLanguageRule(cfg))
+	b.WriteString("\n")
 
 	b.WriteString("OUTPUT FORMAT (JSON only):\n")
 	b.WriteString("{\n")
@@ -301,3 +249,71 @@ func buildMonthlyMergePrompt(monthKey, monthStart, monthEnd string, partials []s
 
 	return b.String()
 }
+
+// generateMonthlyJSON runs the monthly prompt over the slimmed weekly array
+// (chunked + merged when large) and enforces the output language.
+func generateMonthlyJSON(cfg Config, db *sql.DB, monthKey, monthStart, monthEnd string, rawBytes []byte) (string, error) {
+	// ---------- SPLIT IF NEEDED ----------
+	chunks := chunkJSONArray(cfg, rawBytes)
+
+	var monthlyJSON string
+
+	if len(chunks) == 1 {
+		prompt := mustReadPrompt(cfg, "monthly.txt")
+		prompt = strings.ReplaceAll(prompt, "{{MONTH}}", monthKey)
+		prompt = strings.ReplaceAll(prompt, "{{MONTH_START}}", monthStart)
+		prompt = strings.ReplaceAll(prompt, "{{MONTH_END}}", monthEnd)
+		prompt = strings.ReplaceAll(prompt, "{{WEEKLY_JSON_ARRAY}}", string(chunks[0]))
+
+		out, err := callBackgroundLLM(cfg, db, prompt)
+		if err != nil {
+			return "", err
+		}
+		out = strings.TrimSpace(out)
+		if out == "" {
+			return "", fmt.Errorf("monthly llm output is empty")
+		}
+		if !json.Valid([]byte(out)) {
+			return "", fmt.Errorf("monthly llm output invalid JSON\nraw:\n%s", out)
+		}
+		monthlyJSON = out
+	} else {
+		partials := make([]string, 0, len(chunks))
+
+		for i, c := range chunks {
+			prompt := mustReadPrompt(cfg, "monthly.txt")
+			prompt = strings.ReplaceAll(prompt, "{{MONTH}}", monthKey)
+			prompt = strings.ReplaceAll(prompt, "{{MONTH_START}}", monthStart)
+			prompt = strings.ReplaceAll(prompt, "{{MONTH_END}}", monthEnd)
+			prompt = strings.ReplaceAll(
+				prompt,
+				"{{WEEKLY_JSON_ARRAY}}",
+				fmt.Sprintf("/* PART %d/%d */\n%s", i+1, len(chunks), string(c)),
+			)
+
+			out, err := callBackgroundLLM(cfg, db, prompt)
+			if err != nil {
+				return "", err
+			}
+			out = strings.TrimSpace(out)
+			if out == "" {
+				return "", fmt.Errorf("monthly chunk %d empty", i+1)
+			}
+			if !json.Valid([]byte(out)) {
+				return "", fmt.Errorf("monthly chunk %d invalid JSON\nraw:\n%s", i+1, out)
+			}
+			partials = append(partials, out)
+		}
+
+		merged, err := reducePartials(cfg, db, "monthly", partials, func(g []string) string {
+			return buildMonthlyMergePrompt(cfg, monthKey, monthStart, monthEnd, g)
+		})
+		if err != nil {
+			return "", err
+		}
+		monthlyJSON = merged
+	}
+
+	// ---------- OUTPUT LANGUAGE ----------
+	return enforceOutputLanguage(cfg, db, "monthly", monthlyJSON)
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ============================================================
+// Map-reduce merge of chunk partials (daily / weekly / monthly)
+// - Deterministic pre-merge first: an array entry already present in an
+//   earlier partial is dropped from later ones; partials left with nothing
+//   new are dropped. One survivor = no LLM call at all.
+// - Then a hierarchical reduce: partials are packed in order into groups
+//   that fit ChunkMaxTokens (pairs when the budget is unknown), each group
+//   is merged by one LLM call, and the round repeats until one is left.
+//   Every merge prompt stays bounded no matter how many chunks a day has.
+// ============================================================
+
+// premergePartials unions identical array entries across partials (first occurrence wins).
+func premergePartials(partials []string) []string {
+	seen := map[string]map[string]bool{} // field -> canonical entry JSON
+	out := make([]string, 0, len(partials))
+	for _, p := range partials {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(p), &obj); err != nil {
+			out = append(out, p) // not an object: leave it to the LLM
+			continue
+		}
+		hasArray, kept := false, 0
+		for field, v := range obj {
+			arr, ok := v.([]any)
+			if !ok {
+				continue
+			}
+			hasArray = true
+			if seen[field] == nil {
+				seen[field] = map[string]bool{}
+			}
+			uniq := make([]any, 0, len(arr))
+			for _, it := range arr {
+				b, err := json.Marshal(it)
+				if err != nil {
+					continue
+				}
+				k := string(b)
+				if s, ok := it.(string); ok {
+					k = strings.TrimSpace(s)
+				}
+				if k == "" || seen[field][k] {
+					continue
+				}
+				seen[field][k] = true
+				uniq = append(uniq, it)
+			}
+			obj[field] = uniq
+			kept += len(uniq)
+		}
+		if hasArray && kept == 0 && len(out) > 0 {
+			continue // nothing this partial adds
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			out = append(out, p)
+			continue
+		}
+		out = append(out, string(b))
+	}
+	return out
+}
+
+// groupPartialsForMerge packs partials (in order) into groups whose estimated
+// size fits cfg.ChunkMaxTokens; every group has at least two members except a
+// trailing leftover, so each round makes progress.
+func groupPartialsForMerge(cfg Config, partials []string) [][]string {
+	budget := cfg.ChunkMaxTokens
+	var groups [][]string
+	var cur []string
+	curTokens := 0
+	for _, p := range partials {
+		n := cfg.ChunkCharsPerToken.Estimate(p)
+		full := len(cur) >= 2 && (budget <= 0 || curTokens+n > budget)
+		if full {
+			groups = append(groups, cur)
+			cur, curTokens = nil, 0
+		}
+		cur = append(cur, p)
+		curTokens += n
+	}
+	if len(cur) > 0 {
+		groups = append(groups, cur)
+	}
+	return groups
+}
+
+// reducePartials merges chunk partials into one JSON summary.
+// build renders the merge prompt for a group; what names the pipeline in errors.
+func reducePartials(cfg Config, db *sql.DB, what string, partials []string, build func([]string) string) (string, error) {
+	partials = premergePartials(partials)
+	for round := 1; len(partials) > 1; round++ {
+		groups := groupPartialsForMerge(cfg, partials)
+		if len(groups) > 1 {
+			log.Printf("[info] %s merge round %d: %d partials -> %d", what, round, len(partials), len(groups))
+		}
+		next := make([]string, 0, len(groups))
+		for _, g := range groups {
+			if len(g) == 1 {
+				next = append(next, g[0])
+				continue
+			}
+			merged, err := callBackgroundLLM(cfg, db, build(g))
+			if err != nil {
+				return "", err
+			}
+			merged = strings.TrimSpace(merged)
+			if merged == "" {
+				return "", fmt.Errorf("%s merged output is empty", what)
+			}
+			if !json.Valid([]byte(merged)) {
+				return "", fmt.Errorf("%s merged output invalid JSON\nraw:\n%s", what, merged)
+			}
+			next = append(next, merged)
+		}
+		partials = premergePartials(next)
+	}
+	if len(partials) == 0 {
+		return "", fmt.Errorf("%s merge: no partials", what)
+	}
+	return partials[0], nil
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// ============================================================
+// Daily skip for empty / noise-only days
+// - Before the daily LLM call the day's dialog is measured: user messages
+//   that are not greetings / acknowledgements / commands, plus external
+//   events, count as substantive.
+// - Fewer than cfg.SummarySkipMinMessages substantive messages, or fewer than
+//   cfg.SummarySkipMinChars characters in them, and no explicit user fact:
+//   a lightweight "no significant activity" daily is written without any LLM
+//   call (weekly / monthly rollups and search still see the day).
+// - The summary JSON carries "skipped": {reason, counts} — the skip manifest;
+//   GET /api/summaries/skipped lists those days. A later edit of the raw log
+//   re-evaluates the day like any other daily.
+// - TIMELAYER_SUMMARY_SKIP_MIN_MESSAGES=0 turns the skip off.
+// ============================================================
+
+// trivialMessages are normalized (lower-case, no spaces / punctuation) messages
+// that carry no content of their own.
+var trivialMessages = map[string]bool{
+	"你好": true, "您好": true, "嗨": true, "哈喽": true, "哈罗": true, "在吗": true, "在不在": true, "在么": true,
+	"早": true, "早安": true, "早上好": true, "中午好": true, "下午好": true, "晚上好": true, "午安": true, "晚安": true,
+	"谢谢": true, "多谢": true, "谢啦": true, "感谢": true, "好的": true, "好": true, "好吧": true, "行": true,
+	"嗯": true, "嗯嗯": true, "哦": true, "噢": true, "啊": true, "哈哈": true, "哈哈哈": true, "收到": true, "知道了": true,
+	"拜拜": true, "再见": true, "测试": true, "试试": true,
+	"hi": true, "hello": true, "hey": true, "yo": true, "ok": true, "okay": true, "k": true, "thanks": true,
+	"thankyou": true, "thx": true, "ty": true, "bye": true, "goodnight": true, "gn": true, "morning": true,
+	"goodmorning": true, "yes": true, "no": true, "yep": true, "nope": true, "lol": true, "test": true,
+}
+
+type dailyActivity struct {
+	UserMessages        int `json:"user_messages"`
+	SubstantiveMessages int `json:"substantive_messages"`
+	Events              int `json:"events"`
+	Chars               int `json:"chars"` // runes in substantive messages / events
+}
+
+// substantiveText returns the message without punctuation / spaces, or ""
+// when it is a command or made only of trivial words ("ok thanks", "你好谢谢").
+func substantiveText(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "/") {
+		return ""
+	}
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	trivial := true
+	for _, w := range words {
+		if !isTrivialWord(w) {
+			trivial = false
+			break
+		}
+	}
+	if trivial {
+		return ""
+	}
+	return strings.Join(words, "")
+}
+
+// isTrivialWord reports whether w is one char or a concatenation of
+// trivialMessages entries (CJK has no spaces between them).
+func isTrivialWord(w string) bool {
+	r := []rune(w)
+	if len(r) <= 1 {
+		return true
+	}
+	ok := make([]bool, len(r)+1) // ok[i]: r[:i] is covered
+	ok[0] = true
+	for i := 1; i <= len(r); i++ {
+		for j := 0; j < i && !ok[i]; j++ {
+			ok[i] = ok[j] && trivialMessages[string(r[j:i])]
+		}
+	}
+	return ok[len(r)]
+}
+
+// measureDailyActivity counts the substantive content of a day's dialog JSONL
+// (op / redacted lines already filtered out).
+func measureDailyActivity(raw []byte) dailyActivity {
+	var a dailyActivity
+	scanJSONL(raw, func(line []byte) {
+		var r RawLine
+		if json.Unmarshal(line, &r) != nil {
+			return
+		}
+		switch r.Role {
+		case "user":
+			a.UserMessages++
+			if t := substantiveText(r.Content); t != "" {
+				a.SubstantiveMessages++
+				a.Chars += len([]rune(t))
+			}
+		case roleEvent:
+			a.Events++
+			a.SubstantiveMessages++
+			a.Chars += len([]rune(strings.TrimSpace(r.Content)))
+		}
+	})
+	return a
+}
+
+// shouldSkipDaily reports whether the day is below the configured threshold.
+func shouldSkipDaily(cfg Config, a dailyActivity) bool {
+	if cfg.SummarySkipMinMessages <= 0 {
+		return false
+	}
+	return a.SubstantiveMessages < cfg.SummarySkipMinMessages || a.Chars < cfg.SummarySkipMinChars
+}
+
+// noActivityDailyJSON is the daily written for a skipped day (same shape as
+// the LLM / extractive dailies).
+func noActivityDailyJSON(cfg Config, date string, a dailyActivity) (string, error) {
+	b, err := json.MarshalIndent(map[string]any{
+		"type":                "daily",
+		"date":                date,
+		"topics":              []string{},
+		"patterns":            []string{},
+		"open_questions":      []string{},
+		"highlights":          []string{noActivityNote(cfg)},
+		"lowlights":           []string{},
+		"user_facts_explicit": []string{},
+		"skipped": map[string]any{
+			"reason":               "no_significant_activity",
+			"user_messages":        a.UserMessages,
+			"substantive_messages": a.SubstantiveMessages,
+			"events":               a.Events,
+			"chars":                a.Chars,
+		},
+	}, "", "  ")
+	return string(b), err
+}
+
+// noActivityNote is the highlight / title of a skipped day.
+func noActivityNote(cfg Config) string {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(cfg.OutputLanguage)), "en") {
+		return "No significant activity"
+	}
+	return "无重要活动"
+}
+
+type SkippedDaily struct {
+	Date     string        `json:"date"`
+	Activity dailyActivity `json:"activity"`
+}
+
+// ListSkippedDailies returns the days summarized without the LLM, newest first.
+func ListSkippedDailies(db *sql.DB, limit int) ([]SkippedDaily, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := db.Query(`
+		SELECT period_key, json FROM summaries
+		WHERE type='daily' AND deleted_at IS NULL AND json LIKE '%"skipped"%'
+		ORDER BY period_key DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []SkippedDaily{}
+	for rows.Next() {
+		var key, js string
+		if err := rows.Scan(&key, &js); err != nil {
+			return nil, err
+		}
+		var obj struct {
+			Skipped *dailyActivity `json:"skipped"`
+		}
+		if json.Unmarshal([]byte(js), &obj) != nil || obj.Skipped == nil {
+			continue
+		}
+		out = append(out, SkippedDaily{Date: key, Activity: *obj.Skipped})
+		if len(out) >= limit {
+			break
+		}
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ============================================================
+// Stale daily summaries
+// - summaries.source_hash = sha256 of the dialog JSONL the daily was built from
+//   (malformed / op lines excluded, so /logcheck --fix does not mark it stale).
+// - ensureDaily compares it on access and regenerates on mismatch;
+//   /stale_check [--fix] sweeps all dailies.
+// - Rows from older versions have no hash: it is backfilled on first check.
+// ============================================================
+
+type StaleSummary struct {
+	Date       string `json:"date"`
+	StoredHash string `json:"stored_hash"`
+	SourceHash string `json:"source_hash"`
+}
+
+// dailySourceHash hashes the dialog records of a day ("" when there is no raw log).
+func dailySourceHash(cfg Config, db *sql.DB, date string) string {
+	raw, err := readRawDay(cfg, db, date)
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	raw, _ = filterDialogJSONL(raw)
+	raw = filterUserJSONL(raw, "")
+	if len(raw) == 0 {
+		return ""
+	}
+	return sha256Hex(string(raw))
+}
+
+func setSummarySourceHash(db *sql.DB, typ, key, hash string) error {
+	_, err := db.Exec(`UPDATE summaries SET source_hash=? WHERE type=? AND period_key=?`, hash, typ, key)
+	return err
+}
+
+// dailySourceChanged reports whether the raw log of date changed since its daily was built.
+// A missing raw log is never "changed" (the summary is all that is left).
+func dailySourceChanged(cfg Config, db *sql.DB, date string) (bool, StaleSummary) {
+	st := StaleSummary{Date: date}
+	var deleted bool
+	_ = db.QueryRow(
+		`SELECT COALESCE(source_hash,''), deleted_at IS NOT NULL FROM summaries WHERE type='daily' AND period_key=?`, date,
+	).Scan(&st.StoredHash, &deleted)
+	if deleted {
+		// in the trash: never rebuilt behind the user's back
+		return false, st
+	}
+
+	st.SourceHash = dailySourceHash(cfg, db, date)
+	if st.SourceHash == "" {
+		return false, st
+	}
+	if st.StoredHash == "" {
+		_ = setSummarySourceHash(db, "daily", date, st.SourceHash)
+		return false, st
+	}
+	return st.StoredHash != st.SourceHash, st
+}
+
+// FindStaleDailies checks every daily summary against its raw log.
+func FindStaleDailies(cfg Config, db *sql.DB) ([]StaleSummary, error) {
+	rows, err := db.Query(`SELECT period_key FROM summaries WHERE type='daily' ORDER BY period_key`)
+	if err != nil {
+		return nil, err
+	}
+	var dates []string
+	for rows.Next() {
+		var d string
+		if rows.Scan(&d) == nil {
+			dates = append(dates, d)
+		}
+	}
+	rows.Close()
+
+	var out []StaleSummary
+	for _, d := range dates {
+		if changed, st := dailySourceChanged(cfg, db, d); changed {
+			out = append(out, st)
+		}
+	}
+	return out, nil
+}
+
+// runStaleCheckCommand implements /stale_check [--fix].
+func runStaleCheckCommand(cfg Config, db *sql.DB, arg string) (string, error) {
+	fix := strings.Contains(arg, "--fix")
+
+	stale, err := FindStaleDailies(cfg, db)
+	if err != nil {
+		return "", err
+	}
+	if len(stale) == 0 {
+		return "[ok] no stale daily summaries", nil
+	}
+
+	var b strings.Builder
+	regenerated := 0
+	for _, s := range stale {
+		fmt.Fprintf(&b, "%s stale", s.Date)
+		if fix {
+			// ensureDaily sees the hash mismatch and regenerates in place.
+			if err := ensureDaily(cfg, db, s.Date, false); err != nil {
+				fmt.Fprintf(&b, " (regenerate failed: %v)", err)
+				log.Printf("[warn] regenerate daily %s failed: %v", s.Date, err)
+			} else {
+				b.WriteString(" -> regenerated")
+				regenerated++
+			}
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "[ok] stale=%d regenerated=%d", len(stale), regenerated)
+	if !fix {
+		b.WriteString(" (run /stale_check --fix to regenerate)")
+	} else if regenerated > 0 {
+		b.WriteString(" (weekly/monthly covering these days are not rebuilt; use /weekly or /monthly)")
+	}
+	return b.String(), nil
+}
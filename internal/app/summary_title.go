@@ -0,0 +1,115 @@
+package app
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// ============================================================
+// Daily titles
+// - A short title per day ("讨论 NAS 备份方案"), generated from the daily
+//   summary by one small background LLM call and stored on summaries.title.
+// - Generated after each daily is written; missing titles are backfilled
+//   when ensureDaily sees an existing daily without one.
+// - Exposed by GET /api/summaries.
+// ============================================================
+
+const summaryTitleMaxRunes = 24
+
+type SummaryListItem struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	PeriodKey string `json:"period_key"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Title     string `json:"title"`
+	CreatedAt string `json:"created_at"`
+}
+
+func buildTitlePrompt(cfg Config, indexText string) string {
+	var b strings.Builder
+	b.WriteString("Give a short title for the conversation summarized below.\n")
+	b.WriteString("RULES:\n")
+	b.WriteString("- Output the title only: one line, no quotes, no trailing punctuation.\n")
+	b.WriteString("- At most 16 Chinese characters or 8 English words; name the main topic.\n")
+	b.WriteString("- Write it in " + outputLanguageName(cfg.OutputLanguage) + ".\n")
+	b.WriteString("\nSUMMARY:\n")
+	b.WriteString(indexText)
+	return b.String()
+}
+
+// cleanTitle keeps the first line, strips quotes/punctuation and caps the length.
+func cleanTitle(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "Title:"), "标题：")
+	s = strings.Trim(strings.TrimSpace(s), "\"'“”‘’「」《》。.!！")
+	if r := []rune(s); len(r) > summaryTitleMaxRunes {
+		s = string(r[:summaryTitleMaxRunes])
+	}
+	return strings.TrimSpace(s)
+}
+
+// ensureSummaryTitle generates a title for typ/key if it has none. Best effort.
+func ensureSummaryTitle(cfg Config, db *sql.DB, typ, key string) {
+	var title, js string
+	err := db.QueryRow(
+		`SELECT COALESCE(title,''), json FROM summaries WHERE type=? AND period_key=?`, typ, key,
+	).Scan(&title, &js)
+	if err != nil || title != "" {
+		return
+	}
+	text := strings.TrimSpace(extractIndexText(js))
+	if text == "" {
+		return
+	}
+	if r := []rune(text); len(r) > 2000 {
+		text = string(r[:2000])
+	}
+
+	out, err := callBackgroundLLM(cfg, db, buildTitlePrompt(cfg, text))
+	if err != nil {
+		log.Printf("[warn] title for %s %s failed: %v", typ, key, err)
+		return
+	}
+	if title = cleanTitle(out); title == "" {
+		return
+	}
+	_, _ = db.Exec(`UPDATE summaries SET title=? WHERE type=? AND period_key=?`, title, typ, key)
+}
+
+// ListSummaries lists user's summaries of typ ("" = daily/weekly/monthly/user_daily), newest first.
+func ListSummaries(db *sql.DB, user, typ string, limit int) ([]SummaryListItem, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	q := `SELECT id, type, period_key, start_date, end_date, COALESCE(title,''), created_at FROM summaries
+		WHERE deleted_at IS NULL AND user_id=?`
+	args := []any{user}
+	if typ != "" {
+		q += ` AND type=?`
+		args = append(args, typ)
+	} else {
+		q += ` AND type IN ('daily','weekly','monthly','user_daily')`
+	}
+	q += ` ORDER BY start_date DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SummaryListItem
+	for rows.Next() {
+		var it SummaryListItem
+		if err := rows.Scan(&it.ID, &it.Type, &it.PeriodKey, &it.StartDate, &it.EndDate, &it.Title, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
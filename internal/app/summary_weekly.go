@@ -26,13 +26,7 @@ periodKey = YYYY-Www
 func ensureWeekly(cfg Config, db *sql.DB, weekKey string, force bool) error {
 	// ---------- FORCE MODE ----------
 	if force {
-		_, _ = db.Exec(`
-			DELETE FROM embeddings
-			WHERE summary_id IN (
-				SELECT id FROM summaries
-				WHERE type='weekly' AND period_key=?
-			)
-		`, weekKey)
+		dropSummaryEmbedding(db, "weekly", weekKey)
 
 		_, _ = db.Exec(`
 			DELETE FROM summaries
@@ -43,9 +37,14 @@ func ensureWeekly(cfg Config, db *sql.DB, weekKey string, force bool) error {
 	}
 
 	// ---------- IDEMPOTENT CHECK ----------
+	// degraded（extractive 兜底）的 summary 在 LLM-only 模式下原地重建
+	regen := false
 	if !force {
 		if ok, _ := summaryExists(db, "weekly", weekKey); ok {
-			return nil
+			if !summaryNeedsRegen(cfg, db, "weekly", weekKey) {
+				return nil
+			}
+			regen = true
 		}
 	}
 
@@ -94,6 +93,11 @@ func ensureWeekly(cfg Config, db *sql.DB, weekKey string, force bool) error {
 			"highlights":     obj["highlights"],
 			"lowlights":      obj["lowlights"],
 		}
+		if d, ok := obj["date"].(string); ok {
+			if notes := annotationNotes(db, "daily", d); len(notes) > 0 {
+				slim["user_annotations"] = notes
+			}
+		}
 		slimmed = append(slimmed, slim)
 	}
 
@@ -102,77 +106,17 @@ func ensureWeekly(cfg Config, db *sql.DB, weekKey string, force bool) error {
 		return fmt.Errorf("weekly marshal slimmed dailies failed: %w", err)
 	}
 
-	// ---------- CHUNK IF NEEDED ----------
-	chunks := splitJSONBytes(rawBytes, cfg.MaxDailyJSONLBytes)
-
-	var weeklyJSON string
-
-	if len(chunks) == 1 {
-		prompt := mustReadPrompt(cfg, "weekly.txt")
-		prompt = strings.ReplaceAll(prompt, "{{WEEK_START}}", weekStart)
-		prompt = strings.ReplaceAll(prompt, "{{WEEK_END}}", weekEnd)
-		prompt = strings.ReplaceAll(prompt, "{{DAILY_JSON_ARRAY}}", string(chunks[0]))
-
-		out, err := callLLMNonStream(cfg, prompt)
-		if err != nil {
-			return err
-		}
-		out = strings.TrimSpace(out)
-		if out == "" {
-			return fmt.Errorf("weekly llm output is empty")
-		}
-		if !json.Valid([]byte(out)) {
-			return fmt.Errorf("weekly llm output is not valid JSON\nraw:\n%s", out)
-		}
-		weeklyJSON = out
-	} else {
-		partials := make([]string, 0, len(chunks))
-
-		for i, c := range chunks {
-			prompt := mustReadPrompt(cfg, "weekly.txt")
-			prompt = strings.ReplaceAll(prompt, "{{WEEK_START}}", weekStart)
-			prompt = strings.ReplaceAll(prompt, "{{WEEK_END}}", weekEnd)
-
-			prompt = strings.ReplaceAll(
-				prompt,
-				"{{DAILY_JSON_ARRAY}}",
-				fmt.Sprintf("/* PART %d/%d */\n%s", i+1, len(chunks), string(c)),
-			)
-
-			out, err := callLLMNonStream(cfg, prompt)
-			if err != nil {
-				return err
-			}
-			out = strings.TrimSpace(out)
-			if out == "" {
-				return fmt.Errorf("weekly chunk %d output is empty", i+1)
-			}
-			if !json.Valid([]byte(out)) {
-				return fmt.Errorf("weekly chunk %d output invalid JSON\nraw:\n%s", i+1, out)
-			}
-			partials = append(partials, out)
-		}
-
-		mergePrompt := buildWeeklyMergePrompt(weekKey, weekStart, weekEnd, partials)
-		merged, err := callLLMNonStream(cfg, mergePrompt)
-		if err != nil {
-			return err
-		}
-		merged = strings.TrimSpace(merged)
-		if merged == "" {
-			return fmt.Errorf("weekly merged output is empty")
-		}
-		if !json.Valid([]byte(merged)) {
-			return fmt.Errorf("weekly merged output invalid JSON\nraw:\n%s", merged)
-		}
-		weeklyJSON = merged
+	// ---------- SUMMARIZE (LLM; extractive fallback, see summarizer.go) ----------
+	weeklyJSON, _, err := summarizeWithFallback(cfg, db, "weekly", weekKey,
+		func() (string, error) { return generateWeeklyJSON(cfg, db, weekKey, weekStart, weekEnd, rawBytes) },
+		func() (string, error) { return extractiveWeeklyJSON(weekStart, weekEnd, slimmed) },
+	)
+	if err != nil {
+		return err
 	}
 
 	// ---------- ⭐ SUMMARY GUARDS（新增） ----------
-	warnings := RunSummaryGuards(db, "weekly", weeklyJSON)
-	for _, w := range warnings {
-		log.Printf("[SUMMARY %s] %s", w.Type, w.Message)
-	}
+	recordSummaryWarnings(cfg, db, "weekly", weekKey, RunSummaryGuards(db, "weekly", weeklyJSON))
 
 	// ---------- WRITE FILE ----------
 	outPath := filepath.Join(cfg.LogDir, weekKey+".weekly.json")
@@ -197,6 +141,9 @@ func ensureWeekly(cfg Config, db *sql.DB, weekKey string, force bool) error {
 	if err != nil {
 		return err
 	}
+	if regen {
+		dropSummaryEmbedding(db, "weekly", weekKey)
+	}
 
 	// ---------- ⭐ EMBEDDING DRIFT GUARD ----------
 	{
@@ -356,7 +303,7 @@ func hardSplitBytes(b []byte, maxBytes int64) [][]byte {
 	return out
 }
 
-func buildWeeklyMergePrompt(weekKey, weekStart, weekEnd string, partials []string) string {
+func buildWeeklyMergePrompt(cfg Config, weekKey, weekStart, weekEnd string, partials []string) string {
 	var b strings.Builder
 
 	b.WriteString("You are a strict weekly summary reducer.\n")
@@ -366,7 +313,9 @@ func buildWeeklyMergePrompt(weekKey, weekStart, weekEnd string, partials []strin
 	b.WriteString("- Output JSON only.\n")
 	b.WriteString("- Do NOT add new facts.\n")
 	b.WriteString("- Do NOT infer user identity.\n")
-	b.WriteString("- Deduplicate and merge semantically.\n\n")
+	b.WriteString("- Deduplicate and merge semantically.\n")
+	b.WriteString(outputLanguageRule(cfg))
+	b.WriteString("\n")
 
 	b.WriteString("OUTPUT FORMAT (JSON only):\n")
 	b.WriteString("{\n")
@@ -390,3 +339,70 @@ func buildWeeklyMergePrompt(weekKey, weekStart, weekEnd string, partials []strin
 
 	return b.String()
 }
+
+// generateWeeklyJSON runs the weekly prompt over the slimmed daily array
+// (chunked + merged when large) and enforces the output language.
+func generateWeeklyJSON(cfg Config, db *sql.DB, weekKey, weekStart, weekEnd string, rawBytes []byte) (string, error) {
+	// ---------- CHUNK IF NEEDED ----------
+	chunks := chunkJSONArray(cfg, rawBytes)
+
+	var weeklyJSON string
+
+	if len(chunks) == 1 {
+		prompt := mustReadPrompt(cfg, "weekly.txt")
+		prompt = strings.ReplaceAll(prompt, "{{WEEK_START}}", weekStart)
+		prompt = strings.ReplaceAll(prompt, "{{WEEK_END}}", weekEnd)
+		prompt = strings.ReplaceAll(prompt, "{{DAILY_JSON_ARRAY}}", string(chunks[0]))
+
+		out, err := callBackgroundLLM(cfg, db, prompt)
+		if err != nil {
+			return "", err
+		}
+		out = strings.TrimSpace(out)
+		if out == "" {
+			return "", fmt.Errorf("weekly llm output is empty")
+		}
+		if !json.Valid([]byte(out)) {
+			return "", fmt.Errorf("weekly llm output is not valid JSON\nraw:\n%s", out)
+		}
+		weeklyJSON = out
+	} else {
+		partials := make([]string, 0, len(chunks))
+
+		for i, c := range chunks {
+			prompt := mustReadPrompt(cfg, "weekly.txt")
+			prompt = strings.ReplaceAll(prompt, "{{WEEK_START}}", weekStart)
+			prompt = strings.ReplaceAll(prompt, "{{WEEK_END}}", weekEnd)
+
+			prompt = strings.ReplaceAll(
+				prompt,
+				"{{DAILY_JSON_ARRAY}}",
+				fmt.Sprintf("/* PART %d/%d */\n%s", i+1, len(chunks), string(c)),
+			)
+
+			out, err := callBackgroundLLM(cfg, db, prompt)
+			if err != nil {
+				return "", err
+			}
+			out = strings.TrimSpace(out)
+			if out == "" {
+				return "", fmt.Errorf("weekly chunk %d output is empty", i+1)
+			}
+			if !json.Valid([]byte(out)) {
+				return "", fmt.Errorf("weekly chunk %d output invalid JSON\nraw:\n%s", i+1, out)
+			}
+			partials = append(partials, out)
+		}
+
+		merged, err := reducePartials(cfg, db, "weekly", partials, func(g []string) string {
+			return buildWeeklyMergePrompt(cfg, weekKey, weekStart, weekEnd, g)
+		})
+		if err != nil {
+			return "", err
+		}
+		weeklyJSON = merged
+	}
+
+	// ---------- OUTPUT LANGUAGE ----------
+	return enforceOutputLanguage(cfg, db, "weekly", weeklyJSON)
+}
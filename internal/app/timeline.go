@@ -0,0 +1,359 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Memory timeline ("life log")
+// - GET /api/timeline?from=2026-01-01&to=2026-01-31[&types=fact_learned,highlight][&order=desc][&limit=500]
+// - One chronological feed instead of the client stitching raw logs / facts /
+//   history / conflicts / summaries together:
+//     messages           raw dialog lines of a day (count = user + assistant
+//                        + event; dated by the day itself)
+//     fact_learned       user_facts_history active, version 1
+//     fact_updated       user_facts_history active, version > 1
+//     fact_forgotten     user_facts_history forgotten
+//     conflict_detected  user_fact_conflicts opened (any status)
+//     conflict_resolved  user_fact_conflicts resolved_keep | resolved_replace
+//     summary            daily / weekly / monthly summary generated
+//     highlight          highlights of the daily summaries in range
+//                        (dated by the day itself; skipped days have none)
+// - from / to are local dates (inclusive); default = the last 30 days,
+//   at most timelineMaxDays.
+// ============================================================
+
+const (
+	timelineMaxDays      = 366
+	timelineDefaultDays  = 30
+	timelineDefaultLimit = 500
+	timelineMaxLimit     = 2000
+)
+
+var timelineTypes = []string{
+	"messages",
+	"fact_learned", "fact_updated", "fact_forgotten",
+	"conflict_detected", "conflict_resolved", "summary", "highlight",
+}
+
+type TimelineItem struct {
+	Type    string `json:"type"`
+	Date    string `json:"date"` // YYYY-MM-DD (local)
+	TS      string `json:"ts"`   // RFC3339; highlights carry the day only
+	Title   string `json:"title"`
+	Text    string `json:"text,omitempty"`
+	FactKey string `json:"fact_key,omitempty"`
+	Ref     string `json:"ref,omitempty"`   // daily:2026-01-08 | conflict:12 | history:34 | log:2026-01-08
+	Count   int    `json:"count,omitempty"` // messages only
+}
+
+type TimelineQuery struct {
+	From  string
+	To    string
+	Types map[string]bool // nil = all
+	Desc  bool
+	Limit int
+}
+
+// parseTimelineQuery reads from / to / types / order / limit (defaults filled in).
+func parseTimelineQuery(cfg Config, get func(string) string) (TimelineQuery, error) {
+	q := TimelineQuery{
+		From:  strings.TrimSpace(get("from")),
+		To:    strings.TrimSpace(get("to")),
+		Desc:  strings.EqualFold(strings.TrimSpace(get("order")), "desc"),
+		Limit: parseIntClamp(get("limit"), timelineDefaultLimit, 1, timelineMaxLimit),
+	}
+	today := time.Now().In(cfg.Location)
+	if q.To == "" {
+		q.To = today.Format("2006-01-02")
+	}
+	end, err := time.ParseInLocation("2006-01-02", q.To, cfg.Location)
+	if err != nil {
+		return q, fmt.Errorf("invalid to date: %s", q.To)
+	}
+	if q.From == "" {
+		q.From = end.AddDate(0, 0, -(timelineDefaultDays - 1)).Format("2006-01-02")
+	}
+	start, err := time.ParseInLocation("2006-01-02", q.From, cfg.Location)
+	if err != nil {
+		return q, fmt.Errorf("invalid from date: %s", q.From)
+	}
+	if end.Before(start) {
+		return q, fmt.Errorf("to (%s) is before from (%s)", q.To, q.From)
+	}
+	if int(end.Sub(start).Hours()/24) >= timelineMaxDays {
+		return q, fmt.Errorf("range too large (max %d days)", timelineMaxDays)
+	}
+	if v := strings.TrimSpace(get("types")); v != "" {
+		q.Types = map[string]bool{}
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				continue
+			}
+			known := false
+			for _, k := range timelineTypes {
+				known = known || k == t
+			}
+			if !known {
+				return q, fmt.Errorf("unknown type: %s (use %s)", t, strings.Join(timelineTypes, ", "))
+			}
+			q.Types[t] = true
+		}
+	}
+	return q, nil
+}
+
+func (q TimelineQuery) wants(typ string) bool {
+	return q.Types == nil || q.Types[typ]
+}
+
+// BuildTimeline merges raw message counts, fact history, conflicts, summaries
+// and daily highlights in [q.From, q.To] into one feed sorted by time
+// (the primary user's only, see users.go).
+func BuildTimeline(cfg Config, db *sql.DB, q TimelineQuery) ([]TimelineItem, error) {
+	out := []TimelineItem{}
+	if db == nil {
+		return out, nil
+	}
+
+	if q.wants("messages") {
+		out = append(out, timelineMessages(cfg, db, q)...)
+	}
+
+	// like the summaries below, facts and conflicts are the primary user's
+	primaryKeys, primaryArgs := userKeyWhere("fact_key", "")
+
+	if q.wants("fact_learned") || q.wants("fact_updated") || q.wants("fact_forgotten") {
+		rows, err := readDB(db).Query(`
+			SELECT id, fact_key, fact, status, version, source_type, created_at
+			FROM user_facts_history
+			WHERE status IN ('active','forgotten') AND substr(created_at,1,10) BETWEEN ? AND ?
+			  AND `+primaryKeys+`
+		`, append([]any{q.From, q.To}, primaryArgs...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var key, fact, status, source, ts string
+			var version int
+			if err := rows.Scan(&id, &key, &fact, &status, &version, &source, &ts); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			typ, title := "fact_learned", "Learned a fact"
+			switch {
+			case status == "forgotten":
+				typ, title = "fact_forgotten", "Forgot a fact"
+			case version > 1:
+				typ, title = "fact_updated", fmt.Sprintf("Updated a fact (v%d)", version)
+			}
+			if !q.wants(typ) {
+				continue
+			}
+			if source != "" {
+				title += " · " + source
+			}
+			out = append(out, TimelineItem{Type: typ, Date: timelineDate(ts), TS: ts, Title: title, Text: fact, FactKey: key, Ref: "history:" + itoa64(id)})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if q.wants("conflict_detected") {
+		rows, err := readDB(db).Query(`
+			SELECT id, fact_key, existing_fact, proposed_fact, created_at
+			FROM user_fact_conflicts
+			WHERE substr(created_at,1,10) BETWEEN ? AND ? AND `+primaryKeys+`
+		`, append([]any{q.From, q.To}, primaryArgs...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var key, existing, proposed, ts string
+			if err := rows.Scan(&id, &key, &existing, &proposed, &ts); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			out = append(out, TimelineItem{Type: "conflict_detected", Date: timelineDate(ts), TS: ts, Title: "Conflict detected", Text: existing + " ⇄ " + proposed, FactKey: key, Ref: "conflict:" + itoa64(id)})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if q.wants("conflict_resolved") {
+		rows, err := readDB(db).Query(`
+			SELECT id, fact_key, existing_fact, proposed_fact, status, updated_at
+			FROM user_fact_conflicts
+			WHERE status LIKE 'resolved_%' AND substr(updated_at,1,10) BETWEEN ? AND ?
+			  AND `+primaryKeys+`
+		`, append([]any{q.From, q.To}, primaryArgs...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var key, existing, proposed, status, ts string
+			if err := rows.Scan(&id, &key, &existing, &proposed, &status, &ts); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			title, text := "Conflict resolved: kept existing", existing
+			if status == "resolved_replace" {
+				title, text = "Conflict resolved: replaced", proposed
+			}
+			out = append(out, TimelineItem{Type: "conflict_resolved", Date: timelineDate(ts), TS: ts, Title: title, Text: text, FactKey: key, Ref: "conflict:" + itoa64(id)})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if q.wants("summary") {
+		rows, err := readDB(db).Query(`
+			SELECT type, period_key, COALESCE(title,''), created_at
+			FROM summaries
+			WHERE deleted_at IS NULL AND type IN ('daily','weekly','monthly')
+			  AND substr(created_at,1,10) BETWEEN ? AND ?
+		`, q.From, q.To)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var typ, key, title, ts string
+			if err := rows.Scan(&typ, &key, &title, &ts); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			out = append(out, TimelineItem{Type: "summary", Date: timelineDate(ts), TS: ts, Title: typ + " summary " + key, Text: title, Ref: typ + ":" + key})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if q.wants("highlight") {
+		rows, err := readDB(db).Query(`
+			SELECT period_key, json FROM summaries
+			WHERE type='daily' AND deleted_at IS NULL AND period_key BETWEEN ? AND ?
+		`, q.From, q.To)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var key, js string
+			if err := rows.Scan(&key, &js); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			var obj struct {
+				Highlights []any           `json:"highlights"`
+				Skipped    json.RawMessage `json:"skipped"`
+			}
+			if json.Unmarshal([]byte(js), &obj) != nil || len(obj.Skipped) > 0 {
+				continue
+			}
+			for _, h := range extractStringList(obj.Highlights) {
+				if h = strings.TrimSpace(h); h != "" {
+					out = append(out, TimelineItem{Type: "highlight", Date: key, TS: key, Title: "Highlight", Text: h, Ref: "daily:" + key})
+				}
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// ts strings share one format per source; a day-only ts sorts first within its day
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].TS != out[j].TS {
+			return (out[i].TS < out[j].TS) != q.Desc
+		}
+		return timelineTypeRank(out[i].Type) < timelineTypeRank(out[j].Type)
+	})
+	if len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+// timelineMessages counts the dialog lines of each day in range (days without
+// a readable raw log, e.g. archived ones, are left out).
+func timelineMessages(cfg Config, db *sql.DB, q TimelineQuery) []TimelineItem {
+	var out []TimelineItem
+	start, err1 := time.ParseInLocation("2006-01-02", q.From, cfg.Location)
+	end, err2 := time.ParseInLocation("2006-01-02", q.To, cfg.Location)
+	if err1 != nil || err2 != nil {
+		return out
+	}
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		raw, err := readRawDay(cfg, db, date)
+		if err != nil || len(raw) == 0 {
+			continue
+		}
+		raw, _ = filterDialogJSONL(raw)
+		raw = filterUserJSONL(raw, "")
+		var user, assistant, events int
+		scanJSONL(raw, func(line []byte) {
+			var r RawLine
+			if json.Unmarshal(line, &r) != nil {
+				return
+			}
+			switch r.Role {
+			case "user":
+				user++
+			case "assistant":
+				assistant++
+			case roleEvent:
+				events++
+			}
+		})
+		n := user + assistant + events
+		if n == 0 {
+			continue
+		}
+		text := fmt.Sprintf("%d user · %d assistant", user, assistant)
+		if events > 0 {
+			text += fmt.Sprintf(" · %d event(s)", events)
+		}
+		out = append(out, TimelineItem{Type: "messages", Date: date, TS: date, Title: fmt.Sprintf("%d messages", n), Text: text, Ref: "log:" + date, Count: n})
+	}
+	return out
+}
+
+func timelineTypeRank(typ string) int {
+	for i, t := range timelineTypes {
+		if t == typ {
+			return i
+		}
+	}
+	return len(timelineTypes)
+}
+
+// timelineDate is the YYYY-MM-DD prefix of a stored RFC3339 timestamp.
+func timelineDate(ts string) string {
+	if len(ts) >= 10 {
+		return ts[:10]
+	}
+	return ts
+}
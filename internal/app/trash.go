@@ -0,0 +1,310 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Trash (soft delete)
+// - Forgotten facts, rejected pending facts and deleted summaries get
+//   deleted_at; they stay restorable for TrashDays (TIMELAYER_TRASH_DAYS).
+// - Deleted summaries lose their embedding and their <key>.<type>.json is
+//   renamed to *.trash, so neither search nor file-based context sees them.
+// - A fact is not restored while another active fact holds its slot
+//   (checkFactRestore, as for /restore <fact>).
+// - Listing and restoring only see the rows of cfg.User (users.go).
+// - purgeExpiredTrash hard-deletes expired rows (startup + day change).
+// ============================================================
+
+const (
+	trashKindFact    = "fact"
+	trashKindPending = "pending"
+	trashKindSummary = "summary"
+)
+
+type TrashItem struct {
+	Kind      string `json:"kind"` // fact | pending | summary
+	ID        int64  `json:"id"`
+	Label     string `json:"label"`
+	Detail    string `json:"detail,omitempty"`
+	DeletedAt string `json:"deleted_at"`
+	PurgeAt   string `json:"purge_at"`
+}
+
+func summaryFilePath(cfg Config, typ, key string) string {
+	return filepath.Join(cfg.LogDir, key+"."+typ+".json")
+}
+
+func trashCutoff(cfg Config) string {
+	return time.Now().In(cfg.Location).AddDate(0, 0, -cfg.TrashDays).Format(time.RFC3339)
+}
+
+func purgeAt(cfg Config, deletedAt string) string {
+	t, err := time.Parse(time.RFC3339, deletedAt)
+	if err != nil {
+		return ""
+	}
+	return t.AddDate(0, 0, cfg.TrashDays).Format(time.RFC3339)
+}
+
+// SoftDeleteSummary moves a daily/weekly/monthly/user_daily summary to the trash.
+func SoftDeleteSummary(cfg Config, db *sql.DB, typ, key string) error {
+	switch typ {
+	case "daily", "weekly", "monthly", summaryTypeUserDaily:
+	default:
+		return fmt.Errorf("cannot delete %s summaries (use /forget for facts)", typ)
+	}
+	var id int64
+	err := db.QueryRow(
+		`SELECT id FROM summaries WHERE type=? AND period_key=? AND deleted_at IS NULL`, typ, key,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("summary not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().In(cfg.Location).Format(time.RFC3339)
+	if _, err := db.Exec(`UPDATE summaries SET deleted_at=? WHERE id=?`, now, id); err != nil {
+		return err
+	}
+	_ = deleteEmbedding(db, id)
+	path := summaryFilePath(cfg, typ, key)
+	if _, err := os.Stat(path); err == nil {
+		_ = os.Rename(path, path+".trash")
+	}
+	bumpMemoryVersion()
+	return nil
+}
+
+// ListTrash returns everything of cfg.User that is restorable, newest first per kind.
+func ListTrash(cfg Config, db *sql.DB) ([]TrashItem, error) {
+	var out []TrashItem
+	scan := func(kind, q string) error {
+		rows, err := readDB(db).Query(q, cfg.User)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			it := TrashItem{Kind: kind}
+			if err := rows.Scan(&it.ID, &it.Label, &it.Detail, &it.DeletedAt); err != nil {
+				return err
+			}
+			it.PurgeAt = purgeAt(cfg, it.DeletedAt)
+			out = append(out, it)
+		}
+		return rows.Err()
+	}
+
+	if err := scan(trashKindFact, `
+		SELECT id, fact, fact_key, deleted_at FROM user_facts
+		WHERE is_active=0 AND deleted_at IS NOT NULL AND user_id=?
+		ORDER BY deleted_at DESC
+	`); err != nil {
+		return nil, err
+	}
+	if err := scan(trashKindPending, `
+		SELECT id, fact, source_type || ':' || source_key, deleted_at FROM pending_facts
+		WHERE status='rejected' AND deleted_at IS NOT NULL AND user_id=?
+		ORDER BY deleted_at DESC
+	`); err != nil {
+		return nil, err
+	}
+	if err := scan(trashKindSummary, `
+		SELECT id, type || ' ' || period_key, COALESCE(title,''), deleted_at FROM summaries
+		WHERE deleted_at IS NOT NULL AND user_id=?
+		ORDER BY deleted_at DESC
+	`); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RestoreTrashItem undoes a soft delete of one of cfg.User's rows.
+func RestoreTrashItem(cfg Config, db *sql.DB, kind string, id int64) error {
+	now := time.Now().In(cfg.Location)
+	switch kind {
+	case trashKindFact:
+		var fact, key string
+		err := db.QueryRow(
+			`SELECT fact, fact_key FROM user_facts WHERE id=? AND is_active=0 AND deleted_at IS NOT NULL AND user_id=?`, id, cfg.User,
+		).Scan(&fact, &key)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("fact not in trash")
+		}
+		if err != nil {
+			return err
+		}
+		err = withDBRetry(3, 25*time.Millisecond, func() error {
+			return withTx(db, func(tx *sql.Tx) error {
+				if err := checkFactRestore(tx, key, fact); err != nil {
+					return err
+				}
+				if err := upsertUserFact(tx, fact, key, true, now); err != nil {
+					return err
+				}
+				return appendUserFactHistory(tx, key, fact, "active", "trash_restore", "trash:"+strconv.FormatInt(id, 10), now, 0)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		return syncFactToSearch(cfg, db, key, fact, "trash_restore")
+
+	case trashKindPending:
+		res, err := db.Exec(`
+			UPDATE pending_facts SET status='pending', deleted_at=NULL, updated_at=?
+			WHERE id=? AND status='rejected' AND deleted_at IS NOT NULL AND user_id=?
+		`, now.Format(time.RFC3339), id, cfg.User)
+		if err != nil {
+			// UNIQUE(fact_key, status, source_type, source_key): same candidate already pending
+			return fmt.Errorf("restore pending %d: %w", id, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errors.New("pending fact not in trash")
+		}
+		invalidatePendingGroups()
+		kickPendingEmbeddings()
+		return nil
+
+	case trashKindSummary:
+		var typ, key, text string
+		err := db.QueryRow(
+			`SELECT type, period_key, text FROM summaries WHERE id=? AND deleted_at IS NOT NULL AND user_id=?`, id, cfg.User,
+		).Scan(&typ, &key, &text)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("summary not in trash")
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(`UPDATE summaries SET deleted_at=NULL WHERE id=?`, id); err != nil {
+			return err
+		}
+		path := summaryFilePath(cfg, typ, key)
+		if _, err := os.Stat(path + ".trash"); err == nil {
+			_ = os.Rename(path+".trash", path)
+		}
+		bumpMemoryVersion()
+		if err := ensureEmbedding(db, cfg, text, typ, key); err != nil {
+			log.Printf("[warn] restore %s %s: embedding failed: %v", typ, key, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown trash kind: %s (fact|pending|summary)", kind)
+}
+
+func isTrashKind(kind string) bool {
+	return kind == trashKindFact || kind == trashKindPending || kind == trashKindSummary
+}
+
+// purgeExpiredTrash hard-deletes trash older than TrashDays.
+func purgeExpiredTrash(cfg Config, db *sql.DB) (int, error) {
+	if db == nil || cfg.TrashDays <= 0 {
+		return 0, nil
+	}
+	cutoff := trashCutoff(cfg)
+	total := 0
+
+	// summaries: remove trashed files first, then rows (embeddings/tags cascade)
+	rows, err := db.Query(`SELECT type, period_key FROM summaries WHERE deleted_at IS NOT NULL AND deleted_at < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var typ, key string
+		if rows.Scan(&typ, &key) == nil {
+			_ = os.Remove(summaryFilePath(cfg, typ, key) + ".trash")
+		}
+	}
+	rows.Close()
+
+	for _, q := range []string{
+		`DELETE FROM summary_annotations WHERE (type, period_key) IN (
+			SELECT type, period_key FROM summaries WHERE deleted_at IS NOT NULL AND deleted_at < ?
+		)`,
+		`DELETE FROM summaries WHERE deleted_at IS NOT NULL AND deleted_at < ?`,
+		// forgotten facts: drop a search row left by older versions (forget deletes it now)
+		`DELETE FROM summaries WHERE type='fact' AND period_key IN (
+			SELECT 'fact:' || fact_key FROM user_facts
+			WHERE is_active=0 AND deleted_at IS NOT NULL AND deleted_at < ?
+		)`,
+		`DELETE FROM user_facts WHERE is_active=0 AND deleted_at IS NOT NULL AND deleted_at < ?`,
+		`DELETE FROM pending_facts WHERE status='rejected' AND deleted_at IS NOT NULL AND deleted_at < ?`,
+	} {
+		res, err := db.Exec(q, cutoff)
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += int(n)
+	}
+	return total, nil
+}
+
+func runTrashPurge(cfg Config, db *sql.DB) {
+	n, err := purgeExpiredTrash(cfg, db)
+	if err != nil {
+		log.Printf("[warn] trash purge failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("[info] trash purge: removed %d item(s) older than %d days", n, cfg.TrashDays)
+	}
+}
+
+// runTrashCommand implements /trash, /restore <kind> <id>, /restore <fact>
+// (fact_restore.go) and /delete_summary <type> <key>.
+func runTrashCommand(cfg Config, db *sql.DB, cmd, arg string) (string, error) {
+	fields := strings.Fields(arg)
+	switch cmd {
+	case "/restore":
+		if len(fields) == 0 {
+			return "usage: /restore <fact|pending|summary> <id> | /restore <fact>", nil
+		}
+		id, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+		if len(fields) != 2 || err != nil || !isTrashKind(fields[0]) {
+			r, err := RestoreFact(cfg, db, arg)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("[ok] restored %s (v%d, from %s v%d)", r.Fact, r.Version, r.FromStatus, r.FromVersion), nil
+		}
+		if err := RestoreTrashItem(cfg, db, fields[0], id); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("[ok] restored %s #%d", fields[0], id), nil
+
+	case "/delete_summary":
+		if len(fields) != 2 {
+			return "usage: /delete_summary <daily|weekly|monthly> <period_key>", nil
+		}
+		if err := SoftDeleteSummary(cfg, db, fields[0], fields[1]); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("[ok] %s %s moved to trash (restorable for %d days)", fields[0], fields[1], cfg.TrashDays), nil
+	}
+
+	items, err := ListTrash(cfg, db)
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "(trash is empty)", nil
+	}
+	var b strings.Builder
+	for _, it := range items {
+		fmt.Fprintf(&b, "%s #%d %s (deleted %s, purge %s)\n", it.Kind, it.ID, it.Label, it.DeletedAt, it.PurgeAt)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
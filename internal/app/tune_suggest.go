@@ -0,0 +1,338 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Retrieval tuning suggestions (feedback-driven)
+// - Turn feedback: POST /api/chat/turns/:id/feedback {"rating":"up"|"down"}
+//   → turn_feedback (rating again replaces it).
+// - `local-ai tune suggest [--days=30] [--min=5] [--json]` and
+//   GET /api/tune/suggest join the ratings with the stored context audits
+//   (TIMELAYER_CONTEXT_AUDIT_PERSIST) and compare 👎 turns with 👍 turns:
+//     weak_hits       no search hit reached tuneStrongScore → SearchMinScore
+//     rerank_skipped  the rerank gate skipped the hits      → RerankMode
+//     topk_full       every SearchTopK slot was used        → SearchTopK
+//     budget_drop     a block was dropped over the budget   → MaxContextTokens
+// - A rule fires only with at least `min` 👎 turns, a 👎 rate at or above
+//   its threshold and clearly above the 👍 rate. Each suggestion carries the
+//   counts and sample turn ids. Suggestions only: nothing is changed.
+// ============================================================
+
+const (
+	tuneDefaultDays    = 30
+	tuneMaxDays        = 365
+	tuneDefaultMinDown = 5
+	tuneStrongScore    = 0.8
+	tuneMinRateGap     = 0.2 // 👎 rate must exceed the 👍 rate by this much
+	tuneSampleTurns    = 5
+)
+
+type TurnFeedback struct {
+	TurnID    string `json:"turn_id"`
+	Rating    int    `json:"rating"` // 1 | -1
+	Note      string `json:"note,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// parseTurnRating accepts up / down / 1 / -1 (👍 / 👎).
+func parseTurnRating(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "up", "1", "+1", "👍":
+		return 1, nil
+	case "down", "-1", "👎":
+		return -1, nil
+	}
+	return 0, fmt.Errorf("invalid rating: %q (up|down)", s)
+}
+
+// SetTurnFeedback stores the rating of a chat turn (known from prompts_log or context_audits).
+func SetTurnFeedback(cfg Config, db *sql.DB, turnID string, rating int, note string) (TurnFeedback, error) {
+	fb := TurnFeedback{TurnID: strings.TrimSpace(turnID), Rating: rating, Note: strings.TrimSpace(note)}
+	if fb.TurnID == "" {
+		return fb, errors.New("turn id is required")
+	}
+	var one int
+	err := db.QueryRow(`
+		SELECT 1 FROM prompts_log WHERE turn_id=?
+		UNION SELECT 1 FROM context_audits WHERE turn_id=?
+		LIMIT 1`, fb.TurnID, fb.TurnID).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fb, errors.New("turn not found")
+	}
+	if err != nil {
+		return fb, err
+	}
+	ts := time.Now().In(cfg.Location).Format(time.RFC3339)
+	if _, err := db.Exec(`
+		INSERT INTO turn_feedback(turn_id, rating, note, created_at, updated_at) VALUES(?,?,?,?,?)
+		ON CONFLICT(turn_id) DO UPDATE SET rating=excluded.rating, note=excluded.note, updated_at=excluded.updated_at
+	`, fb.TurnID, fb.Rating, fb.Note, ts, ts); err != nil {
+		return fb, err
+	}
+	_ = db.QueryRow(`SELECT created_at, updated_at FROM turn_feedback WHERE turn_id=?`, fb.TurnID).Scan(&fb.CreatedAt, &fb.UpdatedAt)
+	return fb, nil
+}
+
+type TuneSuggestion struct {
+	Rule      string   `json:"rule"`
+	Param     string   `json:"param"`
+	Env       string   `json:"env"`
+	Current   string   `json:"current"`
+	Suggested string   `json:"suggested"`
+	Message   string   `json:"message"`
+	DownHits  int      `json:"down_hits"`
+	DownN     int      `json:"down_n"`
+	DownRate  float64  `json:"down_rate"`
+	UpHits    int      `json:"up_hits"`
+	UpN       int      `json:"up_n"`
+	UpRate    float64  `json:"up_rate"`
+	Turns     []string `json:"turns"` // sample 👎 turns the rule matched
+}
+
+type TuneReport struct {
+	Since       string           `json:"since"`
+	Rated       int              `json:"rated"`
+	Up          int              `json:"up"`
+	Down        int              `json:"down"`
+	NoAudit     int              `json:"no_audit"` // rated turns without a stored context audit
+	Suggestions []TuneSuggestion `json:"suggestions"`
+	Notes       []string         `json:"notes,omitempty"`
+}
+
+// tuneTurn is what the rules look at for one rated turn.
+type tuneTurn struct {
+	id       string
+	down     bool
+	search   bool    // a search_hit block was built
+	top      float64 // best embedding score among its hits
+	included int     // hits injected
+	gate     string
+	dropped  bool // some block dropped over the token budget
+}
+
+func loadTuneTurns(db *sql.DB, since string) ([]tuneTurn, int, error) {
+	rows, err := readDB(db).Query(`
+		SELECT f.turn_id, f.rating, a.blocks_json
+		FROM turn_feedback f
+		LEFT JOIN context_audits a ON a.turn_id=f.turn_id
+		WHERE substr(f.updated_at,1,10) >= ?
+		ORDER BY f.updated_at DESC
+	`, since)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var out []tuneTurn
+	noAudit := 0
+	for rows.Next() {
+		var id string
+		var rating int
+		var blocksJSON sql.NullString
+		if err := rows.Scan(&id, &rating, &blocksJSON); err != nil {
+			return nil, 0, err
+		}
+		var blocks []ContextBlockView
+		if !blocksJSON.Valid || json.Unmarshal([]byte(blocksJSON.String), &blocks) != nil {
+			noAudit++
+			continue
+		}
+		t := tuneTurn{id: id, down: rating < 0}
+		for _, b := range blocks {
+			t.dropped = t.dropped || b.Dropped
+			if b.Source != "search_hit" {
+				continue
+			}
+			t.search, t.gate = true, b.Gate
+			for _, h := range b.Hits {
+				t.top = math.Max(t.top, h.EmbScore)
+				if h.Included {
+					t.included++
+				}
+			}
+		}
+		out = append(out, t)
+	}
+	return out, noAudit, rows.Err()
+}
+
+// SuggestRetrievalTuning analyzes the rated turns of the last `days` days.
+func SuggestRetrievalTuning(cfg Config, db *sql.DB, days, minDown int) (TuneReport, error) {
+	if days <= 0 {
+		days = tuneDefaultDays
+	}
+	if minDown <= 0 {
+		minDown = tuneDefaultMinDown
+	}
+	rep := TuneReport{
+		Since:       time.Now().In(cfg.Location).AddDate(0, 0, -(days - 1)).Format("2006-01-02"),
+		Suggestions: []TuneSuggestion{},
+	}
+	if db == nil {
+		return rep, nil
+	}
+	turns, noAudit, err := loadTuneTurns(db, rep.Since)
+	if err != nil {
+		return rep, err
+	}
+	rep.NoAudit = noAudit
+	rep.Rated = len(turns) + noAudit
+	for _, t := range turns {
+		if t.down {
+			rep.Down++
+		} else {
+			rep.Up++
+		}
+	}
+	if noAudit > 0 && !cfg.ContextAuditPersist {
+		rep.Notes = append(rep.Notes, fmt.Sprintf("%d rated turns have no stored context audit; set TIMELAYER_CONTEXT_AUDIT_PERSIST=1 so future turns can be analyzed", noAudit))
+	}
+	if rep.Down < minDown {
+		rep.Notes = append(rep.Notes, fmt.Sprintf("only %d thumbs-down turns with an audit since %s (need %d)", rep.Down, rep.Since, minDown))
+		return rep, nil
+	}
+
+	rule := func(s TuneSuggestion, minRate float64, applies, match func(tuneTurn) bool) {
+		for _, t := range turns {
+			if !applies(t) {
+				continue
+			}
+			if t.down {
+				s.DownN++
+				if match(t) {
+					s.DownHits++
+					if len(s.Turns) < tuneSampleTurns {
+						s.Turns = append(s.Turns, t.id)
+					}
+				}
+			} else {
+				s.UpN++
+				if match(t) {
+					s.UpHits++
+				}
+			}
+		}
+		if s.DownN < minDown {
+			return
+		}
+		s.DownRate = float64(s.DownHits) / float64(s.DownN)
+		if s.UpN > 0 {
+			s.UpRate = float64(s.UpHits) / float64(s.UpN)
+		}
+		if s.DownRate < minRate || s.DownRate-s.UpRate < tuneMinRateGap {
+			return
+		}
+		s.Message = fmt.Sprintf("in %.0f%% of thumbs-down turns (%d/%d), %s (thumbs-up: %.0f%%) — consider %s",
+			100*s.DownRate, s.DownHits, s.DownN, s.Message, 100*s.UpRate, s.Suggested)
+		rep.Suggestions = append(rep.Suggestions, s)
+	}
+	all := func(tuneTurn) bool { return true }
+	withSearch := func(t tuneTurn) bool { return t.search }
+
+	if next := math.Round((cfg.SearchMinScore-0.1)*100) / 100; next >= 0.3 {
+		rule(TuneSuggestion{
+			Rule: "weak_hits", Param: "SearchMinScore", Env: "TIMELAYER_SEARCH_MIN_SCORE",
+			Current:   fmt.Sprintf("%.2f", cfg.SearchMinScore),
+			Suggested: fmt.Sprintf("lowering SearchMinScore to %.2f", next),
+			Message:   fmt.Sprintf("no search hit exceeded %.2f", tuneStrongScore),
+		}, 0.5, all, func(t tuneTurn) bool { return t.top < tuneStrongScore })
+	}
+	if cfg.EnableRerank && cfg.RerankMode != "always" {
+		rule(TuneSuggestion{
+			Rule: "rerank_skipped", Param: "RerankMode", Env: "TIMELAYER_RERANK_MODE",
+			Current:   cfg.RerankMode,
+			Suggested: "TIMELAYER_RERANK_MODE=always (or lower TIMELAYER_SEARCH_MIN_STRONG)",
+			Message:   "the rerank gate skipped the search hits",
+		}, 0.5, withSearch, func(t tuneTurn) bool { return strings.HasPrefix(t.gate, "skipped:") })
+	}
+	rule(TuneSuggestion{
+		Rule: "topk_full", Param: "SearchTopK", Env: "TIMELAYER_SEARCH_TOP_K",
+		Current:   strconv.Itoa(cfg.SearchTopK),
+		Suggested: fmt.Sprintf("raising SearchTopK to %d", cfg.SearchTopK+2),
+		Message:   fmt.Sprintf("all %d search slots were filled", cfg.SearchTopK),
+	}, 0.5, withSearch, func(t tuneTurn) bool { return t.included >= cfg.SearchTopK })
+	if cfg.MaxContextTokens > 0 {
+		rule(TuneSuggestion{
+			Rule: "budget_drop", Param: "MaxContextTokens", Env: "TIMELAYER_MAX_CONTEXT_TOKENS",
+			Current:   strconv.Itoa(cfg.MaxContextTokens),
+			Suggested: fmt.Sprintf("a larger context (TIMELAYER_MAX_CONTEXT_TOKENS=%d, if the model supports it)", cfg.MaxContextTokens*3/2),
+			Message:   "a memory block was dropped over the token budget",
+		}, 0.3, all, func(t tuneTurn) bool { return t.dropped })
+	}
+	if len(rep.Suggestions) == 0 {
+		rep.Notes = append(rep.Notes, "no rule separates thumbs-down from thumbs-up turns; the retrieval settings look fine for this sample")
+	}
+	return rep, nil
+}
+
+func renderTuneReport(rep TuneReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rated turns since %s: %d (👍 %d, 👎 %d, without audit %d)\n", rep.Since, rep.Rated, rep.Up, rep.Down, rep.NoAudit)
+	for i, s := range rep.Suggestions {
+		fmt.Fprintf(&b, "\n%d. [%s] %s\n", i+1, s.Rule, s.Message)
+		fmt.Fprintf(&b, "   %s (%s) is %s; evidence turns: %s\n", s.Param, s.Env, s.Current, strings.Join(s.Turns, ", "))
+	}
+	for _, n := range rep.Notes {
+		fmt.Fprintf(&b, "\nnote: %s\n", n)
+	}
+	return b.String()
+}
+
+// runTuneCLI implements `local-ai tune suggest [--days=30] [--min=5] [--json]`.
+func runTuneCLI(cfg Config, args []string) int {
+	days, minDown, asJSON := tuneDefaultDays, tuneDefaultMinDown, false
+	if len(args) == 0 || args[0] != "suggest" {
+		fmt.Println("usage: local-ai tune suggest [--days=30] [--min=5] [--json]")
+		return 2
+	}
+	for _, arg := range args[1:] {
+		k, v, _ := strings.Cut(arg, "=")
+		switch k {
+		case "--days":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > tuneMaxDays {
+				fmt.Printf("--days needs 1..%d\n", tuneMaxDays)
+				return 2
+			}
+			days = n
+		case "--min":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				fmt.Println("--min needs a positive number")
+				return 2
+			}
+			minDown = n
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Println("unknown flag:", arg)
+			return 2
+		}
+	}
+
+	db := mustOpenDB(cfg)
+	defer closeDB(db)
+	rep, err := SuggestRetrievalTuning(cfg, db, days, minDown)
+	if err != nil {
+		fmt.Println("[error]", err)
+		return 1
+	}
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+		return 0
+	}
+	fmt.Print(renderTuneReport(rep))
+	return 0
+}
@@ -18,6 +18,7 @@ User Fact Extraction (V2)
 type RawLine struct {
 	Role    string
 	Content string
+	User    string // "" = primary user (users.go)
 }
 
 /*
@@ -124,8 +125,12 @@ Fact 主体抽取（关键）
 -------------------------
 */
 
-// 从自然语言中抽取“现实对象主体”
+// 从自然语言中抽取“现实对象主体”（已按 subject_aliases 归一）
 func extractFactSubject(fact string) string {
+	return resolveSubjectAlias(currentSubjectAliases(), extractRawFactSubject(fact))
+}
+
+func extractRawFactSubject(fact string) string {
 	fact = strings.TrimSpace(fact)
 
 	if i := strings.Index(fact, "就是"); i > 0 {
@@ -139,7 +144,12 @@ func extractFactSubject(fact string) string {
 
 // ✅ 从“主体”派生稳定 fact_key（根解法）
 func deriveFactKeyFromSubject(content string) string {
-	subject := extractFactSubject(content)
+	return deriveFactKeyWithAliases(content, currentSubjectAliases())
+}
+
+// deriveFactKeyWithAliases is deriveFactKeyFromSubject with an explicit alias set (dry runs).
+func deriveFactKeyWithAliases(content string, aliases map[string]string) string {
+	subject := resolveSubjectAlias(aliases, extractRawFactSubject(content))
 	if subject == "" {
 		// 没有明确主体的事实，退回全文（行为与现在一致）
 		return normalizeFactKey(content)
@@ -218,17 +228,25 @@ func upsertEmbeddingFromText(cfg Config, db *sql.DB, summaryID int64, text strin
 	}
 
 	now := time.Now().In(cfg.Location).Format(time.RFC3339)
-	return upsertEmbedding(db, summaryID, vec, l2, now)
+	if err := upsertEmbedding(db, summaryID, vec, l2, now); err != nil {
+		return err
+	}
+	noteEmbeddingWrite(cfg, db, len(vec))
+	vectorIndexPut(cfg, db, summaryID, vec)
+	return nil
 }
 
-func removeFactFromSearch(db *sql.DB, factKey string, reason string) {
+// removeFactFromSearch deletes the fact's search row and its embedding
+// (syncFactToSearch recreates both when the fact is remembered again).
+func removeFactFromSearch(db *sql.DB, factKey string) {
 	summaryKey := "fact:" + factKey
 
 	row := db.QueryRow(`SELECT id FROM summaries WHERE type='fact' AND period_key=?`, summaryKey)
 	var id int64
 	if err := row.Scan(&id); err == nil && id > 0 {
 		_ = deleteEmbedding(db, id)
-		_, _ = db.Exec(`UPDATE summaries SET source_path=? WHERE id=?`, reason, id)
+		_, _ = db.Exec(`DELETE FROM summaries WHERE id=?`, id)
+		bumpMemoryVersion()
 	}
 }
 
@@ -255,26 +273,26 @@ func RememberFactWithOutcome(lw *LogWriter, cfg Config, db *sql.DB, content stri
 		return nil, err
 	}
 
-	// 4️⃣ raw 日志
-	if lw != nil {
+	// 4️⃣ raw 日志（命中禁存规则的内容不落盘）
+	if lw != nil && (out == nil || out.Status != "blocked") {
 		if out != nil && out.Status == "conflict" {
-			_ = lw.WriteRecord(map[string]string{
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{
 				"role":    "user",
 				"content": "我提出一个可能的事实：" + content,
-			})
-			_ = lw.WriteRecord(map[string]string{
+			}))
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{
 				"role":    "assistant",
 				"content": "我记录了一个事实确认冲突，需要你在 FACTS 面板里裁决后才会晋升为长期事实。",
-			})
+			}))
 		} else {
-			_ = lw.WriteRecord(map[string]string{
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{
 				"role":    "user",
 				"content": "我确认一个事实：" + content,
-			})
-			_ = lw.WriteRecord(map[string]string{
+			}))
+			_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{
 				"role":    "assistant",
 				"content": "我理解了，你提到" + content,
-			})
+			}))
 		}
 	}
 
@@ -327,14 +345,14 @@ func ForgetFact(lw *LogWriter, cfg Config, db *sql.DB, content string) error {
 	}
 
 	if lw != nil {
-		_ = lw.WriteRecord(map[string]string{
+		_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{
 			"role":    "user",
 			"content": "我撤回之前的事实：" + content,
-		})
-		_ = lw.WriteRecord(map[string]string{
+		}))
+		_ = lw.WriteRecord(withRecordFields(cfg, map[string]string{
 			"role":    "assistant",
 			"content": "我理解了，你明确表示之前关于「" + content + "」的事实不再成立。",
-		})
+		}))
 	}
 
 	return nil
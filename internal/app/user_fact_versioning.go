@@ -27,11 +27,19 @@ type UserFactConflict struct {
 }
 
 type UserFactRow struct {
-	FactKey   string `json:"fact_key"`
-	Fact      string `json:"fact"`
-	IsActive  bool   `json:"is_active"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	FactKey     string   `json:"fact_key"`
+	Fact        string   `json:"fact"`
+	IsActive    bool     `json:"is_active"`
+	IsCore      bool     `json:"is_core"`            // always injected (fact_core.go)
+	Category    string   `json:"category,omitempty"` // identity / family / work / ... (fact_category.go)
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+	Tags        []string `json:"tags,omitempty"`
+	InjectCount int      `json:"inject_count"`           // times injected into chat context
+	SlotHits    int      `json:"slot_hits"`              // times matched by slot / value set lookup
+	LastUsedAt  string   `json:"last_used_at,omitempty"` // either of the above
+
+	Provenance *FactProvenance `json:"provenance,omitempty"` // /api/facts/active (fact_provenance.go)
 }
 
 type UserFactHistoryRow struct {
@@ -49,7 +57,7 @@ func getActiveUserFactByKey(db dbTX, factKey string) (fact string, ok bool) {
 	if db == nil || factKey == "" {
 		return "", false
 	}
-	row := db.QueryRow(`SELECT fact FROM user_facts WHERE fact_key=? AND is_active=1 LIMIT 1`, factKey)
+	row := stmtQueryRow(db, `SELECT fact FROM user_facts WHERE fact_key=? AND is_active=1 LIMIT 1`, factKey)
 	if err := row.Scan(&fact); err != nil {
 		return "", false
 	}
@@ -61,15 +69,15 @@ func getActiveUserFactByKey(db dbTX, factKey string) (fact string, ok bool) {
 //
 // NOTE: slotKey is produced by FactTriple.SlotKey(). It is non-empty only for conservative,
 // single-valued relations (e.g. name/email/phone/identity/location/job).
-func getActiveUserFactBySlotKey(db dbTX, slotKey string) (factKey, fact string, ok bool) {
+// Only user's facts are considered (users.go).
+func getActiveUserFactBySlotKey(db dbTX, user, slotKey string) (factKey, fact string, ok bool) {
 	if db == nil || slotKey == "" {
 		return "", "", false
 	}
-	rows, err := db.Query(`SELECT fact_key, fact FROM user_facts WHERE is_active=1`)
+	rows, err := db.Query(`SELECT fact_key, fact FROM user_facts WHERE is_active=1 AND user_id=?`, user)
 	if err != nil {
 		return "", "", false
 	}
-	defer rows.Close()
 	for rows.Next() {
 		var k, f string
 		if err := rows.Scan(&k, &f); err != nil {
@@ -77,10 +85,15 @@ func getActiveUserFactBySlotKey(db dbTX, slotKey string) (factKey, fact string,
 		}
 		tr := ExtractFactTriple(f)
 		if tr.SlotKey() == slotKey {
-			return k, f, true
+			factKey, fact, ok = k, f, true
+			break
 		}
 	}
-	return "", "", false
+	rows.Close()
+	if ok {
+		recordFactSlotHit(db, factKey)
+	}
+	return factKey, fact, ok
 }
 
 func nextUserFactVersion(db dbTX, factKey string) int {
@@ -162,22 +175,43 @@ func CountFactConflicts(db *sql.DB) int {
 	return n
 }
 
-func ListFactConflicts(db *sql.DB, limit int) ([]UserFactConflict, error) {
+// ListFactConflicts lists open conflicts on user's facts ("" = primary).
+func ListFactConflicts(db *sql.DB, user string, limit int) ([]UserFactConflict, error) {
+	return ListFactConflictsAfter(db, user, 0, limit)
+}
+
+// ListFactConflictsAfter is ListFactConflicts continuing after conflict afterID (newest first).
+func ListFactConflictsAfter(db *sql.DB, user string, afterID int64, limit int) ([]UserFactConflict, error) {
 	if db == nil {
 		return nil, nil
 	}
 	if limit <= 0 {
 		limit = 50
 	}
-	rows, err := db.Query(`
+	// the key names the user (users.go)
+	where, args := userKeyWhere("fact_key", user)
+	where = "status='conflict' AND " + where
+	if afterID > 0 {
+		var created string
+		err := readDB(db).QueryRow(`SELECT created_at FROM user_fact_conflicts WHERE id=?`, afterID).Scan(&created)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errUnknownCursor
+		}
+		if err != nil {
+			return nil, err
+		}
+		where += " AND (created_at<? OR (created_at=? AND id<?))"
+		args = append(args, created, created, afterID)
+	}
+	rows, err := readDB(db).Query(`
         SELECT id, fact_key, existing_fact, proposed_fact,
                proposed_source_type, proposed_source_key,
                status, created_at, updated_at
         FROM user_fact_conflicts
-        WHERE status='conflict'
-        ORDER BY created_at DESC
+        WHERE `+where+`
+        ORDER BY created_at DESC, id DESC
         LIMIT ?
-    `, limit)
+    `, append(args, limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -186,25 +220,36 @@ func ListFactConflicts(db *sql.DB, limit int) ([]UserFactConflict, error) {
 	for rows.Next() {
 		var c UserFactConflict
 		if err := rows.Scan(&c.ID, &c.FactKey, &c.ExistingFact, &c.ProposedFact, &c.ProposedSourceType, &c.ProposedSourceKey, &c.Status, &c.CreatedAt, &c.UpdatedAt); err != nil {
-			continue
+			return nil, err
 		}
 		out = append(out, c)
 	}
-	return out, nil
+	return out, rows.Err()
+}
+
+// CountUserFactConflicts counts user's open conflicts (CountFactConflicts is instance-wide).
+func CountUserFactConflicts(db *sql.DB, user string) (int, error) {
+	if db == nil {
+		return 0, nil
+	}
+	where, args := userKeyWhere("fact_key", user)
+	var n int
+	err := readDB(db).QueryRow(`SELECT COUNT(*) FROM user_fact_conflicts WHERE status='conflict' AND `+where, args...).Scan(&n)
+	return n, err
 }
 
-func ListActiveFacts(db *sql.DB, limit int) ([]UserFactRow, error) {
+func ListActiveFacts(db *sql.DB, user string, limit int) ([]UserFactRow, error) {
 	if db == nil {
 		return nil, nil
 	}
 	if limit <= 0 {
 		limit = 50
 	}
-	rows, err := db.Query(`SELECT fact_key, fact, is_active, created_at, updated_at
+	rows, err := readDB(db).Query(`SELECT fact_key, fact, is_active, COALESCE(is_core,0), COALESCE(category,''), created_at, updated_at, `+factUsageCols+`
 FROM user_facts
-WHERE is_active = 1
+WHERE is_active = 1 AND user_id = ?
 ORDER BY updated_at DESC
-LIMIT ?`, limit)
+LIMIT ?`, user, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -212,30 +257,55 @@ LIMIT ?`, limit)
 	var out []UserFactRow
 	for rows.Next() {
 		var r UserFactRow
-		var active int
-		if err := rows.Scan(&r.FactKey, &r.Fact, &active, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		var active, core int
+		if err := rows.Scan(&r.FactKey, &r.Fact, &active, &core, &r.Category, &r.CreatedAt, &r.UpdatedAt, &r.InjectCount, &r.SlotHits, &r.LastUsedAt); err != nil {
 			return nil, err
 		}
 		r.IsActive = active != 0
+		r.IsCore = core != 0
 		out = append(out, r)
 	}
-	return out, nil
+	return attachFactTags(db, out), nil
+}
+
+func ListUserFactHistory(db *sql.DB, user string, limit int) ([]UserFactHistoryRow, error) {
+	return ListUserFactHistoryAfter(db, user, 0, limit)
 }
 
-func ListUserFactHistory(db *sql.DB, limit int) ([]UserFactHistoryRow, error) {
+// userFactHistoryFilter hides legacy rows.
+// NOTE: older versions mistakenly wrote "pending" into user_facts_history.
+// We hide those legacy rows here; pending facts belong to pending_facts (FACTS → PENDING).
+const userFactHistoryFilter = "status != 'pending'"
+
+// ListUserFactHistoryAfter is ListUserFactHistory continuing after history row afterID (newest first).
+// Only user's keys are listed (users.go).
+func ListUserFactHistoryAfter(db *sql.DB, user string, afterID int64, limit int) ([]UserFactHistoryRow, error) {
 	if db == nil {
 		return nil, nil
 	}
 	if limit <= 0 {
 		limit = 200
 	}
-	// NOTE: older versions mistakenly wrote "pending" into user_facts_history.
-	// We hide those legacy rows here; pending facts belong to pending_facts (FACTS → PENDING).
-	rows, err := db.Query(`SELECT id, fact_key, fact, status, version, source_type, source_key, created_at
+	userCond, args := userKeyWhere("fact_key", user)
+	where := userFactHistoryFilter + " AND " + userCond
+	if afterID > 0 {
+		var created string
+		err := readDB(db).QueryRow(`SELECT created_at FROM user_facts_history WHERE id=?`, afterID).Scan(&created)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errUnknownCursor
+		}
+		if err != nil {
+			return nil, err
+		}
+		where += " AND (created_at<? OR (created_at=? AND id<?))"
+		args = append(args, created, created, afterID)
+	}
+	args = append(args, limit)
+	rows, err := readDB(db).Query(`SELECT id, fact_key, fact, status, version, source_type, source_key, created_at
 FROM user_facts_history
-WHERE status != 'pending'
-ORDER BY created_at DESC
-LIMIT ?`, limit)
+WHERE `+where+`
+ORDER BY created_at DESC, id DESC
+LIMIT ?`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -251,6 +321,17 @@ LIMIT ?`, limit)
 	return out, nil
 }
 
+// CountUserFactHistory counts the rows ListUserFactHistory pages through.
+func CountUserFactHistory(db *sql.DB, user string) (int, error) {
+	if db == nil {
+		return 0, nil
+	}
+	userCond, args := userKeyWhere("fact_key", user)
+	var n int
+	err := readDB(db).QueryRow(`SELECT COUNT(1) FROM user_facts_history WHERE `+userFactHistoryFilter+` AND `+userCond, args...).Scan(&n)
+	return n, err
+}
+
 func getFactConflictByID(db dbTX, id int64) (*UserFactConflict, error) {
 	if db == nil || id <= 0 {
 		return nil, nil
@@ -275,6 +356,11 @@ func getFactConflictByID(db dbTX, id int64) (*UserFactConflict, error) {
 // ResolveFactConflictKeep keeps the existing fact; the proposed fact is recorded as rejected.
 // This function is transactional.
 func ResolveFactConflictKeep(db *sql.DB, id int64, now time.Time) error {
+	return resolveFactConflictKeepAs(db, id, "conflict_keep", now)
+}
+
+// resolveFactConflictKeepAs is ResolveFactConflictKeep recording sourceType in the history.
+func resolveFactConflictKeepAs(db *sql.DB, id int64, sourceType string, now time.Time) error {
 	if db == nil || id <= 0 {
 		return errors.New("conflict not found")
 	}
@@ -290,7 +376,7 @@ func ResolveFactConflictKeep(db *sql.DB, id int64, now time.Time) error {
 			}
 
 			// history: proposed fact rejected
-			if err := appendUserFactHistory(tx, c.FactKey, c.ProposedFact, "rejected", "conflict_keep", "conflict:"+itoa64(c.ID), now, 0); err != nil {
+			if err := appendUserFactHistory(tx, c.FactKey, c.ProposedFact, "rejected", sourceType, "conflict:"+itoa64(c.ID), now, 0); err != nil {
 				return err
 			}
 
@@ -304,6 +390,11 @@ func ResolveFactConflictKeep(db *sql.DB, id int64, now time.Time) error {
 // ResolveFactConflictReplace archives existing and replaces with proposed.
 // This function is transactional (the semantic-search sync runs post-commit, best-effort).
 func ResolveFactConflictReplace(cfg Config, db *sql.DB, id int64, replacement string, now time.Time) error {
+	return resolveFactConflictReplaceAs(cfg, db, id, replacement, "conflict_replace", now)
+}
+
+// resolveFactConflictReplaceAs is ResolveFactConflictReplace recording sourceType in the history.
+func resolveFactConflictReplaceAs(cfg Config, db *sql.DB, id int64, replacement, sourceType string, now time.Time) error {
 	if db == nil || id <= 0 {
 		return errors.New("conflict not found")
 	}
@@ -342,11 +433,11 @@ func ResolveFactConflictReplace(cfg Config, db *sql.DB, id int64, replacement st
 
 			// history
 			if strings.TrimSpace(current) != "" {
-				if err := appendUserFactHistory(tx, c.FactKey, current, "archived", "conflict_replace", "conflict:"+itoa64(c.ID), now, 0); err != nil {
+				if err := appendUserFactHistory(tx, c.FactKey, current, "archived", sourceType, "conflict:"+itoa64(c.ID), now, 0); err != nil {
 					return err
 				}
 			}
-			if err := appendUserFactHistory(tx, c.FactKey, repl, "active", "conflict_replace", "conflict:"+itoa64(c.ID), now, 0); err != nil {
+			if err := appendUserFactHistory(tx, c.FactKey, repl, "active", sourceType, "conflict:"+itoa64(c.ID), now, 0); err != nil {
 				return err
 			}
 
@@ -361,7 +452,7 @@ func ResolveFactConflictReplace(cfg Config, db *sql.DB, id int64, replacement st
 
 	// best-effort: keep summaries+embedding aligned with current fact
 	if factKey != "" && replacementTrim != "" {
-		_ = syncFactToSearch(cfg, db, factKey, replacementTrim, "conflict_replace")
+		_ = syncFactToSearch(cfg, db, factKey, replacementTrim, sourceType)
 	}
 	return nil
 }
@@ -0,0 +1,316 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================================
+// Users (several people sharing one instance)
+// - TIMELAYER_USER (CLI / web default) or "user" per chat request selects
+//   whose memory a turn reads and writes. "" is the primary user: every
+//   row written before users existed belongs to them.
+// - Identity: another user's fact keys carry a "@user:<name>" suffix, so
+//   history, tags, conflicts and the "fact:<key>" search docs that follow
+//   the key are per user without schema changes.
+// - user_facts / pending_facts / summaries.user_id is derived from the key
+//   on write (keyUser) and is what reads filter on: remembered facts and
+//   slot lookups, search, the pending / active / conflict lists, the trash,
+//   the summaries list and /api/stats.
+// - Log records carry "user". The daily summary (and everything rolled up
+//   from it) covers the primary user's records only; each other user gets
+//   a "user_daily" summary (key "<date>@user:<name>") that their chat
+//   context and search use, and whose facts go to their pending list.
+// - Weekly / monthly rollups read "daily" only: they cover the primary user.
+// - GET /api/users; /users.
+// ============================================================
+
+const (
+	summaryTypeUserDaily = "user_daily"
+	userKeySep           = "@user:"
+)
+
+var reUserName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// normalizeUserName validates a user name ("" = the primary user).
+func normalizeUserName(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || reUserName.MatchString(s) {
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid user %q: use a-z, 0-9, _ or - (max 32)", s)
+}
+
+// userFactKey namespaces a fact key (or slot key) for user.
+func userFactKey(user, key string) string {
+	if user == "" || key == "" || keyUser(key) == user {
+		return key
+	}
+	return key + userKeySep + user
+}
+
+// keyUser is the user a fact key / summary key belongs to ("" = primary).
+func keyUser(key string) string {
+	i := strings.LastIndex(key, userKeySep)
+	if i < 0 {
+		return ""
+	}
+	if u := key[i+len(userKeySep):]; reUserName.MatchString(u) {
+		return u
+	}
+	return ""
+}
+
+// userKeyWhere is keyUser(col) == user as a SQL condition, for queries that
+// page or count in SQL and so cannot filter the keys in Go.
+func userKeyWhere(col, user string) (string, []any) {
+	if user == "" {
+		return "instr(" + col + ", ?) = 0", []any{userKeySep}
+	}
+	suffix := userKeySep + user
+	return "substr(" + col + ", -" + strconv.Itoa(len(suffix)) + ") = ?", []any{suffix}
+}
+
+// deriveUserFactKey is deriveFactKeyFromSubject for the user of cfg.
+func deriveUserFactKey(cfg Config, content string) string {
+	return userFactKey(cfg.User, deriveFactKeyFromSubject(content))
+}
+
+// ensureUsersSchema adds user_id for older DBs (best-effort).
+func ensureUsersSchema(db *sql.DB) error {
+	if db == nil {
+		return nil
+	}
+	for _, table := range []string{"user_facts", "pending_facts", "summaries"} {
+		if err := addColumnIfMissing(db, table, "user_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_user_facts_user ON user_facts(user_id, is_active)`)
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_pending_facts_user ON pending_facts(user_id, status)`)
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_summaries_user ON summaries(user_id, type)`)
+	return nil
+}
+
+// filterUserJSONL keeps the records of user ("" = records without "user").
+func filterUserJSONL(b []byte, user string) []byte {
+	var out []byte
+	scanJSONL(b, func(line []byte) {
+		var rec struct {
+			User string `json:"user"`
+		}
+		if json.Unmarshal(line, &rec) == nil && rec.User == user {
+			out = append(append(out, line...), '\n')
+		}
+	})
+	return out
+}
+
+// usersInJSONL lists the non-primary users with at least one record.
+func usersInJSONL(b []byte) []string {
+	seen := map[string]bool{}
+	var out []string
+	scanJSONL(b, func(line []byte) {
+		var rec struct {
+			User string `json:"user"`
+		}
+		if json.Unmarshal(line, &rec) == nil && rec.User != "" && !seen[rec.User] {
+			seen[rec.User] = true
+			out = append(out, rec.User)
+		}
+	})
+	return out
+}
+
+func userDailyKey(date, user string) string { return date + userKeySep + user }
+
+// ensureUserDailies builds one user_daily summary per non-primary user of date.
+// Each keeps the hash of its own records, so another user's chat never
+// regenerates it. Best effort per user.
+func ensureUserDailies(cfg Config, db *sql.DB, date string, force bool) {
+	raw, err := readRawDay(cfg, db, date)
+	if err != nil || len(raw) == 0 {
+		return
+	}
+	raw, _ = filterDialogJSONL(raw)
+	for _, user := range usersInJSONL(raw) {
+		if err := ensureUserDaily(cfg, db, date, user, filterUserJSONL(raw, user), force); err != nil {
+			log.Printf("[warn] user daily %s: %v", userDailyKey(date, user), err)
+		}
+	}
+}
+
+func ensureUserDaily(cfg Config, db *sql.DB, date, user string, sub []byte, force bool) error {
+	key := userDailyKey(date, user)
+	hash := sha256Hex(string(sub))
+	if !force {
+		var stored string
+		var deleted bool
+		err := db.QueryRow(`SELECT COALESCE(source_hash,''), deleted_at IS NOT NULL FROM summaries WHERE type=? AND period_key=?`,
+			summaryTypeUserDaily, key).Scan(&stored, &deleted)
+		if err == nil && (deleted || stored == hash) {
+			return nil
+		}
+	}
+	if shouldSkipDaily(cfg, measureDailyActivity(sub)) {
+		return nil
+	}
+	sub = maskSecretsJSONL(cfg, sub)
+	js, _, err := summarizeWithFallback(cfg, db, summaryTypeUserDaily, key,
+		func() (string, error) { return generateDailyJSON(cfg, db, date, sub) },
+		func() (string, error) { return extractiveDailyJSON(date, sub) },
+	)
+	if err != nil {
+		return err
+	}
+	indexText := extractIndexText(js)
+	if _, err := upsertSummary(db, cfg, summaryTypeUserDaily, key, date, date, js, indexText, ""); err != nil {
+		return err
+	}
+	_ = setSummarySourceHash(db, summaryTypeUserDaily, key, hash)
+	dropSummaryEmbedding(db, summaryTypeUserDaily, key)
+	if err := ensureEmbedding(db, cfg, indexText, summaryTypeUserDaily, key); err != nil {
+		log.Printf("[warn] ensureEmbedding failed for %s %s: %v", summaryTypeUserDaily, key, err)
+	}
+
+	ucfg := cfg
+	ucfg.User = user
+	return EnsurePendingFactsFromDailyJSON(ucfg, db, date, js)
+}
+
+// regenUserDaily rebuilds one user_daily (bg regen after an extractive fallback).
+func regenUserDaily(cfg Config, db *sql.DB, key string) error {
+	date, user, ok := strings.Cut(key, userKeySep)
+	if !ok {
+		return fmt.Errorf("invalid user daily key: %s", key)
+	}
+	raw, err := readRawDay(cfg, db, date)
+	if err != nil {
+		return err
+	}
+	raw, _ = filterDialogJSONL(raw)
+	return ensureUserDaily(cfg, db, date, user, filterUserJSONL(raw, user), true)
+}
+
+// userDailySourceChanged reports whether the user's records of a user_daily
+// no longer match its source_hash (e.g. a redacted message).
+func userDailySourceChanged(cfg Config, db *sql.DB, key string) bool {
+	date, user, ok := strings.Cut(key, userKeySep)
+	if !ok {
+		return false
+	}
+	var stored string
+	var deleted bool
+	_ = db.QueryRow(`SELECT COALESCE(source_hash,''), deleted_at IS NOT NULL FROM summaries WHERE type=? AND period_key=?`,
+		summaryTypeUserDaily, key).Scan(&stored, &deleted)
+	if deleted || stored == "" {
+		return false
+	}
+	raw, err := readRawDay(cfg, db, date)
+	if err != nil {
+		return false
+	}
+	raw, _ = filterDialogJSONL(raw)
+	return sha256Hex(string(filterUserJSONL(raw, user))) != stored
+}
+
+// loadUserDailySummary is loadDailySummary for a non-primary user.
+func loadUserDailySummary(db *sql.DB, user, date string) string {
+	var js string
+	_ = readDB(db).QueryRow(`SELECT json FROM summaries WHERE type=? AND period_key=? AND deleted_at IS NULL`,
+		summaryTypeUserDaily, userDailyKey(date, user)).Scan(&js)
+	return strings.TrimSpace(js)
+}
+
+// dailySummaryRef is the summary a user's "daily" of date is stored as.
+func dailySummaryRef(cfg Config, date string) summaryRef {
+	if cfg.User == "" {
+		return summaryRef{"daily", date}
+	}
+	return summaryRef{summaryTypeUserDaily, userDailyKey(date, cfg.User)}
+}
+
+type UserInfo struct {
+	User      string `json:"user"` // "" = primary
+	Facts     int    `json:"facts"`
+	Pending   int    `json:"pending"`
+	Summaries int    `json:"summaries"`
+}
+
+// ListUsers lists every user with memory (the primary user first).
+func ListUsers(db *sql.DB) ([]UserInfo, error) {
+	by := map[string]*UserInfo{"": {}}
+	count := func(q string, set func(*UserInfo, int)) error {
+		rows, err := readDB(db).Query(q)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var u string
+			var n int
+			if err := rows.Scan(&u, &n); err != nil {
+				return err
+			}
+			if by[u] == nil {
+				by[u] = &UserInfo{User: u}
+			}
+			set(by[u], n)
+		}
+		return rows.Err()
+	}
+	if err := count(`SELECT user_id, COUNT(*) FROM user_facts WHERE is_active=1 GROUP BY user_id`,
+		func(u *UserInfo, n int) { u.Facts = n }); err != nil {
+		return nil, err
+	}
+	if err := count(`SELECT user_id, COUNT(*) FROM pending_facts WHERE status='pending' GROUP BY user_id`,
+		func(u *UserInfo, n int) { u.Pending = n }); err != nil {
+		return nil, err
+	}
+	if err := count(`SELECT user_id, COUNT(*) FROM summaries WHERE deleted_at IS NULL AND type<>'fact' GROUP BY user_id`,
+		func(u *UserInfo, n int) { u.Summaries = n }); err != nil {
+		return nil, err
+	}
+	out := make([]UserInfo, 0, len(by))
+	for _, u := range by {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].User < out[j].User })
+	return out, nil
+}
+
+// runUsersCommand implements /users (list).
+func runUsersCommand(cfg Config, db *sql.DB) (string, error) {
+	items, err := ListUsers(db)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, u := range items {
+		mark := " "
+		if u.User == cfg.User {
+			mark = "*"
+		}
+		name := u.User
+		if name == "" {
+			name = "(primary)"
+		}
+		fmt.Fprintf(&b, "%s %s facts=%d pending=%d summaries=%d\n", mark, name, u.Facts, u.Pending, u.Summaries)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// requestUser is the ?user= of a web request (default: TIMELAYER_USER).
+func requestUser(cfg Config, r *http.Request) (string, error) {
+	if v := r.URL.Query().Get("user"); v != "" {
+		return normalizeUserName(v)
+	}
+	return cfg.User, nil
+}
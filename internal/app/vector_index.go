@@ -0,0 +1,505 @@
+package app
+
+import (
+	"container/heap"
+	"database/sql"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// In-memory vector index (HNSW)
+// - SearchWithScore scanned every embedding row and computed the cosine in
+//   Go on each query. Past TIMELAYER_VECTOR_INDEX_MIN_ROWS vectors (per user
+//   and dimension) it asks this index for the nearest candidates instead and
+//   re-scores only those (plus the full-text hits) exactly from SQLite.
+// - Built in the background at startup (search full-scans until it is
+//   ready); ensureEmbedding / upsertEmbedding add vectors incrementally.
+// - A deleted embedding (deleteEmbedding: trash, regen) retires its node: it
+//   stays in the graph for navigation but is neither counted live nor
+//   returned. Bulk deletes behind its back (repair, wipe) never reach a result
+//   either: candidates are re-read from the DB. A re-embedded summary
+//   replaces its node; when replaced nodes outnumber live ones the index is
+//   rebuilt.
+// - Approximate: a scoped search (tags) filters after the candidate cut.
+//   TIMELAYER_VECTOR_INDEX_MIN_ROWS=0 disables the index.
+// ============================================================
+
+const (
+	defaultVectorIndexMinRows = 2000
+
+	hnswM              = 16  // links per node (2*M on layer 0)
+	hnswEfConstruction = 64  // candidate list while inserting
+	hnswEfSearch       = 128 // candidate list while searching
+	vectorIndexK       = 200 // ANN candidates re-scored per query
+)
+
+type hnswNode struct {
+	id       int64
+	vec      []float32 // unit length
+	links    [][]int32 // per layer
+	replaced bool      // superseded by a newer vector of the same summary, or deleted
+}
+
+type hnswIndex struct {
+	mu       sync.RWMutex
+	dim      int
+	nodes    []hnswNode
+	byID     map[int64]int32
+	entry    int32
+	maxLevel int
+	live     int
+	rng      *rand.Rand
+}
+
+func newHNSWIndex(dim int) *hnswIndex {
+	return &hnswIndex{
+		dim:   dim,
+		byID:  map[int64]int32{},
+		entry: -1,
+		rng:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func unitVec(v []float32) []float32 {
+	var s float64
+	for _, x := range v {
+		s += float64(x) * float64(x)
+	}
+	if s == 0 || math.IsNaN(s) || math.IsInf(s, 0) {
+		return nil
+	}
+	inv := float32(1 / math.Sqrt(s))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
+
+func dot32(a, b []float32) float32 {
+	var s float32
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+type hnswCand struct {
+	idx int32
+	sim float32
+}
+
+// hnswBest pops the most similar candidate first, hnswWorst the least similar.
+type hnswBest []hnswCand
+type hnswWorst []hnswCand
+
+func (h hnswBest) Len() int            { return len(h) }
+func (h hnswBest) Less(i, j int) bool  { return h[i].sim > h[j].sim }
+func (h hnswBest) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswBest) Push(x any)         { *h = append(*h, x.(hnswCand)) }
+func (h *hnswBest) Pop() any           { old := *h; x := old[len(old)-1]; *h = old[:len(old)-1]; return x }
+func (h hnswWorst) Len() int           { return len(h) }
+func (h hnswWorst) Less(i, j int) bool { return h[i].sim < h[j].sim }
+func (h hnswWorst) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswWorst) Push(x any)        { *h = append(*h, x.(hnswCand)) }
+func (h *hnswWorst) Pop() any          { old := *h; x := old[len(old)-1]; *h = old[:len(old)-1]; return x }
+
+// greedy walks layer l from ep to the node most similar to q.
+func (h *hnswIndex) greedy(q []float32, ep int32, l int) int32 {
+	cur, curSim := ep, dot32(q, h.nodes[ep].vec)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range h.nodes[cur].links[l] {
+			if s := dot32(q, h.nodes[nb].vec); s > curSim {
+				cur, curSim, changed = nb, s, true
+			}
+		}
+	}
+	return cur
+}
+
+// searchLayer returns up to ef nodes of layer l most similar to q, best first.
+func (h *hnswIndex) searchLayer(q []float32, eps []int32, ef, l int) []hnswCand {
+	visited := make([]uint64, len(h.nodes)/64+1)
+	seen := func(i int32) bool {
+		w, b := i/64, uint64(1)<<(i%64)
+		if visited[w]&b != 0 {
+			return true
+		}
+		visited[w] |= b
+		return false
+	}
+	var cands hnswBest
+	var res hnswWorst
+	for _, ep := range eps {
+		seen(ep)
+		c := hnswCand{ep, dot32(q, h.nodes[ep].vec)}
+		heap.Push(&cands, c)
+		heap.Push(&res, c)
+	}
+	for cands.Len() > 0 {
+		c := heap.Pop(&cands).(hnswCand)
+		if res.Len() >= ef && c.sim < res[0].sim {
+			break
+		}
+		for _, nb := range h.nodes[c.idx].links[l] {
+			if seen(nb) {
+				continue
+			}
+			s := dot32(q, h.nodes[nb].vec)
+			if res.Len() < ef || s > res[0].sim {
+				heap.Push(&cands, hnswCand{nb, s})
+				heap.Push(&res, hnswCand{nb, s})
+				if res.Len() > ef {
+					heap.Pop(&res)
+				}
+			}
+		}
+	}
+	out := []hnswCand(res)
+	sort.Slice(out, func(i, j int) bool { return out[i].sim > out[j].sim })
+	return out
+}
+
+// Insert adds (or replaces) the vector of summary id.
+func (h *hnswIndex) Insert(id int64, vec []float32) {
+	u := unitVec(vec)
+	if len(u) != h.dim {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if old, ok := h.byID[id]; ok && !h.nodes[old].replaced {
+		h.nodes[old].replaced = true
+		h.live--
+	}
+	level := int(-math.Log(1-h.rng.Float64()) / math.Log(hnswM))
+	idx := int32(len(h.nodes))
+	h.nodes = append(h.nodes, hnswNode{id: id, vec: u, links: make([][]int32, level+1)})
+	h.byID[id] = idx
+	h.live++
+	if h.entry < 0 {
+		h.entry, h.maxLevel = idx, level
+		return
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(u, ep, l)
+	}
+	eps := []int32{ep}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		w := h.searchLayer(u, eps, hnswEfConstruction, l)
+		maxLinks := hnswM
+		if l == 0 {
+			maxLinks = 2 * hnswM
+		}
+		for _, c := range h.selectNeighbors(w, hnswM) {
+			h.nodes[idx].links[l] = append(h.nodes[idx].links[l], c.idx)
+			h.nodes[c.idx].links[l] = append(h.nodes[c.idx].links[l], idx)
+			if len(h.nodes[c.idx].links[l]) > maxLinks {
+				h.prune(c.idx, l, maxLinks)
+			}
+		}
+		eps = eps[:0]
+		for _, c := range w {
+			eps = append(eps, c.idx)
+		}
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = idx, level
+	}
+}
+
+// selectNeighbors picks up to n of cands (best first), preferring ones that are
+// closer to the new node than to an already picked neighbor (HNSW heuristic),
+// so links spread in all directions instead of into one cluster.
+func (h *hnswIndex) selectNeighbors(cands []hnswCand, n int) []hnswCand {
+	if len(cands) <= n {
+		return cands
+	}
+	out := make([]hnswCand, 0, n)
+	var skipped []hnswCand
+	for _, c := range cands {
+		if len(out) >= n {
+			break
+		}
+		keep := true
+		for _, o := range out {
+			if dot32(h.nodes[c.idx].vec, h.nodes[o.idx].vec) > c.sim {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+	for _, c := range skipped {
+		if len(out) >= n {
+			break
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// prune keeps the n links of node i on layer l closest to it.
+func (h *hnswIndex) prune(i int32, l, n int) {
+	v := h.nodes[i].vec
+	cands := make([]hnswCand, 0, len(h.nodes[i].links[l]))
+	for _, nb := range h.nodes[i].links[l] {
+		cands = append(cands, hnswCand{nb, dot32(v, h.nodes[nb].vec)})
+	}
+	sort.Slice(cands, func(a, b int) bool { return cands[a].sim > cands[b].sim })
+	links := h.nodes[i].links[l][:0]
+	for _, c := range cands[:n] {
+		links = append(links, c.idx)
+	}
+	h.nodes[i].links[l] = links
+}
+
+// Remove retires the vector of summary id (its embedding was deleted).
+func (h *hnswIndex) Remove(id int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i, ok := h.byID[id]; ok && !h.nodes[i].replaced {
+		h.nodes[i].replaced = true
+		h.live--
+	}
+}
+
+// Search returns the ids of up to k live vectors most similar to q.
+func (h *hnswIndex) Search(q []float32, k int) []int64 {
+	u := unitVec(q)
+	if len(u) != h.dim {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.entry < 0 {
+		return nil
+	}
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(u, ep, l)
+	}
+	out := make([]int64, 0, k)
+	for _, c := range h.searchLayer(u, []int32{ep}, max(hnswEfSearch, k), 0) {
+		if len(out) >= k {
+			break
+		}
+		if !h.nodes[c.idx].replaced {
+			out = append(out, h.nodes[c.idx].id)
+		}
+	}
+	return out
+}
+
+// Live is the number of current vectors; stale reports that replaced nodes dominate.
+func (h *hnswIndex) Live() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.live
+}
+
+func (h *hnswIndex) stale() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.nodes)-h.live > h.live && len(h.nodes) > 1000
+}
+
+// ------------------------------------------------------------
+// Process-wide index: one graph per (user, dimension)
+// ------------------------------------------------------------
+
+type vectorIndexKey struct {
+	user string
+	dim  int
+}
+
+type vectorIndexAdd struct {
+	key vectorIndexKey
+	id  int64
+	vec []float32 // nil: the embedding of id was deleted
+}
+
+const (
+	vectorIndexOff = iota
+	vectorIndexBuilding
+	vectorIndexReady
+)
+
+var vectorIndex struct {
+	sync.Mutex
+	state   int
+	byKey   map[vectorIndexKey]*hnswIndex
+	pending []vectorIndexAdd // added / deleted while building, in order
+}
+
+// buildVectorIndex (re)builds the index from the embeddings table.
+func buildVectorIndex(cfg Config, db *sql.DB) {
+	if db == nil || cfg.VectorIndexMinRows <= 0 {
+		return
+	}
+	vectorIndex.Lock()
+	if vectorIndex.state == vectorIndexBuilding {
+		vectorIndex.Unlock()
+		return
+	}
+	vectorIndex.state = vectorIndexBuilding
+	vectorIndex.pending = nil
+	vectorIndex.Unlock()
+
+	start := time.Now()
+	rows, err := readDB(db).Query(`
+		SELECT e.summary_id, s.user_id, e.dim, e.vec
+		FROM embeddings e
+		JOIN summaries s ON s.id = e.summary_id
+		WHERE s.deleted_at IS NULL AND s.type <> ?
+	`, summaryTypeDailyPartial)
+	if err != nil {
+		log.Printf("[warn] vector index build failed: %v", err)
+		vectorIndex.Lock()
+		vectorIndex.state = vectorIndexOff
+		vectorIndex.Unlock()
+		return
+	}
+	// read everything first: the scan must not hold the connection while the graph is built
+	var adds []vectorIndexAdd
+	for rows.Next() {
+		var (
+			a    vectorIndexAdd
+			blob []byte
+		)
+		if rows.Scan(&a.id, &a.key.user, &a.key.dim, &blob) != nil {
+			continue
+		}
+		if a.vec = decodeVecBlob(blob, a.key.dim); a.vec != nil {
+			adds = append(adds, a)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		// a truncated scan would be a partial index marked ready: stay on full scans
+		log.Printf("[warn] vector index build failed: %v", err)
+		vectorIndex.Lock()
+		vectorIndex.state = vectorIndexOff
+		vectorIndex.pending = nil
+		vectorIndex.Unlock()
+		return
+	}
+
+	byKey := map[vectorIndexKey]*hnswIndex{}
+	insert := func(a vectorIndexAdd) {
+		if a.vec == nil {
+			for _, ix := range byKey {
+				ix.Remove(a.id)
+			}
+			return
+		}
+		ix := byKey[a.key]
+		if ix == nil {
+			ix = newHNSWIndex(a.key.dim)
+			byKey[a.key] = ix
+		}
+		ix.Insert(a.id, a.vec)
+	}
+	for _, a := range adds {
+		insert(a)
+	}
+
+	vectorIndex.Lock()
+	for _, a := range vectorIndex.pending {
+		insert(a)
+	}
+	vectorIndex.pending = nil
+	vectorIndex.byKey = byKey
+	vectorIndex.state = vectorIndexReady
+	vectorIndex.Unlock()
+	log.Printf("[info] vector index: %d vectors in %s", len(adds), time.Since(start).Round(time.Millisecond))
+}
+
+// vectorIndexPut adds a freshly written embedding of summary id (best effort).
+func vectorIndexPut(cfg Config, db *sql.DB, id int64, vec []float32) {
+	if db == nil || cfg.VectorIndexMinRows <= 0 || len(vec) == 0 {
+		return
+	}
+	a := vectorIndexAdd{key: vectorIndexKey{dim: len(vec)}, id: id, vec: vec}
+	if err := db.QueryRow(`SELECT user_id FROM summaries WHERE id=?`, id).Scan(&a.key.user); err != nil {
+		return
+	}
+
+	vectorIndex.Lock()
+	switch vectorIndex.state {
+	case vectorIndexBuilding:
+		vectorIndex.pending = append(vectorIndex.pending, a)
+		vectorIndex.Unlock()
+		return
+	case vectorIndexOff:
+		vectorIndex.Unlock()
+		return
+	}
+	ix := vectorIndex.byKey[a.key]
+	if ix == nil {
+		ix = newHNSWIndex(a.key.dim)
+		vectorIndex.byKey[a.key] = ix
+	}
+	vectorIndex.Unlock()
+
+	ix.Insert(a.id, a.vec)
+	if ix.stale() {
+		goSafe("vector_index", func() { buildVectorIndex(cfg, db) })
+	}
+}
+
+// vectorIndexDrop retires the vector of summary id after its embedding was deleted.
+func vectorIndexDrop(id int64) {
+	vectorIndex.Lock()
+	defer vectorIndex.Unlock()
+	switch vectorIndex.state {
+	case vectorIndexBuilding:
+		vectorIndex.pending = append(vectorIndex.pending, vectorIndexAdd{id: id})
+	case vectorIndexReady:
+		for _, ix := range vectorIndex.byKey {
+			ix.Remove(id)
+		}
+	}
+}
+
+// vectorIndexCandidates returns the ANN candidates of q for user, or ok=false
+// when the caller should scan all rows (index off, not built, too small).
+func vectorIndexCandidates(cfg Config, user string, q []float32, k int) ([]int64, bool) {
+	if cfg.VectorIndexMinRows <= 0 {
+		return nil, false
+	}
+	vectorIndex.Lock()
+	var ix *hnswIndex
+	if vectorIndex.state == vectorIndexReady {
+		ix = vectorIndex.byKey[vectorIndexKey{user: user, dim: len(q)}]
+	}
+	vectorIndex.Unlock()
+	if ix == nil || ix.Live() < cfg.VectorIndexMinRows {
+		return nil, false
+	}
+	return ix.Search(q, k), true
+}
+
+// clearVectorIndex empties the index (wipe).
+func clearVectorIndex() {
+	vectorIndex.Lock()
+	defer vectorIndex.Unlock()
+	if vectorIndex.state == vectorIndexReady {
+		vectorIndex.byKey = map[vectorIndexKey]*hnswIndex{}
+	}
+	vectorIndex.pending = nil
+}
@@ -429,6 +429,16 @@ function setCoreStatus(online) {
 setInterval(checkHealth, 3000);
 checkHealth();
 
+// first run: point at the setup (the server started with defaults nobody chose)
+fetch('/api/onboarding', { cache: 'no-store' })
+  .then(r => (r.ok ? r.json() : null))
+  .then(j => {
+    if (j?.onboarding && !j.onboarding.done) {
+      showToast('not set up yet: run `local-ai init` (or POST /api/onboarding)', 'warn', 8000);
+    }
+  })
+  .catch(() => {});
+
 /* ============================================================
    TOASTS (silent UX feedback for notice-only events)
    ============================================================ */
@@ -595,22 +605,78 @@ function setActiveTab(tabName) {
   paneHistory.classList.toggle('hidden', tabName !== 'history');
 }
 
+// pending triage filters (sent to /api/facts/pending/groups)
+const pendingFilter = { sort: 'newest', min_confidence: '', source_type: '' };
+
+function makePendingSelect(key, options) {
+  const sel = document.createElement('select');
+  sel.className = 'fact-select';
+  for (const [value, label] of options) {
+    const o = document.createElement('option');
+    o.value = value;
+    o.textContent = label;
+    sel.appendChild(o);
+  }
+  sel.value = pendingFilter[key];
+  sel.onchange = () => {
+    pendingFilter[key] = sel.value;
+    loadPendingGroups();
+  };
+  return sel;
+}
+
+function makePendingToolbar(bySource) {
+  const bar = document.createElement('div');
+  bar.className = 'fact-toolbar';
+
+  const total = Object.values(bySource || {}).reduce((a, n) => a + Number(n || 0), 0);
+  const sources = [['', `all sources (${total})`]];
+  for (const [st, n] of Object.entries(bySource || {}).sort((a, b) => b[1] - a[1])) {
+    sources.push([st, `${st} (${n})`]);
+  }
+  if (pendingFilter.source_type && !(pendingFilter.source_type in (bySource || {}))) {
+    sources.push([pendingFilter.source_type, `${pendingFilter.source_type} (0)`]);
+  }
+
+  bar.appendChild(makePendingSelect('sort', [
+    ['newest', 'newest'],
+    ['confidence', 'confidence'],
+    ['age', 'oldest'],
+  ]));
+  bar.appendChild(makePendingSelect('min_confidence', [
+    ['', 'any conf'],
+    ['0.8', 'conf ≥ 0.80'],
+    ['0.9', 'conf ≥ 0.90'],
+    ['0.95', 'conf ≥ 0.95'],
+  ]));
+  bar.appendChild(makePendingSelect('source_type', sources));
+  return bar;
+}
+
 async function loadPendingGroups() {
   panePending.innerHTML = '<div class="fact-meta">Loading…</div>';
   try {
-    const resp = await fetch('/api/facts/pending/groups', { cache: 'no-store' });
+    const qs = new URLSearchParams();
+    for (const [k, v] of Object.entries(pendingFilter)) {
+      if (v) qs.set(k, v);
+    }
+    const resp = await fetch('/api/facts/pending/groups?' + qs.toString(), { cache: 'no-store' });
     if (!resp.ok) {
       panePending.innerHTML = '<div class="fact-meta">Failed to load.</div>';
       return;
     }
     const data = await resp.json();
     const groups = data.groups || [];
+    panePending.innerHTML = '';
+    panePending.appendChild(makePendingToolbar(data.by_source));
     if (groups.length === 0) {
-      panePending.innerHTML = '<div class="fact-meta">No pending facts.</div>';
+      const empty = document.createElement('div');
+      empty.className = 'fact-meta';
+      empty.textContent = 'No pending facts.';
+      panePending.appendChild(empty);
       return;
     }
 
-    panePending.innerHTML = '';
     for (const g of groups) {
       const wrap = document.createElement('div');
       wrap.className = 'fact-group';
@@ -684,7 +750,7 @@ async function loadPendingGroups() {
       for (const it of (g.items || [])) {
         const confVal = (typeof it.confidence === "number") ? it.confidence : Number(it.confidence || 0);
         const confText = Number.isFinite(confVal) ? confVal.toFixed(2) : String(it.confidence || "");
-        const meta = `conf=${confText} · ${it.source_key || ''}`;
+        const meta = `conf=${confText} · ${it.source_type || ''} · ${it.source_key || ''}`;
 
         const btnRemember = document.createElement('button');
         btnRemember.className = 'fact-btn primary';
@@ -718,7 +784,25 @@ async function loadPendingGroups() {
           }
         };
 
-        const row = makeFactRow(escapeHtml(it.fact), meta, [btnRemember, btnReject]);
+        const btnEdit = document.createElement('button');
+        btnEdit.className = 'fact-btn';
+        btnEdit.textContent = 'EDIT';
+        btnEdit.onclick = async () => {
+          const v = prompt('Edit fact before remembering:', it.fact || '');
+          if (v === null || !v.trim()) return;
+          btnEdit.disabled = true;
+          try {
+            await fetch(`/api/facts/pending/${it.id}/remember_edited`, {
+              method: 'POST',
+              headers: { 'Content-Type': 'application/json' },
+              body: JSON.stringify({ fact: v.trim() })
+            });
+          } finally {
+            await refreshFactsUI();
+          }
+        };
+
+        const row = makeFactRow(escapeHtml(it.fact), meta, [btnRemember, btnEdit, btnReject]);
         body.appendChild(row);
       }
 
@@ -803,7 +887,62 @@ async function loadConflicts() {
         }
       };
 
-      const row = makeFactRow(text.replace(/\n/g, '<br/>'), `key=${c.fact_key || ''}`, [btnKeep, btnReplace, btnEdit]);
+      // LLM advice (fact_conflict_suggest.go); applied only after confirm()
+      const btnSuggest = document.createElement('button');
+      btnSuggest.className = 'fact-btn';
+      btnSuggest.textContent = 'SUGGEST';
+
+      const row = makeFactRow(text.replace(/\n/g, '<br/>'), `key=${c.fact_key || ''}`, [btnKeep, btnReplace, btnEdit, btnSuggest]);
+      const advice = document.createElement('div');
+      advice.className = 'fact-meta';
+      row.querySelector('.fact-text').appendChild(advice);
+
+      btnSuggest.onclick = async () => {
+        btnSuggest.disabled = true;
+        advice.textContent = 'Asking the model…';
+        try {
+          const res = await fetch(`/api/facts/conflicts/${c.id}/suggest`, { method: 'POST' });
+          if (!res.ok) {
+            advice.textContent = '';
+            showToast((await res.text()) || 'suggest failed', 'err', 2600);
+            return;
+          }
+          const s = (await res.json()).suggestion || {};
+          const action = String(s.action || '').toUpperCase();
+          advice.innerHTML = `<b>SUGGESTED: ${escapeHtml(action)}</b> — ${escapeHtml(s.rationale || '')}` +
+            (s.merged ? `<br/>MERGED: ${escapeHtml(s.merged)}` : '');
+
+          const btnApply = document.createElement('button');
+          btnApply.className = 'fact-btn primary';
+          btnApply.textContent = `APPLY ${action}`;
+          btnApply.onclick = async () => {
+            const what = s.action === 'keep' ? `keep "${c.existing_fact || ''}"`
+              : s.action === 'merge' ? `replace with "${s.merged}"`
+              : `replace with "${c.proposed_fact || ''}"`;
+            if (!confirm(`Apply the suggestion: ${what}?`)) return;
+            btnApply.disabled = true;
+            try {
+              const body = s.action === 'keep' ? { action: 'keep' }
+                : { action: 'replace', replacement: s.action === 'merge' ? s.merged : '' };
+              const r = await fetch(`/api/facts/conflicts/${c.id}/resolve`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body)
+              });
+              if (!r.ok) showToast((await r.text()) || 'resolve failed', 'err', 2600);
+            } finally {
+              await refreshFactsUI();
+            }
+          };
+          advice.appendChild(document.createElement('br'));
+          advice.appendChild(btnApply);
+        } catch {
+          advice.textContent = '';
+          showToast('suggest failed', 'err', 2600);
+        } finally {
+          btnSuggest.disabled = false;
+        }
+      };
       paneConflicts.appendChild(row);
     }
   } catch {
@@ -935,7 +1074,7 @@ async function loadDebugContext() {
     const resp = await fetch('/api/debug/context', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ question: lastUserInput })
+      body: JSON.stringify(withSession({ question: lastUserInput }))
     });
     if (!resp.ok) {
       debugHadError = true;
@@ -1227,12 +1366,194 @@ function enableInput(enable) {
   elSend.disabled = !enable;
 }
 
+/* ============================================================
+   INCOGNITO (memory=off per request; /incognito or the MEMORY item)
+   ============================================================ */
+let incognito = false;
+const memBtn = document.getElementById('mem-btn');
+const memLed = document.getElementById('mem-led');
+const memStatus = document.getElementById('mem-status');
+
+function setIncognito(on) {
+  incognito = !!on;
+  memLed?.classList.toggle('on', !incognito);
+  if (memStatus) memStatus.textContent = incognito ? 'OFF' : 'ON';
+  showToast(incognito ? 'incognito ON: nothing is written' : 'incognito OFF', 'ok', 1800);
+}
+
+memBtn?.addEventListener('click', () => setIncognito(!incognito));
+
+/* ============================================================
+   SESSIONS (independent conversations; /session new [title] | main | <id>)
+   - recent context on the server follows the session; "main" = default stream
+   ============================================================ */
+const SESSION_KEY = 'timelayer.session';
+let sessionId = localStorage.getItem(SESSION_KEY) || '';
+const sessionBtn = document.getElementById('session-btn');
+const sessionLed = document.getElementById('session-led');
+const sessionStatus = document.getElementById('session-status');
+
+function withSession(body) {
+  if (sessionId) body.session = sessionId;
+  return body;
+}
+
+function showSession(s) {
+  sessionLed?.classList.toggle('on', !!sessionId);
+  if (sessionStatus) sessionStatus.textContent = sessionId ? ((s && s.title) || 'NEW').slice(0, 16) : 'MAIN';
+}
+
+function setSession(s) {
+  const next = s ? s.id : '';
+  if (next !== sessionId) elLog.innerHTML = '';
+  sessionId = next;
+  if (sessionId) localStorage.setItem(SESSION_KEY, sessionId);
+  else localStorage.removeItem(SESSION_KEY);
+  showSession(s);
+}
+
+async function listSessions() {
+  const res = await fetch('/api/sessions');
+  if (!res.ok) throw new Error(await res.text());
+  return (await res.json()).items || [];
+}
+
+async function newSession(title) {
+  try {
+    const res = await fetch('/api/sessions', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ title: title || '' })
+    });
+    if (!res.ok) throw new Error(await res.text());
+    setSession((await res.json()).session);
+    showToast('new session', 'ok', 1500);
+  } catch (e) {
+    showToast('session: ' + (e?.message || e), 'err', 2500);
+  }
+}
+
+async function switchSession(arg) {
+  if (!arg || arg === 'main') {
+    setSession(null);
+    showToast('session: main', 'ok', 1500);
+    return;
+  }
+  if (arg === 'new' || arg.startsWith('new ')) {
+    await newSession(arg.slice(3).trim());
+    return;
+  }
+  try {
+    const s = (await listSessions()).find(it => it.id.startsWith(arg));
+    if (!s) throw new Error('unknown session: ' + arg);
+    setSession(s);
+    showToast('session: ' + (s.title || s.id), 'ok', 1500);
+  } catch (e) {
+    showToast('session: ' + (e?.message || e), 'err', 2500);
+  }
+}
+
+// a stored session that was deleted meanwhile falls back to main
+async function restoreSession() {
+  if (!sessionId) return showSession(null);
+  try {
+    const s = (await listSessions()).find(it => it.id === sessionId);
+    if (s) showSession(s);
+    else setSession(null);
+  } catch (_) {
+    showSession(null);
+  }
+}
+
+sessionBtn?.addEventListener('click', () => newSession(''));
+restoreSession();
+
+/* ============================================================
+   GUIDED FLOWS (/flow <name>: multi-turn commands via /api/flows)
+   ============================================================ */
+let activeFlow = null; // flow id while a flow waits for an answer
+
+async function runFlowTurn(input, start) {
+  const userMsg = document.createElement('div');
+  userMsg.className = 'msg user';
+  userMsg.textContent = input;
+  elLog.appendChild(userMsg);
+
+  const aiMsg = document.createElement('div');
+  aiMsg.className = 'msg ai';
+  const aiContent = document.createElement('div');
+  aiContent.className = 'ai-content';
+  aiMsg.appendChild(aiContent);
+  elLog.appendChild(aiMsg);
+  scrollToBottom(elLog);
+
+  setBusy(true);
+  try {
+    const res = await fetch(start ? '/api/flows/start' : '/api/flows/' + encodeURIComponent(activeFlow), {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(start ? { flow: start[1], arg: start[2] || '' } : { input })
+    });
+    if (!res.ok) {
+      if (res.status === 404) activeFlow = null;
+      aiContent.textContent = '[error] ' + (await res.text());
+      return;
+    }
+    const step = (await res.json()).step || {};
+    activeFlow = step.done ? null : step.flow_id;
+    aiContent.textContent = step.prompt || '';
+    if (!step.done) {
+      const row = document.createElement('div');
+      row.style.marginTop = '10px';
+      for (const opt of [...(step.options || []), 'cancel']) {
+        const btn = document.createElement('button');
+        btn.className = opt === 'cancel' ? 'fact-btn' : 'fact-btn primary';
+        btn.textContent = opt.toUpperCase();
+        btn.onclick = () => {
+          row.querySelectorAll('button').forEach(b => { b.disabled = true; });
+          sendStream(opt);
+        };
+        row.appendChild(btn);
+      }
+      aiContent.appendChild(row);
+    } else if (step.flow === 'replace_conflict') {
+      await refreshFactsUI();
+    }
+  } catch (e) {
+    aiContent.textContent = '[error] ' + (e?.message || e);
+  } finally {
+    setBusy(false);
+    scrollToBottom(elLog);
+  }
+}
+
 /* ============================================================
    SSE 聊天（你原来的逻辑：保留 + 背景响应）
    ============================================================ */
 async function sendStream(input) {
   if (!coreOnline) return;
 
+  // /incognito [on|off] is a client-side toggle (the server is stateless per request)
+  const inc = String(input || '').trim().match(/^\/incognito(?:\s+(on|off))?\s*$/i);
+  if (inc) {
+    setIncognito(inc[1] ? inc[1].toLowerCase() === 'on' : !incognito);
+    return;
+  }
+
+  // /session [new [title]|main|<id>] switches the conversation (client-side, like /incognito)
+  const ses = String(input || '').trim().match(/^\/session(?:\s+(.*))?$/i);
+  if (ses) {
+    await switchSession((ses[1] || 'new').trim());
+    return;
+  }
+
+  // /flow <name> [arg] starts a guided flow; while one is open every input answers it
+  const fl = String(input || '').trim().match(/^\/flow\s+(\S+)(?:\s+(.*))?$/i);
+  if (fl || activeFlow) {
+    await runFlowTurn(input, fl);
+    return;
+  }
+
   // for /api/debug/context
   lastUserInput = String(input || '').trim();
   debugHadError = false;
@@ -1296,7 +1617,7 @@ async function sendStream(input) {
     const resp = await fetch('/api/chat/stream', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ input })
+      body: JSON.stringify(withSession(incognito ? { input, memory: 'off' } : { input }))
     });
 
     if (!resp.ok || !resp.body) {
@@ -1306,9 +1627,6 @@ async function sendStream(input) {
       return;
     }
 
-    const reader = resp.body.getReader();
-    const decoder = new TextDecoder('utf-8');
-    let buf = '';
     let gotAny = false;
     let renderedAnyText = false;
     let hadError = false;
@@ -1334,55 +1652,94 @@ async function sendStream(input) {
       }
     };
 
-    while (true) {
-      const { value, done } = await reader.read();
-      if (done) break;
+    // Resumable streams: the server sends {"gen": id} first; if the connection
+    // drops before "done", GET /api/chat/stream/resume replays the deltas we missed.
+    let gen = '';
+    let deltaN = 0;
+    let finished = false;
 
-      buf += decoder.decode(value, { stream: true });
+    const readFrames = async (body) => {
+      const reader = body.getReader();
+      const decoder = new TextDecoder('utf-8');
+      let buf = '';
+      while (true) {
+        const { value, done } = await reader.read();
+        if (done) break;
 
-      let idx;
-      while ((idx = buf.indexOf('\n\n')) >= 0) {
-        const frame = buf.slice(0, idx);
-        buf = buf.slice(idx + 2);
+        buf += decoder.decode(value, { stream: true });
 
-        if (!frame.startsWith('data: ')) continue;
+        let idx;
+        while ((idx = buf.indexOf('\n\n')) >= 0) {
+          const frame = buf.slice(0, idx);
+          buf = buf.slice(idx + 2);
 
-        const obj = JSON.parse(frame.slice(6));
+          if (!frame.startsWith('data: ')) continue;
 
-        if (obj.error) {
-          gotAny = true;
-          hadError = true;
-          debugHadError = true;
-          refreshDebugLed();
-          typer.push(`[error] ${obj.error}`);
-          continue;
-        }
+          const obj = JSON.parse(frame.slice(6));
 
-        // Meta/notice-only events (e.g. facts remember/forget) should be silent in chat.
-        if (obj.notice) {
-          gotAny = true;
-          if (obj.notice === 'facts') {
-            // Silent UX: only refresh FACTS LED/counts, no chat bubble/toast.
-            await fetchFactCounts();
+          if (obj.gen) {
+            gen = obj.gen;
+            continue;
           }
-          continue;
-        }
-        if (obj.delta) {
-          gotAny = true;
-          renderedAnyText = true;
-          pushDeltaClean(obj.delta);
-
-          maybeAutoScroll(elLog);
-          !userAtBottom && showJumpBtn();
-          trimMessagesIfNeeded();
-
-          bumpActivity(0.03 + Math.min(0.05, obj.delta.length * 0.0012));
-          if (Math.random() < 0.06) {
-            const n = NODES[(Math.random() * NODES.length) | 0];
-            spawnPulse(n.x, n.y, 0.55 + activity * 0.35);
+          if (obj.done) finished = true;
+
+          if (obj.error) {
+            finished = true;
+            gotAny = true;
+            hadError = true;
+            debugHadError = true;
+            refreshDebugLed();
+            typer.push(`[error] ${obj.error}`);
+            continue;
+          }
+
+          // Meta/notice-only events (e.g. facts remember/forget) should be silent in chat.
+          if (obj.notice) {
+            gotAny = true;
+            if (obj.notice === 'facts') {
+              // Silent UX: only refresh FACTS LED/counts, no chat bubble/toast.
+              await fetchFactCounts();
+            }
+            continue;
+          }
+          if (obj.delta) {
+            deltaN++;
+            gotAny = true;
+            renderedAnyText = true;
+            pushDeltaClean(obj.delta);
+
+            maybeAutoScroll(elLog);
+            !userAtBottom && showJumpBtn();
+            trimMessagesIfNeeded();
+
+            bumpActivity(0.03 + Math.min(0.05, obj.delta.length * 0.0012));
+            if (Math.random() < 0.06) {
+              const n = NODES[(Math.random() * NODES.length) | 0];
+              spawnPulse(n.x, n.y, 0.55 + activity * 0.35);
+            }
           }
         }
       }
+    };
+
+    let body = resp.body;
+    for (let attempt = 0; ; attempt++) {
+      try {
+        if (body) await readFrames(body);
+      } catch (e) {
+        if (!gen || attempt >= 3) throw e;
+      }
+      if (finished || !gen || attempt >= 3) break;
+
+      await new Promise(r => setTimeout(r, 500 * (attempt + 1)));
+      body = null;
+      try {
+        const r = await fetch(`/api/chat/stream/resume?gen=${encodeURIComponent(gen)}&offset=${deltaN}`);
+        if (!r.ok || !r.body) break;
+        body = r.body;
+      } catch (e) {
+        // still offline: retry on the next attempt
+      }
     }
 
     // Flush any buffered prefix chunk (short responses may not reach the length threshold).
@@ -21,6 +21,10 @@ func HandleCommandWeb(cfg Config, db *sql.DB, lw *LogWriter, input string) (bool
 		// helpText 在 embedding_search_reflect.go 里
 		return true, helpText, nil
 
+	case "/incognito":
+		// the web server is stateless per request: the UI keeps the toggle and sends memory=off
+		return true, "incognito is per request on the web: send {\"memory\":\"off\"} (the web UI's /incognito toggles it)", nil
+
 	case "/debug":
 		if arg == "" {
 			return true, "usage: /debug <msg>", nil
@@ -28,16 +32,20 @@ func HandleCommandWeb(cfg Config, db *sql.DB, lw *LogWriter, input string) (bool
 		return true, DebugChatText(cfg, db, arg), nil
 
 	case "/search":
-		if arg == "" {
-			return true, "usage: /search <query>", nil
+		q, scope := parseScopeFlags(arg)
+		if q == "" {
+			return true, "usage: /search <query> [--scope tag,tag] [--ws name]", nil
 		}
-		hits, err := SearchWithScore(db, cfg, arg)
+		scfg := cfg
+		scfg.Scope = scope
+		hits, err := SearchWithScore(db, scfg, q)
 		if err != nil {
 			return true, "", err
 		}
 		if len(hits) == 0 {
 			return true, "no related memory", nil
 		}
+		markArchivedHits(cfg, db, hits)
 		var b strings.Builder
 		for _, h := range hits {
 			if h.Type == "fact" {
@@ -47,6 +55,9 @@ func HandleCommandWeb(cfg Config, db *sql.DB, lw *LogWriter, input string) (bool
 				b.WriteString(fmt.Sprintf("[%.4f] %s %s\n", h.Score, h.Date, h.Type))
 				b.WriteString(h.Text)
 			}
+			if h.Archived {
+				b.WriteString("\n(raw log archived: /unarchive " + h.Date + " to cite it)")
+			}
 			b.WriteString("\n----------------------\n")
 		}
 
@@ -64,20 +75,22 @@ func HandleCommandWeb(cfg Config, db *sql.DB, lw *LogWriter, input string) (bool
 
 	case "/remember":
 		if arg == "" {
-			return true, "usage: /remember <fact>", nil
+			return true, "usage: /remember [--tag <category>] <fact>", nil
 		}
-		out, err := RememberFactWithOutcome(lw, cfg, db, arg)
+		out, err := rememberTagged(lw, cfg, db, arg)
 		if err != nil {
 			return true, "", err
 		}
 		if out != nil {
 			switch out.Status {
 			case "conflict":
-				return true, "[conflict] 已进入 FACTS -> CONFLICTS，处理后才会晋升为长期事实。", nil
+				return true, conflictMessage(out), nil
 			case "remembered":
 				return true, "[ok] fact recorded", nil
 			case "noop":
 				return true, "[noop] nothing to remember", nil
+			case "blocked":
+				return true, "[blocked] matches a fact policy rule or contains a secret; not stored", nil
 			}
 		}
 		return true, "[ok] fact recorded", nil
@@ -91,6 +104,182 @@ func HandleCommandWeb(cfg Config, db *sql.DB, lw *LogWriter, input string) (bool
 		}
 		return true, "[ok] fact retracted", nil
 
+	case "/tag", "/untag", "/tags":
+		out, err := runFactTagCommand(cfg, db, cmd, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/core", "/uncore":
+		out, err := runFactCoreCommand(cfg, db, cmd, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/merge":
+		out, err := runFactMergeCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/maintenance":
+		out, err := runMaintenanceCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/conflict_sweep":
+		out, err := runConflictSweepCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/define", "/undefine", "/glossary":
+		out, err := runGlossaryCommand(cfg, db, cmd, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/preview":
+		out, err := runChatPreviewCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/memory_diff":
+		out, err := runMemoryDiffCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/flow":
+		name, farg, _ := strings.Cut(strings.TrimSpace(arg), " ")
+		if name == "" {
+			return true, runFlowListCommand(), nil
+		}
+		// API clients get the first prompt here; the web UI intercepts /flow and drives /api/flows itself
+		step, err := StartCommandFlow(cfg, db, name, farg)
+		if err != nil {
+			return true, "", err
+		}
+		if step.Done {
+			return true, step.Prompt, nil
+		}
+		return true, step.Prompt + "\n\n(flow " + step.FlowID + ": answer via POST /api/flows/" + step.FlowID + ")", nil
+
+	case "/logcheck":
+		out, err := runLogCheckCommand(cfg, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/logs_import", "/logs_export":
+		out, err := runLogStoreCommand(cfg, db, cmd, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/export_chat":
+		out, err := runExportChatCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/export_summary":
+		out, err := runExportSummaryCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/email_poll":
+		out, err := runEmailPollCommand(cfg, db)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/embed_heal":
+		out, err := runEmbedHealCommand(cfg, db)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/hygiene":
+		out, err := runHygieneCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/stale_check":
+		out, err := runStaleCheckCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/style":
+		out, err := runStyleCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/annotate", "/annotations":
+		out, err := runAnnotateCommand(cfg, db, cmd, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/trash", "/restore", "/delete_summary":
+		out, err := runTrashCommand(cfg, db, cmd, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/unarchive":
+		out, err := runUnarchiveCommand(cfg, db, arg)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/assistants":
+		out, err := runAssistantsCommand(cfg, db)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/users":
+		out, err := runUsersCommand(cfg, db)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
+	case "/sessions":
+		out, err := runSessionsCommand(cfg, db)
+		if err != nil {
+			return true, "", err
+		}
+		return true, out, nil
+
 	case "/pending_add":
 		if strings.TrimSpace(arg) == "" {
 			return true, "usage: /pending_add <fact> [--conf 0.85]", nil
@@ -139,7 +328,15 @@ func HandleCommandWeb(cfg Config, db *sql.DB, lw *LogWriter, input string) (bool
 			}
 		}
 
-		if err := ensureDaily(cfg, db, day, force); err != nil {
+		if strings.Contains(arg, "--partial") {
+			out, err := runDailyPartial(foregroundLLM(cfg), db, day)
+			if err != nil {
+				return true, "", err
+			}
+			return true, out, nil
+		}
+
+		if err := ensureDaily(foregroundLLM(cfg), db, day, force); err != nil {
 			return true, "", err
 		}
 		return true, "[ok] daily summary ensured: " + day, nil
@@ -148,7 +345,7 @@ func HandleCommandWeb(cfg Config, db *sql.DB, lw *LogWriter, input string) (bool
 		force := strings.Contains(arg, "--force")
 		y, w := time.Now().In(cfg.Location).ISOWeek()
 		key := fmt.Sprintf("%04d-W%02d", y, w)
-		if err := ensureWeekly(cfg, db, key, force); err != nil {
+		if err := ensureWeekly(foregroundLLM(cfg), db, key, force); err != nil {
 			return true, "", err
 		}
 		return true, "[ok] weekly summary ensured: " + key, nil
@@ -156,7 +353,7 @@ func HandleCommandWeb(cfg Config, db *sql.DB, lw *LogWriter, input string) (bool
 	case "/monthly":
 		force := strings.Contains(arg, "--force")
 		key := time.Now().In(cfg.Location).Format("2006-01")
-		if err := ensureMonthly(cfg, db, key, force); err != nil {
+		if err := ensureMonthly(foregroundLLM(cfg), db, key, force); err != nil {
 			return true, "", err
 		}
 		return true, "[ok] monthly summary ensured: " + key, nil
@@ -166,6 +363,13 @@ func HandleCommandWeb(cfg Config, db *sql.DB, lw *LogWriter, input string) (bool
 		if target == "" {
 			target = "daily"
 		}
+		if target == "--model-migrate" {
+			out, err := runReindexModelMigrate(cfg, db)
+			if err != nil {
+				return true, "", err
+			}
+			return true, out, nil
+		}
 		if err := Reindex(db, cfg, target); err != nil {
 			return true, "", err
 		}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -25,15 +26,109 @@ type apiChatReq struct {
 	// question is used by the web UI debug overlay (/api/debug/context)
 	// kept for backward/forward compatibility with older web assets.
 	Question string `json:"question"`
+
+	// Optional per-chat fact tag policy (merged with cfg.ContextFactTags).
+	IncludeTags []string `json:"include_tags,omitempty"`
+	ExcludeTags []string `json:"exclude_tags,omitempty"`
+
+	// Optional scope: constrains fact injection + retrieval to tagged content.
+	Scope *SearchScope `json:"scope,omitempty"`
+
+	// Optional answer style: concise|detailed|bullet|none, plus a sentence cap.
+	// Profile selects the stored default (answer_style_profiles) these override.
+	Style        string `json:"style,omitempty"`
+	MaxSentences int    `json:"max_sentences,omitempty"`
+	Profile      string `json:"profile,omitempty"`
+
+	// Optional assistant profile (persona + memory view), see assistants.go.
+	Assistant string `json:"assistant,omitempty"`
+
+	// memory=off: incognito turn (answered with context, nothing is written).
+	Memory string `json:"memory,omitempty"`
+
+	// Optional user whose memory the turn uses (default TIMELAYER_USER), see users.go.
+	User string `json:"user,omitempty"`
+
+	// Optional conversation session of that user (recent context), see sessions.go.
+	Session string `json:"session,omitempty"`
+}
+
+// userConfig selects the request's user; commands run with it too.
+func (req apiChatReq) userConfig(cfg Config) (Config, error) {
+	user, err := normalizeUserName(req.User)
+	if err != nil {
+		return cfg, err
+	}
+	if user != "" {
+		cfg.User = user
+	}
+	return cfg, nil
+}
+
+// chatConfig applies the selected user, session and assistant, then the request-level overrides.
+func (req apiChatReq) chatConfig(cfg Config, db *sql.DB) (Config, error) {
+	cfg, err := req.userConfig(cfg)
+	if err != nil {
+		return cfg, err
+	}
+	cfg, err = applySession(cfg, db, req.Session)
+	if err != nil {
+		return cfg, err
+	}
+	if req.Assistant == "" {
+		// TIMELAYER_ASSISTANT is the default; a deleted profile must not break chat
+		if c, err := applyAssistant(cfg, db, cfg.AssistantName); err == nil {
+			cfg = c
+		}
+		return req.requestConfig(cfg), nil
+	}
+	cfg, err = applyAssistant(cfg, db, req.Assistant)
+	if err != nil {
+		return cfg, err
+	}
+	return req.requestConfig(cfg), nil
+}
+
+// requestConfig returns a copy of cfg with the request's tag policy, scope and answer style applied.
+// Per-chat include only narrows the configured include (it is intersected with
+// it when one is set) and excludes are always additive, so a request can never
+// widen the operator's tag policy.
+func (req apiChatReq) requestConfig(cfg Config) Config {
+	if req.Scope != nil {
+		cfg.Scope = *req.Scope
+	}
+	cfg.ContextFactTags = cfg.ContextFactTags.narrowInclude(normalizeFactTags(req.IncludeTags))
+	if exc := normalizeFactTags(req.ExcludeTags); len(exc) > 0 {
+		merged := append(append([]string{}, cfg.ContextFactTags.Exclude...), exc...)
+		cfg.ContextFactTags.Exclude = normalizeFactTags(merged)
+	}
+	if s, ok := normalizeAnswerStyle(req.Style); ok && s != "" {
+		cfg.AnswerStyleOverride.Style = s
+	}
+	if req.MaxSentences > 0 {
+		cfg.AnswerStyleOverride.MaxSentences = req.MaxSentences
+	}
+	if p := strings.TrimSpace(req.Profile); p != "" {
+		cfg.AnswerProfile = p
+	}
+	if strings.EqualFold(strings.TrimSpace(req.Memory), "off") {
+		cfg.Incognito = true
+	}
+	return cfg
 }
 
 type apiChatResp struct {
-	Text string `json:"text"`
+	Text   string `json:"text"`
+	TurnID string `json:"turn_id,omitempty"`
 }
 
 type apiPendingFactsResp struct {
-	Count int           `json:"count"`
-	Items []PendingFact `json:"items"`
+	Count       int            `json:"count"`
+	Total       int            `json:"total"` // all matching the filters, across pages
+	NextAfterID int64          `json:"next_after_id,omitempty"`
+	Items       []PendingFact  `json:"items"`
+	Sort        string         `json:"sort"`
+	BySource    map[string]int `json:"by_source"` // all pending, before filters
 }
 
 type apiPendingFactsCountResp struct {
@@ -58,20 +153,103 @@ type apiPendingActionReq struct {
 	ID int64 `json:"id"`
 }
 
+type apiTurnFeedbackReq struct {
+	Rating string `json:"rating"` // up | down
+	Note   string `json:"note"`
+}
+
+type apiArchiveRestoreReq struct {
+	Day       string `json:"day"`
+	Force     bool   `json:"force"`
+	Reprocess bool   `json:"reprocess"`
+}
+
+type apiFlowStartReq struct {
+	Flow string `json:"flow"`
+	Arg  string `json:"arg"`
+}
+
+type apiFlowInputReq struct {
+	Input string `json:"input"`
+}
+
+type apiSummaryTagsReq struct {
+	Type      string   `json:"type"`
+	PeriodKey string   `json:"period_key"`
+	Tags      []string `json:"tags"`
+	Action    string   `json:"action"` // set | add | remove
+}
+
+type apiSubjectAliasReq struct {
+	Alias     string `json:"alias"`
+	Canonical string `json:"canonical"`
+	Action    string `json:"action"` // set | delete
+	DryRun    bool   `json:"dry_run"`
+}
+
+type apiFactPolicyReq struct {
+	Kind    string `json:"kind"` // keyword | regex | relation
+	Pattern string `json:"pattern"`
+	Note    string `json:"note"`
+	Fact    string `json:"fact"` // /test only
+}
+
+type apiFactCoreReq struct {
+	FactKey string `json:"fact_key"`
+	Fact    string `json:"fact"` // alternative to fact_key: resolved like /tag
+	Core    bool   `json:"core"`
+}
+
+type apiFactMergeReq struct {
+	FactKeys []string `json:"fact_keys"`
+	Keep     string   `json:"keep"` // surviving fact_key (default: the first)
+	Text     string   `json:"text"` // optional new wording of the survivor
+}
+
+type apiPendingEditReq struct {
+	Fact string `json:"fact"` // the text to remember instead of the proposed one
+}
+
+type apiAskReq struct {
+	Question  string       `json:"question"`
+	User      string       `json:"user,omitempty"`
+	Scope     *SearchScope `json:"scope,omitempty"`
+	AskTarget              // type / period / doc, as /ask --type --period --doc
+}
+
+type apiFactCategoryReq struct {
+	FactKey  string `json:"fact_key"`
+	Fact     string `json:"fact"` // alternative to fact_key: resolved like /tag
+	Category string `json:"category"`
+}
+
+type apiFactTagsReq struct {
+	FactKey string   `json:"fact_key"`
+	Fact    string   `json:"fact"` // alternative to fact_key: resolved like /tag
+	Tags    []string `json:"tags"`
+	Action  string   `json:"action"` // set | add | remove
+}
+
 const maxJSONBodyBytes = 1 << 20 // 1MB
 
 // ============================================================
 // StartWeb
 // ============================================================
 
+// StartWeb serves the web UI and API until SIGINT/SIGTERM (see StartWebContext).
 func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
+	return StartWebContext(context.Background(), cfg, db, lw)
+}
+
+// newWebServer builds the server (nil when there is no DB).
+func newWebServer(cfg Config, db *sql.DB, lw *LogWriter) (*http.Server, *webLifecycle, error) {
 	if db == nil {
-		return nil
+		return nil, nil, nil
 	}
 
 	// Safe-by-default: refuse non-loopback bind unless an auth token is set, or user explicitly allows insecure remote bind.
-	if !cfg.HTTPAllowInsecureRemote && cfg.HTTPAuthToken == "" && !isLoopbackListenAddr(cfg.HTTPAddr) {
-		return fmt.Errorf("refusing to bind to %s without auth; set TIMELAYER_HTTP_AUTH_TOKEN or TIMELAYER_HTTP_ALLOW_INSECURE_REMOTE=1", cfg.HTTPAddr)
+	if !cfg.HTTPAllowInsecureRemote && !httpAuthEnabled(cfg) && !isLoopbackListenAddr(cfg.HTTPAddr) {
+		return nil, nil, fmt.Errorf("refusing to bind to %s without auth; set TIMELAYER_HTTP_AUTH_TOKEN (or TIMELAYER_HTTP_CLIENT_TOKENS) or TIMELAYER_HTTP_ALLOW_INSECURE_REMOTE=1", cfg.HTTPAddr)
 	}
 
 	if cfg.Location == nil {
@@ -79,6 +257,7 @@ func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
 	}
 
 	streamSem := make(chan struct{}, maxInt(1, cfg.HTTPMaxConcurrentStreams))
+	life := newWebLifecycle()
 
 	mux := http.NewServeMux()
 
@@ -116,6 +295,38 @@ func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
 		_, _ = w.Write([]byte("ok"))
 	})
 
+	// =========================
+	// Onboarding (first-run setup, see onboarding.go)
+	// =========================
+	//   GET  /api/onboarding[?check=1]   (check=1 also calls the chat + embedding endpoints)
+	//   POST /api/onboarding {"base_dir","chat_url","embed_url","chat_model","language","timezone","name","persona","assistant"}
+	mux.HandleFunc("/api/onboarding", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			check := r.URL.Query().Get("check")
+			st := GetOnboardingStatus(cfg, db, check == "1" || check == "true")
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "onboarding": st})
+		case http.MethodPost:
+			var req OnboardingRequest
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			res, err := ApplyOnboarding(cfg, db, req)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": res})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
 	// =========================
 	// Pending facts API
 	// =========================
@@ -153,19 +364,56 @@ func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
 			return
 		}
 
-		items, err := ListPendingFacts(db, 60)
+		// ?sort=newest|confidence|age &min_confidence=0.9 &source_type=email &limit=60 &after_id=123 &user=alice
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		afterID, err := parseAfterID(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		q := parsePendingFactQuery(r)
+		q.User = user
+		q.AfterID = afterID
+		limit := q.Limit
+		q.Limit = limit + 1 // one more tells whether there is a next page
+		items, err := ListPendingFactsQuery(db, q)
+		if errors.Is(err, errUnknownCursor) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
 		if err != nil {
 			w.WriteHeader(http.StatusBadGateway)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
+		var next int64
+		if len(items) > limit {
+			items = items[:limit]
+			next = items[limit-1].ID
+		}
+		total, _ := CountPendingFactsQuery(db, q)
 
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(apiPendingFactsResp{Count: len(items), Items: items})
+		_ = json.NewEncoder(w).Encode(apiPendingFactsResp{
+			Count:       len(items),
+			Total:       total,
+			NextAfterID: next,
+			Items:       items,
+			Sort:        normalizePendingSort(q.Sort),
+			BySource:    CountPendingFactsBySource(db),
+		})
 	})
 
 	// REST-ish aliases to match README/diagram style:
 	//   POST /api/facts/pending/:id/remember
+	//   POST /api/facts/pending/:id/remember_edited  {"fact":"edited text"}
 	//   POST /api/facts/pending/:id/reject
 	mux.HandleFunc("/api/facts/pending/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -194,6 +442,20 @@ func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
 				return
 			}
 			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "outcome": out})
+		case "remember_edited":
+			var req apiPendingEditReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": err.Error()})
+				return
+			}
+			out, err := RememberPendingFactEdited(cfg, db, id, req.Fact)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": err.Error()})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "outcome": out})
 		case "reject":
 			if err := RejectPendingFact(cfg, db, id); err != nil {
 				w.WriteHeader(http.StatusBadRequest)
@@ -212,14 +474,23 @@ func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		groups, err := ListPendingFactGroups(cfg, db, 60)
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		// same filters as /api/facts/pending
+		q := parsePendingFactQuery(r)
+		q.User = user
+		groups, err := ListPendingFactGroups(cfg, db, q)
 		if err != nil {
 			w.WriteHeader(http.StatusBadGateway)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "groups": groups})
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "groups": groups, "by_source": CountPendingFactsBySource(db)})
 	})
 
 	mux.HandleFunc("/api/facts/remember", func(w http.ResponseWriter, r *http.Request) {
@@ -325,246 +596,1938 @@ func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		items, err := ListActiveFacts(db, 200)
+		limit := 200
+		unused := isUnusedSort(r.URL.Query().Get("sort"))
+		if unused {
+			limit = 2000
+		}
+		user, err := requestUser(cfg, r)
 		if err != nil {
-			w.WriteHeader(http.StatusBadGateway)
+			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
-	})
-
-	mux.HandleFunc("/api/facts/history", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
-		limit := parseIntClamp(r.URL.Query().Get("limit"), 200, 1, 500)
-		items, err := ListUserFactHistory(db, limit)
+		items, err := ListActiveFactsByTag(db, user, r.URL.Query().Get("tag"), limit)
 		if err != nil {
 			w.WriteHeader(http.StatusBadGateway)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
+		if unused {
+			sortFactsByUsage(items)
+		}
+		items = attachFactProvenance(cfg, db, items)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
 	})
 
-	// =========================
-	// Fact conflicts API
-	// =========================
-	mux.HandleFunc("/api/facts/conflicts", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+	//   POST /api/facts/:key/restore  (latest archived / forgotten version, see fact_restore.go)
+	mux.HandleFunc("/api/facts/", func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/facts/"), "/restore")
+		if !ok || key == "" {
+			w.WriteHeader(http.StatusNotFound)
 			return
 		}
-		items, err := ListFactConflicts(db, 60)
-		if err != nil {
-			w.WriteHeader(http.StatusBadGateway)
-			_, _ = w.Write([]byte(err.Error()))
-			return
-		}
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items, "count": len(items)})
-	})
-
-	mux.HandleFunc("/api/facts/conflicts/keep", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		var req apiPendingActionReq
-		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+		user, err := requestUser(cfg, r)
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		if req.ID <= 0 {
-			w.WriteHeader(http.StatusBadRequest)
+		ucfg := cfg
+		ucfg.User = user
+		res, err := RestoreFact(ucfg, db, key)
+		if errors.Is(err, errFactNotRestorable) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		if err := ResolveFactConflictKeep(db, req.ID, time.Now().In(cfg.Location)); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		if errors.Is(err, errFactRestoreConflict) {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "restored": res})
 	})
 
-	mux.HandleFunc("/api/facts/conflicts/replace", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
+	// =========================
+	// Summaries list (history browser: id / period / title)
+	// =========================
+	//   GET /api/summaries?type=daily&limit=50&user=
+	mux.HandleFunc("/api/summaries", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		var req apiConflictActionReq
-		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+		user, err := requestUser(cfg, r)
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		if req.ID <= 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		if err := ResolveFactConflictReplace(cfg, db, req.ID, req.Replacement, time.Now().In(cfg.Location)); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		items, err := ListSummaries(db, user, strings.TrimSpace(r.URL.Query().Get("type")), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
 	})
 
-	// Convenience REST-style endpoint (alias)
-	//   POST /api/facts/conflicts/:id/resolve
-	// Body:
-	//   {"action":"keep"}
-	//   {"action":"replace","replacement":"..."}
-	mux.HandleFunc("/api/facts/conflicts/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
+	//   GET /api/summaries/skipped?limit=100  (days written without the LLM, see summary_skip.go)
+	mux.HandleFunc("/api/summaries/skipped", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		rest := strings.TrimPrefix(r.URL.Path, "/api/facts/conflicts/")
-		parts := strings.Split(strings.Trim(rest, "/"), "/")
-		if len(parts) != 2 || parts[1] != "resolve" {
-			http.NotFound(w, r)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		items, err := ListSkippedDailies(db, limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		id, err := strconv.ParseInt(parts[0], 10, 64)
-		if err != nil || id <= 0 {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+	})
+
+	// =========================
+	// Summary admin (summary_admin.go) + annotations (user-authored corrections / notes)
+	// =========================
+	//   GET    /api/summaries/:type/:key             (json + text)
+	//   DELETE /api/summaries/:type/:key             (soft delete → trash)
+	//   POST   /api/summaries/:type/:key/regenerate  (--force semantics)
+	//   GET    /api/summaries/:type/:key/annotations
+	//   POST   /api/summaries/:type/:key/annotations {"note":"..."}
+	//   all take ?user= (daily of another user = their user_daily, see summary_admin.go)
+	mux.HandleFunc("/api/summaries/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/summaries/"), "/"), "/")
+		if len(parts) < 2 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		user, err := requestUser(cfg, r)
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		var req struct {
-			Action      string `json:"action"`
-			Replacement string `json:"replacement"`
-		}
-		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		ucfg := cfg
+		ucfg.User = user
+		typ, key, err := userSummaryRef(user, parts[0], parts[1])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		now := time.Now().In(cfg.Location)
-		switch strings.ToLower(strings.TrimSpace(req.Action)) {
-		case "keep":
-			if err := ResolveFactConflictKeep(db, id, now); err != nil {
+		if len(parts) == 2 {
+			switch r.Method {
+			case http.MethodGet:
+				d, err := GetSummary(db, typ, key)
+				if errors.Is(err, errSummaryNotFound) {
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				if err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "summary": d})
+			case http.MethodDelete:
+				if err := SoftDeleteSummary(ucfg, db, typ, key); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if len(parts) == 3 && parts[2] == "regenerate" {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			d, err := RegenerateSummary(ucfg, db, typ, key)
+			if errors.Is(err, errSummaryNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			if err != nil {
 				w.WriteHeader(http.StatusBadRequest)
 				_, _ = w.Write([]byte(err.Error()))
 				return
 			}
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "summary": d})
 			return
-		case "replace":
-			if err := ResolveFactConflictReplace(cfg, db, id, req.Replacement, now); err != nil {
+		}
+		if len(parts) != 3 || parts[2] != "annotations" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			items, err := ListSummaryAnnotations(db, typ, key)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+		case http.MethodPost:
+			var req struct {
+				Note string `json:"note"`
+			}
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			a, err := AddSummaryAnnotation(ucfg, db, typ, key, req.Note)
+			if err != nil {
 				w.WriteHeader(http.StatusBadRequest)
 				_, _ = w.Write([]byte(err.Error()))
 				return
 			}
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
-			return
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "annotation": a})
 		default:
-			w.WriteHeader(http.StatusBadRequest)
-			_, _ = w.Write([]byte("invalid action: expected keep|replace"))
-			return
+			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
 	})
 
 	// =========================
-	// Debug: context injection audit
+	// Assistant profiles
 	// =========================
-	auditHandler := func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
+	//   GET    /api/assistants
+	//   POST   /api/assistants {"name":"工作助手","persona":"...","scope":{"workspace":"acme"},...}
+	//   DELETE /api/assistants/:name
+	mux.HandleFunc("/api/assistants", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			items, err := ListAssistants(db)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+		case http.MethodPost:
+			var req AssistantProfile
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			a, err := SaveAssistant(cfg, db, req)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "assistant": a})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/assistants/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		name, _ := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/api/assistants/"))
+		if err := DeleteAssistant(db, name); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	})
+
+	// =========================
+	// Users (several people sharing one instance, see users.go)
+	// =========================
+	//   GET /api/users   -> users with memory; chat takes {"user":"..."}, fact lists ?user=
+	mux.HandleFunc("/api/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		items, err := ListUsers(db)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items, "current": cfg.User})
+	})
+
+	// =========================
+	// Sessions (independent conversations per day, see sessions.go)
+	// =========================
+	//   GET    /api/sessions?user=       -> sessions, most recently used first
+	//   POST   /api/sessions {"title":"...","user":"..."} -> new session; chat takes {"session":"<id>"}
+	//   POST   /api/sessions/:id {"title":"..."}  (rename)
+	//   DELETE /api/sessions/:id?user=
+	mux.HandleFunc("/api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			user, err := requestUser(cfg, r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			items, err := ListSessions(db, user)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+		case http.MethodPost:
+			var req struct {
+				Title string `json:"title"`
+				User  string `json:"user"`
+			}
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			userCfg, err := apiChatReq{User: req.User}.userConfig(cfg)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			sess, err := CreateSession(userCfg, db, req.Title)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "session": sess})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/api/sessions/"))
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			var req struct {
+				Title string `json:"title"`
+			}
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			sess, err := RenameSession(cfg, db, user, id, req.Title)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "session": sess})
+		case http.MethodDelete:
+			if err := DeleteSession(db, user, id); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	// =========================
+	// Glossary (term → definition, injected when the term is asked about)
+	// =========================
+	//   GET    /api/glossary
+	//   POST   /api/glossary {"term":"TL","definition":"timelayer, this project"}
+	//   DELETE /api/glossary/:term
+	mux.HandleFunc("/api/glossary", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			items, err := ListGlossary(db)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+		case http.MethodPost:
+			var req GlossaryEntry
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			e, err := SaveGlossaryEntry(cfg, db, req.Term, req.Definition)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "entry": e})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/glossary/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		term, _ := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/api/glossary/"))
+		if err := DeleteGlossaryEntry(db, term); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	})
+
+	// =========================
+	// Guided command flows (multi-turn: flow id + next prompt)
+	// =========================
+	//   GET    /api/flows
+	//   POST   /api/flows/start {"flow":"replace_conflict","arg":""}
+	//   POST   /api/flows/:id {"input":"keep"}
+	//   DELETE /api/flows/:id
+	mux.HandleFunc("/api/flows", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		items := make([]map[string]string, 0, len(commandFlows))
+		for _, n := range commandFlowNames() {
+			items = append(items, map[string]string{"flow": n, "usage": commandFlows[n].Usage})
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+	})
+	mux.HandleFunc("/api/flows/", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/api/flows/"))
+		var (
+			step FlowStep
+			err  error
+		)
+		switch {
+		case r.Method == http.MethodPost && id == "start":
+			var req apiFlowStartReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			step, err = StartCommandFlow(cfg, db, req.Flow, req.Arg)
+		case r.Method == http.MethodPost:
+			var req apiFlowInputReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			step, err = AdvanceCommandFlow(cfg, db, id, req.Input)
+			if errors.Is(err, errFlowNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+		case r.Method == http.MethodDelete:
+			if !CancelCommandFlow(id) {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(errFlowNotFound.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+			return
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "step": step})
+	})
+
+	// =========================
+	// Trash (soft-deleted facts / pending / summaries)
+	// =========================
+	//   GET  /api/trash?user=
+	//   POST /api/trash/restore?user= {"kind":"fact|pending|summary","id":123}
+	mux.HandleFunc("/api/trash", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		ucfg := cfg
+		ucfg.User = user
+		items, err := ListTrash(ucfg, db)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "trash_days": cfg.TrashDays, "items": items})
+	})
+	mux.HandleFunc("/api/trash/restore", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Kind string `json:"kind"`
+			ID   int64  `json:"id"`
+		}
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		ucfg := cfg
+		ucfg.User = user
+		if err := RestoreTrashItem(ucfg, db, req.Kind, req.ID); err != nil {
+			if errors.Is(err, errFactRestoreConflict) {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	})
+
+	// =========================
+	// Chat export (Markdown / HTML transcript)
+	// =========================
+	//   GET /api/export/chat?from=2026-01-01&to=2026-01-07&format=md|html
+	mux.HandleFunc("/api/export/chat", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		format, ok := normalizeExportFormat(q.Get("format"))
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("unknown format (use md or html)"))
+			return
+		}
+		from := strings.TrimSpace(q.Get("from"))
+		if from == "" {
+			from = time.Now().In(cfg.Location).Format("2006-01-02")
+		}
+		out, err := ExportChat(cfg, db, from, q.Get("to"), format)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", exportContentType(format))
+		_, _ = w.Write([]byte(out))
+	})
+
+	// =========================
+	// Summary export (Markdown digest)
+	// =========================
+	//   GET /api/export/summaries?type=weekly&key=2026-W02
+	//   GET /api/export/summaries?year=2026[&type=monthly]   -> year in review
+	mux.HandleFunc("/api/export/summaries", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		var out string
+		var err error
+		switch {
+		case q.Get("year") != "":
+			out, err = ExportYearInReview(db, q.Get("year"), q.Get("type"))
+		case q.Get("type") != "" && q.Get("key") != "":
+			out, err = ExportSummaryMarkdown(db, q.Get("type"), q.Get("key"))
+		default:
+			err = fmt.Errorf("need type+key or year")
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", exportContentType(exportFormatMarkdown))
+		_, _ = w.Write([]byte(out))
+	})
+
+	// =========================
+	// External events (fitness / git / calendar webhooks)
+	// =========================
+	//   POST /api/ingest/event {"source":"git","type":"commit","text":"fix login bug","data":{"repo":"api"}}
+	mux.HandleFunc("/api/ingest/event", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var ev IngestEvent
+		if err := decodeJSONLimited(w, r, &ev, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		ev, err := IngestExternalEvent(cfg, lw, ev)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "event": ev})
+	})
+
+	// =========================
+	// Raw messages (TIMELAYER_LOG_STORAGE=sqlite|both)
+	// =========================
+	//   GET /api/messages?date=2026-01-08&limit=50
+	mux.HandleFunc("/api/messages", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		date := strings.TrimSpace(r.URL.Query().Get("date"))
+		if date == "" {
+			date = time.Now().In(cfg.Location).Format("2006-01-02")
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		items, err := ListRawMessages(db, date, limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "date": date, "items": items})
+	})
+
+	// =========================
+	// Fact tags
+	// =========================
+	//   GET  /api/facts/tags               -> tags in use (with counts)
+	//   POST /api/facts/tags {"fact_key":"...","tags":["work"],"action":"set|add|remove"}
+	mux.HandleFunc("/api/facts/tags", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			items, err := ListFactTags(db)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+		case http.MethodPost:
+			var req apiFactTagsReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			key := strings.TrimSpace(req.FactKey)
+			if key == "" {
+				user, err := requestUser(cfg, r)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				key = resolveFactKeyForTagging(db, user, req.Fact)
+			}
+			if key == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte("fact not found"))
+				return
+			}
+			tags, err := SetFactTags(cfg, db, key, req.Tags, req.Action)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "fact_key": key, "tags": tags})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	//   GET  /api/facts/core               -> core facts (always injected)
+	//   POST /api/facts/core {"fact_key":"...","core":true}
+	//   GET  /api/facts/duplicates?user=  groups of active facts that look like the same fact
+	//   POST /api/facts/merge  {"fact_keys":[...],"keep":"<key>","text":"optional new wording"}
+	mux.HandleFunc("/api/facts/duplicates", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		groups, err := FindDuplicateFacts(db, user)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "groups": groups, "count": len(groups)})
+	})
+
+	mux.HandleFunc("/api/facts/merge", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req apiFactMergeReq
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		res, err := MergeFacts(cfg, db, req.FactKeys, req.Keep, req.Text)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": res})
+	})
+
+	mux.HandleFunc("/api/facts/core", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			user, err := requestUser(cfg, r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			items, err := ListCoreFacts(db, user)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+		case http.MethodPost:
+			var req apiFactCoreReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			key := strings.TrimSpace(req.FactKey)
+			if key == "" {
+				user, err := requestUser(cfg, r)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				key = resolveFactKeyForTagging(db, user, req.Fact)
+			}
+			if err := SetFactCore(cfg, db, key, req.Core); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "fact_key": key, "core": req.Core})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	//   GET  /api/facts/categories         -> active facts per category + injection budget
+	//   POST /api/facts/categories {"fact_key":"...","category":"work"}
+	mux.HandleFunc("/api/facts/categories", func(w http.ResponseWriter, r *http.Request) {
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			items, err := ListFactCategories(cfg, db, user)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+		case http.MethodPost:
+			var req apiFactCategoryReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			key := strings.TrimSpace(req.FactKey)
+			if key == "" {
+				key = resolveFactKeyForTagging(db, user, req.Fact)
+			}
+			if err := SetFactCategory(cfg, db, key, req.Category); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "fact_key": key, "category": normalizeFactCategory(req.Category)})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	//   GET /api/facts/value_sets?relation=like&subject=我   -> multi-valued sets (喜欢 / 讨厌 / 擅长 ...)
+	mux.HandleFunc("/api/facts/value_sets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		items, err := ListFactValueSets(db, user, q.Get("relation"), q.Get("subject"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+	})
+
+	// =========================
+	// Subject aliases
+	// =========================
+	//   GET  /api/facts/subject_aliases
+	//   POST /api/facts/subject_aliases {"alias":"我本人","canonical":"我","action":"set|delete","dry_run":true}
+	mux.HandleFunc("/api/facts/subject_aliases", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			items, err := ListSubjectAliases(db)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items, "by_canonical": subjectAliasGroups(items)})
+		case http.MethodPost:
+			var req apiSubjectAliasReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			var rep FactKeyMigrationReport
+			var err error
+			if req.DryRun {
+				rep, err = PreviewSubjectAlias(cfg, db, req.Alias, req.Canonical, req.Action)
+			} else {
+				rep, err = SetSubjectAlias(cfg, db, req.Alias, req.Canonical, req.Action)
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			items, _ := ListSubjectAliases(db)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "dry_run": req.DryRun, "report": rep, "items": items})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	// =========================
+	// Fact deny rules (see fact_policy.go)
+	// =========================
+	//   GET    /api/facts/policies
+	//   POST   /api/facts/policies      {"kind":"keyword|regex|relation","pattern":"密码","note":"..."}
+	//   DELETE /api/facts/policies/:id
+	//   POST   /api/facts/policies/test {"fact":"..."}  -> which rule (if any) would drop it
+	mux.HandleFunc("/api/facts/policies", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			items, err := ListFactDenyRules(db)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "items": items})
+		case http.MethodPost:
+			var req apiFactPolicyReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			rule, err := AddFactDenyRule(cfg, db, req.Kind, req.Pattern, req.Note)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "rule": rule})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/facts/policies/", func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/facts/policies/"), "/")
+		switch {
+		case rest == "test" && r.Method == http.MethodPost:
+			var req apiFactPolicyReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			rule, blocked := matchFactDenyRule(currentFactDenyRules(), req.Fact)
+			resp := map[string]any{"ok": true, "blocked": blocked}
+			if blocked {
+				resp["rule"] = rule
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(resp)
+		case r.Method == http.MethodDelete:
+			id, err := strconv.ParseInt(rest, 10, 64)
+			if err != nil || id <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte("invalid id"))
+				return
+			}
+			if err := DeleteFactDenyRule(db, id); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	//   POST /api/summaries/tags {"type":"daily","period_key":"2026-01-08","tags":["ws:acme"],"action":"add"}
+	mux.HandleFunc("/api/summaries/tags", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req apiSummaryTagsReq
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		tags, err := SetSummaryTags(cfg, db, req.Type, req.PeriodKey, req.Tags, req.Action)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "tags": tags})
+	})
+
+	// =========================
+	// On this day (see on_this_day.go)
+	// =========================
+	//   GET /api/on-this-day?date=2026-10-16   (default today)
+	mux.HandleFunc("/api/on-this-day", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		date := strings.TrimSpace(r.URL.Query().Get("date"))
+		if date == "" {
+			date = time.Now().In(cfg.Location).Format("2006-01-02")
+		}
+		items, err := FindOnThisDay(cfg, db, date)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "date": date, "items": items})
+	})
+
+	// =========================
+	// Memory timeline (life log feed, see timeline.go)
+	// =========================
+	//   GET /api/timeline?from=2026-01-01&to=2026-01-31[&types=...][&order=desc][&limit=500]
+	mux.HandleFunc("/api/timeline", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		q, err := parseTimelineQuery(cfg, r.URL.Query().Get)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		items, err := BuildTimeline(cfg, db, q)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "from": q.From, "to": q.To, "items": items})
+	})
+
+	// =========================
+	// Memory snapshot diff (what changed between two dates, see memory_diff.go)
+	// =========================
+	//   GET /api/memory/diff?from=2025-06-01&to=2025-12-01[&window=30][&narrate=1]
+	mux.HandleFunc("/api/memory/diff", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		q, err := parseMemoryDiffQuery(cfg, r.URL.Query().Get)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		d, err := BuildMemoryDiff(cfg, db, q)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "diff": d})
+	})
+
+	mux.HandleFunc("/api/facts/history", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// ?limit=200 &after_id=123 &user=alice
+		limit := parseIntClamp(r.URL.Query().Get("limit"), 200, 1, 500)
+		afterID, err := parseAfterID(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		items, err := ListUserFactHistoryAfter(db, user, afterID, limit+1)
+		if errors.Is(err, errUnknownCursor) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		resp := map[string]any{"ok": true}
+		if len(items) > limit {
+			items = items[:limit]
+			resp["next_after_id"] = items[limit-1].ID
+		}
+		resp["items"], resp["count"] = items, len(items)
+		resp["total"], _ = CountUserFactHistory(db, user)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+
+	//   GET  /api/facts/search-consistency  user_facts vs fact search rows
+	//   POST /api/facts/search-consistency  repair (delete orphans, resync missing / stale)
+	mux.HandleFunc("/api/facts/search-consistency", func(w http.ResponseWriter, r *http.Request) {
+		var rep FactSearchReport
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			rep, err = CheckFactSearchConsistency(db)
+		case http.MethodPost:
+			rep, err = RepairFactSearch(cfg, db)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "consistent": rep.consistent(), "report": rep})
+	})
+
+	// =========================
+	// Fact conflicts API
+	// =========================
+	mux.HandleFunc("/api/facts/conflicts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		user, err := requestUser(cfg, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		// ?limit=60 &after_id=123 &user=alice
+		limit := parseIntClamp(r.URL.Query().Get("limit"), 60, 1, 500)
+		afterID, err := parseAfterID(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		items, err := ListFactConflictsAfter(db, user, afterID, limit+1)
+		if errors.Is(err, errUnknownCursor) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		resp := map[string]any{"ok": true}
+		if len(items) > limit {
+			items = items[:limit]
+			resp["next_after_id"] = items[limit-1].ID
+		}
+		resp["items"], resp["count"] = items, len(items)
+		resp["total"], _ = CountUserFactConflicts(db, user)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+
+	mux.HandleFunc("/api/facts/conflicts/keep", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req apiPendingActionReq
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if req.ID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := ResolveFactConflictKeep(db, req.ID, time.Now().In(cfg.Location)); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	})
+
+	mux.HandleFunc("/api/facts/conflicts/replace", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req apiConflictActionReq
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if req.ID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := ResolveFactConflictReplace(cfg, db, req.ID, req.Replacement, time.Now().In(cfg.Location)); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	})
+
+	// Convenience REST-style endpoint (alias)
+	//   POST /api/facts/conflicts/:id/resolve
+	// Body:
+	//   {"action":"keep"}
+	//   {"action":"replace","replacement":"..."}
+	//   POST /api/facts/conflicts/:id/suggest (LLM advice only, see fact_conflict_suggest.go)
+	mux.HandleFunc("/api/facts/conflicts/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		rest := strings.TrimPrefix(r.URL.Path, "/api/facts/conflicts/")
+		parts := strings.Split(strings.Trim(rest, "/"), "/")
+		if len(parts) != 2 || (parts[1] != "resolve" && parts[1] != "suggest") {
+			http.NotFound(w, r)
+			return
+		}
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || id <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if parts[1] == "suggest" {
+			s, err := SuggestFactConflict(cfg, db, id)
+			switch {
+			case errors.Is(err, errConflictNotFound):
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			case errors.Is(err, errConflictResolved):
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			case err != nil:
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "suggestion": s})
+			return
+		}
+		var req struct {
+			Action      string `json:"action"`
+			Replacement string `json:"replacement"`
+		}
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		now := time.Now().In(cfg.Location)
+		switch strings.ToLower(strings.TrimSpace(req.Action)) {
+		case "keep":
+			if err := ResolveFactConflictKeep(db, id, now); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+			return
+		case "replace":
+			if err := ResolveFactConflictReplace(cfg, db, id, req.Replacement, now); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+			return
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid action: expected keep|replace"))
+			return
+		}
+	})
+
+	// =========================
+	// Debug: context injection audit
+	// =========================
+	auditHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req apiChatReq
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		// Accept both {"input": "..."} and legacy {"question": "..."}
+		q := strings.TrimSpace(req.Input)
+		if q == "" {
+			q = strings.TrimSpace(req.Question)
+		}
+		if q == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if cfg.HTTPMaxInputBytes > 0 && len(q) > cfg.HTTPMaxInputBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		chatCfg, err := req.chatConfig(cfg, db)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		date := time.Now().In(cfg.Location).Format("2006-01-02")
+		audit := BuildChatContextAudit(chatCfg, db, date, q)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		// Web UI expects the audit object at top-level.
+		_ = json.NewEncoder(w).Encode(audit)
+	}
+	mux.HandleFunc("/api/debug/context", auditHandler)
+	// Alias for README/diagram friendliness
+	mux.HandleFunc("/api/context/audit", auditHandler)
+
+	// Chat preview: the full context of a turn, without the model call (see chat_preview.go)
+	//   POST /api/chat/preview {"input":"...", ...}
+	mux.HandleFunc("/api/chat/preview", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req apiChatReq
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		q := strings.TrimSpace(req.Input)
+		if q == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if cfg.HTTPMaxInputBytes > 0 && len(q) > cfg.HTTPMaxInputBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		chatCfg, err := req.chatConfig(cfg, db)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "preview": BuildChatPreview(chatCfg, db, q)})
+	})
+
+	// Stored per-turn audits (TIMELAYER_CONTEXT_AUDIT_PERSIST)
+	//   GET /api/context/audits?limit=50
+	//   GET /api/context/audits/:turn_id
+	mux.HandleFunc("/api/context/audits", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		limit := parseIntClamp(r.URL.Query().Get("limit"), contextAuditListDefault, 1, contextAuditListMax)
+		items, err := ListTurnContextAudits(db, limit)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"ok":             true,
+			"persist":        cfg.ContextAuditPersist,
+			"retention_days": cfg.ContextAuditRetentionDays,
+			"audits":         items,
+		})
+	})
+	mux.HandleFunc("/api/context/audits/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/context/audits/"), "/")
+		if id == "" || strings.Contains(id, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		ta, err := GetTurnContextAudit(db, id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "audit": ta})
+	})
+
+	// =========================
+	// Non-stream chat
+	// =========================
+	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req apiChatReq
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		req.Input = strings.TrimSpace(req.Input)
+		if req.Input == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if cfg.HTTPMaxInputBytes > 0 && len(req.Input) > cfg.HTTPMaxInputBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		// ===== 1️⃣ 命令优先（CLI 同源）=====
+		if strings.HasPrefix(req.Input, "/") {
+			cmdCfg, err := req.userConfig(cfg)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			handled, out, err := HandleCommandWeb(cmdCfg, db, lw, req.Input)
+			if handled {
+				if err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					_, _ = w.Write([]byte(err.Error()))
+					return
+				}
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				_ = json.NewEncoder(w).Encode(apiChatResp{Text: out})
+				return
+			}
+		}
+
+		// ===== 2️⃣ 普通对话（LLM）=====
+		chatCfg, err := req.chatConfig(cfg, db)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		ans, turnID, err := ChatTurnWithContext(r.Context(), lw, chatCfg, db, req.Input, false, nil)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(apiChatResp{Text: ans, TurnID: turnID})
+	})
+
+	// =========================
+	// Memory-grounded Q&A (/ask over HTTP, see ask.go)
+	// =========================
+	//   POST /api/ask {"question":"...","type":"monthly","period":"2025-09","doc":0,"scope":{...},"user":""}
+	//   → {"ok":true,"supported":bool,"answer":"...","references":[{rank,type,date,score,text,summary_id}]}
+	mux.HandleFunc("/api/ask", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req apiAskReq
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		q := strings.TrimSpace(req.Question)
+		if q == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("question is required"))
+			return
+		}
+		if cfg.HTTPMaxInputBytes > 0 && len(q) > cfg.HTTPMaxInputBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		askCfg, err := apiChatReq{User: req.User}.userConfig(cfg)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if req.Scope != nil {
+			askCfg.Scope = *req.Scope
+		}
+		res, err := AskQuestion(db, askCfg, q, req.AskTarget)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"ok":           true,
+			"question":     res.Question,
+			"supported":    res.Supported,
+			"answer":       res.Answer,
+			"unstructured": res.Unstructured,
+			"references":   res.References,
+		})
+	})
+
+	//   POST /api/ask/stream (same body; SSE, see ask_stream.go)
+	//   → {"delta":"..."}* {"references":[...],"supported":bool,"answer":"...","unstructured":bool} {"done":"1"}
+	mux.HandleFunc("/api/ask/stream", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		fl, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var req apiAskReq
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		q := strings.TrimSpace(req.Question)
+		if q == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("question is required"))
+			return
+		}
+		if cfg.HTTPMaxInputBytes > 0 && len(q) > cfg.HTTPMaxInputBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		askCfg, err := apiChatReq{User: req.User}.userConfig(cfg)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if req.Scope != nil {
+			askCfg.Scope = *req.Scope
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		_, _ = w.Write([]byte(":ok\n\n"))
+		fl.Flush()
+
+		select {
+		case streamSem <- struct{}{}:
+			defer func() { <-streamSem }()
+		default:
+			_ = writeSSE(w, fl, map[string]string{"error": "too many concurrent streams"})
+			_ = writeSSE(w, fl, map[string]string{"done": "1"})
+			return
+		}
+		if life.isDraining() {
+			_ = writeSSE(w, fl, map[string]string{"error": errWebShuttingDown.Error()})
+			_ = writeSSE(w, fl, map[string]string{"done": "1"})
+			return
+		}
+		life.enter()
+		defer life.leave()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		defer context.AfterFunc(life.stopped, cancel)() // drain timeout (web_shutdown.go)
+
+		res, err := AskQuestionStream(ctx, db, askCfg, q, req.AskTarget, func(delta string) {
+			if err := writeSSE(w, fl, map[string]string{"delta": delta}); err != nil {
+				cancel() // client gone: stop the model
+			}
+		})
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				if life.stopped.Err() != nil {
+					_ = writeSSE(w, fl, map[string]string{"error": errWebShuttingDown.Error()})
+				}
+				return
+			}
+			_ = writeSSE(w, fl, map[string]string{"error": err.Error()})
+			_ = writeSSE(w, fl, map[string]string{"done": "1"})
+			return
 		}
-		var req apiChatReq
-		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+		_ = writeSSE(w, fl, map[string]any{
+			"references":   res.References,
+			"supported":    res.Supported,
+			"answer":       res.Answer,
+			"unstructured": res.Unstructured,
+		})
+		_ = writeSSE(w, fl, map[string]string{"done": "1"})
+	})
+
+	// =========================
+	// Metrics (Prometheus text format)
+	// =========================
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w)
+	})
+
+	// =========================
+	// Stats (memory counts + embedding coverage)
+	// =========================
+	//   GET /api/stats?user=  (summary / fact / pending counts are per user; coverage is global)
+	mux.HandleFunc("/api/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		user, err := requestUser(cfg, r)
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		// Accept both {"input": "..."} and legacy {"question": "..."}
-		q := strings.TrimSpace(req.Input)
-		if q == "" {
-			q = strings.TrimSpace(req.Question)
+		cov, err := GetEmbeddingCoverage(db)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
 		}
-		if q == "" {
-			w.WriteHeader(http.StatusBadRequest)
+		summaries := map[string]int{}
+		rows, err := db.Query(`SELECT type, COUNT(*) FROM summaries WHERE deleted_at IS NULL AND user_id=? GROUP BY type`, user)
+		if err == nil {
+			for rows.Next() {
+				var typ string
+				var n int
+				if rows.Scan(&typ, &n) == nil {
+					summaries[typ] = n
+				}
+			}
+			rows.Close()
+		}
+		var facts int
+		_ = db.QueryRow(`SELECT COUNT(*) FROM user_facts WHERE is_active=1 AND user_id=?`, user).Scan(&facts)
+		pending, _ := CountPendingFactsQuery(db, PendingFactQuery{User: user})
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"ok":               true,
+			"summaries":        summaries,
+			"facts":            facts,
+			"pending":          pending,
+			"embeddings":       cov,
+			"embedding_model":  GetEmbeddingModelStatus(db),
+			"implicit_capture": GetImplicitCaptureStats(cfg, time.Now().In(cfg.Location)),
+		})
+	})
+
+	// =========================
+	// Background jobs + LLM budget
+	// =========================
+	//   GET /api/jobs
+	mux.HandleFunc("/api/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		if cfg.HTTPMaxInputBytes > 0 && len(q) > cfg.HTTPMaxInputBytes {
-			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		budget, err := GetLLMBudgetStatus(cfg, db)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		jobs, err := ListBackgroundJobs(db, 50)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		date := time.Now().In(cfg.Location).Format("2006-01-02")
-		audit := BuildChatContextAudit(cfg, db, date, q)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		// Web UI expects the audit object at top-level.
-		_ = json.NewEncoder(w).Encode(audit)
-	}
-	mux.HandleFunc("/api/debug/context", auditHandler)
-	// Alias for README/diagram friendliness
-	mux.HandleFunc("/api/context/audit", auditHandler)
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "budget": budget, "jobs": jobs})
+	})
 
 	// =========================
-	// Non-stream chat
+	// Per-turn prompt (reproducibility)
 	// =========================
-	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
+	//   GET /api/chat/turns/:id/prompt
+	//   GET /api/chat/turns/:id/audit
+	//   POST /api/chat/turns/:id/feedback   {"rating":"up"|"down","note":""}
+	mux.HandleFunc("/api/chat/turns/", func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/api/chat/turns/")
+		parts := strings.Split(strings.Trim(rest, "/"), "/")
+		if len(parts) != 2 || parts[0] == "" || (parts[1] != "prompt" && parts[1] != "audit" && parts[1] != "feedback") {
+			http.NotFound(w, r)
+			return
+		}
+		if parts[1] == "feedback" {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			var req apiTurnFeedbackReq
+			if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			rating, err := parseTurnRating(req.Rating)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			fb, err := SetTurnFeedback(cfg, db, parts[0], rating, req.Note)
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "feedback": fb})
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if parts[1] == "audit" {
+			ta, err := GetTurnContextAudit(db, parts[0])
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "audit": ta})
+			return
+		}
+		tp, err := GetTurnPrompt(db, parts[0])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "prompt": tp})
+	})
+
+	//   GET /api/tune/suggest?days=30&min=5   (retrieval tuning from turn feedback)
+	mux.HandleFunc("/api/tune/suggest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
+		q := r.URL.Query()
+		rep, err := SuggestRetrievalTuning(cfg, db,
+			parseIntClamp(q.Get("days"), tuneDefaultDays, 1, tuneMaxDays),
+			parseIntClamp(q.Get("min"), tuneDefaultMinDown, 1, 1000))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "report": rep})
+	})
 
-		var req apiChatReq
+	//   GET  /api/archive           (archive manifest + legacy monthly files)
+	//   POST /api/archive/restore   {"day":"YYYY-MM-DD","force":false,"reprocess":false}
+	mux.HandleFunc("/api/archive", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		list, err := ListArchive(cfg, db)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "archive": list})
+	})
+	mux.HandleFunc("/api/archive/restore", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req apiArchiveRestoreReq
 		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-
-		req.Input = strings.TrimSpace(req.Input)
-		if req.Input == "" {
+		rep, err := RestoreArchivedDay(cfg, db, strings.TrimSpace(req.Day), req.Force, req.Reprocess)
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
-		if cfg.HTTPMaxInputBytes > 0 && len(req.Input) > cfg.HTTPMaxInputBytes {
-			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "restored": rep})
+	})
+
+	//   POST /api/chat/messages/:id/redact   (id = <date>:<seq> or a messages id)
+	mux.HandleFunc("/api/chat/messages/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
+		rest := strings.TrimPrefix(r.URL.Path, "/api/chat/messages/")
+		parts := strings.Split(strings.Trim(rest, "/"), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] != "redact" {
+			http.NotFound(w, r)
+			return
+		}
+		res, err := RedactMessage(cfg, db, lw, parts[0])
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": err.Error()})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "redacted": res})
+	})
 
-		// ===== 1️⃣ 命令优先（CLI 同源）=====
-		if strings.HasPrefix(req.Input, "/") {
-			handled, out, err := HandleCommandWeb(cfg, db, lw, req.Input)
-			if handled {
-				if err != nil {
-					w.WriteHeader(http.StatusBadGateway)
-					_, _ = w.Write([]byte(err.Error()))
-					return
-				}
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				_ = json.NewEncoder(w).Encode(apiChatResp{Text: out})
+	//   POST /api/admin/wipe {"confirm":"WIPE","export":true}
+	// Off unless TIMELAYER_HTTP_ALLOW_WIPE=1. With an auth token configured the
+	// token is required even from loopback; without one only a direct loopback
+	// peer may call it.
+	mux.HandleFunc("/api/admin/wipe", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fail := func(code int, msg string) {
+			w.WriteHeader(code)
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": msg})
+		}
+		if !cfg.HTTPAllowWipe {
+			fail(http.StatusForbidden, "wipe is disabled (set TIMELAYER_HTTP_ALLOW_WIPE=1)")
+			return
+		}
+		if cfg.HTTPAuthToken != "" {
+			if !requestHasToken(cfg.HTTPAuthToken, r) {
+				fail(http.StatusUnauthorized, "auth token required")
 				return
 			}
+		} else if !isLoopbackRemoteAddr(r.RemoteAddr) || hasForwardedHeaders(r) {
+			fail(http.StatusForbidden, "wipe without an auth token is loopback-only")
+			return
 		}
-
-		// ===== 2️⃣ 普通对话（LLM）=====
-		ans, err := ChatOnceWithContext(r.Context(), lw, cfg, db, req.Input, false, nil)
+		var req struct {
+			Confirm string `json:"confirm"`
+			Export  bool   `json:"export"`
+		}
+		if err := decodeJSONLimited(w, r, &req, maxJSONBodyBytes); err != nil {
+			fail(http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.Confirm != wipeConfirmPhrase {
+			fail(http.StatusBadRequest, `confirm must be "`+wipeConfirmPhrase+`"`)
+			return
+		}
+		exportDir := ""
+		if req.Export {
+			exportDir = defaultWipeExportDir(cfg)
+		}
+		rep, err := WipeAll(cfg, db, lw, exportDir)
 		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				return
-			}
-			w.WriteHeader(http.StatusBadGateway)
-			_, _ = w.Write([]byte(err.Error()))
+			fail(http.StatusInternalServerError, err.Error())
 			return
 		}
-
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(apiChatResp{Text: ans})
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "wipe": rep})
 	})
 
 	// =========================
@@ -609,28 +2572,22 @@ func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
 		// ===== 1️⃣ 命令模式（一次性返回）=====
 		if strings.HasPrefix(req.Input, "/") {
 			cmd, _ := normalizeCommand(req.Input)
-			handled, out, err := HandleCommandWeb(cfg, db, lw, req.Input)
+			cmdCfg, err := req.userConfig(cfg)
+			if err != nil {
+				_ = writeSSE(w, fl, map[string]string{"error": err.Error()})
+				_ = writeSSE(w, fl, map[string]string{"done": "1"})
+				return
+			}
+			handled, out, err := HandleCommandWeb(cmdCfg, db, lw, req.Input)
 			if handled {
 				if err != nil {
 					writeSSE(w, fl, map[string]string{"error": err.Error()})
 					return
 				}
 
-				// Facts ops are designed to be "silent" in chat: only refresh LEDs/counters.
-				out = strings.TrimSpace(out)
-				silentFacts := (cmd == "/remember" || cmd == "/forget" || cmd == "/pending_add") &&
-					(strings.HasPrefix(out, "[ok]") || strings.HasPrefix(out, "[noop]"))
-				if cmd == "/remember" || cmd == "/forget" || cmd == "/pending_add" {
-					_ = writeSSE(w, fl, map[string]string{"notice": "facts"})
-					if !silentFacts && out != "" {
-						_ = writeSSE(w, fl, map[string]string{"delta": out})
-					}
-					_ = writeSSE(w, fl, map[string]string{"done": "1"})
-					return
+				for _, f := range chatCommandFrames(cmd, out) {
+					_ = writeSSE(w, fl, f)
 				}
-
-				_ = writeSSE(w, fl, map[string]string{"delta": out})
-				_ = writeSSE(w, fl, map[string]string{"done": "1"})
 				return
 			}
 		}
@@ -644,39 +2601,126 @@ func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
 			_ = writeSSE(w, fl, map[string]string{"done": "1"})
 			return
 		}
+		if life.isDraining() {
+			_ = writeSSE(w, fl, map[string]string{"error": errWebShuttingDown.Error()})
+			_ = writeSSE(w, fl, map[string]string{"done": "1"})
+			return
+		}
+		life.enter()
+		defer life.leave()
 
 		// ===== 2️⃣ 普通对话（流式 LLM）=====
-		ctx, cancel := context.WithCancel(r.Context())
+		chatCfg, err := req.chatConfig(cfg, db)
+		if err != nil {
+			_ = writeSSE(w, fl, map[string]string{"error": err.Error()})
+			_ = writeSSE(w, fl, map[string]string{"done": "1"})
+			return
+		}
+
+		// resumable: deltas are buffered per generation and a dropped client
+		// does not cancel the model (stream_resume.go)
+		parent := r.Context()
+		genID, gen := "", (*streamGen)(nil)
+		if cfg.HTTPStreamResumeWindow > 0 {
+			genID, gen = newStreamGen()
+			parent = context.WithoutCancel(parent)
+			_ = writeSSE(w, fl, map[string]string{"gen": genID})
+		}
+		ctx, cancel := context.WithCancel(parent)
 		defer cancel()
+		defer context.AfterFunc(life.stopped, cancel)() // drain timeout (web_shutdown.go)
 
-		_, err := ChatOnceWithContext(ctx, lw, cfg, db, req.Input, false, func(delta string) {
+		clientGone := false
+		_, turnID, err := ChatTurnWithContext(ctx, lw, chatCfg, db, req.Input, false, func(delta string) {
 			select {
 			case <-ctx.Done():
 				return
 			default:
 			}
 
+			if gen != nil {
+				gen.append(delta)
+			}
+			if clientGone {
+				return
+			}
 			if err := writeSSE(w, fl, map[string]string{"delta": delta}); err != nil {
+				if gen != nil {
+					clientGone = true // keep generating; the client may resume
+					return
+				}
 				cancel() // 触发上游取消
 				return
 			}
 		})
 
+		if gen != nil {
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			gen.finish(genID, turnID, errMsg, cfg.HTTPStreamResumeWindow)
+		}
+		if clientGone {
+			return
+		}
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
+				if life.stopped.Err() != nil {
+					_ = writeSSE(w, fl, map[string]string{"error": errWebShuttingDown.Error()})
+				}
 				return
 			}
 			_ = writeSSE(w, fl, map[string]string{"error": err.Error()})
 			return
 		}
 
+		if turnID != "" {
+			_ = writeSSE(w, fl, map[string]string{"turn_id": turnID})
+		}
 		_ = writeSSE(w, fl, map[string]string{"done": "1"})
 		time.Sleep(10 * time.Millisecond)
 	})
 
+	// =========================
+	// WebSocket chat (chat_ws.go)
+	// =========================
+	//   GET /api/chat/ws   (upgrade; {"type":"chat","input":"..."} / {"type":"cancel"})
+	mux.HandleFunc("/api/chat/ws", func(w http.ResponseWriter, r *http.Request) {
+		serveChatWS(cfg, db, lw, streamSem, life, w, r)
+	})
+
+	//   GET /api/chat/stream/resume?gen=...&offset=N   (replay missed deltas, then follow)
+	mux.HandleFunc("/api/chat/stream/resume", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		fl, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		q := r.URL.Query()
+		gen, ok := lookupStreamGen(strings.TrimSpace(q.Get("gen")))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("unknown or expired generation"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		_, _ = w.Write([]byte(":ok\n\n"))
+		fl.Flush()
+
+		serveStreamResume(w, r, fl, gen, parseIntClamp(q.Get("offset"), 0, 0, 0))
+	})
+
 	srv := &http.Server{
 		Addr:              cfg.HTTPAddr,
-		Handler:           applyHTTPMiddleware(cfg, mux),
+		Handler:           applyHTTPMiddleware(cfg, db, mux),
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       30 * time.Second,
 		WriteTimeout:      0,
@@ -684,7 +2728,7 @@ func StartWeb(cfg Config, db *sql.DB, lw *LogWriter) error {
 		MaxHeaderBytes:    1 << 20,
 	}
 
-	return srv.ListenAndServe()
+	return srv, life, nil
 }
 
 // ============================================================
@@ -699,6 +2743,35 @@ func mustSubFS(fsys fs.FS, dir string) fs.FS {
 	return sub
 }
 
+// parsePendingFactQuery reads sort / min_confidence / source_type / limit.
+func parsePendingFactQuery(r *http.Request) PendingFactQuery {
+	v := r.URL.Query()
+	q := PendingFactQuery{
+		Sort:       v.Get("sort"),
+		SourceType: strings.TrimSpace(v.Get("source_type")),
+		Limit:      parseIntClamp(v.Get("limit"), 60, 1, 500),
+		Refresh:    v.Get("refresh") == "1" || v.Get("refresh") == "true",
+		Rep:        v.Get("rep"),
+	}
+	if f, err := strconv.ParseFloat(strings.TrimSpace(v.Get("min_confidence")), 64); err == nil && f > 0 {
+		q.MinConfidence = f
+	}
+	return q
+}
+
+// parseAfterID reads the ?after_id= cursor of a paged list (0 = first page).
+func parseAfterID(r *http.Request) (int64, error) {
+	v := strings.TrimSpace(r.URL.Query().Get("after_id"))
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid after_id: %q", v)
+	}
+	return id, nil
+}
+
 func parseIntClamp(s string, def int, minV int, maxV int) int {
 	if strings.TrimSpace(s) == "" {
 		return def
@@ -729,6 +2802,21 @@ func decodeJSONLimited(w http.ResponseWriter, r *http.Request, v any, maxBytes i
 	return nil
 }
 
+// chatCommandFrames are the stream frames of a handled command (SSE and WebSocket).
+func chatCommandFrames(cmd, out string) []map[string]string {
+	// Facts ops are designed to be "silent" in chat: only refresh LEDs/counters.
+	out = strings.TrimSpace(out)
+	if cmd == "/remember" || cmd == "/forget" || cmd == "/pending_add" {
+		frames := []map[string]string{{"notice": "facts"}}
+		silentFacts := strings.HasPrefix(out, "[ok]") || strings.HasPrefix(out, "[noop]")
+		if !silentFacts && out != "" {
+			frames = append(frames, map[string]string{"delta": out})
+		}
+		return append(frames, map[string]string{"done": "1"})
+	}
+	return []map[string]string{{"delta": out}, {"done": "1"}}
+}
+
 func writeSSE(w http.ResponseWriter, fl http.Flusher, payload any) error {
 	b, err := json.Marshal(payload)
 	if err != nil {
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ============================================================
+// Web graceful shutdown
+// - StartWebContext serves until ctx is cancelled or the process gets
+//   SIGINT/SIGTERM, then shuts down in three steps:
+//     1. drain: stop accepting connections; requests in flight and chat
+//        streams (SSE and WebSocket turns) run to completion; new chat
+//        turns are refused; idle WebSockets are closed ("going away"),
+//        busy ones right after their turn.
+//     2. stop: whatever is still streaming after
+//        TIMELAYER_HTTP_SHUTDOWN_SECONDS (default 30) is cancelled, the same
+//        as a client cancel.
+//     3. the background workers (MustInit) are stopped and waited for, the
+//        LogWriter is flushed and closed, then the DB is closed.
+// - StartWeb is StartWebContext with context.Background().
+// ============================================================
+
+const (
+	defaultHTTPShutdownTimeout = 30 * time.Second
+	webStopGrace               = 3 * time.Second // after cancelling, wait this long for streams to return
+)
+
+var errWebShuttingDown = errors.New("server is shutting down")
+
+// webLifecycle lets the handlers follow the shutdown phases.
+type webLifecycle struct {
+	draining context.Context // done once shutdown starts
+	stopped  context.Context // done when the drain timeout has passed
+	drain    context.CancelFunc
+	stop     context.CancelFunc
+	active   atomic.Int64 // running chat streams and WebSocket connections
+}
+
+func newWebLifecycle() *webLifecycle {
+	l := &webLifecycle{}
+	l.draining, l.drain = context.WithCancel(context.Background())
+	l.stopped, l.stop = context.WithCancel(context.Background())
+	return l
+}
+
+func (l *webLifecycle) enter() { l.active.Add(1) }
+func (l *webLifecycle) leave() { l.active.Add(-1) }
+
+// isDraining reports whether new chat turns should be refused.
+func (l *webLifecycle) isDraining() bool { return l.draining.Err() != nil }
+
+// waitIdle waits until no stream is active or ctx is done.
+func (l *webLifecycle) waitIdle(ctx context.Context) bool {
+	t := time.NewTicker(50 * time.Millisecond)
+	defer t.Stop()
+	for l.active.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.C:
+		}
+	}
+	return true
+}
+
+// StartWebContext is StartWeb with graceful shutdown on ctx cancel or SIGINT/SIGTERM.
+// After a shutdown it has stopped the background workers, flushed and closed lw
+// and closed db; it returns nil then.
+func StartWebContext(ctx context.Context, cfg Config, db *sql.DB, lw *LogWriter) error {
+	srv, life, err := newWebServer(cfg, db, lw)
+	if srv == nil || err != nil {
+		return err
+	}
+
+	sigCtx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-sigCtx.Done():
+	}
+	stopSignals() // a second signal kills the process as usual
+
+	timeout := cfg.HTTPShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultHTTPShutdownTimeout
+	}
+	log.Printf("[info] web: shutting down (draining streams up to %s)", timeout)
+	shutdownWeb(srv, life, timeout)
+
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("[warn] web: %v", err)
+	}
+	stopBackgroundWorkers()
+	if lw != nil {
+		if err := lw.Flush(); err != nil {
+			log.Printf("[warn] web: flush log: %v", err)
+		}
+		lw.Close()
+	}
+	if err := closeDB(db); err != nil {
+		log.Printf("[warn] web: close db: %v", err)
+	}
+	log.Printf("[info] web: stopped")
+	return nil
+}
+
+// shutdownWeb drains srv for up to timeout, then cancels what is left.
+func shutdownWeb(srv *http.Server, life *webLifecycle, timeout time.Duration) {
+	life.drain()
+	dctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Shutdown waits for plain handlers (SSE included); hijacked WebSockets are tracked by life.
+	err := srv.Shutdown(dctx)
+	drained := life.waitIdle(dctx)
+	if err == nil && drained {
+		return
+	}
+
+	n := life.active.Load()
+	life.stop()
+	gctx, gcancel := context.WithTimeout(context.Background(), webStopGrace)
+	defer gcancel()
+	life.waitIdle(gctx)
+	_ = srv.Close()
+	log.Printf("[warn] web: drain timeout, cancelled %d stream(s)", n)
+}
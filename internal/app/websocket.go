@@ -0,0 +1,285 @@
+package app
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Minimal WebSocket server (RFC 6455), stdlib only
+// - Enough for /api/chat/ws: text frames, fragmentation, ping/pong and
+//   close. No extensions (permessage-deflate is never negotiated).
+// - Cross-origin upgrades are refused: a browser tab on another site must
+//   not reach the loopback API, which skips the token (checkAuthToken).
+//   Clients without an Origin header (desktop apps, curl) are accepted.
+// ============================================================
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal    = 1000
+	wsCloseGoingAway = 1001
+	wsCloseProtocol  = 1002
+	wsCloseTooBig    = 1009
+
+	wsWriteTimeout = 10 * time.Second
+)
+
+var errWSClosed = errors.New("websocket closed")
+
+type wsConn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	wmu      sync.Mutex
+	maxBytes int64
+	idle     time.Duration // max wait for any frame, pongs included (0 = none)
+	closed   bool
+}
+
+// wsAccept upgrades the request. On failure the HTTP error is already written.
+func wsAccept(w http.ResponseWriter, r *http.Request, maxBytes int64) (*wsConn, error) {
+	if r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("websocket upgrade required"))
+		return nil, errors.New("not a websocket upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		w.WriteHeader(http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := strings.TrimSpace(r.Header.Get("Sec-WebSocket-Key"))
+	if key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+	if !wsSameOrigin(r) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("cross-origin websocket refused"))
+		return nil, errors.New("cross-origin websocket")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, errors.New("http hijacker not supported")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// the server's ReadTimeout deadline is still set on the hijacked conn
+	_ = conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, br: brw.Reader, maxBytes: maxBytes}, nil
+}
+
+// headerHasToken reports whether a comma-separated header lists token (case-insensitive).
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsSameOrigin accepts a missing Origin (non-browser client) or one on the request host.
+func wsSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// ReadMessage returns the next text/binary message. Pings are answered,
+// a close frame is echoed and reported as errWSClosed.
+func (c *wsConn) ReadMessage() (int, []byte, error) {
+	var (
+		msgOp   int
+		msg     []byte
+		started bool
+	)
+	for {
+		if c.idle > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(c.idle))
+		}
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case wsOpPing:
+			_ = c.writeFrame(wsOpPong, payload)
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			_ = c.writeFrame(wsOpClose, payload)
+			return 0, nil, errWSClosed
+		case wsOpText, wsOpBinary:
+			if started {
+				c.CloseWith(wsCloseProtocol, "expected continuation frame")
+				return 0, nil, errWSClosed
+			}
+			msgOp, started = op, true
+		case wsOpContinuation:
+			if !started {
+				c.CloseWith(wsCloseProtocol, "unexpected continuation frame")
+				return 0, nil, errWSClosed
+			}
+		default:
+			c.CloseWith(wsCloseProtocol, "unknown opcode")
+			return 0, nil, errWSClosed
+		}
+		if int64(len(msg)+len(payload)) > c.maxBytes {
+			c.CloseWith(wsCloseTooBig, "message too big")
+			return 0, nil, errWSClosed
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msgOp, msg, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, op int, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.br, h[:]); err != nil {
+		return
+	}
+	fin = h[0]&0x80 != 0
+	op = int(h[0] & 0x0F)
+	if h[0]&0x70 != 0 {
+		c.CloseWith(wsCloseProtocol, "reserved bits set")
+		return false, 0, nil, errWSClosed
+	}
+	masked := h[1]&0x80 != 0
+	n := int64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint64(b[:]) & (1<<63 - 1))
+	}
+	if !masked {
+		// RFC 6455 5.1: client frames are always masked
+		c.CloseWith(wsCloseProtocol, "unmasked client frame")
+		return false, 0, nil, errWSClosed
+	}
+	if op >= wsOpClose && (n > 125 || !fin) {
+		c.CloseWith(wsCloseProtocol, "invalid control frame")
+		return false, 0, nil, errWSClosed
+	}
+	if n > c.maxBytes {
+		c.CloseWith(wsCloseTooBig, "message too big")
+		return false, 0, nil, errWSClosed
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// writeFrame sends one unfragmented, unmasked frame (safe for concurrent use).
+func (c *wsConn) writeFrame(op int, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return errWSClosed
+	}
+	buf := make([]byte, 0, len(payload)+10)
+	buf = append(buf, 0x80|byte(op))
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, 126, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	buf = append(buf, payload...)
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := c.conn.Write(buf)
+	if op == wsOpClose {
+		c.closed = true
+	}
+	return err
+}
+
+// WriteJSON sends payload as one text message.
+func (c *wsConn) WriteJSON(payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsOpText, b)
+}
+
+// Ping sends a keepalive ping.
+func (c *wsConn) Ping() error { return c.writeFrame(wsOpPing, nil) }
+
+// CloseWith sends a close frame (best effort); the caller still calls Close.
+func (c *wsConn) CloseWith(code int, reason string) {
+	if len(reason) > 120 {
+		reason = reason[:120]
+	}
+	b := binary.BigEndian.AppendUint16(nil, uint16(code))
+	_ = c.writeFrame(wsOpClose, append(b, reason...))
+}
+
+// Close closes the TCP connection.
+func (c *wsConn) Close() error { return c.conn.Close() }
@@ -0,0 +1,282 @@
+package app
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Full wipe ("start over")
+// - CLI: `local-ai wipe --confirm [--export[=DIR]]`
+// - API: POST /api/admin/wipe {"confirm":"WIPE","export":true}
+//   (off unless TIMELAYER_HTTP_ALLOW_WIPE=1; needs the auth token even
+//   from loopback when one is set)
+// - All memory tables are emptied in ONE transaction (nothing is deleted
+//   if any statement fails), then VACUUM so the pages are really gone,
+//   then LogDir (dialog logs, ops log, rollup JSON) and ArchiveDir.
+// - Archived days are deleted from the archive backend (local / s3 /
+//   webdav, see archive.go) before the tables are emptied.
+// - Settings survive: assistants, answer_style_profiles, prompts, glossary.
+// - --export first writes <BaseDir>/backups/timelayer-<ts>.tar.gz
+//   (DB snapshot + LogDir + ArchiveDir); a failed export aborts the wipe.
+// ============================================================
+
+const wipeConfirmPhrase = "WIPE"
+
+// wipeTables is every table holding memory (order: children first).
+var wipeTables = []string{
+	"embedding_retry",
+	"embeddings",
+	"summary_embeddings_history",
+	"embedding_model",
+	"summary_annotations",
+	"summary_tags",
+	"summary_warnings",
+	"summaries",
+	"summaries_fts",
+	"pending_fact_embeddings",
+	"pending_facts",
+	"user_fact_tags",
+	"user_fact_conflicts",
+	"user_facts_history",
+	"user_facts",
+	"fact_key_migrations",
+	"subject_aliases",
+	"messages",
+	"ops_log",
+	"prompts_log",
+	"context_audits",
+	"turn_feedback",
+	"archive_manifest",
+	"bg_jobs",
+	"llm_budget",
+	"email_ingest_state",
+	"sessions",
+}
+
+type WipeReport struct {
+	Rows      map[string]int64 `json:"rows"`
+	Dirs      []string         `json:"dirs"`
+	Export    string           `json:"export,omitempty"`
+	Archived  int              `json:"archive_objects,omitempty"` // objects deleted from the archive backend
+	StartedAt string           `json:"started_at"`
+}
+
+// defaultWipeExportDir is outside LogDir so the backup survives the wipe.
+func defaultWipeExportDir(cfg Config) string {
+	return filepath.Join(cfg.BaseDir, "backups")
+}
+
+// WipeAll deletes every log, archive, summary, embedding, fact and history row.
+// exportDir != "" writes a backup there first. lw may be nil (CLI before chat).
+func WipeAll(cfg Config, db *sql.DB, lw *LogWriter, exportDir string) (WipeReport, error) {
+	rep := WipeReport{Rows: map[string]int64{}, StartedAt: time.Now().In(cfg.Location).Format(time.RFC3339)}
+	if db == nil {
+		return rep, errors.New("db is nil")
+	}
+	if exportDir != "" {
+		path, err := exportBeforeWipe(cfg, db, exportDir)
+		if err != nil {
+			return rep, fmt.Errorf("export failed, nothing deleted: %w", err)
+		}
+		rep.Export = path
+	}
+	// archive objects first (remote backends are not covered by the dir removal);
+	// a failure leaves the manifest so nothing is orphaned
+	n, err := deleteArchiveObjects(cfg, db)
+	rep.Archived = n
+	if err != nil {
+		return rep, fmt.Errorf("archive cleanup failed, database not wiped: %w", err)
+	}
+
+	err = withTx(db, func(tx *sql.Tx) error {
+		for _, t := range wipeTables {
+			if t == "summaries_fts" && !ftsAvailable {
+				continue
+			}
+			res, err := tx.Exec(`DELETE FROM ` + t)
+			if err != nil {
+				return fmt.Errorf("wipe %s: %w", t, err)
+			}
+			n, _ := res.RowsAffected()
+			rep.Rows[t] = n
+		}
+		// restart AUTOINCREMENT ids (best-effort: sqlite_sequence may not exist)
+		_, _ = tx.Exec(`DELETE FROM sqlite_sequence WHERE name IN ('` + strings.Join(wipeTables, "','") + `')`)
+		return nil
+	})
+	if err != nil {
+		return rep, err
+	}
+	_ = loadSubjectAliases(db)
+	invalidatePendingGroups() // ids restart from 1
+	clearVectorIndex()
+	_, _ = db.Exec(`VACUUM`)
+	_, _ = db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+
+	wipeDirs := func() error {
+		for _, dir := range []string{cfg.LogDir, cfg.ArchiveDir} {
+			if strings.TrimSpace(dir) == "" {
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("remove %s: %w", dir, err)
+			}
+			rep.Dirs = append(rep.Dirs, dir)
+		}
+		mustEnsureDirs(cfg)
+		return nil
+	}
+	if lw != nil {
+		err = lw.withFilesClosed(wipeDirs)
+	} else {
+		err = wipeDirs()
+	}
+	return rep, err
+}
+
+// withFilesClosed runs fn under the writer lock with today's log closed;
+// the next WriteRecord reopens it (and recomputes messages.seq).
+func (lw *LogWriter) withFilesClosed(fn func() error) error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if lw.file != nil {
+		_ = lw.file.Close()
+		lw.file = nil
+	}
+	lw.currentDay = ""
+	return fn()
+}
+
+// exportBeforeWipe writes <dir>/timelayer-<ts>.tar.gz with a consistent DB
+// snapshot (VACUUM INTO) plus LogDir and ArchiveDir.
+func exportBeforeWipe(cfg Config, db *sql.DB, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	ts := time.Now().In(cfg.Location).Format("20060102-150405")
+	snap := filepath.Join(dir, "memory-"+ts+".sqlite")
+	if _, err := db.Exec(`VACUUM INTO ?`, snap); err != nil {
+		return "", fmt.Errorf("db snapshot: %w", err)
+	}
+	defer os.Remove(snap)
+
+	path := filepath.Join(dir, "timelayer-"+ts+".tar.gz")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	werr := addFileToTar(tw, snap, "memory.sqlite")
+	if werr == nil {
+		werr = addDirToTar(tw, cfg.LogDir, "logs")
+	}
+	// ArchiveDir usually lives inside LogDir (already included)
+	if rel, err := filepath.Rel(cfg.LogDir, cfg.ArchiveDir); werr == nil && (err != nil || strings.HasPrefix(rel, "..")) {
+		werr = addDirToTar(tw, cfg.ArchiveDir, "archive")
+	}
+	for _, c := range []io.Closer{tw, gz, f} {
+		if err := c.Close(); err != nil && werr == nil {
+			werr = err
+		}
+	}
+	if werr != nil {
+		_ = os.Remove(path)
+		return "", werr
+	}
+	return path, nil
+}
+
+func addFileToTar(tw *tar.Writer, src, name string) error {
+	st, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(st, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(name)
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func addDirToTar(tw *tar.Writer, root, prefix string) error {
+	if _, err := os.Stat(root); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		return addFileToTar(tw, p, filepath.Join(prefix, rel))
+	})
+}
+
+// runWipeCLI implements `local-ai wipe --confirm [--export[=DIR]]`.
+func runWipeCLI(cfg Config, args []string) int {
+	confirm, exportDir := false, ""
+	for _, a := range args {
+		switch {
+		case a == "--confirm":
+			confirm = true
+		case a == "--export":
+			exportDir = defaultWipeExportDir(cfg)
+		case strings.HasPrefix(a, "--export="):
+			exportDir = strings.TrimSpace(strings.TrimPrefix(a, "--export="))
+		default:
+			fmt.Println("unknown flag:", a)
+			return 2
+		}
+	}
+	if !confirm {
+		fmt.Println("usage: local-ai wipe --confirm [--export[=DIR]]")
+		fmt.Println("Deletes ALL memory: raw logs + archives (" + cfg.LogDir + ", " + cfg.ArchiveDir + "),")
+		fmt.Println("summaries, embeddings, facts, pending, history, prompts_log (" + cfg.DBPath + ").")
+		fmt.Println("Assistants, answer style profiles, prompts and the glossary are kept. --export writes a backup first.")
+		return 2
+	}
+
+	db := mustOpenDB(cfg)
+	defer closeDB(db)
+	rep, err := WipeAll(cfg, db, nil, exportDir)
+	if err != nil {
+		fmt.Println("[error] wipe:", err)
+		return 1
+	}
+	var rows int64
+	for _, n := range rep.Rows {
+		rows += n
+	}
+	if rep.Export != "" {
+		fmt.Println("[ok] backup:", rep.Export)
+	}
+	if rep.Archived > 0 {
+		fmt.Printf("[ok] deleted %d archived days from the %s archive\n", rep.Archived, cfg.ArchiveBackend)
+	}
+	fmt.Printf("[ok] wiped %d rows and %s\n", rows, strings.Join(rep.Dirs, ", "))
+	return 0
+}
@@ -0,0 +1,373 @@
+// Package fakellama is a scripted stand-in for llama-server: the chat
+// (OpenAI-style, plain and SSE), embedding and rerank endpoints the app
+// talks to. It backs the pipeline tests (internal/app/pipeline_test.go) and
+// can drive any other end-to-end check without a model.
+package fakellama
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// Fake llama-server
+// - POST /v1/chat/completions  {"messages":[...],"stream":bool}
+// - POST /embedding            {"input":"..."} → {"embedding":[...]}
+//                               {"input":["a","b"]} → [{"index":0,"embedding":[[...]]},...]
+// - POST /v1/rerank_text       {"query","documents"} → {"scores":[...]}
+// - Rules (On) are tried in order: the first whose endpoint matches and
+//   whose Match is a substring of the request text answers. Times limits
+//   how often a rule fires (0 = always).
+// - Without a rule: chat answers DefaultChat, embeddings are a
+//   deterministic bag-of-characters vector (texts sharing words are
+//   similar), rerank scores the share of query characters in a document.
+// - Replies can fail on purpose: Status (HTTP error), Raw (malformed
+//   body), Delay (timeouts), Chunks (SSE deltas, cut short with Raw).
+// ============================================================
+
+const (
+	Chat   = "chat"
+	Embed  = "embed"
+	Rerank = "rerank"
+
+	embedDim = 64
+)
+
+// Reply is a scripted answer.
+type Reply struct {
+	Status int           // HTTP status; 0 = 200
+	Body   string        // chat: the assistant content; with Raw: the whole response body
+	Raw    bool          // write Body as-is (malformed JSON, odd shapes, a cut-off stream)
+	Chunks []string      // chat stream deltas (default: Body split in a few pieces)
+	Delay  time.Duration // wait before answering
+}
+
+// Rule answers requests to Endpoint whose text contains Match.
+type Rule struct {
+	Endpoint string
+	Match    string
+	Times    int // 0 = unlimited
+	Reply    Reply
+
+	used int
+}
+
+// Request is a recorded call.
+type Request struct {
+	Endpoint string
+	Text     string // chat: the message contents; embed: the input (batch: one per line); rerank: the query
+	Stream   bool
+}
+
+type Server struct {
+	URL         string
+	DefaultChat string
+
+	srv      *httptest.Server
+	mu       sync.Mutex
+	rules    []*Rule
+	requests []Request
+}
+
+// New starts a fake server on a loopback port.
+func New() *Server {
+	s := &Server{DefaultChat: "好的。"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", s.handleChat)
+	mux.HandleFunc("/embedding", s.handleEmbed)
+	mux.HandleFunc("/v1/rerank_text", s.handleRerank)
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL
+	return s
+}
+
+func (s *Server) Close() { s.srv.Close() }
+
+func (s *Server) ChatURL() string   { return s.URL + "/v1/chat/completions" }
+func (s *Server) EmbedURL() string  { return s.URL + "/embedding" }
+func (s *Server) RerankURL() string { return s.URL + "/v1/rerank_text" }
+
+// On adds a rule; later rules are tried after earlier ones.
+func (s *Server) On(endpoint, match string, r Reply) *Rule {
+	rule := &Rule{Endpoint: endpoint, Match: match, Reply: r}
+	s.mu.Lock()
+	s.rules = append(s.rules, rule)
+	s.mu.Unlock()
+	return rule
+}
+
+// Once is On for a rule that fires a single time.
+func (s *Server) Once(endpoint, match string, r Reply) *Rule {
+	rule := s.On(endpoint, match, r)
+	s.mu.Lock()
+	rule.Times = 1
+	s.mu.Unlock()
+	return rule
+}
+
+// Reset drops every rule and recorded request.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.rules, s.requests = nil, nil
+	s.mu.Unlock()
+}
+
+// Requests returns the recorded calls to endpoint ("" = all).
+func (s *Server) Requests(endpoint string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Request
+	for _, r := range s.requests {
+		if endpoint == "" || r.Endpoint == endpoint {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// match records the request and returns the first rule that answers it.
+func (s *Server) match(req Request) (Reply, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	for _, r := range s.rules {
+		if r.Endpoint != req.Endpoint || !strings.Contains(req.Text, r.Match) {
+			continue
+		}
+		if r.Times > 0 && r.used >= r.Times {
+			continue
+		}
+		r.used++
+		return r.Reply, true
+	}
+	return Reply{}, false
+}
+
+// answer handles Delay / Status / Raw; it reports whether the reply is fully written.
+func answer(w http.ResponseWriter, r *http.Request, rep Reply) bool {
+	if rep.Delay > 0 {
+		select {
+		case <-time.After(rep.Delay):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if rep.Status != 0 && rep.Status != http.StatusOK {
+		w.WriteHeader(rep.Status)
+		_, _ = w.Write([]byte(rep.Body))
+		return true
+	}
+	if rep.Raw && len(rep.Chunks) == 0 {
+		_, _ = w.Write([]byte(rep.Body))
+		return true
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+		Stream bool `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var text strings.Builder
+	for _, m := range req.Messages {
+		text.WriteString(m.Content)
+		text.WriteString("\n")
+	}
+	rep, ok := s.match(Request{Endpoint: Chat, Text: text.String(), Stream: req.Stream})
+	if !ok {
+		rep = Reply{Body: s.DefaultChat}
+	}
+	if answer(w, r, rep) {
+		return
+	}
+
+	if !req.Stream {
+		writeJSON(w, map[string]any{
+			"choices": []any{map[string]any{
+				"index":   0,
+				"message": map[string]string{"role": "assistant", "content": rep.Body},
+			}},
+		})
+		return
+	}
+
+	chunks := rep.Chunks
+	if len(chunks) == 0 {
+		chunks = splitChunks(rep.Body, 3)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	fl, _ := w.(http.Flusher)
+	for _, c := range chunks {
+		b, _ := json.Marshal(map[string]any{
+			"choices": []any{map[string]any{"index": 0, "delta": map[string]string{"content": c}}},
+		})
+		fmt.Fprintf(w, "data: %s\n\n", b)
+		if fl != nil {
+			fl.Flush()
+		}
+	}
+	if rep.Raw {
+		return // cut off: no [DONE]
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input   any `json:"input"`
+		Content any `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in := req.Input
+	if in == nil {
+		in = req.Content
+	}
+	text := fmt.Sprint(in)
+	var batch []string
+	if arr, ok := in.([]any); ok && len(arr) > 0 {
+		text = fmt.Sprint(arr[0])
+		if len(arr) > 1 {
+			for _, a := range arr {
+				batch = append(batch, fmt.Sprint(a))
+			}
+			text = strings.Join(batch, "\n")
+		}
+	}
+	rep, ok := s.match(Request{Endpoint: Embed, Text: text})
+	if ok && answer(w, r, rep) {
+		return
+	}
+	if ok && rep.Body != "" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(rep.Body))
+		return
+	}
+	if len(batch) > 0 {
+		out := make([]map[string]any, len(batch))
+		for i, t := range batch {
+			out[i] = map[string]any{"index": i, "embedding": [][]float64{Vector(t)}}
+		}
+		writeJSON(w, out)
+		return
+	}
+	writeJSON(w, map[string]any{"embedding": Vector(text)})
+}
+
+func (s *Server) handleRerank(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string   `json:"query"`
+		Documents []string `json:"documents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rep, ok := s.match(Request{Endpoint: Rerank, Text: req.Query})
+	if ok && answer(w, r, rep) {
+		return
+	}
+	if ok && rep.Body != "" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(rep.Body))
+		return
+	}
+	scores := make([]float64, len(req.Documents))
+	for i, d := range req.Documents {
+		scores[i] = overlap(req.Query, d)
+	}
+	writeJSON(w, map[string]any{"scores": scores})
+}
+
+// Vector is the default embedding: runes and rune pairs hashed into
+// embedDim buckets, L2-normalized. Deterministic across runs.
+func Vector(text string) []float64 {
+	v := make([]float64, embedDim)
+	rs := []rune(strings.ToLower(text))
+	add := func(s string) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(s))
+		v[h.Sum32()%embedDim]++
+	}
+	for i, r := range rs {
+		if r == ' ' || r == '\n' || r == '\t' {
+			continue
+		}
+		add(string(r))
+		if i+1 < len(rs) {
+			add(string(rs[i : i+2]))
+		}
+	}
+	var n float64
+	for _, x := range v {
+		n += x * x
+	}
+	if n == 0 {
+		v[0] = 1
+		return v
+	}
+	n = math.Sqrt(n)
+	for i := range v {
+		v[i] /= n
+	}
+	return v
+}
+
+// overlap is the share of the query's distinct runes found in doc.
+func overlap(query, doc string) float64 {
+	seen := map[rune]bool{}
+	hit := 0
+	for _, r := range query {
+		if r == ' ' || seen[r] {
+			continue
+		}
+		seen[r] = true
+		if strings.ContainsRune(doc, r) {
+			hit++
+		}
+	}
+	if len(seen) == 0 {
+		return 0
+	}
+	return float64(hit) / float64(len(seen))
+}
+
+// splitChunks cuts s into about n rune-aligned pieces.
+func splitChunks(s string, n int) []string {
+	rs := []rune(s)
+	if len(rs) == 0 {
+		return []string{""}
+	}
+	size := (len(rs) + n - 1) / n
+	var out []string
+	for i := 0; i < len(rs); i += size {
+		end := i + size
+		if end > len(rs) {
+			end = len(rs)
+		}
+		out = append(out, string(rs[i:end]))
+	}
+	return out
+}